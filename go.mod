@@ -8,6 +8,7 @@ require (
 	github.com/cristalhq/synx v0.8.0
 	github.com/cristalhq/testt v0.0.1
 	github.com/redis/go-redis/v9 v9.5.3
+	github.com/tidwall/btree v1.1.0
 	github.com/tidwall/redcon v1.6.2
 )
 
@@ -34,7 +35,6 @@ require (
 	github.com/prometheus/common v0.32.1 // indirect
 	github.com/prometheus/procfs v0.7.3 // indirect
 	github.com/rogpeppe/go-internal v1.9.0 // indirect
-	github.com/tidwall/btree v1.1.0 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	golang.org/x/exp v0.0.0-20230626212559-97b1e661b5df // indirect
 	golang.org/x/sys v0.11.0 // indirect
@@ -0,0 +1,300 @@
+package core
+
+import (
+	"math"
+	"math/bits"
+)
+
+// BitRange is a range of BITCOUNT and BITPOS, Start and End are inclusive
+// and negative values are counted from the end of the value.
+type BitRange struct {
+	Start, End int64
+	// Bit means Start and End are offsets in bits rather than in bytes.
+	Bit bool
+}
+
+// FullBitRange covers the whole value.
+var FullBitRange = BitRange{Start: 0, End: -1}
+
+// Empty reports whether both ends are negative and Start is after End,
+// BITCOUNT replies 0 for such a range regardless of the value length.
+func (r BitRange) Empty() bool {
+	return r.Start < 0 && r.End < 0 && r.Start > r.End
+}
+
+// Bits resolves the range for a value of n bytes into bit offsets (both inclusive),
+// false is returned if the range is empty.
+func (r BitRange) Bits(n int64) (first, last int64, ok bool) {
+	total := n
+	if r.Bit {
+		total *= 8
+	}
+	start, end := r.Start, r.End
+	if start < 0 {
+		start += total
+	}
+	if end < 0 {
+		end += total
+	}
+	start, end = max(start, 0), max(end, 0)
+	end = min(end, total-1)
+	if start > end {
+		return 0, 0, false
+	}
+	if r.Bit {
+		return start, end, true
+	}
+	return start * 8, end*8 + 7, true
+}
+
+// BitCount returns the number of set bits between bit offsets first and last (both inclusive),
+// last must be within the value.
+func BitCount(val []byte, first, last int64) int64 {
+	firstByte, lastByte := first>>3, last>>3
+	if firstByte == lastByte {
+		return int64(bits.OnesCount8(val[firstByte] & headMask(first) & tailMask(last)))
+	}
+
+	n := bits.OnesCount8(val[firstByte] & headMask(first))
+	n += bits.OnesCount8(val[lastByte] & tailMask(last))
+	for _, c := range val[firstByte+1 : lastByte] {
+		n += bits.OnesCount8(c)
+	}
+	return int64(n)
+}
+
+// BitPos returns the offset of the first bit set to bit between bit offsets first and last (both inclusive),
+// -1 is returned if there is none. Last must be within the value.
+func BitPos(val []byte, bit int, first, last int64) int64 {
+	for i := first >> 3; i <= last>>3; i++ {
+		c := val[i]
+		// clear bits are looked for as set bits of the inverted byte.
+		if bit == 0 {
+			c = ^c
+		}
+		if i == first>>3 {
+			c &= headMask(first)
+		}
+		if i == last>>3 {
+			c &= tailMask(last)
+		}
+		if c != 0 {
+			return i*8 + int64(bits.LeadingZeros8(c))
+		}
+	}
+	return -1
+}
+
+// headMask keeps bits of a byte starting at the bit offset.
+func headMask(offset int64) byte {
+	return 0xff >> (offset & 7)
+}
+
+// tailMask keeps bits of a byte up to the bit offset (inclusive).
+func tailMask(offset int64) byte {
+	return 0xff << (7 - offset&7)
+}
+
+// GetBit returns the bit at offset, bits beyond the value are zeros.
+func GetBit(val []byte, offset int64) int {
+	if offset>>3 >= int64(len(val)) {
+		return 0
+	}
+	return int(val[offset>>3]>>(7-offset&7)) & 1
+}
+
+// SetBit sets the bit at offset and returns its old value, the value must be long enough.
+func SetBit(val []byte, offset int64, bit int) int {
+	old := GetBit(val, offset)
+	mask := byte(1) << (7 - offset&7)
+	if bit == 1 {
+		val[offset>>3] |= mask
+	} else {
+		val[offset>>3] &^= mask
+	}
+	return old
+}
+
+// BitOp is an operation of BITOP command.
+type BitOp int
+
+const (
+	BitAnd BitOp = iota
+	BitOr
+	BitXor
+	BitNot
+)
+
+// Apply writes the result of the operation over srcs into dst,
+// sources shorter than dst are padded with zeros.
+func (op BitOp) Apply(dst []byte, srcs [][]byte) {
+	if op == BitNot {
+		for i := range dst {
+			dst[i] = ^byteAt(srcs[0], i)
+		}
+		return
+	}
+	for i := range dst {
+		c := byteAt(srcs[0], i)
+		for _, src := range srcs[1:] {
+			switch op {
+			case BitAnd:
+				c &= byteAt(src, i)
+			case BitOr:
+				c |= byteAt(src, i)
+			case BitXor:
+				c ^= byteAt(src, i)
+			}
+		}
+		dst[i] = c
+	}
+}
+
+func byteAt(val []byte, i int) byte {
+	if i < len(val) {
+		return val[i]
+	}
+	return 0
+}
+
+// BitFieldKind is a kind of BITFIELD subcommand.
+type BitFieldKind int
+
+const (
+	BitFieldGet BitFieldKind = iota
+	BitFieldSet
+	BitFieldIncrBy
+)
+
+// BitFieldOverflow is an overflow behavior of BITFIELD writes.
+type BitFieldOverflow int
+
+const (
+	BitFieldWrap BitFieldOverflow = iota
+	BitFieldSat
+	BitFieldFail
+)
+
+// BitFieldOp is a single BITFIELD subcommand.
+type BitFieldOp struct {
+	Kind BitFieldKind
+	// Signed and Width are the integer type, up to i64 and u63.
+	Signed bool
+	Width  int
+	// Offset is in bits.
+	Offset int64
+	// Value to set or to increment by.
+	Value    int64
+	Overflow BitFieldOverflow
+}
+
+// End returns the number of bytes the value must have to hold the field.
+func (op BitFieldOp) End() int64 {
+	return (op.Offset+int64(op.Width)-1)>>3 + 1
+}
+
+// Exec runs the subcommand over the value and returns its reply,
+// nil means the write overflowed with FAIL and the value wasn't changed.
+// Writes require the value to be at least End bytes long.
+func (op BitFieldOp) Exec(val []byte) *int64 {
+	old := getBitField(val, op.Offset, op.Width, op.Signed)
+	if op.Kind == BitFieldGet {
+		return &old
+	}
+
+	var res, next int64
+	var overflow bool
+	if op.Kind == BitFieldIncrBy {
+		next, overflow = op.fit(old, op.Value)
+		res = next
+	} else {
+		next, overflow = op.fit(op.Value, 0)
+		res = old
+	}
+	if overflow && op.Overflow == BitFieldFail {
+		return nil
+	}
+	setBitField(val, op.Offset, op.Width, uint64(next))
+	return &res
+}
+
+// fit returns value+incr handled according to the overflow behavior and
+// reports whether it overflowed, like checkSignedBitfieldOverflow and
+// checkUnsignedBitfieldOverflow of Redis do.
+func (op BitFieldOp) fit(value, incr int64) (int64, bool) {
+	if !op.Signed {
+		return op.fitUnsigned(uint64(value), incr)
+	}
+
+	maxVal := int64(math.MaxInt64)
+	if op.Width < 64 {
+		maxVal = int64(1)<<(op.Width-1) - 1
+	}
+	minVal := -maxVal - 1
+	// maxIncr and minIncr may overflow, they are used only after value is checked.
+	maxIncr := int64(uint64(maxVal) - uint64(value))
+	minIncr := minVal - value
+
+	var limit int64
+	switch {
+	case value > maxVal || (op.Width != 64 && incr > maxIncr) || (value >= 0 && incr > 0 && incr > maxIncr):
+		limit = maxVal
+	case value < minVal || (op.Width != 64 && incr < minIncr) || (value < 0 && incr < 0 && incr < minIncr):
+		limit = minVal
+	default:
+		return value + incr, false
+	}
+	if op.Overflow != BitFieldWrap {
+		return limit, true
+	}
+
+	res := uint64(value) + uint64(incr)
+	if op.Width < 64 {
+		// propagate the sign bit to the higher bits.
+		mask := ^uint64(0) << op.Width
+		if res&(1<<(op.Width-1)) != 0 {
+			res |= mask
+		} else {
+			res &^= mask
+		}
+	}
+	return int64(res), true
+}
+
+func (op BitFieldOp) fitUnsigned(value uint64, incr int64) (int64, bool) {
+	maxVal := uint64(1)<<op.Width - 1
+	maxIncr := int64(maxVal - value)
+	minIncr := -int64(value)
+
+	var limit uint64
+	switch {
+	case value > maxVal || (incr > 0 && incr > maxIncr):
+		limit = maxVal
+	case incr < 0 && incr < minIncr:
+		limit = 0
+	default:
+		return int64(value + uint64(incr)), false
+	}
+	if op.Overflow != BitFieldWrap {
+		return int64(limit), true
+	}
+	return int64((value + uint64(incr)) & maxVal), true
+}
+
+func getBitField(val []byte, offset int64, width int, signed bool) int64 {
+	var res uint64
+	for i := int64(0); i < int64(width); i++ {
+		res = res<<1 | uint64(GetBit(val, offset+i))
+	}
+	if signed && width < 64 && res&(1<<(width-1)) != 0 {
+		res |= ^uint64(0) << width
+	}
+	return int64(res)
+}
+
+func setBitField(val []byte, offset int64, width int, value uint64) {
+	for i := 0; i < width; i++ {
+		bit := int(value>>(width-1-i)) & 1
+		SetBit(val, offset+int64(i), bit)
+	}
+}
@@ -0,0 +1,257 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+)
+
+// Bloom filters https://redis.io/docs/data-types/probabilistic/bloom-filter/
+
+var (
+	ErrBloomFull      = NewError(PrefixErr, "non scaling filter is full")
+	ErrBloomErrorRate = NewError(PrefixErr, "(0 < error rate range < 1)")
+	ErrBloomCapacity  = NewError(PrefixErr, "(capacity should be larger than 0)")
+	ErrBloomTooBig    = NewError(PrefixErr, "Insufficient memory to create filter")
+	ErrFilterExists   = NewError(PrefixErr, "item exists")
+	ErrFilterNotFound = NewError(PrefixErr, "not found")
+
+	errCorruptedBloom = errors.New("corrupted Bloom filter")
+)
+
+// Defaults of filters created by BF.ADD and BF.MADD, like in RedisBloom.
+const (
+	BloomDefaultErrorRate = 0.01
+	BloomDefaultCapacity  = 100
+	BloomDefaultExpansion = 2
+)
+
+// bloomMaxSize is the maximum size of a layer bit array in bytes, like the maximum size of a string.
+const bloomMaxSize = 512 << 20
+
+// bloomTightening is the ratio of error rates of consecutive layers,
+// so the error rate of the whole chain stays below the requested one.
+const bloomTightening = 0.5
+
+// FilterStorage reads and writes arrays of filter layers. Stores keep arrays in chunks,
+// so operations touch only the parts of arrays they need. Bytes never written are zeros.
+type FilterStorage interface {
+	ReadAt(layer int, offset int64, buf []byte) error
+	WriteAt(layer int, offset int64, data []byte) error
+}
+
+// BloomOptions are options of BF.RESERVE command.
+type BloomOptions struct {
+	// ErrorRate is the false positive rate, it's in (0, 1).
+	ErrorRate float64
+	// Capacity is the number of items of the first layer.
+	Capacity int64
+	// Expansion is the ratio of capacities of consecutive layers.
+	Expansion int64
+	// NonScaling filters have a single layer and fail once it's full.
+	NonScaling bool
+}
+
+// BloomInfo is a reply of BF.INFO command.
+type BloomInfo struct {
+	// Capacity is the total number of items of all layers.
+	Capacity int64
+	// Size is the number of bytes of bit arrays.
+	Size    int64
+	Filters int64
+	Items   int64
+	// Expansion is zero for non scaling filters.
+	Expansion int64
+}
+
+// BloomFilter is a scalable Bloom filter: a chain of layers with growing capacities
+// and tightening error rates, items are added to the last one. Bit arrays of layers
+// are kept in FilterStorage, the filter itself is a small header.
+type BloomFilter struct {
+	opts   BloomOptions
+	layers []bloomLayer
+}
+
+type bloomLayer struct {
+	capacity, items int64
+	bits            uint64
+	hashes          uint64
+}
+
+// NewBloomFilter returns an empty filter with a single layer,
+// ErrBloomTooBig is returned if its bit array is larger than bloomMaxSize.
+func NewBloomFilter(opts BloomOptions) (*BloomFilter, error) {
+	switch {
+	case !(opts.ErrorRate > 0 && opts.ErrorRate < 1):
+		return nil, ErrBloomErrorRate
+	case opts.Capacity <= 0:
+		return nil, ErrBloomCapacity
+	}
+	f := &BloomFilter{opts: opts}
+	if err := f.addLayer(opts.Capacity, opts.ErrorRate); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// addLayer adds a layer sized like in RedisBloom: bits per item are -ln(p)/ln(2)^2
+// and the number of hashes is ln(2) times bits per item.
+// The size is checked before conversion, so huge capacities can't overflow it.
+func (f *BloomFilter) addLayer(capacity int64, errorRate float64) error {
+	bpe := -math.Log(errorRate) / (math.Ln2 * math.Ln2)
+	bits := math.Ceil(float64(capacity) * bpe)
+	if !(bits <= bloomMaxSize*8) {
+		return ErrBloomTooBig
+	}
+	f.layers = append(f.layers, bloomLayer{
+		capacity: capacity,
+		bits:     (uint64(bits) + 7) &^ 7,
+		hashes:   uint64(math.Ceil(math.Ln2 * bpe)),
+	})
+	return nil
+}
+
+// Add adds the item and reports whether it's new, a full last layer is followed by a new one.
+func (f *BloomFilter) Add(st FilterStorage, item []byte) (bool, error) {
+	a, b := bloomHash(item)
+	for i := range f.layers {
+		found, err := f.layers[i].test(st, i, a, b, false)
+		if err != nil || found {
+			return false, err
+		}
+	}
+
+	last := &f.layers[len(f.layers)-1]
+	if last.items >= last.capacity {
+		if f.opts.NonScaling || last.capacity > math.MaxInt64/f.opts.Expansion {
+			return false, ErrBloomFull
+		}
+		tightening := math.Pow(bloomTightening, float64(len(f.layers)))
+		if err := f.addLayer(last.capacity*f.opts.Expansion, f.opts.ErrorRate*tightening); err != nil {
+			return false, err
+		}
+		last = &f.layers[len(f.layers)-1]
+	}
+	if _, err := last.test(st, len(f.layers)-1, a, b, true); err != nil {
+		return false, err
+	}
+	last.items++
+	return true, nil
+}
+
+// Exists reports whether the item may have been added.
+func (f *BloomFilter) Exists(st FilterStorage, item []byte) (bool, error) {
+	a, b := bloomHash(item)
+	for i := len(f.layers) - 1; i >= 0; i-- {
+		found, err := f.layers[i].test(st, i, a, b, false)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// Info returns stats of the filter.
+func (f *BloomFilter) Info() BloomInfo {
+	info := BloomInfo{Filters: int64(len(f.layers))}
+	if !f.opts.NonScaling {
+		info.Expansion = f.opts.Expansion
+	}
+	for _, l := range f.layers {
+		info.Capacity += l.capacity
+		info.Size += int64(l.bits / 8)
+		info.Items += l.items
+	}
+	return info
+}
+
+// Clone returns a copy of the header.
+func (f *BloomFilter) Clone() *BloomFilter {
+	return &BloomFilter{opts: f.opts, layers: slices.Clone(f.layers)}
+}
+
+// test reports whether all bits of the item are set in the layer, unset ones are set if set is true.
+func (l *bloomLayer) test(st FilterStorage, layer int, a, b uint64, set bool) (bool, error) {
+	found := true
+	var buf [1]byte
+	for i := uint64(0); i < l.hashes; i++ {
+		pos := (a + i*b) % l.bits
+		if err := st.ReadAt(layer, int64(pos>>3), buf[:]); err != nil {
+			return false, err
+		}
+		mask := byte(1) << (pos & 7)
+		if buf[0]&mask != 0 {
+			continue
+		}
+		found = false
+		if !set {
+			return false, nil
+		}
+		buf[0] |= mask
+		if err := st.WriteAt(layer, int64(pos>>3), buf[:]); err != nil {
+			return false, err
+		}
+	}
+	return found, nil
+}
+
+// bloomHash returns hashes bit positions are derived from, like in RedisBloom.
+func bloomHash(item []byte) (uint64, uint64) {
+	a := murmurHash64A(item, 0xc6a4a7935bd1e995)
+	return a, murmurHash64A(item, a)
+}
+
+// Encode returns the header of the filter, bit arrays are not included.
+func (f *BloomFilter) Encode() []byte {
+	res := make([]byte, 0, 8+8+8+1+4+len(f.layers)*32)
+	res = binary.BigEndian.AppendUint64(res, math.Float64bits(f.opts.ErrorRate))
+	res = binary.BigEndian.AppendUint64(res, uint64(f.opts.Capacity))
+	res = binary.BigEndian.AppendUint64(res, uint64(f.opts.Expansion))
+	if f.opts.NonScaling {
+		res = append(res, 1)
+	} else {
+		res = append(res, 0)
+	}
+	res = binary.BigEndian.AppendUint32(res, uint32(len(f.layers)))
+	for _, l := range f.layers {
+		res = binary.BigEndian.AppendUint64(res, uint64(l.capacity))
+		res = binary.BigEndian.AppendUint64(res, uint64(l.items))
+		res = binary.BigEndian.AppendUint64(res, l.bits)
+		res = binary.BigEndian.AppendUint64(res, l.hashes)
+	}
+	return res
+}
+
+// DecodeBloomFilter decodes a header returned by Encode.
+func DecodeBloomFilter(b []byte) (*BloomFilter, error) {
+	const hdr = 8 + 8 + 8 + 1 + 4
+	if len(b) < hdr {
+		return nil, errCorruptedBloom
+	}
+	f := &BloomFilter{opts: BloomOptions{
+		ErrorRate:  math.Float64frombits(binary.BigEndian.Uint64(b)),
+		Capacity:   int64(binary.BigEndian.Uint64(b[8:])),
+		Expansion:  int64(binary.BigEndian.Uint64(b[16:])),
+		NonScaling: b[24] == 1,
+	}}
+	n := int(binary.BigEndian.Uint32(b[25:]))
+	b = b[hdr:]
+	if n == 0 || len(b) != n*32 {
+		return nil, errCorruptedBloom
+	}
+	f.layers = make([]bloomLayer, n)
+	for i := range f.layers {
+		f.layers[i] = bloomLayer{
+			capacity: int64(binary.BigEndian.Uint64(b)),
+			items:    int64(binary.BigEndian.Uint64(b[8:])),
+			bits:     binary.BigEndian.Uint64(b[16:]),
+			hashes:   binary.BigEndian.Uint64(b[24:]),
+		}
+		if f.layers[i].bits == 0 {
+			return nil, errCorruptedBloom
+		}
+		b = b[32:]
+	}
+	return f, nil
+}
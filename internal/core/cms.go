@@ -0,0 +1,206 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Count-Min sketches https://redis.io/docs/data-types/probabilistic/count-min-sketch/
+
+var (
+	ErrCMSExists        = NewError(PrefixCMS, "key already exists")
+	ErrCMSNotFound      = NewError(PrefixCMS, "key does not exist")
+	ErrCMSOverflow      = NewError(PrefixCMS, "INCRBY overflow")
+	ErrCMSMergeOverflow = NewError(PrefixCMS, "MERGE overflow")
+	ErrCMSDimensions    = NewError(PrefixCMS, "width/depth is not equal")
+	ErrCMSNumber        = NewError(PrefixCMS, "Cannot parse number")
+	ErrCMSWidth         = NewError(PrefixCMS, "invalid width")
+	ErrCMSDepth         = NewError(PrefixCMS, "invalid depth")
+	ErrCMSErrorRate     = NewError(PrefixCMS, "invalid overestimation value")
+	ErrCMSProb          = NewError(PrefixCMS, "invalid prob value")
+	ErrCMSNumKeys       = NewError(PrefixCMS, "invalid numkeys")
+	ErrCMSWeight        = NewError(PrefixCMS, "invalid weight value")
+
+	errCorruptedCMS = errors.New("corrupted Count-Min sketch")
+)
+
+// CountMinSketch is a Count-Min sketch: depth rows of width 32-bit counters,
+// an item increments a counter in each row and its count is the minimum of them.
+// Rows are kept in FilterStorage as layers, the sketch itself is a small header.
+type CountMinSketch struct {
+	width, depth int64
+	// count is the total of all increments.
+	count int64
+}
+
+// NewCountMinSketch returns an empty sketch.
+func NewCountMinSketch(width, depth int64) *CountMinSketch {
+	return &CountMinSketch{width: width, depth: depth}
+}
+
+// CMSDimsByProb returns dimensions of a sketch that overestimates counts by at most
+// errorRate of the total with the probability of prob, like RedisBloom does.
+func CMSDimsByProb(errorRate, prob float64) (width, depth int64) {
+	width = int64(math.Ceil(2 / errorRate))
+	depth = int64(math.Ceil(math.Log10(prob) / math.Log10(0.5)))
+	return width, depth
+}
+
+// IncrBy increments counters of the item and returns its count. Counters are not changed
+// if any of them would overflow.
+func (s *CountMinSketch) IncrBy(st FilterStorage, item []byte, by int64) (int64, error) {
+	counters := make([]int64, s.depth)
+	for row := range counters {
+		n, err := s.counter(st, row, item)
+		if err != nil {
+			return 0, err
+		}
+		if n+by > math.MaxUint32 {
+			return 0, ErrCMSOverflow
+		}
+		counters[row] = n + by
+	}
+
+	res := int64(math.MaxUint32)
+	for row, n := range counters {
+		var buf [4]byte
+		binary.BigEndian.PutUint32(buf[:], uint32(n))
+		if err := st.WriteAt(row, s.offset(row, item), buf[:]); err != nil {
+			return 0, err
+		}
+		res = min(res, n)
+	}
+	s.count += by
+	return res, nil
+}
+
+// Query returns the count of the item, it's never less than the real one.
+func (s *CountMinSketch) Query(st FilterStorage, item []byte) (int64, error) {
+	res := int64(math.MaxUint32)
+	for row := 0; row < int(s.depth); row++ {
+		n, err := s.counter(st, row, item)
+		if err != nil {
+			return 0, err
+		}
+		res = min(res, n)
+	}
+	return res, nil
+}
+
+// Merge replaces counters of the sketch with sums of counters of srcs multiplied by weights.
+// Sums are checked for overflow before any counter is changed. Rows are merged one at a time,
+// so the sketch may be one of srcs.
+func (s *CountMinSketch) Merge(st FilterStorage, srcs []*CountMinSketch, srcSts []FilterStorage, weights []int64) error {
+	count := int64(0)
+	for i, src := range srcs {
+		if src.width != s.width || src.depth != s.depth {
+			return ErrCMSDimensions
+		}
+		count += src.count * weights[i]
+	}
+
+	sums := make([]int64, s.width)
+	row := make([]byte, 4*s.width)
+	for _, write := range [2]bool{false, true} {
+		for r := 0; r < int(s.depth); r++ {
+			clear(sums)
+			for i := range srcs {
+				if err := srcSts[i].ReadAt(r, 0, row); err != nil {
+					return err
+				}
+				for j := range sums {
+					sums[j] += int64(binary.BigEndian.Uint32(row[4*j:])) * weights[i]
+				}
+			}
+			for j, n := range sums {
+				if n < 0 || n > math.MaxUint32 {
+					return ErrCMSMergeOverflow
+				}
+				binary.BigEndian.PutUint32(row[4*j:], uint32(n))
+			}
+			if !write {
+				continue
+			}
+			if err := st.WriteAt(r, 0, row); err != nil {
+				return err
+			}
+		}
+	}
+	s.count = count
+	return nil
+}
+
+// Clone returns a copy of the header.
+func (s *CountMinSketch) Clone() *CountMinSketch {
+	res := *s
+	return &res
+}
+
+func (s *CountMinSketch) counter(st FilterStorage, row int, item []byte) (int64, error) {
+	var buf [4]byte
+	if err := st.ReadAt(row, s.offset(row, item), buf[:]); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint32(buf[:])), nil
+}
+
+// offset returns the offset of the counter of the item in the row, rows are hashed with their index like in RedisBloom.
+func (s *CountMinSketch) offset(row int, item []byte) int64 {
+	return 4 * int64(uint64(murmurHash2(item, uint32(row)))%uint64(s.width))
+}
+
+// Encode returns the header of the sketch, counters are not included.
+func (s *CountMinSketch) Encode() []byte {
+	res := make([]byte, 0, 8+8+8)
+	res = binary.BigEndian.AppendUint64(res, uint64(s.width))
+	res = binary.BigEndian.AppendUint64(res, uint64(s.depth))
+	return binary.BigEndian.AppendUint64(res, uint64(s.count))
+}
+
+// DecodeCountMinSketch decodes a header returned by Encode.
+func DecodeCountMinSketch(b []byte) (*CountMinSketch, error) {
+	if len(b) != 8+8+8 {
+		return nil, errCorruptedCMS
+	}
+	s := &CountMinSketch{
+		width: int64(binary.BigEndian.Uint64(b)),
+		depth: int64(binary.BigEndian.Uint64(b[8:])),
+		count: int64(binary.BigEndian.Uint64(b[16:])),
+	}
+	if s.width <= 0 || s.depth <= 0 {
+		return nil, errCorruptedCMS
+	}
+	return s, nil
+}
+
+// murmurHash2 is the 32-bit hash function used by RedisBloom for sketches.
+func murmurHash2(key []byte, seed uint32) uint32 {
+	const m = 0x5bd1e995
+	const r = 24
+
+	h := seed ^ uint32(len(key))
+	for ; len(key) >= 4; key = key[4:] {
+		k := binary.LittleEndian.Uint32(key)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	switch len(key) {
+	case 3:
+		h ^= uint32(key[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(key[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(key[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
@@ -0,0 +1,239 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"slices"
+)
+
+// Cuckoo filters https://redis.io/docs/data-types/probabilistic/cuckoo-filter/
+
+var (
+	ErrCuckooFull     = NewError(PrefixErr, "Filter is full")
+	ErrCuckooNotFound = NewError(PrefixErr, "Not found")
+
+	errCorruptedCuckoo = errors.New("corrupted Cuckoo filter")
+)
+
+// CuckooDefaultCapacity is the capacity of filters created by CF.ADD and CF.ADDNX, like in RedisBloom.
+const CuckooDefaultCapacity = 1024
+
+const (
+	// cuckooBucketSize is the number of fingerprints of a bucket.
+	cuckooBucketSize = 2
+	// cuckooMaxIterations is the number of relocations before the filter is considered full.
+	cuckooMaxIterations = 20
+	// cuckooMaxFilters limits the chain of filters, each of them is as large as the first one.
+	cuckooMaxFilters = 32
+)
+
+// CuckooFilter is a scalable Cuckoo filter: a chain of filters of 1-byte fingerprints
+// in buckets of cuckooBucketSize, a new filter is added when relocations in the last one fail.
+// Buckets are kept in FilterStorage, the filter itself is a small header.
+type CuckooFilter struct {
+	items, deletes int64
+	// buckets is the number of buckets of each filter, it's a power of 2.
+	buckets []uint64
+}
+
+// NewCuckooFilter returns an empty filter for the capacity.
+func NewCuckooFilter(capacity int64) *CuckooFilter {
+	return &CuckooFilter{buckets: []uint64{cuckooBuckets(capacity)}}
+}
+
+func cuckooBuckets(capacity int64) uint64 {
+	n := uint64(max(capacity/cuckooBucketSize, 1))
+	if n&(n-1) != 0 {
+		n = 1 << bits.Len64(n)
+	}
+	return n
+}
+
+// Add adds the item, items may be added more than once.
+func (f *CuckooFilter) Add(st FilterStorage, item []byte) error {
+	fp, h1, h2 := cuckooHash(item)
+
+	// a free slot in any filter, newest first.
+	for layer := len(f.buckets) - 1; layer >= 0; layer-- {
+		n := f.buckets[layer]
+		for _, i := range [2]uint64{h1 % n, h2 % n} {
+			var bucket [cuckooBucketSize]byte
+			if err := st.ReadAt(layer, int64(i*cuckooBucketSize), bucket[:]); err != nil {
+				return err
+			}
+			if slot := bytes.IndexByte(bucket[:], 0); slot >= 0 {
+				f.items++
+				return st.WriteAt(layer, int64(i*cuckooBucketSize)+int64(slot), []byte{fp})
+			}
+		}
+	}
+
+	ok, err := f.relocate(st, fp, h1)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if len(f.buckets) >= cuckooMaxFilters {
+			return ErrCuckooFull
+		}
+		f.buckets = append(f.buckets, f.buckets[len(f.buckets)-1])
+		i := h1 % f.buckets[len(f.buckets)-1]
+		if err := st.WriteAt(len(f.buckets)-1, int64(i*cuckooBucketSize), []byte{fp}); err != nil {
+			return err
+		}
+	}
+	f.items++
+	return nil
+}
+
+// relocate inserts the fingerprint into the last filter kicking out fingerprints to their alternate
+// buckets. Buckets are changed in memory and written only if a free slot is found.
+func (f *CuckooFilter) relocate(st FilterStorage, fp byte, h uint64) (bool, error) {
+	layer := len(f.buckets) - 1
+	n := f.buckets[layer]
+	changed := make(map[uint64][]byte)
+	bucket := func(i uint64) ([]byte, error) {
+		if b, ok := changed[i]; ok {
+			return b, nil
+		}
+		b := make([]byte, cuckooBucketSize)
+		if err := st.ReadAt(layer, int64(i*cuckooBucketSize), b); err != nil {
+			return nil, err
+		}
+		changed[i] = b
+		return b, nil
+	}
+
+	i := h % n
+	for iter := 0; iter < cuckooMaxIterations; iter++ {
+		b, err := bucket(i)
+		if err != nil {
+			return false, err
+		}
+		victim := iter % cuckooBucketSize
+		fp, b[victim] = b[victim], fp
+
+		i = cuckooAltIndex(fp, i) % n
+		if b, err = bucket(i); err != nil {
+			return false, err
+		}
+		if slot := bytes.IndexByte(b, 0); slot >= 0 {
+			b[slot] = fp
+			for i, b := range changed {
+				if err := st.WriteAt(layer, int64(i*cuckooBucketSize), b); err != nil {
+					return false, err
+				}
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Count returns how many times the item may have been added.
+func (f *CuckooFilter) Count(st FilterStorage, item []byte) (int64, error) {
+	fp, h1, h2 := cuckooHash(item)
+	var count int64
+	for layer, n := range f.buckets {
+		i1, i2 := h1%n, h2%n
+		for _, i := range [2]uint64{i1, i2} {
+			var bucket [cuckooBucketSize]byte
+			if err := st.ReadAt(layer, int64(i*cuckooBucketSize), bucket[:]); err != nil {
+				return 0, err
+			}
+			count += int64(bytes.Count(bucket[:], []byte{fp}))
+			if i1 == i2 {
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+// Exists reports whether the item may have been added.
+func (f *CuckooFilter) Exists(st FilterStorage, item []byte) (bool, error) {
+	fp, h1, h2 := cuckooHash(item)
+	_, _, ok, err := f.find(st, fp, h1, h2)
+	return ok, err
+}
+
+// Del removes a single copy of the item and reports whether it was found.
+func (f *CuckooFilter) Del(st FilterStorage, item []byte) (bool, error) {
+	fp, h1, h2 := cuckooHash(item)
+	layer, offset, ok, err := f.find(st, fp, h1, h2)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := st.WriteAt(layer, offset, []byte{0}); err != nil {
+		return false, err
+	}
+	f.items--
+	f.deletes++
+	return true, nil
+}
+
+// find returns the filter and offset of the fingerprint, filters are searched newest first.
+func (f *CuckooFilter) find(st FilterStorage, fp byte, h1, h2 uint64) (int, int64, bool, error) {
+	for layer := len(f.buckets) - 1; layer >= 0; layer-- {
+		n := f.buckets[layer]
+		for _, i := range [2]uint64{h1 % n, h2 % n} {
+			var bucket [cuckooBucketSize]byte
+			if err := st.ReadAt(layer, int64(i*cuckooBucketSize), bucket[:]); err != nil {
+				return 0, 0, false, err
+			}
+			if slot := bytes.IndexByte(bucket[:], fp); slot >= 0 {
+				return layer, int64(i*cuckooBucketSize) + int64(slot), true, nil
+			}
+		}
+	}
+	return 0, 0, false, nil
+}
+
+// Clone returns a copy of the header.
+func (f *CuckooFilter) Clone() *CuckooFilter {
+	return &CuckooFilter{items: f.items, deletes: f.deletes, buckets: slices.Clone(f.buckets)}
+}
+
+// cuckooHash returns a non-zero fingerprint and both bucket hashes of the item, like in RedisBloom.
+func cuckooHash(item []byte) (byte, uint64, uint64) {
+	h := murmurHash64A(item, 0)
+	fp := byte(h%255 + 1)
+	return fp, h, cuckooAltIndex(fp, h)
+}
+
+// cuckooAltIndex returns the other bucket of the fingerprint, it works both ways for power of 2 sizes.
+func cuckooAltIndex(fp byte, i uint64) uint64 {
+	return i ^ uint64(fp)*0x5bd1e995
+}
+
+// Encode returns the header of the filter, buckets are not included.
+func (f *CuckooFilter) Encode() []byte {
+	res := make([]byte, 0, 8+8+len(f.buckets)*8)
+	res = binary.BigEndian.AppendUint64(res, uint64(f.items))
+	res = binary.BigEndian.AppendUint64(res, uint64(f.deletes))
+	for _, n := range f.buckets {
+		res = binary.BigEndian.AppendUint64(res, n)
+	}
+	return res
+}
+
+// DecodeCuckooFilter decodes a header returned by Encode.
+func DecodeCuckooFilter(b []byte) (*CuckooFilter, error) {
+	if len(b) < 8+8+8 || len(b)%8 != 0 {
+		return nil, errCorruptedCuckoo
+	}
+	f := &CuckooFilter{
+		items:   int64(binary.BigEndian.Uint64(b)),
+		deletes: int64(binary.BigEndian.Uint64(b[8:])),
+	}
+	for b = b[16:]; len(b) > 0; b = b[8:] {
+		n := binary.BigEndian.Uint64(b)
+		if n == 0 || n&(n-1) != 0 {
+			return nil, errCorruptedCuckoo
+		}
+		f.buckets = append(f.buckets, n)
+	}
+	return f, nil
+}
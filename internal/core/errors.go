@@ -0,0 +1,41 @@
+package core
+
+// Prefixes of RESP error replies.
+const (
+	PrefixErr        = "ERR"
+	PrefixWrongType  = "WRONGTYPE"
+	PrefixNoScript   = "NOSCRIPT"
+	PrefixExecAbort  = "EXECABORT"
+	PrefixNoGroup    = "NOGROUP"
+	PrefixBusyGroup  = "BUSYGROUP"
+	PrefixInvalidObj = "INVALIDOBJ"
+	PrefixCMS        = "CMS:"
+	PrefixTopK       = "TopK:"
+)
+
+var (
+	ErrKeyNotFound        = NewError(PrefixErr, "no such key")
+	ErrNotIntOrOutOfRange = NewError(PrefixErr, "value is not an integer or out of range")
+	ErrOutOfRange         = NewError(PrefixErr, "value is out of range")
+	ErrNotFloat           = NewError(PrefixErr, "value is not a valid float")
+	ErrStringTooLong      = NewError(PrefixErr, "string exceeds maximum allowed size (proto-max-bulk-len)")
+	ErrSameObject         = NewError(PrefixErr, "source and destination objects are the same")
+	ErrSyntax             = NewError(PrefixErr, "syntax error")
+	ErrWrongType          = NewError(PrefixWrongType, "Operation against a key holding the wrong kind of value")
+)
+
+// Error is an error that is sent to the client with the given prefix.
+// Other errors are sent with ERR prefix.
+type Error struct {
+	Prefix string
+	Msg    string
+}
+
+// NewError returns an error with the given RESP prefix.
+func NewError(prefix, msg string) *Error {
+	return &Error{Prefix: prefix, Msg: msg}
+}
+
+func (e *Error) Error() string {
+	return e.Prefix + " " + e.Msg
+}
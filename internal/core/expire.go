@@ -0,0 +1,94 @@
+package core
+
+import (
+	"math"
+	"time"
+)
+
+var ErrInvalidExpireTime = NewError(PrefixErr, "invalid expire time")
+
+// ExpireCond is a set of conditions for EXPIRE family commands.
+// Zero value sets the expiry unconditionally.
+type ExpireCond int
+
+const (
+	// ExpireNX sets the expiry only when the key has no expiry.
+	ExpireNX ExpireCond = 1 << iota
+	// ExpireXX sets the expiry only when the key has an existing expiry.
+	ExpireXX
+	// ExpireGT sets the expiry only when the new expiry is greater than current one.
+	ExpireGT
+	// ExpireLT sets the expiry only when the new expiry is less than current one.
+	ExpireLT
+)
+
+// Allow reports whether expiry can be changed from cur to next.
+// Zero cur means that key has no expiry (is persistent).
+func (c ExpireCond) Allow(cur, next int64) bool {
+	switch {
+	case c&ExpireNX != 0 && cur != 0:
+		return false
+	case c&ExpireXX != 0 && cur == 0:
+		return false
+	case c&ExpireGT != 0 && (cur == 0 || next <= cur):
+		// persistent key has infinite TTL, nothing is greater.
+		return false
+	case c&ExpireLT != 0 && cur != 0 && next >= cur:
+		return false
+	}
+	return true
+}
+
+// NowMs returns current unix time in milliseconds.
+func NowMs() int64 {
+	return time.Now().UnixMilli()
+}
+
+// ExpireAtMs converts relative expiry in the given unit to absolute unix time in milliseconds.
+func ExpireAtMs(now, d int64, unit time.Duration) (int64, error) {
+	mul := int64(unit / time.Millisecond)
+	if d > math.MaxInt64/mul || d < math.MinInt64/mul {
+		return 0, ErrInvalidExpireTime
+	}
+	d *= mul
+	if d > 0 && now > math.MaxInt64-d {
+		return 0, ErrInvalidExpireTime
+	}
+	return now + d, nil
+}
+
+// UnixMs converts absolute unix time in the given unit to milliseconds.
+func UnixMs(at int64, unit time.Duration) (int64, error) {
+	return ExpireAtMs(0, at, unit)
+}
+
+// TTLReply returns TTL reply in the given unit for a key expiring at the given time.
+// Values -2 and -1 are returned for missing and persistent keys respectively.
+func TTLReply(ok bool, now, at int64, unit time.Duration) int64 {
+	switch {
+	case !ok:
+		return -2
+	case at == 0:
+		return -1
+	}
+	ttl := max(at-now, 0)
+	if unit == time.Second {
+		return (ttl + 500) / 1000
+	}
+	return ttl
+}
+
+// ExpireTimeReply returns EXPIRETIME reply in the given unit for a key expiring at the given time.
+// Values -2 and -1 are returned for missing and persistent keys respectively.
+func ExpireTimeReply(ok bool, at int64, unit time.Duration) int64 {
+	switch {
+	case !ok:
+		return -2
+	case at == 0:
+		return -1
+	}
+	if unit == time.Second {
+		return (at + 500) / 1000
+	}
+	return at
+}
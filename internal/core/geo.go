@@ -0,0 +1,412 @@
+package core
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// Geo indexes are sorted sets scored by 52-bit geohashes, so they are read and written
+// with sorted set commands too. The encoding and the search follow geohash.c,
+// geohash_helper.c and geo.c of Redis, so scores are the same on both sides:
+// latitude bits are even bits of the score and longitude bits are odd ones.
+
+var ErrGeoMemberNotFound = NewError(PrefixErr, "could not decode requested zset member")
+
+// Bounds of valid positions, latitudes are limited like in EPSG:900913.
+const (
+	GeoLonMin = -180.0
+	GeoLonMax = 180.0
+	GeoLatMin = -85.05112878
+	GeoLatMax = 85.05112878
+)
+
+const (
+	geoStepMax = 26
+	// geoEarthRadius is the earth radius in meters used by Redis.
+	geoEarthRadius = 6372797.560856
+	// geoMercatorMax is the max coordinate of the mercator projection in meters.
+	geoMercatorMax = 20037726.37
+
+	geoAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+// GeoUnit is a unit of distance, its value is the number of meters in it.
+type GeoUnit float64
+
+const (
+	GeoMeters     GeoUnit = 1
+	GeoKilometers GeoUnit = 1000
+	GeoFeet       GeoUnit = 0.3048
+	GeoMiles      GeoUnit = 1609.34
+)
+
+// ValidLonLat reports whether the position can be indexed.
+func ValidLonLat(lon, lat float64) bool {
+	return lon >= GeoLonMin && lon <= GeoLonMax && lat >= GeoLatMin && lat <= GeoLatMax
+}
+
+// GeoScore returns the sorted set score of the position, it must be valid.
+func GeoScore(lon, lat float64) float64 {
+	return float64(geoEncode(lon, lat, GeoLatMax, geoStepMax))
+}
+
+// GeoPosition returns the center of the geohash cell of the score.
+func GeoPosition(score float64) (lon, lat float64) {
+	a := geoDecode(uint64(score), geoStepMax)
+	lon = min(max((a.lonMin+a.lonMax)/2, GeoLonMin), GeoLonMax)
+	lat = min(max((a.latMin+a.latMax)/2, GeoLatMin), GeoLatMax)
+	return lon, lat
+}
+
+// GeoHash returns the standard 11 characters geohash of the score,
+// which unlike the score uses the whole range of latitudes.
+func GeoHash(score float64) string {
+	lon, lat := GeoPosition(score)
+	hash := geoEncode(lon, lat, 90, geoStepMax)
+
+	var buf [11]byte
+	for i := range buf {
+		// 52 bits are only enough for 10 characters, the last one is always 0 like in Redis.
+		idx := 0
+		if i < 10 {
+			idx = int(hash>>(52-(i+1)*5)) & 0x1f
+		}
+		buf[i] = geoAlphabet[idx]
+	}
+	return string(buf[:])
+}
+
+// GeoDist returns the distance between two positions in meters.
+func GeoDist(lon1, lat1, lon2, lat2 float64) float64 {
+	lon1r, lon2r := degRad(lon1), degRad(lon2)
+	v := math.Sin((lon2r - lon1r) / 2)
+	// the same longitude doesn't need expensive math.
+	if v == 0 {
+		return geoLatDist(lat1, lat2)
+	}
+	lat1r, lat2r := degRad(lat1), degRad(lat2)
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2 * geoEarthRadius * math.Asin(math.Sqrt(a))
+}
+
+func geoLatDist(lat1, lat2 float64) float64 {
+	return geoEarthRadius * math.Abs(degRad(lat2)-degRad(lat1))
+}
+
+func degRad(deg float64) float64 {
+	return deg * (math.Pi / 180)
+}
+
+func radDeg(rad float64) float64 {
+	return rad / (math.Pi / 180)
+}
+
+// GeoSort is an order of search results.
+type GeoSort int
+
+const (
+	GeoSortNone GeoSort = iota
+	GeoSortAsc
+	GeoSortDesc
+)
+
+// GeoSearch is a search of GEOSEARCH command.
+type GeoSearch struct {
+	// FromMember is the member at the center of the search if not nil, Lon and Lat are used otherwise.
+	FromMember []byte
+	Lon, Lat   float64
+	// ByBox searches in a Width x Height box, in a Radius circle otherwise.
+	// Sizes and distances of results are in Unit.
+	ByBox         bool
+	Radius        float64
+	Width, Height float64
+	Unit          GeoUnit
+	Sort          GeoSort
+	// Count limits the number of results if not zero, the nearest ones are returned
+	// unless Any is set, then the search stops as soon as Count members are found.
+	Count int
+	Any   bool
+}
+
+// GeoPoint is a member found by a search.
+type GeoPoint struct {
+	Member []byte
+	Score  float64
+	// Dist is the distance from the center of the search in its unit.
+	Dist     float64
+	Lon, Lat float64
+}
+
+// Search calls scan for score ranges of geohash cells that cover the area of the search
+// and returns matching members sorted and limited by the search. Center of the search
+// must be resolved to Lon and Lat before. scan calls fn for members with min <= score < max
+// in score order until it returns false, member passed to fn is valid only until it returns.
+func (q GeoSearch) Search(scan func(min, max float64, fn func(ZMember) bool) error) ([]GeoPoint, error) {
+	res := []GeoPoint{}
+	full := func() bool {
+		return q.Any && q.Count > 0 && len(res) >= q.Count
+	}
+
+	for _, r := range q.ranges() {
+		if full() {
+			break
+		}
+		err := scan(r[0], r[1], func(m ZMember) bool {
+			if p, ok := q.match(m); ok {
+				p.Member = slices.Clone(m.Member)
+				res = append(res, p)
+			}
+			return !full()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort := q.Sort
+	if q.Count > 0 && !q.Any && sort == GeoSortNone {
+		sort = GeoSortAsc
+	}
+	switch sort {
+	case GeoSortAsc:
+		slices.SortStableFunc(res, func(a, b GeoPoint) int {
+			return cmp.Compare(a.Dist, b.Dist)
+		})
+	case GeoSortDesc:
+		slices.SortStableFunc(res, func(a, b GeoPoint) int {
+			return cmp.Compare(b.Dist, a.Dist)
+		})
+	}
+	if q.Count > 0 && len(res) > q.Count {
+		res = res[:q.Count]
+	}
+	return res, nil
+}
+
+// match returns the member as a point if it's in the area of the search.
+func (q GeoSearch) match(m ZMember) (GeoPoint, bool) {
+	lon, lat := GeoPosition(m.Score)
+	unit := float64(q.Unit)
+
+	var dist float64
+	if q.ByBox {
+		// latitude distance is cheaper, so it's checked first.
+		if geoLatDist(lat, q.Lat) > q.Height*unit/2 {
+			return GeoPoint{}, false
+		}
+		if GeoDist(lon, lat, q.Lon, lat) > q.Width*unit/2 {
+			return GeoPoint{}, false
+		}
+		dist = GeoDist(q.Lon, q.Lat, lon, lat)
+	} else {
+		dist = GeoDist(q.Lon, q.Lat, lon, lat)
+		if dist > q.Radius*unit {
+			return GeoPoint{}, false
+		}
+	}
+	return GeoPoint{Score: m.Score, Dist: dist / unit, Lon: lon, Lat: lat}, true
+}
+
+// geoCell is a geohash with the number of bits per coordinate, zero one is no cell.
+type geoCell struct {
+	bits uint64
+	step uint
+}
+
+// ranges returns score ranges of the cell of the center and its neighbours that cover the area,
+// like geohashCalculateAreasByShapeWGS84 and membersOfAllNeighbors of Redis.
+func (q GeoSearch) ranges() [][2]float64 {
+	unit := float64(q.Unit)
+	radius := q.Radius * unit
+	halfWidth, halfHeight := radius, radius
+	if q.ByBox {
+		halfWidth, halfHeight = q.Width/2*unit, q.Height/2*unit
+		radius = math.Sqrt(q.Width/2*q.Width/2+q.Height/2*q.Height/2) * unit
+	}
+
+	// bounding box of the area.
+	latDelta := radDeg(halfHeight / geoEarthRadius)
+	lonDeltaTop := radDeg(halfWidth / geoEarthRadius / math.Cos(degRad(q.Lat+latDelta)))
+	lonDeltaBottom := radDeg(halfWidth / geoEarthRadius / math.Cos(degRad(q.Lat-latDelta)))
+	lonDelta := lonDeltaTop
+	if q.Lat < 0 {
+		lonDelta = lonDeltaBottom
+	}
+	minLon, maxLon := q.Lon-lonDelta, q.Lon+lonDelta
+	minLat, maxLat := q.Lat-latDelta, q.Lat+latDelta
+
+	step := geoSteps(radius, q.Lat)
+	center := geoCell{bits: geoEncode(q.Lon, q.Lat, GeoLatMax, step), step: step}
+	neighbours := center.neighbours()
+	area := geoDecode(center.bits, step)
+
+	// the cell might be too small for the area, then a bigger one is used.
+	north := geoDecode(neighbours[0].bits, step)
+	south := geoDecode(neighbours[1].bits, step)
+	east := geoDecode(neighbours[2].bits, step)
+	west := geoDecode(neighbours[3].bits, step)
+	if step > 1 && (north.latMax < maxLat || south.latMin > minLat || east.lonMax < maxLon || west.lonMin > minLon) {
+		step--
+		center = geoCell{bits: geoEncode(q.Lon, q.Lat, GeoLatMax, step), step: step}
+		neighbours = center.neighbours()
+		area = geoDecode(center.bits, step)
+	}
+
+	// neighbours outside of the area are skipped.
+	const (
+		n, s, e, w, ne, nw, se, sw = 0, 1, 2, 3, 4, 5, 6, 7
+	)
+	if step >= 2 {
+		if area.latMin < minLat {
+			neighbours[s], neighbours[sw], neighbours[se] = geoCell{}, geoCell{}, geoCell{}
+		}
+		if area.latMax > maxLat {
+			neighbours[n], neighbours[ne], neighbours[nw] = geoCell{}, geoCell{}, geoCell{}
+		}
+		if area.lonMin < minLon {
+			neighbours[w], neighbours[sw], neighbours[nw] = geoCell{}, geoCell{}, geoCell{}
+		}
+		if area.lonMax > maxLon {
+			neighbours[e], neighbours[se], neighbours[ne] = geoCell{}, geoCell{}, geoCell{}
+		}
+	}
+
+	cells := append([]geoCell{center}, neighbours[:]...)
+	res := make([][2]float64, 0, len(cells))
+	last := 0
+	for i, c := range cells {
+		if c == (geoCell{}) {
+			continue
+		}
+		// cells of huge areas can be the same, each one is scanned once.
+		if last != 0 && c == cells[last] {
+			continue
+		}
+		shift := 52 - 2*c.step
+		res = append(res, [2]float64{float64(c.bits << shift), float64((c.bits + 1) << shift)})
+		last = i
+	}
+	return res
+}
+
+// neighbours returns cells around in order N, S, E, W, NE, NW, SE, SW.
+func (c geoCell) neighbours() [8]geoCell {
+	moves := [8][2]int{{0, 1}, {0, -1}, {1, 0}, {-1, 0}, {1, 1}, {-1, 1}, {1, -1}, {-1, -1}}
+	var res [8]geoCell
+	for i, m := range moves {
+		res[i] = c.moveX(m[0]).moveY(m[1])
+	}
+	return res
+}
+
+// moveX moves the cell along the longitude, odd bits of the hash.
+func (c geoCell) moveX(d int) geoCell {
+	if d == 0 {
+		return c
+	}
+	x := c.bits & 0xaaaaaaaaaaaaaaaa
+	y := c.bits & 0x5555555555555555
+	zz := uint64(0x5555555555555555) >> (64 - c.step*2)
+	if d > 0 {
+		x += zz + 1
+	} else {
+		x |= zz
+		x -= zz + 1
+	}
+	x &= 0xaaaaaaaaaaaaaaaa >> (64 - c.step*2)
+	return geoCell{bits: x | y, step: c.step}
+}
+
+// moveY moves the cell along the latitude, even bits of the hash.
+func (c geoCell) moveY(d int) geoCell {
+	if d == 0 {
+		return c
+	}
+	x := c.bits & 0xaaaaaaaaaaaaaaaa
+	y := c.bits & 0x5555555555555555
+	zz := uint64(0xaaaaaaaaaaaaaaaa) >> (64 - c.step*2)
+	if d > 0 {
+		y += zz + 1
+	} else {
+		y |= zz
+		y -= zz + 1
+	}
+	y &= 0x5555555555555555 >> (64 - c.step*2)
+	return geoCell{bits: x | y, step: c.step}
+}
+
+// geoSteps returns the number of bits per coordinate of cells that fit the radius in meters.
+func geoSteps(radius, lat float64) uint {
+	if radius == 0 {
+		return geoStepMax
+	}
+	step := 1
+	for radius < geoMercatorMax {
+		radius *= 2
+		step++
+	}
+	// make sure the radius is covered in most cases.
+	step -= 2
+
+	// cells are narrower towards the poles.
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+	return uint(min(max(step, 1), geoStepMax))
+}
+
+// geoArea is the area of a geohash cell.
+type geoArea struct {
+	lonMin, lonMax float64
+	latMin, latMax float64
+}
+
+// geoEncode returns geohash of the position with step bits per coordinate,
+// latitudes are in [-latMax, latMax] range.
+func geoEncode(lon, lat, latMax float64, step uint) uint64 {
+	latOffset := (lat + latMax) / (2 * latMax)
+	lonOffset := (lon - GeoLonMin) / (GeoLonMax - GeoLonMin)
+	latOffset *= float64(uint64(1) << step)
+	lonOffset *= float64(uint64(1) << step)
+	return spreadBits(uint32(latOffset)) | spreadBits(uint32(lonOffset))<<1
+}
+
+func geoDecode(hash uint64, step uint) geoArea {
+	lat, lon := squashBits(hash), squashBits(hash>>1)
+	cells := float64(uint64(1) << step)
+	latScale := GeoLatMax - GeoLatMin
+	lonScale := GeoLonMax - GeoLonMin
+	return geoArea{
+		lonMin: GeoLonMin + float64(lon)/cells*lonScale,
+		lonMax: GeoLonMin + float64(uint64(lon)+1)/cells*lonScale,
+		latMin: GeoLatMin + float64(lat)/cells*latScale,
+		latMax: GeoLatMin + float64(uint64(lat)+1)/cells*latScale,
+	}
+}
+
+// spreadBits moves bits of v to even bits of the result.
+func spreadBits(v uint32) uint64 {
+	x := uint64(v)
+	x = (x | x<<16) & 0x0000ffff0000ffff
+	x = (x | x<<8) & 0x00ff00ff00ff00ff
+	x = (x | x<<4) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+	return x
+}
+
+// squashBits is the reverse of spreadBits.
+func squashBits(x uint64) uint32 {
+	x &= 0x5555555555555555
+	x = (x | x>>1) & 0x3333333333333333
+	x = (x | x>>2) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x>>4) & 0x00ff00ff00ff00ff
+	x = (x | x>>8) & 0x0000ffff0000ffff
+	x = (x | x>>16) & 0x00000000ffffffff
+	return uint32(x)
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"math"
+	"math/rand"
+)
+
+var (
+	ErrHashNotInt   = NewError(PrefixErr, "hash value is not an integer")
+	ErrHashNotFloat = NewError(PrefixErr, "hash value is not a float")
+	ErrOverflow     = NewError(PrefixErr, "increment or decrement would overflow")
+	ErrNaNOrInf     = NewError(PrefixErr, "value is NaN or Infinity")
+	ErrIncrNaNOrInf = NewError(PrefixErr, "increment would produce NaN or Infinity")
+)
+
+// Per-field replies of HEXPIRE family and HPERSIST commands.
+const (
+	// FieldMissing is returned when the field or the key doesn't exist.
+	FieldMissing = -2
+	// FieldNoExpire is returned by HPERSIST when the field has no expiry.
+	FieldNoExpire = -1
+	// FieldCondNotMet is returned when the expiry is not set because of the condition.
+	FieldCondNotMet = 0
+	// FieldUpdated is returned when the expiry is set or removed.
+	FieldUpdated = 1
+	// FieldDeleted is returned when the field is deleted because the expiry is in the past.
+	FieldDeleted = 2
+)
+
+// AddInt64 returns a+b, fails if the result overflows int64.
+func AddInt64(a, b int64) (int64, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, ErrOverflow
+	}
+	return a + b, nil
+}
+
+// RandIndexes picks random indexes of a collection of the given length like HRANDFIELD does.
+// Positive count returns up to count distinct indexes,
+// negative count returns exactly -count indexes that might repeat.
+func RandIndexes(n, count int) []int {
+	if n == 0 {
+		return []int{}
+	}
+	if count < 0 {
+		res := make([]int, -count)
+		for i := range res {
+			res[i] = rand.Intn(n)
+		}
+		return res
+	}
+	res := rand.Perm(n)
+	return res[:min(count, n)]
+}
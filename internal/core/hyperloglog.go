@@ -0,0 +1,497 @@
+package core
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// HyperLogLog values are strings in the Redis encoding, so they can be read with GET
+// and restored with SET on both sides. The layout and algorithms follow hyperloglog.c:
+//
+//	"HYLL" + encoding (1 byte) + unused (3 bytes) + cached cardinality (8 bytes, little endian)
+//
+// followed by 16384 registers of 6 bits in the dense encoding or by opcodes
+// in the sparse one:
+//
+//	00xxxxxx           => ZERO, xxxxxx+1 registers set to 0
+//	01xxxxxx yyyyyyyy  => XZERO, xxxxxxyyyyyyyy+1 registers set to 0
+//	1vvvvvxx           => VAL, xx+1 registers set to vvvvv+1
+//
+// The most significant bit of the cached cardinality is set when the cache is stale.
+
+var (
+	ErrHLLWrongType = NewError(PrefixWrongType, "Key is not a valid HyperLogLog string value.")
+	ErrHLLCorrupted = NewError(PrefixInvalidObj, "Corrupted HLL object detected")
+)
+
+const (
+	hllP         = 14
+	hllQ         = 64 - hllP
+	hllRegisters = 1 << hllP
+	hllBits      = 6
+	hllRegMax    = 1<<hllBits - 1
+	hllHdrSize   = 16
+	hllDenseSize = hllHdrSize + (hllRegisters*hllBits+7)/8
+
+	hllDense  = 0
+	hllSparse = 1
+
+	// hllSparseMaxBytes is hll-sparse-max-bytes of Redis, sparse values
+	// growing over it are converted to dense.
+	hllSparseMaxBytes = 3000
+
+	sparseValMaxValue = 32
+	sparseValMaxLen   = 4
+	sparseZeroMaxLen  = 64
+
+	// hllAlphaInf is 0.5/ln(2).
+	hllAlphaInf = 0.721347520444481703680
+)
+
+// NewHLL returns an empty HyperLogLog in the sparse encoding.
+func NewHLL() []byte {
+	val := make([]byte, hllHdrSize, hllHdrSize+2)
+	copy(val, "HYLL")
+	val[4] = hllSparse
+	return append(val, sparseXZero(hllRegisters)...)
+}
+
+// CheckHLL reports whether the string looks like a HyperLogLog, like isHLLObjectOrReply of Redis.
+// Sparse opcodes are validated only when they are read.
+func CheckHLL(val []byte) error {
+	switch {
+	case len(val) < hllHdrSize, string(val[:4]) != "HYLL", val[4] > hllSparse:
+		return ErrHLLWrongType
+	case val[4] == hllDense && len(val) != hllDenseSize:
+		return ErrHLLWrongType
+	default:
+		return nil
+	}
+}
+
+// IsDenseHLL reports whether the HyperLogLog is in the dense encoding.
+func IsDenseHLL(val []byte) bool {
+	return val[4] == hllDense
+}
+
+// HLLAdd adds elements to the HyperLogLog and reports whether any register was updated.
+// A nil value is a missing key, a new HyperLogLog is created for it, which counts as an update.
+// The value is updated in place when possible.
+func HLLAdd(val []byte, elements [][]byte) ([]byte, bool, error) {
+	updated := false
+	if val == nil {
+		val, updated = NewHLL(), true
+	}
+	for _, elem := range elements {
+		index, count := hllPatLen(elem)
+
+		var ok bool
+		var err error
+		if IsDenseHLL(val) {
+			ok = denseSet(val[hllHdrSize:], index, count)
+		} else {
+			val, ok, err = sparseSet(val, index, count)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		updated = updated || ok
+	}
+	if updated {
+		invalidateHLLCache(val)
+	}
+	return val, updated, nil
+}
+
+// HLLCount returns the cardinality of the HyperLogLog. When the cached one is stale,
+// it's recomputed and written to the value in place, true is returned in that case.
+func HLLCount(val []byte) (int64, bool, error) {
+	if val[15]&0x80 == 0 {
+		return int64(binary.LittleEndian.Uint64(val[8:hllHdrSize])), false, nil
+	}
+
+	var histo [64]int
+	if IsDenseHLL(val) {
+		regs := val[hllHdrSize:]
+		for i := 0; i < hllRegisters; i++ {
+			histo[denseGet(regs, i)]++
+		}
+	} else {
+		idx := 0
+		err := walkSparse(val, func(value uint8, runlen int) bool {
+			histo[value] += runlen
+			idx += runlen
+			return true
+		})
+		if err != nil || idx != hllRegisters {
+			return 0, false, ErrHLLCorrupted
+		}
+	}
+
+	card := hllEstimate(&histo)
+	binary.LittleEndian.PutUint64(val[8:hllHdrSize], uint64(card))
+	return card, true, nil
+}
+
+// HLLRegisters are raw registers used to merge HyperLogLogs.
+type HLLRegisters [hllRegisters]uint8
+
+// Merge sets every register to the max of itself and the one of the HyperLogLog.
+func (r *HLLRegisters) Merge(val []byte) error {
+	if IsDenseHLL(val) {
+		regs := val[hllHdrSize:]
+		for i := range r {
+			r[i] = max(r[i], denseGet(regs, i))
+		}
+		return nil
+	}
+
+	idx := 0
+	err := walkSparse(val, func(value uint8, runlen int) bool {
+		if value == 0 {
+			idx += runlen
+			return true
+		}
+		if idx+runlen > hllRegisters {
+			return false
+		}
+		for ; runlen > 0; runlen-- {
+			r[idx] = max(r[idx], value)
+			idx++
+		}
+		return true
+	})
+	if err != nil || idx != hllRegisters {
+		return ErrHLLCorrupted
+	}
+	return nil
+}
+
+// Count returns the cardinality estimated from the registers.
+func (r *HLLRegisters) Count() int64 {
+	var histo [64]int
+	for _, v := range r {
+		histo[v]++
+	}
+	return hllEstimate(&histo)
+}
+
+// HLLStore writes the registers into the HyperLogLog as PFMERGE does, converting it
+// to the dense encoding first if dense is set. The cached cardinality is invalidated.
+func HLLStore(val []byte, regs *HLLRegisters, dense bool) ([]byte, error) {
+	var err error
+	if dense {
+		if val, err = sparseToDense(val); err != nil {
+			return nil, err
+		}
+	}
+	for i, count := range regs {
+		if count == 0 {
+			continue
+		}
+		if IsDenseHLL(val) {
+			denseSet(val[hllHdrSize:], i, count)
+		} else if val, _, err = sparseSet(val, i, count); err != nil {
+			return nil, err
+		}
+	}
+	invalidateHLLCache(val)
+	return val, nil
+}
+
+func invalidateHLLCache(val []byte) {
+	val[15] |= 0x80
+}
+
+// hllPatLen returns the register index of the element and the length of
+// the "00..1" pattern of its hash.
+func hllPatLen(elem []byte) (int, uint8) {
+	hash := murmurHash64A(elem, 0xadc83b19)
+	index := int(hash & (hllRegisters - 1))
+	hash >>= hllP
+	// make sure the count is at most hllQ+1.
+	hash |= 1 << hllQ
+	return index, uint8(bits.TrailingZeros64(hash) + 1)
+}
+
+// murmurHash64A is the hash function used by Redis for HyperLogLog and by RedisBloom.
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+
+	h := seed ^ uint64(len(key))*m
+	for len(key) >= 8 {
+		k := binary.LittleEndian.Uint64(key)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+		key = key[8:]
+	}
+	if len(key) > 0 {
+		for i := len(key) - 1; i >= 0; i-- {
+			h ^= uint64(key[i]) << (8 * i)
+		}
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// hllEstimate estimates the cardinality from the histogram of registers, see
+// "New cardinality estimation algorithms for HyperLogLog sketches" by Otmar Ertl.
+func hllEstimate(histo *[64]int) int64 {
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histo[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histo[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histo[0])/m)
+	return int64(math.Round(hllAlphaInf * m * m / z))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if prev == z {
+			return z / 3
+		}
+	}
+}
+
+func denseGet(regs []byte, index int) uint8 {
+	bit := index * hllBits
+	b0, b1 := uint(regs[bit/8]), uint(0)
+	if bit/8+1 < len(regs) {
+		b1 = uint(regs[bit/8+1])
+	}
+	fb := uint(bit & 7)
+	return uint8((b0>>fb | b1<<(8-fb)) & hllRegMax)
+}
+
+// denseSet sets the register to count if it's greater and reports whether it was updated.
+func denseSet(regs []byte, index int, count uint8) bool {
+	if count <= denseGet(regs, index) {
+		return false
+	}
+	bit := index * hllBits
+	fb := uint(bit & 7)
+	v := uint(count)
+	regs[bit/8] &^= byte(hllRegMax << fb)
+	regs[bit/8] |= byte(v << fb)
+	if bit/8+1 < len(regs) {
+		regs[bit/8+1] &^= byte(hllRegMax >> (8 - fb))
+		regs[bit/8+1] |= byte(v >> (8 - fb))
+	}
+	return true
+}
+
+// walkSparse calls fn for every opcode of the sparse HyperLogLog until fn returns false.
+func walkSparse(val []byte, fn func(value uint8, runlen int) bool) error {
+	for p := hllHdrSize; p < len(val); {
+		value, runlen, oplen := sparseOp(val, p)
+		if oplen == 0 {
+			return ErrHLLCorrupted
+		}
+		if !fn(value, runlen) {
+			return ErrHLLCorrupted
+		}
+		p += oplen
+	}
+	return nil
+}
+
+// sparseOp decodes the opcode at p, zero oplen means it's truncated.
+func sparseOp(val []byte, p int) (value uint8, runlen, oplen int) {
+	switch op := val[p]; {
+	case op&0xc0 == 0:
+		return 0, int(op&0x3f) + 1, 1
+	case op&0x80 != 0:
+		return (op>>2)&0x1f + 1, int(op&0x3) + 1, 1
+	case p+1 < len(val):
+		return 0, (int(op&0x3f)<<8 | int(val[p+1])) + 1, 2
+	default:
+		return 0, 0, 0
+	}
+}
+
+func sparseVal(value uint8, runlen int) byte {
+	return (value-1)<<2 | byte(runlen-1) | 0x80
+}
+
+func sparseZero(runlen int) byte {
+	return byte(runlen - 1)
+}
+
+func sparseXZero(runlen int) []byte {
+	return []byte{byte((runlen-1)>>8) | 0x40, byte(runlen - 1)}
+}
+
+// sparseZeros encodes a run of zero registers with ZERO or XZERO opcode.
+func sparseZeros(runlen int) []byte {
+	if runlen > sparseZeroMaxLen {
+		return sparseXZero(runlen)
+	}
+	return []byte{sparseZero(runlen)}
+}
+
+// sparseSet sets the register to count if it's greater, like hllSparseSet of Redis,
+// so the resulting opcodes are the same. The value is converted to dense if
+// count doesn't fit the sparse encoding or the value grows too much.
+func sparseSet(val []byte, index int, count uint8) ([]byte, bool, error) {
+	if count > sparseValMaxValue {
+		return promoteSet(val, index, count)
+	}
+
+	// locate the opcode covering the register.
+	p, prev, first := hllHdrSize, -1, 0
+	var value uint8
+	var runlen, oplen int
+	for p < len(val) {
+		value, runlen, oplen = sparseOp(val, p)
+		if oplen == 0 {
+			return nil, false, ErrHLLCorrupted
+		}
+		if index <= first+runlen-1 {
+			break
+		}
+		prev = p
+		p += oplen
+		first += runlen
+	}
+	if runlen == 0 || p >= len(val) {
+		return nil, false, ErrHLLCorrupted
+	}
+	isVal := val[p]&0x80 != 0
+
+	switch {
+	case isVal && value >= count:
+		return val, false, nil
+	case runlen == 1 && (isVal || oplen == 1):
+		// a single register of VAL or ZERO is updated in place.
+		val[p] = sparseVal(count, 1)
+	default:
+		// the opcode is split into up to three: before the register, the register and after it.
+		last := first + runlen - 1
+		seq := make([]byte, 0, 5)
+		if index != first {
+			if isVal {
+				seq = append(seq, sparseVal(value, index-first))
+			} else {
+				seq = append(seq, sparseZeros(index-first)...)
+			}
+		}
+		seq = append(seq, sparseVal(count, 1))
+		if index != last {
+			if isVal {
+				seq = append(seq, sparseVal(value, last-index))
+			} else {
+				seq = append(seq, sparseZeros(last-index)...)
+			}
+		}
+
+		delta := len(seq) - oplen
+		if delta > 0 && len(val)+delta-hllHdrSize > hllSparseMaxBytes {
+			return promoteSet(val, index, count)
+		}
+		tail := append(seq, val[p+oplen:]...)
+		val = append(val[:p], tail...)
+	}
+
+	// adjacent VAL opcodes with the same value are merged, scanning up to 5 opcodes from prev.
+	p = prev
+	if p < 0 {
+		p = hllHdrSize
+	}
+	for scan := 5; p < len(val) && scan > 0; scan-- {
+		switch {
+		case val[p]&0xc0 == 0x40:
+			p += 2
+			continue
+		case val[p]&0xc0 == 0:
+			p++
+			continue
+		}
+		if p+1 < len(val) && val[p+1]&0x80 != 0 {
+			v1, len1, _ := sparseOp(val, p)
+			v2, len2, _ := sparseOp(val, p+1)
+			if v1 == v2 && len1+len2 <= sparseValMaxLen {
+				val[p+1] = sparseVal(v1, len1+len2)
+				val = append(val[:p], val[p+1:]...)
+				continue
+			}
+		}
+		p++
+	}
+	invalidateHLLCache(val)
+	return val, true, nil
+}
+
+// promoteSet converts the value to dense and sets the register, it's always an update.
+func promoteSet(val []byte, index int, count uint8) ([]byte, bool, error) {
+	val, err := sparseToDense(val)
+	if err != nil {
+		return nil, false, err
+	}
+	return val, denseSet(val[hllHdrSize:], index, count), nil
+}
+
+// sparseToDense converts the value to the dense encoding keeping its header.
+func sparseToDense(val []byte) ([]byte, error) {
+	if IsDenseHLL(val) {
+		return val, nil
+	}
+
+	dense := make([]byte, hllDenseSize)
+	copy(dense, val[:hllHdrSize])
+	dense[4] = hllDense
+	regs := dense[hllHdrSize:]
+
+	idx := 0
+	err := walkSparse(val, func(value uint8, runlen int) bool {
+		if value == 0 {
+			idx += runlen
+			return true
+		}
+		if idx+runlen > hllRegisters {
+			return false
+		}
+		for ; runlen > 0; runlen-- {
+			denseSet(regs, idx, value)
+			idx++
+		}
+		return true
+	})
+	if err != nil || idx != hllRegisters {
+		return nil, ErrHLLCorrupted
+	}
+	return dense, nil
+}
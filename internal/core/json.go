@@ -0,0 +1,1089 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSON documents are kept in a binary encoding, so paths are resolved by jumping over offsets
+// and updated values are spliced in without parsing the rest of the document:
+//
+//	null, false, true => tag
+//	integer           => tag + int64 (8 bytes)
+//	number            => tag + float64 (8 bytes)
+//	string            => tag + len (4 bytes) + bytes
+//	array, object     => tag + size (4 bytes) + n (4 bytes) + n offsets (4 bytes each) + n entries
+//
+// Size is the size of the whole node, offsets are from the start of the node.
+// Array entries are values, object entries are len(key) (4 bytes) + key + value,
+// members of objects keep insertion order like in RedisJSON. Integers are big endian.
+
+var (
+	ErrJSONNewAtRoot        = NewError(PrefixErr, "new objects must be created at the root")
+	ErrJSONNoKey            = NewError(PrefixErr, "could not perform this operation on a key that doesn't exist")
+	ErrJSONIndexOutOfBounds = NewError(PrefixErr, "index out of bounds")
+	ErrJSONNotNumber        = NewError(PrefixErr, "result is not a number")
+)
+
+const (
+	jsonNull byte = iota + 1
+	jsonFalse
+	jsonTrue
+	jsonInt
+	jsonFloat
+	jsonString
+	jsonArray
+	jsonObject
+)
+
+const (
+	jsonContainerHdr = 1 + 4 + 4
+	// jsonMaxDepth limits nesting of parsed documents like RedisJSON does.
+	jsonMaxDepth = 128
+)
+
+// JSONFormat is formatting of JSON replies, zero value is compact JSON.
+type JSONFormat struct {
+	// Indent is written per nesting level, Newline after every entry of arrays and objects
+	// and Space after keys of objects.
+	Indent, Newline, Space string
+}
+
+// JSONSetOptions are options of JSON.SET command.
+type JSONSetOptions struct {
+	// NX only sets values that don't exist.
+	NX bool
+	// XX only sets values that exist.
+	XX bool
+}
+
+// JSONSet sets the value at the path and returns the new document, nil if nothing is set.
+// doc is nil for a missing key, values are in the binary encoding.
+// Missing keys of existing objects are added if the last step of the path is a key.
+func JSONSet(doc []byte, path JSONPath, value []byte, opts JSONSetOptions) ([]byte, error) {
+	if doc == nil {
+		switch {
+		case !path.IsRoot():
+			return nil, ErrJSONNewAtRoot
+		case opts.XX:
+			return nil, nil
+		}
+		return value, nil
+	}
+
+	matches := path.matches(doc)
+	if len(matches) > 0 {
+		if opts.NX {
+			return nil, nil
+		}
+		return jsonUpdate(doc, matches, func(int, []byte) ([]byte, error) {
+			return value, nil
+		})
+	}
+	if opts.XX {
+		return nil, nil
+	}
+	return jsonCreate(doc, path, value)
+}
+
+// JSONGet returns values at paths as JSON.GET does, the root is returned without paths.
+// A legacy path returns its value and a JSONPath an array of values, several paths return
+// an object of results by path, all of them as JSONPaths unless all paths are legacy.
+func JSONGet(doc []byte, f JSONFormat, paths ...JSONPath) ([]byte, error) {
+	switch len(paths) {
+	case 0:
+		return appendJSON(nil, doc, f, 0), nil
+	case 1:
+		return jsonGet(doc, f, paths[0])
+	}
+
+	legacy := true
+	for _, p := range paths {
+		legacy = legacy && p.Legacy
+	}
+	var keys, vals [][]byte
+	for _, p := range paths {
+		var val []byte
+		if legacy {
+			m := p.matches(doc)
+			if len(m) == 0 {
+				return nil, p.notFound()
+			}
+			val = m[0].node
+		} else {
+			val = jsonMatchesArray(p.eval(doc))
+		}
+		// the same path is written once.
+		if i := slices.IndexFunc(keys, func(k []byte) bool { return string(k) == p.raw }); i >= 0 {
+			vals[i] = val
+			continue
+		}
+		keys, vals = append(keys, []byte(p.raw)), append(vals, val)
+	}
+	return appendJSON(nil, jsonObjectOf(keys, vals), f, 0), nil
+}
+
+// JSONMGet returns values at the path in documents like JSON.MGET does,
+// nil for missing documents and values.
+func JSONMGet(docs [][]byte, path JSONPath) [][]byte {
+	res := make([][]byte, len(docs))
+	for i, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		if val, err := jsonGet(doc, JSONFormat{}, path); err == nil {
+			res[i] = val
+		}
+	}
+	return res
+}
+
+func jsonGet(doc []byte, f JSONFormat, path JSONPath) ([]byte, error) {
+	matches := path.matches(doc)
+	if !path.Legacy {
+		return appendJSON(nil, jsonMatchesArray(matches), f, 0), nil
+	}
+	if len(matches) == 0 {
+		return nil, path.notFound()
+	}
+	return appendJSON(nil, matches[0].node, f, 0), nil
+}
+
+// JSONDel removes values at the path, which must not be the root,
+// and returns the new document, nil if nothing is removed, and the number of removed values.
+func JSONDel(doc []byte, path JSONPath) ([]byte, int, error) {
+	n := 0
+	res, err := jsonUpdate(doc, path.matches(doc), func(int, []byte) ([]byte, error) {
+		n++
+		return nil, nil
+	})
+	if err != nil || n == 0 {
+		return nil, 0, err
+	}
+	return res, n, nil
+}
+
+// JSONType returns types of values at the path.
+func JSONType(doc []byte, path JSONPath) []string {
+	matches := path.matches(doc)
+	res := make([]string, len(matches))
+	for i, m := range matches {
+		res[i] = jsonTypeName(m.node)
+	}
+	return res
+}
+
+// JSONNumIncrBy adds by to numbers at the path and returns the new document and the reply of
+// JSON.NUMINCRBY: the new value for a legacy path, an array of new values with nulls for values
+// that are not numbers otherwise.
+func JSONNumIncrBy(doc []byte, path JSONPath, by []byte) ([]byte, []byte, error) {
+	if doc == nil {
+		return nil, nil, ErrJSONNoKey
+	}
+	if !jsonIsNumber(by) {
+		return nil, nil, jsonWrongValue("number", by)
+	}
+	matches, err := path.legacyMatches(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vals := make([][]byte, len(matches))
+	res, err := jsonUpdate(doc, matches, func(i int, n []byte) ([]byte, error) {
+		if !jsonIsNumber(n) {
+			return n, path.wrongType("number", n)
+		}
+		val, err := jsonAdd(n, by)
+		vals[i] = val
+		return val, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if path.Legacy {
+		return res, appendJSON(nil, vals[0], JSONFormat{}, 0), nil
+	}
+	for i := range vals {
+		if vals[i] == nil {
+			vals[i] = []byte{jsonNull}
+		}
+	}
+	return res, appendJSON(nil, jsonContainer(jsonArray, vals), JSONFormat{}, 0), nil
+}
+
+// JSONStrAppend appends the string to strings at the path and returns the new document and
+// new lengths of strings, nil for values that are not strings.
+func JSONStrAppend(doc []byte, path JSONPath, value []byte) ([]byte, []*int64, error) {
+	if doc == nil {
+		return nil, nil, ErrJSONNoKey
+	}
+	if value[0] != jsonString {
+		return nil, nil, jsonWrongValue("string", value)
+	}
+	return jsonLenUpdate(doc, path, "string", func(n []byte) ([]byte, int, error) {
+		s := append(bytes.Clone(jsonStr(n)), jsonStr(value)...)
+		return jsonStringNode(s), len(s), nil
+	})
+}
+
+// JSONArrAppend appends values to arrays at the path and returns the new document and
+// new lengths of arrays, nil for values that are not arrays.
+func JSONArrAppend(doc []byte, path JSONPath, values ...[]byte) ([]byte, []*int64, error) {
+	if doc == nil {
+		return nil, nil, ErrJSONNoKey
+	}
+	return jsonLenUpdate(doc, path, "array", func(n []byte) ([]byte, int, error) {
+		entries := append(jsonEntries(n), values...)
+		return jsonContainer(jsonArray, entries), len(entries), nil
+	})
+}
+
+// JSONArrInsert inserts values before the index of arrays at the path, negative index counts
+// from the end, and returns the new document and new lengths of arrays, nil for values
+// that are not arrays.
+func JSONArrInsert(doc []byte, path JSONPath, index int64, values ...[]byte) ([]byte, []*int64, error) {
+	if doc == nil {
+		return nil, nil, ErrJSONNoKey
+	}
+	return jsonLenUpdate(doc, path, "array", func(n []byte) ([]byte, int, error) {
+		entries := jsonEntries(n)
+		i := index
+		if i < 0 {
+			i += int64(len(entries))
+		}
+		if i < 0 || i > int64(len(entries)) {
+			return nil, 0, ErrJSONIndexOutOfBounds
+		}
+		entries = slices.Insert(entries, int(i), values...)
+		return jsonContainer(jsonArray, entries), len(entries), nil
+	})
+}
+
+// JSONArrPop removes the element at the index of arrays at the path and returns the new document,
+// nil if nothing is removed, and removed elements as JSON, nil for values that are not arrays
+// or are empty ones. Negative index counts from the end, out of range one is clamped.
+func JSONArrPop(doc []byte, path JSONPath, index int64) ([]byte, [][]byte, error) {
+	if doc == nil {
+		return nil, nil, ErrJSONNoKey
+	}
+	matches, err := path.legacyMatches(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	popped := make([][]byte, len(matches))
+	changed := false
+	res, err := jsonUpdate(doc, matches, func(i int, n []byte) ([]byte, error) {
+		if n[0] != jsonArray {
+			return n, path.wrongType("array", n)
+		}
+		entries := jsonEntries(n)
+		if len(entries) == 0 {
+			return n, nil
+		}
+		idx := index
+		if idx < 0 {
+			idx += int64(len(entries))
+		}
+		idx = min(max(idx, 0), int64(len(entries)-1))
+		popped[i] = appendJSON(nil, entries[idx], JSONFormat{}, 0)
+		changed = true
+		return jsonContainer(jsonArray, slices.Delete(entries, int(idx), int(idx)+1)), nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if !changed {
+		res = nil
+	}
+	return res, popped, nil
+}
+
+// JSONObjKeys returns keys of objects at the path, nil for values that are not objects.
+func JSONObjKeys(doc []byte, path JSONPath) ([][][]byte, error) {
+	matches, err := path.legacyMatches(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([][][]byte, len(matches))
+	for i, m := range matches {
+		if m.node[0] != jsonObject {
+			if err := path.wrongType("object", m.node); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		keys := make([][]byte, jsonLen(m.node))
+		for j := range keys {
+			key, _ := jsonMember(jsonEntry(m.node, j))
+			keys[j] = bytes.Clone(key)
+		}
+		res[i] = keys
+	}
+	return res, nil
+}
+
+// JSONMerge merges the patch into values at the path like RFC 7396 does and returns
+// the new document, nil if nothing is changed. doc is nil for a missing key.
+// Null patch removes values, missing keys of existing objects are added like with JSONSet.
+func JSONMerge(doc []byte, path JSONPath, patch []byte) ([]byte, error) {
+	if doc == nil {
+		if !path.IsRoot() {
+			return nil, ErrJSONNewAtRoot
+		}
+		return jsonMergePatch(nil, patch), nil
+	}
+
+	matches := path.matches(doc)
+	if len(matches) > 0 {
+		return jsonUpdate(doc, matches, func(i int, n []byte) ([]byte, error) {
+			if patch[0] == jsonNull && len(matches[i].path) > 0 {
+				return nil, nil
+			}
+			return jsonMergePatch(n, patch), nil
+		})
+	}
+	if patch[0] == jsonNull {
+		return nil, nil
+	}
+	return jsonCreate(doc, path, jsonMergePatch(nil, patch))
+}
+
+// jsonMergePatch returns the target with the patch applied, nil target is a missing value.
+func jsonMergePatch(target, patch []byte) []byte {
+	if patch[0] != jsonObject {
+		return patch
+	}
+
+	var keys, vals [][]byte
+	if target != nil && target[0] == jsonObject {
+		for i := 0; i < jsonLen(target); i++ {
+			key, val := jsonMember(jsonEntry(target, i))
+			keys, vals = append(keys, key), append(vals, val)
+		}
+	}
+	for i := 0; i < jsonLen(patch); i++ {
+		key, val := jsonMember(jsonEntry(patch, i))
+		j := slices.IndexFunc(keys, func(k []byte) bool { return bytes.Equal(k, key) })
+		switch {
+		case val[0] == jsonNull && j >= 0:
+			keys, vals = slices.Delete(keys, j, j+1), slices.Delete(vals, j, j+1)
+		case val[0] == jsonNull:
+		case j >= 0:
+			vals[j] = jsonMergePatch(vals[j], val)
+		default:
+			keys, vals = append(keys, key), append(vals, jsonMergePatch(nil, val))
+		}
+	}
+	return jsonObjectOf(keys, vals)
+}
+
+// jsonCreate adds the value to objects matched by the parent of the path if the last step
+// of the path is a key they don't have, nil is returned if nothing is added.
+func jsonCreate(doc []byte, path JSONPath, value []byte) ([]byte, error) {
+	parent, key, ok := path.parent()
+	if !ok {
+		return nil, nil
+	}
+
+	added := false
+	res, err := jsonUpdate(doc, parent.eval(doc), func(_ int, n []byte) ([]byte, error) {
+		if n[0] != jsonObject || jsonFind(n, key) >= 0 {
+			return n, nil
+		}
+		added = true
+		entries := append(jsonEntries(n), jsonMemberEntry(key, value))
+		return jsonContainer(jsonObject, entries), nil
+	})
+	if err != nil || !added {
+		return nil, err
+	}
+	return res, nil
+}
+
+// jsonLenUpdate replaces values of the type at the path with results of fn
+// and returns their lengths, nil for values of other types.
+func jsonLenUpdate(doc []byte, path JSONPath, typ string, fn func(n []byte) ([]byte, int, error)) ([]byte, []*int64, error) {
+	matches, err := path.legacyMatches(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lens := make([]*int64, len(matches))
+	res, err := jsonUpdate(doc, matches, func(i int, n []byte) ([]byte, error) {
+		if jsonTypeName(n) != typ {
+			return n, path.wrongType(typ, n)
+		}
+		val, size, err := fn(n)
+		if err != nil {
+			return nil, err
+		}
+		lens[i] = new(int64)
+		*lens[i] = int64(size)
+		return val, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, lens, nil
+}
+
+// jsonUpdate replaces values at matches with results of fn, nil result removes the value.
+// Matches are visited in reverse document order, so positions of the ones left stay valid,
+// and fn sees updates of values nested in the matched one. The same match is visited once.
+func jsonUpdate(doc []byte, matches []jsonMatch, fn func(i int, n []byte) ([]byte, error)) ([]byte, error) {
+	order := make([]int, len(matches))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return slices.Compare(matches[b].path, matches[a].path)
+	})
+
+	for j, i := range order {
+		if j > 0 && slices.Equal(matches[i].path, matches[order[j-1]].path) {
+			continue
+		}
+		var err error
+		doc, err = jsonReplace(doc, matches[i].path, func(n []byte) ([]byte, error) {
+			return fn(i, n)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// jsonReplace replaces the value at path with the result of fn, nil result removes it.
+// Containers on the way are re-encoded with other entries copied as is.
+func jsonReplace(n []byte, path []int, fn func(n []byte) ([]byte, error)) ([]byte, error) {
+	if len(path) == 0 {
+		return fn(n)
+	}
+
+	entries := jsonEntries(n)
+	i := path[0]
+	var key []byte
+	val := entries[i]
+	if n[0] == jsonObject {
+		key, val = jsonMember(val)
+	}
+	val, err := jsonReplace(val, path[1:], fn)
+	switch {
+	case err != nil:
+		return nil, err
+	case val == nil:
+		entries = slices.Delete(entries, i, i+1)
+	case n[0] == jsonObject:
+		entries[i] = jsonMemberEntry(key, val)
+	default:
+		entries[i] = val
+	}
+	return jsonContainer(n[0], entries), nil
+}
+
+// jsonAdd returns the sum of two numbers, integers overflow into floats.
+func jsonAdd(a, b []byte) ([]byte, error) {
+	if a[0] == jsonInt && b[0] == jsonInt {
+		x, y := jsonIntVal(a), jsonIntVal(b)
+		if sum := x + y; (sum > x) == (y > 0) {
+			return jsonIntNode(sum), nil
+		}
+	}
+	sum := jsonNumber(a) + jsonNumber(b)
+	if math.IsInf(sum, 0) || math.IsNaN(sum) {
+		return nil, ErrJSONNotNumber
+	}
+	return jsonFloatNode(sum), nil
+}
+
+func jsonWrongValue(expected string, n []byte) error {
+	return NewError(PrefixErr, "wrong type of value - expected "+expected+" but found "+jsonTypeName(n))
+}
+
+// Nodes of the binary encoding.
+
+func jsonTypeName(n []byte) string {
+	switch n[0] {
+	case jsonNull:
+		return "null"
+	case jsonFalse, jsonTrue:
+		return "boolean"
+	case jsonInt:
+		return "integer"
+	case jsonFloat:
+		return "number"
+	case jsonString:
+		return "string"
+	case jsonArray:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func jsonIsNumber(n []byte) bool {
+	return n[0] == jsonInt || n[0] == jsonFloat
+}
+
+// jsonSize returns the size of the node.
+func jsonSize(n []byte) int {
+	switch n[0] {
+	case jsonInt, jsonFloat:
+		return 1 + 8
+	case jsonString:
+		return 1 + 4 + int(binary.BigEndian.Uint32(n[1:]))
+	case jsonArray, jsonObject:
+		return int(binary.BigEndian.Uint32(n[1:]))
+	default:
+		return 1
+	}
+}
+
+// jsonLen returns the number of entries of the container.
+func jsonLen(n []byte) int {
+	return int(binary.BigEndian.Uint32(n[5:]))
+}
+
+// jsonEntry returns the i-th entry of the container.
+func jsonEntry(n []byte, i int) []byte {
+	start := binary.BigEndian.Uint32(n[jsonContainerHdr+4*i:])
+	end := uint32(jsonSize(n))
+	if i+1 < jsonLen(n) {
+		end = binary.BigEndian.Uint32(n[jsonContainerHdr+4*(i+1):])
+	}
+	return n[start:end]
+}
+
+// jsonEntries returns all entries of the container.
+func jsonEntries(n []byte) [][]byte {
+	res := make([][]byte, jsonLen(n))
+	for i := range res {
+		res[i] = jsonEntry(n, i)
+	}
+	return res
+}
+
+// jsonChild returns the value of the i-th entry of the container.
+func jsonChild(n []byte, i int) []byte {
+	e := jsonEntry(n, i)
+	if n[0] == jsonObject {
+		_, e = jsonMember(e)
+	}
+	return e
+}
+
+// jsonMember splits an entry of an object into its key and value.
+func jsonMember(e []byte) ([]byte, []byte) {
+	k := 4 + binary.BigEndian.Uint32(e)
+	return e[4:k], e[k:]
+}
+
+// jsonFind returns position of the key in the object, -1 if it's missing.
+func jsonFind(n []byte, key []byte) int {
+	for i := 0; i < jsonLen(n); i++ {
+		if k, _ := jsonMember(jsonEntry(n, i)); bytes.Equal(k, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+func jsonStr(n []byte) []byte {
+	return n[5:jsonSize(n)]
+}
+
+func jsonIntVal(n []byte) int64 {
+	return int64(binary.BigEndian.Uint64(n[1:]))
+}
+
+// jsonNumber returns the value of an integer or a number as float.
+func jsonNumber(n []byte) float64 {
+	if n[0] == jsonInt {
+		return float64(jsonIntVal(n))
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(n[1:]))
+}
+
+func jsonIntNode(v int64) []byte {
+	return binary.BigEndian.AppendUint64([]byte{jsonInt}, uint64(v))
+}
+
+func jsonFloatNode(v float64) []byte {
+	return binary.BigEndian.AppendUint64([]byte{jsonFloat}, math.Float64bits(v))
+}
+
+func jsonStringNode(s []byte) []byte {
+	res := make([]byte, 0, 5+len(s))
+	res = append(res, jsonString)
+	res = binary.BigEndian.AppendUint32(res, uint32(len(s)))
+	return append(res, s...)
+}
+
+func jsonMemberEntry(key, val []byte) []byte {
+	res := make([]byte, 0, 4+len(key)+len(val))
+	res = binary.BigEndian.AppendUint32(res, uint32(len(key)))
+	res = append(res, key...)
+	return append(res, val...)
+}
+
+func jsonObjectOf(keys, vals [][]byte) []byte {
+	entries := make([][]byte, len(keys))
+	for i := range keys {
+		entries[i] = jsonMemberEntry(keys[i], vals[i])
+	}
+	return jsonContainer(jsonObject, entries)
+}
+
+// jsonMatchesArray returns an array of matched values.
+func jsonMatchesArray(matches []jsonMatch) []byte {
+	vals := make([][]byte, len(matches))
+	for i, m := range matches {
+		vals[i] = m.node
+	}
+	return jsonContainer(jsonArray, vals)
+}
+
+// jsonContainer encodes an array or an object of the entries.
+func jsonContainer(tag byte, entries [][]byte) []byte {
+	hdr := jsonContainerHdr + 4*len(entries)
+	size := hdr
+	for _, e := range entries {
+		size += len(e)
+	}
+
+	res := make([]byte, hdr, size)
+	res[0] = tag
+	binary.BigEndian.PutUint32(res[1:], uint32(size))
+	binary.BigEndian.PutUint32(res[5:], uint32(len(entries)))
+	off := hdr
+	for i, e := range entries {
+		binary.BigEndian.PutUint32(res[jsonContainerHdr+4*i:], uint32(off))
+		off += len(e)
+	}
+	for _, e := range entries {
+		res = append(res, e...)
+	}
+	return res
+}
+
+// ParseJSON parses JSON text into the binary encoding of documents.
+// Errors are reported like RedisJSON does.
+func ParseJSON(text []byte) ([]byte, error) {
+	p := jsonParser{s: text}
+	p.skipSpace()
+	n, err := p.value(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return nil, p.errorf("trailing characters")
+	}
+	return n, nil
+}
+
+var jsonLiterals = []struct {
+	text string
+	tag  byte
+}{
+	{"null", jsonNull},
+	{"true", jsonTrue},
+	{"false", jsonFalse},
+}
+
+type jsonParser struct {
+	s   []byte
+	pos int
+}
+
+func (p *jsonParser) errorf(msg string) error {
+	line, col := 1, 0
+	for _, c := range p.s[:min(p.pos+1, len(p.s))] {
+		if c == '\n' {
+			line, col = line+1, 0
+		} else {
+			col++
+		}
+	}
+	return NewError(PrefixErr, fmt.Sprintf("%s at line %d column %d", msg, line, col))
+}
+
+func (p *jsonParser) skipSpace() {
+	for p.pos < len(p.s) {
+		switch p.s[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *jsonParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *jsonParser) value(depth int) ([]byte, error) {
+	if depth > jsonMaxDepth {
+		return nil, p.errorf("recursion limit exceeded")
+	}
+	if p.eof() {
+		return nil, p.errorf("EOF while parsing a value")
+	}
+
+	switch c := p.s[p.pos]; {
+	case c == '{':
+		return p.object(depth)
+	case c == '[':
+		return p.array(depth)
+	case c == '"':
+		s, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		return jsonStringNode(s), nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.number()
+	}
+
+	for _, lit := range jsonLiterals {
+		if bytes.HasPrefix(p.s[p.pos:], []byte(lit.text)) {
+			p.pos += len(lit.text)
+			return []byte{lit.tag}, nil
+		}
+	}
+	return nil, p.errorf("expected value")
+}
+
+func (p *jsonParser) array(depth int) ([]byte, error) {
+	p.pos++
+	p.skipSpace()
+	if !p.eof() && p.s[p.pos] == ']' {
+		p.pos++
+		return jsonContainer(jsonArray, nil), nil
+	}
+
+	var entries [][]byte
+	for {
+		p.skipSpace()
+		val, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, val)
+
+		p.skipSpace()
+		switch {
+		case p.eof():
+			return nil, p.errorf("EOF while parsing a list")
+		case p.s[p.pos] == ',':
+			p.pos++
+		case p.s[p.pos] == ']':
+			p.pos++
+			return jsonContainer(jsonArray, entries), nil
+		default:
+			return nil, p.errorf("expected `,` or `]`")
+		}
+	}
+}
+
+func (p *jsonParser) object(depth int) ([]byte, error) {
+	p.pos++
+	p.skipSpace()
+	if !p.eof() && p.s[p.pos] == '}' {
+		p.pos++
+		return jsonContainer(jsonObject, nil), nil
+	}
+
+	var keys, vals [][]byte
+	// duplicate keys keep the first position and the last value.
+	pos := map[string]int{}
+	for {
+		p.skipSpace()
+		switch {
+		case p.eof():
+			return nil, p.errorf("EOF while parsing an object")
+		case p.s[p.pos] != '"':
+			return nil, p.errorf("key must be a string")
+		}
+		key, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpace()
+		switch {
+		case p.eof():
+			return nil, p.errorf("EOF while parsing an object")
+		case p.s[p.pos] != ':':
+			return nil, p.errorf("expected `:`")
+		}
+		p.pos++
+		p.skipSpace()
+		val, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := pos[string(key)]; ok {
+			vals[i] = val
+		} else {
+			pos[string(key)] = len(keys)
+			keys, vals = append(keys, key), append(vals, val)
+		}
+
+		p.skipSpace()
+		switch {
+		case p.eof():
+			return nil, p.errorf("EOF while parsing an object")
+		case p.s[p.pos] == ',':
+			p.pos++
+		case p.s[p.pos] == '}':
+			p.pos++
+			return jsonObjectOf(keys, vals), nil
+		default:
+			return nil, p.errorf("expected `,` or `}`")
+		}
+	}
+}
+
+// string parses a string starting at the opening quote.
+func (p *jsonParser) string() ([]byte, error) {
+	p.pos++
+	res := []byte{}
+	for {
+		if p.eof() {
+			return nil, p.errorf("EOF while parsing a string")
+		}
+		c := p.s[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return res, nil
+		case c < 0x20:
+			return nil, p.errorf("control character (\\u0000-\\u001F) found while parsing a string")
+		case c != '\\':
+			res = append(res, c)
+			p.pos++
+			continue
+		}
+
+		p.pos++
+		if p.eof() {
+			return nil, p.errorf("EOF while parsing a string")
+		}
+		switch e := p.s[p.pos]; e {
+		case '"', '\\', '/':
+			res = append(res, e)
+		case 'b':
+			res = append(res, '\b')
+		case 'f':
+			res = append(res, '\f')
+		case 'n':
+			res = append(res, '\n')
+		case 'r':
+			res = append(res, '\r')
+		case 't':
+			res = append(res, '\t')
+		case 'u':
+			r, err := p.unicode()
+			if err != nil {
+				return nil, err
+			}
+			res = utf8.AppendRune(res, r)
+			continue
+		default:
+			return nil, p.errorf("invalid escape")
+		}
+		p.pos++
+	}
+}
+
+// unicode parses \u escape starting at u, surrogate pairs are combined.
+func (p *jsonParser) unicode() (rune, error) {
+	r, err := p.hex()
+	if err != nil {
+		return 0, err
+	}
+	if r < 0xd800 || r > 0xdfff {
+		return r, nil
+	}
+	if r >= 0xdc00 || !bytes.HasPrefix(p.s[p.pos:], []byte(`\u`)) {
+		return 0, p.errorf("lone leading surrogate in hex escape")
+	}
+	p.pos++
+	lo, err := p.hex()
+	if err != nil {
+		return 0, err
+	}
+	if lo < 0xdc00 || lo > 0xdfff {
+		return 0, p.errorf("lone leading surrogate in hex escape")
+	}
+	return 0x10000 + (r-0xd800)<<10 + (lo - 0xdc00), nil
+}
+
+// hex parses 4 hex digits after u and moves past them.
+func (p *jsonParser) hex() (rune, error) {
+	if p.pos+5 > len(p.s) {
+		p.pos = len(p.s)
+		return 0, p.errorf("EOF while parsing a string")
+	}
+	v, err := strconv.ParseUint(string(p.s[p.pos+1:p.pos+5]), 16, 16)
+	if err != nil {
+		return 0, p.errorf("invalid escape")
+	}
+	p.pos += 5
+	return rune(v), nil
+}
+
+func (p *jsonParser) number() ([]byte, error) {
+	start := p.pos
+	digits := func() bool {
+		from := p.pos
+		for !p.eof() && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+			p.pos++
+		}
+		return p.pos > from
+	}
+
+	if p.s[p.pos] == '-' {
+		p.pos++
+	}
+	switch {
+	case !p.eof() && p.s[p.pos] == '0':
+		p.pos++
+	case !digits():
+		return nil, p.errorf("invalid number")
+	}
+	isFloat := false
+	if !p.eof() && p.s[p.pos] == '.' {
+		p.pos++
+		if !digits() {
+			return nil, p.errorf("invalid number")
+		}
+		isFloat = true
+	}
+	if !p.eof() && (p.s[p.pos] == 'e' || p.s[p.pos] == 'E') {
+		p.pos++
+		if !p.eof() && (p.s[p.pos] == '+' || p.s[p.pos] == '-') {
+			p.pos++
+		}
+		if !digits() {
+			return nil, p.errorf("invalid number")
+		}
+		isFloat = true
+	}
+
+	text := string(p.s[start:p.pos])
+	if !isFloat {
+		if v, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return jsonIntNode(v), nil
+		}
+	}
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, p.errorf("number out of range")
+	}
+	return jsonFloatNode(v), nil
+}
+
+// appendJSON appends the node as JSON text, depth is the nesting level for indentation.
+func appendJSON(dst, n []byte, f JSONFormat, depth int) []byte {
+	switch n[0] {
+	case jsonNull:
+		return append(dst, "null"...)
+	case jsonFalse:
+		return append(dst, "false"...)
+	case jsonTrue:
+		return append(dst, "true"...)
+	case jsonInt:
+		return strconv.AppendInt(dst, jsonIntVal(n), 10)
+	case jsonFloat:
+		return appendJSONFloat(dst, jsonNumber(n))
+	case jsonString:
+		return appendJSONString(dst, jsonStr(n))
+	}
+
+	open, end := byte('['), byte(']')
+	if n[0] == jsonObject {
+		open, end = '{', '}'
+	}
+	dst = append(dst, open)
+	for i := 0; i < jsonLen(n); i++ {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, f.Newline...)
+		dst = append(dst, strings.Repeat(f.Indent, depth+1)...)
+		val := jsonEntry(n, i)
+		if n[0] == jsonObject {
+			var key []byte
+			key, val = jsonMember(val)
+			dst = appendJSONString(dst, key)
+			dst = append(dst, ':')
+			dst = append(dst, f.Space...)
+		}
+		dst = appendJSON(dst, val, f, depth+1)
+	}
+	if jsonLen(n) > 0 {
+		dst = append(dst, f.Newline...)
+		dst = append(dst, strings.Repeat(f.Indent, depth)...)
+	}
+	return append(dst, end)
+}
+
+// appendJSONFloat formats numbers like RedisJSON does: the shortest representation,
+// with .0 for integral values and in exponent form for very small and very large ones.
+func appendJSONFloat(dst []byte, v float64) []byte {
+	if abs := math.Abs(v); abs != 0 && (abs < 1e-5 || abs >= 1e16) {
+		s := strconv.FormatFloat(v, 'e', -1, 64)
+		mant, exp, _ := strings.Cut(s, "e")
+		e, _ := strconv.Atoi(exp)
+		dst = append(dst, mant...)
+		dst = append(dst, 'e')
+		return strconv.AppendInt(dst, int64(e), 10)
+	}
+	start := len(dst)
+	dst = strconv.AppendFloat(dst, v, 'f', -1, 64)
+	if !bytes.ContainsRune(dst[start:], '.') {
+		dst = append(dst, ".0"...)
+	}
+	return dst
+}
+
+func appendJSONString(dst, s []byte) []byte {
+	const hex = "0123456789abcdef"
+
+	dst = append(dst, '"')
+	for _, c := range s {
+		switch c {
+		case '"', '\\':
+			dst = append(dst, '\\', c)
+		case '\b':
+			dst = append(dst, '\\', 'b')
+		case '\f':
+			dst = append(dst, '\\', 'f')
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		case '\t':
+			dst = append(dst, '\\', 't')
+		default:
+			if c < 0x20 {
+				dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			} else {
+				dst = append(dst, c)
+			}
+		}
+	}
+	return append(dst, '"')
+}
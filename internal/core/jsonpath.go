@@ -0,0 +1,635 @@
+package core
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a path of JSON commands, either a JSONPath starting with $ or a legacy path.
+// Supported are keys (.key, ['key']), wildcards (.*, [*]), indexes and unions of them ([0,-1]),
+// slices ([1:5:2]), recursive descent (..key) and filters ([?(@.price < 10 && @.tag == 'a')]).
+type JSONPath struct {
+	// Legacy is set for paths not starting with $, they address a single value
+	// and fail when nothing matches.
+	Legacy bool
+	raw    string
+	segs   []jsonSegment
+}
+
+// JSONRoot is the root of documents, it's the default path of commands.
+var JSONRoot = JSONPath{Legacy: true, raw: "."}
+
+type jsonSelector int
+
+const (
+	jsonSelKeys jsonSelector = iota
+	jsonSelIndexes
+	jsonSelWildcard
+	jsonSelSlice
+	jsonSelFilter
+)
+
+// jsonSegment selects children of values, or of values and all their descendants for `..`.
+type jsonSegment struct {
+	descendant bool
+	sel        jsonSelector
+	keys       []string
+	indexes    []int
+	// slice bounds, nil for defaults.
+	start, end *int
+	step       int
+	filter     *jsonFilter
+}
+
+// jsonMatch is a value matched by a path, path holds positions of entries from the root.
+type jsonMatch struct {
+	path []int
+	node []byte
+}
+
+// ParseJSONPath parses a path, paths without $ are legacy ones.
+func ParseJSONPath(path []byte) (JSONPath, error) {
+	s := string(path)
+	res := JSONPath{raw: s}
+	switch {
+	case strings.HasPrefix(s, "$"):
+		s = s[1:]
+	case s == ".":
+		res.Legacy, s = true, ""
+	case s == "":
+		return JSONPath{}, res.invalid(0)
+	default:
+		res.Legacy = true
+		if s[0] != '.' && s[0] != '[' {
+			s = "." + s
+		}
+	}
+
+	p := jsonPathParser{s: s}
+	segs, err := p.segments()
+	if err == nil && p.pos < len(s) {
+		err = errJSONPath
+	}
+	if err != nil {
+		return JSONPath{}, res.invalid(p.pos)
+	}
+	res.segs = segs
+	return res, nil
+}
+
+// String returns the path as it was given.
+func (p JSONPath) String() string {
+	return p.raw
+}
+
+// IsRoot reports whether the path is the root of documents.
+func (p JSONPath) IsRoot() bool {
+	return len(p.segs) == 0
+}
+
+func (p JSONPath) invalid(pos int) error {
+	return NewError(PrefixErr, "invalid JSONPath '"+p.raw+"' at position "+strconv.Itoa(pos))
+}
+
+func (p JSONPath) notFound() error {
+	return NewError(PrefixErr, "Path '"+p.raw+"' does not exist")
+}
+
+// wrongType returns an error for a value of unexpected type for legacy paths,
+// other paths skip such values.
+func (p JSONPath) wrongType(expected string, n []byte) error {
+	if !p.Legacy {
+		return nil
+	}
+	return NewError(PrefixErr, "wrong type of path value - expected "+expected+" but found "+jsonTypeName(n))
+}
+
+// parent returns the path without the last step and the key of the last step
+// if it's a single key.
+func (p JSONPath) parent() (JSONPath, []byte, bool) {
+	if len(p.segs) == 0 {
+		return JSONPath{}, nil, false
+	}
+	last := p.segs[len(p.segs)-1]
+	if last.descendant || last.sel != jsonSelKeys || len(last.keys) != 1 {
+		return JSONPath{}, nil, false
+	}
+	parent := p
+	parent.segs = p.segs[:len(p.segs)-1]
+	return parent, []byte(last.keys[0]), true
+}
+
+// matches returns values matched by the path, a legacy path matches only the first one.
+func (p JSONPath) matches(doc []byte) []jsonMatch {
+	res := p.eval(doc)
+	if p.Legacy && len(res) > 1 {
+		res = res[:1]
+	}
+	return res
+}
+
+// legacyMatches is matches which fails for a legacy path that matches nothing.
+func (p JSONPath) legacyMatches(doc []byte) ([]jsonMatch, error) {
+	res := p.matches(doc)
+	if p.Legacy && len(res) == 0 {
+		return nil, p.notFound()
+	}
+	return res, nil
+}
+
+// eval returns values matched by the path in document order.
+func (p JSONPath) eval(doc []byte) []jsonMatch {
+	return evalJSONSegments(doc, doc, p.segs)
+}
+
+func evalJSONSegments(root, n []byte, segs []jsonSegment) []jsonMatch {
+	cur := []jsonMatch{{node: n}}
+	for i := range segs {
+		var next []jsonMatch
+		for _, m := range cur {
+			next = segs[i].apply(root, m, next)
+		}
+		cur = next
+	}
+	return cur
+}
+
+func (seg *jsonSegment) apply(root []byte, m jsonMatch, res []jsonMatch) []jsonMatch {
+	res = seg.selectChildren(root, m, res)
+	if !seg.descendant || (m.node[0] != jsonArray && m.node[0] != jsonObject) {
+		return res
+	}
+	for i := 0; i < jsonLen(m.node); i++ {
+		res = seg.apply(root, jsonChildMatch(m, i), res)
+	}
+	return res
+}
+
+func (seg *jsonSegment) selectChildren(root []byte, m jsonMatch, res []jsonMatch) []jsonMatch {
+	n := m.node
+	switch {
+	case n[0] == jsonObject && seg.sel == jsonSelKeys:
+		for _, key := range seg.keys {
+			if i := jsonFind(n, []byte(key)); i >= 0 {
+				res = append(res, jsonChildMatch(m, i))
+			}
+		}
+
+	case n[0] == jsonArray && seg.sel == jsonSelIndexes:
+		size := jsonLen(n)
+		for _, i := range seg.indexes {
+			if i < 0 {
+				i += size
+			}
+			if i >= 0 && i < size {
+				res = append(res, jsonChildMatch(m, i))
+			}
+		}
+
+	case n[0] == jsonArray && seg.sel == jsonSelSlice:
+		size := jsonLen(n)
+		bound := func(v *int, def int) int {
+			if v == nil {
+				return def
+			}
+			if *v < 0 {
+				return max(*v+size, 0)
+			}
+			return min(*v, size)
+		}
+		for i := bound(seg.start, 0); i < bound(seg.end, size); i += seg.step {
+			res = append(res, jsonChildMatch(m, i))
+		}
+
+	case (n[0] == jsonArray || n[0] == jsonObject) && seg.sel == jsonSelWildcard:
+		for i := 0; i < jsonLen(n); i++ {
+			res = append(res, jsonChildMatch(m, i))
+		}
+
+	case (n[0] == jsonArray || n[0] == jsonObject) && seg.sel == jsonSelFilter:
+		for i := 0; i < jsonLen(n); i++ {
+			if seg.filter.match(root, jsonChild(n, i)) {
+				res = append(res, jsonChildMatch(m, i))
+			}
+		}
+	}
+	return res
+}
+
+func jsonChildMatch(m jsonMatch, i int) jsonMatch {
+	path := append(m.path[:len(m.path):len(m.path)], i)
+	return jsonMatch{path: path, node: jsonChild(m.node, i)}
+}
+
+// jsonFilter is an expression of a filter: a logical operation of other expressions,
+// a comparison of operands or a check that the left operand exists.
+type jsonFilter struct {
+	op          string
+	left, right *jsonFilter
+	lhs, rhs    jsonOperand
+	re          *regexp.Regexp
+}
+
+// jsonOperand is a literal value or a path relative to the current value (@) or the root ($).
+type jsonOperand struct {
+	value    []byte
+	segs     []jsonSegment
+	relative bool
+}
+
+func (o jsonOperand) values(root, n []byte) [][]byte {
+	if o.value != nil {
+		return [][]byte{o.value}
+	}
+	if !o.relative {
+		n = root
+	}
+	var res [][]byte
+	for _, m := range evalJSONSegments(root, n, o.segs) {
+		res = append(res, m.node)
+	}
+	return res
+}
+
+func (f *jsonFilter) match(root, n []byte) bool {
+	switch f.op {
+	case "||":
+		return f.left.match(root, n) || f.right.match(root, n)
+	case "&&":
+		return f.left.match(root, n) && f.right.match(root, n)
+	case "!":
+		return !f.left.match(root, n)
+	case "":
+		vals := f.lhs.values(root, n)
+		return len(vals) > 0 && !(f.lhs.value != nil && vals[0][0] == jsonFalse)
+	}
+
+	for _, l := range f.lhs.values(root, n) {
+		if f.re != nil {
+			if l[0] == jsonString && f.re.Match(jsonStr(l)) {
+				return true
+			}
+			continue
+		}
+		for _, r := range f.rhs.values(root, n) {
+			if jsonCompareOp(f.op, l, r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonCompareOp(op string, a, b []byte) bool {
+	c, ok := 0, false
+	switch {
+	case jsonIsNumber(a) && jsonIsNumber(b):
+		if a[0] == jsonInt && b[0] == jsonInt {
+			c, ok = cmp.Compare(jsonIntVal(a), jsonIntVal(b)), true
+		} else {
+			c, ok = cmp.Compare(jsonNumber(a), jsonNumber(b)), true
+		}
+	case a[0] == jsonString && b[0] == jsonString:
+		c, ok = bytes.Compare(jsonStr(a), jsonStr(b)), true
+	case op == "==" || op == "!=":
+		// other values are only equal to the same ones.
+		if bytes.Equal(a, b) {
+			c, ok = 0, true
+		}
+	}
+
+	switch op {
+	case "==":
+		return ok && c == 0
+	case "!=":
+		return !ok || c != 0
+	case "<":
+		return ok && c < 0
+	case "<=":
+		return ok && c <= 0
+	case ">":
+		return ok && c > 0
+	default:
+		return ok && c >= 0
+	}
+}
+
+type jsonPathParser struct {
+	s   string
+	pos int
+}
+
+// errJSONPath stops parsing, position of the parser is reported with the path.
+var errJSONPath = errors.New("invalid JSONPath")
+
+func (p *jsonPathParser) peek(s string) bool {
+	return strings.HasPrefix(p.s[p.pos:], s)
+}
+
+func (p *jsonPathParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// segments parses steps of a path while they follow.
+func (p *jsonPathParser) segments() ([]jsonSegment, error) {
+	var res []jsonSegment
+	for p.peek(".") || p.peek("[") {
+		seg, err := p.segment()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, seg)
+	}
+	return res, nil
+}
+
+func (p *jsonPathParser) segment() (jsonSegment, error) {
+	var seg jsonSegment
+	switch {
+	case p.peek(".."):
+		p.pos += 2
+		seg.descendant = true
+		if p.peek("[") {
+			return p.bracket(seg)
+		}
+	case p.peek("."):
+		p.pos++
+	default:
+		return p.bracket(seg)
+	}
+
+	if p.peek("*") {
+		p.pos++
+		seg.sel = jsonSelWildcard
+		return seg, nil
+	}
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(".[]()=!<>&|,'\" \t", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return seg, errJSONPath
+	}
+	seg.sel, seg.keys = jsonSelKeys, []string{p.s[start:p.pos]}
+	return seg, nil
+}
+
+// bracket parses a step in brackets starting at [.
+func (p *jsonPathParser) bracket(seg jsonSegment) (jsonSegment, error) {
+	p.pos++
+	p.skipSpace()
+
+	var err error
+	switch {
+	case p.peek("*"):
+		p.pos++
+		seg.sel = jsonSelWildcard
+	case p.peek("?("):
+		p.pos += 2
+		seg.sel = jsonSelFilter
+		if seg.filter, err = p.filterOr(); err != nil {
+			return seg, err
+		}
+		p.skipSpace()
+		if !p.peek(")") {
+			return seg, errJSONPath
+		}
+		p.pos++
+	case p.peek("'") || p.peek(`"`):
+		seg.sel = jsonSelKeys
+		for {
+			key, err := p.quoted()
+			if err != nil {
+				return seg, err
+			}
+			seg.keys = append(seg.keys, key)
+			if !p.union() {
+				break
+			}
+		}
+	default:
+		if err := p.indexes(&seg); err != nil {
+			return seg, err
+		}
+	}
+
+	p.skipSpace()
+	if !p.peek("]") {
+		return seg, errJSONPath
+	}
+	p.pos++
+	return seg, nil
+}
+
+// union moves past a comma separating entries of a union.
+func (p *jsonPathParser) union() bool {
+	p.skipSpace()
+	if !p.peek(",") {
+		return false
+	}
+	p.pos++
+	p.skipSpace()
+	return true
+}
+
+// indexes parses an index, a union of indexes or a slice.
+func (p *jsonPathParser) indexes(seg *jsonSegment) error {
+	first, ok := p.int()
+	if !p.peek(":") {
+		if !ok {
+			return errJSONPath
+		}
+		seg.sel, seg.indexes = jsonSelIndexes, []int{first}
+		for p.union() {
+			i, ok := p.int()
+			if !ok {
+				return errJSONPath
+			}
+			seg.indexes = append(seg.indexes, i)
+		}
+		return nil
+	}
+
+	seg.sel, seg.step = jsonSelSlice, 1
+	if ok {
+		seg.start = &first
+	}
+	p.pos++
+	if end, ok := p.int(); ok {
+		seg.end = &end
+	}
+	if p.peek(":") {
+		p.pos++
+		if step, ok := p.int(); ok {
+			seg.step = step
+		}
+	}
+	if seg.step <= 0 {
+		return errJSONPath
+	}
+	return nil
+}
+
+func (p *jsonPathParser) int() (int, bool) {
+	p.skipSpace()
+	start := p.pos
+	if p.peek("-") {
+		p.pos++
+	}
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	v, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, false
+	}
+	p.skipSpace()
+	return v, true
+}
+
+// quoted parses a string in single or double quotes, backslash escapes the next char.
+func (p *jsonPathParser) quoted() (string, error) {
+	quote := p.s[p.pos]
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return sb.String(), nil
+		case c == '\\' && p.pos < len(p.s):
+			sb.WriteByte(p.s[p.pos])
+			p.pos++
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", errJSONPath
+}
+
+func (p *jsonPathParser) filterOr() (*jsonFilter, error) {
+	left, err := p.filterAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace(); p.peek("||"); p.skipSpace() {
+		p.pos += 2
+		right, err := p.filterAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &jsonFilter{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *jsonPathParser) filterAnd() (*jsonFilter, error) {
+	left, err := p.filterUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace(); p.peek("&&"); p.skipSpace() {
+		p.pos += 2
+		right, err := p.filterUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &jsonFilter{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *jsonPathParser) filterUnary() (*jsonFilter, error) {
+	p.skipSpace()
+	switch {
+	case p.peek("!") && !p.peek("!="):
+		p.pos++
+		f, err := p.filterUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &jsonFilter{op: "!", left: f}, nil
+	case p.peek("("):
+		p.pos++
+		f, err := p.filterOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.peek(")") {
+			return nil, errJSONPath
+		}
+		p.pos++
+		return f, nil
+	}
+
+	lhs, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	f := &jsonFilter{lhs: lhs}
+	for _, op := range []string{"==", "!=", "<=", ">=", "=~", "<", ">"} {
+		if p.peek(op) {
+			f.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+	if f.op == "" {
+		return f, nil
+	}
+
+	if f.rhs, err = p.operand(); err != nil {
+		return nil, err
+	}
+	if f.op == "=~" {
+		if f.rhs.value == nil || f.rhs.value[0] != jsonString {
+			return nil, errJSONPath
+		}
+		if f.re, err = regexp.Compile(string(jsonStr(f.rhs.value))); err != nil {
+			return nil, errJSONPath
+		}
+	}
+	return f, nil
+}
+
+func (p *jsonPathParser) operand() (jsonOperand, error) {
+	p.skipSpace()
+	if p.peek("@") || p.peek("$") {
+		relative := p.peek("@")
+		p.pos++
+		segs, err := p.segments()
+		return jsonOperand{segs: segs, relative: relative}, err
+	}
+	if p.peek("'") {
+		s, err := p.quoted()
+		return jsonOperand{value: jsonStringNode([]byte(s))}, err
+	}
+
+	// other literals are JSON values.
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(")]=!<>&| \t", rune(p.s[p.pos])) {
+		if p.s[p.pos] == '"' {
+			if _, err := p.quoted(); err != nil {
+				return jsonOperand{}, err
+			}
+			continue
+		}
+		p.pos++
+	}
+	val, err := ParseJSON([]byte(p.s[start:p.pos]))
+	if err != nil || val[0] == jsonArray || val[0] == jsonObject {
+		p.pos = start
+		return jsonOperand{}, errJSONPath
+	}
+	return jsonOperand{value: val}, nil
+}
@@ -0,0 +1,142 @@
+package core
+
+var ErrLCSTooLarge = NewError(PrefixErr, "Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len")
+
+// LCSOptions are options for LCS command.
+type LCSOptions struct {
+	// Len returns only the length of the match.
+	Len bool
+	// Idx returns match positions.
+	Idx bool
+	// MinMatchLen restricts returned matches to the ones with at least this length.
+	MinMatchLen int
+}
+
+// LCSMatch is a range of a common subsequence, positions are inclusive.
+type LCSMatch struct {
+	Start1, End1 int
+	Start2, End2 int
+	Len          int
+}
+
+// LCSResult is a result of LCS command.
+type LCSResult struct {
+	// Seq is the longest common subsequence, set when no Len or Idx are requested.
+	Seq []byte
+	// Len is the length of the longest common subsequence.
+	Len int
+	// Matches are set only for Idx and are ordered from the end of the strings like in Redis.
+	Matches []LCSMatch
+}
+
+// LCS finds the longest common subsequence of a and b.
+//
+// Length alone is computed in linear memory. The subsequence and match
+// positions need the full table, its size is limited by MaxStringSize.
+func LCS(a, b []byte, opts LCSOptions) (LCSResult, error) {
+	if opts.Len && !opts.Idx {
+		return LCSResult{Len: lcsLen(a, b)}, nil
+	}
+
+	n, m := len(a), len(b)
+	size := uint64(n+1) * uint64(m+1) * 4
+	if size > MaxStringSize {
+		return LCSResult{}, ErrLCSTooLarge
+	}
+
+	// dp[j*(n+1)+i] is the LCS length of a[:i] and b[:j].
+	dp := make([]uint32, (n+1)*(m+1))
+	at := func(i, j int) uint32 { return dp[j*(n+1)+i] }
+
+	for i := 1; i <= n; i++ {
+		for j := 1; j <= m; j++ {
+			if a[i-1] == b[j-1] {
+				dp[j*(n+1)+i] = at(i-1, j-1) + 1
+			} else {
+				dp[j*(n+1)+i] = max(at(i-1, j), at(i, j-1))
+			}
+		}
+	}
+
+	res := LCSResult{Len: int(at(n, m))}
+	seq := make([]byte, res.Len)
+	idx := res.Len
+
+	// Walk back the table exactly like Redis does, so ranges are the same.
+	i, j := n, m
+	start1, end1, start2, end2 := n, 0, 0, 0 // start1 == n means no range yet.
+	for i > 0 && j > 0 {
+		emit := false
+		if a[i-1] == b[j-1] {
+			seq[idx-1] = a[i-1]
+
+			switch {
+			case start1 == n:
+				start1, end1 = i-1, i-1
+				start2, end2 = j-1, j-1
+			case start1 == i && start2 == j:
+				// extend current range backward.
+				start1--
+				start2--
+			default:
+				emit = true
+			}
+			// one of the strings is exhausted.
+			if start1 == 0 || start2 == 0 {
+				emit = true
+			}
+			idx--
+			i--
+			j--
+		} else {
+			if at(i-1, j) > at(i, j-1) {
+				i--
+			} else {
+				j--
+			}
+			if start1 != n {
+				emit = true
+			}
+		}
+
+		if emit {
+			matchLen := end1 - start1 + 1
+			if opts.Idx && matchLen >= opts.MinMatchLen {
+				res.Matches = append(res.Matches, LCSMatch{
+					Start1: start1,
+					End1:   end1,
+					Start2: start2,
+					End2:   end2,
+					Len:    matchLen,
+				})
+			}
+			start1 = n
+		}
+	}
+
+	if !opts.Idx {
+		res.Seq = seq
+	}
+	return res, nil
+}
+
+// lcsLen computes the LCS length keeping only 2 rows of the table.
+func lcsLen(a, b []byte) int {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				curr[j] = prev[j-1] + 1
+			} else {
+				curr[j] = max(prev[j], curr[j-1])
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,50 @@
+package core
+
+var ErrIndexOutOfRange = NewError(PrefixErr, "index out of range")
+
+// ListSide is an end of a list.
+type ListSide int
+
+const (
+	ListLeft ListSide = iota
+	ListRight
+)
+
+// LPosOptions are options for LPOS command.
+type LPosOptions struct {
+	// Rank is 1-based position of the first match to return,
+	// negative rank searches from the tail. Zero is treated as 1.
+	Rank int
+	// Count is how many matches to return, zero returns all of them.
+	Count int
+	// MaxLen limits how many elements are compared, zero means no limit.
+	MaxLen int
+}
+
+// ListRange converts start and stop (both inclusive, might be negative like in LRANGE)
+// to a half-open range [from, to) of a list of the given length.
+// Empty range is returned if nothing is selected.
+func ListRange(start, stop, n int) (from, to int) {
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	start = max(start, 0)
+	stop = min(stop, n-1)
+
+	if start > stop {
+		return 0, 0
+	}
+	return start, stop + 1
+}
+
+// ListIndex converts index (might be negative like in LINDEX) to a position
+// in a list of the given length, reports whether the index is in range.
+func ListIndex(index, n int) (int, bool) {
+	if index < 0 {
+		index += n
+	}
+	return index, index >= 0 && index < n
+}
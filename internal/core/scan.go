@@ -0,0 +1,194 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+)
+
+// ScanOptions are options for SCAN command.
+type ScanOptions struct {
+	// Match is a glob-style pattern keys must match, empty matches everything.
+	Match []byte
+	// Count is how many keys are checked in one call at most.
+	Count int
+	// Type filters keys by their type, empty matches every type.
+	Type string
+}
+
+// DefaultScanCount is used when SCAN has no COUNT option.
+const DefaultScanCount = 10
+
+// Scan cursor has the highest byte set to 1, so it's never zero, followed by
+// the first 5 bytes of the key from which scan continues and a sequence number:
+//
+//	0x01 + key[:5] + seq
+//
+// The key itself is remembered by ScanCursors, so scan continues exactly from it.
+// A forgotten cursor (after a restart or when overwritten by newer cursors)
+// continues from the key prefix, keys sharing the prefix might be returned
+// more than once, which is allowed by SCAN guarantees.
+const (
+	cursorPrefixLen = 5
+	cursorSeqLen    = 2
+	cursorMarker    = 1 << 56
+
+	// scanCursorsSize is how many cursors are remembered.
+	scanCursorsSize = 1 << 12
+)
+
+// ScanCursors remembers keys of recently returned scan cursors.
+// The zero value is ready to use.
+type ScanCursors struct {
+	mu      sync.Mutex
+	seq     uint64
+	cursors []scanCursor
+}
+
+type scanCursor struct {
+	cursor uint64
+	scope  []byte
+	key    []byte
+}
+
+// Next returns a cursor to continue the scan of scope from the given key.
+// Scope is a key of the scanned collection or nil for SCAN.
+func (c *ScanCursors) Next(scope, key []byte) uint64 {
+	var buf [8]byte
+	copy(buf[1:1+cursorPrefixLen], key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq = (c.seq + 1) % (1 << (cursorSeqLen * 8))
+	binary.BigEndian.PutUint16(buf[8-cursorSeqLen:], uint16(c.seq))
+	cursor := binary.BigEndian.Uint64(buf[:]) | cursorMarker
+
+	if c.cursors == nil {
+		c.cursors = make([]scanCursor, scanCursorsSize)
+	}
+	c.cursors[c.seq%scanCursorsSize] = scanCursor{
+		cursor: cursor,
+		scope:  bytes.Clone(scope),
+		key:    bytes.Clone(key),
+	}
+	return cursor
+}
+
+// Seek returns the smallest key from which the scan of scope continues for the given cursor.
+func (c *ScanCursors) Seek(scope []byte, cursor uint64) []byte {
+	if cursor == 0 {
+		return []byte{}
+	}
+
+	c.mu.Lock()
+	var key []byte
+	if c.cursors != nil {
+		seq := cursor & (1<<(cursorSeqLen*8) - 1)
+		if sc := c.cursors[seq%scanCursorsSize]; sc.cursor == cursor && bytes.Equal(sc.scope, scope) {
+			key = bytes.Clone(sc.key)
+		}
+	}
+	c.mu.Unlock()
+	if key != nil {
+		return key
+	}
+
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], cursor)
+	return bytes.TrimRight(buf[1:1+cursorPrefixLen], "\x00")
+}
+
+// Match reports whether str matches the Redis glob-style pattern.
+// Supported: * ? [abc] [^abc] [a-z] and \ to escape special characters.
+func Match(pattern, str []byte) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(str); i++ {
+				if Match(pattern[1:], str[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(str) == 0 {
+				return false
+			}
+			str = str[1:]
+
+		case '[':
+			if len(str) == 0 {
+				return false
+			}
+			pattern = pattern[1:]
+			not := len(pattern) > 0 && pattern[0] == '^'
+			if not {
+				pattern = pattern[1:]
+			}
+
+			match := false
+		class:
+			for {
+				switch {
+				case len(pattern) >= 2 && pattern[0] == '\\':
+					pattern = pattern[1:]
+					if pattern[0] == str[0] {
+						match = true
+					}
+				case len(pattern) == 0:
+					// unterminated class is treated as closed.
+					return match != not && len(str) == 1
+				case pattern[0] == ']':
+					break class
+				case len(pattern) >= 3 && pattern[1] == '-':
+					start, end := pattern[0], pattern[2]
+					if start > end {
+						start, end = end, start
+					}
+					pattern = pattern[2:]
+					if str[0] >= start && str[0] <= end {
+						match = true
+					}
+				default:
+					if pattern[0] == str[0] {
+						match = true
+					}
+				}
+				pattern = pattern[1:]
+			}
+
+			if match == not {
+				return false
+			}
+			str = str[1:]
+
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+
+		default:
+			if len(str) == 0 || pattern[0] != str[0] {
+				return false
+			}
+			str = str[1:]
+		}
+
+		pattern = pattern[1:]
+		if len(str) == 0 {
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			return len(pattern) == 0
+		}
+	}
+	return len(str) == 0
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"bytes"
+	"slices"
+)
+
+// SetDiff returns members of the first set that are not in the other ones, sorted.
+func SetDiff(sets ...[][]byte) [][]byte {
+	if len(sets) == 0 {
+		return [][]byte{}
+	}
+	others := map[string]bool{}
+	for _, set := range sets[1:] {
+		for _, member := range set {
+			others[string(member)] = true
+		}
+	}
+
+	res := [][]byte{}
+	for _, member := range sets[0] {
+		if !others[string(member)] {
+			res = append(res, member)
+		}
+	}
+	return sortMembers(res)
+}
+
+// SetInter returns members that are in all sets, sorted.
+// Positive limit stops after so many members are found.
+func SetInter(limit int, sets ...[][]byte) [][]byte {
+	if len(sets) == 0 {
+		return [][]byte{}
+	}
+	// the smallest set is checked against the others.
+	sets = slices.Clone(sets)
+	slices.SortFunc(sets, func(a, b [][]byte) int {
+		return len(a) - len(b)
+	})
+
+	others := make([]map[string]bool, len(sets)-1)
+	for i, set := range sets[1:] {
+		others[i] = make(map[string]bool, len(set))
+		for _, member := range set {
+			others[i][string(member)] = true
+		}
+	}
+
+	res := [][]byte{}
+	for _, member := range sets[0] {
+		if limit > 0 && len(res) == limit {
+			break
+		}
+		inAll := true
+		for _, other := range others {
+			if !other[string(member)] {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			res = append(res, member)
+		}
+	}
+	return sortMembers(res)
+}
+
+// SetUnion returns members that are in any set, sorted.
+func SetUnion(sets ...[][]byte) [][]byte {
+	seen := map[string]bool{}
+	res := [][]byte{}
+	for _, set := range sets {
+		for _, member := range set {
+			if !seen[string(member)] {
+				seen[string(member)] = true
+				res = append(res, member)
+			}
+		}
+	}
+	return sortMembers(res)
+}
+
+func sortMembers(members [][]byte) [][]byte {
+	slices.SortFunc(members, bytes.Compare)
+	return members
+}
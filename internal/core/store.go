@@ -1,14 +1,53 @@
 package core
 
-import "errors"
+import "context"
 
-var (
-	ErrKeyNotFound        = errors.New("key not found")
-	ErrNotIntOrOutOfRange = errors.New("value is not an integer or out of range")
-)
+// MaxStringSize is the maximum size of a string value (512MB like in Redis).
+const MaxStringSize = 512 << 20
 
 type Store interface {
+	// Run background maintenance (like eviction of expired keys) until ctx is done.
+	Run(ctx context.Context) error
+
+	KeyspaceStore
 	StringsStore
+	ExpireStore
+	ListsStore
+	HashesStore
+	SetsStore
+	SortedSetsStore
+	StreamsStore
+	BitmapsStore
+	HyperLogLogStore
+	GeoStore
+	JSONStore
+	BloomStore
+	CuckooStore
+	CountMinSketchStore
+	TopKStore
+	TimeSeriesStore
+}
+
+// SetOptions are options for SET command.
+type SetOptions struct {
+	// NX sets the key only if it does not already exist.
+	NX bool
+	// XX sets the key only if it already exists.
+	XX bool
+	// Get returns the old value stored at key.
+	Get bool
+	// KeepTTL retains the expiry associated with the key.
+	KeepTTL bool
+	// ExpireAt is unix time in milliseconds when the key expires, zero means never.
+	ExpireAt int64
+}
+
+// GetExOptions are options for GETEX command.
+type GetExOptions struct {
+	// Persist removes the expiry associated with the key.
+	Persist bool
+	// ExpireAt is unix time in milliseconds when the key expires, zero leaves expiry as is.
+	ExpireAt int64
 }
 
 type StringsStore interface {
@@ -17,21 +56,354 @@ type StringsStore interface {
 	DECRBY(key []byte, by int) (int64, error)
 	GET(key []byte) ([]byte, error)
 	GETDEL(key []byte) ([]byte, error)
-	// TODO: GETEX()
+	GETEX(key []byte, opts GetExOptions) ([]byte, error)
 	GETRANGE(key []byte, start, end int) ([]byte, error)
 	GETSET(key, value []byte) ([]byte, error)
 	INCR(key []byte) (int64, error)
 	INCRBY(key []byte, by int) (int64, error)
 	INCRBYFLOAT(key []byte, by float64) (string, error)
-	// TODO: LCS()
+	LCS(key1, key2 []byte, opts LCSOptions) (LCSResult, error)
 	MGET(keys ...[]byte) ([][]byte, error)
 	MSET(keyvals ...[]byte) error
-	// TODO: MSETNX()
-	// TODO: PSETEX()
-	SET(key, value []byte) error
-	// TODO: SETEX()
-	// TODO: SETNX()
-	// TODO: SETRANGE(key []byte, offset int, value []byte)
+	MSETNX(keyvals ...[]byte) (bool, error)
+	PSETEX(key []byte, milliseconds int64, value []byte) error
+	// SET returns the old value if opts.Get is set and reports whether the value was set.
+	SET(key, value []byte, opts SetOptions) ([]byte, bool, error)
+	SETEX(key []byte, seconds int64, value []byte) error
+	SETNX(key, value []byte) (bool, error)
+	SETRANGE(key []byte, offset int, value []byte) (int, error)
 	STRLEN(key []byte) (int64, error)
-	// TODO: SUBSTR(key []byte, start, end int)
+	SUBSTR(key []byte, start, end int) ([]byte, error)
+}
+
+type KeyspaceStore interface {
+	COPY(src, dst []byte, replace bool) (bool, error)
+	DEL(keys ...[]byte) (int, error)
+	EXISTS(keys ...[]byte) (int, error)
+	FLUSHDB() error
+	KEYS(pattern []byte) ([][]byte, error)
+	RENAME(key, newkey []byte) error
+	RENAMENX(key, newkey []byte) (bool, error)
+	SCAN(cursor uint64, opts ScanOptions) ([][]byte, uint64, error)
+	TOUCH(keys ...[]byte) (int, error)
+	TYPE(key []byte) (string, error)
+	UNLINK(keys ...[]byte) (int, error)
+}
+
+type ExpireStore interface {
+	EXPIRE(key []byte, seconds int64, cond ExpireCond) (bool, error)
+	EXPIREAT(key []byte, unixSeconds int64, cond ExpireCond) (bool, error)
+	EXPIRETIME(key []byte) (int64, error)
+	PERSIST(key []byte) (bool, error)
+	PEXPIRE(key []byte, milliseconds int64, cond ExpireCond) (bool, error)
+	PEXPIREAT(key []byte, unixMilliseconds int64, cond ExpireCond) (bool, error)
+	PEXPIRETIME(key []byte) (int64, error)
+	PTTL(key []byte) (int64, error)
+	TTL(key []byte) (int64, error)
+}
+
+type ListsStore interface {
+	LINDEX(key []byte, index int) ([]byte, error)
+	// LINSERT inserts element before pivot for ListLeft and after it for ListRight.
+	// Returns -1 if pivot is not found and 0 if the key doesn't exist.
+	LINSERT(key []byte, side ListSide, pivot, element []byte) (int, error)
+	LLEN(key []byte) (int, error)
+	LMOVE(src, dst []byte, from, to ListSide) ([]byte, error)
+	LPOP(key []byte, count int) ([][]byte, error)
+	LPOS(key, element []byte, opts LPosOptions) ([]int, error)
+	LPUSH(key []byte, elements ...[]byte) (int, error)
+	LPUSHX(key []byte, elements ...[]byte) (int, error)
+	LRANGE(key []byte, start, stop int) ([][]byte, error)
+	LREM(key []byte, count int, element []byte) (int, error)
+	LSET(key []byte, index int, element []byte) error
+	LTRIM(key []byte, start, stop int) error
+	RPOP(key []byte, count int) ([][]byte, error)
+	RPUSH(key []byte, elements ...[]byte) (int, error)
+	RPUSHX(key []byte, elements ...[]byte) (int, error)
+}
+
+type HashesStore interface {
+	HDEL(key []byte, fields ...[]byte) (int, error)
+	HEXISTS(key, field []byte) (bool, error)
+	// HEXPIRE sets expiry of the fields and returns a status per field, see [FieldMissing] and others.
+	HEXPIRE(key []byte, seconds int64, cond ExpireCond, fields ...[]byte) ([]int, error)
+	HEXPIREAT(key []byte, unixSeconds int64, cond ExpireCond, fields ...[]byte) ([]int, error)
+	HEXPIRETIME(key []byte, fields ...[]byte) ([]int64, error)
+	HGET(key, field []byte) ([]byte, error)
+	// HGETALL returns fields followed by their values, sorted by field.
+	HGETALL(key []byte) ([][]byte, error)
+	HINCRBY(key, field []byte, by int64) (int64, error)
+	HINCRBYFLOAT(key, field []byte, by float64) (string, error)
+	HKEYS(key []byte) ([][]byte, error)
+	HLEN(key []byte) (int, error)
+	// HMGET returns nil for missing fields.
+	HMGET(key []byte, fields ...[]byte) ([][]byte, error)
+	HPERSIST(key []byte, fields ...[]byte) ([]int, error)
+	HPEXPIRE(key []byte, milliseconds int64, cond ExpireCond, fields ...[]byte) ([]int, error)
+	HPEXPIREAT(key []byte, unixMilliseconds int64, cond ExpireCond, fields ...[]byte) ([]int, error)
+	HPEXPIRETIME(key []byte, fields ...[]byte) ([]int64, error)
+	HPTTL(key []byte, fields ...[]byte) ([]int64, error)
+	// HRANDFIELD picks fields like [RandIndexes] does, values follow fields if withValues is set.
+	HRANDFIELD(key []byte, count int, withValues bool) ([][]byte, error)
+	// HSCAN returns fields followed by their values, opts.Type is ignored.
+	HSCAN(key []byte, cursor uint64, opts ScanOptions) ([][]byte, uint64, error)
+	// HSET sets field-value pairs and returns the number of added fields, expiry of the fields is removed.
+	HSET(key []byte, fieldvals ...[]byte) (int, error)
+	HSETNX(key, field, value []byte) (bool, error)
+	HSTRLEN(key, field []byte) (int, error)
+	HTTL(key []byte, fields ...[]byte) ([]int64, error)
+	HVALS(key []byte) ([][]byte, error)
+}
+
+type SetsStore interface {
+	SADD(key []byte, members ...[]byte) (int, error)
+	SCARD(key []byte) (int, error)
+	SDIFF(keys ...[]byte) ([][]byte, error)
+	// SDIFFSTORE replaces dst with the result and returns its size, same for other STORE variants.
+	SDIFFSTORE(dst []byte, keys ...[]byte) (int, error)
+	SINTER(keys ...[]byte) ([][]byte, error)
+	// SINTERCARD returns the size of intersection, zero limit means no limit.
+	SINTERCARD(limit int, keys ...[]byte) (int, error)
+	SINTERSTORE(dst []byte, keys ...[]byte) (int, error)
+	SISMEMBER(key, member []byte) (bool, error)
+	// SMEMBERS returns members sorted, same for other commands returning a set.
+	SMEMBERS(key []byte) ([][]byte, error)
+	SMISMEMBER(key []byte, members ...[]byte) ([]bool, error)
+	SMOVE(src, dst, member []byte) (bool, error)
+	// SPOP removes and returns up to count random members.
+	SPOP(key []byte, count int) ([][]byte, error)
+	// SRANDMEMBER picks members like [RandIndexes] does.
+	SRANDMEMBER(key []byte, count int) ([][]byte, error)
+	SREM(key []byte, members ...[]byte) (int, error)
+	SSCAN(key []byte, cursor uint64, opts ScanOptions) ([][]byte, uint64, error)
+	SUNION(keys ...[]byte) ([][]byte, error)
+	SUNIONSTORE(dst []byte, keys ...[]byte) (int, error)
+}
+
+type SortedSetsStore interface {
+	// ZADD returns the number of added members, or changed ones with CH option.
+	ZADD(key []byte, opts ZAddOptions, members ...ZMember) (int, error)
+	ZCARD(key []byte) (int, error)
+	ZCOUNT(key []byte, min, max ScoreBound) (int, error)
+	// ZDIFF returns members sorted by score, same for ZINTER and ZUNION.
+	// Sets are accepted as sorted sets with all scores equal to 1 by all aggregation commands.
+	ZDIFF(keys ...[]byte) ([]ZMember, error)
+	// ZDIFFSTORE replaces dst with the result and returns its size, same for other STORE variants.
+	ZDIFFSTORE(dst []byte, keys ...[]byte) (int, error)
+	// ZINCRBY also implements ZADD with INCR option,
+	// false is returned if the score is not updated because of the options.
+	ZINCRBY(key []byte, by float64, member []byte, opts ZAddOptions) (float64, bool, error)
+	ZINTER(opts ZAggOptions, keys ...[]byte) ([]ZMember, error)
+	// ZINTERCARD returns the size of intersection, zero limit means no limit.
+	ZINTERCARD(limit int, keys ...[]byte) (int, error)
+	ZINTERSTORE(dst []byte, opts ZAggOptions, keys ...[]byte) (int, error)
+	ZLEXCOUNT(key []byte, min, max LexBound) (int, error)
+	// ZMSCORE returns nil for missing members.
+	ZMSCORE(key []byte, members ...[]byte) ([]*float64, error)
+	// ZPOPMAX removes and returns up to count members with the highest scores.
+	ZPOPMAX(key []byte, count int) ([]ZMember, error)
+	// ZPOPMIN removes and returns up to count members with the lowest scores.
+	ZPOPMIN(key []byte, count int) ([]ZMember, error)
+	ZRANGE(key []byte, r ZRange) ([]ZMember, error)
+	// ZRANGESTORE replaces dst with the range and returns its size.
+	ZRANGESTORE(dst, src []byte, r ZRange) (int, error)
+	// ZRANK returns the rank of the member and its score, false if the member is missing.
+	ZRANK(key, member []byte) (int, float64, bool, error)
+	ZREM(key []byte, members ...[]byte) (int, error)
+	ZREMRANGEBYLEX(key []byte, min, max LexBound) (int, error)
+	ZREMRANGEBYRANK(key []byte, start, stop int) (int, error)
+	ZREMRANGEBYSCORE(key []byte, min, max ScoreBound) (int, error)
+	ZREVRANK(key, member []byte) (int, float64, bool, error)
+	ZSCORE(key, member []byte) (float64, bool, error)
+	ZUNION(opts ZAggOptions, keys ...[]byte) ([]ZMember, error)
+	ZUNIONSTORE(dst []byte, opts ZAggOptions, keys ...[]byte) (int, error)
+}
+
+type StreamsStore interface {
+	XACK(key, group []byte, ids ...StreamID) (int, error)
+	// XADD appends an entry and returns its ID,
+	// false is returned if the stream doesn't exist and opts.NoMkStream is set.
+	XADD(key []byte, opts XAddOptions, fieldvals ...[]byte) (StreamID, bool, error)
+	// XAUTOCLAIM returns the ID to continue from, claimed entries and IDs of deleted entries removed from PEL.
+	XAUTOCLAIM(key, group, consumer []byte, minIdle int64, start StreamID, count int, justID bool) (StreamID, []StreamEntry, []StreamID, error)
+	// XCLAIM returns claimed entries, deleted entries are removed from PEL.
+	// Groups commands fail with ErrKeyNotFound for a missing stream and ErrNoGroup for a missing group.
+	XCLAIM(key, group, consumer []byte, minIdle int64, ids []StreamID, opts XClaimOptions) ([]StreamEntry, error)
+	XDEL(key []byte, ids ...StreamID) (int, error)
+	// XGROUPCREATE creates a missing stream only if mkStream is set.
+	XGROUPCREATE(key, group []byte, opts XGroupIDOptions, mkStream bool) error
+	XGROUPCREATECONSUMER(key, group, consumer []byte) (bool, error)
+	// XGROUPDELCONSUMER returns the number of pending entries the consumer had.
+	XGROUPDELCONSUMER(key, group, consumer []byte) (int, error)
+	XGROUPDESTROY(key, group []byte) (bool, error)
+	XGROUPSETID(key, group []byte, opts XGroupIDOptions) error
+	XINFOCONSUMERS(key, group []byte) ([]StreamConsumerInfo, error)
+	// XINFOGROUPS returns groups sorted by name.
+	XINFOGROUPS(key []byte) ([]StreamGroupInfo, error)
+	XINFOSTREAM(key []byte) (StreamInfo, error)
+	XLEN(key []byte) (int, error)
+	XPENDING(key, group []byte) (StreamPendingSummary, error)
+	XPENDINGRANGE(key, group []byte, opts XPendingOptions) ([]StreamPendingEntry, error)
+	// XRANGE returns entries with IDs between start and end (both inclusive),
+	// at most count of them if it's positive.
+	XRANGE(key []byte, start, end StreamID, count int) ([]StreamEntry, error)
+	// XREAD returns entries with IDs greater than the given ones, at most count per stream if it's positive.
+	// Streams without such entries are omitted.
+	XREAD(keys [][]byte, ids []StreamID, count int) ([]StreamEntries, error)
+	// XREADGROUP reads new entries for nil IDs (`>`) and pending entries of the consumer after the ID otherwise.
+	// Pending entries deleted from the stream have nil fields, streams read for pending entries are never omitted.
+	XREADGROUP(group, consumer []byte, keys [][]byte, ids []*StreamID, count int, noAck bool) ([]StreamEntries, error)
+	// XREVRANGE is like XRANGE but returns entries in reverse order.
+	XREVRANGE(key []byte, end, start StreamID, count int) ([]StreamEntry, error)
+	// XTRIM returns the number of evicted entries.
+	XTRIM(key []byte, opts XTrimOptions) (int, error)
+}
+
+// BitmapsStore operates on strings as arrays of bits, bits beyond the value are zeros.
+// Writes grow the value with zeros as needed.
+type BitmapsStore interface {
+	// BITCOUNT returns the number of set bits in the range.
+	BITCOUNT(key []byte, rng BitRange) (int64, error)
+	// BITFIELD returns a reply per op, nil for writes that failed with FAIL overflow.
+	// The value is grown to fit every write, even a failed one, like in Redis.
+	BITFIELD(key []byte, ops []BitFieldOp) ([]*int64, error)
+	// BITFIELDRO is BITFIELD with only GET ops.
+	BITFIELDRO(key []byte, ops []BitFieldOp) ([]int64, error)
+	// BITOP stores the result of the operation over keys in dst and returns its length,
+	// dst is deleted if the result is empty.
+	BITOP(op BitOp, dst []byte, keys ...[]byte) (int, error)
+	// BITPOS returns the offset of the first bit set to bit in the range, -1 if there is none.
+	// A clear bit right after the value is reported if hasEnd isn't set, like in Redis.
+	BITPOS(key []byte, bit int, rng BitRange, hasEnd bool) (int64, error)
+	GETBIT(key []byte, offset int64) (int, error)
+	// SETBIT returns the old value of the bit.
+	SETBIT(key []byte, offset int64, bit int) (int, error)
+}
+
+// HyperLogLogStore operates on HyperLogLogs, they are strings in the Redis encoding.
+// Strings that are not HyperLogLogs fail with ErrHLLWrongType.
+type HyperLogLogStore interface {
+	// PFADD reports whether the HyperLogLog was created or any of its registers was updated.
+	PFADD(key []byte, elements ...[]byte) (bool, error)
+	// PFCOUNT returns the cardinality of the union of HyperLogLogs, missing keys are empty ones.
+	// The cached cardinality is updated in the value for a single key, like in Redis.
+	PFCOUNT(keys ...[]byte) (int64, error)
+	// PFMERGE stores the union of dst and keys in dst.
+	PFMERGE(dst []byte, keys ...[]byte) error
+}
+
+// GeoStore searches geo indexes, they are sorted sets scored by geohashes.
+// Other geo commands are implemented with sorted set ones.
+type GeoStore interface {
+	// GEOSEARCH returns members found by the search, the result is empty for a missing key.
+	// ErrGeoMemberNotFound is returned if the center member is missing.
+	GEOSEARCH(key []byte, q GeoSearch) ([]GeoPoint, error)
+	// GEOSEARCHSTORE replaces dst with members found by the search and returns their number,
+	// scores are distances if storeDist is set and geohashes otherwise.
+	GEOSEARCHSTORE(dst, src []byte, q GeoSearch, storeDist bool) (int, error)
+}
+
+// JSONStore operates on JSON documents, values and patches are in the binary encoding of ParseJSON.
+// Write commands fail with ErrJSONNoKey for a missing key unless they can create it,
+// read commands return ErrKeyNotFound. Results of a legacy path have a single entry.
+type JSONStore interface {
+	// JSONARRAPPEND returns new lengths of arrays, nil for values that are not arrays.
+	JSONARRAPPEND(key []byte, path JSONPath, values ...[]byte) ([]*int64, error)
+	// JSONARRINSERT returns new lengths of arrays, nil for values that are not arrays.
+	JSONARRINSERT(key []byte, path JSONPath, index int64, values ...[]byte) ([]*int64, error)
+	// JSONARRPOP returns removed elements as JSON, nil for values that are not arrays or are empty.
+	JSONARRPOP(key []byte, path JSONPath, index int64) ([][]byte, error)
+	// JSONDEL returns the number of removed values, the root removes the key.
+	JSONDEL(key []byte, path JSONPath) (int, error)
+	// JSONGET returns values at paths as JSON like JSONGet does.
+	JSONGET(key []byte, f JSONFormat, paths ...JSONPath) ([]byte, error)
+	JSONMERGE(key []byte, path JSONPath, patch []byte) error
+	// JSONMGET returns values at the path as JSON, nil for missing keys, values and keys of other types.
+	JSONMGET(path JSONPath, keys ...[]byte) ([][]byte, error)
+	// JSONNUMINCRBY returns the reply of JSON.NUMINCRBY like JSONNumIncrBy does.
+	JSONNUMINCRBY(key []byte, path JSONPath, by []byte) ([]byte, error)
+	// JSONOBJKEYS returns keys of objects, nil for values that are not objects.
+	JSONOBJKEYS(key []byte, path JSONPath) ([][][]byte, error)
+	// JSONSET reports whether the value was set.
+	JSONSET(key []byte, path JSONPath, value []byte, opts JSONSetOptions) (bool, error)
+	// JSONSTRAPPEND returns new lengths of strings, nil for values that are not strings.
+	JSONSTRAPPEND(key []byte, path JSONPath, value []byte) ([]*int64, error)
+	// JSONTYPE returns types of values.
+	JSONTYPE(key []byte, path JSONPath) ([]string, error)
+}
+
+// BloomStore operates on scalable Bloom filters, BF.ADD and BF.EXISTS are BFMADD and BFMEXISTS of a single item.
+type BloomStore interface {
+	// BFINFO returns ErrFilterNotFound for a missing key.
+	BFINFO(key []byte) (BloomInfo, error)
+	// BFMADD reports for items whether they were added, a missing filter is created with default options.
+	// Results of items added before ErrBloomFull are returned with it.
+	BFMADD(key []byte, items ...[]byte) ([]bool, error)
+	// BFMEXISTS reports for items whether they may have been added, a missing filter has none.
+	BFMEXISTS(key []byte, items ...[]byte) ([]bool, error)
+	// BFRESERVE creates an empty filter, ErrFilterExists is returned if the key exists.
+	BFRESERVE(key []byte, opts BloomOptions) error
+}
+
+// CuckooStore operates on scalable Cuckoo filters, unlike Bloom filters they allow deletes and counting.
+type CuckooStore interface {
+	// CFADD adds the item, a missing filter is created with the default capacity.
+	CFADD(key, item []byte) error
+	// CFADDNX adds the item only if it may not have been added and reports whether it was added.
+	CFADDNX(key, item []byte) (bool, error)
+	// CFCOUNT returns how many times the item may have been added.
+	CFCOUNT(key, item []byte) (int64, error)
+	// CFDEL removes a single copy of the item, ErrCuckooNotFound is returned for a missing key.
+	CFDEL(key, item []byte) (bool, error)
+	// CFEXISTS reports whether the item may have been added.
+	CFEXISTS(key, item []byte) (bool, error)
+}
+
+// CountMinSketchStore operates on Count-Min sketches, commands fail with ErrCMSNotFound for a missing key
+// unless they create it.
+type CountMinSketchStore interface {
+	// CMSINCRBY increments counts of items by incrs and returns their new counts.
+	CMSINCRBY(key []byte, items [][]byte, incrs []int64) ([]int64, error)
+	// CMSINIT creates an empty sketch, ErrCMSExists is returned if the key exists.
+	CMSINIT(key []byte, width, depth int64) error
+	// CMSMERGE replaces counters of dst with sums of counters of keys multiplied by weights,
+	// all sketches must have the same dimensions.
+	CMSMERGE(dst []byte, keys [][]byte, weights []int64) error
+	// CMSQUERY returns counts of items, they are never less than the real ones.
+	CMSQUERY(key []byte, items ...[]byte) ([]int64, error)
+}
+
+// TopKStore operates on Top-K lists, commands fail with ErrTopKNotFound for a missing key
+// unless they create it. TOPK.ADD is TOPKINCRBY by one.
+type TopKStore interface {
+	// TOPKINCRBY increments counts of items by incrs and returns items expelled from the top, nil for none.
+	TOPKINCRBY(key []byte, items [][]byte, incrs []int64) ([][]byte, error)
+	TOPKINFO(key []byte) (TopKOptions, error)
+	// TOPKLIST returns items of the top by count in descending order.
+	TOPKLIST(key []byte) ([]TopKEntry, error)
+	// TOPKQUERY reports for items whether they are in the top.
+	TOPKQUERY(key []byte, items ...[]byte) ([]bool, error)
+	// TOPKRESERVE creates an empty top, ErrTopKExists is returned if the key exists.
+	TOPKRESERVE(key []byte, opts TopKOptions) error
+}
+
+// TimeSeriesStore operates on time series, commands fail with ErrTSNotFound for a missing key
+// unless they create it.
+type TimeSeriesStore interface {
+	// TSADD adds a sample and returns its timestamp, the series is created with opts if it does not exist.
+	// Samples produced by compaction rules are written to their destinations.
+	TSADD(key []byte, sample TSSample, opts TSAddOptions) (int64, error)
+	// TSCREATE creates an empty series, ErrTSExists is returned if the key exists.
+	TSCREATE(key []byte, opts TSOptions) error
+	// TSCREATERULE adds a rule compacting samples of src added from now on into dst.
+	TSCREATERULE(src, dst []byte, agg TSAggregation) error
+	// TSDELETERULE deletes the rule of src for dst, ErrTSRuleNotFound is returned if there is none.
+	TSDELETERULE(src, dst []byte) error
+	// TSMRANGE returns ranges of series matching all filters ordered by key,
+	// at least one filter must be positive.
+	TSMRANGE(filters []TSFilter, opts TSRangeOptions) ([]TSRange, error)
+	// TSQUERYINDEX returns keys of series matching all filters in order,
+	// at least one filter must be positive.
+	TSQUERYINDEX(filters []TSFilter) ([][]byte, error)
+	TSRANGE(key []byte, opts TSRangeOptions) ([]TSSample, error)
 }
@@ -0,0 +1,355 @@
+package core
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrStreamIDInvalid   = NewError(PrefixErr, "Invalid stream ID specified as stream command argument")
+	ErrStreamIDZero      = NewError(PrefixErr, "The ID specified in XADD must be greater than 0-0")
+	ErrStreamIDTooSmall  = NewError(PrefixErr, "The ID specified in XADD is equal or smaller than the target stream top item")
+	ErrStreamIDExhausted = NewError(PrefixErr, "The stream has exhausted the last possible ID, unable to add more items")
+	ErrNoGroup           = NewError(PrefixNoGroup, "No such consumer group")
+	ErrBusyGroup         = NewError(PrefixBusyGroup, "Consumer Group name already exists")
+)
+
+// StreamNodeEntries is the number of entries Redis keeps in a stream node (stream-node-max-entries).
+// Approximate trimming removes entries only in batches of this size, like Redis removes whole nodes.
+const StreamNodeEntries = 100
+
+// StreamID is an ID of a stream entry: unix time in milliseconds and a sequence number.
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// MaxStreamID is the greatest possible ID, it's `+` in ranges.
+var MaxStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+// ParseStreamID parses `ms-seq` or `ms`, in the latter case seq is set to missingSeq.
+func ParseStreamID(s string, missingSeq uint64) (StreamID, error) {
+	msStr, seqStr, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msStr, 10, 64)
+	if err != nil {
+		return StreamID{}, ErrStreamIDInvalid
+	}
+	if !hasSeq {
+		return StreamID{Ms: ms, Seq: missingSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return StreamID{}, ErrStreamIDInvalid
+	}
+	return StreamID{Ms: ms, Seq: seq}, nil
+}
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Compare returns -1, 0 or 1 when id is less, equal or greater than other.
+func (id StreamID) Compare(other StreamID) int {
+	switch {
+	case id.Ms < other.Ms:
+		return -1
+	case id.Ms > other.Ms:
+		return 1
+	case id.Seq < other.Seq:
+		return -1
+	case id.Seq > other.Seq:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Next returns the smallest ID greater than id, reports false if id is the greatest one.
+func (id StreamID) Next() (StreamID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return StreamID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return StreamID{Ms: id.Ms + 1}, true
+	default:
+		return id, false
+	}
+}
+
+// Prev returns the greatest ID less than id, reports false if id is 0-0.
+func (id StreamID) Prev() (StreamID, bool) {
+	switch {
+	case id.Seq > 0:
+		return StreamID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	case id.Ms > 0:
+		return StreamID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	default:
+		return id, false
+	}
+}
+
+// StreamEntry is an entry of a stream.
+type StreamEntry struct {
+	ID StreamID
+	// Fields are field-value pairs in the order they were added.
+	Fields [][]byte
+}
+
+// StreamEntries are entries read from the stream at key.
+type StreamEntries struct {
+	Key     []byte
+	Entries []StreamEntry
+}
+
+// XAddOptions are options for XADD command.
+type XAddOptions struct {
+	// ID is the ID of the new entry unless AutoID or AutoSeq is set.
+	ID StreamID
+	// AutoID generates the ID from the current time (`*`).
+	AutoID bool
+	// AutoSeq generates the sequence number for ID.Ms (`ms-*`).
+	AutoSeq bool
+	// NoMkStream doesn't create a missing stream.
+	NoMkStream bool
+	// Trim is applied after the entry is added.
+	Trim XTrimOptions
+}
+
+// NextID returns ID of a new entry for a stream with the given last ID.
+func (o XAddOptions) NextID(last StreamID, nowMs int64) (StreamID, error) {
+	var id StreamID
+	switch {
+	case o.AutoID:
+		if now := uint64(max(nowMs, 0)); now > last.Ms {
+			return StreamID{Ms: now}, nil
+		}
+		next, ok := last.Next()
+		if !ok {
+			return StreamID{}, ErrStreamIDExhausted
+		}
+		return next, nil
+	case o.AutoSeq:
+		id = StreamID{Ms: o.ID.Ms}
+		if o.ID.Ms == last.Ms {
+			if last.Seq == math.MaxUint64 {
+				return StreamID{}, ErrStreamIDTooSmall
+			}
+			id.Seq = last.Seq + 1
+		}
+	default:
+		id = o.ID
+		if id == (StreamID{}) {
+			return StreamID{}, ErrStreamIDZero
+		}
+	}
+	if id.Compare(last) <= 0 {
+		return StreamID{}, ErrStreamIDTooSmall
+	}
+	return id, nil
+}
+
+// XTrimStrategy is a way to select stream entries to evict.
+type XTrimStrategy int
+
+const (
+	// XTrimNone doesn't trim the stream.
+	XTrimNone XTrimStrategy = iota
+	// XTrimMaxLen evicts the oldest entries until the stream length is MaxLen.
+	XTrimMaxLen
+	// XTrimMinID evicts entries with IDs lower than MinID.
+	XTrimMinID
+)
+
+// XTrimOptions are options for XTRIM command and trimming in XADD.
+type XTrimOptions struct {
+	Strategy XTrimStrategy
+	MaxLen   int
+	MinID    StreamID
+	// Approx evicts entries only in batches of [StreamNodeEntries] (`~`),
+	// so the stream might keep a bit more entries than requested.
+	Approx bool
+	// Limit is the maximum number of entries to evict, zero means no limit.
+	Limit int
+}
+
+// Evict returns how many of the oldest entries to evict, given the stream length
+// and the number of entries with IDs lower than MinID (for XTrimMinID only).
+func (o XTrimOptions) Evict(n, belowMinID int) int {
+	var res int
+	switch o.Strategy {
+	case XTrimMaxLen:
+		res = max(n-o.MaxLen, 0)
+	case XTrimMinID:
+		res = belowMinID
+	}
+	if o.Limit > 0 {
+		res = min(res, o.Limit)
+	}
+	if o.Approx {
+		res -= res % StreamNodeEntries
+	}
+	return res
+}
+
+// StreamCounters are counters of a stream, they are used to track progress of consumer groups like Redis does.
+type StreamCounters struct {
+	Len int
+	// FirstID is the ID of the first entry, zero for an empty stream.
+	FirstID StreamID
+	LastID  StreamID
+	// MaxDeletedID is the greatest ID of an entry deleted by XDEL.
+	MaxDeletedID StreamID
+	// EntriesAdded is the number of entries ever added to the stream.
+	EntriesAdded int64
+}
+
+// hasTombstones reports whether entries after id might be deleted.
+func (c StreamCounters) hasTombstones(id StreamID) bool {
+	if c.Len == 0 || c.MaxDeletedID == (StreamID{}) {
+		return false
+	}
+	return id.Compare(c.MaxDeletedID) <= 0
+}
+
+// estimateEntriesRead returns the number of entries up to id since the first ever one, -1 if unknown.
+func (c StreamCounters) estimateEntriesRead(id StreamID) int64 {
+	if c.EntriesAdded == 0 {
+		return 0
+	}
+	cmpLast := id.Compare(c.LastID)
+	switch {
+	case c.Len == 0 && cmpLast <= 0, cmpLast == 0:
+		return c.EntriesAdded
+	case cmpLast > 0:
+		return -1
+	}
+
+	// if nothing after the first entry was deleted, the number of entries before it is known.
+	if c.MaxDeletedID == (StreamID{}) || c.MaxDeletedID.Compare(c.FirstID) < 0 {
+		switch id.Compare(c.FirstID) {
+		case -1:
+			return c.EntriesAdded - int64(c.Len)
+		case 0:
+			return c.EntriesAdded - int64(c.Len) + 1
+		}
+	}
+	return -1
+}
+
+// NextEntriesRead returns entries read counter of a group after it's got the entry with id, -1 if unknown.
+func (c StreamCounters) NextEntriesRead(entriesRead int64, id StreamID) int64 {
+	switch {
+	case entriesRead >= 0 && !c.hasTombstones(id):
+		return entriesRead + 1
+	case c.EntriesAdded > 0:
+		return c.estimateEntriesRead(id)
+	default:
+		return entriesRead
+	}
+}
+
+// Lag returns the number of entries not yet delivered to a group, -1 if unknown.
+func (c StreamCounters) Lag(lastID StreamID, entriesRead int64) int64 {
+	if c.EntriesAdded == 0 {
+		return 0
+	}
+	if entriesRead < 0 || c.hasTombstones(lastID) {
+		entriesRead = c.estimateEntriesRead(lastID)
+		if entriesRead < 0 {
+			return -1
+		}
+	}
+	return c.EntriesAdded - entriesRead
+}
+
+// StreamInfo is a stream state like XINFO STREAM replies.
+type StreamInfo struct {
+	StreamCounters
+	Groups int
+	// FirstEntry and LastEntry are nil for an empty stream.
+	FirstEntry *StreamEntry
+	LastEntry  *StreamEntry
+}
+
+// StreamGroupInfo is a consumer group state like XINFO GROUPS replies.
+type StreamGroupInfo struct {
+	Name            []byte
+	Consumers       int
+	Pending         int
+	LastDeliveredID StreamID
+	// EntriesRead is -1 if unknown, same for Lag.
+	EntriesRead int64
+	Lag         int64
+}
+
+// StreamConsumerInfo is a consumer state like XINFO CONSUMERS replies.
+type StreamConsumerInfo struct {
+	Name    []byte
+	Pending int
+	// Idle is milliseconds since the last attempted interaction.
+	Idle int64
+	// Inactive is milliseconds since the last successful interaction, -1 if never.
+	Inactive int64
+}
+
+// StreamPendingEntry is an entry delivered to a consumer but not acknowledged yet.
+type StreamPendingEntry struct {
+	ID       StreamID
+	Consumer []byte
+	// Idle is milliseconds since the entry was delivered last time.
+	Idle       int64
+	Deliveries int64
+}
+
+// StreamPendingSummary is a summary of pending entries of a group like XPENDING replies.
+type StreamPendingSummary struct {
+	Count int
+	// First and Last are the lowest and the greatest pending IDs.
+	First StreamID
+	Last  StreamID
+	// Consumers are consumers with pending entries sorted by name.
+	Consumers []StreamConsumerPending
+}
+
+// StreamConsumerPending is the number of pending entries of a consumer.
+type StreamConsumerPending struct {
+	Name  []byte
+	Count int
+}
+
+// XGroupIDOptions are options for XGROUP CREATE and XGROUP SETID commands.
+type XGroupIDOptions struct {
+	// ID is the last delivered ID of the group unless LastID is set.
+	ID StreamID
+	// LastID uses the last ID of the stream (`$`).
+	LastID bool
+	// EntriesRead is the number of entries read by the group, -1 if unknown.
+	EntriesRead int64
+}
+
+// XPendingOptions are options for extended form of XPENDING command.
+type XPendingOptions struct {
+	// MinIdle filters entries idle for at least this number of milliseconds.
+	MinIdle int64
+	// Start and End are inclusive bounds of IDs.
+	Start StreamID
+	End   StreamID
+	Count int
+	// Consumer filters entries by consumer if set.
+	Consumer []byte
+}
+
+// XClaimOptions are options for XCLAIM command.
+type XClaimOptions struct {
+	// DeliveryTime is unix time in milliseconds of the last delivery, zero means now.
+	DeliveryTime int64
+	// RetryCount is the new delivery count, if it's negative the count is incremented (unless JustID is set).
+	RetryCount int64
+	// Force creates pending entries for entries that are not pending yet.
+	Force bool
+	// JustID returns only IDs and doesn't increment delivery count.
+	JustID bool
+	// LastID is set as the last delivered ID of the group if it's greater.
+	LastID StreamID
+}
@@ -0,0 +1,48 @@
+package core
+
+// Substr returns a part of the value between start and end (both inclusive).
+// Negative offsets are counted from the end of the value, like in GETRANGE.
+func Substr(val []byte, start, end int) []byte {
+	from, to := SubstrRange(len(val), start, end)
+	if from == to {
+		return []byte{}
+	}
+	return val[from:to]
+}
+
+// SubstrRange is like Substr but returns bounds of the part in a value of n bytes,
+// so it can be read without loading the whole value.
+func SubstrRange(n, start, end int) (from, to int) {
+	if start < 0 && end < 0 && start > end {
+		return 0, 0
+	}
+	if start < 0 {
+		start += n
+	}
+	if end < 0 {
+		end += n
+	}
+	start, end = max(start, 0), max(end, 0)
+	end = min(end, n-1)
+
+	if start > end || n == 0 {
+		return 0, 0
+	}
+	return start, end + 1
+}
+
+// SetRange overwrites part of the value starting at offset, padding it with zeros if needed.
+func SetRange(val []byte, offset int, value []byte) ([]byte, error) {
+	if len(value) == 0 {
+		return val, nil
+	}
+	size := offset + len(value)
+	if size > MaxStringSize {
+		return nil, ErrStringTooLong
+	}
+	if size > len(val) {
+		val = append(val, make([]byte, size-len(val))...)
+	}
+	copy(val[offset:], value)
+	return val, nil
+}
@@ -0,0 +1,851 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Time series https://redis.io/docs/data-types/timeseries/
+
+var (
+	ErrTSExists         = NewError(PrefixErr, "TSDB: key already exists")
+	ErrTSNotFound       = NewError(PrefixErr, "TSDB: the key does not exist")
+	ErrTSDuplicate      = NewError(PrefixErr, "TSDB: Error at upsert, update is not supported when DUPLICATE_POLICY is set to BLOCK mode")
+	ErrTSTooOld         = NewError(PrefixErr, "TSDB: Timestamp is older than retention")
+	ErrTSSameKey        = NewError(PrefixErr, "TSDB: the source key and destination key should be different")
+	ErrTSSrcHasSrc      = NewError(PrefixErr, "TSDB: the source key already has a source rule")
+	ErrTSDstHasSrc      = NewError(PrefixErr, "TSDB: the destination key already has a src rule")
+	ErrTSDstHasRules    = NewError(PrefixErr, "TSDB: the destination key already has a dst rule")
+	ErrTSRuleNotFound   = NewError(PrefixErr, "TSDB: compaction rule does not exist")
+	ErrTSInvalidFilter  = NewError(PrefixErr, "TSDB: failed parsing labels")
+	ErrTSNoMatcher      = NewError(PrefixErr, "TSDB: please provide at least one matcher")
+	ErrTSUnknownAgg     = NewError(PrefixErr, "TSDB: Unknown aggregation type")
+	ErrTSUnknownPolicy  = NewError(PrefixErr, "TSDB: Unknown DUPLICATE_POLICY")
+	ErrTSBucketDuration = NewError(PrefixErr, "TSDB: bucketDuration must be greater than zero")
+
+	errCorruptedTS = errors.New("corrupted time series")
+)
+
+// TSSample is a sample of a time series, timestamp is unix time in milliseconds.
+type TSSample struct {
+	Timestamp int64
+	Value     float64
+}
+
+// TSLabel is a label of a time series.
+type TSLabel struct {
+	Name  []byte
+	Value []byte
+}
+
+// TSDuplicatePolicy tells what to do with a sample for a timestamp that already has one.
+type TSDuplicatePolicy byte
+
+const (
+	// TSDuplicateDefault is the policy of the series for TS.ADD and BLOCK for a series.
+	TSDuplicateDefault TSDuplicatePolicy = iota
+	TSDuplicateBlock
+	TSDuplicateFirst
+	TSDuplicateLast
+	TSDuplicateMin
+	TSDuplicateMax
+	TSDuplicateSum
+)
+
+var tsDuplicatePolicies = []string{"", "block", "first", "last", "min", "max", "sum"}
+
+// ParseTSDuplicatePolicy parses a policy name case-insensitively.
+func ParseTSDuplicatePolicy(name []byte) (TSDuplicatePolicy, error) {
+	for i, s := range tsDuplicatePolicies[1:] {
+		if strings.EqualFold(string(name), s) {
+			return TSDuplicatePolicy(i + 1), nil
+		}
+	}
+	return 0, ErrTSUnknownPolicy
+}
+
+func (p TSDuplicatePolicy) String() string {
+	if p == TSDuplicateDefault || int(p) >= len(tsDuplicatePolicies) {
+		return "block"
+	}
+	return tsDuplicatePolicies[p]
+}
+
+// apply returns the value to keep for a timestamp that has the value old.
+func (p TSDuplicatePolicy) apply(old, value float64) (float64, error) {
+	switch p {
+	case TSDuplicateFirst:
+		return old, nil
+	case TSDuplicateLast:
+		return value, nil
+	case TSDuplicateMin:
+		return min(old, value), nil
+	case TSDuplicateMax:
+		return max(old, value), nil
+	case TSDuplicateSum:
+		return old + value, nil
+	default:
+		return 0, ErrTSDuplicate
+	}
+}
+
+// TSAggregationType is an aggregation of samples of a bucket.
+type TSAggregationType byte
+
+const (
+	TSAggAvg TSAggregationType = iota
+	TSAggSum
+	TSAggMin
+	TSAggMax
+	TSAggRange
+	TSAggCount
+	TSAggFirst
+	TSAggLast
+	TSAggStdP
+	TSAggStdS
+	TSAggVarP
+	TSAggVarS
+)
+
+var tsAggregations = []string{"avg", "sum", "min", "max", "range", "count", "first", "last", "std.p", "std.s", "var.p", "var.s"}
+
+// ParseTSAggregationType parses an aggregation name case-insensitively.
+func ParseTSAggregationType(name []byte) (TSAggregationType, error) {
+	for i, s := range tsAggregations {
+		if strings.EqualFold(string(name), s) {
+			return TSAggregationType(i), nil
+		}
+	}
+	return 0, ErrTSUnknownAgg
+}
+
+func (t TSAggregationType) String() string {
+	if int(t) >= len(tsAggregations) {
+		return "unknown"
+	}
+	return tsAggregations[t]
+}
+
+// TSBucketTimestamp is a timestamp reported for an aggregated bucket.
+type TSBucketTimestamp byte
+
+const (
+	// TSBucketLow is the start of the bucket.
+	TSBucketLow TSBucketTimestamp = iota
+	// TSBucketHigh is the end of the bucket.
+	TSBucketHigh
+	// TSBucketMid is the middle of the bucket.
+	TSBucketMid
+)
+
+// TSAggregation aggregates samples by buckets of BucketDuration milliseconds,
+// buckets start at Align plus a multiple of the duration.
+type TSAggregation struct {
+	Type           TSAggregationType
+	BucketDuration int64
+	Align          int64
+	// BucketTimestamp is the timestamp reported for buckets.
+	BucketTimestamp TSBucketTimestamp
+	// Empty also reports empty buckets between the first and the last ones.
+	Empty bool
+}
+
+// bucketStart returns the start of the bucket of ts.
+func (a TSAggregation) bucketStart(ts int64) int64 {
+	off := (ts - a.Align) % a.BucketDuration
+	if off < 0 {
+		off += a.BucketDuration
+	}
+	return ts - off
+}
+
+// TSOptions are options of a new time series.
+type TSOptions struct {
+	// Retention is the maximum age of samples relative to the last one in milliseconds, zero keeps all.
+	Retention       int64
+	DuplicatePolicy TSDuplicatePolicy
+	Labels          []TSLabel
+}
+
+// TSAddOptions are options for TS.ADD command.
+type TSAddOptions struct {
+	// TSOptions are used if the series does not exist.
+	TSOptions
+	// OnDuplicate overrides the duplicate policy of the series.
+	OnDuplicate TSDuplicatePolicy
+}
+
+// TSRangeOptions are options for TS.RANGE and TS.MRANGE commands.
+type TSRangeOptions struct {
+	// From and To are inclusive.
+	From, To int64
+	// FilterByTS keeps only samples with these timestamps, nil keeps all.
+	FilterByTS []int64
+	// FilterByValue keeps only samples with values between MinValue and MaxValue inclusive.
+	FilterByValue      bool
+	MinValue, MaxValue float64
+	// Count limits the number of reported samples or buckets, zero is no limit.
+	Count int64
+	// Aggregation is applied if its bucket duration is set.
+	Aggregation TSAggregation
+}
+
+// TSRange is a range of samples of a series for TS.MRANGE command.
+type TSRange struct {
+	Key     []byte
+	Labels  []TSLabel
+	Samples []TSSample
+}
+
+// TSFilter matches labels of a series:
+//
+//	label=value       label equals value
+//	label!=value      label is missing or doesn't equal value
+//	label=            label is missing
+//	label!=           label exists
+//	label=(a,b)       label equals one of values
+//	label!=(a,b)      label is missing or equals none of values
+type TSFilter struct {
+	Label []byte
+	// Values to compare with, an empty value stands for a missing label.
+	Values [][]byte
+	Not    bool
+}
+
+// ParseTSFilter parses a filter expression.
+func ParseTSFilter(expr []byte) (TSFilter, error) {
+	i := bytes.IndexByte(expr, '=')
+	if i < 0 {
+		return TSFilter{}, ErrTSInvalidFilter
+	}
+	f := TSFilter{Label: expr[:i]}
+	if bytes.HasSuffix(f.Label, []byte("!")) {
+		f.Label, f.Not = f.Label[:len(f.Label)-1], true
+	}
+	if len(f.Label) == 0 {
+		return TSFilter{}, ErrTSInvalidFilter
+	}
+
+	value := expr[i+1:]
+	if len(value) >= 2 && value[0] == '(' && value[len(value)-1] == ')' {
+		f.Values = bytes.Split(value[1:len(value)-1], []byte(","))
+	} else {
+		f.Values = [][]byte{value}
+	}
+	return f, nil
+}
+
+// Positive reports whether only series having the label can match, so the label index can be used.
+func (f TSFilter) Positive() bool {
+	if f.Not {
+		return false
+	}
+	for _, v := range f.Values {
+		if len(v) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Match reports whether the labels match the filter.
+func (f TSFilter) Match(labels []TSLabel) bool {
+	var value []byte
+	for _, l := range labels {
+		if bytes.Equal(l.Name, f.Label) {
+			value = l.Value
+			break
+		}
+	}
+	found := slices.ContainsFunc(f.Values, func(v []byte) bool {
+		return bytes.Equal(v, value)
+	})
+	return found != f.Not
+}
+
+// MatchTSFilters reports whether the labels match all filters.
+func MatchTSFilters(filters []TSFilter, labels []TSLabel) bool {
+	for _, f := range filters {
+		if !f.Match(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// TSIndexFilter returns the first positive filter, ErrTSNoMatcher is returned if there is none.
+func TSIndexFilter(filters []TSFilter) (TSFilter, error) {
+	for _, f := range filters {
+		if f.Positive() {
+			return f, nil
+		}
+	}
+	return TSFilter{}, ErrTSNoMatcher
+}
+
+// TSStorage keeps samples of a series ordered by timestamp.
+type TSStorage interface {
+	Get(ts int64) (float64, bool, error)
+	Put(s TSSample) error
+	// Range calls fn for samples from from to to inclusive in order until fn returns false.
+	Range(from, to int64, fn func(s TSSample) bool) error
+	// DeleteBefore removes samples older than ts.
+	DeleteBefore(ts int64) error
+}
+
+// TSRule is a compaction rule, samples of a source series are aggregated into the destination one.
+type TSRule struct {
+	DstKey      []byte
+	Aggregation TSAggregation
+
+	// open tells whether there is a bucket still receiving samples.
+	open        bool
+	bucketStart int64
+	acc         tsAccumulator
+}
+
+// TSCompacted is a sample produced by a rule for its destination.
+type TSCompacted struct {
+	DstKey []byte
+	Sample TSSample
+}
+
+// TimeSeries is a header of a time series, samples are kept in TSStorage.
+//
+// Compaction rules are kept in the source series and the destination knows its source,
+// a rule is live only while both sides agree, so deleted or replaced series don't need
+// to be unlinked from each other.
+type TimeSeries struct {
+	opts TSOptions
+	// last is the latest timestamp, valid if hasSamples.
+	last       int64
+	hasSamples bool
+	srcKey     []byte
+	rules      []TSRule
+}
+
+// NewTimeSeries returns an empty series.
+func NewTimeSeries(opts TSOptions) *TimeSeries {
+	if opts.DuplicatePolicy == TSDuplicateDefault {
+		opts.DuplicatePolicy = TSDuplicateBlock
+	}
+	labels := make([]TSLabel, len(opts.Labels))
+	for i, l := range opts.Labels {
+		labels[i] = TSLabel{Name: bytes.Clone(l.Name), Value: bytes.Clone(l.Value)}
+	}
+	opts.Labels = labels
+	return &TimeSeries{opts: opts}
+}
+
+// Labels returns labels of the series.
+func (ts *TimeSeries) Labels() []TSLabel {
+	return ts.opts.Labels
+}
+
+// Add adds a sample resolving duplicates by policy (or by the policy of the series for TSDuplicateDefault)
+// and returns samples its compaction rules produced.
+func (ts *TimeSeries) Add(st TSStorage, s TSSample, policy TSDuplicatePolicy) ([]TSCompacted, error) {
+	if policy == TSDuplicateDefault {
+		policy = ts.opts.DuplicatePolicy
+	}
+	if ts.tooOld(s.Timestamp) {
+		return nil, ErrTSTooOld
+	}
+	old, ok, err := st.Get(s.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if s.Value, err = policy.apply(old, s.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	appended := !ts.hasSamples || s.Timestamp > ts.last
+	if err := ts.put(st, s); err != nil {
+		return nil, err
+	}
+	return ts.compact(st, s, appended)
+}
+
+// Upsert writes a sample produced by a compaction rule, samples older than retention are dropped.
+func (ts *TimeSeries) Upsert(st TSStorage, s TSSample) error {
+	if ts.tooOld(s.Timestamp) {
+		return nil
+	}
+	return ts.put(st, s)
+}
+
+func (ts *TimeSeries) tooOld(timestamp int64) bool {
+	return ts.hasSamples && ts.opts.Retention > 0 && timestamp < ts.last-ts.opts.Retention
+}
+
+func (ts *TimeSeries) put(st TSStorage, s TSSample) error {
+	if err := st.Put(s); err != nil {
+		return err
+	}
+	if ts.hasSamples && s.Timestamp <= ts.last {
+		return nil
+	}
+	ts.last, ts.hasSamples = s.Timestamp, true
+	if ts.opts.Retention > 0 {
+		return st.DeleteBefore(ts.last - ts.opts.Retention)
+	}
+	return nil
+}
+
+// compact updates buckets of rules with the sample. A bucket is reported once a sample
+// of a later bucket arrives, changes to earlier buckets are recomputed from the source.
+func (ts *TimeSeries) compact(st TSStorage, s TSSample, appended bool) ([]TSCompacted, error) {
+	var res []TSCompacted
+	for i := range ts.rules {
+		r := &ts.rules[i]
+		start := r.Aggregation.bucketStart(s.Timestamp)
+
+		switch {
+		case !r.open:
+			r.open, r.bucketStart, r.acc = true, start, tsAccumulator{}
+			r.acc.add(s.Value)
+		case start > r.bucketStart:
+			res = append(res, TSCompacted{
+				DstKey: r.DstKey,
+				Sample: TSSample{Timestamp: r.bucketStart, Value: r.acc.value(r.Aggregation.Type)},
+			})
+			r.bucketStart, r.acc = start, tsAccumulator{}
+			r.acc.add(s.Value)
+		case start == r.bucketStart && appended:
+			r.acc.add(s.Value)
+		default:
+			var acc tsAccumulator
+			err := st.Range(start, start+r.Aggregation.BucketDuration-1, func(s TSSample) bool {
+				acc.add(s.Value)
+				return true
+			})
+			if err != nil {
+				return nil, err
+			}
+			if start == r.bucketStart {
+				r.acc = acc
+				continue
+			}
+			res = append(res, TSCompacted{
+				DstKey: r.DstKey,
+				Sample: TSSample{Timestamp: start, Value: acc.value(r.Aggregation.Type)},
+			})
+		}
+	}
+	return res, nil
+}
+
+// SrcKey returns the source of the series, nil if it's not a destination of a rule.
+func (ts *TimeSeries) SrcKey() []byte {
+	return ts.srcKey
+}
+
+// SetSrcKey sets the source of the series, nil unsets it.
+func (ts *TimeSeries) SetSrcKey(key []byte) {
+	ts.srcKey = bytes.Clone(key)
+}
+
+// HasRule reports whether the series has a rule for the destination.
+func (ts *TimeSeries) HasRule(dst []byte) bool {
+	return slices.ContainsFunc(ts.rules, func(r TSRule) bool {
+		return bytes.Equal(r.DstKey, dst)
+	})
+}
+
+// HasRules reports whether the series has any rules.
+func (ts *TimeSeries) HasRules() bool {
+	return len(ts.rules) > 0
+}
+
+// AddRule adds a rule for the destination, samples added before it are not compacted.
+func (ts *TimeSeries) AddRule(dst []byte, agg TSAggregation) {
+	ts.rules = append(ts.rules, TSRule{DstKey: bytes.Clone(dst), Aggregation: agg})
+}
+
+// DeleteRule deletes the rule for the destination and reports whether there was one.
+func (ts *TimeSeries) DeleteRule(dst []byte) bool {
+	n := len(ts.rules)
+	ts.rules = slices.DeleteFunc(ts.rules, func(r TSRule) bool {
+		return bytes.Equal(r.DstKey, dst)
+	})
+	return len(ts.rules) != n
+}
+
+// CheckTSRule checks that a rule of src for dst can be added, lookup returns a series or nil
+// for a missing key or a key of another type. A series is either a source or a destination of rules.
+func CheckTSRule(src, dst []byte, srcTS, dstTS *TimeSeries, lookup func(key []byte) (*TimeSeries, error)) error {
+	if bytes.Equal(src, dst) {
+		return ErrTSSameKey
+	}
+	linked := func(from, to []byte, toTS *TimeSeries) (bool, error) {
+		if toTS == nil || toTS.srcKey == nil || !bytes.Equal(toTS.srcKey, from) {
+			return false, nil
+		}
+		fromTS, err := lookup(from)
+		if err != nil || fromTS == nil {
+			return false, err
+		}
+		return fromTS.HasRule(to), nil
+	}
+
+	if srcTS.srcKey != nil {
+		ok, err := linked(srcTS.srcKey, src, srcTS)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return ErrTSSrcHasSrc
+		}
+	}
+	if dstTS.srcKey != nil {
+		ok, err := linked(dstTS.srcKey, dst, dstTS)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return ErrTSDstHasSrc
+		}
+	}
+	for _, r := range dstTS.rules {
+		ruleTS, err := lookup(r.DstKey)
+		if err != nil {
+			return err
+		}
+		if ruleTS != nil && bytes.Equal(ruleTS.srcKey, dst) {
+			return ErrTSDstHasRules
+		}
+	}
+	return nil
+}
+
+// Range returns samples of the series selected by opts, samples older than retention are skipped.
+func (ts *TimeSeries) Range(st TSStorage, opts TSRangeOptions) ([]TSSample, error) {
+	from := opts.From
+	if ts.hasSamples && ts.opts.Retention > 0 {
+		from = max(from, ts.last-ts.opts.Retention)
+	}
+	if from > opts.To {
+		return []TSSample{}, nil
+	}
+
+	var byTS map[int64]struct{}
+	if opts.FilterByTS != nil {
+		byTS = make(map[int64]struct{}, len(opts.FilterByTS))
+		for _, t := range opts.FilterByTS {
+			byTS[t] = struct{}{}
+		}
+	}
+
+	agg := opts.Aggregation
+	b := tsBuckets{agg: agg, from: from, count: opts.Count, res: []TSSample{}}
+	err := st.Range(from, opts.To, func(s TSSample) bool {
+		if byTS != nil {
+			if _, ok := byTS[s.Timestamp]; !ok {
+				return true
+			}
+		}
+		if opts.FilterByValue && (s.Value < opts.MinValue || s.Value > opts.MaxValue) {
+			return true
+		}
+		if agg.BucketDuration == 0 {
+			b.res = append(b.res, s)
+			return opts.Count == 0 || int64(len(b.res)) < opts.Count
+		}
+		return b.add(s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if agg.BucketDuration != 0 {
+		b.flush()
+	}
+	return b.res, nil
+}
+
+// tsBuckets aggregates ordered samples by buckets.
+type tsBuckets struct {
+	agg   TSAggregation
+	from  int64
+	count int64
+	res   []TSSample
+
+	open  bool
+	start int64
+	acc   tsAccumulator
+}
+
+// add adds a sample and reports whether more samples are needed.
+func (b *tsBuckets) add(s TSSample) bool {
+	start := b.agg.bucketStart(s.Timestamp)
+	if b.open && start != b.start {
+		b.flush()
+		if b.full() {
+			return false
+		}
+		if b.agg.Empty {
+			for t := b.start + b.agg.BucketDuration; t < start && !b.full(); t += b.agg.BucketDuration {
+				b.emit(t, tsAccumulator{})
+			}
+			if b.full() {
+				return false
+			}
+		}
+	}
+	if !b.open || start != b.start {
+		b.open, b.start, b.acc = true, start, tsAccumulator{}
+	}
+	b.acc.add(s.Value)
+	return true
+}
+
+// flush reports the open bucket.
+func (b *tsBuckets) flush() {
+	if b.open && !b.full() {
+		b.emit(b.start, b.acc)
+	}
+	b.open = false
+}
+
+func (b *tsBuckets) full() bool {
+	return b.count != 0 && int64(len(b.res)) >= b.count
+}
+
+func (b *tsBuckets) emit(start int64, acc tsAccumulator) {
+	ts := start
+	switch b.agg.BucketTimestamp {
+	case TSBucketHigh:
+		ts += b.agg.BucketDuration
+	case TSBucketMid:
+		ts += b.agg.BucketDuration / 2
+	}
+	// the first bucket may start before the range, like with ALIGN.
+	ts = max(ts, b.from)
+	b.res = append(b.res, TSSample{Timestamp: ts, Value: acc.value(b.agg.Type)})
+	// the start of an emitted bucket is kept to fill empty buckets after it.
+	b.start = start
+}
+
+// tsAccumulator keeps state of all aggregations of a bucket,
+// variance is computed with Welford's algorithm.
+type tsAccumulator struct {
+	count                      int64
+	sum, min, max, first, last float64
+	mean, m2                   float64
+}
+
+func (a *tsAccumulator) add(v float64) {
+	if a.count == 0 {
+		a.min, a.max, a.first = v, v, v
+	}
+	a.count++
+	a.sum += v
+	a.min, a.max, a.last = min(a.min, v), max(a.max, v), v
+
+	delta := v - a.mean
+	a.mean += delta / float64(a.count)
+	a.m2 += delta * (v - a.mean)
+}
+
+// value returns the aggregation, empty buckets are zero for sum and count and NaN otherwise.
+func (a *tsAccumulator) value(t TSAggregationType) float64 {
+	switch t {
+	case TSAggSum:
+		return a.sum
+	case TSAggCount:
+		return float64(a.count)
+	}
+	if a.count == 0 {
+		return math.NaN()
+	}
+	switch t {
+	case TSAggAvg:
+		return a.sum / float64(a.count)
+	case TSAggMin:
+		return a.min
+	case TSAggMax:
+		return a.max
+	case TSAggRange:
+		return a.max - a.min
+	case TSAggFirst:
+		return a.first
+	case TSAggLast:
+		return a.last
+	case TSAggStdP:
+		return math.Sqrt(a.m2 / float64(a.count))
+	case TSAggStdS:
+		return math.Sqrt(a.sampleVar())
+	case TSAggVarP:
+		return a.m2 / float64(a.count)
+	case TSAggVarS:
+		return a.sampleVar()
+	default:
+		return math.NaN()
+	}
+}
+
+func (a *tsAccumulator) sampleVar() float64 {
+	if a.count == 1 {
+		return 0
+	}
+	return a.m2 / float64(a.count-1)
+}
+
+// Clone returns a copy of the header.
+func (ts *TimeSeries) Clone() *TimeSeries {
+	res, err := DecodeTimeSeries(ts.Encode())
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// Encode returns the header of the series, samples are not included.
+func (ts *TimeSeries) Encode() []byte {
+	var e tsEncoder
+	e.u64(uint64(ts.opts.Retention))
+	e.b = append(e.b, byte(ts.opts.DuplicatePolicy))
+	e.bool(ts.hasSamples)
+	e.u64(uint64(ts.last))
+	e.u64(uint64(len(ts.opts.Labels)))
+	for _, l := range ts.opts.Labels {
+		e.bytes(l.Name)
+		e.bytes(l.Value)
+	}
+	e.bool(ts.srcKey != nil)
+	e.bytes(ts.srcKey)
+
+	e.u64(uint64(len(ts.rules)))
+	for _, r := range ts.rules {
+		e.bytes(r.DstKey)
+		e.b = append(e.b, byte(r.Aggregation.Type))
+		e.u64(uint64(r.Aggregation.BucketDuration))
+		e.u64(uint64(r.Aggregation.Align))
+		e.bool(r.open)
+		e.u64(uint64(r.bucketStart))
+		e.u64(uint64(r.acc.count))
+		for _, f := range []float64{r.acc.sum, r.acc.min, r.acc.max, r.acc.first, r.acc.last, r.acc.mean, r.acc.m2} {
+			e.u64(math.Float64bits(f))
+		}
+	}
+	return e.b
+}
+
+// DecodeTimeSeries decodes a header returned by Encode.
+func DecodeTimeSeries(b []byte) (*TimeSeries, error) {
+	d := &tsDecoder{b: b}
+	ts := &TimeSeries{}
+	ts.opts.Retention = int64(d.u64())
+	ts.opts.DuplicatePolicy = TSDuplicatePolicy(d.byte())
+	ts.hasSamples = d.bool()
+	ts.last = int64(d.u64())
+	n := d.count()
+	for i := 0; i < n && !d.bad; i++ {
+		ts.opts.Labels = append(ts.opts.Labels, TSLabel{Name: d.bytes(), Value: d.bytes()})
+	}
+	if hasSrc := d.bool(); hasSrc {
+		ts.srcKey = d.bytes()
+	} else {
+		d.bytes()
+	}
+
+	n = d.count()
+	for i := 0; i < n && !d.bad; i++ {
+		r := TSRule{DstKey: d.bytes()}
+		r.Aggregation.Type = TSAggregationType(d.byte())
+		r.Aggregation.BucketDuration = int64(d.u64())
+		r.Aggregation.Align = int64(d.u64())
+		r.open = d.bool()
+		r.bucketStart = int64(d.u64())
+		r.acc.count = int64(d.u64())
+		for _, f := range []*float64{&r.acc.sum, &r.acc.min, &r.acc.max, &r.acc.first, &r.acc.last, &r.acc.mean, &r.acc.m2} {
+			*f = math.Float64frombits(d.u64())
+		}
+		if r.Aggregation.BucketDuration <= 0 {
+			return nil, errCorruptedTS
+		}
+		ts.rules = append(ts.rules, r)
+	}
+	if d.bad || len(d.b) != 0 {
+		return nil, errCorruptedTS
+	}
+	return ts, nil
+}
+
+type tsEncoder struct {
+	b []byte
+}
+
+func (e *tsEncoder) u64(v uint64) {
+	e.b = binary.BigEndian.AppendUint64(e.b, v)
+}
+
+func (e *tsEncoder) bool(v bool) {
+	if v {
+		e.b = append(e.b, 1)
+	} else {
+		e.b = append(e.b, 0)
+	}
+}
+
+func (e *tsEncoder) bytes(v []byte) {
+	e.b = binary.BigEndian.AppendUint32(e.b, uint32(len(v)))
+	e.b = append(e.b, v...)
+}
+
+// tsDecoder reads values written by tsEncoder, bad is set after a read past the end.
+type tsDecoder struct {
+	b   []byte
+	bad bool
+}
+
+func (d *tsDecoder) next(n int) []byte {
+	if d.bad || len(d.b) < n {
+		d.bad = true
+		return nil
+	}
+	res := d.b[:n:n]
+	d.b = d.b[n:]
+	return res
+}
+
+func (d *tsDecoder) u64() uint64 {
+	if b := d.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *tsDecoder) byte() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *tsDecoder) bool() bool {
+	return d.byte() != 0
+}
+
+// count reads a number of items, each of them takes at least 4 bytes.
+func (d *tsDecoder) count() int {
+	n := d.u64()
+	if n > uint64(len(d.b)/4) {
+		d.bad = true
+		return 0
+	}
+	return int(n)
+}
+
+func (d *tsDecoder) bytes() []byte {
+	b := d.next(4)
+	if b == nil {
+		return nil
+	}
+	return bytes.Clone(d.next(int(binary.BigEndian.Uint32(b))))
+}
@@ -0,0 +1,245 @@
+package core
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+)
+
+// Top-K https://redis.io/docs/data-types/probabilistic/top-k/
+
+var (
+	ErrTopKExists    = NewError(PrefixTopK, "key already exists")
+	ErrTopKNotFound  = NewError(PrefixTopK, "key does not exist")
+	ErrTopKIncrement = NewError(PrefixTopK, "increment must be an integer greater or equal to 1 and less than or equal to 100000")
+	ErrTopKK         = NewError(PrefixTopK, "invalid k")
+	ErrTopKWidth     = NewError(PrefixTopK, "invalid width")
+	ErrTopKDepth     = NewError(PrefixTopK, "invalid depth")
+	ErrTopKDecay     = NewError(PrefixTopK, "invalid decay value. must be '<= 1' & '> 0'")
+
+	errCorruptedTopK = errors.New("corrupted Top-K")
+)
+
+// Defaults of TOPK.RESERVE command, like in RedisBloom.
+const (
+	TopKDefaultWidth = 8
+	TopKDefaultDepth = 7
+	TopKDefaultDecay = 0.9
+)
+
+// topkFingerprintSeed is the seed of fingerprints of items, like in RedisBloom.
+const topkFingerprintSeed = 1919
+
+// TopKOptions are options of TOPK.RESERVE command and the reply of TOPK.INFO.
+type TopKOptions struct {
+	K, Width, Depth int64
+	// Decay is the probability base of decrementing a counter of another item, it's in (0, 1].
+	Decay float64
+}
+
+// TopKEntry is an item of the top with its count.
+type TopKEntry struct {
+	Item  []byte
+	Count int64
+}
+
+// TopK keeps k items with the highest counts using HeavyKeeper: depth rows of width buckets
+// of a fingerprint and a count. An item takes over an empty bucket or a bucket of its own,
+// buckets of other items decay with the probability of Decay^count. Rows are kept
+// in FilterStorage as layers, the header keeps options and a min-heap of the top.
+type TopK struct {
+	opts TopKOptions
+	// heap is a min-heap of k entries by count, entries not taken yet have nil item.
+	heap []topkEntry
+}
+
+type topkEntry struct {
+	item  []byte
+	fp    uint32
+	count uint32
+}
+
+// NewTopK returns an empty top.
+func NewTopK(opts TopKOptions) *TopK {
+	return &TopK{opts: opts, heap: make([]topkEntry, opts.K)}
+}
+
+// Info returns options of the top.
+func (t *TopK) Info() TopKOptions {
+	return t.opts
+}
+
+// IncrBy increments the count of the item and returns the item expelled from the top, nil for none.
+func (t *TopK) IncrBy(st FilterStorage, item []byte, by int64) ([]byte, error) {
+	fp := murmurHash2(item, topkFingerprintSeed)
+	heapMin := t.heap[0].count
+
+	var maxCount uint32
+	for row := 0; row < int(t.opts.Depth); row++ {
+		offset := 8 * int64(uint64(murmurHash2(item, uint32(row)))%uint64(t.opts.Width))
+		var bucket [8]byte
+		if err := st.ReadAt(row, offset, bucket[:]); err != nil {
+			return nil, err
+		}
+		bucketFP, count := binary.BigEndian.Uint32(bucket[:]), binary.BigEndian.Uint32(bucket[4:])
+
+		switch {
+		case count == 0:
+			bucketFP, count = fp, uint32(by)
+		case bucketFP == fp:
+			count = uint32(min(int64(count)+by, math.MaxUint32))
+		default:
+			// the bucket of another item decays, the item takes it over once it's zero.
+			for left := by; left > 0; left-- {
+				if rand.Float64() >= math.Pow(t.opts.Decay, float64(count)) {
+					continue
+				}
+				if count--; count == 0 {
+					bucketFP, count = fp, uint32(left)
+					break
+				}
+			}
+		}
+		if bucketFP == fp {
+			maxCount = max(maxCount, count)
+		}
+
+		binary.BigEndian.PutUint32(bucket[:], bucketFP)
+		binary.BigEndian.PutUint32(bucket[4:], count)
+		if err := st.WriteAt(row, offset, bucket[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	if maxCount == 0 || maxCount < heapMin {
+		return nil, nil
+	}
+	if i := t.find(item, fp); i >= 0 {
+		t.heap[i].count = maxCount
+		t.down(i)
+		return nil, nil
+	}
+	expelled := t.heap[0].item
+	t.heap[0] = topkEntry{item: bytes.Clone(item), fp: fp, count: maxCount}
+	t.down(0)
+	return expelled, nil
+}
+
+// Query reports whether the item is in the top.
+func (t *TopK) Query(item []byte) bool {
+	return t.find(item, murmurHash2(item, topkFingerprintSeed)) >= 0
+}
+
+// List returns items of the top by count in descending order.
+func (t *TopK) List() []TopKEntry {
+	res := make([]TopKEntry, 0, len(t.heap))
+	for _, e := range t.heap {
+		if e.item != nil {
+			res = append(res, TopKEntry{Item: e.item, Count: int64(e.count)})
+		}
+	}
+	slices.SortStableFunc(res, func(a, b TopKEntry) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+	return res
+}
+
+// Clone returns a copy of the header.
+func (t *TopK) Clone() *TopK {
+	res := &TopK{opts: t.opts, heap: slices.Clone(t.heap)}
+	for i := range res.heap {
+		if res.heap[i].item != nil {
+			res.heap[i].item = bytes.Clone(res.heap[i].item)
+		}
+	}
+	return res
+}
+
+func (t *TopK) find(item []byte, fp uint32) int {
+	for i, e := range t.heap {
+		if e.item != nil && e.fp == fp && bytes.Equal(e.item, item) {
+			return i
+		}
+	}
+	return -1
+}
+
+// down restores the heap after the count of the entry i has grown.
+func (t *TopK) down(i int) {
+	for {
+		least := i
+		for _, child := range [2]int{2*i + 1, 2*i + 2} {
+			if child < len(t.heap) && t.heap[child].count < t.heap[least].count {
+				least = child
+			}
+		}
+		if least == i {
+			return
+		}
+		t.heap[i], t.heap[least] = t.heap[least], t.heap[i]
+		i = least
+	}
+}
+
+// Encode returns the header of the top, rows are not included.
+func (t *TopK) Encode() []byte {
+	res := make([]byte, 0, 8+8+8+8+len(t.heap)*12)
+	res = binary.BigEndian.AppendUint64(res, uint64(t.opts.K))
+	res = binary.BigEndian.AppendUint64(res, uint64(t.opts.Width))
+	res = binary.BigEndian.AppendUint64(res, uint64(t.opts.Depth))
+	res = binary.BigEndian.AppendUint64(res, math.Float64bits(t.opts.Decay))
+	for _, e := range t.heap {
+		res = binary.BigEndian.AppendUint32(res, e.fp)
+		res = binary.BigEndian.AppendUint32(res, e.count)
+		// the length is shifted by one, so an empty item can be told from a missing one.
+		if e.item == nil {
+			res = binary.BigEndian.AppendUint32(res, 0)
+			continue
+		}
+		res = binary.BigEndian.AppendUint32(res, uint32(len(e.item))+1)
+		res = append(res, e.item...)
+	}
+	return res
+}
+
+// DecodeTopK decodes a header returned by Encode.
+func DecodeTopK(b []byte) (*TopK, error) {
+	const hdr = 8 + 8 + 8 + 8
+	if len(b) < hdr {
+		return nil, errCorruptedTopK
+	}
+	t := &TopK{opts: TopKOptions{
+		K:     int64(binary.BigEndian.Uint64(b)),
+		Width: int64(binary.BigEndian.Uint64(b[8:])),
+		Depth: int64(binary.BigEndian.Uint64(b[16:])),
+		Decay: math.Float64frombits(binary.BigEndian.Uint64(b[24:])),
+	}}
+	if t.opts.K <= 0 || t.opts.K > int64(len(b)-hdr)/12 || t.opts.Width <= 0 || t.opts.Depth <= 0 {
+		return nil, errCorruptedTopK
+	}
+	t.heap = make([]topkEntry, t.opts.K)
+	b = b[hdr:]
+	for i := range t.heap {
+		if len(b) < 12 {
+			return nil, errCorruptedTopK
+		}
+		e := topkEntry{fp: binary.BigEndian.Uint32(b), count: binary.BigEndian.Uint32(b[4:])}
+		n := int(binary.BigEndian.Uint32(b[8:]))
+		b = b[12:]
+		if n > 0 {
+			if len(b) < n-1 {
+				return nil, errCorruptedTopK
+			}
+			e.item, b = bytes.Clone(b[:n-1]), b[n-1:]
+		}
+		t.heap[i] = e
+	}
+	if len(b) != 0 {
+		return nil, errCorruptedTopK
+	}
+	return t, nil
+}
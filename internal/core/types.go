@@ -0,0 +1,52 @@
+package core
+
+// KeyType is a type of value stored at key.
+type KeyType byte
+
+const (
+	TypeNone KeyType = iota
+	TypeString
+	TypeList
+	TypeHash
+	TypeSet
+	TypeZSet
+	TypeStream
+	TypeJSON
+	TypeBloom
+	TypeCuckoo
+	TypeCMS
+	TypeTopK
+	TypeTimeSeries
+)
+
+// String returns type name like TYPE command does.
+func (t KeyType) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeList:
+		return "list"
+	case TypeHash:
+		return "hash"
+	case TypeSet:
+		return "set"
+	case TypeZSet:
+		return "zset"
+	case TypeStream:
+		return "stream"
+	case TypeJSON:
+		return "ReJSON-RL"
+	case TypeBloom:
+		return "MBbloom--"
+	case TypeCuckoo:
+		return "MBbloomCF"
+	case TypeCMS:
+		return "CMSk-type"
+	case TypeTopK:
+		return "TopK-TYPE"
+	case TypeTimeSeries:
+		return "TSDB-TYPE"
+	default:
+		return "none"
+	}
+}
@@ -0,0 +1,427 @@
+package core
+
+import (
+	"bytes"
+	"cmp"
+	"math"
+	"strconv"
+)
+
+var ErrScoreNaN = NewError(PrefixErr, "resulting score is not a number (NaN)")
+
+// ZMember is a member of a sorted set with its score.
+type ZMember struct {
+	Member []byte
+	Score  float64
+}
+
+// CompareZMembers orders sorted set members by score and then by member.
+func CompareZMembers(a, b ZMember) int {
+	if c := cmp.Compare(a.Score, b.Score); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.Member, b.Member)
+}
+
+// FormatScore formats the score like Redis does in replies: the shortest representation,
+// integers in the long long range and other numbers up to 17 digits have no exponent.
+func FormatScore(score float64) string {
+	abs := math.Abs(score)
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	case score == math.Trunc(score) && abs < 1<<63, abs >= 1e-4 && abs < 1e17:
+		return strconv.FormatFloat(score, 'f', -1, 64)
+	default:
+		return strconv.FormatFloat(score, 'g', -1, 64)
+	}
+}
+
+// ZAddOptions are options for ZADD command.
+type ZAddOptions struct {
+	// NX only adds new members.
+	NX bool
+	// XX only updates existing members.
+	XX bool
+	// GT only updates existing members if the new score is greater.
+	GT bool
+	// LT only updates existing members if the new score is less.
+	LT bool
+	// CH counts updated members in the result, not only added ones.
+	CH bool
+}
+
+// Allowed reports whether the score of a member can be set to score,
+// old is the current score if the member exists.
+func (o ZAddOptions) Allowed(old, score float64, exists bool) bool {
+	switch {
+	case !exists:
+		return !o.XX
+	case o.NX:
+		return false
+	case o.GT && score <= old:
+		return false
+	case o.LT && score >= old:
+		return false
+	default:
+		return true
+	}
+}
+
+// ZRangeBy is a kind of sorted set range.
+type ZRangeBy int
+
+const (
+	ZRangeByIndex ZRangeBy = iota
+	ZRangeByScore
+	ZRangeByLex
+)
+
+// ScoreBound is a bound of a score range.
+type ScoreBound struct {
+	Value     float64
+	Exclusive bool
+}
+
+// LexBound is a bound of a lexicographical range.
+type LexBound struct {
+	Value     []byte
+	Exclusive bool
+	// Inf is -1 for "-" and 1 for "+", Value is used only if it's zero.
+	Inf int
+}
+
+// ZRange selects members of a sorted set like ZRANGE command does.
+type ZRange struct {
+	By ZRangeBy
+	// Start and Stop are inclusive indexes for ZRangeByIndex, negative ones count from the end.
+	Start, Stop int
+	// Min and Max bound scores for ZRangeByScore.
+	Min, Max ScoreBound
+	// LexMin and LexMax bound members for ZRangeByLex.
+	LexMin, LexMax LexBound
+	// Rev returns members from the highest to the lowest, indexes count from the highest too.
+	Rev bool
+	// Limit enables Offset and Count for ZRangeByScore and ZRangeByLex,
+	// negative Count returns all members after Offset.
+	Limit         bool
+	Offset, Count int
+}
+
+// AboveMin reports whether the member is not below the lower bound of the range.
+// Index ranges have no bounds.
+func (r ZRange) AboveMin(m ZMember) bool {
+	switch r.By {
+	case ZRangeByScore:
+		return m.Score > r.Min.Value || (m.Score == r.Min.Value && !r.Min.Exclusive)
+	case ZRangeByLex:
+		if r.LexMin.Inf != 0 {
+			return r.LexMin.Inf < 0
+		}
+		c := bytes.Compare(m.Member, r.LexMin.Value)
+		return c > 0 || (c == 0 && !r.LexMin.Exclusive)
+	default:
+		return true
+	}
+}
+
+// BelowMax reports whether the member is not above the upper bound of the range.
+// Index ranges have no bounds.
+func (r ZRange) BelowMax(m ZMember) bool {
+	switch r.By {
+	case ZRangeByScore:
+		return m.Score < r.Max.Value || (m.Score == r.Max.Value && !r.Max.Exclusive)
+	case ZRangeByLex:
+		if r.LexMax.Inf != 0 {
+			return r.LexMax.Inf > 0
+		}
+		c := bytes.Compare(m.Member, r.LexMax.Value)
+		return c < 0 || (c == 0 && !r.LexMax.Exclusive)
+	default:
+		return true
+	}
+}
+
+// Slice returns the range of members sorted by score and member, in the range order.
+func (r ZRange) Slice(members []ZMember) []ZMember {
+	c := r.Cursor(len(members))
+
+	res := []ZMember{}
+	for i := range members {
+		m := members[i]
+		if r.Rev {
+			m = members[len(members)-1-i]
+		}
+		take, more := c.Next(m)
+		if take {
+			res = append(res, m)
+		}
+		if !more {
+			break
+		}
+	}
+	return res
+}
+
+// Cursor returns a cursor for members of a sorted set of size n.
+func (r ZRange) Cursor(n int) *ZRangeCursor {
+	c := &ZRangeCursor{r: r, count: -1}
+	switch {
+	case r.By == ZRangeByIndex:
+		from, to := ListRange(r.Start, r.Stop, n)
+		c.skip, c.count = from, to-from
+	case !r.Limit:
+	case r.Offset < 0:
+		c.count = 0
+	default:
+		c.skip, c.count = r.Offset, r.Count
+	}
+	return c
+}
+
+// ZRangeCursor applies bounds, offset and count of a range to members
+// visited in the range order, so stores can stream them.
+type ZRangeCursor struct {
+	r ZRange
+	// skip is the number of members in range left to skip,
+	// count is the number of members left to return, negative if unlimited.
+	skip, count int
+}
+
+// Next reports whether the member is in the range and whether there might be more.
+func (c *ZRangeCursor) Next(m ZMember) (take, more bool) {
+	switch {
+	case c.count == 0:
+		return false, false
+	case !c.r.inStart(m):
+		return false, true
+	case !c.r.inEnd(m):
+		return false, false
+	case c.skip > 0:
+		c.skip--
+		return false, true
+	}
+	c.count--
+	return true, c.count != 0
+}
+
+// inStart reports whether the member is not before the start of the range in its order.
+func (r ZRange) inStart(m ZMember) bool {
+	if r.Rev {
+		return r.BelowMax(m)
+	}
+	return r.AboveMin(m)
+}
+
+// inEnd reports whether the member is not after the end of the range in its order.
+func (r ZRange) inEnd(m ZMember) bool {
+	if r.Rev {
+		return r.AboveMin(m)
+	}
+	return r.BelowMax(m)
+}
+
+// ZAggregate is a way to combine scores of the same member in different sorted sets.
+type ZAggregate int
+
+const (
+	ZAggregateSum ZAggregate = iota
+	ZAggregateMin
+	ZAggregateMax
+)
+
+// ZAggOptions are options for ZUNION and ZINTER commands.
+type ZAggOptions struct {
+	// Weights multiply scores of the corresponding sorted sets, nil means all ones.
+	Weights   []float64
+	Aggregate ZAggregate
+}
+
+// weighted returns the score of a member of the i-th sorted set multiplied by its weight.
+func (o ZAggOptions) weighted(i int, score float64) float64 {
+	if o.Weights == nil {
+		return score
+	}
+	// like Redis, inf * 0 is 0.
+	if res := score * o.Weights[i]; !math.IsNaN(res) {
+		return res
+	}
+	return 0
+}
+
+func (o ZAggOptions) aggregate(a, b float64) float64 {
+	switch o.Aggregate {
+	case ZAggregateMin:
+		return min(a, b)
+	case ZAggregateMax:
+		return max(a, b)
+	}
+	// like Redis, inf + -inf is 0.
+	if res := a + b; !math.IsNaN(res) {
+		return res
+	}
+	return 0
+}
+
+// ZMemberIter returns members of a sorted set ordered by member, false when there are no more.
+// Returned member is valid until the next call.
+type ZMemberIter func() (ZMember, bool, error)
+
+// zhead is the current member of an iterator.
+type zhead struct {
+	m  ZMember
+	ok bool
+}
+
+func zstart(iters []ZMemberIter) ([]zhead, error) {
+	heads := make([]zhead, len(iters))
+	for i := range iters {
+		if err := heads[i].next(iters[i]); err != nil {
+			return nil, err
+		}
+	}
+	return heads, nil
+}
+
+func (h *zhead) next(iter ZMemberIter) error {
+	var err error
+	h.m, h.ok, err = iter()
+	return err
+}
+
+// ZUnion merges members of all sorted sets and calls fn in member order until it returns false.
+// Member passed to fn is valid only until it returns.
+func ZUnion(iters []ZMemberIter, opts ZAggOptions, fn func(ZMember) bool) error {
+	heads, err := zstart(iters)
+	if err != nil {
+		return err
+	}
+
+	matched := make([]bool, len(heads))
+	for {
+		first := -1
+		for i, h := range heads {
+			if h.ok && (first == -1 || bytes.Compare(h.m.Member, heads[first].m.Member) < 0) {
+				first = i
+			}
+		}
+		if first == -1 {
+			return nil
+		}
+
+		res := ZMember{Member: heads[first].m.Member}
+		for i, h := range heads {
+			matched[i] = h.ok && bytes.Equal(h.m.Member, res.Member)
+			if !matched[i] {
+				continue
+			}
+			score := opts.weighted(i, h.m.Score)
+			if i == first {
+				res.Score = score
+			} else {
+				res.Score = opts.aggregate(res.Score, score)
+			}
+		}
+		if !fn(res) {
+			return nil
+		}
+
+		for i := range heads {
+			if !matched[i] {
+				continue
+			}
+			if err := heads[i].next(iters[i]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ZInter merges members that are in all sorted sets and calls fn in member order until it returns false.
+// Member passed to fn is valid only until it returns.
+func ZInter(iters []ZMemberIter, opts ZAggOptions, fn func(ZMember) bool) error {
+	if len(iters) == 0 {
+		return nil
+	}
+	heads, err := zstart(iters)
+	if err != nil {
+		return err
+	}
+
+	for {
+		last := 0
+		for i, h := range heads {
+			if !h.ok {
+				return nil
+			}
+			if bytes.Compare(h.m.Member, heads[last].m.Member) > 0 {
+				last = i
+			}
+		}
+
+		// members before the last one can't be in all sorted sets.
+		behind := false
+		for i := range heads {
+			if i == last || bytes.Equal(heads[i].m.Member, heads[last].m.Member) {
+				continue
+			}
+			behind = true
+			if err := heads[i].next(iters[i]); err != nil {
+				return err
+			}
+		}
+		if behind {
+			continue
+		}
+
+		res := ZMember{Member: heads[0].m.Member, Score: opts.weighted(0, heads[0].m.Score)}
+		for i, h := range heads[1:] {
+			res.Score = opts.aggregate(res.Score, opts.weighted(i+1, h.m.Score))
+		}
+		if !fn(res) {
+			return nil
+		}
+
+		for i := range heads {
+			if err := heads[i].next(iters[i]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ZDiff calls fn for members of the first sorted set that are not in the other ones,
+// in member order until it returns false. Member passed to fn is valid only until it returns.
+func ZDiff(iters []ZMemberIter, fn func(ZMember) bool) error {
+	if len(iters) == 0 {
+		return nil
+	}
+	heads, err := zstart(iters)
+	if err != nil {
+		return err
+	}
+
+	for first := &heads[0]; first.ok; {
+		found := false
+		for i := 1; i < len(heads); i++ {
+			for heads[i].ok && bytes.Compare(heads[i].m.Member, first.m.Member) < 0 {
+				if err := heads[i].next(iters[i]); err != nil {
+					return err
+				}
+			}
+			if heads[i].ok && bytes.Equal(heads[i].m.Member, first.m.Member) {
+				found = true
+				break
+			}
+		}
+		if !found && !fn(first.m) {
+			return nil
+		}
+		if err := first.next(iters[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
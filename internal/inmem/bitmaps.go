@@ -0,0 +1,159 @@
+package inmem
+
+import (
+	"github.com/cristaloleg/didis/internal/core"
+)
+
+// Bitmaps operations https://redis.io/commands/?group=bitmap
+
+func (s *Store) BITCOUNT(key []byte, rng core.BitRange) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	val, ok, err := s.getString(key)
+	if err != nil || !ok || rng.Empty() {
+		return 0, err
+	}
+	first, last, ok := rng.Bits(int64(len(val)))
+	if !ok {
+		return 0, nil
+	}
+	return core.BitCount(val, first, last), nil
+}
+
+func (s *Store) BITFIELD(key []byte, ops []core.BitFieldOp) ([]*int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, _, err := s.loadString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// the value is grown for all writes upfront, even for the ones that fail.
+	var size int64
+	for _, op := range ops {
+		if op.Kind != core.BitFieldGet {
+			size = max(size, op.End())
+		}
+	}
+	if size != 0 {
+		if val, err = grow(val, size); err != nil {
+			return nil, err
+		}
+		s.put(string(key), val)
+	}
+
+	res := make([]*int64, 0, len(ops))
+	for _, op := range ops {
+		res = append(res, op.Exec(val))
+	}
+	return res, nil
+}
+
+func (s *Store) BITFIELDRO(key []byte, ops []core.BitFieldOp) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	val, _, err := s.getString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]int64, 0, len(ops))
+	for _, op := range ops {
+		res = append(res, *op.Exec(val))
+	}
+	return res, nil
+}
+
+func (s *Store) BITOP(op core.BitOp, dst []byte, keys ...[]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	srcs := make([][]byte, len(keys))
+	var size int
+	for i, key := range keys {
+		val, _, err := s.loadString(key)
+		if err != nil {
+			return 0, err
+		}
+		srcs[i] = val
+		size = max(size, len(val))
+	}
+
+	if size == 0 {
+		s.del(string(dst))
+		return 0, nil
+	}
+	res := make([]byte, size)
+	op.Apply(res, srcs)
+	s.set(string(dst), res)
+	return size, nil
+}
+
+func (s *Store) BITPOS(key []byte, bit int, rng core.BitRange, hasEnd bool) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	val, ok, err := s.getString(key)
+	if err != nil {
+		return 0, err
+	}
+	// a missing key is an infinite array of clear bits.
+	if !ok {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+	first, last, ok := rng.Bits(int64(len(val)))
+	if !ok {
+		return -1, nil
+	}
+
+	pos := core.BitPos(val, bit, first, last)
+	if pos == -1 && bit == 0 && !hasEnd {
+		return int64(len(val)) * 8, nil
+	}
+	return pos, nil
+}
+
+func (s *Store) GETBIT(key []byte, offset int64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	val, _, err := s.getString(key)
+	if err != nil {
+		return 0, err
+	}
+	return core.GetBit(val, offset), nil
+}
+
+func (s *Store) SETBIT(key []byte, offset int64, bit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, _, err := s.loadString(key)
+	if err != nil {
+		return 0, err
+	}
+	val, err = grow(val, offset>>3+1)
+	if err != nil {
+		return 0, err
+	}
+	old := core.SetBit(val, offset, bit)
+	s.put(string(key), val)
+	return old, nil
+}
+
+// grow pads the value with zeros to at least size bytes.
+func grow(val []byte, size int64) ([]byte, error) {
+	if size <= int64(len(val)) {
+		return val, nil
+	}
+	if size > core.MaxStringSize {
+		return nil, core.ErrStringTooLong
+	}
+	return append(val, make([]byte, size-int64(len(val)))...), nil
+}
@@ -0,0 +1,254 @@
+package inmem
+
+import (
+	"bytes"
+
+	"github.com/cristaloleg/didis/internal/core"
+)
+
+// Bloom filter operations https://redis.io/commands/?group=bf
+// Cuckoo filter operations https://redis.io/commands/?group=cf
+
+// bloom is a Bloom filter with its bit arrays.
+type bloom struct {
+	filter *core.BloomFilter
+	arrays filterArrays
+}
+
+// cuckoo is a Cuckoo filter with its buckets.
+type cuckoo struct {
+	filter *core.CuckooFilter
+	arrays filterArrays
+}
+
+// filterArrays implements core.FilterStorage, arrays grow on writes.
+type filterArrays [][]byte
+
+func (a filterArrays) ReadAt(layer int, offset int64, buf []byte) error {
+	clear(buf)
+	if layer < len(a) && offset < int64(len(a[layer])) {
+		copy(buf, a[layer][offset:])
+	}
+	return nil
+}
+
+func (a *filterArrays) WriteAt(layer int, offset int64, data []byte) error {
+	for layer >= len(*a) {
+		*a = append(*a, nil)
+	}
+	arr := (*a)[layer]
+	if end := offset + int64(len(data)); end > int64(len(arr)) {
+		arr = append(arr, make([]byte, end-int64(len(arr)))...)
+		(*a)[layer] = arr
+	}
+	copy(arr[offset:], data)
+	return nil
+}
+
+func (a filterArrays) clone() filterArrays {
+	res := make(filterArrays, len(a))
+	for i, arr := range a {
+		res[i] = bytes.Clone(arr)
+	}
+	return res
+}
+
+func (s *Store) BFINFO(key []byte) (core.BloomInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bf, err := s.getBloom(key)
+	if err != nil {
+		return core.BloomInfo{}, err
+	}
+	if bf == nil {
+		return core.BloomInfo{}, core.ErrFilterNotFound
+	}
+	return bf.filter.Info(), nil
+}
+
+func (s *Store) BFMADD(key []byte, items ...[]byte) ([]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bf, err := s.loadBloom(key)
+	if err != nil {
+		return nil, err
+	}
+	if bf == nil {
+		f, err := core.NewBloomFilter(core.BloomOptions{
+			ErrorRate: core.BloomDefaultErrorRate,
+			Capacity:  core.BloomDefaultCapacity,
+			Expansion: core.BloomDefaultExpansion,
+		})
+		if err != nil {
+			return nil, err
+		}
+		bf = &bloom{filter: f}
+		s.set(string(key), bf)
+	}
+
+	res := make([]bool, 0, len(items))
+	for _, item := range items {
+		added, err := bf.filter.Add(&bf.arrays, item)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, added)
+	}
+	return res, nil
+}
+
+func (s *Store) BFMEXISTS(key []byte, items ...[]byte) ([]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bf, err := s.getBloom(key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]bool, len(items))
+	if bf == nil {
+		return res, nil
+	}
+	for i, item := range items {
+		res[i], _ = bf.filter.Exists(&bf.arrays, item)
+	}
+	return res, nil
+}
+
+func (s *Store) BFRESERVE(key []byte, opts core.BloomOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.load(key); ok {
+		return core.ErrFilterExists
+	}
+	f, err := core.NewBloomFilter(opts)
+	if err != nil {
+		return err
+	}
+	s.set(string(key), &bloom{filter: f})
+	return nil
+}
+
+func (s *Store) CFADD(key, item []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cf, err := s.loadOrNewCuckoo(key)
+	if err != nil {
+		return err
+	}
+	return cf.filter.Add(&cf.arrays, item)
+}
+
+func (s *Store) CFADDNX(key, item []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cf, err := s.loadOrNewCuckoo(key)
+	if err != nil {
+		return false, err
+	}
+	if ok, _ := cf.filter.Exists(&cf.arrays, item); ok {
+		return false, nil
+	}
+	if err := cf.filter.Add(&cf.arrays, item); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Store) CFCOUNT(key, item []byte) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cf, err := s.getCuckoo(key)
+	if err != nil || cf == nil {
+		return 0, err
+	}
+	return cf.filter.Count(&cf.arrays, item)
+}
+
+func (s *Store) CFDEL(key, item []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cf, err := s.loadCuckoo(key)
+	if err != nil {
+		return false, err
+	}
+	if cf == nil {
+		return false, core.ErrCuckooNotFound
+	}
+	return cf.filter.Del(&cf.arrays, item)
+}
+
+func (s *Store) CFEXISTS(key, item []byte) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cf, err := s.getCuckoo(key)
+	if err != nil || cf == nil {
+		return false, err
+	}
+	return cf.filter.Exists(&cf.arrays, item)
+}
+
+// loadOrNewCuckoo is like loadCuckoo but creates a filter of the default capacity for a missing key.
+func (s *Store) loadOrNewCuckoo(key []byte) (*cuckoo, error) {
+	cf, err := s.loadCuckoo(key)
+	if err != nil || cf != nil {
+		return cf, err
+	}
+	cf = &cuckoo{filter: core.NewCuckooFilter(core.CuckooDefaultCapacity)}
+	s.set(string(key), cf)
+	return cf, nil
+}
+
+// getBloom is like get but fails for keys that are not Bloom filters, the filter is nil for a missing key.
+func (s *Store) getBloom(key []byte) (*bloom, error) {
+	val, ok := s.get(key)
+	return asBloom(val, ok)
+}
+
+// loadBloom is like load but fails for keys that are not Bloom filters, the filter is nil for a missing key.
+func (s *Store) loadBloom(key []byte) (*bloom, error) {
+	val, ok := s.load(key)
+	return asBloom(val, ok)
+}
+
+func asBloom(val any, ok bool) (*bloom, error) {
+	if !ok {
+		return nil, nil
+	}
+	bf, isBloom := val.(*bloom)
+	if !isBloom {
+		return nil, core.ErrWrongType
+	}
+	return bf, nil
+}
+
+// getCuckoo is like get but fails for keys that are not Cuckoo filters, the filter is nil for a missing key.
+func (s *Store) getCuckoo(key []byte) (*cuckoo, error) {
+	val, ok := s.get(key)
+	return asCuckoo(val, ok)
+}
+
+// loadCuckoo is like load but fails for keys that are not Cuckoo filters, the filter is nil for a missing key.
+func (s *Store) loadCuckoo(key []byte) (*cuckoo, error) {
+	val, ok := s.load(key)
+	return asCuckoo(val, ok)
+}
+
+func asCuckoo(val any, ok bool) (*cuckoo, error) {
+	if !ok {
+		return nil, nil
+	}
+	cf, isCuckoo := val.(*cuckoo)
+	if !isCuckoo {
+		return nil, core.ErrWrongType
+	}
+	return cf, nil
+}
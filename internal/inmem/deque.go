@@ -0,0 +1,79 @@
+package inmem
+
+// deque is a double-ended queue on top of a ring buffer.
+type deque struct {
+	buf  [][]byte
+	head int
+	size int
+}
+
+func newDeque(elems ...[]byte) *deque {
+	d := &deque{buf: make([][]byte, max(len(elems), 8))}
+	copy(d.buf, elems)
+	d.size = len(elems)
+	return d
+}
+
+func (d *deque) Len() int {
+	return d.size
+}
+
+// At returns i-th element, i must be in [0, Len()).
+func (d *deque) At(i int) []byte {
+	return d.buf[(d.head+i)%len(d.buf)]
+}
+
+// Set replaces i-th element, i must be in [0, Len()).
+func (d *deque) Set(i int, v []byte) {
+	d.buf[(d.head+i)%len(d.buf)] = v
+}
+
+func (d *deque) PushFront(v []byte) {
+	d.grow()
+	d.head = (d.head - 1 + len(d.buf)) % len(d.buf)
+	d.buf[d.head] = v
+	d.size++
+}
+
+func (d *deque) PushBack(v []byte) {
+	d.grow()
+	d.buf[(d.head+d.size)%len(d.buf)] = v
+	d.size++
+}
+
+// PopFront removes the first element, deque must not be empty.
+func (d *deque) PopFront() []byte {
+	v := d.buf[d.head]
+	d.buf[d.head] = nil
+	d.head = (d.head + 1) % len(d.buf)
+	d.size--
+	return v
+}
+
+// PopBack removes the last element, deque must not be empty.
+func (d *deque) PopBack() []byte {
+	i := (d.head + d.size - 1) % len(d.buf)
+	v := d.buf[i]
+	d.buf[i] = nil
+	d.size--
+	return v
+}
+
+// Slice returns a copy of elements in [from, to).
+func (d *deque) Slice(from, to int) [][]byte {
+	res := make([][]byte, 0, to-from)
+	for i := from; i < to; i++ {
+		res = append(res, d.At(i))
+	}
+	return res
+}
+
+func (d *deque) grow() {
+	if d.size < len(d.buf) {
+		return
+	}
+	buf := make([][]byte, 2*len(d.buf))
+	n := copy(buf, d.buf[d.head:])
+	copy(buf[n:], d.buf[:d.head])
+	d.buf, d.head = buf, 0
+}
@@ -0,0 +1,266 @@
+package inmem
+
+import (
+	"bytes"
+	"slices"
+	"time"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/tidwall/btree"
+)
+
+// Generic operations https://redis.io/commands/?group=generic
+
+func (s *Store) COPY(src, dst []byte, replace bool) (bool, error) {
+	if string(src) == string(dst) {
+		return false, core.ErrSameObject
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, ok := s.load(src)
+	if !ok {
+		return false, nil
+	}
+	if _, ok := s.load(dst); ok && !replace {
+		return false, nil
+	}
+
+	s.set(string(dst), clone(val))
+	if at, ok := s.exp[string(src)]; ok {
+		s.exp[string(dst)] = at
+	}
+	return true, nil
+}
+
+func (s *Store) DEL(keys ...[]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, key := range keys {
+		if _, ok := s.load(key); ok {
+			s.del(string(key))
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) EXISTS(keys ...[]byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, key := range keys {
+		if _, ok := s.get(key); ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) EXPIRE(key []byte, seconds int64, cond core.ExpireCond) (bool, error) {
+	at, err := core.ExpireAtMs(core.NowMs(), seconds, time.Second)
+	if err != nil {
+		return false, err
+	}
+	return s.expireAt(key, at, cond)
+}
+
+func (s *Store) EXPIREAT(key []byte, unixSeconds int64, cond core.ExpireCond) (bool, error) {
+	at, err := core.UnixMs(unixSeconds, time.Second)
+	if err != nil {
+		return false, err
+	}
+	return s.expireAt(key, at, cond)
+}
+
+func (s *Store) EXPIRETIME(key []byte) (int64, error) {
+	return s.expireTime(key, time.Second), nil
+}
+
+func (s *Store) FLUSHDB() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.m = make(map[string]any, 1024)
+	s.exp = make(map[string]int64)
+	s.keys = btree.NewNonConcurrent(lessKey)
+	return nil
+}
+
+func (s *Store) KEYS(pattern []byte) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := core.NowMs()
+	res := [][]byte{}
+	for key := range s.m {
+		if !s.isExpired(key, now) && core.Match(pattern, []byte(key)) {
+			res = append(res, []byte(key))
+		}
+	}
+	slices.SortFunc(res, bytes.Compare)
+	return res, nil
+}
+
+func (s *Store) PERSIST(key []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.load(key); !ok {
+		return false, nil
+	}
+	if _, ok := s.exp[string(key)]; !ok {
+		return false, nil
+	}
+	delete(s.exp, string(key))
+	return true, nil
+}
+
+func (s *Store) PEXPIRE(key []byte, milliseconds int64, cond core.ExpireCond) (bool, error) {
+	at, err := core.ExpireAtMs(core.NowMs(), milliseconds, time.Millisecond)
+	if err != nil {
+		return false, err
+	}
+	return s.expireAt(key, at, cond)
+}
+
+func (s *Store) PEXPIREAT(key []byte, unixMilliseconds int64, cond core.ExpireCond) (bool, error) {
+	return s.expireAt(key, unixMilliseconds, cond)
+}
+
+func (s *Store) PEXPIRETIME(key []byte) (int64, error) {
+	return s.expireTime(key, time.Millisecond), nil
+}
+
+func (s *Store) PTTL(key []byte) (int64, error) {
+	return s.ttl(key, time.Millisecond), nil
+}
+
+func (s *Store) RENAME(key, newkey []byte) error {
+	_, err := s.rename(key, newkey, false)
+	return err
+}
+
+func (s *Store) RENAMENX(key, newkey []byte) (bool, error) {
+	return s.rename(key, newkey, true)
+}
+
+// SCAN walks the sorted index of keys, so the cursor continues from a key
+// and is not affected by map mutations between calls.
+// Expired keys are checked too, so a call visits at most count keys.
+func (s *Store) SCAN(cursor uint64, opts core.ScanOptions) ([][]byte, uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := opts.Count
+	if count <= 0 {
+		count = core.DefaultScanCount
+	}
+
+	now := core.NowMs()
+	seek := string(s.cursors.Seek(nil, cursor))
+	res := [][]byte{}
+	next := uint64(0)
+	checked := 0
+	s.keys.Ascend(seek, func(item any) bool {
+		key := item.(string)
+		if checked == count {
+			next = s.cursors.Next(nil, []byte(key))
+			return false
+		}
+		checked++
+
+		switch {
+		case s.isExpired(key, now):
+		case opts.Type != "" && opts.Type != typeOf(s.m[key]).String():
+		case len(opts.Match) == 0 || core.Match(opts.Match, []byte(key)):
+			res = append(res, []byte(key))
+		}
+		return true
+	})
+	return res, next, nil
+}
+
+func (s *Store) TOUCH(keys ...[]byte) (int, error) {
+	return s.EXISTS(keys...)
+}
+
+func (s *Store) TTL(key []byte) (int64, error) {
+	return s.ttl(key, time.Second), nil
+}
+
+func (s *Store) TYPE(key []byte) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	val, _ := s.get(key)
+	return typeOf(val).String(), nil
+}
+
+func (s *Store) UNLINK(keys ...[]byte) (int, error) {
+	return s.DEL(keys...)
+}
+
+func (s *Store) expireAt(key []byte, at int64, cond core.ExpireCond) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.load(key); !ok {
+		return false, nil
+	}
+	if !cond.Allow(s.exp[string(key)], at) {
+		return false, nil
+	}
+
+	if at <= core.NowMs() {
+		s.del(string(key))
+		return true, nil
+	}
+	s.exp[string(key)] = at
+	return true, nil
+}
+
+func (s *Store) expireTime(key []byte, unit time.Duration) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.get(key)
+	return core.ExpireTimeReply(ok, s.exp[string(key)], unit)
+}
+
+func (s *Store) ttl(key []byte, unit time.Duration) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.get(key)
+	return core.TTLReply(ok, core.NowMs(), s.exp[string(key)], unit)
+}
+
+func (s *Store) rename(key, newkey []byte, nx bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, ok := s.load(key)
+	if !ok {
+		return false, core.ErrKeyNotFound
+	}
+	if string(key) == string(newkey) {
+		return !nx, nil
+	}
+	if _, ok := s.load(newkey); ok && nx {
+		return false, nil
+	}
+
+	at, hasTTL := s.exp[string(key)]
+	s.del(string(key))
+	s.set(string(newkey), val)
+	if hasTTL {
+		s.exp[string(newkey)] = at
+	}
+	return true, nil
+}
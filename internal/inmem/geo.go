@@ -0,0 +1,75 @@
+package inmem
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/cristaloleg/didis/internal/core"
+)
+
+// Geospatial operations https://redis.io/commands/?group=geo
+
+func (s *Store) GEOSEARCH(key []byte, q core.GeoSearch) ([]core.GeoPoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	z, ok, err := s.getZSet(key)
+	if err != nil || !ok {
+		return []core.GeoPoint{}, err
+	}
+	return geoSearch(z, q)
+}
+
+func (s *Store) GEOSEARCHSTORE(dst, src []byte, q core.GeoSearch, storeDist bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, ok, err := s.getZSet(src)
+	if err != nil {
+		return 0, err
+	}
+	res := []core.GeoPoint{}
+	if ok {
+		res, err = geoSearch(z, q)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	s.del(string(dst))
+	if len(res) == 0 {
+		return 0, nil
+	}
+	members := make([]core.ZMember, len(res))
+	for i, p := range res {
+		members[i] = core.ZMember{Member: p.Member, Score: p.Score}
+		if storeDist {
+			members[i].Score = p.Dist
+		}
+	}
+	s.set(string(dst), newZSetOf(members))
+	return len(res), nil
+}
+
+// geoSearch resolves the center of the search and runs it over the sorted set.
+func geoSearch(z *zset, q core.GeoSearch) ([]core.GeoPoint, error) {
+	if q.FromMember != nil {
+		score, ok := z.score(q.FromMember)
+		if !ok {
+			return nil, core.ErrGeoMemberNotFound
+		}
+		q.Lon, q.Lat = core.GeoPosition(score)
+	}
+
+	return q.Search(func(min, max float64, fn func(core.ZMember) bool) error {
+		i, _ := slices.BinarySearchFunc(z.sorted, min, func(m core.ZMember, score float64) int {
+			return cmp.Compare(m.Score, score)
+		})
+		for ; i < len(z.sorted) && z.sorted[i].Score < max; i++ {
+			if !fn(z.sorted[i]) {
+				break
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,199 @@
+package inmem
+
+import (
+	"testing"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cristalhq/testt"
+)
+
+func TestGEOSEARCH(t *testing.T) {
+	/*
+		redis> GEOADD Sicily 13.361389 38.115556 "Palermo" 15.087269 37.502669 "Catania"
+		(integer) 2
+		redis> GEOADD Sicily 12.758489 38.788135 "edge1"   17.241510 38.788135 "edge2"
+		(integer) 2
+		redis> GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 200 km ASC
+		1) "Catania"
+		2) "Palermo"
+		redis> GEOSEARCH Sicily FROMLONLAT 15 37 BYBOX 400 400 km ASC WITHCOORD WITHDIST
+		1) 1) "Catania"
+		   2) "56.4413"
+		   3) 1) "15.08726745843887329"
+		      2) "37.50266842333162032"
+		2) 1) "Palermo"
+		   2) "190.4424"
+		   3) 1) "13.36138933897018433"
+		      2) "38.11555639549629859"
+		3) 1) "edge2"
+		   2) "279.7403"
+		   3) 1) "17.24151045083999634"
+		      2) "38.78813451624225195"
+		4) 1) "edge1"
+		   2) "279.7405"
+		   3) 1) "12.7584877610206604"
+		      2) "38.78813451624225195"
+		redis>
+	*/
+
+	sicily := []byte("Sicily")
+
+	s := New()
+	_, err := s.ZADD(sicily, core.ZAddOptions{}, sicilyMembers()...)
+	testt.NoError(t, err)
+
+	res, err := s.GEOSEARCH(sicily, core.GeoSearch{
+		Lon: 15, Lat: 37, Radius: 200, Unit: core.GeoKilometers, Sort: core.GeoSortAsc,
+	})
+	testt.NoError(t, err)
+	testt.MustEqual(t, geoMembers(res), []string{"Catania", "Palermo"})
+
+	res, err = s.GEOSEARCH(sicily, core.GeoSearch{
+		Lon: 15, Lat: 37, ByBox: true, Width: 400, Height: 400, Unit: core.GeoKilometers, Sort: core.GeoSortAsc,
+	})
+	testt.NoError(t, err)
+	testt.MustEqual(t, geoMembers(res), []string{"Catania", "Palermo", "edge2", "edge1"})
+	testt.MustEqual(t, int(res[0].Dist*10000+0.5), 564413)
+	testt.MustEqual(t, res[0].Score, float64(3479447370796909))
+	testt.MustEqual(t, res[0].Lon, 15.08726745843887329)
+	testt.MustEqual(t, res[0].Lat, 37.50266842333162032)
+
+	res, err = s.GEOSEARCH(sicily, core.GeoSearch{
+		FromMember: []byte("Palermo"), Radius: 200, Unit: core.GeoKilometers, Sort: core.GeoSortDesc,
+	})
+	testt.NoError(t, err)
+	testt.MustEqual(t, geoMembers(res), []string{"Catania", "edge1", "Palermo"})
+
+	// COUNT returns the nearest members.
+	res, err = s.GEOSEARCH(sicily, core.GeoSearch{
+		FromMember: []byte("Palermo"), Radius: 200, Unit: core.GeoKilometers, Count: 2,
+	})
+	testt.NoError(t, err)
+	testt.MustEqual(t, geoMembers(res), []string{"Palermo", "edge1"})
+
+	res, err = s.GEOSEARCH(sicily, core.GeoSearch{
+		Lon: 15, Lat: 37, Radius: 500, Unit: core.GeoKilometers, Count: 1, Any: true,
+	})
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 1)
+
+	res, err = s.GEOSEARCH(sicily, core.GeoSearch{
+		Lon: 0, Lat: 0, Radius: 100, Unit: core.GeoKilometers,
+	})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.GeoPoint{})
+
+	// the whole world.
+	res, err = s.GEOSEARCH(sicily, core.GeoSearch{
+		Lon: 0, Lat: 0, Radius: 40000, Unit: core.GeoKilometers,
+	})
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 4)
+
+	_, err = s.GEOSEARCH(sicily, core.GeoSearch{
+		FromMember: []byte("Rome"), Radius: 200, Unit: core.GeoKilometers,
+	})
+	testt.MustEqual(t, err, core.ErrGeoMemberNotFound)
+
+	res, err = s.GEOSEARCH([]byte("nokey"), core.GeoSearch{
+		FromMember: []byte("Rome"), Radius: 200, Unit: core.GeoKilometers,
+	})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.GeoPoint{})
+
+	_, err = s.LPUSH([]byte("list"), []byte("a"))
+	testt.NoError(t, err)
+
+	_, err = s.GEOSEARCH([]byte("list"), core.GeoSearch{Radius: 200, Unit: core.GeoKilometers})
+	testt.MustEqual(t, err, core.ErrWrongType)
+}
+
+func TestGEOSEARCHSTORE(t *testing.T) {
+	/*
+		redis> GEOADD Sicily 13.361389 38.115556 "Palermo" 15.087269 37.502669 "Catania"
+		(integer) 2
+		redis> GEOADD Sicily 12.758489 38.788135 "edge1"   17.241510 38.788135 "edge2"
+		(integer) 2
+		redis> GEOSEARCHSTORE key1 Sicily FROMLONLAT 15 37 BYBOX 400 400 km ASC COUNT 3
+		(integer) 3
+		redis> GEOSEARCHSTORE key2 Sicily FROMLONLAT 15 37 BYBOX 400 400 km ASC COUNT 3 STOREDIST
+		(integer) 3
+		redis> ZRANGE key2 0 -1 WITHSCORES
+		1) "Catania"
+		2) "56.441257870158204"
+		3) "Palermo"
+		4) "190.44242984775784"
+		5) "edge2"
+		6) "279.7403417843143"
+		redis>
+	*/
+
+	sicily, key1, key2 := []byte("Sicily"), []byte("key1"), []byte("key2")
+	q := core.GeoSearch{
+		Lon: 15, Lat: 37, ByBox: true, Width: 400, Height: 400, Unit: core.GeoKilometers, Sort: core.GeoSortAsc, Count: 3,
+	}
+
+	s := New()
+	_, err := s.ZADD(sicily, core.ZAddOptions{}, sicilyMembers()...)
+	testt.NoError(t, err)
+
+	n, err := s.GEOSEARCHSTORE(key1, sicily, q, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	res, err := s.ZRANGE(key1, core.ZRange{Start: 0, Stop: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{
+		{Member: []byte("Palermo"), Score: 3479099956230698},
+		{Member: []byte("Catania"), Score: 3479447370796909},
+		{Member: []byte("edge2"), Score: 3481342659049484},
+	})
+
+	n, err = s.GEOSEARCHSTORE(key2, sicily, q, true)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	res, err = s.ZRANGE(key2, core.ZRange{Start: 0, Stop: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 3)
+	testt.MustEqual(t, string(res[0].Member), "Catania")
+	testt.MustEqual(t, int(res[0].Score*10000+0.5), 564413)
+
+	// src can be dst.
+	n, err = s.GEOSEARCHSTORE(key1, key1, core.GeoSearch{
+		FromMember: []byte("Catania"), Radius: 100, Unit: core.GeoKilometers,
+	}, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	res, err = s.ZRANGE(key1, core.ZRange{Start: 0, Stop: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{{Member: []byte("Catania"), Score: 3479447370796909}})
+
+	// nothing found removes dst.
+	n, err = s.GEOSEARCHSTORE(key2, []byte("nokey"), q, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	exists, err := s.EXISTS(key2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, exists, 0)
+}
+
+func sicilyMembers() []core.ZMember {
+	return []core.ZMember{
+		{Member: []byte("Palermo"), Score: core.GeoScore(13.361389, 38.115556)},
+		{Member: []byte("Catania"), Score: core.GeoScore(15.087269, 37.502669)},
+		{Member: []byte("edge1"), Score: core.GeoScore(12.758489, 38.788135)},
+		{Member: []byte("edge2"), Score: core.GeoScore(17.241510, 38.788135)},
+	}
+}
+
+func geoMembers(points []core.GeoPoint) []string {
+	res := make([]string, len(points))
+	for i, p := range points {
+		res[i] = string(p.Member)
+	}
+	return res
+}
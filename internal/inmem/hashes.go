@@ -0,0 +1,582 @@
+package inmem
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/cristaloleg/didis/internal/core"
+)
+
+// Hashes operations https://redis.io/commands/?group=hash
+
+// hash is a field to value map, fields might expire.
+// Expired fields are hidden on read and removed on write access.
+type hash struct {
+	fields map[string][]byte
+	// exp is field to unix time in milliseconds when field expires.
+	exp map[string]int64
+}
+
+func newHash() *hash {
+	return &hash{
+		fields: make(map[string][]byte),
+		exp:    make(map[string]int64),
+	}
+}
+
+func (h *hash) clone() *hash {
+	res := newHash()
+	for field, value := range h.fields {
+		res.fields[field] = bytes.Clone(value)
+	}
+	for field, at := range h.exp {
+		res.exp[field] = at
+	}
+	return res
+}
+
+// get returns value of the field, expired field is treated as missing.
+func (h *hash) get(field []byte, now int64) ([]byte, bool) {
+	value, ok := h.fields[string(field)]
+	if !ok || h.isExpired(string(field), now) {
+		return nil, false
+	}
+	return value, true
+}
+
+// set replaces value of the field and discards its expiry.
+func (h *hash) set(field, value []byte) {
+	h.fields[string(field)] = value
+	delete(h.exp, string(field))
+}
+
+func (h *hash) del(field string) {
+	delete(h.fields, field)
+	delete(h.exp, field)
+}
+
+func (h *hash) isExpired(field string, now int64) bool {
+	at, ok := h.exp[field]
+	return ok && at <= now
+}
+
+// len returns the number of not expired fields.
+func (h *hash) len(now int64) int {
+	n := len(h.fields)
+	for field := range h.exp {
+		if h.isExpired(field, now) {
+			n--
+		}
+	}
+	return n
+}
+
+// purge removes expired fields.
+func (h *hash) purge(now int64) {
+	for field := range h.exp {
+		if h.isExpired(field, now) {
+			h.del(field)
+		}
+	}
+}
+
+// sortedFields returns not expired fields in sorted order, so replies are deterministic.
+func (h *hash) sortedFields(now int64) []string {
+	res := make([]string, 0, len(h.fields))
+	for field := range h.fields {
+		if !h.isExpired(field, now) {
+			res = append(res, field)
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+func (s *Store) HDEL(key []byte, fields ...[]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok, err := s.loadHash(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	n := 0
+	for _, field := range fields {
+		if _, ok := h.fields[string(field)]; ok {
+			h.del(string(field))
+			n++
+		}
+	}
+	if len(h.fields) == 0 {
+		s.del(string(key))
+	}
+	return n, nil
+}
+
+func (s *Store) HEXISTS(key, field []byte) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h, ok, err := s.getHash(key)
+	if err != nil || !ok {
+		return false, err
+	}
+	_, ok = h.get(field, core.NowMs())
+	return ok, nil
+}
+
+func (s *Store) HEXPIRE(key []byte, seconds int64, cond core.ExpireCond, fields ...[]byte) ([]int, error) {
+	now := core.NowMs()
+	at, err := core.ExpireAtMs(now, seconds, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return s.hexpireAt(key, now, at, cond, fields)
+}
+
+func (s *Store) HEXPIREAT(key []byte, unixSeconds int64, cond core.ExpireCond, fields ...[]byte) ([]int, error) {
+	at, err := core.UnixMs(unixSeconds, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return s.hexpireAt(key, core.NowMs(), at, cond, fields)
+}
+
+func (s *Store) HEXPIRETIME(key []byte, fields ...[]byte) ([]int64, error) {
+	return s.fieldTTL(key, fields, func(ok bool, at int64) int64 {
+		return core.ExpireTimeReply(ok, at, time.Second)
+	})
+}
+
+func (s *Store) HGET(key, field []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h, ok, err := s.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	value, ok := h.get(field, core.NowMs())
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	return bytes.Clone(value), nil
+}
+
+func (s *Store) HGETALL(key []byte) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h, ok, err := s.getHash(key)
+	if err != nil || !ok {
+		return [][]byte{}, err
+	}
+
+	fields := h.sortedFields(core.NowMs())
+	res := make([][]byte, 0, 2*len(fields))
+	for _, field := range fields {
+		res = append(res, []byte(field), bytes.Clone(h.fields[field]))
+	}
+	return res, nil
+}
+
+func (s *Store) HINCRBY(key, field []byte, by int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.loadOrNewHash(key)
+	if err != nil {
+		return 0, err
+	}
+
+	num := int64(0)
+	if value, ok := h.fields[string(field)]; ok {
+		num, err = strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return 0, core.ErrHashNotInt
+		}
+	}
+	num, err = core.AddInt64(num, by)
+	if err != nil {
+		return 0, err
+	}
+
+	h.fields[string(field)] = []byte(strconv.FormatInt(num, 10))
+	s.putHash(key, h)
+	return num, nil
+}
+
+func (s *Store) HINCRBYFLOAT(key, field []byte, by float64) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.loadOrNewHash(key)
+	if err != nil {
+		return "", err
+	}
+
+	num := float64(0)
+	if value, ok := h.fields[string(field)]; ok {
+		num, err = strconv.ParseFloat(string(value), 64)
+		if err != nil {
+			return "", core.ErrHashNotFloat
+		}
+	}
+	num += by
+	if math.IsNaN(num) || math.IsInf(num, 0) {
+		return "", core.ErrIncrNaNOrInf
+	}
+
+	value := strconv.FormatFloat(num, 'f', -1, 64)
+	h.fields[string(field)] = []byte(value)
+	s.putHash(key, h)
+	return value, nil
+}
+
+func (s *Store) HKEYS(key []byte) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h, ok, err := s.getHash(key)
+	if err != nil || !ok {
+		return [][]byte{}, err
+	}
+
+	fields := h.sortedFields(core.NowMs())
+	res := make([][]byte, 0, len(fields))
+	for _, field := range fields {
+		res = append(res, []byte(field))
+	}
+	return res, nil
+}
+
+func (s *Store) HLEN(key []byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h, ok, err := s.getHash(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return h.len(core.NowMs()), nil
+}
+
+func (s *Store) HMGET(key []byte, fields ...[]byte) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h, ok, err := s.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([][]byte, len(fields))
+	if !ok {
+		return res, nil
+	}
+	now := core.NowMs()
+	for i, field := range fields {
+		value, _ := h.get(field, now)
+		res[i] = bytes.Clone(value)
+	}
+	return res, nil
+}
+
+func (s *Store) HPERSIST(key []byte, fields ...[]byte) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok, err := s.loadHash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]int, len(fields))
+	for i, field := range fields {
+		if !ok {
+			res[i] = core.FieldMissing
+			continue
+		}
+		_, hasField := h.fields[string(field)]
+		_, hasTTL := h.exp[string(field)]
+		switch {
+		case !hasField:
+			res[i] = core.FieldMissing
+		case !hasTTL:
+			res[i] = core.FieldNoExpire
+		default:
+			delete(h.exp, string(field))
+			res[i] = core.FieldUpdated
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) HPEXPIRE(key []byte, milliseconds int64, cond core.ExpireCond, fields ...[]byte) ([]int, error) {
+	now := core.NowMs()
+	at, err := core.ExpireAtMs(now, milliseconds, time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	return s.hexpireAt(key, now, at, cond, fields)
+}
+
+func (s *Store) HPEXPIREAT(key []byte, unixMilliseconds int64, cond core.ExpireCond, fields ...[]byte) ([]int, error) {
+	return s.hexpireAt(key, core.NowMs(), unixMilliseconds, cond, fields)
+}
+
+func (s *Store) HPEXPIRETIME(key []byte, fields ...[]byte) ([]int64, error) {
+	return s.fieldTTL(key, fields, func(ok bool, at int64) int64 {
+		return core.ExpireTimeReply(ok, at, time.Millisecond)
+	})
+}
+
+func (s *Store) HPTTL(key []byte, fields ...[]byte) ([]int64, error) {
+	return s.fieldTTL(key, fields, func(ok bool, at int64) int64 {
+		return core.TTLReply(ok, core.NowMs(), at, time.Millisecond)
+	})
+}
+
+func (s *Store) HRANDFIELD(key []byte, count int, withValues bool) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h, ok, err := s.getHash(key)
+	if err != nil || !ok {
+		return [][]byte{}, err
+	}
+
+	fields := h.sortedFields(core.NowMs())
+	res := [][]byte{}
+	for _, i := range core.RandIndexes(len(fields), count) {
+		res = append(res, []byte(fields[i]))
+		if withValues {
+			res = append(res, bytes.Clone(h.fields[fields[i]]))
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) HSCAN(key []byte, cursor uint64, opts core.ScanOptions) ([][]byte, uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h, ok, err := s.getHash(key)
+	if err != nil || !ok {
+		return [][]byte{}, 0, err
+	}
+
+	count := opts.Count
+	if count <= 0 {
+		count = core.DefaultScanCount
+	}
+
+	now := core.NowMs()
+	seek := string(s.cursors.Seek(key, cursor))
+	fields := scanKeys(h.fields, seek, count+1, func(field string) bool {
+		return h.isExpired(field, now)
+	})
+
+	res := [][]byte{}
+	for i, field := range fields {
+		if i == count {
+			return res, s.cursors.Next(key, []byte(field)), nil
+		}
+		if len(opts.Match) == 0 || core.Match(opts.Match, []byte(field)) {
+			res = append(res, []byte(field), bytes.Clone(h.fields[field]))
+		}
+	}
+	return res, 0, nil
+}
+
+func (s *Store) HSET(key []byte, fieldvals ...[]byte) (int, error) {
+	if len(fieldvals) == 0 || len(fieldvals)%2 == 1 {
+		return 0, fmt.Errorf("wrong number of arguments for 'hset' command")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.loadOrNewHash(key)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for i := 0; i < len(fieldvals); i += 2 {
+		if _, ok := h.fields[string(fieldvals[i])]; !ok {
+			n++
+		}
+		h.set(fieldvals[i], bytes.Clone(fieldvals[i+1]))
+	}
+	s.putHash(key, h)
+	return n, nil
+}
+
+func (s *Store) HSETNX(key, field, value []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.loadOrNewHash(key)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := h.fields[string(field)]; ok {
+		return false, nil
+	}
+
+	h.set(field, bytes.Clone(value))
+	s.putHash(key, h)
+	return true, nil
+}
+
+func (s *Store) HSTRLEN(key, field []byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h, ok, err := s.getHash(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	value, _ := h.get(field, core.NowMs())
+	return len(value), nil
+}
+
+func (s *Store) HTTL(key []byte, fields ...[]byte) ([]int64, error) {
+	return s.fieldTTL(key, fields, func(ok bool, at int64) int64 {
+		return core.TTLReply(ok, core.NowMs(), at, time.Second)
+	})
+}
+
+func (s *Store) HVALS(key []byte) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h, ok, err := s.getHash(key)
+	if err != nil || !ok {
+		return [][]byte{}, err
+	}
+
+	fields := h.sortedFields(core.NowMs())
+	res := make([][]byte, 0, len(fields))
+	for _, field := range fields {
+		res = append(res, bytes.Clone(h.fields[field]))
+	}
+	return res, nil
+}
+
+// hexpireAt sets expiry of fields to at, from is the time a relative TTL is counted from,
+// so fields are deleted only for a zero TTL or a time in the past, not when the lock was slow.
+func (s *Store) hexpireAt(key []byte, from, at int64, cond core.ExpireCond, fields [][]byte) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok, err := s.loadHash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]int, len(fields))
+	for i, field := range fields {
+		if !ok {
+			res[i] = core.FieldMissing
+			continue
+		}
+		_, hasField := h.fields[string(field)]
+		switch {
+		case !hasField:
+			res[i] = core.FieldMissing
+		case !cond.Allow(h.exp[string(field)], at):
+			res[i] = core.FieldCondNotMet
+		case at <= from:
+			h.del(string(field))
+			res[i] = core.FieldDeleted
+		default:
+			h.exp[string(field)] = at
+			res[i] = core.FieldUpdated
+		}
+	}
+	if ok && len(h.fields) == 0 {
+		s.del(string(key))
+	}
+	return res, nil
+}
+
+// fieldTTL returns reply of fn for expiry of each field, ok reports whether the field exists.
+func (s *Store) fieldTTL(key []byte, fields [][]byte, fn func(ok bool, at int64) int64) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h, ok, err := s.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := core.NowMs()
+	res := make([]int64, len(fields))
+	for i, field := range fields {
+		hasField, at := false, int64(0)
+		if ok {
+			_, hasField = h.get(field, now)
+			at = h.exp[string(field)]
+		}
+		res[i] = fn(hasField, at)
+	}
+	return res, nil
+}
+
+// getHash is like get but fails for keys that are not hashes.
+func (s *Store) getHash(key []byte) (*hash, bool, error) {
+	val, ok := s.get(key)
+	return asHash(val, ok)
+}
+
+// loadHash is like load but fails for keys that are not hashes.
+func (s *Store) loadHash(key []byte) (*hash, bool, error) {
+	val, ok := s.load(key)
+	return asHash(val, ok)
+}
+
+// loadOrNewHash is like loadHash but returns an empty hash for a missing key,
+// it must be stored with putHash.
+func (s *Store) loadOrNewHash(key []byte) (*hash, error) {
+	h, ok, err := s.loadHash(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		h = newHash()
+	}
+	return h, nil
+}
+
+// putHash stores the hash if it's new, expiry of an existing key is kept.
+func (s *Store) putHash(key []byte, h *hash) {
+	if _, ok := s.m[string(key)]; !ok {
+		s.set(string(key), h)
+	}
+}
+
+func isEmptyHash(val any, now int64) bool {
+	h, isHash := val.(*hash)
+	return isHash && h.len(now) == 0
+}
+
+func asHash(val any, ok bool) (*hash, bool, error) {
+	if !ok {
+		return nil, false, nil
+	}
+	h, isHash := val.(*hash)
+	if !isHash {
+		return nil, false, core.ErrWrongType
+	}
+	return h, true, nil
+}
@@ -0,0 +1,100 @@
+package inmem
+
+import (
+	"github.com/cristaloleg/didis/internal/core"
+)
+
+// HyperLogLog operations https://redis.io/commands/?group=hyperloglog
+
+func (s *Store) PFADD(key []byte, elements ...[]byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, _, err := s.loadHLL(key)
+	if err != nil {
+		return false, err
+	}
+
+	val, updated, err := core.HLLAdd(val, elements)
+	if err != nil {
+		return false, err
+	}
+	if updated {
+		s.put(string(key), val)
+	}
+	return updated, nil
+}
+
+func (s *Store) PFCOUNT(keys ...[]byte) (int64, error) {
+	// the cached cardinality of a single key is updated.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(keys) == 1 {
+		val, ok, err := s.loadHLL(keys[0])
+		if err != nil || !ok {
+			return 0, err
+		}
+		card, _, err := core.HLLCount(val)
+		return card, err
+	}
+
+	var regs core.HLLRegisters
+	for _, key := range keys {
+		val, ok, err := s.loadHLL(key)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		if err := regs.Merge(val); err != nil {
+			return 0, err
+		}
+	}
+	return regs.Count(), nil
+}
+
+func (s *Store) PFMERGE(dst []byte, keys ...[]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var regs core.HLLRegisters
+	dense := false
+	for _, key := range append([][]byte{dst}, keys...) {
+		val, ok, err := s.loadHLL(key)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		dense = dense || core.IsDenseHLL(val)
+		if err := regs.Merge(val); err != nil {
+			return err
+		}
+	}
+
+	val, ok, err := s.loadHLL(dst)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		val = core.NewHLL()
+	}
+	val, err = core.HLLStore(val, &regs, dense)
+	if err != nil {
+		return err
+	}
+	s.put(string(dst), val)
+	return nil
+}
+
+// loadHLL is like loadString but fails for strings that are not HyperLogLogs.
+func (s *Store) loadHLL(key []byte) ([]byte, bool, error) {
+	val, ok, err := s.loadString(key)
+	if err == nil && ok {
+		err = core.CheckHLL(val)
+	}
+	return val, ok, err
+}
@@ -0,0 +1,237 @@
+package inmem
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cristalhq/testt"
+)
+
+func TestPFADD(t *testing.T) {
+	/*
+		redis> PFADD hll a b c d e f g
+		(integer) 1
+		redis> PFCOUNT hll
+		(integer) 7
+		redis>
+	*/
+
+	hll := []byte("hll")
+
+	s := New()
+	ok, err := s.PFADD(hll, []byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e"), []byte("f"), []byte("g"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	card, err := s.PFCOUNT(hll)
+	testt.NoError(t, err)
+	testt.MustEqual(t, card, int64(7))
+
+	ok, err = s.PFADD(hll, []byte("a"), []byte("g"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	// a key without elements is still created.
+	empty := []byte("empty")
+	ok, err = s.PFADD(empty)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	val, err := s.GET(empty)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80\x7f\xff")
+
+	ok, err = s.PFADD(empty)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	_, _, err = s.SET([]byte("str"), []byte("Hello World"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	_, err = s.PFADD([]byte("str"), []byte("a"))
+	testt.MustEqual(t, err, core.ErrHLLWrongType)
+
+	_, err = s.LPUSH([]byte("list"), []byte("a"))
+	testt.NoError(t, err)
+
+	_, err = s.PFADD([]byte("list"), []byte("a"))
+	testt.MustEqual(t, err, core.ErrWrongType)
+}
+
+func TestPFCOUNT(t *testing.T) {
+	/*
+		redis> PFADD hll foo bar zap
+		(integer) 1
+		redis> PFADD hll zap zap zap
+		(integer) 0
+		redis> PFADD hll foo bar
+		(integer) 0
+		redis> PFCOUNT hll
+		(integer) 3
+		redis> PFADD some-other-hll 1 2 3
+		(integer) 1
+		redis> PFCOUNT hll some-other-hll
+		(integer) 6
+		redis>
+	*/
+
+	hll, other := []byte("hll"), []byte("some-other-hll")
+
+	s := New()
+	ok, err := s.PFADD(hll, []byte("foo"), []byte("bar"), []byte("zap"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = s.PFADD(hll, []byte("zap"), []byte("zap"), []byte("zap"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	ok, err = s.PFADD(hll, []byte("foo"), []byte("bar"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	card, err := s.PFCOUNT(hll)
+	testt.NoError(t, err)
+	testt.MustEqual(t, card, int64(3))
+
+	// the cached cardinality is stored in the value.
+	val, err := s.GET(hll)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val[8:16]), "\x03\x00\x00\x00\x00\x00\x00\x00")
+
+	ok, err = s.PFADD(other, []byte("1"), []byte("2"), []byte("3"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	card, err = s.PFCOUNT(hll, other)
+	testt.NoError(t, err)
+	testt.MustEqual(t, card, int64(6))
+
+	card, err = s.PFCOUNT(hll, []byte("nokey"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, card, int64(3))
+
+	card, err = s.PFCOUNT([]byte("nokey"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, card, int64(0))
+}
+
+func TestPFMERGE(t *testing.T) {
+	/*
+		redis> PFADD hll1 foo bar zap a
+		(integer) 1
+		redis> PFADD hll2 a b c foo
+		(integer) 1
+		redis> PFMERGE hll3 hll1 hll2
+		"OK"
+		redis> PFCOUNT hll3
+		(integer) 6
+		redis>
+	*/
+
+	hll1, hll2, hll3 := []byte("hll1"), []byte("hll2"), []byte("hll3")
+
+	s := New()
+	ok, err := s.PFADD(hll1, []byte("foo"), []byte("bar"), []byte("zap"), []byte("a"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = s.PFADD(hll2, []byte("a"), []byte("b"), []byte("c"), []byte("foo"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	err = s.PFMERGE(hll3, hll1, hll2)
+	testt.NoError(t, err)
+
+	card, err := s.PFCOUNT(hll3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, card, int64(6))
+
+	// dst is merged too.
+	err = s.PFMERGE(hll3, []byte("nokey"))
+	testt.NoError(t, err)
+
+	card, err = s.PFCOUNT(hll3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, card, int64(6))
+
+	_, _, err = s.SET([]byte("str"), []byte("Hello World"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	err = s.PFMERGE(hll3, []byte("str"))
+	testt.MustEqual(t, err, core.ErrHLLWrongType)
+}
+
+func TestHyperLogLogEncodings(t *testing.T) {
+	sparse, dense, merged := []byte("sparse"), []byte("dense"), []byte("merged")
+
+	s := New()
+	var elems [][]byte
+	for i := 0; i < 20000; i++ {
+		elems = append(elems, []byte(strconv.Itoa(i)))
+	}
+	_, err := s.PFADD(sparse, elems[:100]...)
+	testt.NoError(t, err)
+	_, err = s.PFADD(dense, elems...)
+	testt.NoError(t, err)
+
+	val, err := s.GET(sparse)
+	testt.NoError(t, err)
+	testt.MustEqual(t, val[4], byte(1))
+
+	val, err = s.GET(dense)
+	testt.NoError(t, err)
+	testt.MustEqual(t, val[4], byte(0))
+	testt.MustEqual(t, len(val), 12304)
+
+	card, err := s.PFCOUNT(sparse)
+	testt.NoError(t, err)
+	testt.MustEqual(t, card >= 99 && card <= 101, true)
+
+	card, err = s.PFCOUNT(dense)
+	testt.NoError(t, err)
+	testt.MustEqual(t, card > 19600 && card < 20400, true)
+
+	// merging sparse into dense changes nothing.
+	err = s.PFMERGE(merged, sparse, dense)
+	testt.NoError(t, err)
+
+	got, err := s.PFCOUNT(merged)
+	testt.NoError(t, err)
+	testt.MustEqual(t, got, card)
+
+	// the sparse value is the same HyperLogLog after conversion to dense.
+	card, err = s.PFCOUNT(sparse)
+	testt.NoError(t, err)
+
+	err = s.PFMERGE(sparse, merged)
+	testt.NoError(t, err)
+	err = s.PFMERGE(merged, sparse)
+	testt.NoError(t, err)
+
+	got, err = s.PFCOUNT(sparse, []byte("nokey"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, got > card, true)
+}
+
+func TestHyperLogLogCorrupted(t *testing.T) {
+	hll := []byte("hll")
+
+	s := New()
+	_, err := s.PFADD(hll, []byte("a"), []byte("b"), []byte("c"))
+	testt.NoError(t, err)
+
+	_, err = s.APPEND(hll, []byte("hello"))
+	testt.NoError(t, err)
+
+	_, err = s.PFCOUNT(hll)
+	testt.MustEqual(t, err, core.ErrHLLCorrupted)
+
+	_, err = s.SETRANGE(hll, 0, []byte("0123"))
+	testt.NoError(t, err)
+
+	_, err = s.PFCOUNT(hll)
+	testt.MustEqual(t, err, core.ErrHLLWrongType)
+}
@@ -1,20 +1,76 @@
 package inmem
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/tidwall/btree"
 )
 
 var _ core.Store = &Store{}
 
 type Store struct {
-	mu sync.RWMutex
-	m  map[string][]byte
+	mu  sync.RWMutex
+	m   map[string]any   // key to value: []byte, *deque, *hash, set or *zset.
+	exp map[string]int64 // key to unix time in milliseconds when key expires.
+
+	keys *btree.BTree // keys of m in sorted order, so SCAN doesn't walk the whole map.
+
+	cursors core.ScanCursors // cursors of SCAN, HSCAN and SSCAN commands.
 }
 
 func New() *Store {
 	return &Store{
-		m: make(map[string][]byte, 1024),
+		m:    make(map[string]any, 1024),
+		exp:  make(map[string]int64),
+		keys: btree.NewNonConcurrent(lessKey),
+	}
+}
+
+const (
+	// sweepInterval is how often expired keys are evicted.
+	sweepInterval = 100 * time.Millisecond
+	// sweepSample is how many keys with expiry are checked at once.
+	sweepSample = 20
+)
+
+// Run evicts expired keys in the background until ctx is done.
+// Expired keys are also hidden lazily on access, so this only reclaims memory.
+func (s *Store) Run(ctx context.Context) error {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			for s.sweep() {
+			}
+		}
+	}
+}
+
+// sweep checks a random sample of keys with expiry and removes expired ones.
+// Reports whether more than a quarter of the sample was expired (like Redis does).
+func (s *Store) sweep() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := core.NowMs()
+	checked, expired := 0, 0
+	for key, at := range s.exp {
+		if checked == sweepSample {
+			break
+		}
+		checked++
+		if at <= now {
+			s.del(key)
+			expired++
+		}
 	}
+	return expired > sweepSample/4
 }
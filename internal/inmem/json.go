@@ -0,0 +1,187 @@
+package inmem
+
+import (
+	"github.com/cristaloleg/didis/internal/core"
+)
+
+// JSON operations https://redis.io/commands/?group=json
+
+// jsonDoc is a JSON document in the binary encoding of core, it's never modified in place.
+type jsonDoc []byte
+
+func (s *Store) JSONARRAPPEND(key []byte, path core.JSONPath, values ...[]byte) ([]*int64, error) {
+	var res []*int64
+	err := s.updateJSON(key, func(doc []byte) (out []byte, err error) {
+		out, res, err = core.JSONArrAppend(doc, path, values...)
+		return out, err
+	})
+	return res, err
+}
+
+func (s *Store) JSONARRINSERT(key []byte, path core.JSONPath, index int64, values ...[]byte) ([]*int64, error) {
+	var res []*int64
+	err := s.updateJSON(key, func(doc []byte) (out []byte, err error) {
+		out, res, err = core.JSONArrInsert(doc, path, index, values...)
+		return out, err
+	})
+	return res, err
+}
+
+func (s *Store) JSONARRPOP(key []byte, path core.JSONPath, index int64) ([][]byte, error) {
+	var res [][]byte
+	err := s.updateJSON(key, func(doc []byte) (out []byte, err error) {
+		out, res, err = core.JSONArrPop(doc, path, index)
+		return out, err
+	})
+	return res, err
+}
+
+func (s *Store) JSONDEL(key []byte, path core.JSONPath) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok, err := s.loadJSON(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	if path.IsRoot() {
+		s.del(string(key))
+		return 1, nil
+	}
+
+	doc, n, err := core.JSONDel(doc, path)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	s.put(string(key), jsonDoc(doc))
+	return n, nil
+}
+
+func (s *Store) JSONGET(key []byte, f core.JSONFormat, paths ...core.JSONPath) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	doc, ok, err := s.getJSON(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	return core.JSONGet(doc, f, paths...)
+}
+
+func (s *Store) JSONMERGE(key []byte, path core.JSONPath, patch []byte) error {
+	return s.updateJSON(key, func(doc []byte) ([]byte, error) {
+		return core.JSONMerge(doc, path, patch)
+	})
+}
+
+func (s *Store) JSONMGET(path core.JSONPath, keys ...[]byte) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	docs := make([][]byte, len(keys))
+	for i, key := range keys {
+		// keys of other types are reported as missing.
+		docs[i], _, _ = s.getJSON(key)
+	}
+	return core.JSONMGet(docs, path), nil
+}
+
+func (s *Store) JSONNUMINCRBY(key []byte, path core.JSONPath, by []byte) ([]byte, error) {
+	var res []byte
+	err := s.updateJSON(key, func(doc []byte) (out []byte, err error) {
+		out, res, err = core.JSONNumIncrBy(doc, path, by)
+		return out, err
+	})
+	return res, err
+}
+
+func (s *Store) JSONOBJKEYS(key []byte, path core.JSONPath) ([][][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	doc, ok, err := s.getJSON(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	return core.JSONObjKeys(doc, path)
+}
+
+func (s *Store) JSONSET(key []byte, path core.JSONPath, value []byte, opts core.JSONSetOptions) (bool, error) {
+	set := false
+	err := s.updateJSON(key, func(doc []byte) ([]byte, error) {
+		out, err := core.JSONSet(doc, path, value, opts)
+		set = out != nil
+		return out, err
+	})
+	return set, err
+}
+
+func (s *Store) JSONSTRAPPEND(key []byte, path core.JSONPath, value []byte) ([]*int64, error) {
+	var res []*int64
+	err := s.updateJSON(key, func(doc []byte) (out []byte, err error) {
+		out, res, err = core.JSONStrAppend(doc, path, value)
+		return out, err
+	})
+	return res, err
+}
+
+func (s *Store) JSONTYPE(key []byte, path core.JSONPath) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	doc, ok, err := s.getJSON(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	return core.JSONType(doc, path), nil
+}
+
+// updateJSON replaces the document of the key with the result of fn unless it's nil,
+// fn gets nil for a missing key. Expiry of the key is kept.
+func (s *Store) updateJSON(key []byte, fn func(doc []byte) ([]byte, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, _, err := s.loadJSON(key)
+	if err != nil {
+		return err
+	}
+	doc, err = fn(doc)
+	if err != nil || doc == nil {
+		return err
+	}
+	s.put(string(key), jsonDoc(doc))
+	return nil
+}
+
+// getJSON is like get but fails for keys that are not JSON documents.
+func (s *Store) getJSON(key []byte) ([]byte, bool, error) {
+	val, ok := s.get(key)
+	return asJSON(val, ok)
+}
+
+// loadJSON is like load but fails for keys that are not JSON documents.
+func (s *Store) loadJSON(key []byte) ([]byte, bool, error) {
+	val, ok := s.load(key)
+	return asJSON(val, ok)
+}
+
+func asJSON(val any, ok bool) ([]byte, bool, error) {
+	if !ok {
+		return nil, false, nil
+	}
+	doc, isDoc := val.(jsonDoc)
+	if !isDoc {
+		return nil, false, core.ErrWrongType
+	}
+	return doc, true, nil
+}
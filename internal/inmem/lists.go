@@ -0,0 +1,331 @@
+package inmem
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/cristaloleg/didis/internal/core"
+)
+
+// Lists operations https://redis.io/commands/?group=list
+
+func (s *Store) LINDEX(key []byte, index int) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	l, ok, err := s.getList(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+
+	i, ok := core.ListIndex(index, l.Len())
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	return bytes.Clone(l.At(i)), nil
+}
+
+func (s *Store) LINSERT(key []byte, side core.ListSide, pivot, element []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok, err := s.loadList(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	elems := l.Slice(0, l.Len())
+	for i, elem := range elems {
+		if !bytes.Equal(elem, pivot) {
+			continue
+		}
+		if side == core.ListRight {
+			i++
+		}
+		elems = append(elems[:i], append([][]byte{bytes.Clone(element)}, elems[i:]...)...)
+		s.put(string(key), newDeque(elems...))
+		return len(elems), nil
+	}
+	return -1, nil
+}
+
+func (s *Store) LLEN(key []byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	l, ok, err := s.getList(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return l.Len(), nil
+}
+
+func (s *Store) LMOVE(src, dst []byte, from, to core.ListSide) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok, err := s.loadList(src)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	// destination is checked first, so nothing is popped on error.
+	if _, _, err := s.loadList(dst); err != nil {
+		return nil, err
+	}
+
+	elem := s.pop(src, l, from, 1)[0]
+	s.push(dst, to, elem)
+	return bytes.Clone(elem), nil
+}
+
+func (s *Store) LPOP(key []byte, count int) ([][]byte, error) {
+	return s.popGeneric(key, core.ListLeft, count)
+}
+
+func (s *Store) LPOS(key, element []byte, opts core.LPosOptions) ([]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	l, ok, err := s.getList(key)
+	if err != nil || !ok {
+		return []int{}, err
+	}
+
+	n := l.Len()
+	rank, step, i := opts.Rank, 1, 0
+	if rank < 0 {
+		rank, step, i = -rank, -1, n-1
+	}
+	rank = max(rank, 1)
+
+	res := []int{}
+	for checked := 0; i >= 0 && i < n; i, checked = i+step, checked+1 {
+		if opts.MaxLen != 0 && checked == opts.MaxLen {
+			break
+		}
+		if !bytes.Equal(l.At(i), element) {
+			continue
+		}
+		if rank--; rank > 0 {
+			continue
+		}
+		res = append(res, i)
+		if len(res) == opts.Count {
+			break
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) LPUSH(key []byte, elements ...[]byte) (int, error) {
+	return s.pushGeneric(key, core.ListLeft, false, elements)
+}
+
+func (s *Store) LPUSHX(key []byte, elements ...[]byte) (int, error) {
+	return s.pushGeneric(key, core.ListLeft, true, elements)
+}
+
+func (s *Store) LRANGE(key []byte, start, stop int) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	l, ok, err := s.getList(key)
+	if err != nil || !ok {
+		return [][]byte{}, err
+	}
+
+	from, to := core.ListRange(start, stop, l.Len())
+	res := l.Slice(from, to)
+	for i := range res {
+		res[i] = bytes.Clone(res[i])
+	}
+	return res, nil
+}
+
+func (s *Store) LREM(key []byte, count int, element []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok, err := s.loadList(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	elems := l.Slice(0, l.Len())
+	if count < 0 {
+		slices.Reverse(elems)
+	}
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+
+	removed := 0
+	res := elems[:0]
+	for _, elem := range elems {
+		if (limit == 0 || removed < limit) && bytes.Equal(elem, element) {
+			removed++
+			continue
+		}
+		res = append(res, elem)
+	}
+	if count < 0 {
+		slices.Reverse(res)
+	}
+
+	if len(res) == 0 {
+		s.del(string(key))
+	} else {
+		s.put(string(key), newDeque(res...))
+	}
+	return removed, nil
+}
+
+func (s *Store) LSET(key []byte, index int, element []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok, err := s.loadList(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return core.ErrKeyNotFound
+	}
+
+	i, ok := core.ListIndex(index, l.Len())
+	if !ok {
+		return core.ErrIndexOutOfRange
+	}
+	l.Set(i, bytes.Clone(element))
+	return nil
+}
+
+func (s *Store) LTRIM(key []byte, start, stop int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok, err := s.loadList(key)
+	if err != nil || !ok {
+		return err
+	}
+
+	n := l.Len()
+	from, to := core.ListRange(start, stop, n)
+	if from == to {
+		s.del(string(key))
+		return nil
+	}
+	for i := 0; i < from; i++ {
+		l.PopFront()
+	}
+	for i := to; i < n; i++ {
+		l.PopBack()
+	}
+	return nil
+}
+
+func (s *Store) RPOP(key []byte, count int) ([][]byte, error) {
+	return s.popGeneric(key, core.ListRight, count)
+}
+
+func (s *Store) RPUSH(key []byte, elements ...[]byte) (int, error) {
+	return s.pushGeneric(key, core.ListRight, false, elements)
+}
+
+func (s *Store) RPUSHX(key []byte, elements ...[]byte) (int, error) {
+	return s.pushGeneric(key, core.ListRight, true, elements)
+}
+
+func (s *Store) pushGeneric(key []byte, side core.ListSide, onlyExisting bool, elements [][]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok, err := s.loadList(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok && onlyExisting {
+		return 0, nil
+	}
+
+	for _, elem := range elements {
+		s.push(key, side, bytes.Clone(elem))
+	}
+	return s.m[string(key)].(*deque).Len(), nil
+}
+
+func (s *Store) popGeneric(key []byte, side core.ListSide, count int) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok, err := s.loadList(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	return s.pop(key, l, side, count), nil
+}
+
+// push adds element to the list, creating it if needed.
+// Must be called with write lock held and the key must be a list or missing.
+func (s *Store) push(key []byte, side core.ListSide, elem []byte) {
+	l, ok := s.m[string(key)].(*deque)
+	if !ok {
+		l = newDeque()
+		s.put(string(key), l)
+	}
+	if side == core.ListLeft {
+		l.PushFront(elem)
+	} else {
+		l.PushBack(elem)
+	}
+}
+
+// pop removes up to count elements from the list, empty list is removed.
+// Must be called with write lock held.
+func (s *Store) pop(key []byte, l *deque, side core.ListSide, count int) [][]byte {
+	res := make([][]byte, 0, min(count, l.Len()))
+	for len(res) < count && l.Len() > 0 {
+		if side == core.ListLeft {
+			res = append(res, l.PopFront())
+		} else {
+			res = append(res, l.PopBack())
+		}
+	}
+	if l.Len() == 0 {
+		s.del(string(key))
+	}
+	return res
+}
+
+// getList is like get but fails for keys that are not lists.
+func (s *Store) getList(key []byte) (*deque, bool, error) {
+	val, ok := s.get(key)
+	return asList(val, ok)
+}
+
+// loadList is like load but fails for keys that are not lists.
+func (s *Store) loadList(key []byte) (*deque, bool, error) {
+	val, ok := s.load(key)
+	return asList(val, ok)
+}
+
+func asList(val any, ok bool) (*deque, bool, error) {
+	if !ok {
+		return nil, false, nil
+	}
+	l, isList := val.(*deque)
+	if !isList {
+		return nil, false, core.ErrWrongType
+	}
+	return l, true, nil
+}
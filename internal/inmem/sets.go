@@ -0,0 +1,323 @@
+package inmem
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/cristaloleg/didis/internal/core"
+)
+
+// Sets operations https://redis.io/commands/?group=set
+
+// set is a set of members.
+type set map[string]struct{}
+
+func (st set) clone() set {
+	res := make(set, len(st))
+	for member := range st {
+		res[member] = struct{}{}
+	}
+	return res
+}
+
+// sorted returns members in sorted order, so replies are deterministic.
+func (st set) sorted() [][]byte {
+	res := make([][]byte, 0, len(st))
+	for member := range st {
+		res = append(res, []byte(member))
+	}
+	slices.SortFunc(res, bytes.Compare)
+	return res
+}
+
+func (s *Store) SADD(key []byte, members ...[]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok, err := s.loadSet(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		st = set{}
+		s.set(string(key), st)
+	}
+
+	n := 0
+	for _, member := range members {
+		if _, ok := st[string(member)]; !ok {
+			st[string(member)] = struct{}{}
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) SCARD(key []byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, _, err := s.getSet(key)
+	return len(st), err
+}
+
+func (s *Store) SDIFF(keys ...[]byte) ([][]byte, error) {
+	return s.algebraGeneric(keys, core.SetDiff)
+}
+
+func (s *Store) SDIFFSTORE(dst []byte, keys ...[]byte) (int, error) {
+	return s.storeGeneric(dst, keys, core.SetDiff)
+}
+
+func (s *Store) SINTER(keys ...[]byte) ([][]byte, error) {
+	return s.algebraGeneric(keys, func(sets ...[][]byte) [][]byte {
+		return core.SetInter(0, sets...)
+	})
+}
+
+func (s *Store) SINTERCARD(limit int, keys ...[]byte) (int, error) {
+	res, err := s.algebraGeneric(keys, func(sets ...[][]byte) [][]byte {
+		return core.SetInter(limit, sets...)
+	})
+	return len(res), err
+}
+
+func (s *Store) SINTERSTORE(dst []byte, keys ...[]byte) (int, error) {
+	return s.storeGeneric(dst, keys, func(sets ...[][]byte) [][]byte {
+		return core.SetInter(0, sets...)
+	})
+}
+
+func (s *Store) SISMEMBER(key, member []byte) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, _, err := s.getSet(key)
+	_, ok := st[string(member)]
+	return ok, err
+}
+
+func (s *Store) SMEMBERS(key []byte) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, _, err := s.getSet(key)
+	if err != nil {
+		return nil, err
+	}
+	return st.sorted(), nil
+}
+
+func (s *Store) SMISMEMBER(key []byte, members ...[]byte) ([]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, _, err := s.getSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]bool, len(members))
+	for i, member := range members {
+		_, res[i] = st[string(member)]
+	}
+	return res, nil
+}
+
+func (s *Store) SMOVE(src, dst, member []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from, ok, err := s.loadSet(src)
+	if err != nil {
+		return false, err
+	}
+	to, hasDst, err := s.loadSet(dst)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+	if _, ok := from[string(member)]; !ok {
+		return false, nil
+	}
+	if string(src) == string(dst) {
+		return true, nil
+	}
+
+	delete(from, string(member))
+	if len(from) == 0 {
+		s.del(string(src))
+	}
+	if !hasDst {
+		to = set{}
+		s.set(string(dst), to)
+	}
+	to[string(member)] = struct{}{}
+	return true, nil
+}
+
+func (s *Store) SPOP(key []byte, count int) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok, err := s.loadSet(key)
+	if err != nil || !ok {
+		return [][]byte{}, err
+	}
+
+	members := st.sorted()
+	res := [][]byte{}
+	for _, i := range core.RandIndexes(len(members), count) {
+		delete(st, string(members[i]))
+		res = append(res, members[i])
+	}
+	if len(st) == 0 {
+		s.del(string(key))
+	}
+	return res, nil
+}
+
+func (s *Store) SRANDMEMBER(key []byte, count int) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, ok, err := s.getSet(key)
+	if err != nil || !ok {
+		return [][]byte{}, err
+	}
+
+	members := st.sorted()
+	res := [][]byte{}
+	for _, i := range core.RandIndexes(len(members), count) {
+		res = append(res, members[i])
+	}
+	return res, nil
+}
+
+func (s *Store) SREM(key []byte, members ...[]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok, err := s.loadSet(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	n := 0
+	for _, member := range members {
+		if _, ok := st[string(member)]; ok {
+			delete(st, string(member))
+			n++
+		}
+	}
+	if len(st) == 0 {
+		s.del(string(key))
+	}
+	return n, nil
+}
+
+func (s *Store) SSCAN(key []byte, cursor uint64, opts core.ScanOptions) ([][]byte, uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, ok, err := s.getSet(key)
+	if err != nil || !ok {
+		return [][]byte{}, 0, err
+	}
+
+	count := opts.Count
+	if count <= 0 {
+		count = core.DefaultScanCount
+	}
+
+	seek := string(s.cursors.Seek(key, cursor))
+	res := [][]byte{}
+	for i, member := range scanKeys(st, seek, count+1, nil) {
+		if i == count {
+			return res, s.cursors.Next(key, []byte(member)), nil
+		}
+		if len(opts.Match) == 0 || core.Match(opts.Match, []byte(member)) {
+			res = append(res, []byte(member))
+		}
+	}
+	return res, 0, nil
+}
+
+func (s *Store) SUNION(keys ...[]byte) ([][]byte, error) {
+	return s.algebraGeneric(keys, core.SetUnion)
+}
+
+func (s *Store) SUNIONSTORE(dst []byte, keys ...[]byte) (int, error) {
+	return s.storeGeneric(dst, keys, core.SetUnion)
+}
+
+func (s *Store) algebraGeneric(keys [][]byte, fn func(sets ...[][]byte) [][]byte) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sets, err := s.getSets(keys)
+	if err != nil {
+		return nil, err
+	}
+	return fn(sets...), nil
+}
+
+func (s *Store) storeGeneric(dst []byte, keys [][]byte, fn func(sets ...[][]byte) [][]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sets, err := s.getSets(keys)
+	if err != nil {
+		return 0, err
+	}
+	res := fn(sets...)
+
+	s.del(string(dst))
+	if len(res) == 0 {
+		return 0, nil
+	}
+	st := make(set, len(res))
+	for _, member := range res {
+		st[string(member)] = struct{}{}
+	}
+	s.set(string(dst), st)
+	return len(res), nil
+}
+
+// getSets returns sorted members of each key, missing keys are empty sets.
+func (s *Store) getSets(keys [][]byte) ([][][]byte, error) {
+	res := make([][][]byte, len(keys))
+	for i, key := range keys {
+		st, _, err := s.getSet(key)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = st.sorted()
+	}
+	return res, nil
+}
+
+// getSet is like get but fails for keys that are not sets.
+func (s *Store) getSet(key []byte) (set, bool, error) {
+	val, ok := s.get(key)
+	return asSet(val, ok)
+}
+
+// loadSet is like load but fails for keys that are not sets.
+func (s *Store) loadSet(key []byte) (set, bool, error) {
+	val, ok := s.load(key)
+	return asSet(val, ok)
+}
+
+func asSet(val any, ok bool) (set, bool, error) {
+	if !ok {
+		return nil, false, nil
+	}
+	st, isSet := val.(set)
+	if !isSet {
+		return nil, false, core.ErrWrongType
+	}
+	return st, true, nil
+}
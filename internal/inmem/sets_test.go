@@ -0,0 +1,532 @@
+package inmem
+
+import (
+	"testing"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cristalhq/testt"
+)
+
+func TestSADD(t *testing.T) {
+	/*
+		redis> SADD myset "Hello"
+		(integer) 1
+		redis> SADD myset "World"
+		(integer) 1
+		redis> SADD myset "World"
+		(integer) 0
+		redis> SMEMBERS myset
+		1) "Hello"
+		2) "World"
+		redis>
+	*/
+
+	myset := []byte("myset")
+
+	s := New()
+	n, err := s.SADD(myset, []byte("Hello"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.SADD(myset, []byte("World"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.SADD(myset, []byte("World"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	res, err := s.SMEMBERS(myset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("Hello"), []byte("World")})
+
+	_, _, err = s.SET([]byte("mystring"), []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+	_, err = s.SADD([]byte("mystring"), []byte("World"))
+	testt.MustEqual(t, err, core.ErrWrongType)
+}
+
+func TestSCARD(t *testing.T) {
+	/*
+		redis> SADD myset "Hello"
+		(integer) 1
+		redis> SADD myset "World"
+		(integer) 1
+		redis> SCARD myset
+		(integer) 2
+		redis>
+	*/
+
+	myset := []byte("myset")
+
+	s := New()
+	n, err := s.SADD(myset, []byte("Hello"), []byte("World"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	n, err = s.SCARD(myset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	n, err = s.SCARD([]byte("nokey"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+}
+
+func TestSDIFF(t *testing.T) {
+	/*
+		redis> SADD key1 "a" "b" "c"
+		(integer) 3
+		redis> SADD key2 "c" "d" "e"
+		(integer) 3
+		redis> SDIFF key1 key2
+		1) "a"
+		2) "b"
+		redis> SDIFFSTORE key key1 key2
+		(integer) 2
+		redis> SMEMBERS key
+		1) "a"
+		2) "b"
+		redis>
+	*/
+
+	key, key1, key2 := []byte("key"), []byte("key1"), []byte("key2")
+
+	s := New()
+	n, err := s.SADD(key1, []byte("a"), []byte("b"), []byte("c"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	n, err = s.SADD(key2, []byte("c"), []byte("d"), []byte("e"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	res, err := s.SDIFF(key1, key2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("a"), []byte("b")})
+
+	n, err = s.SDIFFSTORE(key, key1, key2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err = s.SMEMBERS(key)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("a"), []byte("b")})
+
+	// empty result removes destination.
+	n, err = s.SDIFFSTORE(key, key1, key1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	typ, err := s.TYPE(key)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "none")
+}
+
+func TestSINTER(t *testing.T) {
+	/*
+		redis> SADD key1 "a" "b" "c"
+		(integer) 3
+		redis> SADD key2 "c" "d" "e"
+		(integer) 3
+		redis> SINTER key1 key2
+		1) "c"
+		redis> SINTERSTORE key key1 key2
+		(integer) 1
+		redis> SMEMBERS key
+		1) "c"
+		redis>
+	*/
+
+	key, key1, key2 := []byte("key"), []byte("key1"), []byte("key2")
+
+	s := New()
+	n, err := s.SADD(key1, []byte("a"), []byte("b"), []byte("c"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	n, err = s.SADD(key2, []byte("c"), []byte("d"), []byte("e"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	res, err := s.SINTER(key1, key2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("c")})
+
+	n, err = s.SINTERSTORE(key, key1, key2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	res, err = s.SMEMBERS(key)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("c")})
+
+	res, err = s.SINTER(key1, []byte("nokey"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{})
+}
+
+func TestSINTERCARD(t *testing.T) {
+	/*
+		redis> SADD key1 "a" "b" "c" "d"
+		(integer) 4
+		redis> SADD key2 "c" "d" "e"
+		(integer) 3
+		redis> SINTERCARD 2 key1 key2
+		(integer) 2
+		redis> SINTERCARD 2 key1 key2 LIMIT 1
+		(integer) 1
+		redis>
+	*/
+
+	key1, key2 := []byte("key1"), []byte("key2")
+
+	s := New()
+	n, err := s.SADD(key1, []byte("a"), []byte("b"), []byte("c"), []byte("d"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 4)
+
+	n, err = s.SADD(key2, []byte("c"), []byte("d"), []byte("e"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	n, err = s.SINTERCARD(0, key1, key2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	n, err = s.SINTERCARD(1, key1, key2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+}
+
+func TestSISMEMBER(t *testing.T) {
+	/*
+		redis> SADD myset "one"
+		(integer) 1
+		redis> SISMEMBER myset "one"
+		(integer) 1
+		redis> SISMEMBER myset "two"
+		(integer) 0
+		redis>
+	*/
+
+	myset := []byte("myset")
+
+	s := New()
+	n, err := s.SADD(myset, []byte("one"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	ok, err := s.SISMEMBER(myset, []byte("one"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = s.SISMEMBER(myset, []byte("two"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+}
+
+func TestSMISMEMBER(t *testing.T) {
+	/*
+		redis> SADD myset "one"
+		(integer) 1
+		redis> SADD myset "one"
+		(integer) 0
+		redis> SMISMEMBER myset "one" "notamember"
+		1) (integer) 1
+		2) (integer) 0
+		redis>
+	*/
+
+	myset := []byte("myset")
+
+	s := New()
+	n, err := s.SADD(myset, []byte("one"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.SADD(myset, []byte("one"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	res, err := s.SMISMEMBER(myset, []byte("one"), []byte("notamember"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []bool{true, false})
+}
+
+func TestSMOVE(t *testing.T) {
+	/*
+		redis> SADD myset "one"
+		(integer) 1
+		redis> SADD myset "two"
+		(integer) 1
+		redis> SADD myotherset "three"
+		(integer) 1
+		redis> SMOVE myset myotherset "two"
+		(integer) 1
+		redis> SMEMBERS myset
+		1) "one"
+		redis> SMEMBERS myotherset
+		1) "three"
+		2) "two"
+		redis>
+	*/
+
+	myset, myotherset := []byte("myset"), []byte("myotherset")
+
+	s := New()
+	n, err := s.SADD(myset, []byte("one"), []byte("two"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	n, err = s.SADD(myotherset, []byte("three"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	ok, err := s.SMOVE(myset, myotherset, []byte("two"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	res, err := s.SMEMBERS(myset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("one")})
+
+	res, err = s.SMEMBERS(myotherset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("three"), []byte("two")})
+
+	ok, err = s.SMOVE(myset, myotherset, []byte("four"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	// moving the last member removes the source.
+	ok, err = s.SMOVE(myset, myotherset, []byte("one"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	typ, err := s.TYPE(myset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "none")
+}
+
+func TestSPOP(t *testing.T) {
+	/*
+		redis> SADD myset "one"
+		(integer) 1
+		redis> SADD myset "two"
+		(integer) 1
+		redis> SADD myset "three"
+		(integer) 1
+		redis> SPOP myset
+		"one"
+		redis> SMEMBERS myset
+		1) "three"
+		2) "two"
+		redis> SADD myset "four"
+		(integer) 1
+		redis> SADD myset "five"
+		(integer) 1
+		redis> SPOP myset 3
+		1) "three"
+		2) "four"
+		3) "two"
+		redis> SMEMBERS myset
+		1) "five"
+		redis>
+	*/
+
+	myset := []byte("myset")
+
+	s := New()
+	n, err := s.SADD(myset, []byte("one"), []byte("two"), []byte("three"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	res, err := s.SPOP(myset, 1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 1)
+
+	n, err = s.SCARD(myset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	n, err = s.SADD(myset, []byte("four"), []byte("five"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err = s.SPOP(myset, 3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 3)
+
+	n, err = s.SCARD(myset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	res, err = s.SPOP(myset, 10)
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 1)
+
+	typ, err := s.TYPE(myset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "none")
+}
+
+func TestSRANDMEMBER(t *testing.T) {
+	/*
+		redis> SADD myset one two three
+		(integer) 3
+		redis> SRANDMEMBER myset
+		"one"
+		redis> SRANDMEMBER myset 2
+		1) "one"
+		2) "three"
+		redis> SRANDMEMBER myset -5
+		1) "one"
+		2) "one"
+		3) "one"
+		4) "two"
+		5) "one"
+		redis>
+	*/
+
+	myset := []byte("myset")
+
+	s := New()
+	n, err := s.SADD(myset, []byte("one"), []byte("two"), []byte("three"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	res, err := s.SRANDMEMBER(myset, 1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 1)
+
+	res, err = s.SRANDMEMBER(myset, 2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 2)
+	testt.MustEqual(t, string(res[0]) != string(res[1]), true)
+
+	res, err = s.SRANDMEMBER(myset, 5)
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 3)
+
+	res, err = s.SRANDMEMBER(myset, -5)
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 5)
+
+	n, err = s.SCARD(myset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+}
+
+func TestSREM(t *testing.T) {
+	/*
+		redis> SADD myset "one"
+		(integer) 1
+		redis> SADD myset "two"
+		(integer) 1
+		redis> SADD myset "three"
+		(integer) 1
+		redis> SREM myset "one"
+		(integer) 1
+		redis> SREM myset "four"
+		(integer) 0
+		redis> SMEMBERS myset
+		1) "three"
+		2) "two"
+		redis>
+	*/
+
+	myset := []byte("myset")
+
+	s := New()
+	n, err := s.SADD(myset, []byte("one"), []byte("two"), []byte("three"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	n, err = s.SREM(myset, []byte("one"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.SREM(myset, []byte("four"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	res, err := s.SMEMBERS(myset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("three"), []byte("two")})
+}
+
+func TestSSCAN(t *testing.T) {
+	myset := []byte("myset")
+
+	s := New()
+	n, err := s.SADD(myset, []byte("a1"), []byte("a2"), []byte("b1"), []byte("b2"), []byte("c1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 5)
+
+	var all [][]byte
+	var cursor uint64
+	for {
+		res, next, err := s.SSCAN(myset, cursor, core.ScanOptions{Count: 2})
+		testt.NoError(t, err)
+		all = append(all, res...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	testt.MustEqual(t, len(all), 5)
+
+	res, _, err := s.SSCAN(myset, 0, core.ScanOptions{Match: []byte("a*"), Count: 10})
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 2)
+}
+
+func TestSUNION(t *testing.T) {
+	/*
+		redis> SADD key1 "a" "b" "c"
+		(integer) 3
+		redis> SADD key2 "c" "d" "e"
+		(integer) 3
+		redis> SUNION key1 key2
+		1) "a"
+		2) "b"
+		3) "c"
+		4) "d"
+		5) "e"
+		redis> SUNIONSTORE key key1 key2
+		(integer) 5
+		redis>
+	*/
+
+	key, key1, key2 := []byte("key"), []byte("key1"), []byte("key2")
+	want := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}
+
+	s := New()
+	n, err := s.SADD(key1, []byte("a"), []byte("b"), []byte("c"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	n, err = s.SADD(key2, []byte("c"), []byte("d"), []byte("e"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	res, err := s.SUNION(key1, key2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, want)
+
+	// destination can be one of the sources.
+	n, err = s.SUNIONSTORE(key1, key1, key2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 5)
+
+	res, err = s.SMEMBERS(key1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, want)
+
+	_, _, err = s.SET(key, []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+	_, err = s.SUNION(key1, key)
+	testt.MustEqual(t, err, core.ErrWrongType)
+}
@@ -0,0 +1,220 @@
+package inmem
+
+import (
+	"github.com/cristaloleg/didis/internal/core"
+)
+
+// Count-Min sketch operations https://redis.io/commands/?group=cms
+// Top-K operations https://redis.io/commands/?group=topk
+
+// cms is a Count-Min sketch with its rows.
+type cms struct {
+	sketch *core.CountMinSketch
+	rows   filterArrays
+}
+
+// topk is a Top-K with its rows.
+type topk struct {
+	top  *core.TopK
+	rows filterArrays
+}
+
+func (s *Store) CMSINCRBY(key []byte, items [][]byte, incrs []int64) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.loadCMS(key)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, core.ErrCMSNotFound
+	}
+
+	// items incremented before an overflow stay incremented, like in RedisBloom.
+	res := make([]int64, len(items))
+	for i, item := range items {
+		if res[i], err = c.sketch.IncrBy(&c.rows, item, incrs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) CMSINIT(key []byte, width, depth int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.load(key); ok {
+		return core.ErrCMSExists
+	}
+	s.set(string(key), &cms{sketch: core.NewCountMinSketch(width, depth)})
+	return nil
+}
+
+func (s *Store) CMSMERGE(dst []byte, keys [][]byte, weights []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.loadCMS(dst)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return core.ErrCMSNotFound
+	}
+
+	srcs := make([]*core.CountMinSketch, len(keys))
+	srcRows := make([]core.FilterStorage, len(keys))
+	for i, key := range keys {
+		src, err := s.loadCMS(key)
+		if err != nil {
+			return err
+		}
+		if src == nil {
+			return core.ErrCMSNotFound
+		}
+		srcs[i], srcRows[i] = src.sketch, &src.rows
+	}
+
+	return c.sketch.Merge(&c.rows, srcs, srcRows, weights)
+}
+
+func (s *Store) CMSQUERY(key []byte, items ...[]byte) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, err := s.getCMS(key)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, core.ErrCMSNotFound
+	}
+	res := make([]int64, len(items))
+	for i, item := range items {
+		res[i], _ = c.sketch.Query(&c.rows, item)
+	}
+	return res, nil
+}
+
+func (s *Store) TOPKINCRBY(key []byte, items [][]byte, incrs []int64) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.loadTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, core.ErrTopKNotFound
+	}
+	res := make([][]byte, len(items))
+	for i, item := range items {
+		res[i], _ = t.top.IncrBy(&t.rows, item, incrs[i])
+	}
+	return res, nil
+}
+
+func (s *Store) TOPKINFO(key []byte) (core.TopKOptions, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, err := s.getTopK(key)
+	if err != nil {
+		return core.TopKOptions{}, err
+	}
+	if t == nil {
+		return core.TopKOptions{}, core.ErrTopKNotFound
+	}
+	return t.top.Info(), nil
+}
+
+func (s *Store) TOPKLIST(key []byte) ([]core.TopKEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, err := s.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, core.ErrTopKNotFound
+	}
+	return t.top.List(), nil
+}
+
+func (s *Store) TOPKQUERY(key []byte, items ...[]byte) ([]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, err := s.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, core.ErrTopKNotFound
+	}
+	res := make([]bool, len(items))
+	for i, item := range items {
+		res[i] = t.top.Query(item)
+	}
+	return res, nil
+}
+
+func (s *Store) TOPKRESERVE(key []byte, opts core.TopKOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.load(key); ok {
+		return core.ErrTopKExists
+	}
+	s.set(string(key), &topk{top: core.NewTopK(opts)})
+	return nil
+}
+
+// getCMS is like get but fails for keys that are not Count-Min sketches, the sketch is nil for a missing key.
+func (s *Store) getCMS(key []byte) (*cms, error) {
+	val, ok := s.get(key)
+	return asCMS(val, ok)
+}
+
+// loadCMS is like load but fails for keys that are not Count-Min sketches, the sketch is nil for a missing key.
+func (s *Store) loadCMS(key []byte) (*cms, error) {
+	val, ok := s.load(key)
+	return asCMS(val, ok)
+}
+
+func asCMS(val any, ok bool) (*cms, error) {
+	if !ok {
+		return nil, nil
+	}
+	c, isCMS := val.(*cms)
+	if !isCMS {
+		return nil, core.ErrWrongType
+	}
+	return c, nil
+}
+
+// getTopK is like get but fails for keys that are not Top-K lists, the top is nil for a missing key.
+func (s *Store) getTopK(key []byte) (*topk, error) {
+	val, ok := s.get(key)
+	return asTopK(val, ok)
+}
+
+// loadTopK is like load but fails for keys that are not Top-K lists, the top is nil for a missing key.
+func (s *Store) loadTopK(key []byte) (*topk, error) {
+	val, ok := s.load(key)
+	return asTopK(val, ok)
+}
+
+func asTopK(val any, ok bool) (*topk, error) {
+	if !ok {
+		return nil, nil
+	}
+	t, isTopK := val.(*topk)
+	if !isTopK {
+		return nil, core.ErrWrongType
+	}
+	return t, nil
+}
@@ -0,0 +1,194 @@
+package inmem
+
+import (
+	"testing"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cristalhq/testt"
+)
+
+func TestCMSINCRBY(t *testing.T) {
+	/*
+		redis> CMS.INITBYDIM test 2000 5
+		OK
+		redis> CMS.INCRBY test foo 10 bar 42
+		1) (integer) 10
+		2) (integer) 42
+		redis> CMS.QUERY test foo bar
+		1) (integer) 10
+		2) (integer) 42
+		redis>
+	*/
+
+	test := []byte("test")
+	foo, bar := []byte("foo"), []byte("bar")
+
+	s := New()
+	err := s.CMSINIT(test, 2000, 5)
+	testt.NoError(t, err)
+
+	err = s.CMSINIT(test, 2000, 5)
+	testt.MustEqual(t, err, core.ErrCMSExists)
+
+	res, err := s.CMSINCRBY(test, [][]byte{foo, bar}, []int64{10, 42})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{10, 42})
+
+	res, err = s.CMSQUERY(test, foo, bar, []byte("baz"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{10, 42, 0})
+
+	_, err = s.CMSINCRBY(test, [][]byte{foo}, []int64{1 << 32})
+	testt.MustEqual(t, err, core.ErrCMSOverflow)
+
+	_, err = s.CMSQUERY([]byte("nokey"), foo)
+	testt.MustEqual(t, err, core.ErrCMSNotFound)
+
+	typ, err := s.TYPE(test)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "CMSk-type")
+}
+
+func TestCMSMERGE(t *testing.T) {
+	/*
+		redis> CMS.INITBYDIM test1 100 5
+		OK
+		redis> CMS.INITBYDIM test2 100 5
+		OK
+		redis> CMS.INITBYDIM dest 100 5
+		OK
+		redis> CMS.INCRBY test1 foo 1
+		1) (integer) 1
+		redis> CMS.INCRBY test2 foo 2
+		1) (integer) 2
+		redis> CMS.MERGE dest 2 test1 test2 WEIGHTS 1 3
+		OK
+		redis> CMS.QUERY dest foo
+		1) (integer) 7
+		redis>
+	*/
+
+	test1, test2, dest := []byte("test1"), []byte("test2"), []byte("dest")
+	foo := []byte("foo")
+
+	s := New()
+	for _, key := range [][]byte{test1, test2, dest} {
+		err := s.CMSINIT(key, 100, 5)
+		testt.NoError(t, err)
+	}
+	_, err := s.CMSINCRBY(test1, [][]byte{foo}, []int64{1})
+	testt.NoError(t, err)
+	_, err = s.CMSINCRBY(test2, [][]byte{foo}, []int64{2})
+	testt.NoError(t, err)
+
+	err = s.CMSMERGE(dest, [][]byte{test1, test2}, []int64{1, 3})
+	testt.NoError(t, err)
+
+	res, err := s.CMSQUERY(dest, foo)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{7})
+
+	// the destination may be a source.
+	err = s.CMSMERGE(dest, [][]byte{dest, test1}, []int64{2, 1})
+	testt.NoError(t, err)
+
+	res, err = s.CMSQUERY(dest, foo)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{15})
+
+	// an overflow leaves the destination as is.
+	err = s.CMSMERGE(dest, [][]byte{test1}, []int64{-1})
+	testt.MustEqual(t, err, core.ErrCMSMergeOverflow)
+
+	res, err = s.CMSQUERY(dest, foo)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{15})
+
+	err = s.CMSINIT([]byte("small"), 10, 5)
+	testt.NoError(t, err)
+	err = s.CMSMERGE(dest, [][]byte{[]byte("small")}, []int64{1})
+	testt.MustEqual(t, err, core.ErrCMSDimensions)
+
+	err = s.CMSMERGE([]byte("nokey"), [][]byte{test1}, []int64{1})
+	testt.MustEqual(t, err, core.ErrCMSNotFound)
+}
+
+func TestTOPKADD(t *testing.T) {
+	/*
+		redis> TOPK.RESERVE topk 50 2000 7 0.925
+		OK
+		redis> TOPK.ADD topk foo bar 42
+		1) (nil)
+		2) (nil)
+		3) (nil)
+		redis> TOPK.QUERY topk 42 nonexist
+		1) (integer) 1
+		2) (integer) 0
+		redis> TOPK.INFO topk
+		1) k
+		2) (integer) 50
+		3) width
+		4) (integer) 2000
+		5) depth
+		6) (integer) 7
+		7) decay
+		8) "0.92500000000000004"
+		redis>
+	*/
+
+	topk := []byte("topk")
+	opts := core.TopKOptions{K: 50, Width: 2000, Depth: 7, Decay: 0.925}
+
+	s := New()
+	err := s.TOPKRESERVE(topk, opts)
+	testt.NoError(t, err)
+
+	err = s.TOPKRESERVE(topk, opts)
+	testt.MustEqual(t, err, core.ErrTopKExists)
+
+	res, err := s.TOPKINCRBY(topk, [][]byte{[]byte("foo"), []byte("bar"), []byte("42")}, []int64{1, 1, 1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{nil, nil, nil})
+
+	ok, err := s.TOPKQUERY(topk, []byte("42"), []byte("nonexist"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, []bool{true, false})
+
+	info, err := s.TOPKINFO(topk)
+	testt.NoError(t, err)
+	testt.MustEqual(t, info, opts)
+
+	_, err = s.TOPKLIST([]byte("nokey"))
+	testt.MustEqual(t, err, core.ErrTopKNotFound)
+
+	typ, err := s.TYPE(topk)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "TopK-TYPE")
+}
+
+func TestTOPKINCRBY(t *testing.T) {
+	topk := []byte("topk")
+	a, b, c := []byte("a"), []byte("b"), []byte("c")
+
+	s := New()
+	err := s.TOPKRESERVE(topk, core.TopKOptions{K: 2, Width: 2000, Depth: 7, Decay: 0.9})
+	testt.NoError(t, err)
+
+	res, err := s.TOPKINCRBY(topk, [][]byte{a, b, c}, []int64{3, 2, 1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{nil, nil, nil})
+
+	list, err := s.TOPKLIST(topk)
+	testt.NoError(t, err)
+	testt.MustEqual(t, list, []core.TopKEntry{{Item: a, Count: 3}, {Item: b, Count: 2}})
+
+	// c takes the place of b.
+	res, err = s.TOPKINCRBY(topk, [][]byte{c}, []int64{3})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{b})
+
+	list, err = s.TOPKLIST(topk)
+	testt.NoError(t, err)
+	testt.MustEqual(t, list, []core.TopKEntry{{Item: c, Count: 4}, {Item: a, Count: 3}})
+}
@@ -0,0 +1,790 @@
+package inmem
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+
+	"github.com/cristaloleg/didis/internal/core"
+)
+
+// Streams operations https://redis.io/commands/?group=stream
+
+// stream is a log of entries ordered by ID.
+// Entries are never modified once added, so they can be shared with clones and replies.
+type stream struct {
+	entries []core.StreamEntry
+	// lastID is the ID of the last added entry, even if it was deleted since.
+	lastID       core.StreamID
+	maxDeletedID core.StreamID
+	entriesAdded int64
+	groups       map[string]*streamGroup
+}
+
+func (st *stream) clone() *stream {
+	res := &stream{
+		entries:      slices.Clone(st.entries),
+		lastID:       st.lastID,
+		maxDeletedID: st.maxDeletedID,
+		entriesAdded: st.entriesAdded,
+	}
+	for name, g := range st.groups {
+		res.group(name, g.clone())
+	}
+	return res
+}
+
+func (st *stream) counters() core.StreamCounters {
+	c := core.StreamCounters{
+		Len:          len(st.entries),
+		LastID:       st.lastID,
+		MaxDeletedID: st.maxDeletedID,
+		EntriesAdded: st.entriesAdded,
+	}
+	if len(st.entries) > 0 {
+		c.FirstID = st.entries[0].ID
+	}
+	return c
+}
+
+func (st *stream) entry(id core.StreamID) (core.StreamEntry, bool) {
+	i := st.search(id)
+	if i < len(st.entries) && st.entries[i].ID == id {
+		return st.entries[i], true
+	}
+	return core.StreamEntry{}, false
+}
+
+// group adds the group to the stream.
+func (st *stream) group(name string, g *streamGroup) {
+	if st.groups == nil {
+		st.groups = make(map[string]*streamGroup)
+	}
+	st.groups[name] = g
+}
+
+// streamGroup is a consumer group of a stream.
+type streamGroup struct {
+	lastID core.StreamID
+	// entriesRead is -1 if unknown.
+	entriesRead int64
+	consumers   map[string]*streamConsumer
+	// pel are pending entries ordered by ID.
+	pel []*pendingEntry
+}
+
+type streamConsumer struct {
+	seenTime int64
+	// activeTime is -1 if the consumer has never got an entry.
+	activeTime int64
+}
+
+// pendingEntry is an entry delivered to a consumer but not acknowledged yet.
+type pendingEntry struct {
+	id           core.StreamID
+	consumer     string
+	deliveryTime int64
+	deliveries   int64
+}
+
+func newStreamGroup(lastID core.StreamID, entriesRead int64) *streamGroup {
+	return &streamGroup{
+		lastID:      lastID,
+		entriesRead: entriesRead,
+		consumers:   make(map[string]*streamConsumer),
+	}
+}
+
+func (g *streamGroup) clone() *streamGroup {
+	res := newStreamGroup(g.lastID, g.entriesRead)
+	for name, c := range g.consumers {
+		res.consumers[name] = &streamConsumer{seenTime: c.seenTime, activeTime: c.activeTime}
+	}
+	res.pel = make([]*pendingEntry, len(g.pel))
+	for i, p := range g.pel {
+		cp := *p
+		res.pel[i] = &cp
+	}
+	return res
+}
+
+// consumer returns the consumer, a missing one is created.
+func (g *streamGroup) consumer(name []byte, now int64) *streamConsumer {
+	c, ok := g.consumers[string(name)]
+	if !ok {
+		c = &streamConsumer{seenTime: now, activeTime: -1}
+		g.consumers[string(name)] = c
+	}
+	return c
+}
+
+// searchPending returns the index of the first pending entry with ID not less than id
+// and reports whether it's the entry with id.
+func (g *streamGroup) searchPending(id core.StreamID) (int, bool) {
+	return slices.BinarySearchFunc(g.pel, id, func(p *pendingEntry, id core.StreamID) int {
+		return p.id.Compare(id)
+	})
+}
+
+// deliver makes the entry pending for the consumer, delivery count is reset if it was pending already.
+func (g *streamGroup) deliver(id core.StreamID, consumer string, now int64) {
+	i, ok := g.searchPending(id)
+	if ok {
+		p := g.pel[i]
+		p.consumer, p.deliveryTime, p.deliveries = consumer, now, 1
+		return
+	}
+	g.pel = slices.Insert(g.pel, i, &pendingEntry{id: id, consumer: consumer, deliveryTime: now, deliveries: 1})
+}
+
+// pendingCounts returns the number of pending entries per consumer.
+func (g *streamGroup) pendingCounts() map[string]int {
+	res := make(map[string]int)
+	for _, p := range g.pel {
+		res[p.consumer]++
+	}
+	return res
+}
+
+// history returns pending entries of the consumer with IDs greater than id, at most count if it's positive.
+// Delivery counters of returned entries are incremented, deleted entries are returned with nil fields.
+func (g *streamGroup) history(st *stream, consumer string, id core.StreamID, count int, now int64) []core.StreamEntry {
+	res := []core.StreamEntry{}
+	next, ok := id.Next()
+	if !ok {
+		return res
+	}
+	i, _ := g.searchPending(next)
+	for _, p := range g.pel[i:] {
+		if count > 0 && len(res) == count {
+			break
+		}
+		if p.consumer != consumer {
+			continue
+		}
+		e, ok := st.entry(p.id)
+		if !ok {
+			res = append(res, core.StreamEntry{ID: p.id})
+			continue
+		}
+		p.deliveryTime = now
+		p.deliveries++
+		res = append(res, e)
+	}
+	return res
+}
+
+// search returns the index of the first entry with ID not less than id.
+func (st *stream) search(id core.StreamID) int {
+	i, _ := slices.BinarySearchFunc(st.entries, id, func(e core.StreamEntry, id core.StreamID) int {
+		return e.ID.Compare(id)
+	})
+	return i
+}
+
+// after returns the index of the first entry with ID greater than id.
+func (st *stream) after(id core.StreamID) int {
+	next, ok := id.Next()
+	if !ok {
+		return len(st.entries)
+	}
+	return st.search(next)
+}
+
+func (st *stream) trim(opts core.XTrimOptions) int {
+	n := opts.Evict(len(st.entries), st.search(opts.MinID))
+	st.entries = slices.Delete(st.entries, 0, n)
+	return n
+}
+
+func (s *Store) XACK(key, group []byte, ids ...core.StreamID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, g, err := s.loadStreamGroup(key, group)
+	if err != nil {
+		if errors.Is(err, core.ErrKeyNotFound) || errors.Is(err, core.ErrNoGroup) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	n := 0
+	for _, id := range ids {
+		if i, ok := g.searchPending(id); ok {
+			g.pel = slices.Delete(g.pel, i, i+1)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) XADD(key []byte, opts core.XAddOptions, fieldvals ...[]byte) (core.StreamID, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok, err := s.loadStream(key)
+	if err != nil {
+		return core.StreamID{}, false, err
+	}
+	if !ok {
+		if opts.NoMkStream {
+			return core.StreamID{}, false, nil
+		}
+		st = &stream{}
+	}
+
+	id, err := opts.NextID(st.lastID, core.NowMs())
+	if err != nil {
+		return core.StreamID{}, false, err
+	}
+	if !ok {
+		s.set(string(key), st)
+	}
+
+	fields := make([][]byte, len(fieldvals))
+	for i := range fieldvals {
+		fields[i] = bytes.Clone(fieldvals[i])
+	}
+	st.entries = append(st.entries, core.StreamEntry{ID: id, Fields: fields})
+	st.lastID = id
+	st.entriesAdded++
+	st.trim(opts.Trim)
+	return id, true, nil
+}
+
+func (s *Store) XAUTOCLAIM(key, group, consumer []byte, minIdle int64, start core.StreamID, count int, justID bool) (core.StreamID, []core.StreamEntry, []core.StreamID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, g, err := s.loadStreamGroup(key, group)
+	if err != nil {
+		return core.StreamID{}, nil, nil, err
+	}
+
+	now := core.NowMs()
+	c := g.consumer(consumer, now)
+	c.seenTime = now
+
+	claimed, deleted := []core.StreamEntry{}, []core.StreamID{}
+	i, _ := g.searchPending(start)
+	// like Redis, the scan is limited to 10 pending entries per requested one.
+	for attempts := count * 10; i < len(g.pel) && attempts > 0 && count > 0; attempts-- {
+		p := g.pel[i]
+		e, ok := st.entry(p.id)
+		if !ok {
+			deleted = append(deleted, p.id)
+			g.pel = slices.Delete(g.pel, i, i+1)
+			count--
+			continue
+		}
+		i++
+		if minIdle > 0 && now-p.deliveryTime < minIdle {
+			continue
+		}
+
+		p.consumer, p.deliveryTime = string(consumer), now
+		if justID {
+			e = core.StreamEntry{ID: e.ID}
+		} else {
+			p.deliveries++
+		}
+		claimed = append(claimed, e)
+		c.activeTime = now
+		count--
+	}
+
+	var next core.StreamID
+	if i < len(g.pel) {
+		next = g.pel[i].id
+	}
+	return next, claimed, deleted, nil
+}
+
+func (s *Store) XCLAIM(key, group, consumer []byte, minIdle int64, ids []core.StreamID, opts core.XClaimOptions) ([]core.StreamEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, g, err := s.loadStreamGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	now := core.NowMs()
+	deliveryTime := opts.DeliveryTime
+	if deliveryTime == 0 {
+		deliveryTime = now
+	}
+	if opts.LastID.Compare(g.lastID) > 0 {
+		g.lastID = opts.LastID
+	}
+
+	res := []core.StreamEntry{}
+	for _, id := range ids {
+		i, pending := g.searchPending(id)
+		e, ok := st.entry(id)
+		switch {
+		case !ok:
+			if pending {
+				g.pel = slices.Delete(g.pel, i, i+1)
+			}
+			continue
+		case !pending && !opts.Force:
+			continue
+		case !pending:
+			g.pel = slices.Insert(g.pel, i, &pendingEntry{id: id, deliveries: 1})
+		case minIdle > 0 && now-g.pel[i].deliveryTime < minIdle:
+			continue
+		}
+
+		p := g.pel[i]
+		p.consumer, p.deliveryTime = string(consumer), deliveryTime
+		switch {
+		case opts.RetryCount >= 0:
+			p.deliveries = opts.RetryCount
+		case !opts.JustID:
+			p.deliveries++
+		}
+		if opts.JustID {
+			e = core.StreamEntry{ID: id}
+		}
+		res = append(res, e)
+
+		// unlike XAUTOCLAIM, the consumer is created only when it gets an entry.
+		c := g.consumer(consumer, now)
+		c.seenTime, c.activeTime = now, now
+	}
+	return res, nil
+}
+
+func (s *Store) XDEL(key []byte, ids ...core.StreamID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok, err := s.loadStream(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	n := 0
+	for _, id := range ids {
+		i := st.search(id)
+		if i < len(st.entries) && st.entries[i].ID == id {
+			st.entries = slices.Delete(st.entries, i, i+1)
+			if id.Compare(st.maxDeletedID) > 0 {
+				st.maxDeletedID = id
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) XGROUPCREATE(key, group []byte, opts core.XGroupIDOptions, mkStream bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok, err := s.loadStream(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if !mkStream {
+			return core.ErrKeyNotFound
+		}
+		st = &stream{}
+		s.set(string(key), st)
+	}
+	if _, ok := st.groups[string(group)]; ok {
+		return core.ErrBusyGroup
+	}
+
+	id := opts.ID
+	if opts.LastID {
+		id = st.lastID
+	}
+	st.group(string(group), newStreamGroup(id, opts.EntriesRead))
+	return nil
+}
+
+func (s *Store) XGROUPCREATECONSUMER(key, group, consumer []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, g, err := s.loadStreamGroup(key, group)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := g.consumers[string(consumer)]; ok {
+		return false, nil
+	}
+	g.consumer(consumer, core.NowMs())
+	return true, nil
+}
+
+func (s *Store) XGROUPDELCONSUMER(key, group, consumer []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, g, err := s.loadStreamGroup(key, group)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := g.consumers[string(consumer)]; !ok {
+		return 0, nil
+	}
+	delete(g.consumers, string(consumer))
+
+	n := len(g.pel)
+	g.pel = slices.DeleteFunc(g.pel, func(p *pendingEntry) bool {
+		return p.consumer == string(consumer)
+	})
+	return n - len(g.pel), nil
+}
+
+func (s *Store) XGROUPDESTROY(key, group []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok, err := s.loadStream(key)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, core.ErrKeyNotFound
+	}
+	if _, ok := st.groups[string(group)]; !ok {
+		return false, nil
+	}
+	delete(st.groups, string(group))
+	return true, nil
+}
+
+func (s *Store) XGROUPSETID(key, group []byte, opts core.XGroupIDOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, g, err := s.loadStreamGroup(key, group)
+	if err != nil {
+		return err
+	}
+	g.lastID = opts.ID
+	if opts.LastID {
+		g.lastID = st.lastID
+	}
+	g.entriesRead = opts.EntriesRead
+	return nil
+}
+
+func (s *Store) XINFOCONSUMERS(key, group []byte) ([]core.StreamConsumerInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, g, err := s.getStreamGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	now := core.NowMs()
+	pending := g.pendingCounts()
+	res := make([]core.StreamConsumerInfo, 0, len(g.consumers))
+	for name, c := range g.consumers {
+		info := core.StreamConsumerInfo{
+			Name:     []byte(name),
+			Pending:  pending[name],
+			Idle:     now - c.seenTime,
+			Inactive: -1,
+		}
+		if c.activeTime >= 0 {
+			info.Inactive = now - c.activeTime
+		}
+		res = append(res, info)
+	}
+	slices.SortFunc(res, func(a, b core.StreamConsumerInfo) int {
+		return bytes.Compare(a.Name, b.Name)
+	})
+	return res, nil
+}
+
+func (s *Store) XINFOGROUPS(key []byte) ([]core.StreamGroupInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, ok, err := s.getStream(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+
+	counters := st.counters()
+	res := make([]core.StreamGroupInfo, 0, len(st.groups))
+	for name, g := range st.groups {
+		res = append(res, core.StreamGroupInfo{
+			Name:            []byte(name),
+			Consumers:       len(g.consumers),
+			Pending:         len(g.pel),
+			LastDeliveredID: g.lastID,
+			EntriesRead:     g.entriesRead,
+			Lag:             counters.Lag(g.lastID, g.entriesRead),
+		})
+	}
+	slices.SortFunc(res, func(a, b core.StreamGroupInfo) int {
+		return bytes.Compare(a.Name, b.Name)
+	})
+	return res, nil
+}
+
+func (s *Store) XINFOSTREAM(key []byte) (core.StreamInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, ok, err := s.getStream(key)
+	if err != nil {
+		return core.StreamInfo{}, err
+	}
+	if !ok {
+		return core.StreamInfo{}, core.ErrKeyNotFound
+	}
+
+	res := core.StreamInfo{StreamCounters: st.counters(), Groups: len(st.groups)}
+	if len(st.entries) > 0 {
+		first, last := st.entries[0], st.entries[len(st.entries)-1]
+		res.FirstEntry, res.LastEntry = &first, &last
+	}
+	return res, nil
+}
+
+func (s *Store) XLEN(key []byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, ok, err := s.getStream(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return len(st.entries), nil
+}
+
+func (s *Store) XPENDING(key, group []byte) (core.StreamPendingSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, g, err := s.getStreamGroup(key, group)
+	if err != nil || len(g.pel) == 0 {
+		return core.StreamPendingSummary{}, err
+	}
+
+	res := core.StreamPendingSummary{
+		Count: len(g.pel),
+		First: g.pel[0].id,
+		Last:  g.pel[len(g.pel)-1].id,
+	}
+	for name, n := range g.pendingCounts() {
+		res.Consumers = append(res.Consumers, core.StreamConsumerPending{Name: []byte(name), Count: n})
+	}
+	slices.SortFunc(res.Consumers, func(a, b core.StreamConsumerPending) int {
+		return bytes.Compare(a.Name, b.Name)
+	})
+	return res, nil
+}
+
+func (s *Store) XPENDINGRANGE(key, group []byte, opts core.XPendingOptions) ([]core.StreamPendingEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, g, err := s.getStreamGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	now := core.NowMs()
+	res := []core.StreamPendingEntry{}
+	i, _ := g.searchPending(opts.Start)
+	for ; i < len(g.pel) && len(res) < opts.Count; i++ {
+		p := g.pel[i]
+		if p.id.Compare(opts.End) > 0 {
+			break
+		}
+		idle := now - p.deliveryTime
+		if opts.Consumer != nil && p.consumer != string(opts.Consumer) || idle < opts.MinIdle {
+			continue
+		}
+		res = append(res, core.StreamPendingEntry{
+			ID:         p.id,
+			Consumer:   []byte(p.consumer),
+			Idle:       idle,
+			Deliveries: p.deliveries,
+		})
+	}
+	return res, nil
+}
+
+func (s *Store) XRANGE(key []byte, start, end core.StreamID, count int) ([]core.StreamEntry, error) {
+	return s.xrangeGeneric(key, start, end, count, false)
+}
+
+func (s *Store) XREAD(keys [][]byte, ids []core.StreamID, count int) ([]core.StreamEntries, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := []core.StreamEntries{}
+	for i, key := range keys {
+		st, ok, err := s.getStream(key)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		entries := st.entries[st.after(ids[i]):]
+		if count > 0 && len(entries) > count {
+			entries = entries[:count]
+		}
+		if len(entries) > 0 {
+			res = append(res, core.StreamEntries{Key: key, Entries: slices.Clone(entries)})
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) XREADGROUP(group, consumer []byte, keys [][]byte, ids []*core.StreamID, count int, noAck bool) ([]core.StreamEntries, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	streams := make([]*stream, len(keys))
+	groups := make([]*streamGroup, len(keys))
+	for i, key := range keys {
+		st, g, err := s.loadStreamGroup(key, group)
+		if err != nil {
+			return nil, err
+		}
+		streams[i], groups[i] = st, g
+	}
+
+	now := core.NowMs()
+	res := []core.StreamEntries{}
+	for i, key := range keys {
+		st, g := streams[i], groups[i]
+		c := g.consumer(consumer, now)
+		c.seenTime = now
+
+		if ids[i] != nil {
+			res = append(res, core.StreamEntries{Key: key, Entries: g.history(st, string(consumer), *ids[i], count, now)})
+			continue
+		}
+
+		entries := st.entries[st.after(g.lastID):]
+		if count > 0 && len(entries) > count {
+			entries = entries[:count]
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		counters := st.counters()
+		for _, e := range entries {
+			g.lastID = e.ID
+			g.entriesRead = counters.NextEntriesRead(g.entriesRead, e.ID)
+			if !noAck {
+				g.deliver(e.ID, string(consumer), now)
+			}
+		}
+		if !noAck {
+			c.activeTime = now
+		}
+		res = append(res, core.StreamEntries{Key: key, Entries: slices.Clone(entries)})
+	}
+	return res, nil
+}
+
+func (s *Store) XREVRANGE(key []byte, end, start core.StreamID, count int) ([]core.StreamEntry, error) {
+	return s.xrangeGeneric(key, start, end, count, true)
+}
+
+func (s *Store) XTRIM(key []byte, opts core.XTrimOptions) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok, err := s.loadStream(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return st.trim(opts), nil
+}
+
+func (s *Store) xrangeGeneric(key []byte, start, end core.StreamID, count int, rev bool) ([]core.StreamEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, ok, err := s.getStream(key)
+	if err != nil || !ok || start.Compare(end) > 0 {
+		return []core.StreamEntry{}, err
+	}
+
+	from, to := st.search(start), st.after(end)
+	if count > 0 && to-from > count {
+		if rev {
+			from = to - count
+		} else {
+			to = from + count
+		}
+	}
+
+	res := slices.Clone(st.entries[from:to])
+	if rev {
+		slices.Reverse(res)
+	}
+	return res, nil
+}
+
+// getStream is like get but fails for keys that are not streams.
+func (s *Store) getStream(key []byte) (*stream, bool, error) {
+	val, ok := s.get(key)
+	return asStream(val, ok)
+}
+
+// loadStream is like load but fails for keys that are not streams.
+func (s *Store) loadStream(key []byte) (*stream, bool, error) {
+	val, ok := s.load(key)
+	return asStream(val, ok)
+}
+
+// getStreamGroup is like getStream but returns the group too, it fails for a missing stream or group.
+func (s *Store) getStreamGroup(key, group []byte) (*stream, *streamGroup, error) {
+	st, ok, err := s.getStream(key)
+	return asStreamGroup(st, ok, err, group)
+}
+
+// loadStreamGroup is like loadStream but returns the group too, it fails for a missing stream or group.
+func (s *Store) loadStreamGroup(key, group []byte) (*stream, *streamGroup, error) {
+	st, ok, err := s.loadStream(key)
+	return asStreamGroup(st, ok, err, group)
+}
+
+func asStreamGroup(st *stream, ok bool, err error, group []byte) (*stream, *streamGroup, error) {
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, core.ErrKeyNotFound
+	}
+	g, ok := st.groups[string(group)]
+	if !ok {
+		return nil, nil, core.ErrNoGroup
+	}
+	return st, g, nil
+}
+
+func asStream(val any, ok bool) (*stream, bool, error) {
+	if !ok {
+		return nil, false, nil
+	}
+	st, isStream := val.(*stream)
+	if !isStream {
+		return nil, false, core.ErrWrongType
+	}
+	return st, true, nil
+}
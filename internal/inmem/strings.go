@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/cristaloleg/didis/internal/core"
 )
@@ -12,10 +13,13 @@ func (s *Store) APPEND(key, value []byte) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	val, _ := s.m[string(key)]
+	val, _, err := s.loadString(key)
+	if err != nil {
+		return 0, err
+	}
 	realVal := append(val, value...)
 
-	s.m[string(key)] = realVal
+	s.put(string(key), realVal)
 	return len(realVal), nil
 }
 
@@ -31,7 +35,10 @@ func (s *Store) GET(key []byte) ([]byte, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	val, ok := s.m[string(key)]
+	val, ok, err := s.getString(key)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
 		return nil, core.ErrKeyNotFound
 	}
@@ -42,48 +49,64 @@ func (s *Store) GETDEL(key []byte) ([]byte, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	val, ok := s.m[string(key)]
+	val, ok, err := s.loadString(key)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
 		return nil, core.ErrKeyNotFound
 	}
-	delete(s.m, string(key))
+	s.del(string(key))
 
 	return bytes.Clone(val), nil
 }
 
-// TODO: GETEX()
-
-func (s *Store) GETRANGE(key []byte, start, end int) ([]byte, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+func (s *Store) GETEX(key []byte, opts core.GetExOptions) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	val, ok := s.m[string(key)]
+	val, ok, err := s.loadString(key)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
 		return nil, core.ErrKeyNotFound
 	}
 
-	if start == 0 && end == -1 {
-		return bytes.Clone(val), nil
+	switch {
+	case opts.Persist:
+		delete(s.exp, string(key))
+	case opts.ExpireAt != 0 && opts.ExpireAt <= core.NowMs():
+		s.del(string(key))
+	case opts.ExpireAt != 0:
+		s.exp[string(key)] = opts.ExpireAt
 	}
-	if start > len(val) {
-		return []byte(""), nil
-	}
-	if start < 0 {
-		start += len(val)
+	return bytes.Clone(val), nil
+}
+
+func (s *Store) GETRANGE(key []byte, start, end int) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	val, ok, err := s.getString(key)
+	if err != nil {
+		return nil, err
 	}
-	if end == -1 {
-		end = len(val) - 1
+	if !ok {
+		return nil, core.ErrKeyNotFound
 	}
-	end = min(end, len(val)-1)
-	return bytes.Clone(val[start : end+1]), nil
+	return bytes.Clone(core.Substr(val, start, end)), nil
 }
 
 func (s *Store) GETSET(key, value []byte) ([]byte, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	res := s.m[string(key)]
-	s.m[string(key)] = bytes.Clone(value)
+	res, _, err := s.loadString(key)
+	if err != nil {
+		return nil, err
+	}
+	s.set(string(key), bytes.Clone(value))
 
 	return bytes.Clone(res), nil
 }
@@ -100,23 +123,42 @@ func (s *Store) INCRBYFLOAT(key []byte, by float64) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	val, ok := s.m[string(key)]
+	val, ok, err := s.loadString(key)
+	if err != nil {
+		return "", err
+	}
 	if !ok {
 		val = []byte("0")
 	}
 
 	num, err := strconv.ParseFloat(string(val), 64)
 	if err != nil {
-		return "0", err
+		return "", core.ErrNotFloat
 	}
 	num += by
 
 	value := []byte(strconv.FormatFloat(num, 'f', -1, 64))
-	s.m[string(key)] = value
+	s.put(string(key), value)
 	return string(value), nil
 }
 
-// TODO LCS()
+func (s *Store) LCS(key1, key2 []byte, opts core.LCSOptions) (core.LCSResult, error) {
+	s.mu.RLock()
+	val1, _, err1 := s.getString(key1)
+	val2, _, err2 := s.getString(key2)
+	val1, val2 = bytes.Clone(val1), bytes.Clone(val2)
+	s.mu.RUnlock()
+
+	if err1 != nil {
+		return core.LCSResult{}, err1
+	}
+	if err2 != nil {
+		return core.LCSResult{}, err2
+	}
+
+	// computed without the lock, it's quadratic.
+	return core.LCS(val1, val2, opts)
+}
 
 func (s *Store) MGET(keys ...[]byte) ([][]byte, error) {
 	s.mu.RLock()
@@ -124,7 +166,9 @@ func (s *Store) MGET(keys ...[]byte) ([][]byte, error) {
 
 	res := [][]byte{}
 	for i := range keys {
-		res = append(res, bytes.Clone(s.m[string(keys[i])]))
+		// keys of other types are reported as missing.
+		val, _, _ := s.getString(keys[i])
+		res = append(res, bytes.Clone(val))
 	}
 	return res, nil
 }
@@ -138,31 +182,105 @@ func (s *Store) MSET(keyvals ...[]byte) error {
 	defer s.mu.Unlock()
 
 	for i := 0; i < len(keyvals); i += 2 {
-		s.m[string(keyvals[i])] = bytes.Clone(keyvals[i+1])
+		s.set(string(keyvals[i]), bytes.Clone(keyvals[i+1]))
 	}
 	return nil
 }
 
-// TODO: MSETNX()
-// TODO: PSETEX()
+func (s *Store) MSETNX(keyvals ...[]byte) (bool, error) {
+	if len(keyvals)%2 == 1 {
+		return false, fmt.Errorf("wrong number of arguments for 'msetnx' command")
+	}
 
-func (s *Store) SET(key, value []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.m[string(key)] = bytes.Clone(value)
-	return nil
+	for i := 0; i < len(keyvals); i += 2 {
+		if _, ok := s.load(keyvals[i]); ok {
+			return false, nil
+		}
+	}
+	for i := 0; i < len(keyvals); i += 2 {
+		s.set(string(keyvals[i]), bytes.Clone(keyvals[i+1]))
+	}
+	return true, nil
+}
+
+func (s *Store) PSETEX(key []byte, milliseconds int64, value []byte) error {
+	return s.setEx(key, milliseconds, time.Millisecond, value)
 }
 
-// TODO: SETEX()
-// TODO: SETNX()
-// TODO: SETRANGE(key []byte, offset int, value []byte)
+func (s *Store) SET(key, value []byte, opts core.SetOptions) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, ok := s.load(key)
+	var old []byte
+	if opts.Get && ok {
+		str, isStr := val.([]byte)
+		if !isStr {
+			return nil, false, core.ErrWrongType
+		}
+		old = bytes.Clone(str)
+	}
+
+	switch {
+	case opts.NX && ok, opts.XX && !ok:
+		return old, false, nil
+	case opts.ExpireAt != 0 && opts.ExpireAt <= core.NowMs():
+		s.del(string(key))
+		return old, true, nil
+	}
+
+	at, hasTTL := s.exp[string(key)]
+	s.set(string(key), bytes.Clone(value))
+
+	switch {
+	case opts.KeepTTL && hasTTL:
+		s.exp[string(key)] = at
+	case opts.ExpireAt != 0:
+		s.exp[string(key)] = opts.ExpireAt
+	}
+	return old, true, nil
+}
+
+func (s *Store) SETEX(key []byte, seconds int64, value []byte) error {
+	return s.setEx(key, seconds, time.Second, value)
+}
+
+func (s *Store) SETNX(key, value []byte) (bool, error) {
+	_, ok, err := s.SET(key, value, core.SetOptions{NX: true})
+	return ok, err
+}
+
+func (s *Store) SETRANGE(key []byte, offset int, value []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, ok, err := s.loadString(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok && len(value) == 0 {
+		return 0, nil
+	}
+
+	val, err = core.SetRange(val, offset, value)
+	if err != nil {
+		return 0, err
+	}
+	s.put(string(key), val)
+	return len(val), nil
+}
 
 func (s *Store) STRLEN(key []byte) (int64, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return int64(len(s.m[string(key)])), nil
+	val, _, err := s.getString(key)
+	return int64(len(val)), err
 }
 
-// TODO: SUBSTR(key []byte, start, end int)
+func (s *Store) SUBSTR(key []byte, start, end int) ([]byte, error) {
+	return s.GETRANGE(key, start, end)
+}
@@ -53,14 +53,14 @@ func TestDECR(t *testing.T) {
 	mykey := []byte("mykey")
 
 	s := New()
-	err := s.SET(mykey, []byte("10"))
+	_, _, err := s.SET(mykey, []byte("10"), core.SetOptions{})
 	testt.NoError(t, err)
 
 	val, err := s.DECR(mykey)
 	testt.NoError(t, err)
 	testt.MustEqual(t, val, int64(9))
 
-	err = s.SET(mykey, []byte("234293482390480948029348230948"))
+	_, _, err = s.SET(mykey, []byte("234293482390480948029348230948"), core.SetOptions{})
 	testt.NoError(t, err)
 
 	_, err = s.DECR(mykey)
@@ -80,7 +80,7 @@ func TestDECRBY(t *testing.T) {
 	mykey := []byte("mykey")
 
 	s := New()
-	err := s.SET(mykey, []byte("10"))
+	_, _, err := s.SET(mykey, []byte("10"), core.SetOptions{})
 	testt.NoError(t, err)
 
 	val, err := s.DECRBY(mykey, 3)
@@ -106,7 +106,7 @@ func TestGET(t *testing.T) {
 	testt.WantError(t, err)
 	testt.MustEqual(t, err.Error(), core.ErrKeyNotFound.Error())
 
-	err = s.SET(mykey, []byte("Hello"))
+	_, _, err = s.SET(mykey, []byte("Hello"), core.SetOptions{})
 
 	val, err = s.GET(mykey)
 	testt.NoError(t, err)
@@ -127,7 +127,7 @@ func TestGETDEL(t *testing.T) {
 	mykey := []byte("mykey")
 
 	s := New()
-	err := s.SET(mykey, []byte("Hello"))
+	_, _, err := s.SET(mykey, []byte("Hello"), core.SetOptions{})
 	testt.NoError(t, err)
 
 	val, err := s.GETDEL(mykey)
@@ -139,6 +139,56 @@ func TestGETDEL(t *testing.T) {
 	testt.MustEqual(t, err.Error(), core.ErrKeyNotFound.Error())
 }
 
+func TestGETEX(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> GETEX mykey
+		"Hello"
+		redis> TTL mykey
+		(integer) -1
+		redis> GETEX mykey EX 60
+		"Hello"
+		redis> TTL mykey
+		(integer) 60
+		redis> GETEX mykey PERSIST
+		"Hello"
+		redis> TTL mykey
+		(integer) -1
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := New()
+	_, _, err := s.SET(mykey, []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	val, err := s.GETEX(mykey, core.GetExOptions{})
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "Hello")
+
+	ttl, err := s.TTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(-1))
+
+	val, err = s.GETEX(mykey, core.GetExOptions{ExpireAt: core.NowMs() + 60_000})
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "Hello")
+
+	ttl, err = s.TTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(60))
+
+	val, err = s.GETEX(mykey, core.GetExOptions{Persist: true})
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "Hello")
+
+	ttl, err = s.TTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(-1))
+}
+
 func TestGETRANGE(t *testing.T) {
 	/*
 		redis> SET mykey "This is a string"
@@ -157,7 +207,7 @@ func TestGETRANGE(t *testing.T) {
 	mykey := []byte("mykey")
 
 	s := New()
-	err := s.SET(mykey, []byte("This is a string"))
+	_, _, err := s.SET(mykey, []byte("This is a string"), core.SetOptions{})
 	testt.NoError(t, err)
 
 	val, err := s.GETRANGE(mykey, 0, 3)
@@ -191,7 +241,7 @@ func TestGETSET(t *testing.T) {
 	mykey := []byte("mykey")
 
 	s := New()
-	err := s.SET(mykey, []byte("Hello"))
+	_, _, err := s.SET(mykey, []byte("Hello"), core.SetOptions{})
 	testt.NoError(t, err)
 
 	val, err := s.GETSET(mykey, []byte("World"))
@@ -217,7 +267,7 @@ func TestINCR(t *testing.T) {
 	mykey := []byte("mykey")
 
 	s := New()
-	err := s.SET(mykey, []byte("10"))
+	_, _, err := s.SET(mykey, []byte("10"), core.SetOptions{})
 	testt.NoError(t, err)
 
 	val, err := s.INCR(mykey)
@@ -241,7 +291,7 @@ func TestINCRBY(t *testing.T) {
 	mykey := []byte("mykey")
 
 	s := New()
-	err := s.SET(mykey, []byte("10"))
+	_, _, err := s.SET(mykey, []byte("10"), core.SetOptions{})
 	testt.NoError(t, err)
 
 	val, err := s.INCRBY(mykey, 5)
@@ -267,7 +317,7 @@ func TestINCRBYFLOAT(t *testing.T) {
 	mykey := []byte("mykey")
 
 	s := New()
-	err := s.SET(mykey, []byte("10.50"))
+	_, _, err := s.SET(mykey, []byte("10.50"), core.SetOptions{})
 	testt.NoError(t, err)
 
 	val, err := s.INCRBYFLOAT(mykey, 0.1)
@@ -278,7 +328,7 @@ func TestINCRBYFLOAT(t *testing.T) {
 	testt.NoError(t, err)
 	testt.MustEqual(t, string(val), "5.6")
 
-	err = s.SET(mykey, []byte("5.0e3"))
+	_, _, err = s.SET(mykey, []byte("5.0e3"), core.SetOptions{})
 	testt.NoError(t, err)
 
 	val, err = s.INCRBYFLOAT(mykey, 2.0e2)
@@ -286,6 +336,73 @@ func TestINCRBYFLOAT(t *testing.T) {
 	testt.MustEqual(t, string(val), "5200")
 }
 
+func TestLCS(t *testing.T) {
+	/*
+		redis> MSET key1 ohmytext key2 mynewtext
+		"OK"
+		redis> LCS key1 key2
+		"mytext"
+		redis> LCS key1 key2 LEN
+		(integer) 6
+		redis> LCS key1 key2 IDX
+		1) "matches"
+		2) 1) 1) 1) (integer) 4
+		         2) (integer) 7
+		      2) 1) (integer) 5
+		         2) (integer) 8
+		   2) 1) 1) (integer) 2
+		         2) (integer) 3
+		      2) 1) (integer) 0
+		         2) (integer) 1
+		3) "len"
+		4) (integer) 6
+		redis> LCS key1 key2 IDX MINMATCHLEN 4 WITHMATCHLEN
+		1) "matches"
+		2) 1) 1) 1) (integer) 4
+		         2) (integer) 7
+		      2) 1) (integer) 5
+		         2) (integer) 8
+		      3) (integer) 4
+		3) "len"
+		4) (integer) 6
+		redis>
+	*/
+
+	key1 := []byte("key1")
+	key2 := []byte("key2")
+
+	s := New()
+	err := s.MSET(key1, []byte("ohmytext"), key2, []byte("mynewtext"))
+	testt.NoError(t, err)
+
+	res, err := s.LCS(key1, key2, core.LCSOptions{})
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(res.Seq), "mytext")
+
+	res, err = s.LCS(key1, key2, core.LCSOptions{Len: true})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res.Len, 6)
+
+	res, err = s.LCS(key1, key2, core.LCSOptions{Idx: true})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res.Len, 6)
+	testt.MustEqual(t, res.Matches, []core.LCSMatch{
+		{Start1: 4, End1: 7, Start2: 5, End2: 8, Len: 4},
+		{Start1: 2, End1: 3, Start2: 0, End2: 1, Len: 2},
+	})
+
+	res, err = s.LCS(key1, key2, core.LCSOptions{Idx: true, MinMatchLen: 4})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res.Len, 6)
+	testt.MustEqual(t, res.Matches, []core.LCSMatch{
+		{Start1: 4, End1: 7, Start2: 5, End2: 8, Len: 4},
+	})
+
+	res, err = s.LCS(key1, []byte("nonexisting"), core.LCSOptions{})
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(res.Seq), "")
+}
+
 func TestMGET(t *testing.T) {
 	/*
 		redis> SET key1 "Hello"
@@ -303,10 +420,10 @@ func TestMGET(t *testing.T) {
 	key2 := []byte("key2")
 
 	s := New()
-	err := s.SET(key1, []byte("Hello"))
+	_, _, err := s.SET(key1, []byte("Hello"), core.SetOptions{})
 	testt.NoError(t, err)
 
-	err = s.SET(key2, []byte("World"))
+	_, _, err = s.SET(key2, []byte("World"), core.SetOptions{})
 	testt.NoError(t, err)
 
 	vals, err := s.MGET(key1, key2, []byte("nonexisting"))
@@ -344,6 +461,66 @@ func TestMSET(t *testing.T) {
 	testt.MustEqual(t, string(val), "World")
 }
 
+func TestMSETNX(t *testing.T) {
+	/*
+		redis> MSETNX key1 "Hello" key2 "there"
+		(integer) 1
+		redis> MSETNX key2 "new" key3 "world"
+		(integer) 0
+		redis> MGET key1 key2 key3
+		1) "Hello"
+		2) "there"
+		3) (nil)
+		redis>
+	*/
+
+	key1 := []byte("key1")
+	key2 := []byte("key2")
+	key3 := []byte("key3")
+
+	s := New()
+	ok, err := s.MSETNX(key1, []byte("Hello"), key2, []byte("there"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = s.MSETNX(key2, []byte("new"), key3, []byte("world"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	vals, err := s.MGET(key1, key2, key3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(vals), 3)
+	testt.MustEqual(t, string(vals[0]), "Hello")
+	testt.MustEqual(t, string(vals[1]), "there")
+	testt.MustEqual(t, vals[2], []byte(nil))
+}
+
+func TestPSETEX(t *testing.T) {
+	/*
+		redis> PSETEX mykey 1000 "Hello"
+		"OK"
+		redis> PTTL mykey
+		(integer) 1000
+		redis> GET mykey
+		"Hello"
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := New()
+	err := s.PSETEX(mykey, 1000, []byte("Hello"))
+	testt.NoError(t, err)
+
+	ttl, err := s.PTTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl > 900 && ttl <= 1000, true)
+
+	val, err := s.GET(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "Hello")
+}
+
 func TestSET(t *testing.T) {
 	/*
 		redis> SET mykey "Hello"
@@ -355,14 +532,208 @@ func TestSET(t *testing.T) {
 	mykey := []byte("mykey")
 
 	s := New()
-	err := s.SET(mykey, []byte("Hello"))
+	_, _, err := s.SET(mykey, []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	val, err := s.GET(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "Hello")
+}
+
+func TestSETOptions(t *testing.T) {
+	/*
+		redis> SET mykey "Hello" XX
+		(nil)
+		redis> SET mykey "Hello" NX GET
+		(nil)
+		redis> SET mykey "World" NX
+		(nil)
+		redis> SET mykey "World" XX GET EX 60
+		"Hello"
+		redis> TTL mykey
+		(integer) 60
+		redis> SET mykey "Again" KEEPTTL
+		"OK"
+		redis> TTL mykey
+		(integer) 60
+		redis> SET mykey "Again"
+		"OK"
+		redis> TTL mykey
+		(integer) -1
+		redis> SET mykey "Gone" PXAT 1
+		"OK"
+		redis> GET mykey
+		(nil)
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := New()
+	old, ok, err := s.SET(mykey, []byte("Hello"), core.SetOptions{XX: true})
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+	testt.MustEqual(t, old, []byte(nil))
+
+	old, ok, err = s.SET(mykey, []byte("Hello"), core.SetOptions{NX: true, Get: true})
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+	testt.MustEqual(t, old, []byte(nil))
+
+	_, ok, err = s.SET(mykey, []byte("World"), core.SetOptions{NX: true})
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	old, ok, err = s.SET(mykey, []byte("World"), core.SetOptions{
+		XX:       true,
+		Get:      true,
+		ExpireAt: core.NowMs() + 60_000,
+	})
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+	testt.MustEqual(t, string(old), "Hello")
+
+	ttl, err := s.TTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(60))
+
+	_, _, err = s.SET(mykey, []byte("Again"), core.SetOptions{KeepTTL: true})
+	testt.NoError(t, err)
+
+	ttl, err = s.TTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(60))
+
+	_, _, err = s.SET(mykey, []byte("Again"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	ttl, err = s.TTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(-1))
+
+	_, ok, err = s.SET(mykey, []byte("Gone"), core.SetOptions{ExpireAt: 1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	_, err = s.GET(mykey)
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), core.ErrKeyNotFound.Error())
+}
+
+func TestSETEX(t *testing.T) {
+	/*
+		redis> SETEX mykey 10 "Hello"
+		"OK"
+		redis> TTL mykey
+		(integer) 10
+		redis> GET mykey
+		"Hello"
+		redis> SETEX mykey 0 "Hello"
+		(error) ERR invalid expire time in 'setex' command
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := New()
+	err := s.SETEX(mykey, 10, []byte("Hello"))
+	testt.NoError(t, err)
+
+	ttl, err := s.TTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(10))
+
+	val, err := s.GET(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "Hello")
+
+	err = s.SETEX(mykey, 0, []byte("Hello"))
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), core.ErrInvalidExpireTime.Error())
+}
+
+func TestSETNX(t *testing.T) {
+	/*
+		redis> SETNX mykey "Hello"
+		(integer) 1
+		redis> SETNX mykey "World"
+		(integer) 0
+		redis> GET mykey
+		"Hello"
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := New()
+	ok, err := s.SETNX(mykey, []byte("Hello"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = s.SETNX(mykey, []byte("World"))
 	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
 
 	val, err := s.GET(mykey)
 	testt.NoError(t, err)
 	testt.MustEqual(t, string(val), "Hello")
 }
 
+func TestSETRANGE(t *testing.T) {
+	/*
+		redis> SET key1 "Hello World"
+		"OK"
+		redis> SETRANGE key1 6 "Redis"
+		(integer) 11
+		redis> GET key1
+		"Hello Redis"
+		redis> SETRANGE key2 6 "Redis"
+		(integer) 11
+		redis> GET key2
+		"\x00\x00\x00\x00\x00\x00Redis"
+		redis> SETRANGE key3 6 ""
+		(integer) 0
+		redis> EXISTS key3
+		(integer) 0
+		redis>
+	*/
+
+	key1 := []byte("key1")
+	key2 := []byte("key2")
+	key3 := []byte("key3")
+
+	s := New()
+	_, _, err := s.SET(key1, []byte("Hello World"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	size, err := s.SETRANGE(key1, 6, []byte("Redis"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, size, 11)
+
+	val, err := s.GET(key1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "Hello Redis")
+
+	size, err = s.SETRANGE(key2, 6, []byte("Redis"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, size, 11)
+
+	val, err = s.GET(key2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "\x00\x00\x00\x00\x00\x00Redis")
+
+	size, err = s.SETRANGE(key3, 6, []byte(""))
+	testt.NoError(t, err)
+	testt.MustEqual(t, size, 0)
+
+	_, err = s.GET(key3)
+	testt.WantError(t, err)
+
+	_, err = s.SETRANGE(key3, core.MaxStringSize, []byte("Redis"))
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), core.ErrStringTooLong.Error())
+}
+
 func TestSTRLEN(t *testing.T) {
 	/*
 		redis> SET mykey "Hello world"
@@ -377,7 +748,7 @@ func TestSTRLEN(t *testing.T) {
 	mykey := []byte("mykey")
 
 	s := New()
-	err := s.SET(mykey, []byte("Hello world"))
+	_, _, err := s.SET(mykey, []byte("Hello world"), core.SetOptions{})
 	testt.NoError(t, err)
 
 	size, err := s.STRLEN(mykey)
@@ -388,3 +759,47 @@ func TestSTRLEN(t *testing.T) {
 	testt.NoError(t, err)
 	testt.MustEqual(t, size, int64(0))
 }
+
+func TestSUBSTR(t *testing.T) {
+	/*
+		redis> SET mykey "This is a string"
+		"OK"
+		redis> SUBSTR mykey 0 3
+		"This"
+		redis> SUBSTR mykey -3 -1
+		"ing"
+		redis> SUBSTR mykey -100 3
+		"This"
+		redis> SUBSTR mykey 5 3
+		""
+		redis> SUBSTR mykey 10 100
+		"string"
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := New()
+	_, _, err := s.SET(mykey, []byte("This is a string"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	val, err := s.SUBSTR(mykey, 0, 3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "This")
+
+	val, err = s.SUBSTR(mykey, -3, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "ing")
+
+	val, err = s.SUBSTR(mykey, -100, 3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "This")
+
+	val, err = s.SUBSTR(mykey, 5, 3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "")
+
+	val, err = s.SUBSTR(mykey, 10, 100)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "string")
+}
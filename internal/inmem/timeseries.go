@@ -0,0 +1,236 @@
+package inmem
+
+import (
+	"bytes"
+	"slices"
+	"sort"
+
+	"github.com/cristaloleg/didis/internal/core"
+)
+
+// Time series operations https://redis.io/commands/?group=timeseries
+
+// series is a time series with its samples.
+type series struct {
+	ts      *core.TimeSeries
+	samples tsSamples
+}
+
+// tsSamples implements core.TSStorage, samples are ordered by timestamp.
+type tsSamples []core.TSSample
+
+func (a tsSamples) search(ts int64) (int, bool) {
+	i := sort.Search(len(a), func(i int) bool { return a[i].Timestamp >= ts })
+	return i, i < len(a) && a[i].Timestamp == ts
+}
+
+func (a tsSamples) Get(ts int64) (float64, bool, error) {
+	if i, ok := a.search(ts); ok {
+		return a[i].Value, true, nil
+	}
+	return 0, false, nil
+}
+
+func (a *tsSamples) Put(s core.TSSample) error {
+	i, ok := a.search(s.Timestamp)
+	if ok {
+		(*a)[i] = s
+		return nil
+	}
+	*a = slices.Insert(*a, i, s)
+	return nil
+}
+
+func (a tsSamples) Range(from, to int64, fn func(s core.TSSample) bool) error {
+	i, _ := a.search(from)
+	for ; i < len(a) && a[i].Timestamp <= to; i++ {
+		if !fn(a[i]) {
+			break
+		}
+	}
+	return nil
+}
+
+func (a *tsSamples) DeleteBefore(ts int64) error {
+	i, _ := a.search(ts)
+	*a = slices.Delete(*a, 0, i)
+	return nil
+}
+
+func (s *Store) TSADD(key []byte, sample core.TSSample, opts core.TSAddOptions) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ser, err := s.loadSeries(key)
+	if err != nil {
+		return 0, err
+	}
+	if ser == nil {
+		ser = &series{ts: core.NewTimeSeries(opts.TSOptions)}
+		s.set(string(key), ser)
+	}
+
+	compacted, err := ser.ts.Add(&ser.samples, sample, opts.OnDuplicate)
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range compacted {
+		// destinations deleted or replaced since the rule was created are skipped.
+		dst, err := s.loadSeries(c.DstKey)
+		if err != nil || dst == nil || !bytes.Equal(dst.ts.SrcKey(), key) {
+			continue
+		}
+		if err := dst.ts.Upsert(&dst.samples, c.Sample); err != nil {
+			return 0, err
+		}
+	}
+	return sample.Timestamp, nil
+}
+
+func (s *Store) TSCREATE(key []byte, opts core.TSOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.load(key); ok {
+		return core.ErrTSExists
+	}
+	s.set(string(key), &series{ts: core.NewTimeSeries(opts)})
+	return nil
+}
+
+func (s *Store) TSCREATERULE(src, dst []byte, agg core.TSAggregation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	srcSer, err := s.loadSeries(src)
+	if err != nil {
+		return err
+	}
+	dstSer, err := s.loadSeries(dst)
+	if err != nil {
+		return err
+	}
+	if srcSer == nil || dstSer == nil {
+		return core.ErrTSNotFound
+	}
+
+	err = core.CheckTSRule(src, dst, srcSer.ts, dstSer.ts, func(key []byte) (*core.TimeSeries, error) {
+		ser, err := s.loadSeries(key)
+		if ser == nil || err != nil {
+			return nil, nil
+		}
+		return ser.ts, nil
+	})
+	if err != nil {
+		return err
+	}
+	// a stale rule for dst is replaced.
+	srcSer.ts.DeleteRule(dst)
+	srcSer.ts.AddRule(dst, agg)
+	dstSer.ts.SetSrcKey(src)
+	return nil
+}
+
+func (s *Store) TSDELETERULE(src, dst []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	srcSer, err := s.loadSeries(src)
+	if err != nil {
+		return err
+	}
+	if srcSer == nil {
+		return core.ErrTSNotFound
+	}
+	if !srcSer.ts.DeleteRule(dst) {
+		return core.ErrTSRuleNotFound
+	}
+	if dstSer, _ := s.loadSeries(dst); dstSer != nil && bytes.Equal(dstSer.ts.SrcKey(), src) {
+		dstSer.ts.SetSrcKey(nil)
+	}
+	return nil
+}
+
+func (s *Store) TSMRANGE(filters []core.TSFilter, opts core.TSRangeOptions) ([]core.TSRange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys, err := s.queryIndex(filters)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]core.TSRange, 0, len(keys))
+	for _, key := range keys {
+		ser, _ := s.getSeries(key)
+		samples, err := ser.ts.Range(&ser.samples, opts)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, core.TSRange{Key: key, Labels: ser.ts.Labels(), Samples: samples})
+	}
+	return res, nil
+}
+
+func (s *Store) TSQUERYINDEX(filters []core.TSFilter) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.queryIndex(filters)
+}
+
+// queryIndex returns keys of series matching filters in order, there is no index,
+// so all keys are checked. Must be called with at least read lock held.
+func (s *Store) queryIndex(filters []core.TSFilter) ([][]byte, error) {
+	if _, err := core.TSIndexFilter(filters); err != nil {
+		return nil, err
+	}
+	res := [][]byte{}
+	for key := range s.m {
+		ser, err := s.getSeries([]byte(key))
+		if err != nil || ser == nil {
+			continue
+		}
+		if core.MatchTSFilters(filters, ser.ts.Labels()) {
+			res = append(res, []byte(key))
+		}
+	}
+	slices.SortFunc(res, bytes.Compare)
+	return res, nil
+}
+
+func (s *Store) TSRANGE(key []byte, opts core.TSRangeOptions) ([]core.TSSample, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ser, err := s.getSeries(key)
+	if err != nil {
+		return nil, err
+	}
+	if ser == nil {
+		return nil, core.ErrTSNotFound
+	}
+	return ser.ts.Range(&ser.samples, opts)
+}
+
+// getSeries is like get but fails for keys that are not time series, the series is nil for a missing key.
+func (s *Store) getSeries(key []byte) (*series, error) {
+	val, ok := s.get(key)
+	return asSeries(val, ok)
+}
+
+// loadSeries is like load but fails for keys that are not time series, the series is nil for a missing key.
+func (s *Store) loadSeries(key []byte) (*series, error) {
+	val, ok := s.load(key)
+	return asSeries(val, ok)
+}
+
+func asSeries(val any, ok bool) (*series, error) {
+	if !ok {
+		return nil, nil
+	}
+	ser, isSeries := val.(*series)
+	if !isSeries {
+		return nil, core.ErrWrongType
+	}
+	return ser, nil
+}
@@ -0,0 +1,351 @@
+package inmem
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cristalhq/testt"
+)
+
+func TestTSADD(t *testing.T) {
+	/*
+		redis> TS.CREATE temp RETENTION 100 LABELS sensor 1
+		OK
+		redis> TS.ADD temp 1000 26
+		(integer) 1000
+		redis> TS.ADD temp 1000 27
+		(error) ERR TSDB: Error at upsert, update is not supported when DUPLICATE_POLICY is set to BLOCK mode
+		redis> TS.ADD temp 1000 2 ON_DUPLICATE SUM
+		(integer) 1000
+		redis> TS.ADD temp 1050 30
+		(integer) 1050
+		redis> TS.ADD temp 900 30
+		(error) ERR TSDB: Timestamp is older than retention
+		redis> TS.RANGE temp - +
+		1) 1) (integer) 1000
+		   2) 28
+		2) 1) (integer) 1050
+		   2) 30
+		redis>
+	*/
+
+	temp := []byte("temp")
+	s := New()
+
+	err := s.TSCREATE(temp, core.TSOptions{
+		Retention: 100,
+		Labels:    []core.TSLabel{{Name: []byte("sensor"), Value: []byte("1")}},
+	})
+	testt.NoError(t, err)
+
+	err = s.TSCREATE(temp, core.TSOptions{})
+	testt.MustEqual(t, err, core.ErrTSExists)
+
+	ts, err := s.TSADD(temp, core.TSSample{Timestamp: 1000, Value: 26}, core.TSAddOptions{})
+	testt.NoError(t, err)
+	testt.MustEqual(t, ts, int64(1000))
+
+	_, err = s.TSADD(temp, core.TSSample{Timestamp: 1000, Value: 27}, core.TSAddOptions{})
+	testt.MustEqual(t, err, core.ErrTSDuplicate)
+
+	_, err = s.TSADD(temp, core.TSSample{Timestamp: 1000, Value: 2}, core.TSAddOptions{OnDuplicate: core.TSDuplicateSum})
+	testt.NoError(t, err)
+
+	_, err = s.TSADD(temp, core.TSSample{Timestamp: 1050, Value: 30}, core.TSAddOptions{})
+	testt.NoError(t, err)
+
+	_, err = s.TSADD(temp, core.TSSample{Timestamp: 900, Value: 30}, core.TSAddOptions{})
+	testt.MustEqual(t, err, core.ErrTSTooOld)
+
+	res, err := s.TSRANGE(temp, core.TSRangeOptions{To: math.MaxInt64})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.TSSample{{Timestamp: 1000, Value: 28}, {Timestamp: 1050, Value: 30}})
+
+	// samples older than retention are removed.
+	_, err = s.TSADD(temp, core.TSSample{Timestamp: 1120, Value: 31}, core.TSAddOptions{})
+	testt.NoError(t, err)
+
+	res, err = s.TSRANGE(temp, core.TSRangeOptions{To: math.MaxInt64})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.TSSample{{Timestamp: 1050, Value: 30}, {Timestamp: 1120, Value: 31}})
+
+	// a missing series is created with options of TS.ADD.
+	_, err = s.TSADD([]byte("new"), core.TSSample{Timestamp: 1, Value: 1}, core.TSAddOptions{
+		TSOptions: core.TSOptions{DuplicatePolicy: core.TSDuplicateLast},
+	})
+	testt.NoError(t, err)
+	_, err = s.TSADD([]byte("new"), core.TSSample{Timestamp: 1, Value: 2}, core.TSAddOptions{})
+	testt.NoError(t, err)
+
+	res, err = s.TSRANGE([]byte("new"), core.TSRangeOptions{To: math.MaxInt64})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.TSSample{{Timestamp: 1, Value: 2}})
+
+	_, err = s.TSRANGE([]byte("nokey"), core.TSRangeOptions{To: math.MaxInt64})
+	testt.MustEqual(t, err, core.ErrTSNotFound)
+
+	typ, err := s.TYPE(temp)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "TSDB-TYPE")
+}
+
+func TestTSRANGE(t *testing.T) {
+	/*
+		redis> TS.ADD temp 0 1
+		redis> TS.ADD temp 5 3
+		redis> TS.ADD temp 10 10
+		redis> TS.ADD temp 30 4
+		redis> TS.RANGE temp - + AGGREGATION avg 10
+		1) 1) (integer) 0
+		   2) 2
+		2) 1) (integer) 10
+		   2) 10
+		3) 1) (integer) 30
+		   2) 4
+		redis> TS.RANGE temp - + AGGREGATION count 10 EMPTY
+		1) 1) (integer) 0
+		   2) 2
+		2) 1) (integer) 10
+		   2) 1
+		3) 1) (integer) 20
+		   2) 0
+		4) 1) (integer) 30
+		   2) 1
+		redis>
+	*/
+
+	temp := []byte("temp")
+	s := New()
+	for _, sample := range []core.TSSample{{Timestamp: 0, Value: 1}, {Timestamp: 5, Value: 3}, {Timestamp: 10, Value: 10}, {Timestamp: 30, Value: 4}} {
+		_, err := s.TSADD(temp, sample, core.TSAddOptions{})
+		testt.NoError(t, err)
+	}
+
+	testCases := []struct {
+		opts core.TSRangeOptions
+		want []core.TSSample
+	}{
+		{
+			core.TSRangeOptions{From: 5, To: 10},
+			[]core.TSSample{{Timestamp: 5, Value: 3}, {Timestamp: 10, Value: 10}},
+		},
+		{
+			core.TSRangeOptions{To: math.MaxInt64, Count: 2},
+			[]core.TSSample{{Timestamp: 0, Value: 1}, {Timestamp: 5, Value: 3}},
+		},
+		{
+			core.TSRangeOptions{To: math.MaxInt64, FilterByTS: []int64{5, 30, 31}},
+			[]core.TSSample{{Timestamp: 5, Value: 3}, {Timestamp: 30, Value: 4}},
+		},
+		{
+			core.TSRangeOptions{To: math.MaxInt64, FilterByValue: true, MinValue: 2, MaxValue: 4},
+			[]core.TSSample{{Timestamp: 5, Value: 3}, {Timestamp: 30, Value: 4}},
+		},
+		{
+			core.TSRangeOptions{To: math.MaxInt64, Aggregation: core.TSAggregation{Type: core.TSAggAvg, BucketDuration: 10}},
+			[]core.TSSample{{Timestamp: 0, Value: 2}, {Timestamp: 10, Value: 10}, {Timestamp: 30, Value: 4}},
+		},
+		{
+			core.TSRangeOptions{To: math.MaxInt64, Aggregation: core.TSAggregation{Type: core.TSAggCount, BucketDuration: 10, Empty: true}},
+			[]core.TSSample{{Timestamp: 0, Value: 2}, {Timestamp: 10, Value: 1}, {Timestamp: 20, Value: 0}, {Timestamp: 30, Value: 1}},
+		},
+		{
+			core.TSRangeOptions{To: math.MaxInt64, Count: 1, Aggregation: core.TSAggregation{Type: core.TSAggSum, BucketDuration: 10}},
+			[]core.TSSample{{Timestamp: 0, Value: 4}},
+		},
+		{
+			core.TSRangeOptions{To: math.MaxInt64, Aggregation: core.TSAggregation{Type: core.TSAggStdP, BucketDuration: 10}},
+			[]core.TSSample{{Timestamp: 0, Value: 1}, {Timestamp: 10, Value: 0}, {Timestamp: 30, Value: 0}},
+		},
+		{
+			core.TSRangeOptions{To: math.MaxInt64, Aggregation: core.TSAggregation{Type: core.TSAggMax, BucketDuration: 20, Align: 5}},
+			[]core.TSSample{{Timestamp: 0, Value: 1}, {Timestamp: 5, Value: 10}, {Timestamp: 25, Value: 4}},
+		},
+		{
+			core.TSRangeOptions{To: math.MaxInt64, Aggregation: core.TSAggregation{
+				Type:            core.TSAggLast,
+				BucketDuration:  20,
+				BucketTimestamp: core.TSBucketHigh,
+			}},
+			[]core.TSSample{{Timestamp: 20, Value: 10}, {Timestamp: 40, Value: 4}},
+		},
+	}
+	for _, tc := range testCases {
+		res, err := s.TSRANGE(temp, tc.opts)
+		testt.NoError(t, err)
+		testt.MustEqual(t, res, tc.want)
+	}
+}
+
+func TestTSMRANGE(t *testing.T) {
+	/*
+		redis> TS.CREATE a LABELS type temp room kitchen
+		OK
+		redis> TS.CREATE b LABELS type temp room hall
+		OK
+		redis> TS.CREATE c LABELS type humidity room kitchen
+		OK
+		redis> TS.QUERYINDEX type=temp
+		1) "a"
+		2) "b"
+		redis> TS.QUERYINDEX room=kitchen type!=temp
+		1) "c"
+		redis> TS.QUERYINDEX type=(temp,humidity) room!=(hall)
+		1) "a"
+		2) "c"
+		redis>
+	*/
+
+	s := New()
+	series := map[string][]string{
+		"a": {"type", "temp", "room", "kitchen"},
+		"b": {"type", "temp", "room", "hall"},
+		"c": {"type", "humidity", "room", "kitchen"},
+	}
+	for key, labels := range series {
+		var opts core.TSOptions
+		for i := 0; i < len(labels); i += 2 {
+			opts.Labels = append(opts.Labels, core.TSLabel{Name: []byte(labels[i]), Value: []byte(labels[i+1])})
+		}
+		err := s.TSCREATE([]byte(key), opts)
+		testt.NoError(t, err)
+
+		_, err = s.TSADD([]byte(key), core.TSSample{Timestamp: 1, Value: float64(len(key) + len(labels[1]))}, core.TSAddOptions{})
+		testt.NoError(t, err)
+	}
+	_, _, err := s.SET([]byte("str"), []byte("type=temp"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	testCases := []struct {
+		filters []string
+		want    []string
+	}{
+		{[]string{"type=temp"}, []string{"a", "b"}},
+		{[]string{"room=kitchen", "type!=temp"}, []string{"c"}},
+		{[]string{"type=(temp,humidity)", "room!=(hall)"}, []string{"a", "c"}},
+		{[]string{"room=kitchen", "floor="}, []string{"a", "c"}},
+		{[]string{"room=kitchen", "type!="}, []string{"a", "c"}},
+		{[]string{"room=garage"}, []string{}},
+	}
+	for _, tc := range testCases {
+		filters := parseFilters(t, tc.filters...)
+		keys, err := s.TSQUERYINDEX(filters)
+		testt.NoError(t, err)
+
+		want := make([][]byte, len(tc.want))
+		for i, key := range tc.want {
+			want[i] = []byte(key)
+		}
+		testt.MustEqual(t, keys, want)
+	}
+
+	_, err = s.TSQUERYINDEX(parseFilters(t, "type!=temp"))
+	testt.MustEqual(t, err, core.ErrTSNoMatcher)
+
+	res, err := s.TSMRANGE(parseFilters(t, "room=kitchen"), core.TSRangeOptions{To: math.MaxInt64})
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 2)
+	testt.MustEqual(t, string(res[0].Key), "a")
+	testt.MustEqual(t, res[0].Samples, []core.TSSample{{Timestamp: 1, Value: 5}})
+	testt.MustEqual(t, string(res[1].Key), "c")
+	testt.MustEqual(t, string(res[1].Labels[1].Value), "kitchen")
+}
+
+func TestTSCREATERULE(t *testing.T) {
+	/*
+		redis> TS.CREATE temp
+		OK
+		redis> TS.CREATE temp_avg
+		OK
+		redis> TS.CREATERULE temp temp_avg AGGREGATION avg 10
+		OK
+		redis> TS.ADD temp 1 1
+		redis> TS.ADD temp 5 3
+		redis> TS.ADD temp 12 7
+		redis> TS.RANGE temp_avg - +
+		1) 1) (integer) 0
+		   2) 2
+		redis>
+	*/
+
+	temp, avg := []byte("temp"), []byte("temp_avg")
+	s := New()
+	for _, key := range [][]byte{temp, avg, []byte("other")} {
+		err := s.TSCREATE(key, core.TSOptions{})
+		testt.NoError(t, err)
+	}
+
+	err := s.TSCREATERULE(temp, avg, core.TSAggregation{Type: core.TSAggAvg, BucketDuration: 10})
+	testt.NoError(t, err)
+
+	for _, sample := range []core.TSSample{{Timestamp: 1, Value: 1}, {Timestamp: 5, Value: 3}, {Timestamp: 12, Value: 7}} {
+		_, err := s.TSADD(temp, sample, core.TSAddOptions{})
+		testt.NoError(t, err)
+	}
+	res, err := s.TSRANGE(avg, core.TSRangeOptions{To: math.MaxInt64})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.TSSample{{Timestamp: 0, Value: 2}})
+
+	// a late sample updates its closed bucket.
+	_, err = s.TSADD(temp, core.TSSample{Timestamp: 2, Value: 8}, core.TSAddOptions{})
+	testt.NoError(t, err)
+	res, err = s.TSRANGE(avg, core.TSRangeOptions{To: math.MaxInt64})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.TSSample{{Timestamp: 0, Value: 4}})
+
+	// an update of the open bucket is reported once it's closed.
+	_, err = s.TSADD(temp, core.TSSample{Timestamp: 12, Value: 1}, core.TSAddOptions{OnDuplicate: core.TSDuplicateLast})
+	testt.NoError(t, err)
+	_, err = s.TSADD(temp, core.TSSample{Timestamp: 20, Value: 5}, core.TSAddOptions{})
+	testt.NoError(t, err)
+	res, err = s.TSRANGE(avg, core.TSRangeOptions{To: math.MaxInt64})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.TSSample{{Timestamp: 0, Value: 4}, {Timestamp: 10, Value: 1}})
+
+	testCases := []struct {
+		src, dst string
+		err      error
+	}{
+		{"temp", "temp", core.ErrTSSameKey},
+		{"temp", "nokey", core.ErrTSNotFound},
+		{"other", "temp_avg", core.ErrTSDstHasSrc},
+		{"temp_avg", "other", core.ErrTSSrcHasSrc},
+		{"other", "temp", core.ErrTSDstHasRules},
+	}
+	for _, tc := range testCases {
+		err := s.TSCREATERULE([]byte(tc.src), []byte(tc.dst), core.TSAggregation{Type: core.TSAggSum, BucketDuration: 10})
+		testt.MustEqual(t, err, tc.err)
+	}
+
+	// the rule is dropped with its destination.
+	_, err = s.DEL(avg)
+	testt.NoError(t, err)
+	err = s.TSCREATE(avg, core.TSOptions{})
+	testt.NoError(t, err)
+	_, err = s.TSADD(temp, core.TSSample{Timestamp: 30, Value: 5}, core.TSAddOptions{})
+	testt.NoError(t, err)
+	res, err = s.TSRANGE(avg, core.TSRangeOptions{To: math.MaxInt64})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.TSSample{})
+
+	err = s.TSCREATERULE([]byte("other"), avg, core.TSAggregation{Type: core.TSAggSum, BucketDuration: 10})
+	testt.NoError(t, err)
+	err = s.TSDELETERULE([]byte("other"), avg)
+	testt.NoError(t, err)
+	err = s.TSDELETERULE([]byte("other"), avg)
+	testt.MustEqual(t, err, core.ErrTSRuleNotFound)
+}
+
+func parseFilters(t *testing.T, exprs ...string) []core.TSFilter {
+	t.Helper()
+
+	filters := make([]core.TSFilter, len(exprs))
+	for i, expr := range exprs {
+		f, err := core.ParseTSFilter([]byte(expr))
+		testt.NoError(t, err)
+		filters[i] = f
+	}
+	return filters
+}
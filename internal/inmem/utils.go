@@ -1,7 +1,11 @@
 package inmem
 
 import (
+	"bytes"
+	"fmt"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/cristaloleg/didis/internal/core"
 )
@@ -10,7 +14,10 @@ func (s *Store) setNum(key []byte, by int) (int64, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	val, ok := s.m[string(key)]
+	val, ok, err := s.loadString(key)
+	if err != nil {
+		return 0, err
+	}
 	if !ok {
 		val = []byte("0")
 	}
@@ -21,6 +28,185 @@ func (s *Store) setNum(key []byte, by int) (int64, error) {
 	}
 	num += int64(by)
 
-	s.m[string(key)] = []byte(strconv.FormatInt(num, 10))
+	s.put(string(key), []byte(strconv.FormatInt(num, 10)))
 	return num, nil
 }
+
+func (s *Store) setEx(key []byte, d int64, unit time.Duration, value []byte) error {
+	if d <= 0 {
+		return core.ErrInvalidExpireTime
+	}
+	at, err := core.ExpireAtMs(core.NowMs(), d, unit)
+	if err != nil {
+		return err
+	}
+	_, _, err = s.SET(key, value, core.SetOptions{ExpireAt: at})
+	return err
+}
+
+// get returns value of the key, expired keys and hashes with all fields expired are treated as missing.
+// Must be called with at least read lock held.
+func (s *Store) get(key []byte) (any, bool) {
+	now := core.NowMs()
+	val, ok := s.m[string(key)]
+	if !ok || s.isExpired(string(key), now) || isEmptyHash(val, now) {
+		return nil, false
+	}
+	return val, true
+}
+
+// load is like get but also removes the key if it's expired and expired fields of a hash.
+// Must be called with write lock held.
+func (s *Store) load(key []byte) (any, bool) {
+	now := core.NowMs()
+	val, ok := s.m[string(key)]
+	if !ok {
+		return nil, false
+	}
+	if h, isHash := val.(*hash); isHash {
+		h.purge(now)
+	}
+	if s.isExpired(string(key), now) || isEmptyHash(val, now) {
+		s.del(string(key))
+		return nil, false
+	}
+	return val, true
+}
+
+// getString is like get but fails for keys that are not strings.
+func (s *Store) getString(key []byte) ([]byte, bool, error) {
+	val, ok := s.get(key)
+	return asString(val, ok)
+}
+
+// loadString is like load but fails for keys that are not strings.
+func (s *Store) loadString(key []byte) ([]byte, bool, error) {
+	val, ok := s.load(key)
+	return asString(val, ok)
+}
+
+func asString(val any, ok bool) ([]byte, bool, error) {
+	if !ok {
+		return nil, false, nil
+	}
+	str, isStr := val.([]byte)
+	if !isStr {
+		return nil, false, core.ErrWrongType
+	}
+	return str, true, nil
+}
+
+func (s *Store) isExpired(key string, now int64) bool {
+	at, ok := s.exp[key]
+	return ok && at <= now
+}
+
+// set replaces value of the key and discards its expiry.
+func (s *Store) set(key string, value any) {
+	s.put(key, value)
+	delete(s.exp, key)
+}
+
+// put replaces value of the key keeping its expiry.
+func (s *Store) put(key string, value any) {
+	if _, ok := s.m[key]; !ok {
+		s.keys.Set(key)
+	}
+	s.m[key] = value
+}
+
+func (s *Store) del(key string) {
+	if _, ok := s.m[key]; ok {
+		s.keys.Delete(key)
+	}
+	delete(s.m, key)
+	delete(s.exp, key)
+}
+
+func lessKey(a, b any) bool {
+	return a.(string) < b.(string)
+}
+
+// scanKeys returns at most n smallest keys of m not less than seek in sorted order,
+// keys for which skip reports true are ignored. Only n keys are kept at once,
+// so a scan call doesn't sort the whole map.
+func scanKeys[V any](m map[string]V, seek string, n int, skip func(key string) bool) []string {
+	res := make([]string, 0, n)
+	for key := range m {
+		if key < seek || (len(res) == n && key >= res[n-1]) {
+			continue
+		}
+		if skip != nil && skip(key) {
+			continue
+		}
+		i, _ := slices.BinarySearch(res, key)
+		if len(res) == n {
+			res = res[:n-1]
+		}
+		res = slices.Insert(res, i, key)
+	}
+	return res
+}
+
+func typeOf(val any) core.KeyType {
+	switch val.(type) {
+	case []byte:
+		return core.TypeString
+	case *deque:
+		return core.TypeList
+	case *hash:
+		return core.TypeHash
+	case set:
+		return core.TypeSet
+	case *zset:
+		return core.TypeZSet
+	case *stream:
+		return core.TypeStream
+	case jsonDoc:
+		return core.TypeJSON
+	case *bloom:
+		return core.TypeBloom
+	case *cuckoo:
+		return core.TypeCuckoo
+	case *cms:
+		return core.TypeCMS
+	case *topk:
+		return core.TypeTopK
+	case *series:
+		return core.TypeTimeSeries
+	default:
+		return core.TypeNone
+	}
+}
+
+// clone returns a deep copy of the value.
+func clone(val any) any {
+	switch val := val.(type) {
+	case []byte:
+		return bytes.Clone(val)
+	case *deque:
+		return newDeque(val.Slice(0, val.Len())...)
+	case *hash:
+		return val.clone()
+	case set:
+		return val.clone()
+	case *zset:
+		return val.clone()
+	case *stream:
+		return val.clone()
+	case jsonDoc:
+		return jsonDoc(bytes.Clone(val))
+	case *bloom:
+		return &bloom{filter: val.filter.Clone(), arrays: val.arrays.clone()}
+	case *cuckoo:
+		return &cuckoo{filter: val.filter.Clone(), arrays: val.arrays.clone()}
+	case *cms:
+		return &cms{sketch: val.sketch.Clone(), rows: val.rows.clone()}
+	case *topk:
+		return &topk{top: val.top.Clone(), rows: val.rows.clone()}
+	case *series:
+		return &series{ts: val.ts.Clone(), samples: slices.Clone(val.samples)}
+	default:
+		panic(fmt.Sprintf("unexpected value type %T", val))
+	}
+}
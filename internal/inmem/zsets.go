@@ -0,0 +1,492 @@
+package inmem
+
+import (
+	"bytes"
+	"math"
+	"slices"
+
+	"github.com/cristaloleg/didis/internal/core"
+)
+
+// Sorted sets operations https://redis.io/commands/?group=sorted-set
+
+// zset is a sorted set, members are kept ordered by score and member.
+type zset struct {
+	scores map[string]float64
+	sorted []core.ZMember
+}
+
+func newZSet() *zset {
+	return &zset{scores: map[string]float64{}}
+}
+
+// newZSetOf returns a sorted set of the given distinct members.
+func newZSetOf(members []core.ZMember) *zset {
+	z := &zset{
+		scores: make(map[string]float64, len(members)),
+		sorted: make([]core.ZMember, 0, len(members)),
+	}
+	for _, m := range members {
+		z.sorted = append(z.sorted, core.ZMember{Member: bytes.Clone(m.Member), Score: m.Score})
+		z.scores[string(m.Member)] = m.Score
+	}
+	slices.SortFunc(z.sorted, core.CompareZMembers)
+	return z
+}
+
+func (z *zset) clone() *zset {
+	return newZSetOf(z.sorted)
+}
+
+func (z *zset) len() int {
+	return len(z.sorted)
+}
+
+func (z *zset) score(member []byte) (float64, bool) {
+	score, ok := z.scores[string(member)]
+	return score, ok
+}
+
+// rank returns position of the member in the sorted order.
+func (z *zset) rank(member []byte) (int, bool) {
+	score, ok := z.scores[string(member)]
+	if !ok {
+		return 0, false
+	}
+	return slices.BinarySearchFunc(z.sorted, core.ZMember{Member: member, Score: score}, core.CompareZMembers)
+}
+
+// set adds the member or updates its score.
+func (z *zset) set(member []byte, score float64) {
+	z.del(member)
+
+	m := core.ZMember{Member: bytes.Clone(member), Score: score}
+	i, _ := slices.BinarySearchFunc(z.sorted, m, core.CompareZMembers)
+	z.sorted = slices.Insert(z.sorted, i, m)
+	z.scores[string(member)] = score
+}
+
+// del removes the member, reports whether it existed.
+func (z *zset) del(member []byte) bool {
+	i, ok := z.rank(member)
+	if !ok {
+		return false
+	}
+	z.sorted = slices.Delete(z.sorted, i, i+1)
+	delete(z.scores, string(member))
+	return true
+}
+
+func (s *Store) ZADD(key []byte, opts core.ZAddOptions, members ...core.ZMember) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, ok, err := s.loadZSet(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		z = newZSet()
+	}
+
+	n := 0
+	for _, m := range members {
+		old, exists := z.score(m.Member)
+		if !opts.Allowed(old, m.Score, exists) {
+			continue
+		}
+		switch {
+		case !exists:
+			n++
+		case old == m.Score:
+			continue
+		case opts.CH:
+			n++
+		}
+		z.set(m.Member, m.Score)
+	}
+
+	if !ok && z.len() > 0 {
+		s.set(string(key), z)
+	}
+	return n, nil
+}
+
+func (s *Store) ZCARD(key []byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	z, ok, err := s.getZSet(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return z.len(), nil
+}
+
+func (s *Store) ZCOUNT(key []byte, min, max core.ScoreBound) (int, error) {
+	res, err := s.ZRANGE(key, core.ZRange{By: core.ZRangeByScore, Min: min, Max: max})
+	return len(res), err
+}
+
+func (s *Store) ZDIFF(keys ...[]byte) ([]core.ZMember, error) {
+	return s.zalgebraGeneric(keys, core.ZDiff)
+}
+
+func (s *Store) ZDIFFSTORE(dst []byte, keys ...[]byte) (int, error) {
+	return s.zstoreGeneric(dst, keys, core.ZDiff)
+}
+
+func (s *Store) ZINCRBY(key []byte, by float64, member []byte, opts core.ZAddOptions) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, ok, err := s.loadZSet(key)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		z = newZSet()
+	}
+
+	old, exists := z.score(member)
+	score := old + by
+	if math.IsNaN(score) {
+		return 0, false, core.ErrScoreNaN
+	}
+	if !opts.Allowed(old, score, exists) {
+		return 0, false, nil
+	}
+
+	z.set(member, score)
+	if !ok {
+		s.set(string(key), z)
+	}
+	return score, true, nil
+}
+
+func (s *Store) ZINTER(opts core.ZAggOptions, keys ...[]byte) ([]core.ZMember, error) {
+	return s.zalgebraGeneric(keys, func(iters []core.ZMemberIter, fn func(core.ZMember) bool) error {
+		return core.ZInter(iters, opts, fn)
+	})
+}
+
+func (s *Store) ZINTERCARD(limit int, keys ...[]byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	iters, err := s.zmemberIters(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	err = core.ZInter(iters, core.ZAggOptions{}, func(core.ZMember) bool {
+		n++
+		return limit <= 0 || n < limit
+	})
+	return n, err
+}
+
+func (s *Store) ZINTERSTORE(dst []byte, opts core.ZAggOptions, keys ...[]byte) (int, error) {
+	return s.zstoreGeneric(dst, keys, func(iters []core.ZMemberIter, fn func(core.ZMember) bool) error {
+		return core.ZInter(iters, opts, fn)
+	})
+}
+
+func (s *Store) ZLEXCOUNT(key []byte, min, max core.LexBound) (int, error) {
+	res, err := s.ZRANGE(key, core.ZRange{By: core.ZRangeByLex, LexMin: min, LexMax: max})
+	return len(res), err
+}
+
+func (s *Store) ZMSCORE(key []byte, members ...[]byte) ([]*float64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	z, ok, err := s.getZSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*float64, len(members))
+	if !ok {
+		return res, nil
+	}
+	for i, member := range members {
+		if score, ok := z.score(member); ok {
+			res[i] = &score
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) ZPOPMAX(key []byte, count int) ([]core.ZMember, error) {
+	return s.zpopGeneric(key, count, true)
+}
+
+func (s *Store) ZPOPMIN(key []byte, count int) ([]core.ZMember, error) {
+	return s.zpopGeneric(key, count, false)
+}
+
+func (s *Store) ZRANGE(key []byte, r core.ZRange) ([]core.ZMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	z, ok, err := s.getZSet(key)
+	if err != nil || !ok {
+		return []core.ZMember{}, err
+	}
+	return cloneZMembers(r.Slice(z.sorted)), nil
+}
+
+func (s *Store) ZRANGESTORE(dst, src []byte, r core.ZRange) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, ok, err := s.getZSet(src)
+	if err != nil {
+		return 0, err
+	}
+	res := []core.ZMember{}
+	if ok {
+		res = r.Slice(z.sorted)
+	}
+
+	s.del(string(dst))
+	if len(res) == 0 {
+		return 0, nil
+	}
+	s.set(string(dst), newZSetOf(res))
+	return len(res), nil
+}
+
+func (s *Store) ZRANK(key, member []byte) (int, float64, bool, error) {
+	return s.rankGeneric(key, member, false)
+}
+
+func (s *Store) ZREM(key []byte, members ...[]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, ok, err := s.loadZSet(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	n := 0
+	for _, member := range members {
+		if z.del(member) {
+			n++
+		}
+	}
+	if z.len() == 0 {
+		s.del(string(key))
+	}
+	return n, nil
+}
+
+func (s *Store) ZREMRANGEBYLEX(key []byte, min, max core.LexBound) (int, error) {
+	res, err := s.zremRange(key, core.ZRange{By: core.ZRangeByLex, LexMin: min, LexMax: max})
+	return len(res), err
+}
+
+func (s *Store) ZREMRANGEBYRANK(key []byte, start, stop int) (int, error) {
+	res, err := s.zremRange(key, core.ZRange{Start: start, Stop: stop})
+	return len(res), err
+}
+
+func (s *Store) ZREMRANGEBYSCORE(key []byte, min, max core.ScoreBound) (int, error) {
+	res, err := s.zremRange(key, core.ZRange{By: core.ZRangeByScore, Min: min, Max: max})
+	return len(res), err
+}
+
+func (s *Store) ZREVRANK(key, member []byte) (int, float64, bool, error) {
+	return s.rankGeneric(key, member, true)
+}
+
+func (s *Store) ZSCORE(key, member []byte) (float64, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	z, ok, err := s.getZSet(key)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	score, ok := z.score(member)
+	return score, ok, nil
+}
+
+func (s *Store) ZUNION(opts core.ZAggOptions, keys ...[]byte) ([]core.ZMember, error) {
+	return s.zalgebraGeneric(keys, func(iters []core.ZMemberIter, fn func(core.ZMember) bool) error {
+		return core.ZUnion(iters, opts, fn)
+	})
+}
+
+func (s *Store) ZUNIONSTORE(dst []byte, opts core.ZAggOptions, keys ...[]byte) (int, error) {
+	return s.zstoreGeneric(dst, keys, func(iters []core.ZMemberIter, fn func(core.ZMember) bool) error {
+		return core.ZUnion(iters, opts, fn)
+	})
+}
+
+func (s *Store) rankGeneric(key, member []byte, rev bool) (int, float64, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	z, ok, err := s.getZSet(key)
+	if err != nil || !ok {
+		return 0, 0, false, err
+	}
+	rank, ok := z.rank(member)
+	if !ok {
+		return 0, 0, false, nil
+	}
+	score := z.sorted[rank].Score
+	if rev {
+		rank = z.len() - 1 - rank
+	}
+	return rank, score, true, nil
+}
+
+func (s *Store) zpopGeneric(key []byte, count int, rev bool) ([]core.ZMember, error) {
+	if count <= 0 {
+		return []core.ZMember{}, nil
+	}
+	return s.zremRange(key, core.ZRange{Start: 0, Stop: count - 1, Rev: rev})
+}
+
+// zalgebraGeneric returns the result of merge sorted by score.
+func (s *Store) zalgebraGeneric(keys [][]byte, merge func(iters []core.ZMemberIter, fn func(core.ZMember) bool) error) ([]core.ZMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	iters, err := s.zmemberIters(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []core.ZMember{}
+	err = merge(iters, func(m core.ZMember) bool {
+		res = append(res, core.ZMember{Member: bytes.Clone(m.Member), Score: m.Score})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(res, core.CompareZMembers)
+	return res, nil
+}
+
+// zstoreGeneric replaces dst with the result of merge.
+func (s *Store) zstoreGeneric(dst []byte, keys [][]byte, merge func(iters []core.ZMemberIter, fn func(core.ZMember) bool) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	iters, err := s.zmemberIters(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	res := []core.ZMember{}
+	err = merge(iters, func(m core.ZMember) bool {
+		res = append(res, m)
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.del(string(dst))
+	if len(res) == 0 {
+		return 0, nil
+	}
+	s.set(string(dst), newZSetOf(res))
+	return len(res), nil
+}
+
+// zmemberIters returns iterators over members of sorted sets and sets, missing keys are empty.
+func (s *Store) zmemberIters(keys [][]byte) ([]core.ZMemberIter, error) {
+	iters := make([]core.ZMemberIter, len(keys))
+	for i, key := range keys {
+		val, _ := s.get(key)
+
+		members := []core.ZMember{}
+		switch val := val.(type) {
+		case nil:
+		case *zset:
+			members = slices.Clone(val.sorted)
+			slices.SortFunc(members, func(a, b core.ZMember) int {
+				return bytes.Compare(a.Member, b.Member)
+			})
+		case set:
+			for _, member := range val.sorted() {
+				members = append(members, core.ZMember{Member: member, Score: 1})
+			}
+		default:
+			return nil, core.ErrWrongType
+		}
+		iters[i] = zsliceIter(members)
+	}
+	return iters, nil
+}
+
+func zsliceIter(members []core.ZMember) core.ZMemberIter {
+	return func() (core.ZMember, bool, error) {
+		if len(members) == 0 {
+			return core.ZMember{}, false, nil
+		}
+		m := members[0]
+		members = members[1:]
+		return m, true, nil
+	}
+}
+
+// zremRange removes and returns members in the range.
+func (s *Store) zremRange(key []byte, r core.ZRange) ([]core.ZMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	z, ok, err := s.loadZSet(key)
+	if err != nil || !ok {
+		return []core.ZMember{}, err
+	}
+
+	res := r.Slice(z.sorted)
+	for _, m := range res {
+		z.del(m.Member)
+	}
+	if z.len() == 0 {
+		s.del(string(key))
+	}
+	return res, nil
+}
+
+// cloneZMembers returns a copy of members that doesn't share memory with the store.
+func cloneZMembers(members []core.ZMember) []core.ZMember {
+	res := make([]core.ZMember, len(members))
+	for i, m := range members {
+		res[i] = core.ZMember{Member: bytes.Clone(m.Member), Score: m.Score}
+	}
+	return res
+}
+
+// getZSet is like get but fails for keys that are not sorted sets.
+func (s *Store) getZSet(key []byte) (*zset, bool, error) {
+	val, ok := s.get(key)
+	return asZSet(val, ok)
+}
+
+// loadZSet is like load but fails for keys that are not sorted sets.
+func (s *Store) loadZSet(key []byte) (*zset, bool, error) {
+	val, ok := s.load(key)
+	return asZSet(val, ok)
+}
+
+func asZSet(val any, ok bool) (*zset, bool, error) {
+	if !ok {
+		return nil, false, nil
+	}
+	z, isZSet := val.(*zset)
+	if !isZSet {
+		return nil, false, core.ErrWrongType
+	}
+	return z, true, nil
+}
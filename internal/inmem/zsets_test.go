@@ -0,0 +1,1057 @@
+package inmem
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cristalhq/testt"
+)
+
+func TestZADD(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one"
+		(integer) 1
+		redis> ZADD myzset 1 "uno"
+		(integer) 1
+		redis> ZADD myzset 2 "two" 3 "three"
+		(integer) 2
+		redis> ZRANGE myzset 0 -1 WITHSCORES
+		1) "one"
+		2) "1"
+		3) "uno"
+		4) "1"
+		5) "two"
+		6) "2"
+		7) "three"
+		8) "3"
+		redis>
+	*/
+
+	myzset := []byte("myzset")
+
+	s := New()
+	n, err := s.ZADD(myzset, core.ZAddOptions{}, core.ZMember{Member: []byte("one"), Score: 1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.ZADD(myzset, core.ZAddOptions{}, core.ZMember{Member: []byte("uno"), Score: 1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.ZADD(myzset, core.ZAddOptions{},
+		core.ZMember{Member: []byte("two"), Score: 2},
+		core.ZMember{Member: []byte("three"), Score: 3},
+	)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err := s.ZRANGE(myzset, core.ZRange{Start: 0, Stop: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{
+		{Member: []byte("one"), Score: 1},
+		{Member: []byte("uno"), Score: 1},
+		{Member: []byte("two"), Score: 2},
+		{Member: []byte("three"), Score: 3},
+	})
+
+	typ, err := s.TYPE(myzset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "zset")
+}
+
+func TestZADDOptions(t *testing.T) {
+	myzset := []byte("myzset")
+	one := func(score float64) core.ZMember {
+		return core.ZMember{Member: []byte("one"), Score: score}
+	}
+
+	s := New()
+	n, err := s.ZADD(myzset, core.ZAddOptions{XX: true}, one(1))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	typ, err := s.TYPE(myzset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "none")
+
+	n, err = s.ZADD(myzset, core.ZAddOptions{NX: true}, one(1))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.ZADD(myzset, core.ZAddOptions{NX: true, CH: true}, one(5))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	n, err = s.ZADD(myzset, core.ZAddOptions{GT: true, CH: true}, one(0))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	n, err = s.ZADD(myzset, core.ZAddOptions{GT: true, CH: true}, one(2))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.ZADD(myzset, core.ZAddOptions{LT: true, CH: true}, one(3))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	// same score is not a change.
+	n, err = s.ZADD(myzset, core.ZAddOptions{CH: true}, one(2))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	score, ok, err := s.ZSCORE(myzset, []byte("one"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+	testt.MustEqual(t, score, 2.0)
+
+	// INCR with a condition.
+	_, ok, err = s.ZINCRBY(myzset, -1, []byte("one"), core.ZAddOptions{GT: true})
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	score, ok, err = s.ZINCRBY(myzset, 1, []byte("one"), core.ZAddOptions{GT: true})
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+	testt.MustEqual(t, score, 3.0)
+}
+
+func TestZCARD(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one"
+		(integer) 1
+		redis> ZADD myzset 2 "two"
+		(integer) 1
+		redis> ZCARD myzset
+		(integer) 2
+		redis>
+	*/
+
+	myzset := []byte("myzset")
+
+	s := New()
+	n, err := s.ZADD(myzset, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+	)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	n, err = s.ZCARD(myzset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	_, _, err = s.SET([]byte("mystring"), []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+	_, err = s.ZCARD([]byte("mystring"))
+	testt.MustEqual(t, err, core.ErrWrongType)
+}
+
+func TestZCOUNT(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one"
+		(integer) 1
+		redis> ZADD myzset 2 "two"
+		(integer) 1
+		redis> ZADD myzset 3 "three"
+		(integer) 1
+		redis> ZCOUNT myzset -inf +inf
+		(integer) 3
+		redis> ZCOUNT myzset (1 3
+		(integer) 2
+		redis>
+	*/
+
+	myzset := []byte("myzset")
+
+	s := New()
+	n, err := s.ZADD(myzset, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+		core.ZMember{Member: []byte("three"), Score: 3},
+	)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	n, err = s.ZCOUNT(myzset, core.ScoreBound{Value: math.Inf(-1)}, core.ScoreBound{Value: math.Inf(1)})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	n, err = s.ZCOUNT(myzset, core.ScoreBound{Value: 1, Exclusive: true}, core.ScoreBound{Value: 3})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+}
+
+func TestZDIFF(t *testing.T) {
+	/*
+		redis> ZADD zset1 1 "one"
+		(integer) 1
+		redis> ZADD zset1 2 "two"
+		(integer) 1
+		redis> ZADD zset1 3 "three"
+		(integer) 1
+		redis> ZADD zset2 1 "one"
+		(integer) 1
+		redis> ZADD zset2 2 "two"
+		(integer) 1
+		redis> ZDIFF 2 zset1 zset2
+		1) "three"
+		redis> ZDIFF 2 zset1 zset2 WITHSCORES
+		1) "three"
+		2) "3"
+		redis> ZDIFFSTORE out 2 zset1 zset2
+		(integer) 1
+		redis> ZRANGE out 0 -1 WITHSCORES
+		1) "three"
+		2) "3"
+		redis>
+	*/
+
+	zset1, zset2, out := []byte("zset1"), []byte("zset2"), []byte("out")
+
+	s := New()
+	_, err := s.ZADD(zset1, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+		core.ZMember{Member: []byte("three"), Score: 3},
+	)
+	testt.NoError(t, err)
+	_, err = s.ZADD(zset2, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+	)
+	testt.NoError(t, err)
+
+	res, err := s.ZDIFF(zset1, zset2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{{Member: []byte("three"), Score: 3}})
+
+	n, err := s.ZDIFFSTORE(out, zset1, zset2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	res, err = s.ZRANGE(out, core.ZRange{Start: 0, Stop: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{{Member: []byte("three"), Score: 3}})
+
+	n, err = s.ZDIFFSTORE(out, zset2, zset1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	ok, err := s.EXISTS(out)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, 0)
+}
+
+func TestZINCRBY(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one"
+		(integer) 1
+		redis> ZADD myzset 2 "two"
+		(integer) 1
+		redis> ZINCRBY myzset 2 "one"
+		"3"
+		redis> ZRANGE myzset 0 -1 WITHSCORES
+		1) "two"
+		2) "2"
+		3) "one"
+		4) "3"
+		redis>
+	*/
+
+	myzset := []byte("myzset")
+
+	s := New()
+	n, err := s.ZADD(myzset, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+	)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	score, ok, err := s.ZINCRBY(myzset, 2, []byte("one"), core.ZAddOptions{})
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+	testt.MustEqual(t, score, 3.0)
+
+	res, err := s.ZRANGE(myzset, core.ZRange{Start: 0, Stop: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{
+		{Member: []byte("two"), Score: 2},
+		{Member: []byte("one"), Score: 3},
+	})
+
+	_, err = s.ZADD(myzset, core.ZAddOptions{}, core.ZMember{Member: []byte("inf"), Score: math.Inf(1)})
+	testt.NoError(t, err)
+	_, _, err = s.ZINCRBY(myzset, math.Inf(-1), []byte("inf"), core.ZAddOptions{})
+	testt.MustEqual(t, err, core.ErrScoreNaN)
+}
+
+func TestZINTER(t *testing.T) {
+	/*
+		redis> ZADD zset1 1 "one"
+		(integer) 1
+		redis> ZADD zset1 2 "two"
+		(integer) 1
+		redis> ZADD zset2 1 "one"
+		(integer) 1
+		redis> ZADD zset2 2 "two"
+		(integer) 1
+		redis> ZADD zset2 3 "three"
+		(integer) 1
+		redis> ZINTER 2 zset1 zset2
+		1) "one"
+		2) "two"
+		redis> ZINTER 2 zset1 zset2 WITHSCORES
+		1) "one"
+		2) "2"
+		3) "two"
+		4) "4"
+		redis> ZINTERSTORE out 2 zset1 zset2 WEIGHTS 2 3
+		(integer) 2
+		redis> ZRANGE out 0 -1 WITHSCORES
+		1) "one"
+		2) "5"
+		3) "two"
+		4) "10"
+		redis>
+	*/
+
+	zset1, zset2, out := []byte("zset1"), []byte("zset2"), []byte("out")
+
+	s := New()
+	_, err := s.ZADD(zset1, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+	)
+	testt.NoError(t, err)
+	_, err = s.ZADD(zset2, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+		core.ZMember{Member: []byte("three"), Score: 3},
+	)
+	testt.NoError(t, err)
+
+	res, err := s.ZINTER(core.ZAggOptions{}, zset1, zset2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{
+		{Member: []byte("one"), Score: 2},
+		{Member: []byte("two"), Score: 4},
+	})
+
+	n, err := s.ZINTERSTORE(out, core.ZAggOptions{Weights: []float64{2, 3}}, zset1, zset2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err = s.ZRANGE(out, core.ZRange{Start: 0, Stop: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{
+		{Member: []byte("one"), Score: 5},
+		{Member: []byte("two"), Score: 10},
+	})
+
+	res, err = s.ZINTER(core.ZAggOptions{Aggregate: core.ZAggregateMax}, zset1, zset2, []byte("missing"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{})
+
+	// dst can be one of the inputs.
+	n, err = s.ZINTERSTORE(zset2, core.ZAggOptions{Aggregate: core.ZAggregateMin}, zset1, zset2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err = s.ZRANGE(zset2, core.ZRange{Start: 0, Stop: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{
+		{Member: []byte("one"), Score: 1},
+		{Member: []byte("two"), Score: 2},
+	})
+}
+
+func TestZINTERCARD(t *testing.T) {
+	/*
+		redis> ZADD zset1 1 "one"
+		(integer) 1
+		redis> ZADD zset1 2 "two"
+		(integer) 1
+		redis> ZADD zset2 1 "one"
+		(integer) 1
+		redis> ZADD zset2 2 "two"
+		(integer) 1
+		redis> ZADD zset2 3 "three"
+		(integer) 1
+		redis> ZINTER 2 zset1 zset2
+		1) "one"
+		2) "two"
+		redis> ZINTERCARD 2 zset1 zset2
+		(integer) 2
+		redis> ZINTERCARD 2 zset1 zset2 LIMIT 1
+		(integer) 1
+		redis>
+	*/
+
+	zset1, zset2 := []byte("zset1"), []byte("zset2")
+
+	s := New()
+	_, err := s.ZADD(zset1, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+	)
+	testt.NoError(t, err)
+	_, err = s.ZADD(zset2, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+		core.ZMember{Member: []byte("three"), Score: 3},
+	)
+	testt.NoError(t, err)
+
+	n, err := s.ZINTERCARD(0, zset1, zset2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	n, err = s.ZINTERCARD(1, zset1, zset2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	_, _, err = s.SET([]byte("str"), []byte("v"), core.SetOptions{})
+	testt.NoError(t, err)
+	_, err = s.ZINTERCARD(0, zset1, []byte("str"))
+	testt.MustEqual(t, err, core.ErrWrongType)
+}
+
+func TestZLEXCOUNT(t *testing.T) {
+	/*
+		redis> ZADD myzset 0 a 0 b 0 c 0 d 0 e
+		(integer) 5
+		redis> ZADD myzset 0 f 0 g
+		(integer) 2
+		redis> ZLEXCOUNT myzset - +
+		(integer) 7
+		redis> ZLEXCOUNT myzset [b [f
+		(integer) 5
+		redis>
+	*/
+
+	myzset := []byte("myzset")
+
+	s := New()
+	for _, member := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		_, err := s.ZADD(myzset, core.ZAddOptions{}, core.ZMember{Member: []byte(member)})
+		testt.NoError(t, err)
+	}
+
+	n, err := s.ZLEXCOUNT(myzset, core.LexBound{Inf: -1}, core.LexBound{Inf: 1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 7)
+
+	n, err = s.ZLEXCOUNT(myzset, core.LexBound{Value: []byte("b")}, core.LexBound{Value: []byte("f")})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 5)
+}
+
+func TestZMSCORE(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one"
+		(integer) 1
+		redis> ZADD myzset 2 "two"
+		(integer) 1
+		redis> ZMSCORE myzset "one" "two" "nofield"
+		1) "1"
+		2) "2"
+		3) (nil)
+		redis>
+	*/
+
+	myzset := []byte("myzset")
+
+	s := New()
+	n, err := s.ZADD(myzset, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+	)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err := s.ZMSCORE(myzset, []byte("one"), []byte("two"), []byte("nofield"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 3)
+	testt.MustEqual(t, *res[0], 1.0)
+	testt.MustEqual(t, *res[1], 2.0)
+	testt.MustEqual(t, res[2], (*float64)(nil))
+}
+
+func TestZPOPMAX(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one"
+		(integer) 1
+		redis> ZADD myzset 2 "two"
+		(integer) 1
+		redis> ZADD myzset 3 "three"
+		(integer) 1
+		redis> ZPOPMAX myzset
+		1) "three"
+		2) "3"
+		redis> ZPOPMIN myzset 5
+		1) "one"
+		2) "1"
+		3) "two"
+		4) "2"
+		redis>
+	*/
+
+	myzset := []byte("myzset")
+
+	s := New()
+	n, err := s.ZADD(myzset, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+		core.ZMember{Member: []byte("three"), Score: 3},
+	)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	res, err := s.ZPOPMAX(myzset, 1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{{Member: []byte("three"), Score: 3}})
+
+	res, err = s.ZPOPMIN(myzset, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{})
+
+	res, err = s.ZPOPMIN(myzset, 5)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{
+		{Member: []byte("one"), Score: 1},
+		{Member: []byte("two"), Score: 2},
+	})
+
+	typ, err := s.TYPE(myzset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "none")
+}
+
+func TestZRANGE(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one" 2 "two" 3 "three"
+		(integer) 3
+		redis> ZRANGE myzset 0 -1
+		1) "one"
+		2) "two"
+		3) "three"
+		redis> ZRANGE myzset 2 3
+		1) "three"
+		redis> ZRANGE myzset -2 -1
+		1) "two"
+		2) "three"
+		redis> ZRANGE myzset 0 1 REV
+		1) "three"
+		2) "two"
+		redis> ZRANGE myzset (1 +inf BYSCORE LIMIT 1 1
+		1) "three"
+		redis> ZRANGE myzset +inf 2 BYSCORE REV
+		1) "three"
+		2) "two"
+		redis>
+	*/
+
+	myzset := []byte("myzset")
+	inf := math.Inf(1)
+
+	s := New()
+	n, err := s.ZADD(myzset, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+		core.ZMember{Member: []byte("three"), Score: 3},
+	)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	members := func(r core.ZRange) []string {
+		res, err := s.ZRANGE(myzset, r)
+		testt.NoError(t, err)
+		names := []string{}
+		for _, m := range res {
+			names = append(names, string(m.Member))
+		}
+		return names
+	}
+
+	testt.MustEqual(t, members(core.ZRange{Start: 0, Stop: -1}), []string{"one", "two", "three"})
+	testt.MustEqual(t, members(core.ZRange{Start: 2, Stop: 3}), []string{"three"})
+	testt.MustEqual(t, members(core.ZRange{Start: -2, Stop: -1}), []string{"two", "three"})
+	testt.MustEqual(t, members(core.ZRange{Start: 0, Stop: 1, Rev: true}), []string{"three", "two"})
+	testt.MustEqual(t, members(core.ZRange{Start: 5, Stop: 10}), []string{})
+
+	testt.MustEqual(t, members(core.ZRange{
+		By:    core.ZRangeByScore,
+		Min:   core.ScoreBound{Value: 1, Exclusive: true},
+		Max:   core.ScoreBound{Value: inf},
+		Limit: true, Offset: 1, Count: 1,
+	}), []string{"three"})
+	testt.MustEqual(t, members(core.ZRange{
+		By:  core.ZRangeByScore,
+		Min: core.ScoreBound{Value: 2},
+		Max: core.ScoreBound{Value: inf},
+		Rev: true,
+	}), []string{"three", "two"})
+	testt.MustEqual(t, members(core.ZRange{
+		By:  core.ZRangeByScore,
+		Min: core.ScoreBound{Value: 2},
+		Max: core.ScoreBound{Value: 2, Exclusive: true},
+	}), []string{})
+	testt.MustEqual(t, members(core.ZRange{
+		By:    core.ZRangeByScore,
+		Min:   core.ScoreBound{Value: -inf},
+		Max:   core.ScoreBound{Value: inf},
+		Limit: true, Offset: 1, Count: -1,
+	}), []string{"two", "three"})
+}
+
+func TestZRANGEBYLEX(t *testing.T) {
+	/*
+		redis> ZADD myzset 0 a 0 b 0 c 0 d 0 e 0 f 0 g
+		(integer) 7
+		redis> ZRANGEBYLEX myzset - [c
+		1) "a"
+		2) "b"
+		3) "c"
+		redis> ZRANGEBYLEX myzset - (c
+		1) "a"
+		2) "b"
+		redis> ZRANGEBYLEX myzset [aaa (g
+		1) "b"
+		2) "c"
+		3) "d"
+		4) "e"
+		5) "f"
+		redis> ZREVRANGEBYLEX myzset (g [aaa LIMIT 1 2
+		1) "e"
+		2) "d"
+		redis>
+	*/
+
+	myzset := []byte("myzset")
+
+	s := New()
+	for _, member := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		_, err := s.ZADD(myzset, core.ZAddOptions{}, core.ZMember{Member: []byte(member)})
+		testt.NoError(t, err)
+	}
+
+	members := func(r core.ZRange) []string {
+		r.By = core.ZRangeByLex
+		res, err := s.ZRANGE(myzset, r)
+		testt.NoError(t, err)
+		names := []string{}
+		for _, m := range res {
+			names = append(names, string(m.Member))
+		}
+		return names
+	}
+
+	testt.MustEqual(t, members(core.ZRange{
+		LexMin: core.LexBound{Inf: -1},
+		LexMax: core.LexBound{Value: []byte("c")},
+	}), []string{"a", "b", "c"})
+	testt.MustEqual(t, members(core.ZRange{
+		LexMin: core.LexBound{Inf: -1},
+		LexMax: core.LexBound{Value: []byte("c"), Exclusive: true},
+	}), []string{"a", "b"})
+	testt.MustEqual(t, members(core.ZRange{
+		LexMin: core.LexBound{Value: []byte("aaa")},
+		LexMax: core.LexBound{Value: []byte("g"), Exclusive: true},
+	}), []string{"b", "c", "d", "e", "f"})
+	testt.MustEqual(t, members(core.ZRange{
+		LexMin: core.LexBound{Value: []byte("aaa")},
+		LexMax: core.LexBound{Value: []byte("g"), Exclusive: true},
+		Rev:    true,
+		Limit:  true, Offset: 1, Count: 2,
+	}), []string{"e", "d"})
+	testt.MustEqual(t, members(core.ZRange{
+		LexMin: core.LexBound{Inf: 1},
+		LexMax: core.LexBound{Inf: -1},
+	}), []string{})
+}
+
+func TestZRANGESTORE(t *testing.T) {
+	/*
+		redis> ZADD srczset 1 "one" 2 "two" 3 "three" 4 "four"
+		(integer) 4
+		redis> ZRANGESTORE dstzset srczset 2 -1
+		(integer) 2
+		redis> ZRANGE dstzset 0 -1
+		1) "three"
+		2) "four"
+		redis>
+	*/
+
+	srczset, dstzset := []byte("srczset"), []byte("dstzset")
+
+	s := New()
+	n, err := s.ZADD(srczset, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+		core.ZMember{Member: []byte("three"), Score: 3},
+		core.ZMember{Member: []byte("four"), Score: 4},
+	)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 4)
+
+	n, err = s.ZRANGESTORE(dstzset, srczset, core.ZRange{Start: 2, Stop: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err := s.ZRANGE(dstzset, core.ZRange{Start: 0, Stop: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{
+		{Member: []byte("three"), Score: 3},
+		{Member: []byte("four"), Score: 4},
+	})
+
+	// source can be the destination.
+	n, err = s.ZRANGESTORE(srczset, srczset, core.ZRange{Start: 0, Stop: 0})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.ZCARD(srczset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	// empty range removes the destination.
+	n, err = s.ZRANGESTORE(dstzset, srczset, core.ZRange{Start: 5, Stop: 10})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	typ, err := s.TYPE(dstzset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "none")
+}
+
+func TestZRANK(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one"
+		(integer) 1
+		redis> ZADD myzset 2 "two"
+		(integer) 1
+		redis> ZADD myzset 3 "three"
+		(integer) 1
+		redis> ZRANK myzset "three"
+		(integer) 2
+		redis> ZRANK myzset "four"
+		(nil)
+		redis> ZRANK myzset "three" WITHSCORE
+		1) (integer) 2
+		2) "3"
+		redis> ZREVRANK myzset "one"
+		(integer) 2
+		redis>
+	*/
+
+	myzset := []byte("myzset")
+
+	s := New()
+	n, err := s.ZADD(myzset, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+		core.ZMember{Member: []byte("three"), Score: 3},
+	)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	rank, score, ok, err := s.ZRANK(myzset, []byte("three"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+	testt.MustEqual(t, rank, 2)
+	testt.MustEqual(t, score, 3.0)
+
+	_, _, ok, err = s.ZRANK(myzset, []byte("four"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	rank, score, ok, err = s.ZREVRANK(myzset, []byte("one"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+	testt.MustEqual(t, rank, 2)
+	testt.MustEqual(t, score, 1.0)
+}
+
+func TestZREM(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one"
+		(integer) 1
+		redis> ZADD myzset 2 "two"
+		(integer) 1
+		redis> ZADD myzset 3 "three"
+		(integer) 1
+		redis> ZREM myzset "two"
+		(integer) 1
+		redis> ZRANGE myzset 0 -1 WITHSCORES
+		1) "one"
+		2) "1"
+		3) "three"
+		4) "3"
+		redis>
+	*/
+
+	myzset := []byte("myzset")
+
+	s := New()
+	n, err := s.ZADD(myzset, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+		core.ZMember{Member: []byte("three"), Score: 3},
+	)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	n, err = s.ZREM(myzset, []byte("two"), []byte("four"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	res, err := s.ZRANGE(myzset, core.ZRange{Start: 0, Stop: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{
+		{Member: []byte("one"), Score: 1},
+		{Member: []byte("three"), Score: 3},
+	})
+}
+
+func TestZREMRANGE(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one" 2 "two" 3 "three" 4 "four"
+		(integer) 4
+		redis> ZREMRANGEBYRANK myzset 0 0
+		(integer) 1
+		redis> ZREMRANGEBYSCORE myzset -inf (3
+		(integer) 1
+		redis> ZRANGE myzset 0 -1
+		1) "three"
+		2) "four"
+		redis>
+	*/
+
+	myzset := []byte("myzset")
+
+	s := New()
+	n, err := s.ZADD(myzset, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+		core.ZMember{Member: []byte("three"), Score: 3},
+		core.ZMember{Member: []byte("four"), Score: 4},
+	)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 4)
+
+	n, err = s.ZREMRANGEBYRANK(myzset, 0, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.ZREMRANGEBYSCORE(myzset, core.ScoreBound{Value: math.Inf(-1)}, core.ScoreBound{Value: 3, Exclusive: true})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	res, err := s.ZRANGE(myzset, core.ZRange{Start: 0, Stop: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{
+		{Member: []byte("three"), Score: 3},
+		{Member: []byte("four"), Score: 4},
+	})
+}
+
+func TestZREMRANGEBYLEX(t *testing.T) {
+	/*
+		redis> ZADD myzset 0 aaaa 0 b 0 c 0 d 0 e
+		(integer) 5
+		redis> ZADD myzset 0 foo 0 zap 0 zip 0 ALPHA 0 alpha
+		(integer) 5
+		redis> ZREMRANGEBYLEX myzset [alpha [omega
+		(integer) 6
+		redis> ZRANGE myzset 0 -1
+		1) "ALPHA"
+		2) "aaaa"
+		3) "zap"
+		4) "zip"
+		redis>
+	*/
+
+	myzset := []byte("myzset")
+
+	s := New()
+	for _, member := range []string{"aaaa", "b", "c", "d", "e", "foo", "zap", "zip", "ALPHA", "alpha"} {
+		_, err := s.ZADD(myzset, core.ZAddOptions{}, core.ZMember{Member: []byte(member)})
+		testt.NoError(t, err)
+	}
+
+	n, err := s.ZREMRANGEBYLEX(myzset, core.LexBound{Value: []byte("alpha")}, core.LexBound{Value: []byte("omega")})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 6)
+
+	res, err := s.ZRANGE(myzset, core.ZRange{Start: 0, Stop: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{
+		{Member: []byte("ALPHA")},
+		{Member: []byte("aaaa")},
+		{Member: []byte("zap")},
+		{Member: []byte("zip")},
+	})
+}
+
+func TestZSCORE(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one"
+		(integer) 1
+		redis> ZSCORE myzset "one"
+		"1"
+		redis>
+	*/
+
+	myzset := []byte("myzset")
+
+	s := New()
+	n, err := s.ZADD(myzset, core.ZAddOptions{}, core.ZMember{Member: []byte("one"), Score: 1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	score, ok, err := s.ZSCORE(myzset, []byte("one"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+	testt.MustEqual(t, score, 1.0)
+
+	_, ok, err = s.ZSCORE(myzset, []byte("two"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+}
+
+func TestZUNION(t *testing.T) {
+	/*
+		redis> ZADD zset1 1 "one"
+		(integer) 1
+		redis> ZADD zset1 2 "two"
+		(integer) 1
+		redis> ZADD zset2 1 "one"
+		(integer) 1
+		redis> ZADD zset2 2 "two"
+		(integer) 1
+		redis> ZADD zset2 3 "three"
+		(integer) 1
+		redis> ZUNION 2 zset1 zset2
+		1) "one"
+		2) "three"
+		3) "two"
+		redis> ZUNION 2 zset1 zset2 WITHSCORES
+		1) "one"
+		2) "2"
+		3) "three"
+		4) "3"
+		5) "two"
+		6) "4"
+		redis> ZUNIONSTORE out 2 zset1 zset2 WEIGHTS 2 3
+		(integer) 3
+		redis> ZRANGE out 0 -1 WITHSCORES
+		1) "one"
+		2) "5"
+		3) "three"
+		4) "9"
+		5) "two"
+		6) "10"
+		redis>
+	*/
+
+	zset1, zset2, out := []byte("zset1"), []byte("zset2"), []byte("out")
+
+	s := New()
+	_, err := s.ZADD(zset1, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+	)
+	testt.NoError(t, err)
+	_, err = s.ZADD(zset2, core.ZAddOptions{},
+		core.ZMember{Member: []byte("one"), Score: 1},
+		core.ZMember{Member: []byte("two"), Score: 2},
+		core.ZMember{Member: []byte("three"), Score: 3},
+	)
+	testt.NoError(t, err)
+
+	res, err := s.ZUNION(core.ZAggOptions{}, zset1, zset2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{
+		{Member: []byte("one"), Score: 2},
+		{Member: []byte("three"), Score: 3},
+		{Member: []byte("two"), Score: 4},
+	})
+
+	n, err := s.ZUNIONSTORE(out, core.ZAggOptions{Weights: []float64{2, 3}}, zset1, zset2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	res, err = s.ZRANGE(out, core.ZRange{Start: 0, Stop: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{
+		{Member: []byte("one"), Score: 5},
+		{Member: []byte("three"), Score: 9},
+		{Member: []byte("two"), Score: 10},
+	})
+}
+
+func TestZUNIONWithSet(t *testing.T) {
+	zset, set := []byte("zset"), []byte("set")
+
+	s := New()
+	_, err := s.ZADD(zset, core.ZAddOptions{},
+		core.ZMember{Member: []byte("a"), Score: 5},
+		core.ZMember{Member: []byte("b"), Score: -1},
+	)
+	testt.NoError(t, err)
+	_, err = s.SADD(set, []byte("b"), []byte("c"))
+	testt.NoError(t, err)
+
+	res, err := s.ZUNION(core.ZAggOptions{Aggregate: core.ZAggregateMax}, zset, set)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{
+		{Member: []byte("b"), Score: 1},
+		{Member: []byte("c"), Score: 1},
+		{Member: []byte("a"), Score: 5},
+	})
+
+	res, err = s.ZDIFF(set, zset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{{Member: []byte("c"), Score: 1}})
+
+	// Inputs missing on every side give an empty result and no key.
+	n, err := s.ZUNIONSTORE(zset, core.ZAggOptions{}, []byte("missing"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	ok, err := s.EXISTS(zset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, 0)
+}
+
+func TestZSetNegativeScores(t *testing.T) {
+	myzset := []byte("myzset")
+
+	s := New()
+	n, err := s.ZADD(myzset, core.ZAddOptions{},
+		core.ZMember{Member: []byte("a"), Score: -1.5},
+		core.ZMember{Member: []byte("b"), Score: 0},
+		core.ZMember{Member: []byte("c"), Score: math.Inf(-1)},
+		core.ZMember{Member: []byte("d"), Score: 1e300},
+		core.ZMember{Member: []byte("e"), Score: -1e-300},
+	)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 5)
+
+	res, err := s.ZRANGE(myzset, core.ZRange{Start: 0, Stop: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.ZMember{
+		{Member: []byte("c"), Score: math.Inf(-1)},
+		{Member: []byte("a"), Score: -1.5},
+		{Member: []byte("e"), Score: -1e-300},
+		{Member: []byte("b"), Score: 0},
+		{Member: []byte("d"), Score: 1e300},
+	})
+
+	n, err = s.ZCOUNT(myzset, core.ScoreBound{Value: -1.5, Exclusive: true}, core.ScoreBound{Value: 0})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+}
@@ -0,0 +1,305 @@
+package ondisk
+
+import (
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Bitmaps operations https://redis.io/commands/?group=bitmap
+
+// Bitmaps are strings, long ones are chunked (see strings.go), so single bits
+// and fields are updated in place and ranges are scanned in windows.
+
+// bitmapWindow is the number of bytes scanned at once, a multiple of stringChunkSize.
+const bitmapWindow = 64 * stringChunkSize
+
+func (s *Store) BITCOUNT(key []byte, rng core.BitRange) (int64, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, ok, err := getString(snap, key)
+	if err != nil || !ok || rng.Empty() {
+		return 0, err
+	}
+	first, last, ok := rng.Bits(stringLen(m))
+	if !ok {
+		return 0, nil
+	}
+
+	var n int64
+	err = scanBits(snap, key, m, first, last, func(_ int64, val []byte, first, last int64) bool {
+		n += core.BitCount(val, first, last)
+		return true
+	})
+	return n, err
+}
+
+func (s *Store) BITFIELD(key []byte, ops []core.BitFieldOp) ([]*int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, ok, err := loadString(b, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		m = newString(nil)
+	}
+
+	// the value is grown for all writes upfront, even for the ones that fail.
+	var size int64
+	for _, op := range ops {
+		if op.Kind != core.BitFieldGet {
+			size = max(size, op.End())
+		}
+	}
+	if size != 0 {
+		if err := s.growString(b, key, &m, size); err != nil {
+			return nil, err
+		}
+		if err := putMeta(b, key, m); err != nil {
+			return nil, err
+		}
+	}
+
+	res := make([]*int64, 0, len(ops))
+	for _, op := range ops {
+		at := op.Offset >> 3
+		val, err := readRange(b, key, m, at, op.End()-at)
+		if err != nil {
+			return nil, err
+		}
+		op.Offset -= at * 8
+		res = append(res, op.Exec(val))
+
+		if op.Kind == core.BitFieldGet {
+			continue
+		}
+		if err := s.writeRange(b, key, &m, at, val); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Store) BITFIELDRO(key []byte, ops []core.BitFieldOp) ([]int64, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, _, err := getString(snap, key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]int64, 0, len(ops))
+	for _, op := range ops {
+		at := op.Offset >> 3
+		val, err := readRange(snap, key, m, at, op.End()-at)
+		if err != nil {
+			return nil, err
+		}
+		op.Offset -= at * 8
+		res = append(res, *op.Exec(val))
+	}
+	return res, nil
+}
+
+func (s *Store) BITOP(op core.BitOp, dst []byte, keys ...[]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	metas := make([]meta, len(keys))
+	var size int64
+	for i, key := range keys {
+		m, _, err := loadString(b, key)
+		if err != nil {
+			return 0, err
+		}
+		metas[i] = m
+		size = max(size, stringLen(m))
+	}
+	old, ok, err := loadMeta(b, dst)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case size == 0:
+		if ok {
+			if err := delKey(b, dst, old); err != nil {
+				return 0, err
+			}
+		}
+		return 0, b.Commit(s.syncOpt)
+
+	case size <= stringChunkSize:
+		val, err := bitOp(b, op, keys, metas, 0, size)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := s.setString(b, dst, val, old); err != nil {
+			return 0, err
+		}
+		return int(size), b.Commit(s.syncOpt)
+	}
+
+	// the result is written under a new version, so dst can be one of the sources.
+	m := newString(nil)
+	if err := s.growString(b, dst, &m, size); err != nil {
+		return 0, err
+	}
+	for at := int64(0); at < size; at += bitmapWindow {
+		val, err := bitOp(b, op, keys, metas, at, min(bitmapWindow, size-at))
+		if err != nil {
+			return 0, err
+		}
+		for i := 0; i < len(val); i += stringChunkSize {
+			// chunks are always full, the last one is padded with zeros.
+			chunk := make([]byte, stringChunkSize)
+			copy(chunk, val[i:])
+			if isZero(chunk) {
+				continue
+			}
+			k := chunkKey(dst, m.version, (at+int64(i))/stringChunkSize)
+			if err := b.Set(k, chunk, nil); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if ok {
+		if err := delKey(b, dst, old); err != nil {
+			return 0, err
+		}
+	}
+	if err := putMeta(b, dst, m); err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return int(size), nil
+}
+
+func (s *Store) BITPOS(key []byte, bit int, rng core.BitRange, hasEnd bool) (int64, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, ok, err := getString(snap, key)
+	if err != nil {
+		return 0, err
+	}
+	// a missing key is an infinite array of clear bits.
+	if !ok {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+	n := stringLen(m)
+	first, last, ok := rng.Bits(n)
+	if !ok {
+		return -1, nil
+	}
+
+	pos := int64(-1)
+	err = scanBits(snap, key, m, first, last, func(base int64, val []byte, first, last int64) bool {
+		if p := core.BitPos(val, bit, first, last); p != -1 {
+			pos = base + p
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if pos == -1 && bit == 0 && !hasEnd {
+		return n * 8, nil
+	}
+	return pos, nil
+}
+
+func (s *Store) GETBIT(key []byte, offset int64) (int, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, ok, err := getString(snap, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	val, err := readRange(snap, key, m, offset>>3, 1)
+	if err != nil {
+		return 0, err
+	}
+	return core.GetBit(val, offset&7), nil
+}
+
+func (s *Store) SETBIT(key []byte, offset int64, bit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, ok, err := loadString(b, key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		m = newString(nil)
+	}
+
+	val, err := readRange(b, key, m, offset>>3, 1)
+	if err != nil {
+		return 0, err
+	}
+	old := core.SetBit(val, offset&7, bit)
+	if err := s.writeRange(b, key, &m, offset>>3, val); err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return old, nil
+}
+
+// scanBits calls fn for windows of the string covering bits from first to last (both inclusive),
+// until fn returns false. Base is the offset of the window, first and last are relative to it.
+func scanBits(r pebble.Reader, key []byte, m meta, first, last int64, fn func(base int64, val []byte, first, last int64) bool) error {
+	for at := first >> 3; at <= last>>3; at += bitmapWindow {
+		n := min(bitmapWindow, last>>3+1-at)
+		val, err := readRange(r, key, m, at, n)
+		if err != nil {
+			return err
+		}
+		base := at * 8
+		if !fn(base, val, max(first, base)-base, min(last, base+n*8-1)-base) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// bitOp returns n bytes of the result of the operation over keys starting at offset.
+func bitOp(r pebble.Reader, op core.BitOp, keys [][]byte, metas []meta, offset, n int64) ([]byte, error) {
+	srcs := make([][]byte, len(keys))
+	for i := range keys {
+		val, err := readRange(r, keys[i], metas[i], offset, n)
+		if err != nil {
+			return nil, err
+		}
+		srcs[i] = val
+	}
+	res := make([]byte, n)
+	op.Apply(res, srcs)
+	return res, nil
+}
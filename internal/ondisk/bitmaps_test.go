@@ -0,0 +1,536 @@
+package ondisk
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cristalhq/testt"
+)
+
+func TestBITCOUNT(t *testing.T) {
+	/*
+		redis> SET mykey "foobar"
+		"OK"
+		redis> BITCOUNT mykey
+		(integer) 26
+		redis> BITCOUNT mykey 0 0
+		(integer) 4
+		redis> BITCOUNT mykey 1 1
+		(integer) 6
+		redis> BITCOUNT mykey 1 1 BYTE
+		(integer) 6
+		redis> BITCOUNT mykey 5 30 BIT
+		(integer) 17
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := newStore(t)
+	_, _, err := s.SET(mykey, []byte("foobar"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	n, err := s.BITCOUNT(mykey, core.FullBitRange)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(26))
+
+	n, err = s.BITCOUNT(mykey, core.BitRange{Start: 0, End: 0})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(4))
+
+	n, err = s.BITCOUNT(mykey, core.BitRange{Start: 1, End: 1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(6))
+
+	n, err = s.BITCOUNT(mykey, core.BitRange{Start: 5, End: 30, Bit: true})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(17))
+
+	n, err = s.BITCOUNT(mykey, core.BitRange{Start: -1, End: -2})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+
+	n, err = s.BITCOUNT([]byte("nokey"), core.FullBitRange)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+}
+
+func TestBITFIELD(t *testing.T) {
+	/*
+		redis> BITFIELD mykey INCRBY i5 100 1 GET u4 0
+		1) (integer) 1
+		2) (integer) 0
+		redis> BITFIELD counters incrby u2 100 1 OVERFLOW SAT incrby u2 102 1
+		1) (integer) 1
+		2) (integer) 1
+		redis> BITFIELD counters incrby u2 100 1 OVERFLOW SAT incrby u2 102 1
+		1) (integer) 2
+		2) (integer) 2
+		redis> BITFIELD counters incrby u2 100 1 OVERFLOW SAT incrby u2 102 1
+		1) (integer) 3
+		2) (integer) 3
+		redis> BITFIELD counters incrby u2 100 1 OVERFLOW SAT incrby u2 102 1
+		1) (integer) 0
+		2) (integer) 3
+		redis> BITFIELD counters OVERFLOW FAIL incrby u2 102 1
+		1) (nil)
+		redis> STRLEN counters
+		(integer) 13
+		redis>
+	*/
+
+	mykey, counters := []byte("mykey"), []byte("counters")
+
+	s := newStore(t)
+	res, err := s.BITFIELD(mykey, []core.BitFieldOp{
+		{Kind: core.BitFieldIncrBy, Signed: true, Width: 5, Offset: 100, Value: 1},
+		{Kind: core.BitFieldGet, Width: 4, Offset: 0},
+	})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []*int64{ptr(1), ptr(0)})
+
+	ops := []core.BitFieldOp{
+		{Kind: core.BitFieldIncrBy, Width: 2, Offset: 100, Value: 1},
+		{Kind: core.BitFieldIncrBy, Width: 2, Offset: 102, Value: 1, Overflow: core.BitFieldSat},
+	}
+	for _, want := range [][2]int64{{1, 1}, {2, 2}, {3, 3}, {0, 3}} {
+		res, err = s.BITFIELD(counters, ops)
+		testt.NoError(t, err)
+		testt.MustEqual(t, res, []*int64{ptr(want[0]), ptr(want[1])})
+	}
+
+	res, err = s.BITFIELD(counters, []core.BitFieldOp{
+		{Kind: core.BitFieldIncrBy, Width: 2, Offset: 102, Value: 1, Overflow: core.BitFieldFail},
+	})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []*int64{nil})
+
+	size, err := s.STRLEN(counters)
+	testt.NoError(t, err)
+	testt.MustEqual(t, size, int64(13))
+}
+
+func TestBITFIELDOverflow(t *testing.T) {
+	key := []byte("key")
+
+	s := newStore(t)
+	res, err := s.BITFIELD(key, []core.BitFieldOp{
+		{Kind: core.BitFieldSet, Signed: true, Width: 8, Offset: 0, Value: 100},
+		{Kind: core.BitFieldIncrBy, Signed: true, Width: 8, Offset: 0, Value: 100},
+		{Kind: core.BitFieldIncrBy, Signed: true, Width: 8, Offset: 0, Value: -100, Overflow: core.BitFieldSat},
+		{Kind: core.BitFieldIncrBy, Signed: true, Width: 8, Offset: 0, Value: -100, Overflow: core.BitFieldSat},
+		{Kind: core.BitFieldSet, Width: 8, Offset: 8, Value: 300},
+		{Kind: core.BitFieldSet, Width: 8, Offset: 8, Value: -1, Overflow: core.BitFieldSat},
+		{Kind: core.BitFieldSet, Width: 8, Offset: 8, Value: 256, Overflow: core.BitFieldFail},
+		{Kind: core.BitFieldGet, Width: 8, Offset: 8},
+	})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []*int64{ptr(0), ptr(-56), ptr(-128), ptr(-128), ptr(0), ptr(44), nil, ptr(255)})
+
+	res, err = s.BITFIELD(key, []core.BitFieldOp{
+		{Kind: core.BitFieldSet, Signed: true, Width: 64, Offset: 16, Value: math.MaxInt64},
+		{Kind: core.BitFieldIncrBy, Signed: true, Width: 64, Offset: 16, Value: 1},
+		{Kind: core.BitFieldIncrBy, Signed: true, Width: 64, Offset: 16, Value: -1, Overflow: core.BitFieldSat},
+		{Kind: core.BitFieldSet, Width: 63, Offset: 80, Value: math.MaxInt64},
+		{Kind: core.BitFieldIncrBy, Width: 63, Offset: 80, Value: 1},
+	})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []*int64{ptr(0), ptr(math.MinInt64), ptr(math.MinInt64), ptr(0), ptr(0)})
+}
+
+func TestBITFIELDRO(t *testing.T) {
+	/*
+		redis> SET hello "Hello world"
+		"OK"
+		redis> BITFIELD_RO hello GET i8 16
+		1) (integer) 108
+		redis>
+	*/
+
+	hello := []byte("hello")
+
+	s := newStore(t)
+	_, _, err := s.SET(hello, []byte("Hello world"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	res, err := s.BITFIELDRO(hello, []core.BitFieldOp{
+		{Kind: core.BitFieldGet, Signed: true, Width: 8, Offset: 16},
+		{Kind: core.BitFieldGet, Width: 16, Offset: 1000},
+	})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{108, 0})
+}
+
+func TestBITOP(t *testing.T) {
+	/*
+		redis> SET key1 "foobar"
+		"OK"
+		redis> SET key2 "abcdef"
+		"OK"
+		redis> BITOP AND dest key1 key2
+		(integer) 6
+		redis> GET dest
+		"`bc`ab"
+		redis>
+	*/
+
+	key1, key2, dest := []byte("key1"), []byte("key2"), []byte("dest")
+
+	s := newStore(t)
+	_, _, err := s.SET(key1, []byte("foobar"), core.SetOptions{})
+	testt.NoError(t, err)
+	_, _, err = s.SET(key2, []byte("abcdef"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	n, err := s.BITOP(core.BitAnd, dest, key1, key2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 6)
+
+	val, err := s.GET(dest)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "`bc`ab")
+
+	_, _, err = s.SET(key2, []byte("\xff"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	n, err = s.BITOP(core.BitOr, dest, key2, []byte("nokey"), key1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 6)
+
+	val, err = s.GET(dest)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "\xffoobar")
+
+	n, err = s.BITOP(core.BitXor, dest, key1, key1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 6)
+
+	val, err = s.GET(dest)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "\x00\x00\x00\x00\x00\x00")
+
+	n, err = s.BITOP(core.BitNot, dest, key2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	val, err = s.GET(dest)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "\x00")
+
+	n, err = s.BITOP(core.BitNot, dest, []byte("nokey"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	_, err = s.GET(dest)
+	testt.MustEqual(t, err, core.ErrKeyNotFound)
+
+	_, err = s.LPUSH([]byte("list"), []byte("a"))
+	testt.NoError(t, err)
+
+	_, err = s.BITOP(core.BitAnd, dest, key1, []byte("list"))
+	testt.MustEqual(t, err, core.ErrWrongType)
+}
+
+func TestBITPOS(t *testing.T) {
+	/*
+		redis> SET mykey "\xff\xf0\x00"
+		"OK"
+		redis> BITPOS mykey 0
+		(integer) 12
+		redis> SET mykey "\x00\xff\xf0"
+		"OK"
+		redis> BITPOS mykey 1 0
+		(integer) 8
+		redis> BITPOS mykey 1 2
+		(integer) 16
+		redis> BITPOS mykey 1 2 -1 BYTE
+		(integer) 16
+		redis> BITPOS mykey 1 7 15 BIT
+		(integer) 8
+		redis> set mykey "\x00\x00\x00"
+		"OK"
+		redis> BITPOS mykey 1
+		(integer) -1
+		redis> BITPOS mykey 1 7 -3 BIT
+		(integer) -1
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := newStore(t)
+	_, _, err := s.SET(mykey, []byte("\xff\xf0\x00"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	pos, err := s.BITPOS(mykey, 0, core.FullBitRange, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(12))
+
+	_, _, err = s.SET(mykey, []byte("\x00\xff\xf0"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	pos, err = s.BITPOS(mykey, 1, core.BitRange{Start: 0, End: -1}, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(8))
+
+	pos, err = s.BITPOS(mykey, 1, core.BitRange{Start: 2, End: -1}, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(16))
+
+	pos, err = s.BITPOS(mykey, 1, core.BitRange{Start: 2, End: -1}, true)
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(16))
+
+	pos, err = s.BITPOS(mykey, 1, core.BitRange{Start: 7, End: 15, Bit: true}, true)
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(8))
+
+	_, _, err = s.SET(mykey, []byte("\x00\x00\x00"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	pos, err = s.BITPOS(mykey, 1, core.FullBitRange, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(-1))
+
+	pos, err = s.BITPOS(mykey, 1, core.BitRange{Start: 7, End: -3, Bit: true}, true)
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(-1))
+
+	// clear bit right after the value is reported only without end.
+	_, _, err = s.SET(mykey, []byte("\xff\xff"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	pos, err = s.BITPOS(mykey, 0, core.FullBitRange, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(16))
+
+	pos, err = s.BITPOS(mykey, 0, core.FullBitRange, true)
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(-1))
+
+	pos, err = s.BITPOS([]byte("nokey"), 0, core.FullBitRange, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(0))
+
+	pos, err = s.BITPOS([]byte("nokey"), 1, core.FullBitRange, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(-1))
+}
+
+func TestGETBIT(t *testing.T) {
+	/*
+		redis> SETBIT mykey 7 1
+		(integer) 0
+		redis> GETBIT mykey 0
+		(integer) 0
+		redis> GETBIT mykey 7
+		(integer) 1
+		redis> GETBIT mykey 100
+		(integer) 0
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := newStore(t)
+	old, err := s.SETBIT(mykey, 7, 1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, old, 0)
+
+	bit, err := s.GETBIT(mykey, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, bit, 0)
+
+	bit, err = s.GETBIT(mykey, 7)
+	testt.NoError(t, err)
+	testt.MustEqual(t, bit, 1)
+
+	bit, err = s.GETBIT(mykey, 100)
+	testt.NoError(t, err)
+	testt.MustEqual(t, bit, 0)
+}
+
+func TestSETBIT(t *testing.T) {
+	/*
+		redis> SETBIT mykey 7 1
+		(integer) 0
+		redis> SETBIT mykey 7 0
+		(integer) 1
+		redis> GET mykey
+		"\x00"
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := newStore(t)
+	old, err := s.SETBIT(mykey, 7, 1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, old, 0)
+
+	old, err = s.SETBIT(mykey, 7, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, old, 1)
+
+	val, err := s.GET(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "\x00")
+
+	old, err = s.SETBIT(mykey, 23, 1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, old, 0)
+
+	val, err = s.GET(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "\x00\x00\x01")
+
+	_, err = s.SETBIT(mykey, core.MaxStringSize*8, 1)
+	testt.MustEqual(t, err, core.ErrStringTooLong)
+}
+
+func TestBitmapChunks(t *testing.T) {
+	mykey, other := []byte("mykey"), []byte("other")
+	offset := int64(8<<20 + 5)
+
+	dir := t.TempDir()
+	s, err := Open(Config{Dir: dir})
+	testt.NoError(t, err)
+
+	old, err := s.SETBIT(mykey, offset, 1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, old, 0)
+
+	size, err := s.STRLEN(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, size, int64(1<<20+1))
+
+	// only the touched chunk is written.
+	testt.MustEqual(t, countChunks(t, s, mykey), 1)
+
+	n, err := s.BITCOUNT(mykey, core.FullBitRange)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	pos, err := s.BITPOS(mykey, 1, core.FullBitRange, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, offset)
+
+	pos, err = s.BITPOS(mykey, 0, core.BitRange{Start: offset, End: -1, Bit: true}, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, offset+1)
+
+	val, err := s.GETRANGE(mykey, -2, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "\x00\x04")
+
+	size2, err := s.APPEND(mykey, []byte("Hello"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, size2, 1<<20+6)
+
+	size2, err = s.SETRANGE(mykey, 1<<20+1, []byte("J"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, size2, 1<<20+6)
+
+	val, err = s.GET(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(val), 1<<20+6)
+	testt.MustEqual(t, string(val[1<<20:]), "\x04Jello")
+	testt.MustEqual(t, bytes.Count(val[:1<<20], []byte{0}), 1<<20)
+
+	res, err := s.BITFIELD(mykey, []core.BitFieldOp{
+		{Kind: core.BitFieldIncrBy, Width: 16, Offset: 4096*8 - 8, Value: 0x0102},
+		{Kind: core.BitFieldGet, Width: 8, Offset: 4096 * 8},
+	})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []*int64{ptr(0x0102), ptr(2)})
+	testt.MustEqual(t, countChunks(t, s, mykey), 3)
+
+	_, err = s.INCR(mykey)
+	testt.MustEqual(t, err, core.ErrNotIntOrOutOfRange)
+
+	ok, err := s.COPY(mykey, other, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	n2, err := s.BITOP(core.BitNot, other, other)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n2, 1<<20+6)
+
+	// "\x04Jello" and 0x0102 have 24 bits set.
+	n, err = s.BITCOUNT(other, core.FullBitRange)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, (1<<20+6)*8-int64(24))
+
+	// after restart chunks are still there.
+	err = s.Close()
+	testt.NoError(t, err)
+
+	s, err = Open(Config{Dir: dir})
+	testt.NoError(t, err)
+	defer s.Close()
+
+	bit, err := s.GETBIT(mykey, offset)
+	testt.NoError(t, err)
+	testt.MustEqual(t, bit, 1)
+
+	_, err = s.DEL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, countChunks(t, s, mykey), 0)
+
+	_, _, err = s.SET(other, []byte("short"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	val, err = s.GET(other)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "short")
+}
+
+func TestBitmapInlineToChunks(t *testing.T) {
+	mykey := []byte("mykey")
+	value := bytes.Repeat([]byte("abc"), 2000)
+
+	s := newStore(t)
+	_, _, err := s.SET(mykey, value, core.SetOptions{})
+	testt.NoError(t, err)
+
+	_, err = s.SETBIT(mykey, int64(len(value))*8, 1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, countChunks(t, s, mykey), 2)
+
+	val, err := s.GET(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), string(value)+"\x80")
+
+	val, err = s.GETSET(mykey, []byte("new"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), string(value)+"\x80")
+	testt.MustEqual(t, countChunks(t, s, mykey), 0)
+}
+
+// countChunks returns the number of stored chunks of all versions of the key.
+func countChunks(tb testing.TB, s *Store, key []byte) int {
+	tb.Helper()
+
+	prefix := memberKeyPrefix(dataPrefix, key, 0)
+	prefix = prefix[:len(prefix)-8]
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: prefixEnd(prefix),
+	})
+	testt.NoError(tb, err)
+	defer iter.Close()
+
+	n := 0
+	for iter.First(); iter.Valid(); iter.Next() {
+		n++
+	}
+	return n
+}
+
+func ptr(v int64) *int64 {
+	return &v
+}
@@ -0,0 +1,324 @@
+package ondisk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Bloom filter operations https://redis.io/commands/?group=bf
+// Cuckoo filter operations https://redis.io/commands/?group=cf
+
+// Headers of filters are kept in the meta payload, their arrays are split into chunks
+// created on the first write, so a lookup reads only chunks of the item:
+//
+//	m + key                                        => meta with a version, payload is the header
+//	d + len(key) + key + version + layer + index   => chunk of filterChunkSize bytes
+//
+// Missing chunks are zeros, so a reserved filter of any capacity takes no space.
+
+// filterChunkSize is the size of a chunk of a filter array.
+const filterChunkSize = 4096
+
+func (s *Store) BFINFO(key []byte) (core.BloomInfo, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	_, f, err := getBloom(snap, key)
+	if err != nil {
+		return core.BloomInfo{}, err
+	}
+	if f == nil {
+		return core.BloomInfo{}, core.ErrFilterNotFound
+	}
+	return f.Info(), nil
+}
+
+func (s *Store) BFMADD(key []byte, items ...[]byte) ([]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, f, err := loadBloom(b, key)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		f, err = core.NewBloomFilter(core.BloomOptions{
+			ErrorRate: core.BloomDefaultErrorRate,
+			Capacity:  core.BloomDefaultCapacity,
+			Expansion: core.BloomDefaultExpansion,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if m, err = s.newFilter(b, key, core.TypeBloom); err != nil {
+			return nil, err
+		}
+	}
+
+	st := filterStorage{r: b, b: b, key: key, version: m.version}
+	res := make([]bool, 0, len(items))
+	var addErr error
+	for _, item := range items {
+		added, err := f.Add(st, item)
+		if errors.Is(err, core.ErrBloomFull) || errors.Is(err, core.ErrBloomTooBig) {
+			addErr = err
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, added)
+	}
+
+	// items added before the filter got full or too big to scale are kept.
+	m.payload = f.Encode()
+	if err := putMeta(b, key, m); err != nil {
+		return nil, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return nil, err
+	}
+	return res, addErr
+}
+
+func (s *Store) BFMEXISTS(key []byte, items ...[]byte) ([]bool, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, f, err := getBloom(snap, key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]bool, len(items))
+	if f == nil {
+		return res, nil
+	}
+	st := filterStorage{r: snap, key: key, version: m.version}
+	for i, item := range items {
+		if res[i], err = f.Exists(st, item); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) BFRESERVE(key []byte, opts core.BloomOptions) error {
+	f, err := core.NewBloomFilter(opts)
+	if err != nil {
+		return err
+	}
+	return s.reserve(key, core.TypeBloom, core.ErrFilterExists, f.Encode())
+}
+
+func (s *Store) CFADD(key, item []byte) error {
+	return s.updateCuckoo(key, true, func(f *core.CuckooFilter, st filterStorage) error {
+		return f.Add(st, item)
+	})
+}
+
+func (s *Store) CFADDNX(key, item []byte) (bool, error) {
+	added := false
+	err := s.updateCuckoo(key, true, func(f *core.CuckooFilter, st filterStorage) error {
+		ok, err := f.Exists(st, item)
+		if err != nil || ok {
+			return err
+		}
+		added = true
+		return f.Add(st, item)
+	})
+	return added && err == nil, err
+}
+
+func (s *Store) CFCOUNT(key, item []byte) (int64, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, f, err := getCuckoo(snap, key)
+	if err != nil || f == nil {
+		return 0, err
+	}
+	return f.Count(filterStorage{r: snap, key: key, version: m.version}, item)
+}
+
+func (s *Store) CFDEL(key, item []byte) (bool, error) {
+	deleted := false
+	err := s.updateCuckoo(key, false, func(f *core.CuckooFilter, st filterStorage) (err error) {
+		deleted, err = f.Del(st, item)
+		return err
+	})
+	return deleted, err
+}
+
+func (s *Store) CFEXISTS(key, item []byte) (bool, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, f, err := getCuckoo(snap, key)
+	if err != nil || f == nil {
+		return false, err
+	}
+	return f.Exists(filterStorage{r: snap, key: key, version: m.version}, item)
+}
+
+// updateCuckoo calls fn with the filter of the key and writes its header. A missing filter is created
+// with the default capacity if create is set, otherwise ErrCuckooNotFound is returned.
+func (s *Store) updateCuckoo(key []byte, create bool, fn func(f *core.CuckooFilter, st filterStorage) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, f, err := loadCuckoo(b, key)
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		if !create {
+			return core.ErrCuckooNotFound
+		}
+		f = core.NewCuckooFilter(core.CuckooDefaultCapacity)
+		if m, err = s.newFilter(b, key, core.TypeCuckoo); err != nil {
+			return err
+		}
+	}
+
+	if err := fn(f, filterStorage{r: b, b: b, key: key, version: m.version}); err != nil {
+		return err
+	}
+	m.payload = f.Encode()
+	if err := putMeta(b, key, m); err != nil {
+		return err
+	}
+	return b.Commit(s.syncOpt)
+}
+
+// newFilter returns meta of a new filter or sketch, it's written with the header by the caller.
+func (s *Store) newFilter(b *pebble.Batch, key []byte, typ core.KeyType) (meta, error) {
+	version, err := s.nextVersion(b)
+	if err != nil {
+		return meta{}, err
+	}
+	return meta{typ: typ, version: version}, nil
+}
+
+// filterStorage implements core.FilterStorage over chunks of the key, writes go to b.
+type filterStorage struct {
+	r       pebble.Reader
+	b       *pebble.Batch
+	key     []byte
+	version uint64
+}
+
+func (st filterStorage) ReadAt(layer int, offset int64, buf []byte) error {
+	for len(buf) > 0 {
+		at := offset % filterChunkSize
+		n := min(int64(len(buf)), filterChunkSize-at)
+
+		chunk, ok, err := getValue(st.r, st.chunkKey(layer, offset/filterChunkSize))
+		if err != nil {
+			return err
+		}
+		if ok {
+			copy(buf[:n], chunk[at:])
+		} else {
+			clear(buf[:n])
+		}
+		buf, offset = buf[n:], offset+n
+	}
+	return nil
+}
+
+func (st filterStorage) WriteAt(layer int, offset int64, data []byte) error {
+	for len(data) > 0 {
+		at := offset % filterChunkSize
+		n := min(int64(len(data)), filterChunkSize-at)
+		k := st.chunkKey(layer, offset/filterChunkSize)
+
+		chunk, ok, err := getValue(st.b, k)
+		if err != nil {
+			return err
+		}
+		// missing chunks are zeros, there is no need to write zeros over them.
+		if ok || !isZero(data[:n]) {
+			if !ok {
+				chunk = make([]byte, filterChunkSize)
+			}
+			copy(chunk[at:], data[:n])
+			if err := st.b.Set(k, chunk, nil); err != nil {
+				return err
+			}
+		}
+		data, offset = data[n:], offset+n
+	}
+	return nil
+}
+
+func (st filterStorage) chunkKey(layer int, index int64) []byte {
+	res := binary.BigEndian.AppendUint32(dataKeyPrefix(st.key, st.version), uint32(layer))
+	return binary.BigEndian.AppendUint64(res, uint64(index))
+}
+
+// getBloom is like getMeta but fails for keys that are not Bloom filters and also returns the filter.
+// The filter is nil for a missing key.
+func getBloom(r pebble.Reader, key []byte) (meta, *core.BloomFilter, error) {
+	m, ok, err := getMeta(r, key)
+	return asBloom(key, m, ok, err)
+}
+
+// loadBloom is like loadMeta but fails for keys that are not Bloom filters and also returns the filter.
+// The filter is nil for a missing key.
+func loadBloom(b *pebble.Batch, key []byte) (meta, *core.BloomFilter, error) {
+	m, ok, err := loadMeta(b, key)
+	return asBloom(key, m, ok, err)
+}
+
+func asBloom(key []byte, m meta, ok bool, err error) (meta, *core.BloomFilter, error) {
+	if err != nil || !ok {
+		return meta{}, nil, err
+	}
+	if m.typ != core.TypeBloom {
+		return meta{}, nil, core.ErrWrongType
+	}
+	f, err := core.DecodeBloomFilter(m.payload)
+	if err != nil {
+		return meta{}, nil, fmt.Errorf("key %q: %w", key, err)
+	}
+	return m, f, nil
+}
+
+// getCuckoo is like getMeta but fails for keys that are not Cuckoo filters and also returns the filter.
+// The filter is nil for a missing key.
+func getCuckoo(r pebble.Reader, key []byte) (meta, *core.CuckooFilter, error) {
+	m, ok, err := getMeta(r, key)
+	return asCuckoo(key, m, ok, err)
+}
+
+// loadCuckoo is like loadMeta but fails for keys that are not Cuckoo filters and also returns the filter.
+// The filter is nil for a missing key.
+func loadCuckoo(b *pebble.Batch, key []byte) (meta, *core.CuckooFilter, error) {
+	m, ok, err := loadMeta(b, key)
+	return asCuckoo(key, m, ok, err)
+}
+
+func asCuckoo(key []byte, m meta, ok bool, err error) (meta, *core.CuckooFilter, error) {
+	if err != nil || !ok {
+		return meta{}, nil, err
+	}
+	if m.typ != core.TypeCuckoo {
+		return meta{}, nil, core.ErrWrongType
+	}
+	f, err := core.DecodeCuckooFilter(m.payload)
+	if err != nil {
+		return meta{}, nil, fmt.Errorf("key %q: %w", key, err)
+	}
+	return m, f, nil
+}
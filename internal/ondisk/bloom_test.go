@@ -0,0 +1,263 @@
+package ondisk
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cristalhq/testt"
+)
+
+func TestBFADD(t *testing.T) {
+	/*
+		redis> BF.ADD bf item1
+		(integer) 1
+		redis> BF.ADD bf item1
+		(integer) 0
+		redis> BF.MEXISTS bf item1 item2
+		1) (integer) 1
+		2) (integer) 0
+		redis>
+	*/
+
+	bf := []byte("bf")
+
+	s := newStore(t)
+	res, err := s.BFMADD(bf, []byte("item1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []bool{true})
+
+	res, err = s.BFMADD(bf, []byte("item1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []bool{false})
+
+	res, err = s.BFMEXISTS(bf, []byte("item1"), []byte("item2"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []bool{true, false})
+
+	res, err = s.BFMEXISTS([]byte("nokey"), []byte("item1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []bool{false})
+
+	typ, err := s.TYPE(bf)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "MBbloom--")
+
+	_, err = s.LPUSH([]byte("list"), []byte("a"))
+	testt.NoError(t, err)
+	_, err = s.BFMADD([]byte("list"), []byte("item1"))
+	testt.MustEqual(t, err, core.ErrWrongType)
+}
+
+func TestBFRESERVE(t *testing.T) {
+	/*
+		redis> BF.RESERVE bf 0.01 1000
+		OK
+		redis> BF.RESERVE bf 0.01 1000
+		(error) ERR item exists
+		redis> BF.INFO bf CAPACITY
+		1) (integer) 1000
+		redis> BF.INFO bf ITEMS
+		1) (integer) 0
+		redis>
+	*/
+
+	bf := []byte("bf")
+	opts := core.BloomOptions{ErrorRate: 0.01, Capacity: 1000, Expansion: 2}
+
+	s := newStore(t)
+	err := s.BFRESERVE(bf, opts)
+	testt.NoError(t, err)
+
+	err = s.BFRESERVE(bf, opts)
+	testt.MustEqual(t, err, core.ErrFilterExists)
+
+	info, err := s.BFINFO(bf)
+	testt.NoError(t, err)
+	testt.MustEqual(t, info, core.BloomInfo{Capacity: 1000, Size: 1199, Filters: 1, Expansion: 2})
+
+	_, err = s.BFINFO([]byte("nokey"))
+	testt.MustEqual(t, err, core.ErrFilterNotFound)
+
+	err = s.BFRESERVE([]byte("huge"), core.BloomOptions{ErrorRate: 0.01, Capacity: math.MaxInt64, Expansion: 2})
+	testt.MustEqual(t, err, core.ErrBloomTooBig)
+	err = s.BFRESERVE([]byte("huge"), core.BloomOptions{ErrorRate: 0, Capacity: 10, Expansion: 2})
+	testt.MustEqual(t, err, core.ErrBloomErrorRate)
+	_, err = s.BFINFO([]byte("huge"))
+	testt.MustEqual(t, err, core.ErrFilterNotFound)
+}
+
+func TestBFScaling(t *testing.T) {
+	bf, nonScaling := []byte("bf"), []byte("nonscaling")
+
+	s := newStore(t)
+	err := s.BFRESERVE(bf, core.BloomOptions{ErrorRate: 0.01, Capacity: 10, Expansion: 2})
+	testt.NoError(t, err)
+	err = s.BFRESERVE(nonScaling, core.BloomOptions{ErrorRate: 0.01, Capacity: 10, NonScaling: true})
+	testt.NoError(t, err)
+
+	items := make([][]byte, 100)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item:%d", i))
+	}
+
+	res, err := s.BFMADD(bf, items...)
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), len(items))
+
+	info, err := s.BFINFO(bf)
+	testt.NoError(t, err)
+	testt.MustEqual(t, info.Capacity, int64(10+20+40+80))
+	testt.MustEqual(t, info.Filters, int64(4))
+
+	// there are no false negatives.
+	res, err = s.BFMEXISTS(bf, items...)
+	testt.NoError(t, err)
+	for _, ok := range res {
+		testt.MustEqual(t, ok, true)
+	}
+
+	res, err = s.BFMADD(nonScaling, items...)
+	testt.MustEqual(t, err, core.ErrBloomFull)
+	testt.MustEqual(t, len(res) >= 10, true)
+	testt.MustEqual(t, len(res) < len(items), true)
+
+	info, err = s.BFINFO(nonScaling)
+	testt.NoError(t, err)
+	testt.MustEqual(t, info.Items, int64(10))
+	testt.MustEqual(t, info.Expansion, int64(0))
+}
+
+func TestCFADD(t *testing.T) {
+	/*
+		redis> CF.ADD cf item1
+		(integer) 1
+		redis> CF.ADD cf item1
+		(integer) 1
+		redis> CF.COUNT cf item1
+		(integer) 2
+		redis> CF.ADDNX cf item1
+		(integer) 0
+		redis> CF.DEL cf item1
+		(integer) 1
+		redis> CF.COUNT cf item1
+		(integer) 1
+		redis> CF.EXISTS cf item2
+		(integer) 0
+		redis>
+	*/
+
+	cf, item1 := []byte("cf"), []byte("item1")
+
+	s := newStore(t)
+	err := s.CFADD(cf, item1)
+	testt.NoError(t, err)
+	err = s.CFADD(cf, item1)
+	testt.NoError(t, err)
+
+	n, err := s.CFCOUNT(cf, item1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	ok, err := s.CFADDNX(cf, item1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	ok, err = s.CFDEL(cf, item1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	n, err = s.CFCOUNT(cf, item1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	ok, err = s.CFEXISTS(cf, []byte("item2"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	ok, err = s.CFDEL(cf, []byte("item2"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	_, err = s.CFDEL([]byte("nokey"), item1)
+	testt.MustEqual(t, err, core.ErrCuckooNotFound)
+
+	typ, err := s.TYPE(cf)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "MBbloomCF")
+}
+
+func TestCFScaling(t *testing.T) {
+	cf := []byte("cf")
+
+	s := newStore(t)
+	items := make([][]byte, 5000)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item:%d", i))
+		err := s.CFADD(cf, items[i])
+		testt.NoError(t, err)
+	}
+
+	// items beyond the capacity go to new filters, there are no false negatives.
+	for _, item := range items {
+		ok, err := s.CFEXISTS(cf, item)
+		testt.NoError(t, err)
+		testt.MustEqual(t, ok, true)
+	}
+}
+
+func TestFilterRestart(t *testing.T) {
+	bf, cf, dst := []byte("bf"), []byte("cf"), []byte("dst")
+	dir := t.TempDir()
+
+	s, err := Open(Config{Dir: dir})
+	testt.NoError(t, err)
+
+	_, err = s.BFMADD(bf, []byte("item1"), []byte("item2"))
+	testt.NoError(t, err)
+	err = s.CFADD(cf, []byte("item1"))
+	testt.NoError(t, err)
+
+	err = s.Close()
+	testt.NoError(t, err)
+
+	s, err = Open(Config{Dir: dir})
+	testt.NoError(t, err)
+	defer s.Close()
+
+	res, err := s.BFMEXISTS(bf, []byte("item1"), []byte("item2"), []byte("item3"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []bool{true, true, false})
+
+	info, err := s.BFINFO(bf)
+	testt.NoError(t, err)
+	testt.MustEqual(t, info.Items, int64(2))
+
+	// copies don't share chunks.
+	ok, err := s.COPY(cf, dst, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = s.CFDEL(cf, []byte("item1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = s.CFEXISTS(cf, []byte("item1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	ok, err = s.CFEXISTS(dst, []byte("item1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	// deleted filters leave no chunks behind.
+	n, err := s.DEL(bf)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	res, err = s.BFMADD(bf, []byte("item1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []bool{true})
+}
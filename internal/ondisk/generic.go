@@ -0,0 +1,349 @@
+package ondisk
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Generic operations https://redis.io/commands/?group=generic
+
+func (s *Store) COPY(src, dst []byte, replace bool) (bool, error) {
+	if bytes.Equal(src, dst) {
+		return false, core.ErrSameObject
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, ok, err := loadMeta(b, src)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, b.Commit(s.syncOpt)
+	}
+
+	old, ok, err := loadMeta(b, dst)
+	if err != nil {
+		return false, err
+	}
+	if ok && !replace {
+		return false, b.Commit(s.syncOpt)
+	}
+
+	if ok {
+		if err := delKey(b, dst, old); err != nil {
+			return false, err
+		}
+	}
+	if err := s.copyKey(b, src, dst, m); err != nil {
+		return false, err
+	}
+	return true, b.Commit(s.syncOpt)
+}
+
+func (s *Store) DEL(keys ...[]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	n := 0
+	for _, key := range keys {
+		m, ok, err := loadMeta(b, key)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		if err := delKey(b, key, m); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	return n, b.Commit(s.syncOpt)
+}
+
+func (s *Store) EXISTS(keys ...[]byte) (int, error) {
+	n := 0
+	for _, key := range keys {
+		_, ok, err := getMeta(s.db, key)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) EXPIRE(key []byte, seconds int64, cond core.ExpireCond) (bool, error) {
+	at, err := core.ExpireAtMs(core.NowMs(), seconds, time.Second)
+	if err != nil {
+		return false, err
+	}
+	return s.expireAt(key, at, cond)
+}
+
+func (s *Store) EXPIREAT(key []byte, unixSeconds int64, cond core.ExpireCond) (bool, error) {
+	at, err := core.UnixMs(unixSeconds, time.Second)
+	if err != nil {
+		return false, err
+	}
+	return s.expireAt(key, at, cond)
+}
+
+func (s *Store) EXPIRETIME(key []byte) (int64, error) {
+	m, ok, err := getMeta(s.db, key)
+	if err != nil {
+		return 0, err
+	}
+	return core.ExpireTimeReply(ok, m.expireAt, time.Second), nil
+}
+
+func (s *Store) FLUSHDB() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewBatch()
+	defer tryClose(b)
+
+	for _, prefix := range layoutPrefixes {
+		if err := b.DeleteRange(prefix, prefixEnd(prefix), nil); err != nil {
+			return err
+		}
+	}
+	return b.Commit(s.syncOpt)
+}
+
+func (s *Store) KEYS(pattern []byte) ([][]byte, error) {
+	res := [][]byte{}
+	err := s.walkKeys(nil, func(key []byte, _ meta) bool {
+		if core.Match(pattern, key) {
+			res = append(res, bytes.Clone(key))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Store) PERSIST(key []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, ok, err := loadMeta(b, key)
+	if err != nil {
+		return false, err
+	}
+	if !ok || m.expireAt == 0 {
+		return false, b.Commit(s.syncOpt)
+	}
+
+	if err := setExpire(b, key, &m, 0); err != nil {
+		return false, err
+	}
+	return true, b.Commit(s.syncOpt)
+}
+
+func (s *Store) PEXPIRE(key []byte, milliseconds int64, cond core.ExpireCond) (bool, error) {
+	at, err := core.ExpireAtMs(core.NowMs(), milliseconds, time.Millisecond)
+	if err != nil {
+		return false, err
+	}
+	return s.expireAt(key, at, cond)
+}
+
+func (s *Store) PEXPIREAT(key []byte, unixMilliseconds int64, cond core.ExpireCond) (bool, error) {
+	return s.expireAt(key, unixMilliseconds, cond)
+}
+
+func (s *Store) PEXPIRETIME(key []byte) (int64, error) {
+	m, ok, err := getMeta(s.db, key)
+	if err != nil {
+		return 0, err
+	}
+	return core.ExpireTimeReply(ok, m.expireAt, time.Millisecond), nil
+}
+
+func (s *Store) PTTL(key []byte) (int64, error) {
+	m, ok, err := getMeta(s.db, key)
+	if err != nil {
+		return 0, err
+	}
+	return core.TTLReply(ok, core.NowMs(), m.expireAt, time.Millisecond), nil
+}
+
+func (s *Store) RENAME(key, newkey []byte) error {
+	_, err := s.rename(key, newkey, false)
+	return err
+}
+
+func (s *Store) RENAMENX(key, newkey []byte) (bool, error) {
+	return s.rename(key, newkey, true)
+}
+
+// SCAN walks keys in pebble order, the cursor continues exactly from the next key
+// and falls back to its prefix after a restart.
+func (s *Store) SCAN(cursor uint64, opts core.ScanOptions) ([][]byte, uint64, error) {
+	count := opts.Count
+	if count <= 0 {
+		count = core.DefaultScanCount
+	}
+
+	res := [][]byte{}
+	var next uint64
+	i := 0
+	err := s.walkKeys(s.cursors.Seek(nil, cursor), func(key []byte, m meta) bool {
+		if i == count {
+			next = s.cursors.Next(nil, key)
+			return false
+		}
+		i++
+
+		if opts.Type != "" && opts.Type != m.typ.String() {
+			return true
+		}
+		if len(opts.Match) == 0 || core.Match(opts.Match, key) {
+			res = append(res, bytes.Clone(key))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return res, next, nil
+}
+
+func (s *Store) TOUCH(keys ...[]byte) (int, error) {
+	return s.EXISTS(keys...)
+}
+
+func (s *Store) TTL(key []byte) (int64, error) {
+	m, ok, err := getMeta(s.db, key)
+	if err != nil {
+		return 0, err
+	}
+	return core.TTLReply(ok, core.NowMs(), m.expireAt, time.Second), nil
+}
+
+func (s *Store) TYPE(key []byte) (string, error) {
+	m, _, err := getMeta(s.db, key)
+	if err != nil {
+		return "", err
+	}
+	return m.typ.String(), nil
+}
+
+func (s *Store) UNLINK(keys ...[]byte) (int, error) {
+	return s.DEL(keys...)
+}
+
+func (s *Store) expireAt(key []byte, at int64, cond core.ExpireCond) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, ok, err := loadMeta(b, key)
+	if err != nil {
+		return false, err
+	}
+	if !ok || !cond.Allow(m.expireAt, at) {
+		return false, b.Commit(s.syncOpt)
+	}
+
+	if at <= core.NowMs() {
+		err = delKey(b, key, m)
+	} else {
+		err = setExpire(b, key, &m, at)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, b.Commit(s.syncOpt)
+}
+
+func (s *Store) rename(key, newkey []byte, nx bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, ok, err := loadMeta(b, key)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, core.ErrKeyNotFound
+	}
+	if bytes.Equal(key, newkey) {
+		return !nx, nil
+	}
+
+	old, ok, err := loadMeta(b, newkey)
+	if err != nil {
+		return false, err
+	}
+	if ok && nx {
+		return false, b.Commit(s.syncOpt)
+	}
+
+	if ok {
+		if err := delKey(b, newkey, old); err != nil {
+			return false, err
+		}
+	}
+	if err := s.copyKey(b, key, newkey, m); err != nil {
+		return false, err
+	}
+	if err := delKey(b, key, m); err != nil {
+		return false, err
+	}
+	return true, b.Commit(s.syncOpt)
+}
+
+// walkKeys calls fn for each not expired user key starting from seek, until fn returns false.
+func (s *Store) walkKeys(seek []byte, fn func(key []byte, m meta) bool) error {
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: metaKey(seek),
+		UpperBound: prefixEnd(metaPrefix),
+	})
+	if err != nil {
+		return err
+	}
+	defer tryClose(iter)
+
+	now := core.NowMs()
+	for iter.First(); iter.Valid(); iter.Next() {
+		key := iter.Key()[len(metaPrefix):]
+		m, err := decodeMeta(iter.Value())
+		if err != nil {
+			return err
+		}
+		if m.isExpired(now) {
+			continue
+		}
+		if !fn(key, m) {
+			break
+		}
+	}
+	return iter.Error()
+}
@@ -0,0 +1,821 @@
+package ondisk
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cristalhq/testt"
+)
+
+func TestCOPY(t *testing.T) {
+	/*
+		redis> SET dolly "sheep"
+		"OK"
+		redis> COPY dolly clone
+		(integer) 1
+		redis> GET clone
+		"sheep"
+		redis> COPY dolly clone
+		(integer) 0
+		redis> COPY dolly clone REPLACE
+		(integer) 1
+		redis>
+	*/
+
+	dolly := []byte("dolly")
+	clone := []byte("clone")
+
+	s := newStore(t)
+	_, _, err := s.SET(dolly, []byte("sheep"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	_, err = s.EXPIRE(dolly, 10, 0)
+	testt.NoError(t, err)
+
+	ok, err := s.COPY(dolly, clone, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	val, err := s.GET(clone)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "sheep")
+
+	ttl, err := s.TTL(clone)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(10))
+
+	ok, err = s.COPY(dolly, clone, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	ok, err = s.COPY(dolly, clone, true)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	_, err = s.COPY(dolly, dolly, true)
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), core.ErrSameObject.Error())
+}
+
+func TestDEL(t *testing.T) {
+	/*
+		redis> SET key1 "Hello"
+		"OK"
+		redis> SET key2 "World"
+		"OK"
+		redis> DEL key1 key2 key3
+		(integer) 2
+		redis>
+	*/
+
+	key1 := []byte("key1")
+	key2 := []byte("key2")
+	key3 := []byte("key3")
+
+	s := newStore(t)
+	err := s.MSET(key1, []byte("Hello"), key2, []byte("World"))
+	testt.NoError(t, err)
+
+	n, err := s.DEL(key1, key2, key3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	n, err = s.EXISTS(key1, key2, key3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+}
+
+func TestEXISTS(t *testing.T) {
+	/*
+		redis> SET key1 "Hello"
+		"OK"
+		redis> EXISTS key1
+		(integer) 1
+		redis> EXISTS nosuchkey
+		(integer) 0
+		redis> SET key2 "World"
+		"OK"
+		redis> EXISTS key1 key2 nosuchkey
+		(integer) 2
+		redis> EXISTS key1 key1
+		(integer) 2
+		redis>
+	*/
+
+	key1 := []byte("key1")
+	key2 := []byte("key2")
+	nosuchkey := []byte("nosuchkey")
+
+	s := newStore(t)
+	_, _, err := s.SET(key1, []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	n, err := s.EXISTS(key1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.EXISTS(nosuchkey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	_, _, err = s.SET(key2, []byte("World"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	n, err = s.EXISTS(key1, key2, nosuchkey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	n, err = s.EXISTS(key1, key1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+}
+
+func TestEXPIRE(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> EXPIRE mykey 10
+		(integer) 1
+		redis> TTL mykey
+		(integer) 10
+		redis> SET mykey "Hello World"
+		"OK"
+		redis> TTL mykey
+		(integer) -1
+		redis> EXPIRE mykey 10 XX
+		(integer) 0
+		redis> TTL mykey
+		(integer) -1
+		redis> EXPIRE mykey 10 NX
+		(integer) 1
+		redis> TTL mykey
+		(integer) 10
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := newStore(t)
+	_, _, err := s.SET(mykey, []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	ok, err := s.EXPIRE(mykey, 10, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ttl, err := s.TTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(10))
+
+	_, _, err = s.SET(mykey, []byte("Hello World"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	ttl, err = s.TTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(-1))
+
+	ok, err = s.EXPIRE(mykey, 10, core.ExpireXX)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	ttl, err = s.TTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(-1))
+
+	ok, err = s.EXPIRE(mykey, 10, core.ExpireNX)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ttl, err = s.TTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(10))
+}
+
+func TestEXPIREAT(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> EXPIREAT mykey 1293840000
+		(integer) 1
+		redis> GET mykey
+		(nil)
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := newStore(t)
+	_, _, err := s.SET(mykey, []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	ok, err := s.EXPIREAT(mykey, 1293840000, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	_, err = s.GET(mykey)
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), core.ErrKeyNotFound.Error())
+}
+
+func TestEXPIRETIME(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> EXPIRETIME mykey
+		(integer) -1
+		redis> EXPIREAT mykey 33177117420
+		(integer) 1
+		redis> EXPIRETIME mykey
+		(integer) 33177117420
+		redis> PEXPIRETIME mykey
+		(integer) 33177117420000
+		redis> EXPIRETIME nonexisting
+		(integer) -2
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := newStore(t)
+	_, _, err := s.SET(mykey, []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	at, err := s.EXPIRETIME(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, at, int64(-1))
+
+	ok, err := s.EXPIREAT(mykey, 33177117420, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	at, err = s.EXPIRETIME(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, at, int64(33177117420))
+
+	at, err = s.PEXPIRETIME(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, at, int64(33177117420000))
+
+	at, err = s.EXPIRETIME([]byte("nonexisting"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, at, int64(-2))
+}
+
+func TestFLUSHDB(t *testing.T) {
+	key1 := []byte("key1")
+	key2 := []byte("key2")
+
+	s := newStore(t)
+	err := s.MSET(key1, []byte("Hello"), key2, []byte("World"))
+	testt.NoError(t, err)
+
+	_, err = s.EXPIRE(key1, 10, 0)
+	testt.NoError(t, err)
+
+	err = s.FLUSHDB()
+	testt.NoError(t, err)
+
+	n, err := s.EXISTS(key1, key2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+}
+
+func TestFLUSHDBRaw(t *testing.T) {
+	s := newStore(t)
+	_, err := s.ZADD([]byte("myzset"), core.ZAddOptions{}, core.ZMember{Member: []byte("one"), Score: 1})
+	testt.NoError(t, err)
+	_, err = s.HSET([]byte("myhash"), []byte("field"), []byte("value"))
+	testt.NoError(t, err)
+	_, err = s.HEXPIRE([]byte("myhash"), 100, 0, []byte("field"))
+	testt.NoError(t, err)
+	err = s.XGROUPCREATE([]byte("mystream"), []byte("mygroup"), core.XGroupIDOptions{}, true)
+	testt.NoError(t, err)
+
+	err = s.FLUSHDB()
+	testt.NoError(t, err)
+
+	// only the format and version keys are left.
+	iter, err := s.db.NewIter(nil)
+	testt.NoError(t, err)
+	defer iter.Close()
+
+	var keys []string
+	for iter.First(); iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	testt.NoError(t, iter.Error())
+	testt.MustEqual(t, keys, []string{string(formatKey), string(versionKey)})
+}
+
+func TestKEYS(t *testing.T) {
+	/*
+		redis> MSET firstname Jack lastname Stuntman age 35
+		"OK"
+		redis> KEYS *name*
+		1) "firstname"
+		2) "lastname"
+		redis> KEYS a??
+		1) "age"
+		redis> KEYS *
+		1) "age"
+		2) "firstname"
+		3) "lastname"
+		redis>
+	*/
+
+	s := newStore(t)
+	err := s.MSET(
+		[]byte("firstname"), []byte("Jack"),
+		[]byte("lastname"), []byte("Stuntman"),
+		[]byte("age"), []byte("35"),
+	)
+	testt.NoError(t, err)
+
+	keys, err := s.KEYS([]byte("*name*"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, keys, [][]byte{[]byte("firstname"), []byte("lastname")})
+
+	keys, err = s.KEYS([]byte("a??"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, keys, [][]byte{[]byte("age")})
+
+	keys, err = s.KEYS([]byte("*"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, keys, [][]byte{[]byte("age"), []byte("firstname"), []byte("lastname")})
+
+	keys, err = s.KEYS([]byte("[a-f]*"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, keys, [][]byte{[]byte("age"), []byte("firstname")})
+}
+
+func TestPERSIST(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> EXPIRE mykey 10
+		(integer) 1
+		redis> TTL mykey
+		(integer) 10
+		redis> PERSIST mykey
+		(integer) 1
+		redis> TTL mykey
+		(integer) -1
+		redis> PERSIST mykey
+		(integer) 0
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := newStore(t)
+	_, _, err := s.SET(mykey, []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	ok, err := s.EXPIRE(mykey, 10, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ttl, err := s.TTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(10))
+
+	ok, err = s.PERSIST(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ttl, err = s.TTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(-1))
+
+	ok, err = s.PERSIST(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+}
+
+func TestPEXPIRE(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> PEXPIRE mykey 1500
+		(integer) 1
+		redis> PTTL mykey
+		(integer) 1499
+		redis> PEXPIRE mykey 1000 XX
+		(integer) 1
+		redis> PEXPIRE mykey 2000 NX
+		(integer) 0
+		redis> PEXPIRE mykey 2000 GT
+		(integer) 1
+		redis> PEXPIRE mykey 3000 LT
+		(integer) 0
+		redis> PEXPIRE mykey 10
+		(integer) 1
+		redis> GET mykey
+		(nil)
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := newStore(t)
+	_, _, err := s.SET(mykey, []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	ok, err := s.PEXPIRE(mykey, 1500, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ttl, err := s.PTTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl > 1000 && ttl <= 1500, true)
+
+	ok, err = s.PEXPIRE(mykey, 1000, core.ExpireXX)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = s.PEXPIRE(mykey, 2000, core.ExpireNX)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	ok, err = s.PEXPIRE(mykey, 2000, core.ExpireGT)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = s.PEXPIRE(mykey, 3000, core.ExpireLT)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	ok, err = s.PEXPIRE(mykey, 10, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = s.GET(mykey)
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), core.ErrKeyNotFound.Error())
+}
+
+func TestPTTL(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> EXPIRE mykey 1
+		(integer) 1
+		redis> PTTL mykey
+		(integer) 999
+		redis> PTTL nonexisting
+		(integer) -2
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := newStore(t)
+	_, _, err := s.SET(mykey, []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	ok, err := s.EXPIRE(mykey, 1, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ttl, err := s.PTTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl > 900 && ttl <= 1000, true)
+
+	ttl, err = s.PTTL([]byte("nonexisting"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(-2))
+}
+
+func TestRENAME(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> RENAME mykey myotherkey
+		"OK"
+		redis> GET myotherkey
+		"Hello"
+		redis> RENAME mykey myotherkey
+		(error) ERR no such key
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+	myotherkey := []byte("myotherkey")
+
+	s := newStore(t)
+	_, _, err := s.SET(mykey, []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	err = s.RENAME(mykey, myotherkey)
+	testt.NoError(t, err)
+
+	val, err := s.GET(myotherkey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "Hello")
+
+	err = s.RENAME(mykey, myotherkey)
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), core.ErrKeyNotFound.Error())
+}
+
+func TestRENAMENX(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> SET myotherkey "World"
+		"OK"
+		redis> RENAMENX mykey myotherkey
+		(integer) 0
+		redis> GET myotherkey
+		"World"
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+	myotherkey := []byte("myotherkey")
+
+	s := newStore(t)
+	err := s.MSET(mykey, []byte("Hello"), myotherkey, []byte("World"))
+	testt.NoError(t, err)
+
+	ok, err := s.RENAMENX(mykey, myotherkey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	val, err := s.GET(myotherkey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "World")
+}
+
+func TestSCAN(t *testing.T) {
+	s := newStore(t)
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key:%02d", i))
+		_, _, err := s.SET(key, []byte("value"), core.SetOptions{})
+		testt.NoError(t, err)
+	}
+
+	seen := map[string]int{}
+	var cursor uint64
+	for {
+		keys, next, err := s.SCAN(cursor, core.ScanOptions{Count: 10})
+		testt.NoError(t, err)
+		for _, key := range keys {
+			seen[string(key)]++
+		}
+
+		// mutations between calls must not break the iteration.
+		_, _, err = s.SET([]byte(fmt.Sprintf("new:%d", next)), []byte("value"), core.SetOptions{})
+		testt.NoError(t, err)
+
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	for i := 0; i < 100; i++ {
+		testt.MustEqual(t, seen[fmt.Sprintf("key:%02d", i)] > 0, true)
+	}
+
+	keys, _, err := s.SCAN(0, core.ScanOptions{Match: []byte("key:4?"), Count: 1000})
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(keys), 10)
+
+	keys, next, err := s.SCAN(0, core.ScanOptions{Type: "hash", Count: 1000})
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(keys), 0)
+	testt.MustEqual(t, next, uint64(0))
+}
+
+func TestSCANSharedPrefix(t *testing.T) {
+	s := newStore(t)
+	for i := 0; i < 200; i++ {
+		key := []byte(fmt.Sprintf("session:%03d", i))
+		_, _, err := s.SET(key, []byte("value"), core.SetOptions{})
+		testt.NoError(t, err)
+	}
+
+	seen := map[string]int{}
+	var cursor uint64
+	calls := 0
+	for {
+		keys, next, err := s.SCAN(cursor, core.ScanOptions{Count: 10})
+		testt.NoError(t, err)
+		testt.MustEqual(t, len(keys) <= 10, true)
+		for _, key := range keys {
+			seen[string(key)]++
+		}
+		calls++
+
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	testt.MustEqual(t, calls, 20)
+	testt.MustEqual(t, len(seen), 200)
+	for _, n := range seen {
+		testt.MustEqual(t, n, 1)
+	}
+}
+
+func TestTOUCH(t *testing.T) {
+	/*
+		redis> SET key1 "Hello"
+		"OK"
+		redis> SET key2 "World"
+		"OK"
+		redis> TOUCH key1 key2
+		(integer) 2
+		redis>
+	*/
+
+	key1 := []byte("key1")
+	key2 := []byte("key2")
+
+	s := newStore(t)
+	err := s.MSET(key1, []byte("Hello"), key2, []byte("World"))
+	testt.NoError(t, err)
+
+	n, err := s.TOUCH(key1, key2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+}
+
+func TestTTL(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> EXPIRE mykey 10
+		(integer) 1
+		redis> TTL mykey
+		(integer) 10
+		redis> TTL nonexisting
+		(integer) -2
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+
+	s := newStore(t)
+	_, _, err := s.SET(mykey, []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	ok, err := s.EXPIRE(mykey, 10, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ttl, err := s.TTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(10))
+
+	ttl, err = s.TTL([]byte("nonexisting"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(-2))
+}
+
+func TestTYPE(t *testing.T) {
+	/*
+		redis> SET key1 "value"
+		"OK"
+		redis> TYPE key1
+		string
+		redis> TYPE nonexisting
+		none
+		redis>
+	*/
+
+	key1 := []byte("key1")
+
+	s := newStore(t)
+	_, _, err := s.SET(key1, []byte("value"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	typ, err := s.TYPE(key1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "string")
+
+	typ, err = s.TYPE([]byte("nonexisting"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "none")
+}
+
+func TestUNLINK(t *testing.T) {
+	/*
+		redis> SET key1 "Hello"
+		"OK"
+		redis> SET key2 "World"
+		"OK"
+		redis> UNLINK key1 key2 key3
+		(integer) 2
+		redis>
+	*/
+
+	key1 := []byte("key1")
+	key2 := []byte("key2")
+	key3 := []byte("key3")
+
+	s := newStore(t)
+	err := s.MSET(key1, []byte("Hello"), key2, []byte("World"))
+	testt.NoError(t, err)
+
+	n, err := s.UNLINK(key1, key2, key3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+}
+
+func TestSweep(t *testing.T) {
+	s := newStore(t)
+	for i := 0; i < 300; i++ {
+		key := []byte{byte(i >> 8), byte(i)}
+		_, _, err := s.SET(key, []byte("Hello"), core.SetOptions{})
+		testt.NoError(t, err)
+
+		_, err = s.PEXPIRE(key, 1, 0)
+		testt.NoError(t, err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	n, err := s.sweep()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, sweepLimit)
+
+	for n == sweepLimit {
+		n, err = s.sweep()
+		testt.NoError(t, err)
+	}
+
+	// only the format key is left.
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: dataPrefix,
+		UpperBound: prefixEnd(metaPrefix),
+	})
+	testt.NoError(t, err)
+	defer iter.Close()
+	testt.MustEqual(t, iter.First(), false)
+}
+
+func TestExpirePersisted(t *testing.T) {
+	mykey := []byte("mykey")
+
+	dir := t.TempDir()
+	s, err := Open(Config{Dir: dir})
+	testt.NoError(t, err)
+
+	_, _, err = s.SET(mykey, []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	ok, err := s.EXPIRE(mykey, 100, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	err = s.Close()
+	testt.NoError(t, err)
+
+	s, err = Open(Config{Dir: dir})
+	testt.NoError(t, err)
+	defer s.Close()
+
+	ttl, err := s.TTL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, int64(100))
+}
+
+func TestSCANAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(Config{Dir: dir})
+	testt.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		key := []byte(fmt.Sprintf("key:%02d", i))
+		_, _, err := s.SET(key, []byte("value"), core.SetOptions{})
+		testt.NoError(t, err)
+	}
+	_, err = s.EXPIRE([]byte("key:00"), 100, 0)
+	testt.NoError(t, err)
+
+	keys, cursor, err := s.SCAN(0, core.ScanOptions{Count: 10})
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(keys), 10)
+	testt.MustEqual(t, string(keys[0]), "key:00")
+
+	err = s.Close()
+	testt.NoError(t, err)
+
+	s, err = Open(Config{Dir: dir})
+	testt.NoError(t, err)
+	defer s.Close()
+
+	keys, cursor, err = s.SCAN(cursor, core.ScanOptions{Count: 10})
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(keys) >= 10, true)
+	testt.MustEqual(t, string(keys[len(keys)-1]), "key:19")
+	testt.MustEqual(t, cursor, uint64(0))
+}
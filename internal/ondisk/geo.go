@@ -0,0 +1,99 @@
+package ondisk
+
+import (
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Geospatial operations https://redis.io/commands/?group=geo
+
+// Geo indexes are sorted sets, so a search is a few range scans of the score index,
+// one per geohash cell around the center.
+
+func (s *Store) GEOSEARCH(key []byte, q core.GeoSearch) ([]core.GeoPoint, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, zm, ok, err := getZSet(snap, key)
+	if err != nil || !ok {
+		return []core.GeoPoint{}, err
+	}
+	return geoSearch(snap, key, m, zm, q)
+}
+
+func (s *Store) GEOSEARCHSTORE(dst, src []byte, q core.GeoSearch, storeDist bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, zm, ok, err := loadZSet(b, src)
+	if err != nil {
+		return 0, err
+	}
+	res := []core.GeoPoint{}
+	if ok {
+		res, err = geoSearch(b, src, m, zm, q)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	old, ok, err := loadMeta(b, dst)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		if err := delKey(b, dst, old); err != nil {
+			return 0, err
+		}
+	}
+	if len(res) > 0 {
+		m, zm, err := s.loadOrNewZSet(b, dst)
+		if err != nil {
+			return 0, err
+		}
+		for _, p := range res {
+			score := p.Score
+			if storeDist {
+				score = p.Dist
+			}
+			if err := setScore(b, dst, m, &zm, p.Member, score); err != nil {
+				return 0, err
+			}
+		}
+		if err := putZSet(b, dst, m, zm); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return len(res), nil
+}
+
+// geoSearch resolves the center of the search and runs it over the score index of the sorted set.
+func geoSearch(r pebble.Reader, key []byte, m meta, zm zsetMeta, q core.GeoSearch) ([]core.GeoPoint, error) {
+	if q.FromMember != nil {
+		score, ok, err := getScore(r, key, m, q.FromMember)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, core.ErrGeoMemberNotFound
+		}
+		q.Lon, q.Lat = core.GeoPosition(score)
+	}
+
+	return q.Search(func(min, max float64, fn func(core.ZMember) bool) error {
+		rng := core.ZRange{
+			By:  core.ZRangeByScore,
+			Min: core.ScoreBound{Value: min},
+			Max: core.ScoreBound{Value: max, Exclusive: true},
+		}
+		return walkZRange(r, key, m, zm, rng, fn)
+	})
+}
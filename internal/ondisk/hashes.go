@@ -0,0 +1,828 @@
+package ondisk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Hashes operations https://redis.io/commands/?group=hash
+
+// Hash fields are stored as d + len(key) + key + version + field => value,
+// so a single field is read without loading the whole hash.
+// Field expiry is stored as t + len(key) + key + version + field => expire at
+// and indexed, so sweeper reclaims expired fields without scanning hashes.
+type hashMeta struct {
+	// len is the number of fields, including expired but not yet reclaimed ones.
+	len int
+	// ttls is the number of fields with expiry.
+	ttls int
+}
+
+func decodeHashMeta(m meta) (hashMeta, error) {
+	switch len(m.payload) {
+	case 8:
+		// written before field expiry was added.
+		return hashMeta{len: int(binary.BigEndian.Uint64(m.payload))}, nil
+	case 16:
+		return hashMeta{
+			len:  int(binary.BigEndian.Uint64(m.payload)),
+			ttls: int(binary.BigEndian.Uint64(m.payload[8:])),
+		}, nil
+	default:
+		return hashMeta{}, errCorruptedMeta
+	}
+}
+
+func (h hashMeta) encode() []byte {
+	res := binary.BigEndian.AppendUint64(nil, uint64(h.len))
+	return binary.BigEndian.AppendUint64(res, uint64(h.ttls))
+}
+
+func (s *Store) HDEL(key []byte, fields ...[]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, h, ok, err := loadHash(b, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	n := 0
+	now := core.NowMs()
+	for _, field := range fields {
+		_, at, ok, err := readField(b, key, m, h, field)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		if err := delField(b, key, m, &h, field, at); err != nil {
+			return 0, err
+		}
+		if !isFieldExpired(at, now) {
+			n++
+		}
+	}
+
+	if err := putHash(b, key, m, h); err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *Store) HEXISTS(key, field []byte) (bool, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, h, ok, err := getHash(snap, key)
+	if err != nil || !ok {
+		return false, err
+	}
+	_, ok, err = getField(snap, key, m, h, field)
+	return ok, err
+}
+
+func (s *Store) HEXPIRE(key []byte, seconds int64, cond core.ExpireCond, fields ...[]byte) ([]int, error) {
+	now := core.NowMs()
+	at, err := core.ExpireAtMs(now, seconds, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return s.hexpireAt(key, now, at, cond, fields)
+}
+
+func (s *Store) HEXPIREAT(key []byte, unixSeconds int64, cond core.ExpireCond, fields ...[]byte) ([]int, error) {
+	at, err := core.UnixMs(unixSeconds, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return s.hexpireAt(key, core.NowMs(), at, cond, fields)
+}
+
+func (s *Store) HEXPIRETIME(key []byte, fields ...[]byte) ([]int64, error) {
+	return s.fieldTTL(key, fields, func(ok bool, at int64) int64 {
+		return core.ExpireTimeReply(ok, at, time.Second)
+	})
+}
+
+func (s *Store) HGET(key, field []byte) ([]byte, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, h, ok, err := getHash(snap, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+
+	value, ok, err := getField(snap, key, m, h, field)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	return value, nil
+}
+
+func (s *Store) HGETALL(key []byte) ([][]byte, error) {
+	return s.hashGeneric(key, true, true)
+}
+
+func (s *Store) HINCRBY(key, field []byte, by int64) (int64, error) {
+	var num int64
+	err := s.updateField(key, field, func(value []byte, ok bool) ([]byte, error) {
+		if ok {
+			var err error
+			num, err = strconv.ParseInt(string(value), 10, 64)
+			if err != nil {
+				return nil, core.ErrHashNotInt
+			}
+		}
+		var err error
+		num, err = core.AddInt64(num, by)
+		return []byte(strconv.FormatInt(num, 10)), err
+	})
+	return num, err
+}
+
+func (s *Store) HINCRBYFLOAT(key, field []byte, by float64) (string, error) {
+	var res string
+	err := s.updateField(key, field, func(value []byte, ok bool) ([]byte, error) {
+		num := float64(0)
+		if ok {
+			var err error
+			num, err = strconv.ParseFloat(string(value), 64)
+			if err != nil {
+				return nil, core.ErrHashNotFloat
+			}
+		}
+		num += by
+		if math.IsNaN(num) || math.IsInf(num, 0) {
+			return nil, core.ErrIncrNaNOrInf
+		}
+		res = strconv.FormatFloat(num, 'f', -1, 64)
+		return []byte(res), nil
+	})
+	return res, err
+}
+
+func (s *Store) HKEYS(key []byte) ([][]byte, error) {
+	return s.hashGeneric(key, true, false)
+}
+
+func (s *Store) HLEN(key []byte) (int, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, h, ok, err := getHash(snap, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	expired, err := expiredFields(snap, key, m, h)
+	if err != nil {
+		return 0, err
+	}
+	return h.len - len(expired), nil
+}
+
+func (s *Store) HMGET(key []byte, fields ...[]byte) ([][]byte, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, h, ok, err := getHash(snap, key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([][]byte, len(fields))
+	if !ok {
+		return res, nil
+	}
+	for i, field := range fields {
+		res[i], _, err = getField(snap, key, m, h, field)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) HPERSIST(key []byte, fields ...[]byte) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, h, ok, err := loadHash(b, key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int, len(fields))
+	if !ok {
+		for i := range res {
+			res[i] = core.FieldMissing
+		}
+		return res, nil
+	}
+
+	now := core.NowMs()
+	for i, field := range fields {
+		_, at, ok, err := readField(b, key, m, h, field)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case !ok || isFieldExpired(at, now):
+			res[i] = core.FieldMissing
+		case at == 0:
+			res[i] = core.FieldNoExpire
+		default:
+			if err := setFieldExpire(b, key, m, &h, field, at, 0); err != nil {
+				return nil, err
+			}
+			res[i] = core.FieldUpdated
+		}
+	}
+
+	if err := putHash(b, key, m, h); err != nil {
+		return nil, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Store) HPEXPIRE(key []byte, milliseconds int64, cond core.ExpireCond, fields ...[]byte) ([]int, error) {
+	now := core.NowMs()
+	at, err := core.ExpireAtMs(now, milliseconds, time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	return s.hexpireAt(key, now, at, cond, fields)
+}
+
+func (s *Store) HPEXPIREAT(key []byte, unixMilliseconds int64, cond core.ExpireCond, fields ...[]byte) ([]int, error) {
+	return s.hexpireAt(key, core.NowMs(), unixMilliseconds, cond, fields)
+}
+
+func (s *Store) HPEXPIRETIME(key []byte, fields ...[]byte) ([]int64, error) {
+	return s.fieldTTL(key, fields, func(ok bool, at int64) int64 {
+		return core.ExpireTimeReply(ok, at, time.Millisecond)
+	})
+}
+
+func (s *Store) HPTTL(key []byte, fields ...[]byte) ([]int64, error) {
+	return s.fieldTTL(key, fields, func(ok bool, at int64) int64 {
+		return core.TTLReply(ok, core.NowMs(), at, time.Millisecond)
+	})
+}
+
+func (s *Store) HRANDFIELD(key []byte, count int, withValues bool) ([][]byte, error) {
+	all, err := s.hashGeneric(key, true, true)
+	if err != nil {
+		return nil, err
+	}
+
+	res := [][]byte{}
+	for _, i := range core.RandIndexes(len(all)/2, count) {
+		res = append(res, all[2*i])
+		if withValues {
+			res = append(res, all[2*i+1])
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) HSCAN(key []byte, cursor uint64, opts core.ScanOptions) ([][]byte, uint64, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, h, ok, err := getHash(snap, key)
+	if err != nil || !ok {
+		return [][]byte{}, 0, err
+	}
+
+	count := opts.Count
+	if count <= 0 {
+		count = core.DefaultScanCount
+	}
+
+	res := [][]byte{}
+	var next uint64
+	i := 0
+	err = walkFields(snap, key, m, h, s.cursors.Seek(key, cursor), func(field, value []byte) bool {
+		if i == count {
+			next = s.cursors.Next(key, field)
+			return false
+		}
+		i++
+
+		if len(opts.Match) == 0 || core.Match(opts.Match, field) {
+			res = append(res, bytes.Clone(field), bytes.Clone(value))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return res, next, nil
+}
+
+func (s *Store) HSET(key []byte, fieldvals ...[]byte) (int, error) {
+	if len(fieldvals) == 0 || len(fieldvals)%2 == 1 {
+		return 0, fmt.Errorf("wrong number of arguments for 'hset' command")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, h, err := s.loadOrNewHash(b, key)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for i := 0; i < len(fieldvals); i += 2 {
+		added, err := setField(b, key, m, &h, fieldvals[i], fieldvals[i+1])
+		if err != nil {
+			return 0, err
+		}
+		if added {
+			n++
+		}
+	}
+
+	if err := putHash(b, key, m, h); err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *Store) HSETNX(key, field, value []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, h, err := s.loadOrNewHash(b, key)
+	if err != nil {
+		return false, err
+	}
+	if _, ok, err := getField(b, key, m, h, field); err != nil || ok {
+		return false, err
+	}
+
+	if _, err := setField(b, key, m, &h, field, value); err != nil {
+		return false, err
+	}
+	if err := putHash(b, key, m, h); err != nil {
+		return false, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Store) HSTRLEN(key, field []byte) (int, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, h, ok, err := getHash(snap, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	value, _, err := getField(snap, key, m, h, field)
+	return len(value), err
+}
+
+func (s *Store) HTTL(key []byte, fields ...[]byte) ([]int64, error) {
+	return s.fieldTTL(key, fields, func(ok bool, at int64) int64 {
+		return core.TTLReply(ok, core.NowMs(), at, time.Second)
+	})
+}
+
+func (s *Store) HVALS(key []byte) ([][]byte, error) {
+	return s.hashGeneric(key, false, true)
+}
+
+// hashGeneric returns fields and/or values of the hash, sorted by field.
+func (s *Store) hashGeneric(key []byte, withFields, withValues bool) ([][]byte, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, h, ok, err := getHash(snap, key)
+	if err != nil || !ok {
+		return [][]byte{}, err
+	}
+
+	res := [][]byte{}
+	err = walkFields(snap, key, m, h, nil, func(field, value []byte) bool {
+		if withFields {
+			res = append(res, bytes.Clone(field))
+		}
+		if withValues {
+			res = append(res, bytes.Clone(value))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// hexpireAt sets expiry of fields to at, from is the time a relative TTL is counted from,
+// so fields are deleted only for a zero TTL or a time in the past, not when the lock was slow.
+func (s *Store) hexpireAt(key []byte, from, at int64, cond core.ExpireCond, fields [][]byte) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, h, ok, err := loadHash(b, key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int, len(fields))
+	if !ok {
+		for i := range res {
+			res[i] = core.FieldMissing
+		}
+		return res, nil
+	}
+
+	now := core.NowMs()
+	for i, field := range fields {
+		_, cur, ok, err := readField(b, key, m, h, field)
+		if err != nil {
+			return nil, err
+		}
+		if ok && isFieldExpired(cur, now) {
+			if err := delField(b, key, m, &h, field, cur); err != nil {
+				return nil, err
+			}
+			ok = false
+		}
+
+		switch {
+		case !ok:
+			res[i] = core.FieldMissing
+		case !cond.Allow(cur, at):
+			res[i] = core.FieldCondNotMet
+		case at <= from:
+			err = delField(b, key, m, &h, field, cur)
+			res[i] = core.FieldDeleted
+		default:
+			err = setFieldExpire(b, key, m, &h, field, cur, at)
+			res[i] = core.FieldUpdated
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := putHash(b, key, m, h); err != nil {
+		return nil, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// fieldTTL returns reply of fn for expiry of each field, ok reports whether the field exists.
+func (s *Store) fieldTTL(key []byte, fields [][]byte, fn func(ok bool, at int64) int64) ([]int64, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, h, ok, err := getHash(snap, key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := core.NowMs()
+	res := make([]int64, len(fields))
+	for i, field := range fields {
+		var at int64
+		var exists bool
+		if ok {
+			_, at, exists, err = readField(snap, key, m, h, field)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if exists && isFieldExpired(at, now) {
+			exists = false
+		}
+		res[i] = fn(exists, at)
+	}
+	return res, nil
+}
+
+// updateField replaces value of the field with the one returned by fn,
+// ok reports whether the field exists. Expiry of the field is kept.
+func (s *Store) updateField(key, field []byte, fn func(value []byte, ok bool) ([]byte, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, h, err := s.loadOrNewHash(b, key)
+	if err != nil {
+		return err
+	}
+	value, at, ok, err := readField(b, key, m, h, field)
+	if err != nil {
+		return err
+	}
+	if ok && isFieldExpired(at, core.NowMs()) {
+		if err := delField(b, key, m, &h, field, at); err != nil {
+			return err
+		}
+		value, ok = nil, false
+	}
+
+	value, err = fn(value, ok)
+	if err != nil {
+		return err
+	}
+	if err := b.Set(fieldKey(key, m, field), value, nil); err != nil {
+		return err
+	}
+	if !ok {
+		h.len++
+	}
+
+	if err := putHash(b, key, m, h); err != nil {
+		return err
+	}
+	return b.Commit(s.syncOpt)
+}
+
+// loadOrNewHash is like loadHash but allocates an empty hash for a missing key,
+// it must be stored with putHash.
+func (s *Store) loadOrNewHash(b *pebble.Batch, key []byte) (meta, hashMeta, error) {
+	m, h, ok, err := loadHash(b, key)
+	if err != nil || ok {
+		return m, h, err
+	}
+	version, err := s.nextVersion(b)
+	if err != nil {
+		return meta{}, hashMeta{}, err
+	}
+	return meta{typ: core.TypeHash, version: version}, hashMeta{}, nil
+}
+
+// getHash is like getMeta but fails for keys that are not hashes.
+func getHash(r pebble.Reader, key []byte) (meta, hashMeta, bool, error) {
+	m, ok, err := getMeta(r, key)
+	return asHash(key, m, ok, err)
+}
+
+// loadHash is like loadMeta but fails for keys that are not hashes.
+func loadHash(b *pebble.Batch, key []byte) (meta, hashMeta, bool, error) {
+	m, ok, err := loadMeta(b, key)
+	return asHash(key, m, ok, err)
+}
+
+func asHash(key []byte, m meta, ok bool, err error) (meta, hashMeta, bool, error) {
+	if err != nil || !ok {
+		return meta{}, hashMeta{}, false, err
+	}
+	if m.typ != core.TypeHash {
+		return meta{}, hashMeta{}, false, core.ErrWrongType
+	}
+	h, err := decodeHashMeta(m)
+	if err != nil {
+		return meta{}, hashMeta{}, false, fmt.Errorf("key %q: %w", key, err)
+	}
+	return m, h, true, nil
+}
+
+// putHash writes hash meta, empty hash is removed.
+func putHash(b *pebble.Batch, key []byte, m meta, h hashMeta) error {
+	if h.len == 0 {
+		return delKey(b, key, m)
+	}
+	m.payload = h.encode()
+	return putMeta(b, key, m)
+}
+
+// setField sets value of the field and removes its expiry, h is updated accordingly.
+// Reports whether the field is new.
+func setField(b *pebble.Batch, key []byte, m meta, h *hashMeta, field, value []byte) (bool, error) {
+	_, at, ok, err := readField(b, key, m, *h, field)
+	if err != nil {
+		return false, err
+	}
+	if ok && isFieldExpired(at, core.NowMs()) {
+		if err := delField(b, key, m, h, field, at); err != nil {
+			return false, err
+		}
+		ok = false
+	}
+	if ok {
+		if err := setFieldExpire(b, key, m, h, field, at, 0); err != nil {
+			return false, err
+		}
+	}
+
+	if err := b.Set(fieldKey(key, m, field), value, nil); err != nil {
+		return false, err
+	}
+	if !ok {
+		h.len++
+	}
+	return !ok, nil
+}
+
+// delField removes the field with its expiry, h is updated accordingly.
+func delField(b *pebble.Batch, key []byte, m meta, h *hashMeta, field []byte, at int64) error {
+	if err := setFieldExpire(b, key, m, h, field, at, 0); err != nil {
+		return err
+	}
+	h.len--
+	return b.Delete(fieldKey(key, m, field), nil)
+}
+
+// setFieldExpire changes expiry of the field from cur to at, zero at removes expiry.
+func setFieldExpire(b *pebble.Batch, key []byte, m meta, h *hashMeta, field []byte, cur, at int64) error {
+	if cur == at {
+		return nil
+	}
+	if cur != 0 {
+		if err := b.Delete(fieldExpKey(cur, key, m.version, field), nil); err != nil {
+			return err
+		}
+		h.ttls--
+	}
+	if at == 0 {
+		return b.Delete(fieldTTLKey(key, m, field), nil)
+	}
+	if err := b.Set(fieldExpKey(at, key, m.version, field), nil, nil); err != nil {
+		return err
+	}
+	h.ttls++
+	return b.Set(fieldTTLKey(key, m, field), binary.BigEndian.AppendUint64(nil, uint64(at)), nil)
+}
+
+// getField returns value of the field, expired field is reported as missing.
+func getField(r pebble.Reader, key []byte, m meta, h hashMeta, field []byte) ([]byte, bool, error) {
+	value, at, ok, err := readField(r, key, m, h, field)
+	if err != nil || !ok || isFieldExpired(at, core.NowMs()) {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// readField returns value of the field and its expiry as is, even if it's expired.
+func readField(r pebble.Reader, key []byte, m meta, h hashMeta, field []byte) ([]byte, int64, bool, error) {
+	value, ok, err := getValue(r, fieldKey(key, m, field))
+	if err != nil || !ok || h.ttls == 0 {
+		return value, 0, ok, err
+	}
+
+	val, hasTTL, err := getValue(r, fieldTTLKey(key, m, field))
+	if err != nil || !hasTTL {
+		return value, 0, ok, err
+	}
+	if len(val) != 8 {
+		return nil, 0, false, fmt.Errorf("key %q: corrupted field expiry", key)
+	}
+	return value, decodeExpireAt(val), true, nil
+}
+
+func getValue(r pebble.Reader, k []byte) ([]byte, bool, error) {
+	val, closer, err := r.Get(k)
+	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer tryClose(closer)
+
+	// non-nil slice, so empty value can be told from a missing one.
+	return append([]byte{}, val...), true, nil
+}
+
+func isFieldExpired(at, now int64) bool {
+	return at != 0 && at <= now
+}
+
+// expiredFields returns expired but not yet reclaimed fields of the hash.
+func expiredFields(r pebble.Reader, key []byte, m meta, h hashMeta) (map[string]bool, error) {
+	if h.ttls == 0 {
+		return nil, nil
+	}
+
+	prefix := ttlKeyPrefix(key, m.version)
+	iter, err := r.NewIter(&pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: prefixEnd(prefix),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tryClose(iter)
+
+	now := core.NowMs()
+	res := map[string]bool{}
+	for iter.First(); iter.Valid(); iter.Next() {
+		if isFieldExpired(decodeExpireAt(iter.Value()), now) {
+			res[string(iter.Key()[len(prefix):])] = true
+		}
+	}
+	return res, iter.Error()
+}
+
+// walkFields calls fn for not expired fields starting from seek in sorted order until it returns false.
+// Arguments of fn are valid only until it returns.
+func walkFields(r pebble.Reader, key []byte, m meta, h hashMeta, seek []byte, fn func(field, value []byte) bool) error {
+	expired, err := expiredFields(r, key, m, h)
+	if err != nil {
+		return err
+	}
+
+	prefix := dataKeyPrefix(key, m.version)
+	iter, err := r.NewIter(&pebble.IterOptions{
+		LowerBound: fieldKey(key, m, seek),
+		UpperBound: prefixEnd(prefix),
+	})
+	if err != nil {
+		return err
+	}
+	defer tryClose(iter)
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		field := iter.Key()[len(prefix):]
+		if expired[string(field)] {
+			continue
+		}
+		if !fn(field, iter.Value()) {
+			break
+		}
+	}
+	return iter.Error()
+}
+
+func fieldKey(key []byte, m meta, field []byte) []byte {
+	return append(dataKeyPrefix(key, m.version), field...)
+}
+
+func fieldTTLKey(key []byte, m meta, field []byte) []byte {
+	return append(ttlKeyPrefix(key, m.version), field...)
+}
+
+// reclaimField removes the field expired at the given time, the hash is removed if it becomes empty.
+// Nothing is done if the hash or the field is gone or has another expiry.
+func reclaimField(b *pebble.Batch, key []byte, version uint64, field []byte, at int64) error {
+	m, ok, err := readMeta(b, key)
+	if err != nil || !ok || m.typ != core.TypeHash || m.version != version {
+		return err
+	}
+	_, h, _, err := asHash(key, m, ok, nil)
+	if err != nil {
+		return err
+	}
+
+	_, cur, ok, err := readField(b, key, m, h, field)
+	if err != nil || !ok || cur != at {
+		return err
+	}
+	if err := delField(b, key, m, &h, field, at); err != nil {
+		return err
+	}
+	return putHash(b, key, m, h)
+}
@@ -0,0 +1,960 @@
+package ondisk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cristalhq/testt"
+)
+
+func TestHDEL(t *testing.T) {
+	/*
+		redis> HSET myhash field1 "foo"
+		(integer) 1
+		redis> HDEL myhash field1
+		(integer) 1
+		redis> HDEL myhash field2
+		(integer) 0
+		redis>
+	*/
+
+	myhash := []byte("myhash")
+
+	s := newStore(t)
+	n, err := s.HSET(myhash, []byte("field1"), []byte("foo"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.HDEL(myhash, []byte("field1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.HDEL(myhash, []byte("field2"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	// empty hash is removed.
+	typ, err := s.TYPE(myhash)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "none")
+}
+
+func TestHEXISTS(t *testing.T) {
+	/*
+		redis> HSET myhash field1 "foo"
+		(integer) 1
+		redis> HEXISTS myhash field1
+		(integer) 1
+		redis> HEXISTS myhash field2
+		(integer) 0
+		redis>
+	*/
+
+	myhash := []byte("myhash")
+
+	s := newStore(t)
+	n, err := s.HSET(myhash, []byte("field1"), []byte("foo"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	ok, err := s.HEXISTS(myhash, []byte("field1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = s.HEXISTS(myhash, []byte("field2"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+}
+
+func TestHGET(t *testing.T) {
+	/*
+		redis> HSET myhash field1 "foo"
+		(integer) 1
+		redis> HGET myhash field1
+		"foo"
+		redis> HGET myhash field2
+		(nil)
+		redis>
+	*/
+
+	myhash := []byte("myhash")
+
+	s := newStore(t)
+	n, err := s.HSET(myhash, []byte("field1"), []byte("foo"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	val, err := s.HGET(myhash, []byte("field1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "foo")
+
+	_, err = s.HGET(myhash, []byte("field2"))
+	testt.MustEqual(t, err, core.ErrKeyNotFound)
+}
+
+func TestHGETALL(t *testing.T) {
+	/*
+		redis> HSET myhash field1 "Hello"
+		(integer) 1
+		redis> HSET myhash field2 "World"
+		(integer) 1
+		redis> HGETALL myhash
+		1) "field1"
+		2) "Hello"
+		3) "field2"
+		4) "World"
+		redis>
+	*/
+
+	myhash := []byte("myhash")
+
+	s := newStore(t)
+	n, err := s.HSET(myhash, []byte("field1"), []byte("Hello"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.HSET(myhash, []byte("field2"), []byte("World"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	res, err := s.HGETALL(myhash)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("field1"), []byte("Hello"), []byte("field2"), []byte("World")})
+
+	res, err = s.HGETALL([]byte("nosuchkey"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{})
+}
+
+func TestHINCRBY(t *testing.T) {
+	/*
+		redis> HSET myhash field 5
+		(integer) 1
+		redis> HINCRBY myhash field 1
+		(integer) 6
+		redis> HINCRBY myhash field -1
+		(integer) 5
+		redis> HINCRBY myhash field -10
+		(integer) -5
+		redis>
+	*/
+
+	myhash := []byte("myhash")
+	field := []byte("field")
+
+	s := newStore(t)
+	n, err := s.HSET(myhash, field, []byte("5"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	num, err := s.HINCRBY(myhash, field, 1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, num, int64(6))
+
+	num, err = s.HINCRBY(myhash, field, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, num, int64(5))
+
+	num, err = s.HINCRBY(myhash, field, -10)
+	testt.NoError(t, err)
+	testt.MustEqual(t, num, int64(-5))
+
+	num, err = s.HINCRBY(myhash, []byte("other"), 3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, num, int64(3))
+
+	_, err = s.HINCRBY(myhash, field, -1<<63)
+	testt.MustEqual(t, err, core.ErrOverflow)
+
+	_, err = s.HSET(myhash, field, []byte("abc"))
+	testt.NoError(t, err)
+	_, err = s.HINCRBY(myhash, field, 1)
+	testt.MustEqual(t, err, core.ErrHashNotInt)
+}
+
+func TestHINCRBYFLOAT(t *testing.T) {
+	/*
+		redis> HSET mykey field 10.50
+		(integer) 1
+		redis> HINCRBYFLOAT mykey field 0.1
+		"10.6"
+		redis> HINCRBYFLOAT mykey field -5
+		"5.6"
+		redis> HSET mykey field 5.0e3
+		(integer) 0
+		redis> HINCRBYFLOAT mykey field 2.0e2
+		"5200"
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+	field := []byte("field")
+
+	s := newStore(t)
+	n, err := s.HSET(mykey, field, []byte("10.50"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	val, err := s.HINCRBYFLOAT(mykey, field, 0.1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "10.6")
+
+	val, err = s.HINCRBYFLOAT(mykey, field, -5)
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "5.6")
+
+	n, err = s.HSET(mykey, field, []byte("5.0e3"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	val, err = s.HINCRBYFLOAT(mykey, field, 2.0e2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "5200")
+
+	_, err = s.HSET(mykey, field, []byte("abc"))
+	testt.NoError(t, err)
+	_, err = s.HINCRBYFLOAT(mykey, field, 1)
+	testt.MustEqual(t, err, core.ErrHashNotFloat)
+
+	_, err = s.HSET(mykey, field, []byte("1.7e308"))
+	testt.NoError(t, err)
+	_, err = s.HINCRBYFLOAT(mykey, field, 1.7e308)
+	testt.MustEqual(t, err, core.ErrIncrNaNOrInf)
+
+	got, err := s.HGET(mykey, field)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(got), "1.7e308")
+}
+
+func TestHKEYS(t *testing.T) {
+	/*
+		redis> HSET myhash field1 "Hello"
+		(integer) 1
+		redis> HSET myhash field2 "World"
+		(integer) 1
+		redis> HKEYS myhash
+		1) "field1"
+		2) "field2"
+		redis>
+	*/
+
+	myhash := []byte("myhash")
+
+	s := newStore(t)
+	n, err := s.HSET(myhash, []byte("field1"), []byte("Hello"), []byte("field2"), []byte("World"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err := s.HKEYS(myhash)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("field1"), []byte("field2")})
+}
+
+func TestHLEN(t *testing.T) {
+	/*
+		redis> HSET myhash field1 "Hello"
+		(integer) 1
+		redis> HSET myhash field2 "World"
+		(integer) 1
+		redis> HLEN myhash
+		(integer) 2
+		redis>
+	*/
+
+	myhash := []byte("myhash")
+
+	s := newStore(t)
+	n, err := s.HSET(myhash, []byte("field1"), []byte("Hello"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.HSET(myhash, []byte("field2"), []byte("World"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.HLEN(myhash)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+}
+
+func TestHMGET(t *testing.T) {
+	/*
+		redis> HSET myhash field1 "Hello"
+		(integer) 1
+		redis> HSET myhash field2 "World"
+		(integer) 1
+		redis> HMGET myhash field1 field2 nofield
+		1) "Hello"
+		2) "World"
+		3) (nil)
+		redis>
+	*/
+
+	myhash := []byte("myhash")
+
+	s := newStore(t)
+	n, err := s.HSET(myhash, []byte("field1"), []byte("Hello"), []byte("field2"), []byte("World"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err := s.HMGET(myhash, []byte("field1"), []byte("field2"), []byte("nofield"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("Hello"), []byte("World"), nil})
+
+	res, err = s.HMGET([]byte("nosuchkey"), []byte("field1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{nil})
+}
+
+func TestHRANDFIELD(t *testing.T) {
+	/*
+		redis> HSET coin heads obverse tails reverse edge null
+		(integer) 3
+		redis> HRANDFIELD coin
+		"heads"
+		redis> HRANDFIELD coin -5 WITHVALUES
+		 1) "heads"
+		 2) "obverse"
+		 3) "heads"
+		 4) "obverse"
+		 5) "edge"
+		 6) "null"
+		 7) "tails"
+		 8) "reverse"
+		 9) "heads"
+		10) "obverse"
+		redis>
+	*/
+
+	coin := []byte("coin")
+
+	s := newStore(t)
+	n, err := s.HSET(coin, []byte("heads"), []byte("obverse"), []byte("tails"), []byte("reverse"), []byte("edge"), []byte("null"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	values := map[string]string{"heads": "obverse", "tails": "reverse", "edge": "null"}
+
+	res, err := s.HRANDFIELD(coin, 1, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 1)
+
+	res, err = s.HRANDFIELD(coin, -5, true)
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 10)
+	for i := 0; i < len(res); i += 2 {
+		testt.MustEqual(t, values[string(res[i])], string(res[i+1]))
+	}
+
+	res, err = s.HRANDFIELD(coin, 5, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 3)
+	seen := map[string]bool{}
+	for _, field := range res {
+		seen[string(field)] = true
+	}
+	testt.MustEqual(t, len(seen), 3)
+
+	res, err = s.HRANDFIELD([]byte("nosuchkey"), 1, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{})
+}
+
+func TestHSCAN(t *testing.T) {
+	myhash := []byte("myhash")
+
+	s := newStore(t)
+	for _, field := range []string{"a1", "a2", "b1", "b2", "c1"} {
+		_, err := s.HSET(myhash, []byte(field), []byte("v"+field))
+		testt.NoError(t, err)
+	}
+
+	var fields []string
+	cursor := uint64(0)
+	for {
+		res, next, err := s.HSCAN(myhash, cursor, core.ScanOptions{Count: 2, Match: []byte("*1")})
+		testt.NoError(t, err)
+		for i := 0; i < len(res); i += 2 {
+			testt.MustEqual(t, string(res[i+1]), "v"+string(res[i]))
+			fields = append(fields, string(res[i]))
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	testt.MustEqual(t, fields, []string{"a1", "b1", "c1"})
+
+	res, next, err := s.HSCAN([]byte("nosuchkey"), 0, core.ScanOptions{})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{})
+	testt.MustEqual(t, next, uint64(0))
+}
+
+func TestHSET(t *testing.T) {
+	/*
+		redis> HSET myhash field1 "Hello"
+		(integer) 1
+		redis> HGET myhash field1
+		"Hello"
+		redis> HSET myhash field2 "Hi" field3 "World"
+		(integer) 2
+		redis> HGET myhash field2
+		"Hi"
+		redis> HGET myhash field3
+		"World"
+		redis> HGETALL myhash
+		1) "field1"
+		2) "Hello"
+		3) "field2"
+		4) "Hi"
+		5) "field3"
+		6) "World"
+		redis>
+	*/
+
+	myhash := []byte("myhash")
+
+	s := newStore(t)
+	n, err := s.HSET(myhash, []byte("field1"), []byte("Hello"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	val, err := s.HGET(myhash, []byte("field1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "Hello")
+
+	n, err = s.HSET(myhash, []byte("field2"), []byte("Hi"), []byte("field3"), []byte("World"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	val, err = s.HGET(myhash, []byte("field2"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "Hi")
+
+	val, err = s.HGET(myhash, []byte("field3"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "World")
+
+	res, err := s.HGETALL(myhash)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{
+		[]byte("field1"), []byte("Hello"),
+		[]byte("field2"), []byte("Hi"),
+		[]byte("field3"), []byte("World"),
+	})
+
+	// empty value is not a missing one.
+	n, err = s.HSET(myhash, []byte("empty"), []byte{})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	res, err = s.HMGET(myhash, []byte("empty"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{{}})
+}
+
+func TestHSETNX(t *testing.T) {
+	/*
+		redis> HSETNX myhash field "Hello"
+		(integer) 1
+		redis> HSETNX myhash field "World"
+		(integer) 0
+		redis> HGET myhash field
+		"Hello"
+		redis>
+	*/
+
+	myhash := []byte("myhash")
+	field := []byte("field")
+
+	s := newStore(t)
+	ok, err := s.HSETNX(myhash, field, []byte("Hello"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = s.HSETNX(myhash, field, []byte("World"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	val, err := s.HGET(myhash, field)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "Hello")
+}
+
+func TestHSTRLEN(t *testing.T) {
+	/*
+		redis> HSET myhash f1 HelloWorld f2 99 f3 -256
+		(integer) 3
+		redis> HSTRLEN myhash f1
+		(integer) 10
+		redis> HSTRLEN myhash f2
+		(integer) 2
+		redis> HSTRLEN myhash f3
+		(integer) 4
+		redis>
+	*/
+
+	myhash := []byte("myhash")
+
+	s := newStore(t)
+	n, err := s.HSET(myhash, []byte("f1"), []byte("HelloWorld"), []byte("f2"), []byte("99"), []byte("f3"), []byte("-256"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	n, err = s.HSTRLEN(myhash, []byte("f1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 10)
+
+	n, err = s.HSTRLEN(myhash, []byte("f2"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	n, err = s.HSTRLEN(myhash, []byte("f3"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 4)
+}
+
+func TestHVALS(t *testing.T) {
+	/*
+		redis> HSET myhash field1 "Hello"
+		(integer) 1
+		redis> HSET myhash field2 "World"
+		(integer) 1
+		redis> HVALS myhash
+		1) "Hello"
+		2) "World"
+		redis>
+	*/
+
+	myhash := []byte("myhash")
+
+	s := newStore(t)
+	n, err := s.HSET(myhash, []byte("field1"), []byte("Hello"), []byte("field2"), []byte("World"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err := s.HVALS(myhash)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("Hello"), []byte("World")})
+}
+
+func TestHashWrongType(t *testing.T) {
+	key := []byte("key")
+
+	s := newStore(t)
+	_, _, err := s.SET(key, []byte("value"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	_, err = s.HSET(key, []byte("field"), []byte("value"))
+	testt.MustEqual(t, err, core.ErrWrongType)
+
+	_, err = s.HGET(key, []byte("field"))
+	testt.MustEqual(t, err, core.ErrWrongType)
+
+	_, err = s.HGETALL(key)
+	testt.MustEqual(t, err, core.ErrWrongType)
+
+	_, err = s.HINCRBY(key, []byte("field"), 1)
+	testt.MustEqual(t, err, core.ErrWrongType)
+
+	myhash := []byte("myhash")
+	_, err = s.HSET(myhash, []byte("field"), []byte("value"))
+	testt.NoError(t, err)
+
+	_, err = s.GET(myhash)
+	testt.MustEqual(t, err, core.ErrWrongType)
+
+	typ, err := s.TYPE(myhash)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "hash")
+}
+
+func TestHashCopyRename(t *testing.T) {
+	myhash := []byte("myhash")
+
+	s := newStore(t)
+	_, err := s.HSET(myhash, []byte("field"), []byte("value"))
+	testt.NoError(t, err)
+
+	ok, err := s.COPY(myhash, []byte("copy"), false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	// copy is independent from the source.
+	_, err = s.HSET(myhash, []byte("field"), []byte("changed"))
+	testt.NoError(t, err)
+
+	val, err := s.HGET([]byte("copy"), []byte("field"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "value")
+
+	err = s.RENAME([]byte("copy"), []byte("renamed"))
+	testt.NoError(t, err)
+
+	res, err := s.HGETALL([]byte("renamed"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("field"), []byte("value")})
+
+	n, err := s.HLEN([]byte("copy"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+}
+
+func TestHashKeysDoNotMix(t *testing.T) {
+	s := newStore(t)
+
+	// fields of a hash must not be visible from a hash with a key that is a prefix.
+	_, err := s.HSET([]byte("ab"), []byte("c"), []byte("1"))
+	testt.NoError(t, err)
+	_, err = s.HSET([]byte("a"), []byte("bc"), []byte("2"))
+	testt.NoError(t, err)
+
+	res, err := s.HGETALL([]byte("a"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("bc"), []byte("2")})
+
+	// fields of a deleted hash are not visible in a new one.
+	_, err = s.DEL([]byte("ab"))
+	testt.NoError(t, err)
+	_, err = s.HSET([]byte("ab"), []byte("d"), []byte("3"))
+	testt.NoError(t, err)
+
+	res, err = s.HGETALL([]byte("ab"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("d"), []byte("3")})
+}
+
+func TestHEXPIRE(t *testing.T) {
+	/*
+		redis> HEXPIRE no-key 20 NX FIELDS 2 field1 field2
+		1) (integer) -2
+		2) (integer) -2
+		redis> HSET mykey field1 "hello" field2 "world"
+		(integer) 2
+		redis> HEXPIRE mykey 10 FIELDS 3 field1 field2 field3
+		1) (integer) 1
+		2) (integer) 1
+		3) (integer) -2
+		redis> HGETALL mykey
+		1) "field1"
+		2) "hello"
+		3) "field2"
+		4) "world"
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+	field1, field2, field3 := []byte("field1"), []byte("field2"), []byte("field3")
+
+	s := newStore(t)
+	res, err := s.HEXPIRE([]byte("no-key"), 20, core.ExpireNX, field1, field2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int{core.FieldMissing, core.FieldMissing})
+
+	n, err := s.HSET(mykey, field1, []byte("hello"), field2, []byte("world"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err = s.HEXPIRE(mykey, 10, 0, field1, field2, field3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int{core.FieldUpdated, core.FieldUpdated, core.FieldMissing})
+
+	all, err := s.HGETALL(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, all, [][]byte{field1, []byte("hello"), field2, []byte("world")})
+
+	// conditions are checked per field.
+	res, err = s.HEXPIRE(mykey, 20, core.ExpireNX, field1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int{core.FieldCondNotMet})
+
+	res, err = s.HEXPIRE(mykey, 20, core.ExpireGT, field1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int{core.FieldUpdated})
+
+	res, err = s.HEXPIRE(mykey, 5, core.ExpireGT, field1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int{core.FieldCondNotMet})
+
+	// expiry in the past deletes the field.
+	res, err = s.HEXPIRE(mykey, 0, 0, field2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int{core.FieldDeleted})
+
+	n, err = s.HLEN(mykey)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	_, err = s.HEXPIRE(mykey, 1<<62, 0, field1)
+	testt.MustEqual(t, err, core.ErrInvalidExpireTime)
+}
+
+func TestHEXPIREPast(t *testing.T) {
+	mykey := []byte("mykey")
+	field1, field2, field3 := []byte("field1"), []byte("field2"), []byte("field3")
+
+	s := newStore(t)
+	n, err := s.HSET(mykey, field1, []byte("a"), field2, []byte("b"), field3, []byte("c"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	// a zero TTL deletes the field.
+	res, err := s.HEXPIRE(mykey, 0, 0, field1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int{core.FieldDeleted})
+
+	// a time in the past deletes the field.
+	res, err = s.HEXPIREAT(mykey, 1, 0, field2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int{core.FieldDeleted})
+
+	// a small positive TTL only sets the expiry.
+	res, err = s.HPEXPIRE(mykey, 1, 0, field3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int{core.FieldUpdated})
+
+	exp, err := s.HPEXPIRETIME(mykey, field1, field2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, exp, []int64{core.FieldMissing, core.FieldMissing})
+}
+
+func TestHPERSIST(t *testing.T) {
+	/*
+		redis> HSET mykey field1 "hello" field2 "world"
+		(integer) 2
+		redis> HEXPIRE mykey 300 FIELDS 2 field1 field2
+		1) (integer) 1
+		2) (integer) 1
+		redis> HTTL mykey FIELDS 2 field1 field2
+		1) (integer) 300
+		2) (integer) 300
+		redis> HPERSIST mykey FIELDS 1 field2
+		1) (integer) 1
+		redis> HTTL mykey FIELDS 2 field1 field2
+		1) (integer) 300
+		2) (integer) -1
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+	field1, field2 := []byte("field1"), []byte("field2")
+
+	s := newStore(t)
+	n, err := s.HSET(mykey, field1, []byte("hello"), field2, []byte("world"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err := s.HEXPIRE(mykey, 300, 0, field1, field2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int{core.FieldUpdated, core.FieldUpdated})
+
+	ttls, err := s.HTTL(mykey, field1, field2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttls, []int64{300, 300})
+
+	res, err = s.HPERSIST(mykey, field2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int{core.FieldUpdated})
+
+	ttls, err = s.HTTL(mykey, field1, field2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttls, []int64{300, -1})
+
+	res, err = s.HPERSIST(mykey, field2, []byte("field3"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int{core.FieldNoExpire, core.FieldMissing})
+}
+
+func TestHTTL(t *testing.T) {
+	/*
+		redis> HTTL no-key FIELDS 3 field1 field2 field3
+		1) (integer) -2
+		2) (integer) -2
+		3) (integer) -2
+		redis> HSET mykey field1 "hello" field2 "world"
+		(integer) 2
+		redis> HEXPIRE mykey 300 FIELDS 2 field1 field3
+		1) (integer) 1
+		2) (integer) -2
+		redis> HTTL mykey FIELDS 3 field1 field2 field3
+		1) (integer) 300
+		2) (integer) -1
+		3) (integer) -2
+		redis>
+	*/
+
+	mykey := []byte("mykey")
+	field1, field2, field3 := []byte("field1"), []byte("field2"), []byte("field3")
+
+	s := newStore(t)
+	ttls, err := s.HTTL([]byte("no-key"), field1, field2, field3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttls, []int64{-2, -2, -2})
+
+	n, err := s.HSET(mykey, field1, []byte("hello"), field2, []byte("world"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err := s.HEXPIRE(mykey, 300, 0, field1, field3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int{core.FieldUpdated, core.FieldMissing})
+
+	ttls, err = s.HTTL(mykey, field1, field2, field3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttls, []int64{300, -1, -2})
+
+	at := core.NowMs() + 10_000
+	res, err = s.HPEXPIREAT(mykey, at, 0, field2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int{core.FieldUpdated})
+
+	times, err := s.HPEXPIRETIME(mykey, field2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, times, []int64{at})
+
+	times, err = s.HEXPIRETIME(mykey, field2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, times, []int64{(at + 500) / 1000})
+}
+
+func TestHashFieldExpired(t *testing.T) {
+	myhash := []byte("myhash")
+	field1, field2, field3 := []byte("field1"), []byte("field2"), []byte("field3")
+
+	s := newStore(t)
+	n, err := s.HSET(myhash, field1, []byte("1"), field2, []byte("2"), field3, []byte("3"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	res, err := s.HPEXPIRE(myhash, 1, 0, field1, field2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int{core.FieldUpdated, core.FieldUpdated})
+	time.Sleep(5 * time.Millisecond)
+
+	// expired fields are not visible.
+	_, err = s.HGET(myhash, field1)
+	testt.MustEqual(t, err, core.ErrKeyNotFound)
+
+	all, err := s.HGETALL(myhash)
+	testt.NoError(t, err)
+	testt.MustEqual(t, all, [][]byte{field3, []byte("3")})
+
+	n, err = s.HLEN(myhash)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	ttls, err := s.HPTTL(myhash, field1, field3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttls, []int64{-2, -1})
+
+	// expired field is set as a new one without expiry.
+	n, err = s.HSET(myhash, field1, []byte("new"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	ttls, err = s.HPTTL(myhash, field1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttls, []int64{-1})
+
+	n, err = s.HLEN(myhash)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	// HINCRBY keeps expiry, HSET removes it.
+	_, err = s.HEXPIRE(myhash, 100, 0, field3)
+	testt.NoError(t, err)
+
+	num, err := s.HINCRBY(myhash, field3, 1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, num, int64(4))
+
+	ttls, err = s.HTTL(myhash, field3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttls, []int64{100})
+
+	_, err = s.HSET(myhash, field3, []byte("5"))
+	testt.NoError(t, err)
+
+	ttls, err = s.HTTL(myhash, field3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttls, []int64{-1})
+
+	// copy keeps expiry of fields.
+	_, err = s.HEXPIRE(myhash, 100, 0, field3)
+	testt.NoError(t, err)
+
+	ok, err := s.COPY(myhash, []byte("copy"), false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ttls, err = s.HTTL([]byte("copy"), field1, field3)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttls, []int64{-1, 100})
+
+	// the whole hash expires with its last field.
+	_, err = s.HPEXPIRE(myhash, 1, 0, field1, field3)
+	testt.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	all, err = s.HGETALL(myhash)
+	testt.NoError(t, err)
+	testt.MustEqual(t, all, [][]byte{})
+
+	n, err = s.HSET(myhash, field2, []byte("2"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.HLEN(myhash)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+}
+
+func TestSweepFields(t *testing.T) {
+	myhash := []byte("myhash")
+
+	s := newStore(t)
+	for i := 0; i < 300; i++ {
+		field := []byte{byte(i >> 8), byte(i)}
+		_, err := s.HSET(myhash, field, []byte("Hello"))
+		testt.NoError(t, err)
+
+		_, err = s.HPEXPIRE(myhash, 1, 0, field)
+		testt.NoError(t, err)
+	}
+
+	// index entries of a deleted hash are stale.
+	_, err := s.HSET([]byte("deleted"), []byte("field"), []byte("Hello"))
+	testt.NoError(t, err)
+	_, err = s.HPEXPIRE([]byte("deleted"), 1, 0, []byte("field"))
+	testt.NoError(t, err)
+	_, err = s.DEL([]byte("deleted"))
+	testt.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+
+	n, err := s.sweepFields()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, sweepLimit)
+
+	for n == sweepLimit {
+		n, err = s.sweepFields()
+		testt.NoError(t, err)
+	}
+
+	// the hash is removed with its last field.
+	n, err = s.EXISTS(myhash)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	// only the format and version keys are left.
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: dataPrefix,
+	})
+	testt.NoError(t, err)
+	defer iter.Close()
+	testt.MustEqual(t, iter.First(), false)
+}
@@ -0,0 +1,168 @@
+package ondisk
+
+import (
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// HyperLogLog operations https://redis.io/commands/?group=hyperloglog
+
+// HyperLogLogs are strings, they are read and written as a whole,
+// the dense encoding is only 12KB.
+
+func (s *Store) PFADD(key []byte, elements ...[]byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, val, err := loadHLL(b, key)
+	if err != nil {
+		return false, err
+	}
+
+	val, updated, err := core.HLLAdd(val, elements)
+	if err != nil {
+		return false, err
+	}
+	if !updated {
+		return false, b.Commit(s.syncOpt)
+	}
+	if err := s.putHLL(b, key, m, val); err != nil {
+		return false, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Store) PFCOUNT(keys ...[]byte) (int64, error) {
+	if len(keys) > 1 {
+		return s.pfCountUnion(keys)
+	}
+
+	// the cached cardinality is updated, so it's a write.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, val, err := loadHLL(b, keys[0])
+	if err != nil {
+		return 0, err
+	}
+	if val == nil {
+		return 0, b.Commit(s.syncOpt)
+	}
+
+	card, updated, err := core.HLLCount(val)
+	if err != nil {
+		return 0, err
+	}
+	if updated {
+		if err := s.putHLL(b, keys[0], m, val); err != nil {
+			return 0, err
+		}
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return card, nil
+}
+
+func (s *Store) PFMERGE(dst []byte, keys ...[]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	var regs core.HLLRegisters
+	dense := false
+	for _, key := range append([][]byte{dst}, keys...) {
+		_, val, err := loadHLL(b, key)
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			continue
+		}
+		dense = dense || core.IsDenseHLL(val)
+		if err := regs.Merge(val); err != nil {
+			return err
+		}
+	}
+
+	m, val, err := loadHLL(b, dst)
+	if err != nil {
+		return err
+	}
+	if val == nil {
+		val = core.NewHLL()
+	}
+	val, err = core.HLLStore(val, &regs, dense)
+	if err != nil {
+		return err
+	}
+	if err := s.putHLL(b, dst, m, val); err != nil {
+		return err
+	}
+	return b.Commit(s.syncOpt)
+}
+
+func (s *Store) pfCountUnion(keys [][]byte) (int64, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	var regs core.HLLRegisters
+	for _, key := range keys {
+		m, ok, err := getString(snap, key)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		val, err := stringValue(snap, key, m)
+		if err != nil {
+			return 0, err
+		}
+		if err := core.CheckHLL(val); err != nil {
+			return 0, err
+		}
+		if err := regs.Merge(val); err != nil {
+			return 0, err
+		}
+	}
+	return regs.Count(), nil
+}
+
+// loadHLL is like loadString but also returns the value and fails for strings that are not HyperLogLogs.
+// The value is nil for a missing key.
+func loadHLL(b *pebble.Batch, key []byte) (meta, []byte, error) {
+	m, ok, err := loadString(b, key)
+	if err != nil || !ok {
+		return meta{}, nil, err
+	}
+	val, err := stringValue(b, key, m)
+	if err != nil {
+		return meta{}, nil, err
+	}
+	if err := core.CheckHLL(val); err != nil {
+		return meta{}, nil, err
+	}
+	return m, val, nil
+}
+
+// putHLL writes the HyperLogLog keeping expiry of the key, m is zero for a missing key.
+// HyperLogLogs never shrink, so the value overwrites the old one.
+func (s *Store) putHLL(b *pebble.Batch, key []byte, m meta, val []byte) error {
+	if m.typ == core.TypeNone {
+		m = newString(nil)
+	}
+	return s.writeRange(b, key, &m, 0, val)
+}
@@ -0,0 +1,243 @@
+package ondisk
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// JSON operations https://redis.io/commands/?group=json
+
+// JSON documents are stored in the binary encoding of core as a single data value:
+//
+//	d + len(key) + key + version => document
+//
+// Paths are resolved over the encoding, so reads don't parse the document and meta
+// records stay small. Every write still loads the whole document and stores it again,
+// so its cost grows with the document size, not with the size of the change.
+
+func (s *Store) JSONARRAPPEND(key []byte, path core.JSONPath, values ...[]byte) ([]*int64, error) {
+	var res []*int64
+	err := s.updateJSON(key, func(doc []byte) (out []byte, err error) {
+		out, res, err = core.JSONArrAppend(doc, path, values...)
+		return out, err
+	})
+	return res, err
+}
+
+func (s *Store) JSONARRINSERT(key []byte, path core.JSONPath, index int64, values ...[]byte) ([]*int64, error) {
+	var res []*int64
+	err := s.updateJSON(key, func(doc []byte) (out []byte, err error) {
+		out, res, err = core.JSONArrInsert(doc, path, index, values...)
+		return out, err
+	})
+	return res, err
+}
+
+func (s *Store) JSONARRPOP(key []byte, path core.JSONPath, index int64) ([][]byte, error) {
+	var res [][]byte
+	err := s.updateJSON(key, func(doc []byte) (out []byte, err error) {
+		out, res, err = core.JSONArrPop(doc, path, index)
+		return out, err
+	})
+	return res, err
+}
+
+func (s *Store) JSONDEL(key []byte, path core.JSONPath) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, doc, err := loadJSON(b, key)
+	if err != nil {
+		return 0, err
+	}
+	if doc == nil {
+		return 0, b.Commit(s.syncOpt)
+	}
+
+	n := 1
+	if path.IsRoot() {
+		err = delKey(b, key, m)
+	} else {
+		doc, n, err = core.JSONDel(doc, path)
+		if err == nil && n > 0 {
+			err = b.Set(dataKeyPrefix(key, m.version), doc, nil)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *Store) JSONGET(key []byte, f core.JSONFormat, paths ...core.JSONPath) ([]byte, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	_, doc, err := getJSON(snap, key)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, core.ErrKeyNotFound
+	}
+	return core.JSONGet(doc, f, paths...)
+}
+
+func (s *Store) JSONMERGE(key []byte, path core.JSONPath, patch []byte) error {
+	return s.updateJSON(key, func(doc []byte) ([]byte, error) {
+		return core.JSONMerge(doc, path, patch)
+	})
+}
+
+func (s *Store) JSONMGET(path core.JSONPath, keys ...[]byte) ([][]byte, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	docs := make([][]byte, len(keys))
+	for i, key := range keys {
+		_, doc, err := getJSON(snap, key)
+		// keys of other types are reported as missing.
+		if err != nil && !errors.Is(err, core.ErrWrongType) {
+			return nil, err
+		}
+		docs[i] = doc
+	}
+	return core.JSONMGet(docs, path), nil
+}
+
+func (s *Store) JSONNUMINCRBY(key []byte, path core.JSONPath, by []byte) ([]byte, error) {
+	var res []byte
+	err := s.updateJSON(key, func(doc []byte) (out []byte, err error) {
+		out, res, err = core.JSONNumIncrBy(doc, path, by)
+		return out, err
+	})
+	return res, err
+}
+
+func (s *Store) JSONOBJKEYS(key []byte, path core.JSONPath) ([][][]byte, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	_, doc, err := getJSON(snap, key)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, core.ErrKeyNotFound
+	}
+	return core.JSONObjKeys(doc, path)
+}
+
+func (s *Store) JSONSET(key []byte, path core.JSONPath, value []byte, opts core.JSONSetOptions) (bool, error) {
+	set := false
+	err := s.updateJSON(key, func(doc []byte) ([]byte, error) {
+		out, err := core.JSONSet(doc, path, value, opts)
+		set = out != nil
+		return out, err
+	})
+	return set, err
+}
+
+func (s *Store) JSONSTRAPPEND(key []byte, path core.JSONPath, value []byte) ([]*int64, error) {
+	var res []*int64
+	err := s.updateJSON(key, func(doc []byte) (out []byte, err error) {
+		out, res, err = core.JSONStrAppend(doc, path, value)
+		return out, err
+	})
+	return res, err
+}
+
+func (s *Store) JSONTYPE(key []byte, path core.JSONPath) ([]string, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	_, doc, err := getJSON(snap, key)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, core.ErrKeyNotFound
+	}
+	return core.JSONType(doc, path), nil
+}
+
+// updateJSON replaces the document of the key with the result of fn unless it's nil,
+// fn gets nil for a missing key. Expiry of the key is kept.
+func (s *Store) updateJSON(key []byte, fn func(doc []byte) ([]byte, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, doc, err := loadJSON(b, key)
+	if err != nil {
+		return err
+	}
+	doc, err = fn(doc)
+	if err != nil {
+		return err
+	}
+	if doc != nil {
+		if m.typ == core.TypeNone {
+			if m, err = s.newJSON(b, key); err != nil {
+				return err
+			}
+		}
+		if err := b.Set(dataKeyPrefix(key, m.version), doc, nil); err != nil {
+			return err
+		}
+	}
+	return b.Commit(s.syncOpt)
+}
+
+// newJSON writes meta of a new document.
+func (s *Store) newJSON(b *pebble.Batch, key []byte) (meta, error) {
+	version, err := s.nextVersion(b)
+	if err != nil {
+		return meta{}, err
+	}
+	m := meta{typ: core.TypeJSON, version: version}
+	return m, putKey(b, key, m)
+}
+
+// getJSON is like getMeta but fails for keys that are not JSON documents and also returns the document.
+// The document is nil for a missing key.
+func getJSON(r pebble.Reader, key []byte) (meta, []byte, error) {
+	m, ok, err := getMeta(r, key)
+	return asJSON(r, key, m, ok, err)
+}
+
+// loadJSON is like loadMeta but fails for keys that are not JSON documents and also returns the document.
+// The document is nil for a missing key.
+func loadJSON(b *pebble.Batch, key []byte) (meta, []byte, error) {
+	m, ok, err := loadMeta(b, key)
+	return asJSON(b, key, m, ok, err)
+}
+
+func asJSON(r pebble.Reader, key []byte, m meta, ok bool, err error) (meta, []byte, error) {
+	if err != nil || !ok {
+		return meta{}, nil, err
+	}
+	if m.typ != core.TypeJSON {
+		return meta{}, nil, core.ErrWrongType
+	}
+	doc, ok, err := getValue(r, dataKeyPrefix(key, m.version))
+	if err != nil {
+		return meta{}, nil, err
+	}
+	if !ok {
+		return meta{}, nil, fmt.Errorf("key %q: missing JSON document", key)
+	}
+	return m, doc, nil
+}
@@ -0,0 +1,252 @@
+package ondisk
+
+import (
+	"testing"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cristalhq/testt"
+)
+
+func TestJSONSET(t *testing.T) {
+	/*
+		redis> JSON.SET doc $ '{"f1": {"a":1}, "f2":{"a":2}}'
+		OK
+		redis> JSON.SET doc $..a 3
+		OK
+		redis> JSON.GET doc
+		"{\"f1\":{\"a\":3},\"f2\":{\"a\":3}}"
+		redis>
+	*/
+
+	doc := []byte("doc")
+
+	s := newStore(t)
+	ok, err := s.JSONSET(doc, jsonPath(t, "$"), jsonValue(t, `{"f1": {"a":1}, "f2":{"a":2}}`), core.JSONSetOptions{})
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = s.JSONSET(doc, jsonPath(t, "$..a"), jsonValue(t, `3`), core.JSONSetOptions{})
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	res, err := s.JSONGET(doc, core.JSONFormat{})
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(res), `{"f1":{"a":3},"f2":{"a":3}}`)
+
+	ok, err = s.JSONSET(doc, jsonPath(t, "$.f1.b"), jsonValue(t, `[]`), core.JSONSetOptions{XX: true})
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	ok, err = s.JSONSET(doc, jsonPath(t, "$.f1.b"), jsonValue(t, `[]`), core.JSONSetOptions{NX: true})
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	res, err = s.JSONGET(doc, core.JSONFormat{}, jsonPath(t, "f1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(res), `{"a":3,"b":[]}`)
+
+	_, err = s.JSONSET([]byte("nokey"), jsonPath(t, "$.a"), jsonValue(t, `1`), core.JSONSetOptions{})
+	testt.MustEqual(t, err, core.ErrJSONNewAtRoot)
+
+	_, _, err = s.SET([]byte("str"), []byte("value"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	_, err = s.JSONSET([]byte("str"), jsonPath(t, "$"), jsonValue(t, `1`), core.JSONSetOptions{})
+	testt.MustEqual(t, err, core.ErrWrongType)
+
+	typ, err := s.TYPE(doc)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "ReJSON-RL")
+}
+
+func TestJSONDEL(t *testing.T) {
+	/*
+		redis> JSON.SET doc $ '{"a": 1, "nested": {"a": 2, "b": 3}}'
+		OK
+		redis> JSON.DEL doc $..a
+		(integer) 2
+		redis> JSON.GET doc $
+		"[{\"nested\":{\"b\":3}}]"
+		redis>
+	*/
+
+	doc := []byte("doc")
+
+	s := newStore(t)
+	_, err := s.JSONSET(doc, jsonPath(t, "$"), jsonValue(t, `{"a": 1, "nested": {"a": 2, "b": 3}}`), core.JSONSetOptions{})
+	testt.NoError(t, err)
+
+	n, err := s.JSONDEL(doc, jsonPath(t, "$..a"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err := s.JSONGET(doc, core.JSONFormat{}, jsonPath(t, "$"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(res), `[{"nested":{"b":3}}]`)
+
+	n, err = s.JSONDEL(doc, core.JSONRoot)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	_, err = s.JSONGET(doc, core.JSONFormat{})
+	testt.MustEqual(t, err, core.ErrKeyNotFound)
+}
+
+func TestJSONArrays(t *testing.T) {
+	/*
+		redis> JSON.SET item:1 $ '{"colors":["black","silver"]}'
+		OK
+		redis> JSON.ARRAPPEND item:1 $.colors '"blue"'
+		1) (integer) 3
+		redis> JSON.ARRINSERT item:1 $.colors 2 '"yellow"' '"gold"'
+		1) (integer) 5
+		redis> JSON.ARRPOP item:1 $.colors 0
+		1) "\"black\""
+		redis> JSON.GET item:1 $.colors
+		"[[\"silver\",\"yellow\",\"gold\",\"blue\"]]"
+		redis>
+	*/
+
+	item := []byte("item:1")
+	colors := jsonPath(t, "$.colors")
+
+	s := newStore(t)
+	_, err := s.JSONSET(item, jsonPath(t, "$"), jsonValue(t, `{"colors":["black","silver"]}`), core.JSONSetOptions{})
+	testt.NoError(t, err)
+
+	lens, err := s.JSONARRAPPEND(item, colors, jsonValue(t, `"blue"`))
+	testt.NoError(t, err)
+	testt.MustEqual(t, jsonLens(lens), []int64{3})
+
+	lens, err = s.JSONARRINSERT(item, colors, 2, jsonValue(t, `"yellow"`), jsonValue(t, `"gold"`))
+	testt.NoError(t, err)
+	testt.MustEqual(t, jsonLens(lens), []int64{5})
+
+	popped, err := s.JSONARRPOP(item, colors, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, popped, [][]byte{[]byte(`"black"`)})
+
+	res, err := s.JSONGET(item, core.JSONFormat{}, colors)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(res), `[["silver","yellow","gold","blue"]]`)
+
+	_, err = s.JSONARRINSERT(item, colors, 10, jsonValue(t, `1`))
+	testt.MustEqual(t, err, core.ErrJSONIndexOutOfBounds)
+
+	_, err = s.JSONARRAPPEND([]byte("nokey"), colors, jsonValue(t, `1`))
+	testt.MustEqual(t, err, core.ErrJSONNoKey)
+}
+
+func TestJSONMERGE(t *testing.T) {
+	/*
+		redis> JSON.SET doc $ '{"f1": {"a":1}, "f2":{"a":2}}'
+		OK
+		redis> JSON.MERGE doc $ '{"f1": null, "f2":{"a":3, "b":4}, "f3":[2,4,6]}'
+		OK
+		redis> JSON.GET doc
+		"{\"f2\":{\"a\":3,\"b\":4},\"f3\":[2,4,6]}"
+		redis>
+	*/
+
+	doc := []byte("doc")
+
+	s := newStore(t)
+	_, err := s.JSONSET(doc, jsonPath(t, "$"), jsonValue(t, `{"f1": {"a":1}, "f2":{"a":2}}`), core.JSONSetOptions{})
+	testt.NoError(t, err)
+
+	err = s.JSONMERGE(doc, jsonPath(t, "$"), jsonValue(t, `{"f1": null, "f2":{"a":3, "b":4}, "f3":[2,4,6]}`))
+	testt.NoError(t, err)
+
+	res, err := s.JSONGET(doc, core.JSONFormat{})
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(res), `{"f2":{"a":3,"b":4},"f3":[2,4,6]}`)
+}
+
+func TestJSONMGET(t *testing.T) {
+	/*
+		redis> JSON.SET doc1 $ '{"a":1, "b": 2, "nested": {"a": 3}, "c": null}'
+		OK
+		redis> JSON.SET doc2 $ '{"a":4, "b": 5, "nested": {"a": 6}, "c": null}'
+		OK
+		redis> JSON.MGET doc1 doc2 $..a
+		1) "[1,3]"
+		2) "[4,6]"
+		redis>
+	*/
+
+	s := newStore(t)
+	_, err := s.JSONSET([]byte("doc1"), jsonPath(t, "$"), jsonValue(t, `{"a":1, "b": 2, "nested": {"a": 3}, "c": null}`), core.JSONSetOptions{})
+	testt.NoError(t, err)
+	_, err = s.JSONSET([]byte("doc2"), jsonPath(t, "$"), jsonValue(t, `{"a":4, "b": 5, "nested": {"a": 6}, "c": null}`), core.JSONSetOptions{})
+	testt.NoError(t, err)
+	_, err = s.LPUSH([]byte("list"), []byte("a"))
+	testt.NoError(t, err)
+
+	res, err := s.JSONMGET(jsonPath(t, "$..a"), []byte("doc1"), []byte("doc2"), []byte("nokey"), []byte("list"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, [][]byte{[]byte("[1,3]"), []byte("[4,6]"), nil, nil})
+}
+
+func jsonPath(tb testing.TB, path string) core.JSONPath {
+	tb.Helper()
+	p, err := core.ParseJSONPath([]byte(path))
+	testt.NoError(tb, err)
+	return p
+}
+
+func jsonValue(tb testing.TB, text string) []byte {
+	tb.Helper()
+	val, err := core.ParseJSON([]byte(text))
+	testt.NoError(tb, err)
+	return val
+}
+
+func jsonLens(lens []*int64) []int64 {
+	res := make([]int64, len(lens))
+	for i, n := range lens {
+		if n != nil {
+			res[i] = *n
+		}
+	}
+	return res
+}
+
+func TestJSONRestart(t *testing.T) {
+	doc, dst := []byte("doc"), []byte("dst")
+	dir := t.TempDir()
+
+	s, err := Open(Config{Dir: dir})
+	testt.NoError(t, err)
+
+	_, err = s.JSONSET(doc, jsonPath(t, "$"), jsonValue(t, `{"a":[1,2],"b":{"c":"d"}}`), core.JSONSetOptions{})
+	testt.NoError(t, err)
+
+	err = s.Close()
+	testt.NoError(t, err)
+
+	s, err = Open(Config{Dir: dir})
+	testt.NoError(t, err)
+	defer s.Close()
+
+	lens, err := s.JSONARRAPPEND(doc, jsonPath(t, "$.a"), jsonValue(t, `3`))
+	testt.NoError(t, err)
+	testt.MustEqual(t, jsonLens(lens), []int64{3})
+
+	// copies don't share the document.
+	ok, err := s.COPY(doc, dst, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	n, err := s.JSONDEL(doc, jsonPath(t, "$.b"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	res, err := s.JSONGET(doc, core.JSONFormat{})
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(res), `{"a":[1,2,3]}`)
+
+	res, err = s.JSONGET(dst, core.JSONFormat{})
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(res), `{"a":[1,2,3],"b":{"c":"d"}}`)
+}
@@ -0,0 +1,364 @@
+package ondisk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Keys layout, user key is never written to pebble as is:
+//
+//	\x00format                                         => format version or legacy migration state
+//	\x00version                                        => last allocated collection version
+//	m + key                                            => meta record of the key
+//	d + len(key) + key + version + sub                 => collection member, string chunk or JSON document (sub is type specific)
+//	t + len(key) + key + version + sub                 => expire at of the member (hash fields only)
+//	s + len(key) + key + version + score + member      => nil, sorted set members ordered by score
+//	g + len(key) + key + version + sub                 => stream consumer groups, consumers and pending entries
+//	e + expire at + key                                => nil, expiry index used by sweeper
+//	f + expire at + len(key) + key + version + sub     => nil, member expiry index used by sweeper
+//	l + len(label) + label + len(value) + value + key  => nil, label index of time series
+//
+// Data keys embed key length, so keys that are prefixes of each other don't mix,
+// and key version, so members of deleted or overwritten collections are never visible.
+var (
+	formatKey      = []byte("\x00format")
+	versionKey     = []byte("\x00version")
+	metaPrefix     = []byte("m")
+	dataPrefix     = []byte("d")
+	ttlPrefix      = []byte("t")
+	scorePrefix    = []byte("s")
+	groupPrefix    = []byte("g")
+	expPrefix      = []byte("e")
+	fieldExpPrefix = []byte("f")
+	labelPrefix    = []byte("l")
+)
+
+// layoutPrefixes are prefixes of all records of keys, FLUSHDB clears them.
+var layoutPrefixes = [][]byte{
+	metaPrefix, dataPrefix, ttlPrefix, scorePrefix, groupPrefix, expPrefix, fieldExpPrefix, labelPrefix,
+}
+
+// formatVersion is the current version of the keys layout.
+// Version 0 is a legacy layout with raw string keys.
+const formatVersion = 1
+
+var errCorruptedMeta = errors.New("corrupted meta record")
+
+// meta is a record stored for every key.
+type meta struct {
+	typ core.KeyType
+	// version is unique per collection, it's a part of member keys.
+	version uint64
+	// expireAt is unix time in milliseconds when key expires, zero if never.
+	expireAt int64
+	// payload is a value for strings and a type specific header for collections.
+	payload []byte
+}
+
+const metaHeaderLen = 1 + 8 + 8
+
+func (m meta) encode() []byte {
+	res := make([]byte, 0, metaHeaderLen+len(m.payload))
+	res = append(res, byte(m.typ))
+	res = binary.BigEndian.AppendUint64(res, m.version)
+	res = binary.BigEndian.AppendUint64(res, uint64(m.expireAt))
+	return append(res, m.payload...)
+}
+
+func decodeMeta(b []byte) (meta, error) {
+	if len(b) < metaHeaderLen {
+		return meta{}, errCorruptedMeta
+	}
+	return meta{
+		typ:      core.KeyType(b[0]),
+		version:  binary.BigEndian.Uint64(b[1:]),
+		expireAt: int64(binary.BigEndian.Uint64(b[9:])),
+		// non-nil slice, so empty value can be told from a missing one.
+		payload: append([]byte{}, b[metaHeaderLen:]...),
+	}, nil
+}
+
+func (m meta) isExpired(now int64) bool {
+	return m.expireAt != 0 && m.expireAt <= now
+}
+
+// readMeta returns meta record of the key as is, even if it's expired.
+func readMeta(r pebble.Reader, key []byte) (meta, bool, error) {
+	val, closer, err := r.Get(metaKey(key))
+	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			return meta{}, false, nil
+		}
+		return meta{}, false, err
+	}
+	defer tryClose(closer)
+
+	m, err := decodeMeta(val)
+	if err != nil {
+		return meta{}, false, fmt.Errorf("key %q: %w", key, err)
+	}
+	return m, true, nil
+}
+
+// getMeta returns meta record of the key, expired key is reported as missing.
+func getMeta(r pebble.Reader, key []byte) (meta, bool, error) {
+	m, ok, err := readMeta(r, key)
+	if err != nil || !ok || m.isExpired(core.NowMs()) {
+		return meta{}, false, err
+	}
+	return m, true, nil
+}
+
+// loadMeta is like getMeta but also removes expired key in the batch.
+func loadMeta(b *pebble.Batch, key []byte) (meta, bool, error) {
+	m, ok, err := readMeta(b, key)
+	if err != nil || !ok {
+		return meta{}, false, err
+	}
+	if m.isExpired(core.NowMs()) {
+		return meta{}, false, delKey(b, key, m)
+	}
+	return m, true, nil
+}
+
+func putMeta(b *pebble.Batch, key []byte, m meta) error {
+	return b.Set(metaKey(key), m.encode(), nil)
+}
+
+// putKey writes the key and indexes its expiry, the key must not exist.
+func putKey(b *pebble.Batch, key []byte, m meta) error {
+	if m.expireAt != 0 {
+		if err := b.Set(expKey(m.expireAt, key), nil, nil); err != nil {
+			return err
+		}
+	}
+	return putMeta(b, key, m)
+}
+
+// copyKey writes a copy of the key src as dst, dst must not exist.
+// Collection members are copied under a new version.
+func (s *Store) copyKey(b *pebble.Batch, src, dst []byte, m meta) error {
+	if m.typ == core.TypeString && !isChunked(m) {
+		return putKey(b, dst, m)
+	}
+
+	version, err := s.nextVersion(b)
+	if err != nil {
+		return err
+	}
+	err = copyRange(b, dataKeyPrefix(src, m.version), dataKeyPrefix(dst, version), nil)
+	if err != nil {
+		return err
+	}
+	// member expiry is copied together with its index.
+	err = copyRange(b, ttlKeyPrefix(src, m.version), ttlKeyPrefix(dst, version), func(sub, val []byte) error {
+		return b.Set(fieldExpKey(decodeExpireAt(val), dst, version, sub), nil, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = copyRange(b, scoreKeyPrefix(src, m.version), scoreKeyPrefix(dst, version), nil)
+	if err != nil {
+		return err
+	}
+	err = copyRange(b, groupKeyPrefix(src, m.version), groupKeyPrefix(dst, version), nil)
+	if err != nil {
+		return err
+	}
+
+	if m.typ == core.TypeTimeSeries {
+		ts, err := core.DecodeTimeSeries(m.payload)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", src, err)
+		}
+		if err := putLabelIndex(b, dst, ts.Labels()); err != nil {
+			return err
+		}
+	}
+
+	m.version = version
+	return putKey(b, dst, m)
+}
+
+// copyRange copies all keys with srcPrefix under dstPrefix, fn is called for each copied key if set.
+func copyRange(b *pebble.Batch, srcPrefix, dstPrefix []byte, fn func(sub, val []byte) error) error {
+	iter, err := b.NewIter(&pebble.IterOptions{
+		LowerBound: srcPrefix,
+		UpperBound: prefixEnd(srcPrefix),
+	})
+	if err != nil {
+		return err
+	}
+	defer tryClose(iter)
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		sub := iter.Key()[len(srcPrefix):]
+		if err := b.Set(append(bytes.Clone(dstPrefix), sub...), iter.Value(), nil); err != nil {
+			return err
+		}
+		if fn == nil {
+			continue
+		}
+		if err := fn(sub, iter.Value()); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// nextVersion allocates a version for a new collection.
+// Must be called with s.mu held.
+func (s *Store) nextVersion(b *pebble.Batch) (uint64, error) {
+	s.version++
+	return s.version, b.Set(versionKey, binary.BigEndian.AppendUint64(nil, s.version), nil)
+}
+
+// loadVersion reads the last allocated collection version.
+func (s *Store) loadVersion() error {
+	val, closer, err := s.db.Get(versionKey)
+	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	defer tryClose(closer)
+
+	if len(val) != 8 {
+		return errors.New("corrupted collection version")
+	}
+	s.version = binary.BigEndian.Uint64(val)
+	return nil
+}
+
+// delKey removes the key with its members and expiry.
+func delKey(b *pebble.Batch, key []byte, m meta) error {
+	if err := b.Delete(metaKey(key), nil); err != nil {
+		return err
+	}
+	if m.expireAt != 0 {
+		if err := b.Delete(expKey(m.expireAt, key), nil); err != nil {
+			return err
+		}
+	}
+	if m.typ == core.TypeTimeSeries {
+		if err := delLabelIndex(b, key, m); err != nil {
+			return err
+		}
+	}
+	if m.typ != core.TypeString || isChunked(m) {
+		// member expiry index is cleaned up by sweeper.
+		prefixes := [][]byte{
+			dataKeyPrefix(key, m.version),
+			ttlKeyPrefix(key, m.version),
+			scoreKeyPrefix(key, m.version),
+			groupKeyPrefix(key, m.version),
+		}
+		for _, prefix := range prefixes {
+			if err := b.DeleteRange(prefix, prefixEnd(prefix), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setExpire changes expiry of the key to at, zero at removes expiry.
+func setExpire(b *pebble.Batch, key []byte, m *meta, at int64) error {
+	if m.expireAt == at {
+		return nil
+	}
+	if m.expireAt != 0 {
+		if err := b.Delete(expKey(m.expireAt, key), nil); err != nil {
+			return err
+		}
+	}
+	if at != 0 {
+		if err := b.Set(expKey(at, key), nil, nil); err != nil {
+			return err
+		}
+	}
+	m.expireAt = at
+	return putMeta(b, key, *m)
+}
+
+func metaKey(key []byte) []byte {
+	res := make([]byte, 0, len(metaPrefix)+len(key))
+	res = append(res, metaPrefix...)
+	return append(res, key...)
+}
+
+func dataKeyPrefix(key []byte, version uint64) []byte {
+	return memberKeyPrefix(dataPrefix, key, version)
+}
+
+func ttlKeyPrefix(key []byte, version uint64) []byte {
+	return memberKeyPrefix(ttlPrefix, key, version)
+}
+
+func scoreKeyPrefix(key []byte, version uint64) []byte {
+	return memberKeyPrefix(scorePrefix, key, version)
+}
+
+func groupKeyPrefix(key []byte, version uint64) []byte {
+	return memberKeyPrefix(groupPrefix, key, version)
+}
+
+func memberKeyPrefix(prefix, key []byte, version uint64) []byte {
+	res := make([]byte, 0, len(prefix)+4+len(key)+8)
+	res = append(res, prefix...)
+	res = binary.BigEndian.AppendUint32(res, uint32(len(key)))
+	res = append(res, key...)
+	return binary.BigEndian.AppendUint64(res, version)
+}
+
+func expKey(at int64, key []byte) []byte {
+	res := make([]byte, 0, len(expPrefix)+8+len(key))
+	res = append(res, expPrefix...)
+	res = binary.BigEndian.AppendUint64(res, uint64(at))
+	return append(res, key...)
+}
+
+func fieldExpKey(at int64, key []byte, version uint64, sub []byte) []byte {
+	res := binary.BigEndian.AppendUint64(bytes.Clone(fieldExpPrefix), uint64(at))
+	res = append(res, memberKeyPrefix(nil, key, version)...)
+	return append(res, sub...)
+}
+
+// decodeFieldExpKey is the reverse of fieldExpKey.
+func decodeFieldExpKey(k []byte) (at int64, key []byte, version uint64, sub []byte, err error) {
+	k = k[len(fieldExpPrefix):]
+	if len(k) < 8+4 {
+		return 0, nil, 0, nil, errors.New("corrupted member expiry index")
+	}
+	at = int64(binary.BigEndian.Uint64(k))
+	n := int(binary.BigEndian.Uint32(k[8:]))
+	k = k[8+4:]
+	if len(k) < n+8 {
+		return 0, nil, 0, nil, errors.New("corrupted member expiry index")
+	}
+	return at, k[:n], binary.BigEndian.Uint64(k[n:]), k[n+8:], nil
+}
+
+func decodeExpireAt(val []byte) int64 {
+	return int64(binary.BigEndian.Uint64(val))
+}
+
+// prefixEnd returns the smallest key greater than all keys with the given prefix.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package ondisk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cristalhq/testt"
+)
+
+func TestMigrateLegacy(t *testing.T) {
+	dir := t.TempDir()
+
+	// legacy layout: raw keys and expiry records.
+	db, err := pebble.Open(dir, &pebble.Options{})
+	testt.NoError(t, err)
+
+	at := core.NowMs() + 100_000
+	expired := core.NowMs() - 1
+	legacy := map[string][]byte{
+		"key1":                  []byte("Hello"),
+		"key2":                  []byte("World"),
+		"key3":                  []byte("Gone"),
+		"\x00didis:ttl:key2":    binary.BigEndian.AppendUint64(nil, uint64(at)),
+		"\x00didis:ttl:key3":    binary.BigEndian.AppendUint64(nil, uint64(expired)),
+		"\x00didis:exp:garbage": nil,
+		"\x00didis:user":        []byte("kept"),
+	}
+	for k, v := range legacy {
+		err := db.Set([]byte(k), v, pebble.Sync)
+		testt.NoError(t, err)
+	}
+	err = db.Close()
+	testt.NoError(t, err)
+
+	s, err := Open(Config{Dir: dir})
+	testt.NoError(t, err)
+	defer s.Close()
+
+	keys, err := s.KEYS([]byte("*"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(keys), 3)
+
+	val, err := s.GET([]byte("key1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "Hello")
+
+	typ, err := s.TYPE([]byte("key1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "string")
+
+	ttl, err := s.PEXPIRETIME([]byte("key2"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, at)
+
+	_, err = s.GET([]byte("key3"))
+	testt.WantError(t, err)
+
+	// only expiry records were internal in the legacy layout.
+	val, err = s.GET([]byte("\x00didis:user"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "kept")
+}
+
+func TestMigrateLegacyBatches(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := pebble.Open(dir, &pebble.Options{})
+	testt.NoError(t, err)
+
+	// legacy keys that look like records of the new layout must not be overwritten.
+	n := 2*migrateBatchSize + 500
+	b := db.NewBatch()
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		err := b.Set([]byte(key), []byte(key), nil)
+		testt.NoError(t, err)
+		err = b.Set(append([]byte("m"), key...), []byte("m"+key), nil)
+		testt.NoError(t, err)
+	}
+	err = b.Commit(pebble.Sync)
+	testt.NoError(t, err)
+
+	// interrupted migration continues on the next open.
+	s := &Store{db: db}
+	st := legacyMigration{}
+	st.staging, err = legacyStaging(db)
+	testt.NoError(t, err)
+	err = s.stageLegacy(&st)
+	testt.NoError(t, err)
+	testt.MustEqual(t, st.cleared, false)
+	err = db.Close()
+	testt.NoError(t, err)
+
+	s, err = Open(Config{Dir: dir})
+	testt.NoError(t, err)
+	defer s.Close()
+
+	keys, err := s.KEYS([]byte("*"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(keys), 2*n)
+
+	for i := 0; i < n; i += 97 {
+		key := fmt.Sprintf("key:%d", i)
+		val, err := s.GET([]byte(key))
+		testt.NoError(t, err)
+		testt.MustEqual(t, string(val), key)
+
+		val, err = s.GET([]byte("m" + key))
+		testt.NoError(t, err)
+		testt.MustEqual(t, string(val), "m"+key)
+	}
+
+	// staged records are gone.
+	iter, err := s.db.NewIter(&pebble.IterOptions{LowerBound: []byte{0xff}})
+	testt.NoError(t, err)
+	testt.MustEqual(t, iter.First(), false)
+	err = iter.Close()
+	testt.NoError(t, err)
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Open(Config{Dir: dir})
+	testt.NoError(t, err)
+	err = s.db.Set(formatKey, binary.BigEndian.AppendUint64(nil, formatVersion+1), pebble.Sync)
+	testt.NoError(t, err)
+	err = s.Close()
+	testt.NoError(t, err)
+
+	_, err = Open(Config{Dir: dir})
+	testt.WantError(t, err)
+}
+
+func TestReadOnlyFormat(t *testing.T) {
+	dir := t.TempDir()
+
+	// legacy layout is not migrated in read-only mode.
+	db, err := pebble.Open(dir, &pebble.Options{})
+	testt.NoError(t, err)
+	err = db.Set([]byte("key1"), []byte("Hello"), pebble.Sync)
+	testt.NoError(t, err)
+	err = db.Close()
+	testt.NoError(t, err)
+
+	_, err = Open(Config{Dir: dir, ReadOnly: true})
+	testt.MustEqual(t, errors.Is(err, errMigrationRequired), true)
+
+	// nor is an interrupted migration.
+	db, err = pebble.Open(dir, &pebble.Options{})
+	testt.NoError(t, err)
+	st := legacyMigration{}
+	st.staging, err = legacyStaging(db)
+	testt.NoError(t, err)
+	err = (&Store{db: db}).stageLegacy(&st)
+	testt.NoError(t, err)
+	err = db.Close()
+	testt.NoError(t, err)
+
+	_, err = Open(Config{Dir: dir, ReadOnly: true})
+	testt.MustEqual(t, errors.Is(err, errMigrationRequired), true)
+
+	// after the migration the store opens.
+	s, err := Open(Config{Dir: dir})
+	testt.NoError(t, err)
+	err = s.Close()
+	testt.NoError(t, err)
+
+	s, err = Open(Config{Dir: dir, ReadOnly: true})
+	testt.NoError(t, err)
+	val, err := s.GET([]byte("key1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "Hello")
+	err = s.Close()
+	testt.NoError(t, err)
+
+	// unsupported format version fails too.
+	db, err = pebble.Open(dir, &pebble.Options{})
+	testt.NoError(t, err)
+	err = db.Set(formatKey, binary.BigEndian.AppendUint64(nil, formatVersion+1), pebble.Sync)
+	testt.NoError(t, err)
+	err = db.Close()
+	testt.NoError(t, err)
+
+	_, err = Open(Config{Dir: dir, ReadOnly: true})
+	testt.WantError(t, err)
+}
+
+func TestKeysDoNotMix(t *testing.T) {
+	s := newStore(t)
+
+	// user keys that look like internal records.
+	keys := [][]byte{[]byte("\x00format"), []byte("m"), []byte("e"), []byte("")}
+	for _, key := range keys {
+		_, _, err := s.SET(key, []byte("value"), core.SetOptions{})
+		testt.NoError(t, err)
+	}
+
+	res, err := s.KEYS([]byte("*"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), len(keys))
+
+	n, err := s.DEL(keys...)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, len(keys))
+
+	err = s.Close()
+	testt.NoError(t, err)
+}
@@ -0,0 +1,543 @@
+package ondisk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Lists operations https://redis.io/commands/?group=list
+
+// List elements are stored as d + len(key) + key + version + seq => element,
+// elements occupy sequence numbers [head, tail), so pushes and pops on both ends are O(1).
+type listMeta struct {
+	head uint64
+	tail uint64
+}
+
+// listMidSeq is the first sequence number of a new list, so it can grow in both directions.
+const listMidSeq = 1 << 63
+
+func decodeListMeta(m meta) (listMeta, error) {
+	if len(m.payload) != 16 {
+		return listMeta{}, errCorruptedMeta
+	}
+	return listMeta{
+		head: binary.BigEndian.Uint64(m.payload),
+		tail: binary.BigEndian.Uint64(m.payload[8:]),
+	}, nil
+}
+
+func (l listMeta) encode() []byte {
+	res := binary.BigEndian.AppendUint64(nil, l.head)
+	return binary.BigEndian.AppendUint64(res, l.tail)
+}
+
+func (l listMeta) len() int {
+	return int(l.tail - l.head)
+}
+
+func (s *Store) LINDEX(key []byte, index int) ([]byte, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, l, ok, err := getList(snap, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+
+	i, ok := core.ListIndex(index, l.len())
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	return getElem(snap, key, m, l.head+uint64(i))
+}
+
+func (s *Store) LINSERT(key []byte, side core.ListSide, pivot, element []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, l, ok, err := loadList(b, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	elems, err := listElems(b, key, m, l.head, l.tail)
+	if err != nil {
+		return 0, err
+	}
+	pos := -1
+	for i, elem := range elems {
+		if bytes.Equal(elem, pivot) {
+			pos = i
+			break
+		}
+	}
+	if pos == -1 {
+		return -1, nil
+	}
+	if side == core.ListRight {
+		pos++
+	}
+
+	// elements after the pivot are shifted to the right.
+	for i := pos; i < len(elems); i++ {
+		if err := b.Set(elemKey(key, m, l.head+uint64(i)+1), elems[i], nil); err != nil {
+			return 0, err
+		}
+	}
+	if err := b.Set(elemKey(key, m, l.head+uint64(pos)), element, nil); err != nil {
+		return 0, err
+	}
+	l.tail++
+
+	if err := putList(b, key, m, l); err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return l.len(), nil
+}
+
+func (s *Store) LLEN(key []byte) (int, error) {
+	_, l, _, err := getList(s.db, key)
+	if err != nil {
+		return 0, err
+	}
+	return l.len(), nil
+}
+
+func (s *Store) LMOVE(src, dst []byte, from, to core.ListSide) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, l, ok, err := loadList(b, src)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	// destination is checked first, so nothing is popped on error.
+	if _, _, _, err := loadList(b, dst); err != nil {
+		return nil, err
+	}
+
+	elems, err := popElems(b, src, m, &l, from, 1)
+	if err != nil {
+		return nil, err
+	}
+	if err := putList(b, src, m, l); err != nil {
+		return nil, err
+	}
+	// destination is loaded again, it might be the same list.
+	if _, err := s.pushElems(b, dst, to, false, elems); err != nil {
+		return nil, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return nil, err
+	}
+	return elems[0], nil
+}
+
+func (s *Store) LPOP(key []byte, count int) ([][]byte, error) {
+	return s.popGeneric(key, core.ListLeft, count)
+}
+
+func (s *Store) LPOS(key, element []byte, opts core.LPosOptions) ([]int, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, l, ok, err := getList(snap, key)
+	if err != nil || !ok {
+		return []int{}, err
+	}
+
+	prefix := dataKeyPrefix(key, m.version)
+	iter, err := snap.NewIter(&pebble.IterOptions{
+		LowerBound: elemKey(key, m, l.head),
+		UpperBound: elemKey(key, m, l.tail),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tryClose(iter)
+
+	rank, valid, next := opts.Rank, iter.First, iter.Next
+	if rank < 0 {
+		rank, valid, next = -rank, iter.Last, iter.Prev
+	}
+	rank = max(rank, 1)
+
+	res := []int{}
+	checked := 0
+	for ok := valid(); ok; ok = next() {
+		if opts.MaxLen != 0 && checked == opts.MaxLen {
+			break
+		}
+		checked++
+
+		if !bytes.Equal(iter.Value(), element) {
+			continue
+		}
+		if rank--; rank > 0 {
+			continue
+		}
+		seq := binary.BigEndian.Uint64(iter.Key()[len(prefix):])
+		res = append(res, int(seq-l.head))
+		if len(res) == opts.Count {
+			break
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Store) LPUSH(key []byte, elements ...[]byte) (int, error) {
+	return s.pushGeneric(key, core.ListLeft, false, elements)
+}
+
+func (s *Store) LPUSHX(key []byte, elements ...[]byte) (int, error) {
+	return s.pushGeneric(key, core.ListLeft, true, elements)
+}
+
+func (s *Store) LRANGE(key []byte, start, stop int) ([][]byte, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, l, ok, err := getList(snap, key)
+	if err != nil || !ok {
+		return [][]byte{}, err
+	}
+
+	from, to := core.ListRange(start, stop, l.len())
+	return listElems(snap, key, m, l.head+uint64(from), l.head+uint64(to))
+}
+
+func (s *Store) LREM(key []byte, count int, element []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, l, ok, err := loadList(b, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	elems, err := listElems(b, key, m, l.head, l.tail)
+	if err != nil {
+		return 0, err
+	}
+
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	remove := make([]bool, len(elems))
+	removed := 0
+	for i := range elems {
+		j := i
+		if count < 0 {
+			j = len(elems) - 1 - i
+		}
+		if limit != 0 && removed == limit {
+			break
+		}
+		if bytes.Equal(elems[j], element) {
+			remove[j] = true
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	// remaining elements are compacted towards the head.
+	seq := l.head
+	for i, elem := range elems {
+		if remove[i] {
+			continue
+		}
+		if err := b.Set(elemKey(key, m, seq), elem, nil); err != nil {
+			return 0, err
+		}
+		seq++
+	}
+	if err := b.DeleteRange(elemKey(key, m, seq), elemKey(key, m, l.tail), nil); err != nil {
+		return 0, err
+	}
+	l.tail = seq
+
+	if err := putList(b, key, m, l); err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+func (s *Store) LSET(key []byte, index int, element []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, l, ok, err := loadList(b, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return core.ErrKeyNotFound
+	}
+
+	i, ok := core.ListIndex(index, l.len())
+	if !ok {
+		return core.ErrIndexOutOfRange
+	}
+	if err := b.Set(elemKey(key, m, l.head+uint64(i)), element, nil); err != nil {
+		return err
+	}
+	return b.Commit(s.syncOpt)
+}
+
+func (s *Store) LTRIM(key []byte, start, stop int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, l, ok, err := loadList(b, key)
+	if err != nil || !ok {
+		return err
+	}
+
+	from, to := core.ListRange(start, stop, l.len())
+	head, tail := l.head+uint64(from), l.head+uint64(to)
+	if from == to {
+		head, tail = l.head, l.head
+	}
+	if err := b.DeleteRange(elemKey(key, m, l.head), elemKey(key, m, head), nil); err != nil {
+		return err
+	}
+	if err := b.DeleteRange(elemKey(key, m, tail), elemKey(key, m, l.tail), nil); err != nil {
+		return err
+	}
+	l.head, l.tail = head, tail
+
+	if err := putList(b, key, m, l); err != nil {
+		return err
+	}
+	return b.Commit(s.syncOpt)
+}
+
+func (s *Store) RPOP(key []byte, count int) ([][]byte, error) {
+	return s.popGeneric(key, core.ListRight, count)
+}
+
+func (s *Store) RPUSH(key []byte, elements ...[]byte) (int, error) {
+	return s.pushGeneric(key, core.ListRight, false, elements)
+}
+
+func (s *Store) RPUSHX(key []byte, elements ...[]byte) (int, error) {
+	return s.pushGeneric(key, core.ListRight, true, elements)
+}
+
+func (s *Store) pushGeneric(key []byte, side core.ListSide, onlyExisting bool, elements [][]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	n, err := s.pushElems(b, key, side, onlyExisting, elements)
+	if err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *Store) popGeneric(key []byte, side core.ListSide, count int) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, l, ok, err := loadList(b, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+
+	elems, err := popElems(b, key, m, &l, side, count)
+	if err != nil {
+		return nil, err
+	}
+	if err := putList(b, key, m, l); err != nil {
+		return nil, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return nil, err
+	}
+	return elems, nil
+}
+
+// pushElems adds elements to the list, creating it if needed, returns the list length.
+func (s *Store) pushElems(b *pebble.Batch, key []byte, side core.ListSide, onlyExisting bool, elements [][]byte) (int, error) {
+	m, l, ok, err := loadList(b, key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		if onlyExisting {
+			return 0, nil
+		}
+		version, err := s.nextVersion(b)
+		if err != nil {
+			return 0, err
+		}
+		m = meta{typ: core.TypeList, version: version}
+		l = listMeta{head: listMidSeq, tail: listMidSeq}
+	}
+
+	for _, elem := range elements {
+		seq := l.tail
+		if side == core.ListLeft {
+			l.head--
+			seq = l.head
+		} else {
+			l.tail++
+		}
+		if err := b.Set(elemKey(key, m, seq), elem, nil); err != nil {
+			return 0, err
+		}
+	}
+	if err := putList(b, key, m, l); err != nil {
+		return 0, err
+	}
+	return l.len(), nil
+}
+
+// popElems removes up to count elements from the list, l is updated accordingly.
+func popElems(b *pebble.Batch, key []byte, m meta, l *listMeta, side core.ListSide, count int) ([][]byte, error) {
+	n := min(count, l.len())
+	from, to := l.head, l.head+uint64(n)
+	if side == core.ListRight {
+		from, to = l.tail-uint64(n), l.tail
+	}
+
+	elems, err := listElems(b, key, m, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.DeleteRange(elemKey(key, m, from), elemKey(key, m, to), nil); err != nil {
+		return nil, err
+	}
+
+	if side == core.ListLeft {
+		l.head = to
+	} else {
+		l.tail = from
+		// elements are returned in pop order.
+		for i, j := 0, len(elems)-1; i < j; i, j = i+1, j-1 {
+			elems[i], elems[j] = elems[j], elems[i]
+		}
+	}
+	return elems, nil
+}
+
+// getList is like getMeta but fails for keys that are not lists.
+func getList(r pebble.Reader, key []byte) (meta, listMeta, bool, error) {
+	m, ok, err := getMeta(r, key)
+	return asList(key, m, ok, err)
+}
+
+// loadList is like loadMeta but fails for keys that are not lists.
+func loadList(b *pebble.Batch, key []byte) (meta, listMeta, bool, error) {
+	m, ok, err := loadMeta(b, key)
+	return asList(key, m, ok, err)
+}
+
+func asList(key []byte, m meta, ok bool, err error) (meta, listMeta, bool, error) {
+	if err != nil || !ok {
+		return meta{}, listMeta{}, false, err
+	}
+	if m.typ != core.TypeList {
+		return meta{}, listMeta{}, false, core.ErrWrongType
+	}
+	l, err := decodeListMeta(m)
+	if err != nil {
+		return meta{}, listMeta{}, false, fmt.Errorf("key %q: %w", key, err)
+	}
+	return m, l, true, nil
+}
+
+// putList writes list meta, empty list is removed.
+func putList(b *pebble.Batch, key []byte, m meta, l listMeta) error {
+	if l.len() == 0 {
+		return delKey(b, key, m)
+	}
+	m.payload = l.encode()
+	return putMeta(b, key, m)
+}
+
+// listElems returns elements with sequence numbers in [from, to).
+func listElems(r pebble.Reader, key []byte, m meta, from, to uint64) ([][]byte, error) {
+	iter, err := r.NewIter(&pebble.IterOptions{
+		LowerBound: elemKey(key, m, from),
+		UpperBound: elemKey(key, m, to),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tryClose(iter)
+
+	res := make([][]byte, 0, to-from)
+	for iter.First(); iter.Valid(); iter.Next() {
+		res = append(res, bytes.Clone(iter.Value()))
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func getElem(r pebble.Reader, key []byte, m meta, seq uint64) ([]byte, error) {
+	val, closer, err := r.Get(elemKey(key, m, seq))
+	if err != nil {
+		return nil, err
+	}
+	defer tryClose(closer)
+
+	return bytes.Clone(val), nil
+}
+
+func elemKey(key []byte, m meta, seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(dataKeyPrefix(key, m.version), seq)
+}
@@ -0,0 +1,705 @@
+package ondisk
+
+import (
+	"testing"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cristalhq/testt"
+)
+
+func TestLINDEX(t *testing.T) {
+	/*
+		redis> LPUSH mylist "World"
+		(integer) 1
+		redis> LPUSH mylist "Hello"
+		(integer) 2
+		redis> LINDEX mylist 0
+		"Hello"
+		redis> LINDEX mylist -1
+		"World"
+		redis> LINDEX mylist 3
+		(nil)
+		redis>
+	*/
+
+	mylist := []byte("mylist")
+
+	s := newStore(t)
+	n, err := s.LPUSH(mylist, []byte("World"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.LPUSH(mylist, []byte("Hello"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	val, err := s.LINDEX(mylist, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "Hello")
+
+	val, err = s.LINDEX(mylist, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "World")
+
+	_, err = s.LINDEX(mylist, 3)
+	testt.MustEqual(t, err, error(core.ErrKeyNotFound))
+}
+
+func TestLINSERT(t *testing.T) {
+	/*
+		redis> RPUSH mylist "Hello"
+		(integer) 1
+		redis> RPUSH mylist "World"
+		(integer) 2
+		redis> LINSERT mylist BEFORE "World" "There"
+		(integer) 3
+		redis> LRANGE mylist 0 -1
+		1) "Hello"
+		2) "There"
+		3) "World"
+		redis>
+	*/
+
+	mylist := []byte("mylist")
+
+	s := newStore(t)
+	_, err := s.RPUSH(mylist, []byte("Hello"), []byte("World"))
+	testt.NoError(t, err)
+
+	n, err := s.LINSERT(mylist, core.ListLeft, []byte("World"), []byte("There"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 3)
+
+	n, err = s.LINSERT(mylist, core.ListRight, []byte("World"), []byte("!"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 4)
+
+	n, err = s.LINSERT(mylist, core.ListRight, []byte("nonexisting"), []byte("!"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, -1)
+
+	n, err = s.LINSERT([]byte("nolist"), core.ListRight, []byte("World"), []byte("!"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	vals, err := s.LRANGE(mylist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("Hello"), []byte("There"), []byte("World"), []byte("!")})
+}
+
+func TestLLEN(t *testing.T) {
+	/*
+		redis> LPUSH mylist "World"
+		(integer) 1
+		redis> LPUSH mylist "Hello"
+		(integer) 2
+		redis> LLEN mylist
+		(integer) 2
+		redis>
+	*/
+
+	mylist := []byte("mylist")
+
+	s := newStore(t)
+	_, err := s.LPUSH(mylist, []byte("World"))
+	testt.NoError(t, err)
+	_, err = s.LPUSH(mylist, []byte("Hello"))
+	testt.NoError(t, err)
+
+	n, err := s.LLEN(mylist)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	n, err = s.LLEN([]byte("nonexisting"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+}
+
+func TestLMOVE(t *testing.T) {
+	/*
+		redis> RPUSH mylist "one"
+		(integer) 1
+		redis> RPUSH mylist "two"
+		(integer) 2
+		redis> RPUSH mylist "three"
+		(integer) 3
+		redis> LMOVE mylist myotherlist RIGHT LEFT
+		"three"
+		redis> LMOVE mylist myotherlist LEFT RIGHT
+		"one"
+		redis> LRANGE mylist 0 -1
+		1) "two"
+		redis> LRANGE myotherlist 0 -1
+		1) "three"
+		2) "one"
+		redis>
+	*/
+
+	mylist := []byte("mylist")
+	myotherlist := []byte("myotherlist")
+
+	s := newStore(t)
+	_, err := s.RPUSH(mylist, []byte("one"), []byte("two"), []byte("three"))
+	testt.NoError(t, err)
+
+	val, err := s.LMOVE(mylist, myotherlist, core.ListRight, core.ListLeft)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "three")
+
+	val, err = s.LMOVE(mylist, myotherlist, core.ListLeft, core.ListRight)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "one")
+
+	vals, err := s.LRANGE(mylist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("two")})
+
+	vals, err = s.LRANGE(myotherlist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("three"), []byte("one")})
+
+	// rotation of the same list.
+	val, err = s.LMOVE(myotherlist, myotherlist, core.ListLeft, core.ListRight)
+	testt.NoError(t, err)
+	testt.MustEqual(t, string(val), "three")
+
+	vals, err = s.LRANGE(myotherlist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("one"), []byte("three")})
+
+	_, err = s.LMOVE([]byte("nonexisting"), myotherlist, core.ListLeft, core.ListRight)
+	testt.MustEqual(t, err, error(core.ErrKeyNotFound))
+}
+
+func TestLPOP(t *testing.T) {
+	/*
+		redis> RPUSH mylist "one" "two" "three" "four" "five"
+		(integer) 5
+		redis> LPOP mylist
+		"one"
+		redis> LPOP mylist 2
+		1) "two"
+		2) "three"
+		redis> LRANGE mylist 0 -1
+		1) "four"
+		2) "five"
+		redis>
+	*/
+
+	mylist := []byte("mylist")
+
+	s := newStore(t)
+	_, err := s.RPUSH(mylist, []byte("one"), []byte("two"), []byte("three"), []byte("four"), []byte("five"))
+	testt.NoError(t, err)
+
+	vals, err := s.LPOP(mylist, 1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("one")})
+
+	vals, err = s.LPOP(mylist, 2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("two"), []byte("three")})
+
+	vals, err = s.LRANGE(mylist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("four"), []byte("five")})
+
+	vals, err = s.LPOP(mylist, 10)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("four"), []byte("five")})
+
+	n, err := s.EXISTS(mylist)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	_, err = s.LPOP(mylist, 1)
+	testt.MustEqual(t, err, error(core.ErrKeyNotFound))
+}
+
+func TestLPOS(t *testing.T) {
+	/*
+		redis> RPUSH mylist a b c d 1 2 3 4 3 3 3
+		(integer) 11
+		redis> LPOS mylist 3
+		(integer) 6
+		redis> LPOS mylist 3 COUNT 0 RANK 2
+		1) (integer) 8
+		2) (integer) 9
+		3) (integer) 10
+		redis>
+	*/
+
+	mylist := []byte("mylist")
+
+	s := newStore(t)
+	_, err := s.RPUSH(mylist,
+		[]byte("a"), []byte("b"), []byte("c"), []byte("d"),
+		[]byte("1"), []byte("2"), []byte("3"), []byte("4"),
+		[]byte("3"), []byte("3"), []byte("3"),
+	)
+	testt.NoError(t, err)
+
+	pos, err := s.LPOS(mylist, []byte("3"), core.LPosOptions{Rank: 1, Count: 1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, []int{6})
+
+	pos, err = s.LPOS(mylist, []byte("3"), core.LPosOptions{Rank: 2})
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, []int{8, 9, 10})
+
+	pos, err = s.LPOS(mylist, []byte("3"), core.LPosOptions{Rank: -1, Count: 2})
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, []int{10, 9})
+
+	pos, err = s.LPOS(mylist, []byte("3"), core.LPosOptions{Rank: 1, MaxLen: 7})
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, []int{6})
+
+	pos, err = s.LPOS(mylist, []byte("x"), core.LPosOptions{Rank: 1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, []int{})
+}
+
+func TestLPUSH(t *testing.T) {
+	/*
+		redis> LPUSH mylist "world"
+		(integer) 1
+		redis> LPUSH mylist "hello"
+		(integer) 2
+		redis> LRANGE mylist 0 -1
+		1) "hello"
+		2) "world"
+		redis>
+	*/
+
+	mylist := []byte("mylist")
+
+	s := newStore(t)
+	n, err := s.LPUSH(mylist, []byte("world"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.LPUSH(mylist, []byte("hello"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	vals, err := s.LRANGE(mylist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("hello"), []byte("world")})
+
+	n, err = s.LPUSH(mylist, []byte("a"), []byte("b"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 4)
+
+	vals, err = s.LRANGE(mylist, 0, 1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("b"), []byte("a")})
+}
+
+func TestLPUSHX(t *testing.T) {
+	/*
+		redis> LPUSH mylist "World"
+		(integer) 1
+		redis> LPUSHX mylist "Hello"
+		(integer) 2
+		redis> LPUSHX myotherlist "Hello"
+		(integer) 0
+		redis> LRANGE mylist 0 -1
+		1) "Hello"
+		2) "World"
+		redis> LRANGE myotherlist 0 -1
+		(empty array)
+		redis>
+	*/
+
+	mylist := []byte("mylist")
+	myotherlist := []byte("myotherlist")
+
+	s := newStore(t)
+	_, err := s.LPUSH(mylist, []byte("World"))
+	testt.NoError(t, err)
+
+	n, err := s.LPUSHX(mylist, []byte("Hello"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	n, err = s.LPUSHX(myotherlist, []byte("Hello"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	vals, err := s.LRANGE(mylist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("Hello"), []byte("World")})
+
+	vals, err = s.LRANGE(myotherlist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{})
+}
+
+func TestLRANGE(t *testing.T) {
+	/*
+		redis> RPUSH mylist "one"
+		(integer) 1
+		redis> RPUSH mylist "two"
+		(integer) 2
+		redis> RPUSH mylist "three"
+		(integer) 3
+		redis> LRANGE mylist 0 0
+		1) "one"
+		redis> LRANGE mylist -3 2
+		1) "one"
+		2) "two"
+		3) "three"
+		redis> LRANGE mylist -100 100
+		1) "one"
+		2) "two"
+		3) "three"
+		redis> LRANGE mylist 5 10
+		(empty array)
+		redis>
+	*/
+
+	mylist := []byte("mylist")
+
+	s := newStore(t)
+	_, err := s.RPUSH(mylist, []byte("one"), []byte("two"), []byte("three"))
+	testt.NoError(t, err)
+
+	vals, err := s.LRANGE(mylist, 0, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("one")})
+
+	vals, err = s.LRANGE(mylist, -3, 2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("one"), []byte("two"), []byte("three")})
+
+	vals, err = s.LRANGE(mylist, -100, 100)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("one"), []byte("two"), []byte("three")})
+
+	vals, err = s.LRANGE(mylist, 5, 10)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{})
+}
+
+func TestLREM(t *testing.T) {
+	/*
+		redis> RPUSH mylist "hello"
+		(integer) 1
+		redis> RPUSH mylist "hello"
+		(integer) 2
+		redis> RPUSH mylist "foo"
+		(integer) 3
+		redis> RPUSH mylist "hello"
+		(integer) 4
+		redis> LREM mylist -2 "hello"
+		(integer) 2
+		redis> LRANGE mylist 0 -1
+		1) "hello"
+		2) "foo"
+		redis>
+	*/
+
+	mylist := []byte("mylist")
+
+	s := newStore(t)
+	_, err := s.RPUSH(mylist, []byte("hello"), []byte("hello"), []byte("foo"), []byte("hello"))
+	testt.NoError(t, err)
+
+	n, err := s.LREM(mylist, -2, []byte("hello"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	vals, err := s.LRANGE(mylist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("hello"), []byte("foo")})
+
+	n, err = s.LREM(mylist, 0, []byte("foo"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.LREM(mylist, 1, []byte("hello"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.EXISTS(mylist)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+}
+
+func TestLSET(t *testing.T) {
+	/*
+		redis> RPUSH mylist "one"
+		(integer) 1
+		redis> RPUSH mylist "two"
+		(integer) 2
+		redis> RPUSH mylist "three"
+		(integer) 3
+		redis> LSET mylist 0 "four"
+		"OK"
+		redis> LSET mylist -2 "five"
+		"OK"
+		redis> LRANGE mylist 0 -1
+		1) "four"
+		2) "five"
+		3) "three"
+		redis>
+	*/
+
+	mylist := []byte("mylist")
+
+	s := newStore(t)
+	_, err := s.RPUSH(mylist, []byte("one"), []byte("two"), []byte("three"))
+	testt.NoError(t, err)
+
+	err = s.LSET(mylist, 0, []byte("four"))
+	testt.NoError(t, err)
+
+	err = s.LSET(mylist, -2, []byte("five"))
+	testt.NoError(t, err)
+
+	vals, err := s.LRANGE(mylist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("four"), []byte("five"), []byte("three")})
+
+	err = s.LSET(mylist, 3, []byte("six"))
+	testt.MustEqual(t, err, error(core.ErrIndexOutOfRange))
+
+	err = s.LSET([]byte("nonexisting"), 0, []byte("six"))
+	testt.MustEqual(t, err, error(core.ErrKeyNotFound))
+}
+
+func TestLTRIM(t *testing.T) {
+	/*
+		redis> RPUSH mylist "one"
+		(integer) 1
+		redis> RPUSH mylist "two"
+		(integer) 2
+		redis> RPUSH mylist "three"
+		(integer) 3
+		redis> LTRIM mylist 1 -1
+		"OK"
+		redis> LRANGE mylist 0 -1
+		1) "two"
+		2) "three"
+		redis>
+	*/
+
+	mylist := []byte("mylist")
+
+	s := newStore(t)
+	_, err := s.RPUSH(mylist, []byte("one"), []byte("two"), []byte("three"))
+	testt.NoError(t, err)
+
+	err = s.LTRIM(mylist, 1, -1)
+	testt.NoError(t, err)
+
+	vals, err := s.LRANGE(mylist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("two"), []byte("three")})
+
+	err = s.LTRIM(mylist, 0, 0)
+	testt.NoError(t, err)
+
+	vals, err = s.LRANGE(mylist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("two")})
+
+	err = s.LTRIM(mylist, 5, 10)
+	testt.NoError(t, err)
+
+	n, err := s.EXISTS(mylist)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+}
+
+func TestRPOP(t *testing.T) {
+	/*
+		redis> RPUSH mylist "one" "two" "three" "four" "five"
+		(integer) 5
+		redis> RPOP mylist
+		"five"
+		redis> RPOP mylist 2
+		1) "four"
+		2) "three"
+		redis> LRANGE mylist 0 -1
+		1) "one"
+		2) "two"
+		redis>
+	*/
+
+	mylist := []byte("mylist")
+
+	s := newStore(t)
+	_, err := s.RPUSH(mylist, []byte("one"), []byte("two"), []byte("three"), []byte("four"), []byte("five"))
+	testt.NoError(t, err)
+
+	vals, err := s.RPOP(mylist, 1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("five")})
+
+	vals, err = s.RPOP(mylist, 2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("four"), []byte("three")})
+
+	vals, err = s.LRANGE(mylist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("one"), []byte("two")})
+}
+
+func TestRPUSH(t *testing.T) {
+	/*
+		redis> RPUSH mylist "hello"
+		(integer) 1
+		redis> RPUSH mylist "world"
+		(integer) 2
+		redis> LRANGE mylist 0 -1
+		1) "hello"
+		2) "world"
+		redis>
+	*/
+
+	mylist := []byte("mylist")
+
+	s := newStore(t)
+	n, err := s.RPUSH(mylist, []byte("hello"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.RPUSH(mylist, []byte("world"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	vals, err := s.LRANGE(mylist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("hello"), []byte("world")})
+}
+
+func TestRPUSHX(t *testing.T) {
+	/*
+		redis> RPUSH mylist "Hello"
+		(integer) 1
+		redis> RPUSHX mylist "World"
+		(integer) 2
+		redis> RPUSHX myotherlist "World"
+		(integer) 0
+		redis> LRANGE mylist 0 -1
+		1) "Hello"
+		2) "World"
+		redis>
+	*/
+
+	mylist := []byte("mylist")
+	myotherlist := []byte("myotherlist")
+
+	s := newStore(t)
+	_, err := s.RPUSH(mylist, []byte("Hello"))
+	testt.NoError(t, err)
+
+	n, err := s.RPUSHX(mylist, []byte("World"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	n, err = s.RPUSHX(myotherlist, []byte("World"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	vals, err := s.LRANGE(mylist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("Hello"), []byte("World")})
+}
+
+func TestListWrongType(t *testing.T) {
+	mykey := []byte("mykey")
+	mylist := []byte("mylist")
+
+	s := newStore(t)
+	_, _, err := s.SET(mykey, []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+	_, err = s.RPUSH(mylist, []byte("one"))
+	testt.NoError(t, err)
+
+	_, err = s.LPUSH(mykey, []byte("one"))
+	testt.MustEqual(t, err, error(core.ErrWrongType))
+
+	_, err = s.GET(mylist)
+	testt.MustEqual(t, err, error(core.ErrWrongType))
+
+	_, err = s.LMOVE(mylist, mykey, core.ListLeft, core.ListLeft)
+	testt.MustEqual(t, err, error(core.ErrWrongType))
+
+	typ, err := s.TYPE(mylist)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "list")
+
+	// SET overwrites a key of any type.
+	_, _, err = s.SET(mylist, []byte("Hello"), core.SetOptions{})
+	testt.NoError(t, err)
+
+	typ, err = s.TYPE(mylist)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "string")
+}
+
+func TestListCopyRename(t *testing.T) {
+	mylist := []byte("mylist")
+	clone := []byte("clone")
+	renamed := []byte("renamed")
+
+	s := newStore(t)
+	_, err := s.RPUSH(mylist, []byte("one"), []byte("two"))
+	testt.NoError(t, err)
+
+	ok, err := s.COPY(mylist, clone, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	// copy is independent from the source.
+	_, err = s.RPUSH(mylist, []byte("three"))
+	testt.NoError(t, err)
+
+	vals, err := s.LRANGE(clone, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("one"), []byte("two")})
+
+	err = s.RENAME(mylist, renamed)
+	testt.NoError(t, err)
+
+	vals, err = s.LRANGE(renamed, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("one"), []byte("two"), []byte("three")})
+
+	n, err := s.LLEN(mylist)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+}
+
+func TestListAfterRestart(t *testing.T) {
+	mylist := []byte("mylist")
+
+	dir := t.TempDir()
+	s, err := Open(Config{Dir: dir})
+	testt.NoError(t, err)
+
+	_, err = s.RPUSH(mylist, []byte("one"), []byte("two"))
+	testt.NoError(t, err)
+
+	err = s.Close()
+	testt.NoError(t, err)
+
+	s, err = Open(Config{Dir: dir})
+	testt.NoError(t, err)
+	defer s.Close()
+
+	_, err = s.LPUSH(mylist, []byte("zero"))
+	testt.NoError(t, err)
+
+	// new list must not reuse the version of the existing one.
+	_, err = s.RPUSH([]byte("other"), []byte("x"))
+	testt.NoError(t, err)
+
+	vals, err := s.LRANGE(mylist, 0, -1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, [][]byte{[]byte("zero"), []byte("one"), []byte("two")})
+}
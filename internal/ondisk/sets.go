@@ -0,0 +1,497 @@
+package ondisk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Sets operations https://redis.io/commands/?group=set
+
+// Set members are stored as d + len(key) + key + version + member => nil.
+type setMeta struct {
+	// len is the number of members.
+	len int
+}
+
+func decodeSetMeta(m meta) (setMeta, error) {
+	if len(m.payload) != 8 {
+		return setMeta{}, errCorruptedMeta
+	}
+	return setMeta{len: int(binary.BigEndian.Uint64(m.payload))}, nil
+}
+
+func (st setMeta) encode() []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(st.len))
+}
+
+func (s *Store) SADD(key []byte, members ...[]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, st, err := s.loadOrNewSet(b, key)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, member := range members {
+		added, err := addMember(b, key, m, &st, member)
+		if err != nil {
+			return 0, err
+		}
+		if added {
+			n++
+		}
+	}
+
+	if err := putSet(b, key, m, st); err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *Store) SCARD(key []byte) (int, error) {
+	_, st, _, err := getSet(s.db, key)
+	return st.len, err
+}
+
+func (s *Store) SDIFF(keys ...[]byte) ([][]byte, error) {
+	return s.algebraGeneric(keys, core.SetDiff)
+}
+
+func (s *Store) SDIFFSTORE(dst []byte, keys ...[]byte) (int, error) {
+	return s.storeGeneric(dst, keys, core.SetDiff)
+}
+
+func (s *Store) SINTER(keys ...[]byte) ([][]byte, error) {
+	return s.algebraGeneric(keys, func(sets ...[][]byte) [][]byte {
+		return core.SetInter(0, sets...)
+	})
+}
+
+func (s *Store) SINTERCARD(limit int, keys ...[]byte) (int, error) {
+	res, err := s.algebraGeneric(keys, func(sets ...[][]byte) [][]byte {
+		return core.SetInter(limit, sets...)
+	})
+	return len(res), err
+}
+
+func (s *Store) SINTERSTORE(dst []byte, keys ...[]byte) (int, error) {
+	return s.storeGeneric(dst, keys, func(sets ...[][]byte) [][]byte {
+		return core.SetInter(0, sets...)
+	})
+}
+
+func (s *Store) SISMEMBER(key, member []byte) (bool, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, _, ok, err := getSet(snap, key)
+	if err != nil || !ok {
+		return false, err
+	}
+	return hasMember(snap, key, m, member)
+}
+
+func (s *Store) SMEMBERS(key []byte) ([][]byte, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, _, ok, err := getSet(snap, key)
+	if err != nil || !ok {
+		return [][]byte{}, err
+	}
+	return setMembers(snap, key, m)
+}
+
+func (s *Store) SMISMEMBER(key []byte, members ...[]byte) ([]bool, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, _, ok, err := getSet(snap, key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]bool, len(members))
+	if !ok {
+		return res, nil
+	}
+	for i, member := range members {
+		res[i], err = hasMember(snap, key, m, member)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) SMOVE(src, dst, member []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, st, ok, err := loadSet(b, src)
+	if err != nil {
+		return false, err
+	}
+	// destination must be a set even if nothing is moved.
+	if _, _, _, err := loadSet(b, dst); err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+	if ok, err := hasMember(b, src, m, member); err != nil || !ok {
+		return false, err
+	}
+	if bytes.Equal(src, dst) {
+		return true, nil
+	}
+
+	if err := delMember(b, src, m, &st, member); err != nil {
+		return false, err
+	}
+	if err := putSet(b, src, m, st); err != nil {
+		return false, err
+	}
+	if _, err := s.addMembers(b, dst, [][]byte{member}); err != nil {
+		return false, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Store) SPOP(key []byte, count int) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, st, ok, err := loadSet(b, key)
+	if err != nil || !ok {
+		return [][]byte{}, err
+	}
+	members, err := setMembers(b, key, m)
+	if err != nil {
+		return nil, err
+	}
+
+	res := [][]byte{}
+	for _, i := range core.RandIndexes(len(members), count) {
+		if err := delMember(b, key, m, &st, members[i]); err != nil {
+			return nil, err
+		}
+		res = append(res, members[i])
+	}
+
+	if err := putSet(b, key, m, st); err != nil {
+		return nil, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Store) SRANDMEMBER(key []byte, count int) ([][]byte, error) {
+	members, err := s.SMEMBERS(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := [][]byte{}
+	for _, i := range core.RandIndexes(len(members), count) {
+		res = append(res, members[i])
+	}
+	return res, nil
+}
+
+func (s *Store) SREM(key []byte, members ...[]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, st, ok, err := loadSet(b, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	n := 0
+	for _, member := range members {
+		ok, err := hasMember(b, key, m, member)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		if err := delMember(b, key, m, &st, member); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	if err := putSet(b, key, m, st); err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *Store) SSCAN(key []byte, cursor uint64, opts core.ScanOptions) ([][]byte, uint64, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, _, ok, err := getSet(snap, key)
+	if err != nil || !ok {
+		return [][]byte{}, 0, err
+	}
+
+	count := opts.Count
+	if count <= 0 {
+		count = core.DefaultScanCount
+	}
+
+	res := [][]byte{}
+	var next uint64
+	i := 0
+	err = walkMembers(snap, key, m, s.cursors.Seek(key, cursor), func(member []byte) bool {
+		if i == count {
+			next = s.cursors.Next(key, member)
+			return false
+		}
+		i++
+
+		if len(opts.Match) == 0 || core.Match(opts.Match, member) {
+			res = append(res, bytes.Clone(member))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return res, next, nil
+}
+
+func (s *Store) SUNION(keys ...[]byte) ([][]byte, error) {
+	return s.algebraGeneric(keys, core.SetUnion)
+}
+
+func (s *Store) SUNIONSTORE(dst []byte, keys ...[]byte) (int, error) {
+	return s.storeGeneric(dst, keys, core.SetUnion)
+}
+
+func (s *Store) algebraGeneric(keys [][]byte, fn func(sets ...[][]byte) [][]byte) ([][]byte, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	sets, err := getSets(snap, keys)
+	if err != nil {
+		return nil, err
+	}
+	return fn(sets...), nil
+}
+
+func (s *Store) storeGeneric(dst []byte, keys [][]byte, fn func(sets ...[][]byte) [][]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	sets, err := getSets(b, keys)
+	if err != nil {
+		return 0, err
+	}
+	res := fn(sets...)
+
+	old, ok, err := loadMeta(b, dst)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		if err := delKey(b, dst, old); err != nil {
+			return 0, err
+		}
+	}
+	n, err := s.addMembers(b, dst, res)
+	if err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// addMembers adds members to the set, creating it if needed, returns the set size.
+func (s *Store) addMembers(b *pebble.Batch, key []byte, members [][]byte) (int, error) {
+	m, st, err := s.loadOrNewSet(b, key)
+	if err != nil {
+		return 0, err
+	}
+	for _, member := range members {
+		if _, err := addMember(b, key, m, &st, member); err != nil {
+			return 0, err
+		}
+	}
+	if err := putSet(b, key, m, st); err != nil {
+		return 0, err
+	}
+	return st.len, nil
+}
+
+// loadOrNewSet is like loadSet but allocates an empty set for a missing key,
+// it must be stored with putSet.
+func (s *Store) loadOrNewSet(b *pebble.Batch, key []byte) (meta, setMeta, error) {
+	m, st, ok, err := loadSet(b, key)
+	if err != nil || ok {
+		return m, st, err
+	}
+	version, err := s.nextVersion(b)
+	if err != nil {
+		return meta{}, setMeta{}, err
+	}
+	return meta{typ: core.TypeSet, version: version}, setMeta{}, nil
+}
+
+// getSets returns sorted members of each key, missing keys are empty sets.
+func getSets(r pebble.Reader, keys [][]byte) ([][][]byte, error) {
+	res := make([][][]byte, len(keys))
+	for i, key := range keys {
+		m, _, ok, err := getSet(r, key)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			res[i] = [][]byte{}
+			continue
+		}
+		res[i], err = setMembers(r, key, m)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// getSet is like getMeta but fails for keys that are not sets.
+func getSet(r pebble.Reader, key []byte) (meta, setMeta, bool, error) {
+	m, ok, err := getMeta(r, key)
+	return asSet(key, m, ok, err)
+}
+
+// loadSet is like loadMeta but fails for keys that are not sets.
+func loadSet(b *pebble.Batch, key []byte) (meta, setMeta, bool, error) {
+	m, ok, err := loadMeta(b, key)
+	return asSet(key, m, ok, err)
+}
+
+func asSet(key []byte, m meta, ok bool, err error) (meta, setMeta, bool, error) {
+	if err != nil || !ok {
+		return meta{}, setMeta{}, false, err
+	}
+	if m.typ != core.TypeSet {
+		return meta{}, setMeta{}, false, core.ErrWrongType
+	}
+	st, err := decodeSetMeta(m)
+	if err != nil {
+		return meta{}, setMeta{}, false, fmt.Errorf("key %q: %w", key, err)
+	}
+	return m, st, true, nil
+}
+
+// putSet writes set meta, empty set is removed.
+func putSet(b *pebble.Batch, key []byte, m meta, st setMeta) error {
+	if st.len == 0 {
+		return delKey(b, key, m)
+	}
+	m.payload = st.encode()
+	return putMeta(b, key, m)
+}
+
+// addMember adds the member, st is updated accordingly. Reports whether the member is new.
+func addMember(b *pebble.Batch, key []byte, m meta, st *setMeta, member []byte) (bool, error) {
+	ok, err := hasMember(b, key, m, member)
+	if err != nil || ok {
+		return false, err
+	}
+	if err := b.Set(memberKey(key, m, member), nil, nil); err != nil {
+		return false, err
+	}
+	st.len++
+	return true, nil
+}
+
+// delMember removes the existing member, st is updated accordingly.
+func delMember(b *pebble.Batch, key []byte, m meta, st *setMeta, member []byte) error {
+	st.len--
+	return b.Delete(memberKey(key, m, member), nil)
+}
+
+func hasMember(r pebble.Reader, key []byte, m meta, member []byte) (bool, error) {
+	_, ok, err := getValue(r, memberKey(key, m, member))
+	return ok, err
+}
+
+// setMembers returns all members in sorted order.
+func setMembers(r pebble.Reader, key []byte, m meta) ([][]byte, error) {
+	res := [][]byte{}
+	err := walkMembers(r, key, m, nil, func(member []byte) bool {
+		res = append(res, bytes.Clone(member))
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// walkMembers calls fn for members starting from seek in sorted order until it returns false.
+// Argument of fn is valid only until it returns.
+func walkMembers(r pebble.Reader, key []byte, m meta, seek []byte, fn func(member []byte) bool) error {
+	prefix := dataKeyPrefix(key, m.version)
+	iter, err := r.NewIter(&pebble.IterOptions{
+		LowerBound: memberKey(key, m, seek),
+		UpperBound: prefixEnd(prefix),
+	})
+	if err != nil {
+		return err
+	}
+	defer tryClose(iter)
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		if !fn(iter.Key()[len(prefix):]) {
+			break
+		}
+	}
+	return iter.Error()
+}
+
+func memberKey(key []byte, m meta, member []byte) []byte {
+	return append(dataKeyPrefix(key, m.version), member...)
+}
@@ -0,0 +1,280 @@
+package ondisk
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Count-Min sketch operations https://redis.io/commands/?group=cms
+// Top-K operations https://redis.io/commands/?group=topk
+
+// Sketches are stored like filters: the header is the meta payload and rows
+// of counters are layers of filterStorage, so an update touches only the chunks
+// of counters of the item.
+
+func (s *Store) CMSINCRBY(key []byte, items [][]byte, incrs []int64) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, sketch, err := loadCMS(b, key)
+	if err != nil {
+		return nil, err
+	}
+	if sketch == nil {
+		return nil, core.ErrCMSNotFound
+	}
+
+	st := filterStorage{r: b, b: b, key: key, version: m.version}
+	res := make([]int64, len(items))
+	var incrErr error
+	for i, item := range items {
+		res[i], err = sketch.IncrBy(st, item, incrs[i])
+		if errors.Is(err, core.ErrCMSOverflow) {
+			incrErr = err
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// items incremented before an overflow stay incremented, like in RedisBloom.
+	m.payload = sketch.Encode()
+	if err := putMeta(b, key, m); err != nil {
+		return nil, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return nil, err
+	}
+	if incrErr != nil {
+		return nil, incrErr
+	}
+	return res, nil
+}
+
+func (s *Store) CMSINIT(key []byte, width, depth int64) error {
+	return s.reserve(key, core.TypeCMS, core.ErrCMSExists, core.NewCountMinSketch(width, depth).Encode())
+}
+
+func (s *Store) CMSMERGE(dst []byte, keys [][]byte, weights []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, sketch, err := loadCMS(b, dst)
+	if err != nil {
+		return err
+	}
+	if sketch == nil {
+		return core.ErrCMSNotFound
+	}
+
+	srcs := make([]*core.CountMinSketch, len(keys))
+	srcSts := make([]core.FilterStorage, len(keys))
+	for i, key := range keys {
+		srcMeta, src, err := loadCMS(b, key)
+		if err != nil {
+			return err
+		}
+		if src == nil {
+			return core.ErrCMSNotFound
+		}
+		srcs[i], srcSts[i] = src, filterStorage{r: b, key: key, version: srcMeta.version}
+	}
+
+	if err := sketch.Merge(filterStorage{r: b, b: b, key: dst, version: m.version}, srcs, srcSts, weights); err != nil {
+		return err
+	}
+	m.payload = sketch.Encode()
+	if err := putMeta(b, dst, m); err != nil {
+		return err
+	}
+	return b.Commit(s.syncOpt)
+}
+
+func (s *Store) CMSQUERY(key []byte, items ...[]byte) ([]int64, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, sketch, err := getCMS(snap, key)
+	if err != nil {
+		return nil, err
+	}
+	if sketch == nil {
+		return nil, core.ErrCMSNotFound
+	}
+
+	st := filterStorage{r: snap, key: key, version: m.version}
+	res := make([]int64, len(items))
+	for i, item := range items {
+		if res[i], err = sketch.Query(st, item); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) TOPKINCRBY(key []byte, items [][]byte, incrs []int64) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, top, err := loadTopK(b, key)
+	if err != nil {
+		return nil, err
+	}
+	if top == nil {
+		return nil, core.ErrTopKNotFound
+	}
+
+	st := filterStorage{r: b, b: b, key: key, version: m.version}
+	res := make([][]byte, len(items))
+	for i, item := range items {
+		if res[i], err = top.IncrBy(st, item, incrs[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	m.payload = top.Encode()
+	if err := putMeta(b, key, m); err != nil {
+		return nil, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Store) TOPKINFO(key []byte) (core.TopKOptions, error) {
+	_, top, err := getTopK(s.db, key)
+	if err != nil {
+		return core.TopKOptions{}, err
+	}
+	if top == nil {
+		return core.TopKOptions{}, core.ErrTopKNotFound
+	}
+	return top.Info(), nil
+}
+
+func (s *Store) TOPKLIST(key []byte) ([]core.TopKEntry, error) {
+	_, top, err := getTopK(s.db, key)
+	if err != nil {
+		return nil, err
+	}
+	if top == nil {
+		return nil, core.ErrTopKNotFound
+	}
+	return top.List(), nil
+}
+
+func (s *Store) TOPKQUERY(key []byte, items ...[]byte) ([]bool, error) {
+	_, top, err := getTopK(s.db, key)
+	if err != nil {
+		return nil, err
+	}
+	if top == nil {
+		return nil, core.ErrTopKNotFound
+	}
+	res := make([]bool, len(items))
+	for i, item := range items {
+		res[i] = top.Query(item)
+	}
+	return res, nil
+}
+
+func (s *Store) TOPKRESERVE(key []byte, opts core.TopKOptions) error {
+	return s.reserve(key, core.TypeTopK, core.ErrTopKExists, core.NewTopK(opts).Encode())
+}
+
+// reserve creates a key of the type with the header, errExists is returned if the key exists.
+func (s *Store) reserve(key []byte, typ core.KeyType, errExists error, header []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	_, ok, err := loadMeta(b, key)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return errExists
+	}
+	m, err := s.newFilter(b, key, typ)
+	if err != nil {
+		return err
+	}
+	m.payload = header
+	if err := putMeta(b, key, m); err != nil {
+		return err
+	}
+	return b.Commit(s.syncOpt)
+}
+
+// getCMS is like getMeta but fails for keys that are not Count-Min sketches and also returns the sketch.
+// The sketch is nil for a missing key.
+func getCMS(r pebble.Reader, key []byte) (meta, *core.CountMinSketch, error) {
+	m, ok, err := getMeta(r, key)
+	return asCMS(key, m, ok, err)
+}
+
+// loadCMS is like loadMeta but fails for keys that are not Count-Min sketches and also returns the sketch.
+// The sketch is nil for a missing key.
+func loadCMS(b *pebble.Batch, key []byte) (meta, *core.CountMinSketch, error) {
+	m, ok, err := loadMeta(b, key)
+	return asCMS(key, m, ok, err)
+}
+
+func asCMS(key []byte, m meta, ok bool, err error) (meta, *core.CountMinSketch, error) {
+	if err != nil || !ok {
+		return meta{}, nil, err
+	}
+	if m.typ != core.TypeCMS {
+		return meta{}, nil, core.ErrWrongType
+	}
+	sketch, err := core.DecodeCountMinSketch(m.payload)
+	if err != nil {
+		return meta{}, nil, fmt.Errorf("key %q: %w", key, err)
+	}
+	return m, sketch, nil
+}
+
+// getTopK is like getMeta but fails for keys that are not Top-K lists and also returns the top.
+// The top is nil for a missing key.
+func getTopK(r pebble.Reader, key []byte) (meta, *core.TopK, error) {
+	m, ok, err := getMeta(r, key)
+	return asTopK(key, m, ok, err)
+}
+
+// loadTopK is like loadMeta but fails for keys that are not Top-K lists and also returns the top.
+// The top is nil for a missing key.
+func loadTopK(b *pebble.Batch, key []byte) (meta, *core.TopK, error) {
+	m, ok, err := loadMeta(b, key)
+	return asTopK(key, m, ok, err)
+}
+
+func asTopK(key []byte, m meta, ok bool, err error) (meta, *core.TopK, error) {
+	if err != nil || !ok {
+		return meta{}, nil, err
+	}
+	if m.typ != core.TypeTopK {
+		return meta{}, nil, core.ErrWrongType
+	}
+	top, err := core.DecodeTopK(m.payload)
+	if err != nil {
+		return meta{}, nil, fmt.Errorf("key %q: %w", key, err)
+	}
+	return m, top, nil
+}
@@ -1,8 +1,14 @@
 package ondisk
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"sync"
+	"time"
 
 	"github.com/cristaloleg/didis/internal/core"
 
@@ -13,6 +19,12 @@ var _ core.Store = &Store{}
 
 type Store struct {
 	db *pebble.DB
+	// mu serializes writes, so read-modify-write batches are atomic.
+	mu sync.Mutex
+	// version is the last allocated collection version, guarded by mu.
+	version uint64
+	// cursors of SCAN, HSCAN and SSCAN commands.
+	cursors core.ScanCursors
 
 	syncOpt *pebble.WriteOptions
 }
@@ -73,9 +85,437 @@ func Open(cfg Config) (*Store, error) {
 	if cfg.NoSync {
 		s.syncOpt = pebble.NoSync
 	}
+
+	if err := s.upgrade(cfg.ReadOnly); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("upgrade: %w", err)
+	}
+	if err := s.loadVersion(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("load version: %w", err)
+	}
 	return s, nil
 }
 
+// upgrade checks keys layout version and migrates data from older layouts.
+// Read-only stores can't be migrated, so they fail instead.
+func (s *Store) upgrade(readOnly bool) error {
+	val, closer, err := s.db.Get(formatKey)
+	switch {
+	case errors.Is(err, pebble.ErrNotFound) && readOnly:
+		return s.checkEmpty()
+	case errors.Is(err, pebble.ErrNotFound):
+		return s.migrateLegacy(legacyMigration{})
+	case err != nil:
+		return err
+	}
+	val = bytes.Clone(val)
+	tryClose(closer)
+
+	if bytes.HasPrefix(val, legacyMigrationTag) {
+		if readOnly {
+			return errMigrationRequired
+		}
+		st, err := decodeLegacyMigration(val)
+		if err != nil {
+			return err
+		}
+		return s.migrateLegacy(st)
+	}
+	if len(val) != 8 {
+		return fmt.Errorf("corrupted format version")
+	}
+	if v := binary.BigEndian.Uint64(val); v != formatVersion {
+		return fmt.Errorf("unsupported format version %d, want %d", v, formatVersion)
+	}
+	return nil
+}
+
+var errMigrationRequired = errors.New("legacy layout must be migrated, open the store in read-write mode")
+
+// checkEmpty fails if there is any record, a store without a format version is either empty or legacy.
+func (s *Store) checkEmpty() error {
+	iter, err := s.db.NewIter(nil)
+	if err != nil {
+		return err
+	}
+	defer tryClose(iter)
+
+	if iter.First() {
+		return errMigrationRequired
+	}
+	return iter.Error()
+}
+
+var (
+	// legacyTTLPrefix is a prefix of expiry records in the legacy layout: prefix+key => unix ms.
+	legacyTTLPrefix = []byte("\x00didis:ttl:")
+	// legacyExpPrefix is a prefix of expiry index in the legacy layout: prefix+unix ms+key => nil.
+	legacyExpPrefix = []byte("\x00didis:exp:")
+	// legacyMigrationTag is a prefix of the migration state kept in formatKey.
+	legacyMigrationTag = []byte("legacy:")
+)
+
+// migrateBatchSize is how many keys are migrated in one batch.
+const migrateBatchSize = 1000
+
+// legacyMigration is a state of the legacy layout migration, it's kept in formatKey
+// until the migration is done, so an interrupted migration continues on the next open.
+//
+// Legacy keys are raw user keys, so they can't be rewritten in place: a new record
+// might overwrite a legacy key that is not migrated yet. Instead the migration
+//  1. writes new records under the staging prefix, which is greater than any legacy key,
+//  2. removes all legacy keys with a single range deletion,
+//  3. moves staged records to their place.
+type legacyMigration struct {
+	// staging is a prefix of new records, empty until the migration starts.
+	staging []byte
+	// cleared reports whether legacy keys are removed (step 3).
+	cleared bool
+	// next is a legacy key (step 1) or a staged record (step 3) to continue from.
+	next []byte
+}
+
+func (st legacyMigration) encode() []byte {
+	res := bytes.Clone(legacyMigrationTag)
+	if st.cleared {
+		res = append(res, 1)
+	} else {
+		res = append(res, 0)
+	}
+	res = binary.AppendUvarint(res, uint64(len(st.staging)))
+	res = append(res, st.staging...)
+	return append(res, st.next...)
+}
+
+func decodeLegacyMigration(b []byte) (legacyMigration, error) {
+	b = b[len(legacyMigrationTag):]
+	if len(b) < 2 {
+		return legacyMigration{}, errors.New("corrupted migration state")
+	}
+	n, size := binary.Uvarint(b[1:])
+	if size <= 0 || uint64(len(b)-1-size) < n {
+		return legacyMigration{}, errors.New("corrupted migration state")
+	}
+	staging := b[1+size:]
+	return legacyMigration{
+		staging: staging[:n],
+		cleared: b[0] == 1,
+		next:    staging[n:],
+	}, nil
+}
+
+// migrateLegacy rewrites raw string keys of the legacy layout as typed keys in bounded batches.
+// The format version is written with the last batch, a new database has nothing to migrate.
+func (s *Store) migrateLegacy(st legacyMigration) error {
+	if len(st.staging) == 0 {
+		staging, err := legacyStaging(s.db)
+		if err != nil {
+			return fmt.Errorf("legacy layout: %w", err)
+		}
+		st = legacyMigration{staging: staging}
+	}
+
+	for !st.cleared {
+		if err := s.stageLegacy(&st); err != nil {
+			return fmt.Errorf("legacy layout: %w", err)
+		}
+	}
+	for {
+		done, err := s.unstageLegacy(&st)
+		if err != nil {
+			return fmt.Errorf("legacy layout: %w", err)
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// legacyStaging returns a prefix that is greater than any key in the database
+// and doesn't start like any key of the current layout.
+func legacyStaging(r pebble.Reader) ([]byte, error) {
+	iter, err := r.NewIter(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tryClose(iter)
+
+	staging := []byte{0xff}
+	if iter.Last() && bytes.Compare(iter.Key(), staging) >= 0 {
+		staging = append(bytes.Clone(iter.Key()), 0)
+	}
+	return staging, iter.Error()
+}
+
+// stageLegacy writes the next batch of legacy keys under the staging prefix.
+// After the last one legacy keys are removed.
+func (s *Store) stageLegacy(st *legacyMigration) error {
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: st.next,
+		UpperBound: st.staging,
+	})
+	if err != nil {
+		return err
+	}
+	defer tryClose(iter)
+
+	b := s.db.NewBatch()
+	defer tryClose(b)
+
+	now := core.NowMs()
+	n := 0
+	for iter.First(); iter.Valid() && n < migrateBatchSize; iter.Next() {
+		key := iter.Key()
+		if isLegacyRecord(key) {
+			continue
+		}
+		n++
+
+		at, err := legacyExpireTime(s.db, key)
+		if err != nil {
+			return err
+		}
+		// long values stay inline, the next write splits them into chunks.
+		m := meta{typ: core.TypeString, expireAt: at, payload: iter.Value()}
+		if m.isExpired(now) {
+			continue
+		}
+		if err := b.Set(append(bytes.Clone(st.staging), metaKey(key)...), m.encode(), nil); err != nil {
+			return err
+		}
+		if at != 0 {
+			if err := b.Set(append(bytes.Clone(st.staging), expKey(at, key)...), nil, nil); err != nil {
+				return err
+			}
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	if iter.Valid() {
+		st.next = bytes.Clone(iter.Key())
+	} else {
+		st.cleared, st.next = true, nil
+		if err := b.DeleteRange([]byte{}, st.staging, nil); err != nil {
+			return err
+		}
+	}
+	// the state is written after the range deletion, so it survives it.
+	if err := b.Set(formatKey, st.encode(), nil); err != nil {
+		return err
+	}
+	return b.Commit(pebble.Sync)
+}
+
+// unstageLegacy moves the next batch of staged records to their place,
+// reports whether it was the last one.
+func (s *Store) unstageLegacy(st *legacyMigration) (bool, error) {
+	lower := append(bytes.Clone(st.staging), st.next...)
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: lower,
+		UpperBound: prefixEnd(st.staging),
+	})
+	if err != nil {
+		return false, err
+	}
+	defer tryClose(iter)
+
+	b := s.db.NewBatch()
+	defer tryClose(b)
+
+	var last []byte
+	n := 0
+	for iter.First(); iter.Valid() && n < migrateBatchSize; iter.Next() {
+		last = append(last[:0], iter.Key()...)
+		if err := b.Set(last[len(st.staging):], iter.Value(), nil); err != nil {
+			return false, err
+		}
+		n++
+	}
+	if err := iter.Error(); err != nil {
+		return false, err
+	}
+
+	done := !iter.Valid()
+	var upper []byte
+	if done {
+		upper = append(bytes.Clone(last), 0)
+	} else {
+		upper = bytes.Clone(iter.Key())
+		st.next = upper[len(st.staging):]
+	}
+	if last != nil {
+		if err := b.DeleteRange(lower, upper, nil); err != nil {
+			return false, err
+		}
+	}
+
+	state := st.encode()
+	if done {
+		state = binary.BigEndian.AppendUint64(nil, formatVersion)
+	}
+	if err := b.Set(formatKey, state, nil); err != nil {
+		return false, err
+	}
+	return done, b.Commit(pebble.Sync)
+}
+
+// isLegacyRecord reports whether the key is an internal record of the legacy layout
+// or the migration state. Other keys, including ones starting with \x00didis:, are user keys.
+func isLegacyRecord(key []byte) bool {
+	return bytes.Equal(key, formatKey) ||
+		bytes.HasPrefix(key, legacyTTLPrefix) ||
+		bytes.HasPrefix(key, legacyExpPrefix)
+}
+
+func legacyExpireTime(r pebble.Reader, key []byte) (int64, error) {
+	ttlKey := append(bytes.Clone(legacyTTLPrefix), key...)
+	val, closer, err := r.Get(ttlKey)
+	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer tryClose(closer)
+
+	if len(val) != 8 {
+		return 0, fmt.Errorf("key %q: corrupted expiry", key)
+	}
+	return int64(binary.BigEndian.Uint64(val)), nil
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+const (
+	// sweepInterval is how often expired keys are evicted.
+	sweepInterval = 100 * time.Millisecond
+	// sweepLimit is how many expired keys are removed in one batch.
+	sweepLimit = 100
+)
+
+// Run evicts expired keys in the background until ctx is done.
+// Expired keys are also hidden lazily on access, so this only reclaims space.
+func (s *Store) Run(ctx context.Context) error {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			for {
+				n, err := s.sweep()
+				if err != nil {
+					return fmt.Errorf("sweep: %w", err)
+				}
+				nf, err := s.sweepFields()
+				if err != nil {
+					return fmt.Errorf("sweep fields: %w", err)
+				}
+				if n < sweepLimit && nf < sweepLimit {
+					break
+				}
+			}
+		}
+	}
+}
+
+// sweep removes expired keys using expiry index, returns number of removed keys.
+func (s *Store) sweep() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: expPrefix,
+		UpperBound: expKey(core.NowMs()+1, nil),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer tryClose(iter)
+
+	n := 0
+	for iter.First(); iter.Valid() && n < sweepLimit; iter.Next() {
+		k := iter.Key()[len(expPrefix):]
+		at := int64(binary.BigEndian.Uint64(k))
+		key := bytes.Clone(k[8:])
+
+		m, ok, err := readMeta(b, key)
+		if err != nil {
+			return 0, err
+		}
+		if !ok || m.expireAt != at {
+			// stale index entry, the key is gone or has another expiry.
+			err = b.Delete(iter.Key(), nil)
+		} else {
+			err = delKey(b, key, m)
+		}
+		if err != nil {
+			return 0, err
+		}
+		n++
+	}
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+
+	if n == 0 {
+		return 0, nil
+	}
+	return n, b.Commit(s.syncOpt)
+}
+
+// sweepFields removes expired hash fields using member expiry index, returns number of removed fields.
+func (s *Store) sweepFields() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	iter, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: fieldExpPrefix,
+		UpperBound: binary.BigEndian.AppendUint64(bytes.Clone(fieldExpPrefix), uint64(core.NowMs()+1)),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer tryClose(iter)
+
+	n := 0
+	for iter.First(); iter.Valid() && n < sweepLimit; iter.Next() {
+		at, key, version, field, err := decodeFieldExpKey(iter.Key())
+		if err != nil {
+			return 0, err
+		}
+		if err := reclaimField(b, bytes.Clone(key), version, bytes.Clone(field), at); err != nil {
+			return 0, err
+		}
+		if err := b.Delete(iter.Key(), nil); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+
+	if n == 0 {
+		return 0, nil
+	}
+	return n, b.Commit(s.syncOpt)
+}
+
 func tryClose(c io.Closer) {
 	if c != nil {
 		c.Close()
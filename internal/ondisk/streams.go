@@ -0,0 +1,1363 @@
+package ondisk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Streams operations https://redis.io/commands/?group=stream
+
+// Stream entries are stored as d + len(key) + key + version + ms + seq => fields,
+// both parts of the ID are big-endian, so entries are ordered by ID and ranges are pebble range scans.
+// Unlike other collections an empty stream is kept.
+type streamMeta struct {
+	// len is the number of entries.
+	len int
+	// lastID is the ID of the last added entry, even if it was deleted since.
+	lastID core.StreamID
+	// maxDeletedID is the greatest ID of an entry deleted by XDEL.
+	maxDeletedID core.StreamID
+	// entriesAdded is the number of entries ever added to the stream.
+	entriesAdded int64
+}
+
+func decodeStreamMeta(m meta) (streamMeta, error) {
+	if len(m.payload) != 8+16+16+8 {
+		return streamMeta{}, errCorruptedMeta
+	}
+	return streamMeta{
+		len:          int(binary.BigEndian.Uint64(m.payload)),
+		lastID:       decodeStreamID(m.payload[8:]),
+		maxDeletedID: decodeStreamID(m.payload[24:]),
+		entriesAdded: int64(binary.BigEndian.Uint64(m.payload[40:])),
+	}, nil
+}
+
+func (sm streamMeta) encode() []byte {
+	res := make([]byte, 0, 8+16+16+8)
+	res = binary.BigEndian.AppendUint64(res, uint64(sm.len))
+	res = appendStreamID(res, sm.lastID)
+	res = appendStreamID(res, sm.maxDeletedID)
+	return binary.BigEndian.AppendUint64(res, uint64(sm.entriesAdded))
+}
+
+// Consumer groups are stored as g + len(key) + key + version + sub, where sub is:
+//
+//	g + len(group) + group             => last delivered ID + entries read
+//	c + len(group) + group + consumer  => seen time + active time
+//	p + len(group) + group + ID        => delivery time + deliveries + consumer
+//
+// so consumers are ordered by name and pending entries are ordered by ID.
+const (
+	groupRecord    = 'g'
+	consumerRecord = 'c'
+	pendingRecord  = 'p'
+)
+
+type streamGroup struct {
+	lastID core.StreamID
+	// entriesRead is -1 if unknown.
+	entriesRead int64
+}
+
+func decodeStreamGroup(val []byte) (streamGroup, error) {
+	if len(val) != 16+8 {
+		return streamGroup{}, errCorruptedGroup
+	}
+	return streamGroup{
+		lastID:      decodeStreamID(val),
+		entriesRead: int64(binary.BigEndian.Uint64(val[16:])),
+	}, nil
+}
+
+func (g streamGroup) encode() []byte {
+	res := appendStreamID(make([]byte, 0, 16+8), g.lastID)
+	return binary.BigEndian.AppendUint64(res, uint64(g.entriesRead))
+}
+
+type streamConsumer struct {
+	seenTime int64
+	// activeTime is -1 if the consumer has never got an entry.
+	activeTime int64
+}
+
+func decodeStreamConsumer(val []byte) (streamConsumer, error) {
+	if len(val) != 8+8 {
+		return streamConsumer{}, errCorruptedGroup
+	}
+	return streamConsumer{
+		seenTime:   int64(binary.BigEndian.Uint64(val)),
+		activeTime: int64(binary.BigEndian.Uint64(val[8:])),
+	}, nil
+}
+
+func (c streamConsumer) encode() []byte {
+	res := binary.BigEndian.AppendUint64(make([]byte, 0, 8+8), uint64(c.seenTime))
+	return binary.BigEndian.AppendUint64(res, uint64(c.activeTime))
+}
+
+// pendingEntry is an entry delivered to a consumer but not acknowledged yet.
+type pendingEntry struct {
+	id           core.StreamID
+	consumer     []byte
+	deliveryTime int64
+	deliveries   int64
+}
+
+func decodePendingEntry(sub, val []byte) (pendingEntry, error) {
+	if len(sub) != 16 || len(val) < 8+8 {
+		return pendingEntry{}, errCorruptedGroup
+	}
+	return pendingEntry{
+		id:           decodeStreamID(sub),
+		consumer:     bytes.Clone(val[16:]),
+		deliveryTime: int64(binary.BigEndian.Uint64(val)),
+		deliveries:   int64(binary.BigEndian.Uint64(val[8:])),
+	}, nil
+}
+
+func (p pendingEntry) encode() []byte {
+	res := binary.BigEndian.AppendUint64(make([]byte, 0, 8+8+len(p.consumer)), uint64(p.deliveryTime))
+	res = binary.BigEndian.AppendUint64(res, uint64(p.deliveries))
+	return append(res, p.consumer...)
+}
+
+var (
+	errCorruptedEntry = errors.New("corrupted stream entry")
+	errCorruptedGroup = errors.New("corrupted stream consumer group")
+)
+
+func (s *Store) XACK(key, group []byte, ids ...core.StreamID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, _, _, err := loadStreamGroup(b, key, group)
+	if err != nil {
+		if errors.Is(err, core.ErrKeyNotFound) || errors.Is(err, core.ErrNoGroup) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	n := 0
+	for _, id := range ids {
+		k := pendingKey(key, m, group, id)
+		_, ok, err := getValue(b, k)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		if err := b.Delete(k, nil); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *Store) XADD(key []byte, opts core.XAddOptions, fieldvals ...[]byte) (core.StreamID, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, sm, ok, err := loadStream(b, key)
+	if err != nil {
+		return core.StreamID{}, false, err
+	}
+	if !ok && opts.NoMkStream {
+		return core.StreamID{}, false, nil
+	}
+
+	id, err := opts.NextID(sm.lastID, core.NowMs())
+	if err != nil {
+		return core.StreamID{}, false, err
+	}
+	if !ok {
+		version, err := s.nextVersion(b)
+		if err != nil {
+			return core.StreamID{}, false, err
+		}
+		m = meta{typ: core.TypeStream, version: version}
+	}
+
+	if err := b.Set(entryKey(key, m, id), encodeFields(fieldvals), nil); err != nil {
+		return core.StreamID{}, false, err
+	}
+	sm.len++
+	sm.lastID = id
+	sm.entriesAdded++
+
+	if _, err := trimStream(b, key, m, &sm, opts.Trim); err != nil {
+		return core.StreamID{}, false, err
+	}
+	if err := putStream(b, key, m, sm); err != nil {
+		return core.StreamID{}, false, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return core.StreamID{}, false, err
+	}
+	return id, true, nil
+}
+
+func (s *Store) XAUTOCLAIM(key, group, consumer []byte, minIdle int64, start core.StreamID, count int, justID bool) (core.StreamID, []core.StreamEntry, []core.StreamID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, _, _, err := loadStreamGroup(b, key, group)
+	if err != nil {
+		return core.StreamID{}, nil, nil, err
+	}
+
+	now := core.NowMs()
+	var next core.StreamID
+	claimed, deleted := []core.StreamEntry{}, []core.StreamID{}
+	// like Redis, the scan is limited to 10 pending entries per requested one.
+	attempts := count * 10
+	err = scanPending(b, key, m, group, start, func(p pendingEntry) (bool, error) {
+		if attempts == 0 || count <= 0 {
+			next = p.id
+			return false, nil
+		}
+		attempts--
+
+		e, ok, err := getEntry(b, key, m, p.id)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			deleted = append(deleted, p.id)
+			count--
+			return true, b.Delete(pendingKey(key, m, group, p.id), nil)
+		}
+		if minIdle > 0 && now-p.deliveryTime < minIdle {
+			return true, nil
+		}
+
+		p.consumer, p.deliveryTime = consumer, now
+		if justID {
+			e = core.StreamEntry{ID: e.ID}
+		} else {
+			p.deliveries++
+		}
+		claimed = append(claimed, e)
+		count--
+		return true, putPending(b, key, m, group, p)
+	})
+	if err != nil {
+		return core.StreamID{}, nil, nil, err
+	}
+
+	if err := touchConsumer(b, key, m, group, consumer, now, len(claimed) > 0); err != nil {
+		return core.StreamID{}, nil, nil, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return core.StreamID{}, nil, nil, err
+	}
+	return next, claimed, deleted, nil
+}
+
+func (s *Store) XCLAIM(key, group, consumer []byte, minIdle int64, ids []core.StreamID, opts core.XClaimOptions) ([]core.StreamEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, _, g, err := loadStreamGroup(b, key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	now := core.NowMs()
+	deliveryTime := opts.DeliveryTime
+	if deliveryTime == 0 {
+		deliveryTime = now
+	}
+	if opts.LastID.Compare(g.lastID) > 0 {
+		g.lastID = opts.LastID
+		if err := putGroup(b, key, m, group, g); err != nil {
+			return nil, err
+		}
+	}
+
+	res := []core.StreamEntry{}
+	for _, id := range ids {
+		p, pending, err := getPending(b, key, m, group, id)
+		if err != nil {
+			return nil, err
+		}
+		e, ok, err := getEntry(b, key, m, id)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case !ok:
+			if pending {
+				if err := b.Delete(pendingKey(key, m, group, id), nil); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		case !pending && !opts.Force:
+			continue
+		case !pending:
+			p = pendingEntry{id: id, deliveries: 1}
+		case minIdle > 0 && now-p.deliveryTime < minIdle:
+			continue
+		}
+
+		p.consumer, p.deliveryTime = consumer, deliveryTime
+		switch {
+		case opts.RetryCount >= 0:
+			p.deliveries = opts.RetryCount
+		case !opts.JustID:
+			p.deliveries++
+		}
+		if err := putPending(b, key, m, group, p); err != nil {
+			return nil, err
+		}
+		if opts.JustID {
+			e = core.StreamEntry{ID: id}
+		}
+		res = append(res, e)
+	}
+
+	// unlike XAUTOCLAIM, the consumer is created only when it gets an entry.
+	if len(res) > 0 {
+		if err := touchConsumer(b, key, m, group, consumer, now, true); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Store) XDEL(key []byte, ids ...core.StreamID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, sm, ok, err := loadStream(b, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	n := 0
+	for _, id := range ids {
+		k := entryKey(key, m, id)
+		_, ok, err := getValue(b, k)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		if err := b.Delete(k, nil); err != nil {
+			return 0, err
+		}
+		sm.len--
+		if id.Compare(sm.maxDeletedID) > 0 {
+			sm.maxDeletedID = id
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	if err := putStream(b, key, m, sm); err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *Store) XGROUPCREATE(key, group []byte, opts core.XGroupIDOptions, mkStream bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, sm, ok, err := loadStream(b, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if !mkStream {
+			return core.ErrKeyNotFound
+		}
+		version, err := s.nextVersion(b)
+		if err != nil {
+			return err
+		}
+		m = meta{typ: core.TypeStream, version: version}
+		if err := putStream(b, key, m, sm); err != nil {
+			return err
+		}
+	}
+
+	_, ok, err = getGroup(b, key, m, group)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return core.ErrBusyGroup
+	}
+
+	g := streamGroup{lastID: opts.ID, entriesRead: opts.EntriesRead}
+	if opts.LastID {
+		g.lastID = sm.lastID
+	}
+	if err := putGroup(b, key, m, group, g); err != nil {
+		return err
+	}
+	return b.Commit(s.syncOpt)
+}
+
+func (s *Store) XGROUPCREATECONSUMER(key, group, consumer []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, _, _, err := loadStreamGroup(b, key, group)
+	if err != nil {
+		return false, err
+	}
+	_, ok, err := getConsumer(b, key, m, group, consumer)
+	if err != nil || ok {
+		return false, err
+	}
+
+	c := streamConsumer{seenTime: core.NowMs(), activeTime: -1}
+	if err := b.Set(consumerKey(key, m, group, consumer), c.encode(), nil); err != nil {
+		return false, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Store) XGROUPDELCONSUMER(key, group, consumer []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, _, _, err := loadStreamGroup(b, key, group)
+	if err != nil {
+		return 0, err
+	}
+	_, ok, err := getConsumer(b, key, m, group, consumer)
+	if err != nil || !ok {
+		return 0, err
+	}
+	if err := b.Delete(consumerKey(key, m, group, consumer), nil); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	err = scanPending(b, key, m, group, core.StreamID{}, func(p pendingEntry) (bool, error) {
+		if !bytes.Equal(p.consumer, consumer) {
+			return true, nil
+		}
+		n++
+		return true, b.Delete(pendingKey(key, m, group, p.id), nil)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *Store) XGROUPDESTROY(key, group []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, _, ok, err := loadStream(b, key)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, core.ErrKeyNotFound
+	}
+	_, ok, err = getGroup(b, key, m, group)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if err := b.Delete(groupSubPrefix(key, m, groupRecord, group), nil); err != nil {
+		return false, err
+	}
+	for _, kind := range []byte{consumerRecord, pendingRecord} {
+		prefix := groupSubPrefix(key, m, kind, group)
+		if err := b.DeleteRange(prefix, prefixEnd(prefix), nil); err != nil {
+			return false, err
+		}
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Store) XGROUPSETID(key, group []byte, opts core.XGroupIDOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, sm, _, err := loadStreamGroup(b, key, group)
+	if err != nil {
+		return err
+	}
+
+	g := streamGroup{lastID: opts.ID, entriesRead: opts.EntriesRead}
+	if opts.LastID {
+		g.lastID = sm.lastID
+	}
+	if err := putGroup(b, key, m, group, g); err != nil {
+		return err
+	}
+	return b.Commit(s.syncOpt)
+}
+
+func (s *Store) XINFOCONSUMERS(key, group []byte) ([]core.StreamConsumerInfo, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, _, _, err := getStreamGroup(snap, key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(map[string]int)
+	err = scanPending(snap, key, m, group, core.StreamID{}, func(p pendingEntry) (bool, error) {
+		pending[string(p.consumer)]++
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := groupSubPrefix(key, m, consumerRecord, group)
+	iter, err := snap.NewIter(&pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: prefixEnd(prefix),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tryClose(iter)
+
+	now := core.NowMs()
+	res := []core.StreamConsumerInfo{}
+	for iter.First(); iter.Valid(); iter.Next() {
+		c, err := decodeStreamConsumer(iter.Value())
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key, err)
+		}
+		name := bytes.Clone(iter.Key()[len(prefix):])
+		info := core.StreamConsumerInfo{
+			Name:     name,
+			Pending:  pending[string(name)],
+			Idle:     now - c.seenTime,
+			Inactive: -1,
+		}
+		if c.activeTime >= 0 {
+			info.Inactive = now - c.activeTime
+		}
+		res = append(res, info)
+	}
+	return res, iter.Error()
+}
+
+func (s *Store) XINFOGROUPS(key []byte) ([]core.StreamGroupInfo, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, sm, ok, err := getStream(snap, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	counters, err := streamCounters(snap, key, m, sm)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []core.StreamGroupInfo{}
+	err = scanGroups(snap, key, m, func(name []byte, g streamGroup) error {
+		consumers, err := countRange(snap, groupSubPrefix(key, m, consumerRecord, name))
+		if err != nil {
+			return err
+		}
+		pending, err := countRange(snap, groupSubPrefix(key, m, pendingRecord, name))
+		if err != nil {
+			return err
+		}
+		res = append(res, core.StreamGroupInfo{
+			Name:            name,
+			Consumers:       consumers,
+			Pending:         pending,
+			LastDeliveredID: g.lastID,
+			EntriesRead:     g.entriesRead,
+			Lag:             counters.Lag(g.lastID, g.entriesRead),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// group records are ordered by name length first.
+	slices.SortFunc(res, func(a, b core.StreamGroupInfo) int {
+		return bytes.Compare(a.Name, b.Name)
+	})
+	return res, nil
+}
+
+func (s *Store) XINFOSTREAM(key []byte) (core.StreamInfo, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, sm, ok, err := getStream(snap, key)
+	if err != nil {
+		return core.StreamInfo{}, err
+	}
+	if !ok {
+		return core.StreamInfo{}, core.ErrKeyNotFound
+	}
+	counters, err := streamCounters(snap, key, m, sm)
+	if err != nil {
+		return core.StreamInfo{}, err
+	}
+
+	res := core.StreamInfo{StreamCounters: counters}
+	err = scanGroups(snap, key, m, func([]byte, streamGroup) error {
+		res.Groups++
+		return nil
+	})
+	if err != nil {
+		return core.StreamInfo{}, err
+	}
+
+	for _, rev := range []bool{false, true} {
+		entries, err := streamEntries(snap, key, m, core.StreamID{}, core.MaxStreamID, 1, rev)
+		if err != nil {
+			return core.StreamInfo{}, err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		if rev {
+			res.LastEntry = &entries[0]
+		} else {
+			res.FirstEntry = &entries[0]
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) XLEN(key []byte) (int, error) {
+	_, sm, _, err := getStream(s.db, key)
+	return sm.len, err
+}
+
+func (s *Store) XPENDING(key, group []byte) (core.StreamPendingSummary, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, _, _, err := getStreamGroup(snap, key, group)
+	if err != nil {
+		return core.StreamPendingSummary{}, err
+	}
+
+	var res core.StreamPendingSummary
+	counts := make(map[string]int)
+	err = scanPending(snap, key, m, group, core.StreamID{}, func(p pendingEntry) (bool, error) {
+		if res.Count == 0 {
+			res.First = p.id
+		}
+		res.Last = p.id
+		res.Count++
+		counts[string(p.consumer)]++
+		return true, nil
+	})
+	if err != nil {
+		return core.StreamPendingSummary{}, err
+	}
+
+	for name, n := range counts {
+		res.Consumers = append(res.Consumers, core.StreamConsumerPending{Name: []byte(name), Count: n})
+	}
+	slices.SortFunc(res.Consumers, func(a, b core.StreamConsumerPending) int {
+		return bytes.Compare(a.Name, b.Name)
+	})
+	return res, nil
+}
+
+func (s *Store) XPENDINGRANGE(key, group []byte, opts core.XPendingOptions) ([]core.StreamPendingEntry, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, _, _, err := getStreamGroup(snap, key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	now := core.NowMs()
+	res := []core.StreamPendingEntry{}
+	err = scanPending(snap, key, m, group, opts.Start, func(p pendingEntry) (bool, error) {
+		if len(res) >= opts.Count || p.id.Compare(opts.End) > 0 {
+			return false, nil
+		}
+		idle := now - p.deliveryTime
+		if opts.Consumer != nil && !bytes.Equal(p.consumer, opts.Consumer) || idle < opts.MinIdle {
+			return true, nil
+		}
+		res = append(res, core.StreamPendingEntry{
+			ID:         p.id,
+			Consumer:   p.consumer,
+			Idle:       idle,
+			Deliveries: p.deliveries,
+		})
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Store) XRANGE(key []byte, start, end core.StreamID, count int) ([]core.StreamEntry, error) {
+	return s.xrangeGeneric(key, start, end, count, false)
+}
+
+func (s *Store) XREAD(keys [][]byte, ids []core.StreamID, count int) ([]core.StreamEntries, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	res := []core.StreamEntries{}
+	for i, key := range keys {
+		m, _, ok, err := getStream(snap, key)
+		if err != nil {
+			return nil, err
+		}
+		start, more := ids[i].Next()
+		if !ok || !more {
+			continue
+		}
+
+		entries, err := streamEntries(snap, key, m, start, core.MaxStreamID, count, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			res = append(res, core.StreamEntries{Key: key, Entries: entries})
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) XREADGROUP(group, consumer []byte, keys [][]byte, ids []*core.StreamID, count int, noAck bool) ([]core.StreamEntries, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	metas := make([]meta, len(keys))
+	for i, key := range keys {
+		m, _, _, err := loadStreamGroup(b, key, group)
+		if err != nil {
+			return nil, err
+		}
+		metas[i] = m
+	}
+
+	now := core.NowMs()
+	res := []core.StreamEntries{}
+	for i, key := range keys {
+		m := metas[i]
+		if ids[i] != nil {
+			entries, err := readHistory(b, key, m, group, consumer, *ids[i], count, now)
+			if err != nil {
+				return nil, err
+			}
+			if err := touchConsumer(b, key, m, group, consumer, now, false); err != nil {
+				return nil, err
+			}
+			res = append(res, core.StreamEntries{Key: key, Entries: entries})
+			continue
+		}
+
+		// the group is loaded again, as keys might repeat.
+		_, sm, g, err := loadStreamGroup(b, key, group)
+		if err != nil {
+			return nil, err
+		}
+		entries := []core.StreamEntry{}
+		if start, ok := g.lastID.Next(); ok {
+			entries, err = streamEntries(b, key, m, start, core.MaxStreamID, count, false)
+			if err != nil {
+				return nil, err
+			}
+		}
+		counters, err := streamCounters(b, key, m, sm)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			g.lastID = e.ID
+			g.entriesRead = counters.NextEntriesRead(g.entriesRead, e.ID)
+			if noAck {
+				continue
+			}
+			// an entry pending already is reassigned, like Redis does.
+			p := pendingEntry{id: e.ID, consumer: consumer, deliveryTime: now, deliveries: 1}
+			if err := putPending(b, key, m, group, p); err != nil {
+				return nil, err
+			}
+		}
+		if len(entries) > 0 {
+			if err := putGroup(b, key, m, group, g); err != nil {
+				return nil, err
+			}
+		}
+		if err := touchConsumer(b, key, m, group, consumer, now, len(entries) > 0 && !noAck); err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			res = append(res, core.StreamEntries{Key: key, Entries: entries})
+		}
+	}
+
+	if err := b.Commit(s.syncOpt); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Store) XREVRANGE(key []byte, end, start core.StreamID, count int) ([]core.StreamEntry, error) {
+	return s.xrangeGeneric(key, start, end, count, true)
+}
+
+func (s *Store) XTRIM(key []byte, opts core.XTrimOptions) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, sm, ok, err := loadStream(b, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	n, err := trimStream(b, key, m, &sm, opts)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	if err := putStream(b, key, m, sm); err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *Store) xrangeGeneric(key []byte, start, end core.StreamID, count int, rev bool) ([]core.StreamEntry, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, _, ok, err := getStream(snap, key)
+	if err != nil || !ok || start.Compare(end) > 0 {
+		return []core.StreamEntry{}, err
+	}
+	return streamEntries(snap, key, m, start, end, count, rev)
+}
+
+// readHistory returns pending entries of the consumer with IDs greater than id, at most count if it's positive.
+// Delivery counters of returned entries are incremented, deleted entries are returned with nil fields.
+func readHistory(b *pebble.Batch, key []byte, m meta, group, consumer []byte, id core.StreamID, count int, now int64) ([]core.StreamEntry, error) {
+	res := []core.StreamEntry{}
+	next, ok := id.Next()
+	if !ok {
+		return res, nil
+	}
+
+	err := scanPending(b, key, m, group, next, func(p pendingEntry) (bool, error) {
+		if count > 0 && len(res) == count {
+			return false, nil
+		}
+		if !bytes.Equal(p.consumer, consumer) {
+			return true, nil
+		}
+		e, ok, err := getEntry(b, key, m, p.id)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			res = append(res, core.StreamEntry{ID: p.id})
+			return true, nil
+		}
+		p.deliveryTime = now
+		p.deliveries++
+		res = append(res, e)
+		return true, putPending(b, key, m, group, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// streamCounters returns counters of the stream, it's the meta with the ID of the first entry.
+func streamCounters(r pebble.Reader, key []byte, m meta, sm streamMeta) (core.StreamCounters, error) {
+	res := core.StreamCounters{
+		Len:          sm.len,
+		LastID:       sm.lastID,
+		MaxDeletedID: sm.maxDeletedID,
+		EntriesAdded: sm.entriesAdded,
+	}
+	first, err := streamEntries(r, key, m, core.StreamID{}, core.MaxStreamID, 1, false)
+	if err != nil {
+		return core.StreamCounters{}, err
+	}
+	if len(first) > 0 {
+		res.FirstID = first[0].ID
+	}
+	return res, nil
+}
+
+// getStream is like getMeta but fails for keys that are not streams.
+func getStream(r pebble.Reader, key []byte) (meta, streamMeta, bool, error) {
+	m, ok, err := getMeta(r, key)
+	return asStream(key, m, ok, err)
+}
+
+// loadStream is like loadMeta but fails for keys that are not streams.
+func loadStream(b *pebble.Batch, key []byte) (meta, streamMeta, bool, error) {
+	m, ok, err := loadMeta(b, key)
+	return asStream(key, m, ok, err)
+}
+
+func asStream(key []byte, m meta, ok bool, err error) (meta, streamMeta, bool, error) {
+	if err != nil || !ok {
+		return meta{}, streamMeta{}, false, err
+	}
+	if m.typ != core.TypeStream {
+		return meta{}, streamMeta{}, false, core.ErrWrongType
+	}
+	sm, err := decodeStreamMeta(m)
+	if err != nil {
+		return meta{}, streamMeta{}, false, fmt.Errorf("key %q: %w", key, err)
+	}
+	return m, sm, true, nil
+}
+
+// putStream writes stream meta, empty stream is kept.
+func putStream(b *pebble.Batch, key []byte, m meta, sm streamMeta) error {
+	m.payload = sm.encode()
+	return putMeta(b, key, m)
+}
+
+// trimStream evicts the oldest entries according to opts, sm is updated accordingly.
+// Returns the number of evicted entries.
+func trimStream(b *pebble.Batch, key []byte, m meta, sm *streamMeta, opts core.XTrimOptions) (int, error) {
+	if opts.Strategy == core.XTrimNone {
+		return 0, nil
+	}
+
+	prefix := dataKeyPrefix(key, m.version)
+	iter, err := b.NewIter(&pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: prefixEnd(prefix),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer tryClose(iter)
+
+	below := 0
+	if opts.Strategy == core.XTrimMinID {
+		minKey := entryKey(key, m, opts.MinID)
+		for iter.First(); iter.Valid() && bytes.Compare(iter.Key(), minKey) < 0; iter.Next() {
+			below++
+			if below == opts.Limit {
+				break
+			}
+		}
+		if err := iter.Error(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := opts.Evict(sm.len, below)
+	if n == 0 {
+		return 0, nil
+	}
+
+	// evicted entries are the first n ones, so they are removed with a single range deletion.
+	iter.First()
+	for i := 0; i < n && iter.Valid(); i++ {
+		iter.Next()
+	}
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+	end := prefixEnd(prefix)
+	if iter.Valid() {
+		end = bytes.Clone(iter.Key())
+	}
+	if err := b.DeleteRange(prefix, end, nil); err != nil {
+		return 0, err
+	}
+	sm.len -= n
+	return n, nil
+}
+
+// streamEntries returns entries with IDs between start and end (both inclusive),
+// at most count of them if it's positive, in reverse order if rev is set.
+func streamEntries(r pebble.Reader, key []byte, m meta, start, end core.StreamID, count int, rev bool) ([]core.StreamEntry, error) {
+	prefix := dataKeyPrefix(key, m.version)
+	upper := prefixEnd(prefix)
+	if next, ok := end.Next(); ok {
+		upper = entryKey(key, m, next)
+	}
+	iter, err := r.NewIter(&pebble.IterOptions{
+		LowerBound: entryKey(key, m, start),
+		UpperBound: upper,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tryClose(iter)
+
+	res := []core.StreamEntry{}
+	valid := iter.First()
+	if rev {
+		valid = iter.Last()
+	}
+	for ; valid; valid = stepIter(iter, rev) {
+		if count > 0 && len(res) == count {
+			break
+		}
+		e, err := decodeEntry(iter.Key()[len(prefix):], iter.Value())
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key, err)
+		}
+		res = append(res, e)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// getEntry returns the entry with id.
+func getEntry(r pebble.Reader, key []byte, m meta, id core.StreamID) (core.StreamEntry, bool, error) {
+	val, ok, err := getValue(r, entryKey(key, m, id))
+	if err != nil || !ok {
+		return core.StreamEntry{}, false, err
+	}
+	fields, err := decodeFields(val)
+	if err != nil {
+		return core.StreamEntry{}, false, fmt.Errorf("key %q: %w", key, err)
+	}
+	return core.StreamEntry{ID: id, Fields: fields}, true, nil
+}
+
+// getStreamGroup is like getStream but returns the group too, it fails for a missing stream or group.
+func getStreamGroup(r pebble.Reader, key, group []byte) (meta, streamMeta, streamGroup, error) {
+	m, sm, ok, err := getStream(r, key)
+	return asStreamGroup(r, key, group, m, sm, ok, err)
+}
+
+// loadStreamGroup is like loadStream but returns the group too, it fails for a missing stream or group.
+func loadStreamGroup(b *pebble.Batch, key, group []byte) (meta, streamMeta, streamGroup, error) {
+	m, sm, ok, err := loadStream(b, key)
+	return asStreamGroup(b, key, group, m, sm, ok, err)
+}
+
+func asStreamGroup(r pebble.Reader, key, group []byte, m meta, sm streamMeta, ok bool, err error) (meta, streamMeta, streamGroup, error) {
+	if err != nil {
+		return meta{}, streamMeta{}, streamGroup{}, err
+	}
+	if !ok {
+		return meta{}, streamMeta{}, streamGroup{}, core.ErrKeyNotFound
+	}
+	g, ok, err := getGroup(r, key, m, group)
+	if err != nil {
+		return meta{}, streamMeta{}, streamGroup{}, err
+	}
+	if !ok {
+		return meta{}, streamMeta{}, streamGroup{}, core.ErrNoGroup
+	}
+	return m, sm, g, nil
+}
+
+func getGroup(r pebble.Reader, key []byte, m meta, group []byte) (streamGroup, bool, error) {
+	val, ok, err := getValue(r, groupSubPrefix(key, m, groupRecord, group))
+	if err != nil || !ok {
+		return streamGroup{}, false, err
+	}
+	g, err := decodeStreamGroup(val)
+	if err != nil {
+		return streamGroup{}, false, fmt.Errorf("key %q: %w", key, err)
+	}
+	return g, true, nil
+}
+
+func putGroup(b *pebble.Batch, key []byte, m meta, group []byte, g streamGroup) error {
+	return b.Set(groupSubPrefix(key, m, groupRecord, group), g.encode(), nil)
+}
+
+// scanGroups calls fn for each group of the stream, groups are ordered by name length first.
+func scanGroups(r pebble.Reader, key []byte, m meta, fn func(name []byte, g streamGroup) error) error {
+	prefix := append(groupKeyPrefix(key, m.version), groupRecord)
+	iter, err := r.NewIter(&pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: prefixEnd(prefix),
+	})
+	if err != nil {
+		return err
+	}
+	defer tryClose(iter)
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		sub := iter.Key()[len(prefix):]
+		if len(sub) < 4 {
+			return fmt.Errorf("key %q: %w", key, errCorruptedGroup)
+		}
+		g, err := decodeStreamGroup(iter.Value())
+		if err != nil {
+			return fmt.Errorf("key %q: %w", key, err)
+		}
+		if err := fn(bytes.Clone(sub[4:]), g); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+func getConsumer(r pebble.Reader, key []byte, m meta, group, consumer []byte) (streamConsumer, bool, error) {
+	val, ok, err := getValue(r, consumerKey(key, m, group, consumer))
+	if err != nil || !ok {
+		return streamConsumer{}, false, err
+	}
+	c, err := decodeStreamConsumer(val)
+	if err != nil {
+		return streamConsumer{}, false, fmt.Errorf("key %q: %w", key, err)
+	}
+	return c, true, nil
+}
+
+// touchConsumer updates seen time of the consumer and active time if active is set, a missing consumer is created.
+func touchConsumer(b *pebble.Batch, key []byte, m meta, group, consumer []byte, now int64, active bool) error {
+	c, ok, err := getConsumer(b, key, m, group, consumer)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		c.activeTime = -1
+	}
+	c.seenTime = now
+	if active {
+		c.activeTime = now
+	}
+	return b.Set(consumerKey(key, m, group, consumer), c.encode(), nil)
+}
+
+func getPending(r pebble.Reader, key []byte, m meta, group []byte, id core.StreamID) (pendingEntry, bool, error) {
+	val, ok, err := getValue(r, pendingKey(key, m, group, id))
+	if err != nil || !ok {
+		return pendingEntry{}, false, err
+	}
+	p, err := decodePendingEntry(appendStreamID(nil, id), val)
+	if err != nil {
+		return pendingEntry{}, false, fmt.Errorf("key %q: %w", key, err)
+	}
+	return p, true, nil
+}
+
+func putPending(b *pebble.Batch, key []byte, m meta, group []byte, p pendingEntry) error {
+	return b.Set(pendingKey(key, m, group, p.id), p.encode(), nil)
+}
+
+// scanPending calls fn for pending entries of the group with IDs not less than start in ID order,
+// until fn returns false or an error. fn may modify or delete the current entry.
+func scanPending(r pebble.Reader, key []byte, m meta, group []byte, start core.StreamID, fn func(p pendingEntry) (bool, error)) error {
+	prefix := groupSubPrefix(key, m, pendingRecord, group)
+	iter, err := r.NewIter(&pebble.IterOptions{
+		LowerBound: appendStreamID(bytes.Clone(prefix), start),
+		UpperBound: prefixEnd(prefix),
+	})
+	if err != nil {
+		return err
+	}
+	defer tryClose(iter)
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		p, err := decodePendingEntry(iter.Key()[len(prefix):], iter.Value())
+		if err != nil {
+			return fmt.Errorf("key %q: %w", key, err)
+		}
+		more, err := fn(p)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// countRange returns the number of keys with the prefix.
+func countRange(r pebble.Reader, prefix []byte) (int, error) {
+	iter, err := r.NewIter(&pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: prefixEnd(prefix),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer tryClose(iter)
+
+	n := 0
+	for iter.First(); iter.Valid(); iter.Next() {
+		n++
+	}
+	return n, iter.Error()
+}
+
+// groupSubPrefix returns the key of the group record of the kind, consumer and pending records append to it.
+func groupSubPrefix(key []byte, m meta, kind byte, group []byte) []byte {
+	res := append(groupKeyPrefix(key, m.version), kind)
+	res = binary.BigEndian.AppendUint32(res, uint32(len(group)))
+	return append(res, group...)
+}
+
+func consumerKey(key []byte, m meta, group, consumer []byte) []byte {
+	return append(groupSubPrefix(key, m, consumerRecord, group), consumer...)
+}
+
+func pendingKey(key []byte, m meta, group []byte, id core.StreamID) []byte {
+	return appendStreamID(groupSubPrefix(key, m, pendingRecord, group), id)
+}
+
+func stepIter(iter *pebble.Iterator, rev bool) bool {
+	if rev {
+		return iter.Prev()
+	}
+	return iter.Next()
+}
+
+func entryKey(key []byte, m meta, id core.StreamID) []byte {
+	return appendStreamID(dataKeyPrefix(key, m.version), id)
+}
+
+func appendStreamID(b []byte, id core.StreamID) []byte {
+	b = binary.BigEndian.AppendUint64(b, id.Ms)
+	return binary.BigEndian.AppendUint64(b, id.Seq)
+}
+
+func decodeStreamID(b []byte) core.StreamID {
+	return core.StreamID{
+		Ms:  binary.BigEndian.Uint64(b),
+		Seq: binary.BigEndian.Uint64(b[8:]),
+	}
+}
+
+// decodeEntry decodes entry from the ID part of its key and its value, the result doesn't refer to them.
+func decodeEntry(sub, val []byte) (core.StreamEntry, error) {
+	if len(sub) != 16 {
+		return core.StreamEntry{}, errCorruptedEntry
+	}
+	fields, err := decodeFields(val)
+	if err != nil {
+		return core.StreamEntry{}, err
+	}
+	return core.StreamEntry{ID: decodeStreamID(sub), Fields: fields}, nil
+}
+
+// encodeFields encodes fields as their number followed by length-prefixed fields.
+func encodeFields(fields [][]byte) []byte {
+	size := binary.MaxVarintLen64
+	for _, f := range fields {
+		size += binary.MaxVarintLen64 + len(f)
+	}
+	res := make([]byte, 0, size)
+	res = binary.AppendUvarint(res, uint64(len(fields)))
+	for _, f := range fields {
+		res = binary.AppendUvarint(res, uint64(len(f)))
+		res = append(res, f...)
+	}
+	return res
+}
+
+func decodeFields(val []byte) ([][]byte, error) {
+	n, k := binary.Uvarint(val)
+	if k <= 0 || n > uint64(len(val)) {
+		return nil, errCorruptedEntry
+	}
+	val = val[k:]
+
+	res := make([][]byte, 0, n)
+	for i := uint64(0); i < n; i++ {
+		size, k := binary.Uvarint(val)
+		if k <= 0 || size > uint64(len(val)-k) {
+			return nil, errCorruptedEntry
+		}
+		res = append(res, bytes.Clone(val[k:k+int(size)]))
+		val = val[k+int(size):]
+	}
+	if len(val) != 0 {
+		return nil, errCorruptedEntry
+	}
+	return res, nil
+}
@@ -0,0 +1,858 @@
+package ondisk
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cristalhq/testt"
+)
+
+func TestXADD(t *testing.T) {
+	/*
+		redis> XADD mystream 1526919030474-55 name Sara surname OConnor
+		"1526919030474-55"
+		redis> XADD mystream 1526919030474-* field1 value1
+		"1526919030474-56"
+		redis> XADD mystream 1526919030474-56 field1 value1
+		(error) ERR The ID specified in XADD is equal or smaller than the target stream top item
+		redis> XADD mystream 0-0 field1 value1
+		(error) ERR The ID specified in XADD must be greater than 0-0
+		redis> XLEN mystream
+		(integer) 2
+		redis> XRANGE mystream - +
+		1) 1) "1526919030474-55"
+		   2) 1) "name"
+		      2) "Sara"
+		      3) "surname"
+		      4) "OConnor"
+		2) 1) "1526919030474-56"
+		   2) 1) "field1"
+		      2) "value1"
+		redis>
+	*/
+
+	mystream := []byte("mystream")
+
+	s := newStore(t)
+	id, ok, err := s.XADD(mystream, core.XAddOptions{ID: core.StreamID{Ms: 1526919030474, Seq: 55}},
+		[]byte("name"), []byte("Sara"), []byte("surname"), []byte("OConnor"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+	testt.MustEqual(t, id, core.StreamID{Ms: 1526919030474, Seq: 55})
+
+	id, _, err = s.XADD(mystream, core.XAddOptions{ID: core.StreamID{Ms: 1526919030474}, AutoSeq: true},
+		[]byte("field1"), []byte("value1"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, id, core.StreamID{Ms: 1526919030474, Seq: 56})
+
+	_, _, err = s.XADD(mystream, core.XAddOptions{ID: core.StreamID{Ms: 1526919030474, Seq: 56}},
+		[]byte("field1"), []byte("value1"))
+	testt.MustEqual(t, err, core.ErrStreamIDTooSmall)
+
+	_, _, err = s.XADD(mystream, core.XAddOptions{}, []byte("field1"), []byte("value1"))
+	testt.MustEqual(t, err, core.ErrStreamIDZero)
+
+	n, err := s.XLEN(mystream)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err := s.XRANGE(mystream, core.StreamID{}, core.MaxStreamID, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntry{
+		{
+			ID:     core.StreamID{Ms: 1526919030474, Seq: 55},
+			Fields: [][]byte{[]byte("name"), []byte("Sara"), []byte("surname"), []byte("OConnor")},
+		},
+		{
+			ID:     core.StreamID{Ms: 1526919030474, Seq: 56},
+			Fields: [][]byte{[]byte("field1"), []byte("value1")},
+		},
+	})
+
+	// auto generated ID is greater than the last one even if the clock is behind.
+	id, _, err = s.XADD(mystream, core.XAddOptions{AutoID: true}, []byte("a"), []byte("b"))
+	testt.NoError(t, err)
+	if id.Compare(core.StreamID{Ms: 1526919030474, Seq: 56}) <= 0 {
+		t.Fatalf("got %v", id)
+	}
+
+	typ, err := s.TYPE(mystream)
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "stream")
+
+	_, ok, err = s.XADD([]byte("missing"), core.XAddOptions{AutoID: true, NoMkStream: true}, []byte("a"), []byte("b"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	exists, err := s.EXISTS([]byte("missing"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, exists, 0)
+
+	_, _, err = s.SET([]byte("str"), []byte("v"), core.SetOptions{})
+	testt.NoError(t, err)
+	_, _, err = s.XADD([]byte("str"), core.XAddOptions{AutoID: true}, []byte("a"), []byte("b"))
+	testt.MustEqual(t, err, core.ErrWrongType)
+}
+
+func TestXDEL(t *testing.T) {
+	/*
+		redis> XADD mystream 1538561700640-0 a 1
+		"1538561700640-0"
+		redis> XADD mystream 1538561700641-0 b 2
+		"1538561700641-0"
+		redis> XADD mystream 1538561700642-0 c 3
+		"1538561700642-0"
+		redis> XDEL mystream 1538561700641-0
+		(integer) 1
+		redis> XRANGE mystream - +
+		1) 1) "1538561700640-0"
+		   2) 1) "a"
+		      2) "1"
+		2) 1) "1538561700642-0"
+		   2) 1) "c"
+		      2) "3"
+		redis>
+	*/
+
+	mystream := []byte("mystream")
+
+	s := newStore(t)
+	for i, field := range []string{"a", "b", "c"} {
+		_, _, err := s.XADD(mystream, core.XAddOptions{ID: core.StreamID{Ms: 1538561700640 + uint64(i)}},
+			[]byte(field), []byte(strconv.Itoa(i+1)))
+		testt.NoError(t, err)
+	}
+
+	n, err := s.XDEL(mystream, core.StreamID{Ms: 1538561700641}, core.StreamID{Ms: 1538561700641}, core.StreamID{Ms: 1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	res, err := s.XRANGE(mystream, core.StreamID{}, core.MaxStreamID, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntry{
+		{ID: core.StreamID{Ms: 1538561700640}, Fields: [][]byte{[]byte("a"), []byte("1")}},
+		{ID: core.StreamID{Ms: 1538561700642}, Fields: [][]byte{[]byte("c"), []byte("3")}},
+	})
+
+	// empty stream is kept and its last ID is remembered.
+	n, err = s.XDEL(mystream, core.StreamID{Ms: 1538561700640}, core.StreamID{Ms: 1538561700642})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	exists, err := s.EXISTS(mystream)
+	testt.NoError(t, err)
+	testt.MustEqual(t, exists, 1)
+
+	_, _, err = s.XADD(mystream, core.XAddOptions{ID: core.StreamID{Ms: 1538561700642}}, []byte("d"), []byte("4"))
+	testt.MustEqual(t, err, core.ErrStreamIDTooSmall)
+}
+
+func TestXRANGE(t *testing.T) {
+	/*
+		redis> XADD writers 1-0 name Virginia surname Woolf
+		"1-0"
+		redis> XADD writers 1-1 name Jane surname Austen
+		"1-1"
+		redis> XADD writers 2-0 name Toni surname Morrison
+		"2-0"
+		redis> XADD writers 3-0 name Agatha surname Christie
+		"3-0"
+		redis> XADD writers 3-1 name Ngozi surname Adichie
+		"3-1"
+		redis> XLEN writers
+		(integer) 5
+		redis> XRANGE writers - + COUNT 2
+		1) 1) "1-0"
+		   2) 1) "name"
+		      2) "Virginia"
+		      3) "surname"
+		      4) "Woolf"
+		2) 1) "1-1"
+		   2) 1) "name"
+		      2) "Jane"
+		      3) "surname"
+		      4) "Austen"
+		redis>
+	*/
+
+	writers := []byte("writers")
+	entries := []core.StreamEntry{
+		{ID: core.StreamID{Ms: 1, Seq: 0}, Fields: [][]byte{[]byte("name"), []byte("Virginia"), []byte("surname"), []byte("Woolf")}},
+		{ID: core.StreamID{Ms: 1, Seq: 1}, Fields: [][]byte{[]byte("name"), []byte("Jane"), []byte("surname"), []byte("Austen")}},
+		{ID: core.StreamID{Ms: 2, Seq: 0}, Fields: [][]byte{[]byte("name"), []byte("Toni"), []byte("surname"), []byte("Morrison")}},
+		{ID: core.StreamID{Ms: 3, Seq: 0}, Fields: [][]byte{[]byte("name"), []byte("Agatha"), []byte("surname"), []byte("Christie")}},
+		{ID: core.StreamID{Ms: 3, Seq: 1}, Fields: [][]byte{[]byte("name"), []byte("Ngozi"), []byte("surname"), []byte("Adichie")}},
+	}
+
+	s := newStore(t)
+	for _, e := range entries {
+		_, _, err := s.XADD(writers, core.XAddOptions{ID: e.ID}, e.Fields...)
+		testt.NoError(t, err)
+	}
+
+	n, err := s.XLEN(writers)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 5)
+
+	res, err := s.XRANGE(writers, core.StreamID{}, core.MaxStreamID, 2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, entries[:2])
+
+	res, err = s.XRANGE(writers, core.StreamID{Ms: 1, Seq: 1}, core.StreamID{Ms: 3, Seq: 0}, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, entries[1:4])
+
+	res, err = s.XRANGE(writers, core.StreamID{Ms: 3}, core.StreamID{Ms: 1}, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntry{})
+
+	res, err = s.XREVRANGE(writers, core.MaxStreamID, core.StreamID{}, 2)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntry{entries[4], entries[3]})
+
+	res, err = s.XREVRANGE(writers, core.StreamID{Ms: 2, Seq: 0}, core.StreamID{Ms: 1, Seq: 1}, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntry{entries[2], entries[1]})
+
+	res, err = s.XRANGE([]byte("missing"), core.StreamID{}, core.MaxStreamID, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntry{})
+}
+
+func TestXREAD(t *testing.T) {
+	/*
+		redis> XADD mystream 1-0 a 1
+		"1-0"
+		redis> XADD mystream 2-0 b 2
+		"2-0"
+		redis> XADD otherstream 1-5 c 3
+		"1-5"
+		redis> XREAD COUNT 1 STREAMS mystream otherstream missing 0 1-4 0
+		1) 1) "mystream"
+		   2) 1) 1) "1-0"
+		         2) 1) "a"
+		            2) "1"
+		2) 1) "otherstream"
+		   2) 1) 1) "1-5"
+		         2) 1) "c"
+		            2) "3"
+		redis> XREAD STREAMS mystream otherstream 1-0 1-5
+		1) 1) "mystream"
+		   2) 1) 1) "2-0"
+		         2) 1) "b"
+		            2) "2"
+		redis>
+	*/
+
+	mystream, otherstream := []byte("mystream"), []byte("otherstream")
+
+	s := newStore(t)
+	_, _, err := s.XADD(mystream, core.XAddOptions{ID: core.StreamID{Ms: 1}}, []byte("a"), []byte("1"))
+	testt.NoError(t, err)
+	_, _, err = s.XADD(mystream, core.XAddOptions{ID: core.StreamID{Ms: 2}}, []byte("b"), []byte("2"))
+	testt.NoError(t, err)
+	_, _, err = s.XADD(otherstream, core.XAddOptions{ID: core.StreamID{Ms: 1, Seq: 5}}, []byte("c"), []byte("3"))
+	testt.NoError(t, err)
+
+	res, err := s.XREAD([][]byte{mystream, otherstream, []byte("missing")},
+		[]core.StreamID{{}, {Ms: 1, Seq: 4}, {}}, 1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntries{
+		{Key: mystream, Entries: []core.StreamEntry{{ID: core.StreamID{Ms: 1}, Fields: [][]byte{[]byte("a"), []byte("1")}}}},
+		{Key: otherstream, Entries: []core.StreamEntry{{ID: core.StreamID{Ms: 1, Seq: 5}, Fields: [][]byte{[]byte("c"), []byte("3")}}}},
+	})
+
+	res, err = s.XREAD([][]byte{mystream, otherstream}, []core.StreamID{{Ms: 1}, {Ms: 1, Seq: 5}}, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntries{
+		{Key: mystream, Entries: []core.StreamEntry{{ID: core.StreamID{Ms: 2}, Fields: [][]byte{[]byte("b"), []byte("2")}}}},
+	})
+
+	res, err = s.XREAD([][]byte{mystream}, []core.StreamID{core.MaxStreamID}, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntries{})
+}
+
+func TestXTRIM(t *testing.T) {
+	/*
+		redis> XADD mystream 1-0 field1 A
+		"1-0"
+		redis> XADD mystream 2-0 field2 B
+		"2-0"
+		redis> XADD mystream 3-0 field3 C
+		"3-0"
+		redis> XADD mystream 4-0 field4 D
+		"4-0"
+		redis> XTRIM mystream MAXLEN 2
+		(integer) 2
+		redis> XRANGE mystream - +
+		1) 1) "3-0"
+		   2) 1) "field3"
+		      2) "C"
+		2) 1) "4-0"
+		   2) 1) "field4"
+		      2) "D"
+		redis>
+	*/
+
+	mystream := []byte("mystream")
+
+	s := newStore(t)
+	for i, field := range []string{"field1", "field2", "field3", "field4"} {
+		_, _, err := s.XADD(mystream, core.XAddOptions{ID: core.StreamID{Ms: uint64(i + 1)}}, []byte(field), []byte{'A' + byte(i)})
+		testt.NoError(t, err)
+	}
+
+	n, err := s.XTRIM(mystream, core.XTrimOptions{Strategy: core.XTrimMaxLen, MaxLen: 2})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+
+	res, err := s.XRANGE(mystream, core.StreamID{}, core.MaxStreamID, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntry{
+		{ID: core.StreamID{Ms: 3}, Fields: [][]byte{[]byte("field3"), []byte("C")}},
+		{ID: core.StreamID{Ms: 4}, Fields: [][]byte{[]byte("field4"), []byte("D")}},
+	})
+
+	n, err = s.XTRIM(mystream, core.XTrimOptions{Strategy: core.XTrimMinID, MinID: core.StreamID{Ms: 4}})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.XTRIM(mystream, core.XTrimOptions{Strategy: core.XTrimMaxLen})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+
+	n, err = s.XLEN(mystream)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	n, err = s.XTRIM([]byte("missing"), core.XTrimOptions{Strategy: core.XTrimMaxLen})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+}
+
+func TestXTRIMApprox(t *testing.T) {
+	mystream := []byte("mystream")
+
+	s := newStore(t)
+	for i := 0; i < 250; i++ {
+		_, _, err := s.XADD(mystream, core.XAddOptions{AutoID: true}, []byte("i"), []byte(strconv.Itoa(i)))
+		testt.NoError(t, err)
+	}
+
+	// only whole nodes of entries are evicted.
+	n, err := s.XTRIM(mystream, core.XTrimOptions{Strategy: core.XTrimMaxLen, MaxLen: 10, Approx: true})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 200)
+
+	n, err = s.XTRIM(mystream, core.XTrimOptions{Strategy: core.XTrimMaxLen, MaxLen: 10, Approx: true})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	for i := 250; i < 400; i++ {
+		_, _, err := s.XADD(mystream, core.XAddOptions{AutoID: true}, []byte("i"), []byte(strconv.Itoa(i)))
+		testt.NoError(t, err)
+	}
+
+	n, err = s.XTRIM(mystream, core.XTrimOptions{Strategy: core.XTrimMaxLen, Approx: true, Limit: 150})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 100)
+
+	res, err := s.XRANGE(mystream, core.StreamID{}, core.MaxStreamID, 1)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res[0].Fields, [][]byte{[]byte("i"), []byte("300")})
+
+	// trimming in XADD.
+	last, err := s.XREVRANGE(mystream, core.MaxStreamID, core.StreamID{}, 1)
+	testt.NoError(t, err)
+	minID := last[0].ID
+
+	_, _, err = s.XADD(mystream, core.XAddOptions{
+		AutoID: true,
+		Trim:   core.XTrimOptions{Strategy: core.XTrimMinID, MinID: minID},
+	}, []byte("i"), []byte("400"))
+	testt.NoError(t, err)
+
+	n, err = s.XLEN(mystream)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 2)
+}
+
+func TestXGROUP(t *testing.T) {
+	/*
+		redis> XGROUP CREATE mystream mygroup $
+		(error) ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.
+		redis> XGROUP CREATE mystream mygroup $ MKSTREAM
+		OK
+		redis> XGROUP CREATE mystream mygroup $
+		(error) BUSYGROUP Consumer Group name already exists
+		redis> XGROUP CREATECONSUMER mystream mygroup Alice
+		(integer) 1
+		redis> XGROUP CREATECONSUMER mystream mygroup Alice
+		(integer) 0
+		redis> XGROUP DESTROY mystream mygroup
+		(integer) 1
+		redis> XGROUP DESTROY mystream mygroup
+		(integer) 0
+	*/
+
+	mystream, mygroup, alice := []byte("mystream"), []byte("mygroup"), []byte("Alice")
+
+	s := newStore(t)
+	err := s.XGROUPCREATE(mystream, mygroup, core.XGroupIDOptions{LastID: true, EntriesRead: -1}, false)
+	testt.MustEqual(t, err, core.ErrKeyNotFound)
+
+	err = s.XGROUPCREATE(mystream, mygroup, core.XGroupIDOptions{LastID: true, EntriesRead: -1}, true)
+	testt.NoError(t, err)
+	err = s.XGROUPCREATE(mystream, mygroup, core.XGroupIDOptions{LastID: true, EntriesRead: -1}, true)
+	testt.MustEqual(t, err, core.ErrBusyGroup)
+
+	ok, err := s.XGROUPCREATECONSUMER(mystream, mygroup, alice)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+	ok, err = s.XGROUPCREATECONSUMER(mystream, mygroup, alice)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	_, err = s.XGROUPCREATECONSUMER(mystream, []byte("other"), alice)
+	testt.MustEqual(t, err, core.ErrNoGroup)
+
+	groups, err := s.XINFOGROUPS(mystream)
+	testt.NoError(t, err)
+	testt.MustEqual(t, groups, []core.StreamGroupInfo{
+		{Name: mygroup, Consumers: 1, EntriesRead: -1, Lag: 0},
+	})
+
+	for i := 1; i <= 2; i++ {
+		_, _, err := s.XADD(mystream, core.XAddOptions{ID: core.StreamID{Ms: uint64(i)}}, []byte("a"), []byte("1"))
+		testt.NoError(t, err)
+	}
+	groups, err = s.XINFOGROUPS(mystream)
+	testt.NoError(t, err)
+	testt.MustEqual(t, groups[0].Lag, int64(2))
+
+	err = s.XGROUPSETID(mystream, mygroup, core.XGroupIDOptions{LastID: true, EntriesRead: -1})
+	testt.NoError(t, err)
+	groups, err = s.XINFOGROUPS(mystream)
+	testt.NoError(t, err)
+	testt.MustEqual(t, groups[0].LastDeliveredID, core.StreamID{Ms: 2})
+	testt.MustEqual(t, groups[0].Lag, int64(0))
+
+	n, err := s.XGROUPDELCONSUMER(mystream, mygroup, alice)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	ok, err = s.XGROUPDESTROY(mystream, mygroup)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+	ok, err = s.XGROUPDESTROY(mystream, mygroup)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	err = s.XGROUPSETID(mystream, mygroup, core.XGroupIDOptions{})
+	testt.MustEqual(t, err, core.ErrNoGroup)
+}
+
+func TestXREADGROUP(t *testing.T) {
+	/*
+		redis> XADD mystream 1-0 a 1
+		"1-0"
+		redis> XADD mystream 2-0 b 2
+		"2-0"
+		redis> XGROUP CREATE mystream mygroup 0
+		OK
+		redis> XREADGROUP GROUP mygroup Alice COUNT 1 STREAMS mystream >
+		1) 1) "mystream"
+		   2) 1) 1) "1-0"
+		         2) 1) "a"
+		            2) "1"
+		redis> XREADGROUP GROUP mygroup Bob STREAMS mystream >
+		1) 1) "mystream"
+		   2) 1) 1) "2-0"
+		         2) 1) "b"
+		            2) "2"
+		redis> XPENDING mystream mygroup
+		1) (integer) 2
+		2) "1-0"
+		3) "2-0"
+		4) 1) 1) "Alice"
+		      2) "1"
+		   2) 1) "Bob"
+		      2) "1"
+		redis> XACK mystream mygroup 1-0
+		(integer) 1
+		redis> XDEL mystream 2-0
+		(integer) 1
+		redis> XREADGROUP GROUP mygroup Bob STREAMS mystream 0
+		1) 1) "mystream"
+		   2) 1) 1) "2-0"
+		         2) (nil)
+	*/
+
+	mystream, mygroup := []byte("mystream"), []byte("mygroup")
+	alice, bob := []byte("Alice"), []byte("Bob")
+	entry1 := core.StreamEntry{ID: core.StreamID{Ms: 1}, Fields: [][]byte{[]byte("a"), []byte("1")}}
+	entry2 := core.StreamEntry{ID: core.StreamID{Ms: 2}, Fields: [][]byte{[]byte("b"), []byte("2")}}
+
+	s := newStore(t)
+	_, _, err := s.XADD(mystream, core.XAddOptions{ID: entry1.ID}, entry1.Fields...)
+	testt.NoError(t, err)
+	_, _, err = s.XADD(mystream, core.XAddOptions{ID: entry2.ID}, entry2.Fields...)
+	testt.NoError(t, err)
+
+	_, err = s.XREADGROUP(mygroup, alice, [][]byte{mystream}, []*core.StreamID{nil}, 0, false)
+	testt.MustEqual(t, err, core.ErrNoGroup)
+
+	err = s.XGROUPCREATE(mystream, mygroup, core.XGroupIDOptions{EntriesRead: -1}, false)
+	testt.NoError(t, err)
+
+	res, err := s.XREADGROUP(mygroup, alice, [][]byte{mystream}, []*core.StreamID{nil}, 1, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntries{{Key: mystream, Entries: []core.StreamEntry{entry1}}})
+
+	res, err = s.XREADGROUP(mygroup, bob, [][]byte{mystream}, []*core.StreamID{nil}, 0, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntries{{Key: mystream, Entries: []core.StreamEntry{entry2}}})
+
+	// nothing new is left.
+	res, err = s.XREADGROUP(mygroup, bob, [][]byte{mystream}, []*core.StreamID{nil}, 0, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntries{})
+
+	summary, err := s.XPENDING(mystream, mygroup)
+	testt.NoError(t, err)
+	testt.MustEqual(t, summary, core.StreamPendingSummary{
+		Count:     2,
+		First:     entry1.ID,
+		Last:      entry2.ID,
+		Consumers: []core.StreamConsumerPending{{Name: alice, Count: 1}, {Name: bob, Count: 1}},
+	})
+
+	// history of the consumer increments delivery count.
+	res, err = s.XREADGROUP(mygroup, alice, [][]byte{mystream}, []*core.StreamID{{}}, 0, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntries{{Key: mystream, Entries: []core.StreamEntry{entry1}}})
+
+	pending, err := s.XPENDINGRANGE(mystream, mygroup, core.XPendingOptions{End: core.MaxStreamID, Count: 10, Consumer: alice})
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(pending), 1)
+	testt.MustEqual(t, pending[0].ID, entry1.ID)
+	testt.MustEqual(t, pending[0].Deliveries, int64(2))
+
+	n, err := s.XACK(mystream, mygroup, entry1.ID, core.StreamID{Ms: 5})
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+	n, err = s.XACK(mystream, []byte("other"), entry2.ID)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 0)
+
+	// deleted entries are still pending, they are returned without fields.
+	_, err = s.XDEL(mystream, entry2.ID)
+	testt.NoError(t, err)
+	res, err = s.XREADGROUP(mygroup, bob, [][]byte{mystream}, []*core.StreamID{{}}, 0, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntries{{Key: mystream, Entries: []core.StreamEntry{{ID: entry2.ID}}}})
+
+	// entries read with NOACK are not pending.
+	entry3 := core.StreamEntry{ID: core.StreamID{Ms: 3}, Fields: [][]byte{[]byte("c"), []byte("3")}}
+	_, _, err = s.XADD(mystream, core.XAddOptions{ID: entry3.ID}, entry3.Fields...)
+	testt.NoError(t, err)
+	res, err = s.XREADGROUP(mygroup, alice, [][]byte{mystream}, []*core.StreamID{nil}, 0, true)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntries{{Key: mystream, Entries: []core.StreamEntry{entry3}}})
+
+	summary, err = s.XPENDING(mystream, mygroup)
+	testt.NoError(t, err)
+	testt.MustEqual(t, summary.Count, 1)
+
+	groups, err := s.XINFOGROUPS(mystream)
+	testt.NoError(t, err)
+	testt.MustEqual(t, groups, []core.StreamGroupInfo{
+		{Name: mygroup, Consumers: 2, Pending: 1, LastDeliveredID: entry3.ID, EntriesRead: 3, Lag: 0},
+	})
+
+	consumers, err := s.XINFOCONSUMERS(mystream, mygroup)
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(consumers), 2)
+	testt.MustEqual(t, consumers[0].Name, alice)
+	testt.MustEqual(t, consumers[0].Pending, 0)
+	testt.MustEqual(t, consumers[1].Name, bob)
+	testt.MustEqual(t, consumers[1].Pending, 1)
+
+	n, err = s.XGROUPDELCONSUMER(mystream, mygroup, bob)
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, 1)
+}
+
+func TestXCLAIM(t *testing.T) {
+	mystream, mygroup := []byte("mystream"), []byte("mygroup")
+	alice, bob := []byte("Alice"), []byte("Bob")
+
+	s := newStore(t)
+	for i := 1; i <= 3; i++ {
+		_, _, err := s.XADD(mystream, core.XAddOptions{ID: core.StreamID{Ms: uint64(i)}}, []byte("i"), []byte(strconv.Itoa(i)))
+		testt.NoError(t, err)
+	}
+	err := s.XGROUPCREATE(mystream, mygroup, core.XGroupIDOptions{EntriesRead: -1}, false)
+	testt.NoError(t, err)
+	_, err = s.XREADGROUP(mygroup, alice, [][]byte{mystream}, []*core.StreamID{nil}, 0, false)
+	testt.NoError(t, err)
+
+	deliveries := func(id core.StreamID) (string, int64) {
+		pending, err := s.XPENDINGRANGE(mystream, mygroup, core.XPendingOptions{Start: id, End: id, Count: 1})
+		testt.NoError(t, err)
+		if len(pending) == 0 {
+			return "", 0
+		}
+		return string(pending[0].Consumer), pending[0].Deliveries
+	}
+
+	res, err := s.XCLAIM(mystream, mygroup, bob, 0, []core.StreamID{{Ms: 1}}, core.XClaimOptions{RetryCount: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntry{{ID: core.StreamID{Ms: 1}, Fields: [][]byte{[]byte("i"), []byte("1")}}})
+	consumer, n := deliveries(core.StreamID{Ms: 1})
+	testt.MustEqual(t, consumer, "Bob")
+	testt.MustEqual(t, n, int64(2))
+
+	// entries are claimed only if they are idle long enough.
+	res, err = s.XCLAIM(mystream, mygroup, bob, 3600000, []core.StreamID{{Ms: 2}}, core.XClaimOptions{RetryCount: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntry{})
+
+	res, err = s.XCLAIM(mystream, mygroup, bob, 0, []core.StreamID{{Ms: 2}}, core.XClaimOptions{RetryCount: -1, JustID: true})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntry{{ID: core.StreamID{Ms: 2}}})
+	consumer, n = deliveries(core.StreamID{Ms: 2})
+	testt.MustEqual(t, consumer, "Bob")
+	testt.MustEqual(t, n, int64(1))
+
+	_, err = s.XCLAIM(mystream, mygroup, bob, 0, []core.StreamID{{Ms: 3}}, core.XClaimOptions{RetryCount: 5})
+	testt.NoError(t, err)
+	_, n = deliveries(core.StreamID{Ms: 3})
+	testt.MustEqual(t, n, int64(5))
+
+	// claiming a deleted entry removes it from pending entries.
+	_, err = s.XDEL(mystream, core.StreamID{Ms: 3})
+	testt.NoError(t, err)
+	res, err = s.XCLAIM(mystream, mygroup, bob, 0, []core.StreamID{{Ms: 3}}, core.XClaimOptions{RetryCount: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntry{})
+	consumer, _ = deliveries(core.StreamID{Ms: 3})
+	testt.MustEqual(t, consumer, "")
+
+	// entries that were never delivered are claimed only with FORCE.
+	_, _, err = s.XADD(mystream, core.XAddOptions{ID: core.StreamID{Ms: 4}}, []byte("i"), []byte("4"))
+	testt.NoError(t, err)
+	res, err = s.XCLAIM(mystream, mygroup, bob, 0, []core.StreamID{{Ms: 4}}, core.XClaimOptions{RetryCount: -1})
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntry{})
+	res, err = s.XCLAIM(mystream, mygroup, bob, 0, []core.StreamID{{Ms: 4}}, core.XClaimOptions{RetryCount: -1, Force: true, LastID: core.StreamID{Ms: 4}})
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 1)
+	consumer, n = deliveries(core.StreamID{Ms: 4})
+	testt.MustEqual(t, consumer, "Bob")
+	testt.MustEqual(t, n, int64(2))
+
+	groups, err := s.XINFOGROUPS(mystream)
+	testt.NoError(t, err)
+	testt.MustEqual(t, groups[0].LastDeliveredID, core.StreamID{Ms: 4})
+	testt.MustEqual(t, groups[0].Pending, 3)
+
+	_, err = s.XCLAIM(mystream, []byte("other"), bob, 0, []core.StreamID{{Ms: 4}}, core.XClaimOptions{RetryCount: -1})
+	testt.MustEqual(t, err, core.ErrNoGroup)
+}
+
+func TestXAUTOCLAIM(t *testing.T) {
+	mystream, mygroup := []byte("mystream"), []byte("mygroup")
+	alice, bob := []byte("Alice"), []byte("Bob")
+
+	s := newStore(t)
+	for i := 1; i <= 5; i++ {
+		_, _, err := s.XADD(mystream, core.XAddOptions{ID: core.StreamID{Ms: uint64(i)}}, []byte("i"), []byte(strconv.Itoa(i)))
+		testt.NoError(t, err)
+	}
+	err := s.XGROUPCREATE(mystream, mygroup, core.XGroupIDOptions{EntriesRead: -1}, false)
+	testt.NoError(t, err)
+	_, err = s.XREADGROUP(mygroup, alice, [][]byte{mystream}, []*core.StreamID{nil}, 0, false)
+	testt.NoError(t, err)
+	_, err = s.XDEL(mystream, core.StreamID{Ms: 2})
+	testt.NoError(t, err)
+
+	// deleted entries count towards the limit.
+	next, claimed, deleted, err := s.XAUTOCLAIM(mystream, mygroup, bob, 0, core.StreamID{}, 2, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, next, core.StreamID{Ms: 3})
+	testt.MustEqual(t, claimed, []core.StreamEntry{{ID: core.StreamID{Ms: 1}, Fields: [][]byte{[]byte("i"), []byte("1")}}})
+	testt.MustEqual(t, deleted, []core.StreamID{{Ms: 2}})
+
+	next, claimed, deleted, err = s.XAUTOCLAIM(mystream, mygroup, bob, 0, next, 10, true)
+	testt.NoError(t, err)
+	testt.MustEqual(t, next, core.StreamID{})
+	testt.MustEqual(t, claimed, []core.StreamEntry{{ID: core.StreamID{Ms: 3}}, {ID: core.StreamID{Ms: 4}}, {ID: core.StreamID{Ms: 5}}})
+	testt.MustEqual(t, deleted, []core.StreamID{})
+
+	next, claimed, _, err = s.XAUTOCLAIM(mystream, mygroup, alice, 3600000, core.StreamID{}, 10, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, next, core.StreamID{})
+	testt.MustEqual(t, claimed, []core.StreamEntry{})
+
+	summary, err := s.XPENDING(mystream, mygroup)
+	testt.NoError(t, err)
+	testt.MustEqual(t, summary.Consumers, []core.StreamConsumerPending{{Name: bob, Count: 4}})
+
+	// unlike XCLAIM, XAUTOCLAIM creates the consumer even if nothing is claimed.
+	consumers, err := s.XINFOCONSUMERS(mystream, mygroup)
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(consumers), 2)
+
+	_, _, _, err = s.XAUTOCLAIM([]byte("other"), mygroup, bob, 0, core.StreamID{}, 10, false)
+	testt.MustEqual(t, err, core.ErrKeyNotFound)
+}
+
+func TestXINFOSTREAM(t *testing.T) {
+	mystream := []byte("mystream")
+
+	s := newStore(t)
+	_, err := s.XINFOSTREAM(mystream)
+	testt.MustEqual(t, err, core.ErrKeyNotFound)
+
+	for i := 1; i <= 3; i++ {
+		_, _, err := s.XADD(mystream, core.XAddOptions{ID: core.StreamID{Ms: uint64(i)}}, []byte("i"), []byte(strconv.Itoa(i)))
+		testt.NoError(t, err)
+	}
+	_, err = s.XDEL(mystream, core.StreamID{Ms: 2})
+	testt.NoError(t, err)
+	err = s.XGROUPCREATE(mystream, []byte("mygroup"), core.XGroupIDOptions{EntriesRead: -1}, false)
+	testt.NoError(t, err)
+
+	info, err := s.XINFOSTREAM(mystream)
+	testt.NoError(t, err)
+	testt.MustEqual(t, info, core.StreamInfo{
+		StreamCounters: core.StreamCounters{
+			Len:          2,
+			FirstID:      core.StreamID{Ms: 1},
+			LastID:       core.StreamID{Ms: 3},
+			MaxDeletedID: core.StreamID{Ms: 2},
+			EntriesAdded: 3,
+		},
+		Groups:     1,
+		FirstEntry: &core.StreamEntry{ID: core.StreamID{Ms: 1}, Fields: [][]byte{[]byte("i"), []byte("1")}},
+		LastEntry:  &core.StreamEntry{ID: core.StreamID{Ms: 3}, Fields: [][]byte{[]byte("i"), []byte("3")}},
+	})
+
+	// lag is unknown when entries after the last delivered one were deleted.
+	groups, err := s.XINFOGROUPS(mystream)
+	testt.NoError(t, err)
+	testt.MustEqual(t, groups[0].Lag, int64(-1))
+
+	_, err = s.XREADGROUP([]byte("mygroup"), []byte("Alice"), [][]byte{mystream}, []*core.StreamID{nil}, 0, false)
+	testt.NoError(t, err)
+	groups, err = s.XINFOGROUPS(mystream)
+	testt.NoError(t, err)
+	testt.MustEqual(t, groups[0].EntriesRead, int64(3))
+	testt.MustEqual(t, groups[0].Lag, int64(0))
+}
+
+func TestStreamKeysDoNotMix(t *testing.T) {
+	s := newStore(t)
+
+	// entries of a stream must not be visible from a stream with a key that is a prefix.
+	_, _, err := s.XADD([]byte("ab"), core.XAddOptions{ID: core.StreamID{Ms: 1}}, []byte("c"), []byte("1"))
+	testt.NoError(t, err)
+	_, _, err = s.XADD([]byte("a"), core.XAddOptions{ID: core.StreamID{Ms: 2}}, []byte("bc"), []byte("2"))
+	testt.NoError(t, err)
+
+	res, err := s.XRANGE([]byte("a"), core.StreamID{}, core.MaxStreamID, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntry{{ID: core.StreamID{Ms: 2}, Fields: [][]byte{[]byte("bc"), []byte("2")}}})
+
+	// groups don't mix either, even if group names are prefixes of each other.
+	err = s.XGROUPCREATE([]byte("ab"), []byte("g"), core.XGroupIDOptions{EntriesRead: -1}, false)
+	testt.NoError(t, err)
+	err = s.XGROUPCREATE([]byte("ab"), []byte("gg"), core.XGroupIDOptions{EntriesRead: -1}, false)
+	testt.NoError(t, err)
+	_, err = s.XREADGROUP([]byte("gg"), []byte("c"), [][]byte{[]byte("ab")}, []*core.StreamID{nil}, 0, false)
+	testt.NoError(t, err)
+	_, err = s.XPENDING([]byte("a"), []byte("g"))
+	testt.MustEqual(t, err, core.ErrNoGroup)
+	summary, err := s.XPENDING([]byte("ab"), []byte("g"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, summary.Count, 0)
+
+	// copy keeps entries, groups and the last ID.
+	ok, err := s.COPY([]byte("ab"), []byte("abc"), false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+	_, err = s.XDEL([]byte("ab"), core.StreamID{Ms: 1})
+	testt.NoError(t, err)
+
+	res, err = s.XRANGE([]byte("abc"), core.StreamID{}, core.MaxStreamID, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntry{{ID: core.StreamID{Ms: 1}, Fields: [][]byte{[]byte("c"), []byte("1")}}})
+
+	summary, err = s.XPENDING([]byte("abc"), []byte("gg"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, summary.Count, 1)
+
+	_, _, err = s.XADD([]byte("abc"), core.XAddOptions{ID: core.StreamID{Ms: 1}}, []byte("d"), []byte("3"))
+	testt.MustEqual(t, err, core.ErrStreamIDTooSmall)
+
+	// entries of a deleted stream are not visible in a new one.
+	_, err = s.DEL([]byte("abc"))
+	testt.NoError(t, err)
+	_, _, err = s.XADD([]byte("abc"), core.XAddOptions{ID: core.StreamID{Ms: 1}}, []byte("d"), []byte("3"))
+	testt.NoError(t, err)
+
+	res, err = s.XRANGE([]byte("abc"), core.StreamID{}, core.MaxStreamID, 0)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntry{{ID: core.StreamID{Ms: 1}, Fields: [][]byte{[]byte("d"), []byte("3")}}})
+
+	groups, err := s.XINFOGROUPS([]byte("abc"))
+	testt.NoError(t, err)
+	testt.MustEqual(t, groups, []core.StreamGroupInfo{})
+}
+
+func TestStreamGroupsPersisted(t *testing.T) {
+	mystream, mygroup, alice := []byte("mystream"), []byte("mygroup"), []byte("Alice")
+
+	dir := t.TempDir()
+	s, err := Open(Config{Dir: dir})
+	testt.NoError(t, err)
+
+	for i := 1; i <= 2; i++ {
+		_, _, err := s.XADD(mystream, core.XAddOptions{ID: core.StreamID{Ms: uint64(i)}}, []byte("i"), []byte(strconv.Itoa(i)))
+		testt.NoError(t, err)
+	}
+	err = s.XGROUPCREATE(mystream, mygroup, core.XGroupIDOptions{EntriesRead: -1}, false)
+	testt.NoError(t, err)
+	_, err = s.XREADGROUP(mygroup, alice, [][]byte{mystream}, []*core.StreamID{nil}, 1, false)
+	testt.NoError(t, err)
+
+	err = s.Close()
+	testt.NoError(t, err)
+
+	s, err = Open(Config{Dir: dir})
+	testt.NoError(t, err)
+	defer s.Close()
+
+	pending, err := s.XPENDINGRANGE(mystream, mygroup, core.XPendingOptions{End: core.MaxStreamID, Count: 10})
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(pending), 1)
+	testt.MustEqual(t, pending[0].ID, core.StreamID{Ms: 1})
+	testt.MustEqual(t, pending[0].Consumer, alice)
+	testt.MustEqual(t, pending[0].Deliveries, int64(1))
+
+	// the group continues after the last delivered entry.
+	res, err := s.XREADGROUP(mygroup, alice, [][]byte{mystream}, []*core.StreamID{nil}, 0, false)
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []core.StreamEntries{
+		{Key: mystream, Entries: []core.StreamEntry{{ID: core.StreamID{Ms: 2}, Fields: [][]byte{[]byte("i"), []byte("2")}}}},
+	})
+
+	info, err := s.XINFOSTREAM(mystream)
+	testt.NoError(t, err)
+	testt.MustEqual(t, info.EntriesAdded, int64(2))
+	testt.MustEqual(t, info.Groups, 1)
+}
@@ -2,9 +2,10 @@ package ondisk
 
 import (
 	"bytes"
-	"errors"
+	"encoding/binary"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/cristaloleg/didis/internal/core"
 
@@ -13,21 +14,40 @@ import (
 
 // Strings operations https://redis.io/commands/?group=string
 
+// Strings are kept in the meta payload with zero version. A string longer than
+// stringChunkSize is split into chunks, so a part of it can be read or updated
+// without loading the whole value:
+//
+//	m + key                                => meta with a version, payload is the length
+//	d + len(key) + key + version + index   => chunk of stringChunkSize bytes
+//
+// Missing chunks and bytes of the last chunk beyond the length are zeros.
+
+// stringChunkSize is the size of a chunk of a long string.
+const stringChunkSize = 4096
+
 func (s *Store) APPEND(key, value []byte) (int, error) {
-	b := s.db.NewIndexedBatch()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	val, closer, _ := b.Get(key)
-	defer tryClose(closer)
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
 
-	realVal := append(bytes.Clone(val), value...)
+	m, ok, err := loadString(b, key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		m = newString(nil)
+	}
 
-	if err := b.Set(key, realVal, s.syncOpt); err != nil {
+	if err := s.writeRange(b, key, &m, stringLen(m), value); err != nil {
 		return 0, err
 	}
 	if err := b.Commit(s.syncOpt); err != nil {
 		return 0, err
 	}
-	return len(realVal), nil
+	return int(stringLen(m)), nil
 }
 
 func (s *Store) DECR(key []byte) (int64, error) {
@@ -39,72 +59,121 @@ func (s *Store) DECRBY(key []byte, by int) (int64, error) {
 }
 
 func (s *Store) GET(key []byte) ([]byte, error) {
-	val, closer, err := s.db.Get(key)
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, ok, err := getString(snap, key)
 	if err != nil {
-		if errors.Is(err, pebble.ErrNotFound) {
-			return nil, core.ErrKeyNotFound
-		}
 		return nil, err
 	}
-	defer tryClose(closer)
-
-	return bytes.Clone(val), nil
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	return stringValue(snap, key, m)
 }
 
 func (s *Store) GETDEL(key []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
 
-	val, closer, _ := s.db.Get(key)
-	defer tryClose(closer)
+	m, ok, err := loadString(b, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	val, err := stringValue(b, key, m)
+	if err != nil {
+		return nil, err
+	}
 
-	_ = b.Delete(key, nil)
+	if err := delKey(b, key, m); err != nil {
+		return nil, err
+	}
 	if err := b.Commit(s.syncOpt); err != nil {
 		return nil, err
 	}
 	return val, nil
 }
 
-// TODO: GETEX() error
+func (s *Store) GETEX(key []byte, opts core.GetExOptions) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-func (s *Store) GETRANGE(key []byte, start, end int) ([]byte, error) {
-	val, closer, err := s.db.Get(key)
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, ok, err := loadString(b, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	val, err := stringValue(b, key, m)
 	if err != nil {
-		if errors.Is(err, pebble.ErrNotFound) {
-			return nil, core.ErrKeyNotFound
-		}
 		return nil, err
 	}
-	defer tryClose(closer)
 
-	if start == 0 && end == -1 {
-		return bytes.Clone(val), nil
+	switch {
+	case opts.Persist:
+		err = setExpire(b, key, &m, 0)
+	case opts.ExpireAt != 0 && opts.ExpireAt <= core.NowMs():
+		err = delKey(b, key, m)
+	case opts.ExpireAt != 0:
+		err = setExpire(b, key, &m, opts.ExpireAt)
 	}
-	if start > len(val) {
-		return []byte(""), nil
+	if err != nil {
+		return nil, err
 	}
-	if start < 0 {
-		start += len(val)
+	if err := b.Commit(s.syncOpt); err != nil {
+		return nil, err
 	}
-	if end == -1 {
-		end = len(val) - 1
+	return val, nil
+}
+
+func (s *Store) GETRANGE(key []byte, start, end int) ([]byte, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, ok, err := getString(snap, key)
+	if err != nil {
+		return nil, err
 	}
-	end = min(end, len(val)-1)
-	return bytes.Clone(val[start : end+1]), nil
+	if !ok {
+		return nil, core.ErrKeyNotFound
+	}
+	from, to := core.SubstrRange(int(stringLen(m)), start, end)
+	return readRange(snap, key, m, int64(from), int64(to-from))
 }
 
 func (s *Store) GETSET(key, value []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
 
-	oldValue, closer, _ := b.Get(key)
-	defer tryClose(closer)
+	m, _, err := loadString(b, key)
+	if err != nil {
+		return nil, err
+	}
+	old, err := stringValue(b, key, m)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := b.Set(key, value, nil); err != nil {
+	if _, err := s.setString(b, key, value, m); err != nil {
 		return nil, err
 	}
 	if err := b.Commit(s.syncOpt); err != nil {
 		return nil, err
 	}
-	return bytes.Clone(oldValue), nil
+	return old, nil
 }
 
 func (s *Store) INCR(key []byte) (int64, error) {
@@ -116,49 +185,83 @@ func (s *Store) INCRBY(key []byte, by int) (int64, error) {
 }
 
 func (s *Store) INCRBYFLOAT(key []byte, by float64) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
 
-	val, closer, err := b.Get(key)
+	m, ok, err := loadString(b, key)
 	if err != nil {
-		if !errors.Is(err, pebble.ErrNotFound) {
-			return "", err
-		}
-		val = []byte("0")
+		return "", err
+	}
+	if !ok {
+		m = newString([]byte("0"))
+	}
+	// chunked strings are too long to be numbers.
+	if isChunked(m) {
+		return "", core.ErrNotFloat
 	}
-	defer tryClose(closer)
 
-	num, err := strconv.ParseFloat(string(val), 64)
+	num, err := strconv.ParseFloat(string(m.payload), 64)
 	if err != nil {
-		return "0", err
+		return "", core.ErrNotFloat
 	}
 	num += by
 
-	value := []byte(strconv.FormatFloat(num, 'f', -1, 64))
-
-	if err := b.Set(key, value, s.syncOpt); err != nil {
+	m.payload = []byte(strconv.FormatFloat(num, 'f', -1, 64))
+	if err := putMeta(b, key, m); err != nil {
 		return "", err
 	}
 	if err := b.Commit(s.syncOpt); err != nil {
 		return "", err
 	}
-	return string(value), nil
+	return string(m.payload), nil
 }
 
-// TODO: LCS() error
+func (s *Store) LCS(key1, key2 []byte, opts core.LCSOptions) (core.LCSResult, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m1, _, err := getString(snap, key1)
+	if err != nil {
+		return core.LCSResult{}, err
+	}
+	m2, _, err := getString(snap, key2)
+	if err != nil {
+		return core.LCSResult{}, err
+	}
+	a, err := stringValue(snap, key1, m1)
+	if err != nil {
+		return core.LCSResult{}, err
+	}
+	b, err := stringValue(snap, key2, m2)
+	if err != nil {
+		return core.LCSResult{}, err
+	}
+	return core.LCS(a, b, opts)
+}
 
 func (s *Store) MGET(keys ...[]byte) ([][]byte, error) {
-	b := s.db.NewIndexedBatch()
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
 
 	res := make([][]byte, 0, len(keys))
 	for i := range keys {
-		val, closer, _ := b.Get(keys[i])
-		defer tryClose(closer)
-
-		res = append(res, bytes.Clone(val))
-	}
-
-	if err := b.Close(); err != nil {
-		return nil, err
+		m, ok, err := getMeta(snap, keys[i])
+		if err != nil {
+			return nil, err
+		}
+		// keys of other types are reported as missing.
+		if !ok || m.typ != core.TypeString {
+			res = append(res, nil)
+			continue
+		}
+		val, err := stringValue(snap, keys[i], m)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, val)
 	}
 	return res, nil
 }
@@ -168,44 +271,291 @@ func (s *Store) MSET(keyvals ...[]byte) error {
 		return fmt.Errorf("wrong number of arguments for 'mset' command")
 	}
 
-	b := s.db.NewBatch()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
 
 	for i := 0; i < len(keyvals); i += 2 {
-		err := b.Set(bytes.Clone(keyvals[i]), bytes.Clone(keyvals[i+1]), nil)
+		m, _, err := loadMeta(b, keyvals[i])
 		if err != nil {
 			return err
 		}
+		if _, err := s.setString(b, keyvals[i], keyvals[i+1], m); err != nil {
+			return err
+		}
 	}
-
 	if err := b.Commit(s.syncOpt); err != nil {
 		return err
 	}
 	return nil
 }
 
-// TODO: MSETNX() error { return nil }
-// TODO: PSETEX() error { return nil }
+func (s *Store) MSETNX(keyvals ...[]byte) (bool, error) {
+	if len(keyvals)%2 == 1 {
+		return false, fmt.Errorf("wrong number of arguments for 'msetnx' command")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	for i := 0; i < len(keyvals); i += 2 {
+		_, ok, err := loadMeta(b, keyvals[i])
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return false, b.Commit(s.syncOpt)
+		}
+	}
+	for i := 0; i < len(keyvals); i += 2 {
+		if _, err := s.setString(b, keyvals[i], keyvals[i+1], meta{}); err != nil {
+			return false, err
+		}
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Store) PSETEX(key []byte, milliseconds int64, value []byte) error {
+	return s.setEx(key, milliseconds, time.Millisecond, value)
+}
+
+func (s *Store) SET(key, value []byte, opts core.SetOptions) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, ok, err := loadMeta(b, key)
+	if err != nil {
+		return nil, false, err
+	}
+	var old []byte
+	if opts.Get && ok {
+		if m.typ != core.TypeString {
+			return nil, false, core.ErrWrongType
+		}
+		if old, err = stringValue(b, key, m); err != nil {
+			return nil, false, err
+		}
+	}
+
+	switch {
+	case opts.NX && ok, opts.XX && !ok:
+		return old, false, b.Commit(s.syncOpt)
+	case opts.ExpireAt != 0 && opts.ExpireAt <= core.NowMs():
+		if ok {
+			if err := delKey(b, key, m); err != nil {
+				return nil, false, err
+			}
+		}
+		return old, true, b.Commit(s.syncOpt)
+	}
+
+	at := opts.ExpireAt
+	if opts.KeepTTL {
+		at = m.expireAt
+	}
+	nm, err := s.setString(b, key, value, m)
+	if err != nil {
+		return nil, false, err
+	}
+	if at != 0 {
+		if err := setExpire(b, key, &nm, at); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return nil, false, err
+	}
+	return old, true, nil
+}
+
+func (s *Store) SETEX(key []byte, seconds int64, value []byte) error {
+	return s.setEx(key, seconds, time.Second, value)
+}
 
-func (s *Store) SET(key, value []byte) error {
-	err := s.db.Set(key, value, s.syncOpt)
-	return err
+func (s *Store) SETNX(key, value []byte) (bool, error) {
+	_, ok, err := s.SET(key, value, core.SetOptions{NX: true})
+	return ok, err
 }
 
-// TODO: SETEX() error { return nil }
-// TODO: SETNX() error { return nil }
-// TODO: SETRANGE() error { return nil }
+func (s *Store) SETRANGE(key []byte, offset int, value []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-func (s *Store) STRLEN(key []byte) (int64, error) {
-	val, closer, err := s.db.Get(key)
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, ok, err := loadString(b, key)
 	if err != nil {
-		if errors.Is(err, pebble.ErrNotFound) {
-			return 0, nil
+		return 0, err
+	}
+	if !ok {
+		if len(value) == 0 {
+			return 0, b.Commit(s.syncOpt)
 		}
+		m = newString(nil)
+	}
+
+	if len(value) == 0 {
+		return int(stringLen(m)), b.Commit(s.syncOpt)
+	}
+	if err := s.writeRange(b, key, &m, int64(offset), value); err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return int(stringLen(m)), nil
+}
+
+func (s *Store) STRLEN(key []byte) (int64, error) {
+	m, _, err := getString(s.db, key)
+	if err != nil {
 		return 0, err
 	}
-	defer tryClose(closer)
+	return stringLen(m), nil
+}
+
+func (s *Store) SUBSTR(key []byte, start, end int) ([]byte, error) {
+	return s.GETRANGE(key, start, end)
+}
+
+func isChunked(m meta) bool {
+	return m.version != 0
+}
+
+// stringLen returns the length of the string value.
+func stringLen(m meta) int64 {
+	if !isChunked(m) {
+		return int64(len(m.payload))
+	}
+	return int64(binary.BigEndian.Uint64(m.payload))
+}
+
+// stringValue returns the whole string value.
+func stringValue(r pebble.Reader, key []byte, m meta) ([]byte, error) {
+	if !isChunked(m) {
+		return m.payload, nil
+	}
+	return readRange(r, key, m, 0, stringLen(m))
+}
+
+// readRange reads n bytes of the string starting at offset, bytes beyond the value are zeros.
+func readRange(r pebble.Reader, key []byte, m meta, offset, n int64) ([]byte, error) {
+	res := make([]byte, n)
+	if !isChunked(m) {
+		if offset < int64(len(m.payload)) {
+			copy(res, m.payload[offset:])
+		}
+		return res, nil
+	}
+	if n == 0 {
+		return res, nil
+	}
+
+	prefix := dataKeyPrefix(key, m.version)
+	iter, err := r.NewIter(&pebble.IterOptions{
+		LowerBound: chunkKey(key, m.version, offset/stringChunkSize),
+		UpperBound: chunkKey(key, m.version, (offset+n-1)/stringChunkSize+1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tryClose(iter)
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		chunk := iter.Value()
+		at := int64(binary.BigEndian.Uint64(iter.Key()[len(prefix):])) * stringChunkSize
+		from, to := max(offset, at), min(offset+n, at+int64(len(chunk)))
+		if from < to {
+			copy(res[from-offset:to-offset], chunk[from-at:to-at])
+		}
+	}
+	return res, iter.Error()
+}
+
+// writeRange overwrites part of the string starting at offset, growing it with zeros if needed,
+// and writes its meta.
+func (s *Store) writeRange(b *pebble.Batch, key []byte, m *meta, offset int64, data []byte) error {
+	if err := s.growString(b, key, m, offset+int64(len(data))); err != nil {
+		return err
+	}
+	if !isChunked(*m) {
+		copy(m.payload[offset:], data)
+		return putMeta(b, key, *m)
+	}
+
+	for len(data) > 0 {
+		at := offset % stringChunkSize
+		n := min(int64(len(data)), stringChunkSize-at)
+		k := chunkKey(key, m.version, offset/stringChunkSize)
 
-	return int64(len(val)), nil
+		chunk, ok, err := getValue(b, k)
+		if err != nil {
+			return err
+		}
+		// missing chunks are zeros, there is no need to write zeros over them.
+		if ok || !isZero(data[:n]) {
+			if !ok {
+				chunk = make([]byte, stringChunkSize)
+			}
+			copy(chunk[at:], data[:n])
+			if err := b.Set(k, chunk, nil); err != nil {
+				return err
+			}
+		}
+		data, offset = data[n:], offset+n
+	}
+	return putMeta(b, key, *m)
 }
 
-// TODO: SUBSTR(key []byte, start, end int) (string, error)
+// growString makes the string at least size bytes long, padding it with zeros,
+// and splits it into chunks once it's longer than stringChunkSize.
+// Meta is updated but not written.
+func (s *Store) growString(b *pebble.Batch, key []byte, m *meta, size int64) error {
+	if size <= stringLen(*m) {
+		return nil
+	}
+	if size > core.MaxStringSize {
+		return core.ErrStringTooLong
+	}
+	switch {
+	case isChunked(*m):
+	case size <= stringChunkSize:
+		m.payload = append(m.payload, make([]byte, size-int64(len(m.payload)))...)
+		return nil
+	default:
+		version, err := s.nextVersion(b)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < len(m.payload); i += stringChunkSize {
+			chunk := make([]byte, stringChunkSize)
+			copy(chunk, m.payload[i:])
+			if err := b.Set(chunkKey(key, version, int64(i/stringChunkSize)), chunk, nil); err != nil {
+				return err
+			}
+		}
+		m.version = version
+	}
+	m.payload = binary.BigEndian.AppendUint64(nil, uint64(size))
+	return nil
+}
+
+func chunkKey(key []byte, version uint64, index int64) []byte {
+	return binary.BigEndian.AppendUint64(dataKeyPrefix(key, version), uint64(index))
+}
+
+func isZero(data []byte) bool {
+	return len(bytes.Trim(data, "\x00")) == 0
+}
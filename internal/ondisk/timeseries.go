@@ -0,0 +1,412 @@
+package ondisk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Time series operations https://redis.io/commands/?group=timeseries
+
+// Headers of time series are kept in the meta payload, samples are ordered by timestamp
+// and labels are indexed for filters:
+//
+//	m + key                                            => meta with a version, payload is the header
+//	d + len(key) + key + version + timestamp           => value of the sample
+//	l + len(label) + label + len(value) + value + key  => nil, label index
+//
+// Index records are written with the series and removed by delKey, they are not versioned,
+// so series found by the index are always checked against their labels.
+
+func (s *Store) TSADD(key []byte, sample core.TSSample, opts core.TSAddOptions) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, ts, err := loadTimeSeries(b, key)
+	if err != nil {
+		return 0, err
+	}
+	if ts == nil {
+		ts = core.NewTimeSeries(opts.TSOptions)
+		if m, err = s.newSeries(b, key, ts); err != nil {
+			return 0, err
+		}
+	}
+
+	compacted, err := ts.Add(newTSStorage(b, key, m.version), sample, opts.OnDuplicate)
+	if err != nil {
+		return 0, err
+	}
+	m.payload = ts.Encode()
+	if err := putMeta(b, key, m); err != nil {
+		return 0, err
+	}
+
+	for _, c := range compacted {
+		dstMeta, dst, err := loadTimeSeries(b, c.DstKey)
+		if err != nil && !errors.Is(err, core.ErrWrongType) {
+			return 0, err
+		}
+		// destinations deleted or replaced since the rule was created are skipped.
+		if dst == nil || !bytes.Equal(dst.SrcKey(), key) {
+			continue
+		}
+		if err := dst.Upsert(newTSStorage(b, c.DstKey, dstMeta.version), c.Sample); err != nil {
+			return 0, err
+		}
+		dstMeta.payload = dst.Encode()
+		if err := putMeta(b, c.DstKey, dstMeta); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return sample.Timestamp, nil
+}
+
+func (s *Store) TSCREATE(key []byte, opts core.TSOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	_, ok, err := loadMeta(b, key)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return core.ErrTSExists
+	}
+	if _, err := s.newSeries(b, key, core.NewTimeSeries(opts)); err != nil {
+		return err
+	}
+	return b.Commit(s.syncOpt)
+}
+
+func (s *Store) TSCREATERULE(src, dst []byte, agg core.TSAggregation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	srcMeta, srcTS, err := loadTimeSeries(b, src)
+	if err != nil {
+		return err
+	}
+	dstMeta, dstTS, err := loadTimeSeries(b, dst)
+	if err != nil {
+		return err
+	}
+	if srcTS == nil || dstTS == nil {
+		return core.ErrTSNotFound
+	}
+
+	err = core.CheckTSRule(src, dst, srcTS, dstTS, func(key []byte) (*core.TimeSeries, error) {
+		_, ts, err := loadTimeSeries(b, key)
+		if errors.Is(err, core.ErrWrongType) {
+			return nil, nil
+		}
+		return ts, err
+	})
+	if err != nil {
+		return err
+	}
+	// a stale rule for dst is replaced.
+	srcTS.DeleteRule(dst)
+	srcTS.AddRule(dst, agg)
+	dstTS.SetSrcKey(src)
+
+	srcMeta.payload, dstMeta.payload = srcTS.Encode(), dstTS.Encode()
+	if err := putMeta(b, src, srcMeta); err != nil {
+		return err
+	}
+	if err := putMeta(b, dst, dstMeta); err != nil {
+		return err
+	}
+	return b.Commit(s.syncOpt)
+}
+
+func (s *Store) TSDELETERULE(src, dst []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	srcMeta, srcTS, err := loadTimeSeries(b, src)
+	if err != nil {
+		return err
+	}
+	if srcTS == nil {
+		return core.ErrTSNotFound
+	}
+	if !srcTS.DeleteRule(dst) {
+		return core.ErrTSRuleNotFound
+	}
+	srcMeta.payload = srcTS.Encode()
+	if err := putMeta(b, src, srcMeta); err != nil {
+		return err
+	}
+
+	dstMeta, dstTS, err := loadTimeSeries(b, dst)
+	if err != nil && !errors.Is(err, core.ErrWrongType) {
+		return err
+	}
+	if dstTS != nil && bytes.Equal(dstTS.SrcKey(), src) {
+		dstTS.SetSrcKey(nil)
+		dstMeta.payload = dstTS.Encode()
+		if err := putMeta(b, dst, dstMeta); err != nil {
+			return err
+		}
+	}
+	return b.Commit(s.syncOpt)
+}
+
+func (s *Store) TSMRANGE(filters []core.TSFilter, opts core.TSRangeOptions) ([]core.TSRange, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	res := []core.TSRange{}
+	err := querySeries(snap, filters, func(key []byte, m meta, ts *core.TimeSeries) error {
+		samples, err := ts.Range(newTSStorage(snap, key, m.version), opts)
+		if err != nil {
+			return err
+		}
+		res = append(res, core.TSRange{Key: key, Labels: ts.Labels(), Samples: samples})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Store) TSQUERYINDEX(filters []core.TSFilter) ([][]byte, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	res := [][]byte{}
+	err := querySeries(snap, filters, func(key []byte, _ meta, _ *core.TimeSeries) error {
+		res = append(res, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Store) TSRANGE(key []byte, opts core.TSRangeOptions) ([]core.TSSample, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, ts, err := getTimeSeries(snap, key)
+	if err != nil {
+		return nil, err
+	}
+	if ts == nil {
+		return nil, core.ErrTSNotFound
+	}
+	return ts.Range(newTSStorage(snap, key, m.version), opts)
+}
+
+// newSeries writes a new series with its label index and returns its meta.
+func (s *Store) newSeries(b *pebble.Batch, key []byte, ts *core.TimeSeries) (meta, error) {
+	version, err := s.nextVersion(b)
+	if err != nil {
+		return meta{}, err
+	}
+	m := meta{typ: core.TypeTimeSeries, version: version, payload: ts.Encode()}
+	if err := putMeta(b, key, m); err != nil {
+		return meta{}, err
+	}
+	return m, putLabelIndex(b, key, ts.Labels())
+}
+
+// querySeries calls fn in key order for series matching all filters, they are looked up
+// by values of the first positive filter in the label index.
+func querySeries(r pebble.Reader, filters []core.TSFilter, fn func(key []byte, m meta, ts *core.TimeSeries) error) error {
+	f, err := core.TSIndexFilter(filters)
+	if err != nil {
+		return err
+	}
+
+	var keys [][]byte
+	for _, value := range f.Values {
+		prefix := labelKeyPrefix(f.Label, value)
+		iter, err := r.NewIter(&pebble.IterOptions{
+			LowerBound: prefix,
+			UpperBound: prefixEnd(prefix),
+		})
+		if err != nil {
+			return err
+		}
+		for iter.First(); iter.Valid(); iter.Next() {
+			keys = append(keys, bytes.Clone(iter.Key()[len(prefix):]))
+		}
+		if err := iter.Close(); err != nil {
+			return err
+		}
+	}
+	slices.SortFunc(keys, bytes.Compare)
+	keys = slices.CompactFunc(keys, bytes.Equal)
+
+	for _, key := range keys {
+		m, ts, err := getTimeSeries(r, key)
+		if err != nil && !errors.Is(err, core.ErrWrongType) {
+			return err
+		}
+		if ts == nil || !core.MatchTSFilters(filters, ts.Labels()) {
+			continue
+		}
+		if err := fn(key, m, ts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putLabelIndex indexes labels of the series.
+func putLabelIndex(b *pebble.Batch, key []byte, labels []core.TSLabel) error {
+	for _, l := range labels {
+		if err := b.Set(append(labelKeyPrefix(l.Name, l.Value), key...), nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delLabelIndex removes labels of the series with meta m from the index.
+func delLabelIndex(b *pebble.Batch, key []byte, m meta) error {
+	ts, err := core.DecodeTimeSeries(m.payload)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", key, err)
+	}
+	for _, l := range ts.Labels() {
+		if err := b.Delete(append(labelKeyPrefix(l.Name, l.Value), key...), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func labelKeyPrefix(name, value []byte) []byte {
+	res := make([]byte, 0, len(labelPrefix)+4+len(name)+4+len(value))
+	res = append(res, labelPrefix...)
+	res = binary.BigEndian.AppendUint32(res, uint32(len(name)))
+	res = append(res, name...)
+	res = binary.BigEndian.AppendUint32(res, uint32(len(value)))
+	return append(res, value...)
+}
+
+// tsStorage implements core.TSStorage over samples of the key, writes go to b.
+type tsStorage struct {
+	r      pebble.Reader
+	b      *pebble.Batch
+	prefix []byte
+}
+
+// newTSStorage returns storage of samples of the key, it's writable if r is a batch.
+func newTSStorage(r pebble.Reader, key []byte, version uint64) tsStorage {
+	b, _ := r.(*pebble.Batch)
+	return tsStorage{r: r, b: b, prefix: dataKeyPrefix(key, version)}
+}
+
+func (st tsStorage) Get(ts int64) (float64, bool, error) {
+	val, ok, err := getValue(st.r, st.sampleKey(ts))
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	if len(val) != 8 {
+		return 0, false, errors.New("corrupted time series sample")
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(val)), true, nil
+}
+
+func (st tsStorage) Put(s core.TSSample) error {
+	return st.b.Set(st.sampleKey(s.Timestamp), binary.BigEndian.AppendUint64(nil, math.Float64bits(s.Value)), nil)
+}
+
+func (st tsStorage) Range(from, to int64, fn func(s core.TSSample) bool) error {
+	// timestamps are never negative.
+	from = max(from, 0)
+	if to < from {
+		return nil
+	}
+	iter, err := st.r.NewIter(&pebble.IterOptions{
+		LowerBound: st.sampleKey(from),
+		UpperBound: binary.BigEndian.AppendUint64(bytes.Clone(st.prefix), uint64(to)+1),
+	})
+	if err != nil {
+		return err
+	}
+	defer tryClose(iter)
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		k, val := iter.Key(), iter.Value()
+		if len(k) != len(st.prefix)+8 || len(val) != 8 {
+			return errors.New("corrupted time series sample")
+		}
+		s := core.TSSample{
+			Timestamp: int64(binary.BigEndian.Uint64(k[len(st.prefix):])),
+			Value:     math.Float64frombits(binary.BigEndian.Uint64(val)),
+		}
+		if !fn(s) {
+			break
+		}
+	}
+	return iter.Error()
+}
+
+func (st tsStorage) DeleteBefore(ts int64) error {
+	if ts <= 0 {
+		return nil
+	}
+	return st.b.DeleteRange(st.sampleKey(0), st.sampleKey(ts), nil)
+}
+
+func (st tsStorage) sampleKey(ts int64) []byte {
+	return binary.BigEndian.AppendUint64(bytes.Clone(st.prefix), uint64(ts))
+}
+
+// getTimeSeries is like getMeta but fails for keys that are not time series and also returns the series.
+// The series is nil for a missing key.
+func getTimeSeries(r pebble.Reader, key []byte) (meta, *core.TimeSeries, error) {
+	m, ok, err := getMeta(r, key)
+	return asTimeSeries(key, m, ok, err)
+}
+
+// loadTimeSeries is like loadMeta but fails for keys that are not time series and also returns the series.
+// The series is nil for a missing key.
+func loadTimeSeries(b *pebble.Batch, key []byte) (meta, *core.TimeSeries, error) {
+	m, ok, err := loadMeta(b, key)
+	return asTimeSeries(key, m, ok, err)
+}
+
+func asTimeSeries(key []byte, m meta, ok bool, err error) (meta, *core.TimeSeries, error) {
+	if err != nil || !ok {
+		return meta{}, nil, err
+	}
+	if m.typ != core.TypeTimeSeries {
+		return meta{}, nil, core.ErrWrongType
+	}
+	ts, err := core.DecodeTimeSeries(m.payload)
+	if err != nil {
+		return meta{}, nil, fmt.Errorf("key %q: %w", key, err)
+	}
+	return m, ts, nil
+}
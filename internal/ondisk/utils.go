@@ -1,8 +1,8 @@
 package ondisk
 
 import (
-	"errors"
 	"strconv"
+	"time"
 
 	"github.com/cristaloleg/didis/internal/core"
 
@@ -10,26 +10,32 @@ import (
 )
 
 func (s *Store) setNum(key []byte, by int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
 
-	val, closer, err := b.Get(key)
+	m, ok, err := loadString(b, key)
 	if err != nil {
-		if !errors.Is(err, pebble.ErrNotFound) {
-			return 0, err
-		}
-		val = []byte("0")
+		return 0, err
+	}
+	if !ok {
+		m = newString([]byte("0"))
+	}
+	// chunked strings are too long to be numbers.
+	if isChunked(m) {
+		return 0, core.ErrNotIntOrOutOfRange
 	}
-	defer tryClose(closer)
 
-	num, err := strconv.ParseInt(string(val), 10, 64)
+	num, err := strconv.ParseInt(string(m.payload), 10, 64)
 	if err != nil {
 		return 0, core.ErrNotIntOrOutOfRange
 	}
 	num += int64(by)
 
-	realVal := []byte(strconv.FormatInt(num, 10))
-
-	if err := b.Set(key, realVal, s.syncOpt); err != nil {
+	m.payload = []byte(strconv.FormatInt(num, 10))
+	if err := putMeta(b, key, m); err != nil {
 		return 0, err
 	}
 	if err := b.Commit(s.syncOpt); err != nil {
@@ -37,3 +43,53 @@ func (s *Store) setNum(key []byte, by int) (int64, error) {
 	}
 	return num, nil
 }
+
+func (s *Store) setEx(key []byte, d int64, unit time.Duration, value []byte) error {
+	if d <= 0 {
+		return core.ErrInvalidExpireTime
+	}
+	at, err := core.ExpireAtMs(core.NowMs(), d, unit)
+	if err != nil {
+		return err
+	}
+	_, _, err = s.SET(key, value, core.SetOptions{ExpireAt: at})
+	return err
+}
+
+// getString is like getMeta but fails for keys that are not strings.
+func getString(r pebble.Reader, key []byte) (meta, bool, error) {
+	m, ok, err := getMeta(r, key)
+	if err == nil && ok && m.typ != core.TypeString {
+		return meta{}, false, core.ErrWrongType
+	}
+	return m, ok, err
+}
+
+// loadString is like loadMeta but fails for keys that are not strings.
+func loadString(b *pebble.Batch, key []byte) (meta, bool, error) {
+	m, ok, err := loadMeta(b, key)
+	if err == nil && ok && m.typ != core.TypeString {
+		return meta{}, false, core.ErrWrongType
+	}
+	return m, ok, err
+}
+
+func newString(value []byte) meta {
+	return meta{typ: core.TypeString, payload: value}
+}
+
+// setString replaces the key (if any) with a string value without expiry and returns its meta.
+// Values longer than stringChunkSize are split into chunks.
+func (s *Store) setString(b *pebble.Batch, key, value []byte, old meta) (meta, error) {
+	if old.typ != core.TypeNone {
+		if err := delKey(b, key, old); err != nil {
+			return meta{}, err
+		}
+	}
+	if len(value) <= stringChunkSize {
+		m := newString(value)
+		return m, putMeta(b, key, m)
+	}
+	m := newString(nil)
+	return m, s.writeRange(b, key, &m, 0, value)
+}
@@ -0,0 +1,805 @@
+package ondisk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Sorted sets operations https://redis.io/commands/?group=sorted-set
+
+// Sorted set members are stored twice:
+//
+//	d + len(key) + key + version + member          => score
+//	s + len(key) + key + version + score + member  => nil
+//
+// Score in the second key is encoded so that byte order matches numeric order,
+// which makes score and lex ranges pebble range scans.
+type zsetMeta struct {
+	// len is the number of members.
+	len int
+}
+
+func decodeZSetMeta(m meta) (zsetMeta, error) {
+	if len(m.payload) != 8 {
+		return zsetMeta{}, errCorruptedMeta
+	}
+	return zsetMeta{len: int(binary.BigEndian.Uint64(m.payload))}, nil
+}
+
+func (zm zsetMeta) encode() []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(zm.len))
+}
+
+var errCorruptedScore = errors.New("corrupted sorted set score")
+
+func (s *Store) ZADD(key []byte, opts core.ZAddOptions, members ...core.ZMember) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, zm, err := s.loadOrNewZSet(b, key)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, member := range members {
+		old, exists, err := getScore(b, key, m, member.Member)
+		if err != nil {
+			return 0, err
+		}
+		if !opts.Allowed(old, member.Score, exists) {
+			continue
+		}
+		switch {
+		case !exists:
+			n++
+		case old == member.Score:
+			continue
+		case opts.CH:
+			n++
+		}
+		if err := setScore(b, key, m, &zm, member.Member, member.Score); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := putZSet(b, key, m, zm); err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *Store) ZCARD(key []byte) (int, error) {
+	_, zm, _, err := getZSet(s.db, key)
+	return zm.len, err
+}
+
+func (s *Store) ZCOUNT(key []byte, min, max core.ScoreBound) (int, error) {
+	return s.countGeneric(key, core.ZRange{By: core.ZRangeByScore, Min: min, Max: max})
+}
+
+func (s *Store) ZDIFF(keys ...[]byte) ([]core.ZMember, error) {
+	return s.zalgebraGeneric(keys, core.ZDiff)
+}
+
+func (s *Store) ZDIFFSTORE(dst []byte, keys ...[]byte) (int, error) {
+	return s.zstoreGeneric(dst, keys, core.ZDiff)
+}
+
+func (s *Store) ZINCRBY(key []byte, by float64, member []byte, opts core.ZAddOptions) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, zm, err := s.loadOrNewZSet(b, key)
+	if err != nil {
+		return 0, false, err
+	}
+	old, exists, err := getScore(b, key, m, member)
+	if err != nil {
+		return 0, false, err
+	}
+
+	score := old + by
+	if math.IsNaN(score) {
+		return 0, false, core.ErrScoreNaN
+	}
+	if !opts.Allowed(old, score, exists) {
+		return 0, false, nil
+	}
+
+	if err := setScore(b, key, m, &zm, member, score); err != nil {
+		return 0, false, err
+	}
+	if err := putZSet(b, key, m, zm); err != nil {
+		return 0, false, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, false, err
+	}
+	return score, true, nil
+}
+
+func (s *Store) ZINTER(opts core.ZAggOptions, keys ...[]byte) ([]core.ZMember, error) {
+	return s.zalgebraGeneric(keys, func(iters []core.ZMemberIter, fn func(core.ZMember) bool) error {
+		return core.ZInter(iters, opts, fn)
+	})
+}
+
+func (s *Store) ZINTERCARD(limit int, keys ...[]byte) (int, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	iters, closeIters, err := zmemberIters(snap, keys)
+	if err != nil {
+		return 0, err
+	}
+	defer closeIters()
+
+	n := 0
+	err = core.ZInter(iters, core.ZAggOptions{}, func(core.ZMember) bool {
+		n++
+		return limit <= 0 || n < limit
+	})
+	return n, err
+}
+
+func (s *Store) ZINTERSTORE(dst []byte, opts core.ZAggOptions, keys ...[]byte) (int, error) {
+	return s.zstoreGeneric(dst, keys, func(iters []core.ZMemberIter, fn func(core.ZMember) bool) error {
+		return core.ZInter(iters, opts, fn)
+	})
+}
+
+func (s *Store) ZLEXCOUNT(key []byte, min, max core.LexBound) (int, error) {
+	return s.countGeneric(key, core.ZRange{By: core.ZRangeByLex, LexMin: min, LexMax: max})
+}
+
+func (s *Store) ZMSCORE(key []byte, members ...[]byte) ([]*float64, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, _, ok, err := getZSet(snap, key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*float64, len(members))
+	if !ok {
+		return res, nil
+	}
+	for i, member := range members {
+		score, ok, err := getScore(snap, key, m, member)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res[i] = &score
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) ZPOPMAX(key []byte, count int) ([]core.ZMember, error) {
+	return s.zpopGeneric(key, count, true)
+}
+
+func (s *Store) ZPOPMIN(key []byte, count int) ([]core.ZMember, error) {
+	return s.zpopGeneric(key, count, false)
+}
+
+func (s *Store) ZRANGE(key []byte, r core.ZRange) ([]core.ZMember, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, zm, ok, err := getZSet(snap, key)
+	if err != nil || !ok {
+		return []core.ZMember{}, err
+	}
+	return zrangeMembers(snap, key, m, zm, r)
+}
+
+func (s *Store) ZRANGESTORE(dst, src []byte, r core.ZRange) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, zm, ok, err := loadZSet(b, src)
+	if err != nil {
+		return 0, err
+	}
+	res := []core.ZMember{}
+	if ok {
+		res, err = zrangeMembers(b, src, m, zm, r)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	old, ok, err := loadMeta(b, dst)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		if err := delKey(b, dst, old); err != nil {
+			return 0, err
+		}
+	}
+	if len(res) > 0 {
+		m, zm, err := s.loadOrNewZSet(b, dst)
+		if err != nil {
+			return 0, err
+		}
+		for _, member := range res {
+			if err := setScore(b, dst, m, &zm, member.Member, member.Score); err != nil {
+				return 0, err
+			}
+		}
+		if err := putZSet(b, dst, m, zm); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return len(res), nil
+}
+
+func (s *Store) ZRANK(key, member []byte) (int, float64, bool, error) {
+	return s.rankGeneric(key, member, false)
+}
+
+func (s *Store) ZREM(key []byte, members ...[]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, zm, ok, err := loadZSet(b, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	n := 0
+	for _, member := range members {
+		score, ok, err := getScore(b, key, m, member)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		if err := delScore(b, key, m, &zm, member, score); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	if err := putZSet(b, key, m, zm); err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *Store) ZREMRANGEBYLEX(key []byte, min, max core.LexBound) (int, error) {
+	res, err := s.zremRange(key, core.ZRange{By: core.ZRangeByLex, LexMin: min, LexMax: max})
+	return len(res), err
+}
+
+func (s *Store) ZREMRANGEBYRANK(key []byte, start, stop int) (int, error) {
+	res, err := s.zremRange(key, core.ZRange{Start: start, Stop: stop})
+	return len(res), err
+}
+
+func (s *Store) ZREMRANGEBYSCORE(key []byte, min, max core.ScoreBound) (int, error) {
+	res, err := s.zremRange(key, core.ZRange{By: core.ZRangeByScore, Min: min, Max: max})
+	return len(res), err
+}
+
+func (s *Store) ZREVRANK(key, member []byte) (int, float64, bool, error) {
+	return s.rankGeneric(key, member, true)
+}
+
+func (s *Store) ZSCORE(key, member []byte) (float64, bool, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, _, ok, err := getZSet(snap, key)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	return getScore(snap, key, m, member)
+}
+
+func (s *Store) ZUNION(opts core.ZAggOptions, keys ...[]byte) ([]core.ZMember, error) {
+	return s.zalgebraGeneric(keys, func(iters []core.ZMemberIter, fn func(core.ZMember) bool) error {
+		return core.ZUnion(iters, opts, fn)
+	})
+}
+
+func (s *Store) ZUNIONSTORE(dst []byte, opts core.ZAggOptions, keys ...[]byte) (int, error) {
+	return s.zstoreGeneric(dst, keys, func(iters []core.ZMemberIter, fn func(core.ZMember) bool) error {
+		return core.ZUnion(iters, opts, fn)
+	})
+}
+
+func (s *Store) countGeneric(key []byte, r core.ZRange) (int, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, zm, ok, err := getZSet(snap, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	n := 0
+	err = walkZRange(snap, key, m, zm, r, func(core.ZMember) bool {
+		n++
+		return true
+	})
+	return n, err
+}
+
+func (s *Store) rankGeneric(key, member []byte, rev bool) (int, float64, bool, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	m, zm, ok, err := getZSet(snap, key)
+	if err != nil || !ok {
+		return 0, 0, false, err
+	}
+	score, ok, err := getScore(snap, key, m, member)
+	if err != nil || !ok {
+		return 0, 0, false, err
+	}
+
+	// rank is the number of members before this one in the score index.
+	prefix := scoreKeyPrefix(key, m.version)
+	iter, err := snap.NewIter(&pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: scoreKey(key, m, score, member),
+	})
+	if err != nil {
+		return 0, 0, false, err
+	}
+	defer tryClose(iter)
+
+	rank := 0
+	for iter.First(); iter.Valid(); iter.Next() {
+		rank++
+	}
+	if err := iter.Error(); err != nil {
+		return 0, 0, false, err
+	}
+	if rev {
+		rank = zm.len - 1 - rank
+	}
+	return rank, score, true, nil
+}
+
+func (s *Store) zpopGeneric(key []byte, count int, rev bool) ([]core.ZMember, error) {
+	if count <= 0 {
+		return []core.ZMember{}, nil
+	}
+	return s.zremRange(key, core.ZRange{Start: 0, Stop: count - 1, Rev: rev})
+}
+
+// zremRange removes and returns members in the range.
+func (s *Store) zremRange(key []byte, r core.ZRange) ([]core.ZMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	m, zm, ok, err := loadZSet(b, key)
+	if err != nil || !ok {
+		return []core.ZMember{}, err
+	}
+	res, err := zrangeMembers(b, key, m, zm, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return res, nil
+	}
+
+	for _, member := range res {
+		if err := delScore(b, key, m, &zm, member.Member, member.Score); err != nil {
+			return nil, err
+		}
+	}
+	if err := putZSet(b, key, m, zm); err != nil {
+		return nil, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// zalgebraGeneric returns the result of merge sorted by score.
+func (s *Store) zalgebraGeneric(keys [][]byte, merge func(iters []core.ZMemberIter, fn func(core.ZMember) bool) error) ([]core.ZMember, error) {
+	snap := s.db.NewSnapshot()
+	defer tryClose(snap)
+
+	iters, closeIters, err := zmemberIters(snap, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer closeIters()
+
+	res := []core.ZMember{}
+	err = merge(iters, func(m core.ZMember) bool {
+		res = append(res, core.ZMember{Member: bytes.Clone(m.Member), Score: m.Score})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(res, core.CompareZMembers)
+	return res, nil
+}
+
+// zstoreGeneric replaces dst with the result of merge.
+// Result is written under a new version while inputs are read, so dst can be one of them.
+func (s *Store) zstoreGeneric(dst []byte, keys [][]byte, merge func(iters []core.ZMemberIter, fn func(core.ZMember) bool) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer tryClose(b)
+
+	iters, closeIters, err := zmemberIters(b, keys)
+	if err != nil {
+		return 0, err
+	}
+	defer closeIters()
+
+	version, err := s.nextVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	m := meta{typ: core.TypeZSet, version: version}
+	var zm zsetMeta
+
+	var werr error
+	err = merge(iters, func(member core.ZMember) bool {
+		werr = putScore(b, dst, m, member.Member, member.Score)
+		zm.len++
+		return werr == nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if werr != nil {
+		return 0, werr
+	}
+
+	old, ok, err := loadMeta(b, dst)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		if err := delKey(b, dst, old); err != nil {
+			return 0, err
+		}
+	}
+	if err := putZSet(b, dst, m, zm); err != nil {
+		return 0, err
+	}
+	if err := b.Commit(s.syncOpt); err != nil {
+		return 0, err
+	}
+	return zm.len, nil
+}
+
+// zmemberIters returns iterators over members of sorted sets and sets, missing keys are empty.
+// Returned func closes the underlying pebble iterators.
+func zmemberIters(r pebble.Reader, keys [][]byte) ([]core.ZMemberIter, func(), error) {
+	var pebbleIters []*pebble.Iterator
+	closeIters := func() {
+		for _, iter := range pebbleIters {
+			tryClose(iter)
+		}
+	}
+
+	iters := make([]core.ZMemberIter, len(keys))
+	for i, key := range keys {
+		m, ok, err := getMeta(r, key)
+		if err != nil {
+			closeIters()
+			return nil, nil, err
+		}
+		if !ok {
+			iters[i] = func() (core.ZMember, bool, error) {
+				return core.ZMember{}, false, nil
+			}
+			continue
+		}
+		if m.typ != core.TypeZSet && m.typ != core.TypeSet {
+			closeIters()
+			return nil, nil, core.ErrWrongType
+		}
+
+		prefix := dataKeyPrefix(key, m.version)
+		iter, err := r.NewIter(&pebble.IterOptions{
+			LowerBound: prefix,
+			UpperBound: prefixEnd(prefix),
+		})
+		if err != nil {
+			closeIters()
+			return nil, nil, err
+		}
+		pebbleIters = append(pebbleIters, iter)
+		iters[i] = zmemberIter(iter, len(prefix), m.typ == core.TypeSet)
+	}
+	return iters, closeIters, nil
+}
+
+// zmemberIter adapts iterator over members of a sorted set or a set, members of a set have score 1.
+func zmemberIter(iter *pebble.Iterator, prefixLen int, isSet bool) core.ZMemberIter {
+	started := false
+	return func() (core.ZMember, bool, error) {
+		var ok bool
+		if started {
+			ok = iter.Next()
+		} else {
+			ok, started = iter.First(), true
+		}
+		if !ok {
+			return core.ZMember{}, false, iter.Error()
+		}
+
+		m := core.ZMember{Member: iter.Key()[prefixLen:], Score: 1}
+		if !isSet {
+			score, err := decodeScore(iter.Value())
+			if err != nil {
+				return core.ZMember{}, false, err
+			}
+			m.Score = score
+		}
+		return m, true, nil
+	}
+}
+
+// loadOrNewZSet is like loadZSet but allocates an empty sorted set for a missing key,
+// it must be stored with putZSet.
+func (s *Store) loadOrNewZSet(b *pebble.Batch, key []byte) (meta, zsetMeta, error) {
+	m, zm, ok, err := loadZSet(b, key)
+	if err != nil || ok {
+		return m, zm, err
+	}
+	version, err := s.nextVersion(b)
+	if err != nil {
+		return meta{}, zsetMeta{}, err
+	}
+	return meta{typ: core.TypeZSet, version: version}, zsetMeta{}, nil
+}
+
+// getZSet is like getMeta but fails for keys that are not sorted sets.
+func getZSet(r pebble.Reader, key []byte) (meta, zsetMeta, bool, error) {
+	m, ok, err := getMeta(r, key)
+	return asZSet(key, m, ok, err)
+}
+
+// loadZSet is like loadMeta but fails for keys that are not sorted sets.
+func loadZSet(b *pebble.Batch, key []byte) (meta, zsetMeta, bool, error) {
+	m, ok, err := loadMeta(b, key)
+	return asZSet(key, m, ok, err)
+}
+
+func asZSet(key []byte, m meta, ok bool, err error) (meta, zsetMeta, bool, error) {
+	if err != nil || !ok {
+		return meta{}, zsetMeta{}, false, err
+	}
+	if m.typ != core.TypeZSet {
+		return meta{}, zsetMeta{}, false, core.ErrWrongType
+	}
+	zm, err := decodeZSetMeta(m)
+	if err != nil {
+		return meta{}, zsetMeta{}, false, fmt.Errorf("key %q: %w", key, err)
+	}
+	return m, zm, true, nil
+}
+
+// putZSet writes sorted set meta, empty sorted set is removed.
+func putZSet(b *pebble.Batch, key []byte, m meta, zm zsetMeta) error {
+	if zm.len == 0 {
+		return delKey(b, key, m)
+	}
+	m.payload = zm.encode()
+	return putMeta(b, key, m)
+}
+
+func getScore(r pebble.Reader, key []byte, m meta, member []byte) (float64, bool, error) {
+	val, ok, err := getValue(r, memberKey(key, m, member))
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	score, err := decodeScore(val)
+	if err != nil {
+		return 0, false, err
+	}
+	return score, true, nil
+}
+
+// setScore adds the member or updates its score, zm is updated accordingly.
+func setScore(b *pebble.Batch, key []byte, m meta, zm *zsetMeta, member []byte, score float64) error {
+	old, ok, err := getScore(b, key, m, member)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if err := b.Delete(scoreKey(key, m, old, member), nil); err != nil {
+			return err
+		}
+	} else {
+		zm.len++
+	}
+
+	return putScore(b, key, m, member, score)
+}
+
+// putScore writes the member with the score, the member must not exist.
+func putScore(b *pebble.Batch, key []byte, m meta, member []byte, score float64) error {
+	if err := b.Set(scoreKey(key, m, score, member), nil, nil); err != nil {
+		return err
+	}
+	val := binary.BigEndian.AppendUint64(nil, math.Float64bits(score))
+	return b.Set(memberKey(key, m, member), val, nil)
+}
+
+// delScore removes the existing member with the given score, zm is updated accordingly.
+func delScore(b *pebble.Batch, key []byte, m meta, zm *zsetMeta, member []byte, score float64) error {
+	zm.len--
+	if err := b.Delete(scoreKey(key, m, score, member), nil); err != nil {
+		return err
+	}
+	return b.Delete(memberKey(key, m, member), nil)
+}
+
+// zrangeMembers returns members in the range.
+func zrangeMembers(r pebble.Reader, key []byte, m meta, zm zsetMeta, rng core.ZRange) ([]core.ZMember, error) {
+	res := []core.ZMember{}
+	err := walkZRange(r, key, m, zm, rng, func(member core.ZMember) bool {
+		member.Member = bytes.Clone(member.Member)
+		res = append(res, member)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// walkZRange calls fn for members in the range in the range order until it returns false.
+// Member passed to fn is valid only until it returns.
+func walkZRange(r pebble.Reader, key []byte, m meta, zm zsetMeta, rng core.ZRange, fn func(member core.ZMember) bool) error {
+	prefix := scoreKeyPrefix(key, m.version)
+	iter, err := r.NewIter(&pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: prefixEnd(prefix),
+	})
+	if err != nil {
+		return err
+	}
+	defer tryClose(iter)
+
+	next := iter.Next
+	if rng.Rev {
+		next = iter.Prev
+	}
+
+	c := rng.Cursor(zm.len)
+	for valid := seekZRange(iter, prefix, rng); valid; valid = next() {
+		member, err := decodeScoreKey(iter.Key()[len(prefix):])
+		if err != nil {
+			return err
+		}
+		take, more := c.Next(member)
+		if take && !fn(member) {
+			break
+		}
+		if !more {
+			break
+		}
+	}
+	return iter.Error()
+}
+
+// seekZRange positions the iterator over the score index at the start of the range,
+// members before it are skipped by the range cursor.
+func seekZRange(iter *pebble.Iterator, prefix []byte, r core.ZRange) bool {
+	switch {
+	case r.By == core.ZRangeByScore && !r.Rev:
+		return iter.SeekGE(appendScore(bytes.Clone(prefix), r.Min.Value))
+	case r.By == core.ZRangeByScore:
+		return iter.SeekLT(prefixEnd(appendScore(bytes.Clone(prefix), r.Max.Value)))
+	// lex ranges assume that all members have the same score, like Redis does.
+	case r.By == core.ZRangeByLex && !r.Rev && r.LexMin.Inf == 0:
+		if !iter.First() {
+			return false
+		}
+		start := append(bytes.Clone(iter.Key()[:len(prefix)+8]), r.LexMin.Value...)
+		return iter.SeekGE(start)
+	case r.By == core.ZRangeByLex && r.Rev && r.LexMax.Inf == 0:
+		if !iter.Last() {
+			return false
+		}
+		// the smallest key after the max member.
+		end := append(bytes.Clone(iter.Key()[:len(prefix)+8]), r.LexMax.Value...)
+		return iter.SeekLT(append(end, 0))
+	case r.Rev:
+		return iter.Last()
+	default:
+		return iter.First()
+	}
+}
+
+func decodeScore(val []byte) (float64, error) {
+	if len(val) != 8 {
+		return 0, errCorruptedScore
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(val)), nil
+}
+
+func scoreKey(key []byte, m meta, score float64, member []byte) []byte {
+	res := appendScore(scoreKeyPrefix(key, m.version), score)
+	return append(res, member...)
+}
+
+// decodeScoreKey decodes a key of the score index without the prefix.
+func decodeScoreKey(k []byte) (core.ZMember, error) {
+	if len(k) < 8 {
+		return core.ZMember{}, errCorruptedScore
+	}
+	bits := binary.BigEndian.Uint64(k)
+	if bits&(1<<63) != 0 {
+		bits ^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return core.ZMember{Member: k[8:], Score: math.Float64frombits(bits)}, nil
+}
+
+// appendScore appends the score encoded so that byte order matches numeric order:
+// sign bit is flipped for positive numbers and all bits are flipped for negative ones.
+func appendScore(dst []byte, score float64) []byte {
+	if score == 0 {
+		// -0 is equal to 0.
+		score = 0
+	}
+	bits := math.Float64bits(score)
+	if bits&(1<<63) == 0 {
+		bits ^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return binary.BigEndian.AppendUint64(dst, bits)
+}
@@ -0,0 +1,313 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/tidwall/redcon"
+)
+
+// Bitmaps operations https://redis.io/commands/?group=bitmap
+
+func (s *Server) handleBITCOUNT(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'BITCOUNT' command")
+		return
+	}
+	// start and end go together.
+	if len(cmd.Args) == 3 {
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	rng, _, err := parseBitRange(cmd.Args[2:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	n, err := s.db.BITCOUNT(cmd.Args[1], rng)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt64(n)
+}
+
+func (s *Server) handleBITFIELD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'BITFIELD' command")
+		return
+	}
+
+	ops, err := parseBitField(cmd.Args[2:], false)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.BITFIELD(cmd.Args[1], ops)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteArray(len(res))
+	for _, v := range res {
+		if v == nil {
+			conn.WriteNull()
+		} else {
+			conn.WriteInt64(*v)
+		}
+	}
+}
+
+func (s *Server) handleBITFIELD_RO(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'BITFIELD_RO' command")
+		return
+	}
+
+	ops, err := parseBitField(cmd.Args[2:], true)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.BITFIELDRO(cmd.Args[1], ops)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteArray(len(res))
+	for _, v := range res {
+		conn.WriteInt64(v)
+	}
+}
+
+func (s *Server) handleBITOP(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for 'BITOP' command")
+		return
+	}
+
+	var op core.BitOp
+	switch strings.ToUpper(string(cmd.Args[1])) {
+	case "AND":
+		op = core.BitAnd
+	case "OR":
+		op = core.BitOr
+	case "XOR":
+		op = core.BitXor
+	case "NOT":
+		op = core.BitNot
+	default:
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+	keys := cmd.Args[3:]
+	if op == core.BitNot && len(keys) != 1 {
+		conn.WriteError("ERR BITOP NOT must be called with a single source key.")
+		return
+	}
+
+	n, err := s.db.BITOP(op, cmd.Args[2], keys...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleBITPOS(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'BITPOS' command")
+		return
+	}
+
+	bit, err := strconv.Atoi(string(cmd.Args[2]))
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+	if bit != 0 && bit != 1 {
+		conn.WriteError("ERR The bit argument must be 1 or 0.")
+		return
+	}
+	rng, hasEnd, err := parseBitRange(cmd.Args[3:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	pos, err := s.db.BITPOS(cmd.Args[1], bit, rng, hasEnd)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt64(pos)
+}
+
+func (s *Server) handleGETBIT(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'GETBIT' command")
+		return
+	}
+
+	offset, err := parseBitOffset(cmd.Args[2], 0)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	bit, err := s.db.GETBIT(cmd.Args[1], offset)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(bit)
+}
+
+func (s *Server) handleSETBIT(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'SETBIT' command")
+		return
+	}
+
+	offset, err := parseBitOffset(cmd.Args[2], 0)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	bit, err := strconv.Atoi(string(cmd.Args[3]))
+	if err != nil || (bit != 0 && bit != 1) {
+		conn.WriteError("ERR bit is not an integer or out of range")
+		return
+	}
+
+	old, err := s.db.SETBIT(cmd.Args[1], offset, bit)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(old)
+}
+
+// parseBitRange parses `[start [end [BYTE | BIT]]]` and reports whether end is set.
+func parseBitRange(args [][]byte) (core.BitRange, bool, error) {
+	rng := core.FullBitRange
+	if len(args) > 3 {
+		return rng, false, core.ErrSyntax
+	}
+
+	var err error
+	if len(args) > 0 {
+		if rng.Start, err = strconv.ParseInt(string(args[0]), 10, 64); err != nil {
+			return rng, false, core.ErrNotIntOrOutOfRange
+		}
+	}
+	if len(args) > 1 {
+		if rng.End, err = strconv.ParseInt(string(args[1]), 10, 64); err != nil {
+			return rng, false, core.ErrNotIntOrOutOfRange
+		}
+	}
+	if len(args) > 2 {
+		switch strings.ToUpper(string(args[2])) {
+		case "BYTE":
+		case "BIT":
+			rng.Bit = true
+		default:
+			return rng, false, core.ErrSyntax
+		}
+	}
+	return rng, len(args) > 1, nil
+}
+
+// parseBitOffset parses an offset in bits, for BITFIELD (non-zero width)
+// `#N` means the offset of N-th field of the width.
+func parseBitOffset(arg []byte, width int) (int64, error) {
+	str, multiply := string(arg), false
+	if width != 0 {
+		str, multiply = strings.CutPrefix(str, "#")
+	}
+	offset, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || offset < 0 || offset>>3 >= core.MaxStringSize {
+		return 0, errors.New("bit offset is not an integer or out of range")
+	}
+	if multiply {
+		offset *= int64(width)
+	}
+	if offset>>3 >= core.MaxStringSize {
+		return 0, errors.New("bit offset is not an integer or out of range")
+	}
+	return offset, nil
+}
+
+// parseBitField parses BITFIELD subcommands, readOnly allows only GET ones.
+func parseBitField(args [][]byte, readOnly bool) ([]core.BitFieldOp, error) {
+	var ops []core.BitFieldOp
+	overflow := core.BitFieldWrap
+	for i := 0; i < len(args); i++ {
+		op := core.BitFieldOp{Overflow: overflow}
+		switch sub := strings.ToUpper(string(args[i])); {
+		case sub == "GET" && len(args)-i > 2:
+			op.Kind = core.BitFieldGet
+		case sub == "SET" && len(args)-i > 3:
+			op.Kind = core.BitFieldSet
+		case sub == "INCRBY" && len(args)-i > 3:
+			op.Kind = core.BitFieldIncrBy
+		case sub == "OVERFLOW" && len(args)-i > 1:
+			i++
+			switch strings.ToUpper(string(args[i])) {
+			case "WRAP":
+				overflow = core.BitFieldWrap
+			case "SAT":
+				overflow = core.BitFieldSat
+			case "FAIL":
+				overflow = core.BitFieldFail
+			default:
+				return nil, errors.New("Invalid OVERFLOW type specified")
+			}
+			continue
+		default:
+			return nil, core.ErrSyntax
+		}
+
+		var err error
+		if op.Signed, op.Width, err = parseBitFieldType(args[i+1]); err != nil {
+			return nil, err
+		}
+		if op.Offset, err = parseBitOffset(args[i+2], op.Width); err != nil {
+			return nil, err
+		}
+		i += 2
+
+		if op.Kind != core.BitFieldGet {
+			i++
+			if op.Value, err = strconv.ParseInt(string(args[i]), 10, 64); err != nil {
+				return nil, core.ErrNotIntOrOutOfRange
+			}
+			if readOnly {
+				return nil, errors.New("BITFIELD_RO only supports the GET subcommand")
+			}
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// parseBitFieldType parses `i<bits>` or `u<bits>`, up to i64 and u63.
+func parseBitFieldType(arg []byte) (bool, int, error) {
+	str := strings.ToLower(string(arg))
+	if str != "" {
+		signed := str[0] == 'i'
+		width, err := strconv.Atoi(str[1:])
+		if (signed || str[0] == 'u') && err == nil && width >= 1 &&
+			(signed && width <= 64 || !signed && width <= 63) {
+			return signed, width, nil
+		}
+	}
+	return false, 0, errors.New("Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+}
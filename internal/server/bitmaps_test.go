@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cristalhq/testt"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestBITCOUNT(t *testing.T) {
+	/*
+		redis> SET mykey "foobar"
+		"OK"
+		redis> BITCOUNT mykey
+		(integer) 26
+		redis> BITCOUNT mykey 0 0
+		(integer) 4
+		redis> BITCOUNT mykey 1 1
+		(integer) 6
+		redis> BITCOUNT mykey 1 1 BYTE
+		(integer) 6
+		redis> BITCOUNT mykey 5 30 BIT
+		(integer) 17
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "mykey", "foobar", 0).Err()
+	testt.NoError(t, err)
+
+	n, err := client.BitCount(ctx, "mykey", nil).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(26))
+
+	n, err = client.BitCount(ctx, "mykey", &redis.BitCount{Start: 0, End: 0}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(4))
+
+	n, err = client.BitCount(ctx, "mykey", &redis.BitCount{Start: 1, End: 1, Unit: redis.BitCountIndexByte}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(6))
+
+	n, err = client.BitCount(ctx, "mykey", &redis.BitCount{Start: 5, End: 30, Unit: redis.BitCountIndexBit}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(17))
+
+	err = client.Do(ctx, "BITCOUNT", "mykey", 0).Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+
+	err = client.Do(ctx, "BITCOUNT", "mykey", 0, 1, "BITS").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+}
+
+func TestBITFIELD(t *testing.T) {
+	/*
+		redis> BITFIELD mykey INCRBY i5 100 1 GET u4 0
+		1) (integer) 1
+		2) (integer) 0
+		redis> BITFIELD mykey OVERFLOW FAIL INCRBY i5 100 20 SET u8 #1 42
+		1) (nil)
+		2) (integer) 0
+		redis> BITFIELD_RO mykey GET u8 8
+		1) (integer) 42
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	res, err := client.BitField(ctx, "mykey", "INCRBY", "i5", 100, 1, "GET", "u4", 0).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{1, 0})
+
+	val, err := client.Do(ctx, "BITFIELD", "mykey", "OVERFLOW", "FAIL", "INCRBY", "i5", 100, 20, "SET", "u8", "#1", 42).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, []any{nil, int64(0)})
+
+	res, err = client.BitFieldRO(ctx, "mykey", "u8", 8).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{42})
+
+	err = client.BitField(ctx, "mykey", "GET", "u64", 0).Err()
+	testt.MustEqual(t, err.Error(), "ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+
+	err = client.BitField(ctx, "mykey", "GET", "i8", -1).Err()
+	testt.MustEqual(t, err.Error(), "ERR bit offset is not an integer or out of range")
+
+	err = client.BitField(ctx, "mykey", "OVERFLOW", "NONE").Err()
+	testt.MustEqual(t, err.Error(), "ERR Invalid OVERFLOW type specified")
+
+	err = client.Do(ctx, "BITFIELD_RO", "mykey", "SET", "u8", 0, 1).Err()
+	testt.MustEqual(t, err.Error(), "ERR BITFIELD_RO only supports the GET subcommand")
+}
+
+func TestBITOP(t *testing.T) {
+	/*
+		redis> SET key1 "foobar"
+		"OK"
+		redis> SET key2 "abcdef"
+		"OK"
+		redis> BITOP AND dest key1 key2
+		(integer) 6
+		redis> GET dest
+		"`bc`ab"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "key1", "foobar", 0).Err()
+	testt.NoError(t, err)
+	err = client.Set(ctx, "key2", "abcdef", 0).Err()
+	testt.NoError(t, err)
+
+	n, err := client.BitOpAnd(ctx, "dest", "key1", "key2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(6))
+
+	val, err := client.Get(ctx, "dest").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "`bc`ab")
+
+	err = client.Do(ctx, "BITOP", "NOT", "dest", "key1", "key2").Err()
+	testt.MustEqual(t, err.Error(), "ERR BITOP NOT must be called with a single source key.")
+
+	err = client.Do(ctx, "BITOP", "NAND", "dest", "key1").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+}
+
+func TestBITPOS(t *testing.T) {
+	/*
+		redis> SET mykey "\xff\xf0\x00"
+		"OK"
+		redis> BITPOS mykey 0
+		(integer) 12
+		redis> SET mykey "\x00\xff\xf0"
+		"OK"
+		redis> BITPOS mykey 1 0
+		(integer) 8
+		redis> BITPOS mykey 1 2
+		(integer) 16
+		redis> BITPOS mykey 1 2 -1 BYTE
+		(integer) 16
+		redis> BITPOS mykey 1 7 15 BIT
+		(integer) 8
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "mykey", "\xff\xf0\x00", 0).Err()
+	testt.NoError(t, err)
+
+	pos, err := client.BitPos(ctx, "mykey", 0).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(12))
+
+	err = client.Set(ctx, "mykey", "\x00\xff\xf0", 0).Err()
+	testt.NoError(t, err)
+
+	pos, err = client.BitPos(ctx, "mykey", 1, 0).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(8))
+
+	pos, err = client.BitPos(ctx, "mykey", 1, 2).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(16))
+
+	pos, err = client.BitPosSpan(ctx, "mykey", 1, 2, -1, "byte").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(16))
+
+	pos, err = client.BitPosSpan(ctx, "mykey", 1, 7, 15, "bit").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(8))
+
+	err = client.BitPos(ctx, "mykey", 2).Err()
+	testt.MustEqual(t, err.Error(), "ERR The bit argument must be 1 or 0.")
+}
+
+func TestSETBIT(t *testing.T) {
+	/*
+		redis> SETBIT mykey 7 1
+		(integer) 0
+		redis> SETBIT mykey 7 0
+		(integer) 1
+		redis> GET mykey
+		"\x00"
+		redis> GETBIT mykey 7
+		(integer) 0
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	old, err := client.SetBit(ctx, "mykey", 7, 1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, old, int64(0))
+
+	old, err = client.SetBit(ctx, "mykey", 7, 0).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, old, int64(1))
+
+	val, err := client.Get(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "\x00")
+
+	bit, err := client.GetBit(ctx, "mykey", 7).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, bit, int64(0))
+
+	err = client.SetBit(ctx, "mykey", 1<<32, 1).Err()
+	testt.MustEqual(t, err.Error(), "ERR bit offset is not an integer or out of range")
+
+	err = client.SetBit(ctx, "mykey", 7, 2).Err()
+	testt.MustEqual(t, err.Error(), "ERR bit is not an integer or out of range")
+
+	err = client.LPush(ctx, "list", "a").Err()
+	testt.NoError(t, err)
+
+	err = client.GetBit(ctx, "list", 0).Err()
+	testt.MustEqual(t, err.Error(), "WRONGTYPE Operation against a key holding the wrong kind of value")
+}
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tidwall/redcon"
+)
+
+// Blocked clients are detached from redcon, so waiting doesn't stall its connection loop
+// and a closed connection is noticed while waiting. After that the server serves them itself.
+
+// client is a connection detached from redcon and served by the server.
+type client struct {
+	redcon.DetachedConn
+
+	// cmds are read by a separate goroutine, so closing is noticed during a wait.
+	cmds chan redcon.Command
+	// done is closed when the connection can't be read anymore, readErr is the reason.
+	done    chan struct{}
+	readErr error
+	// wake is signaled when one of the keys the client waits for might be ready.
+	wake chan struct{}
+
+	// req is a blocking request to serve after the current command.
+	req *blockRequest
+}
+
+type blockRequest struct {
+	keys [][]byte
+	// deadline is zero when the client waits forever.
+	deadline time.Time
+	// try writes a reply and reports true when the request is served.
+	try func(conn redcon.Conn) bool
+}
+
+func newClient(conn redcon.DetachedConn) *client {
+	c := &client{
+		DetachedConn: conn,
+		cmds:         make(chan redcon.Command, 128),
+		done:         make(chan struct{}),
+		wake:         make(chan struct{}, 1),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Detach returns the client itself, it's already detached from redcon.
+func (c *client) Detach() redcon.DetachedConn {
+	return c
+}
+
+func (c *client) readLoop() {
+	for {
+		cmd, err := c.ReadCommand()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				c.readErr = err
+			}
+			close(c.done)
+			return
+		}
+
+		select {
+		case c.cmds <- cloneCommand(cmd):
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// block serves the request right away if possible,
+// otherwise the client waits until one of the keys is ready or deadline is reached.
+// Command arguments used by try must not refer to redcon buffers, see [cloneCommand].
+func (s *Server) block(conn redcon.Conn, keys [][]byte, deadline time.Time, try func(conn redcon.Conn) bool) {
+	if try(conn) {
+		return
+	}
+
+	req := &blockRequest{keys: keys, deadline: deadline, try: try}
+	if c, ok := conn.(*client); ok {
+		c.req = req
+		return
+	}
+
+	c := newClient(conn.Detach())
+	c.req = req
+	go s.serveClient(c)
+}
+
+// serveClient serves a detached client until its connection is closed.
+func (s *Server) serveClient(c *client) {
+	err := s.serveCommands(c)
+	c.Close()
+	s.onClosed(c, err)
+}
+
+func (s *Server) serveCommands(c *client) error {
+	for {
+		if c.req != nil && !s.wait(c) {
+			return c.readErr
+		}
+		if err := c.Flush(); err != nil {
+			return err
+		}
+
+		select {
+		case cmd := <-c.cmds:
+			s.mux.ServeRESP(c, cmd)
+		case <-c.done:
+			return c.readErr
+		}
+	}
+}
+
+// wait serves blocking request of the client, reports false if the connection was closed.
+func (s *Server) wait(c *client) bool {
+	req := c.req
+	c.req = nil
+
+	s.waiters.add(c, req.keys)
+	defer s.waiters.remove(c, req.keys)
+
+	var timeout <-chan time.Time
+	if !req.deadline.IsZero() {
+		t := time.NewTimer(time.Until(req.deadline))
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	for {
+		// don't pop elements for a client that is gone.
+		select {
+		case <-c.done:
+			return false
+		default:
+		}
+
+		// the key might be ready before the client was added to waiters.
+		if req.try(c) {
+			return true
+		}
+
+		select {
+		case <-c.wake:
+		case <-timeout:
+			c.WriteNull()
+			return true
+		case <-c.done:
+			return false
+		}
+	}
+}
+
+// waiters is a registry of clients blocked on keys.
+// Clients are woken up one by one in the order they started to wait (FIFO).
+type waiters struct {
+	mu   sync.Mutex
+	keys map[string][]*client
+}
+
+func (w *waiters) add(c *client, keys [][]byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.keys == nil {
+		w.keys = make(map[string][]*client)
+	}
+	for _, key := range keys {
+		w.keys[string(key)] = append(w.keys[string(key)], c)
+	}
+}
+
+// remove removes the client from waiters of the keys.
+// Next waiters are woken up, so a signal consumed by the client is not lost.
+func (w *waiters) remove(c *client, keys [][]byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, key := range keys {
+		queue := w.keys[string(key)]
+		for i := range queue {
+			if queue[i] == c {
+				queue = append(queue[:i], queue[i+1:]...)
+				break
+			}
+		}
+		if len(queue) == 0 {
+			delete(w.keys, string(key))
+			continue
+		}
+		w.keys[string(key)] = queue
+		wakeUp(queue[0])
+	}
+}
+
+// removeClient removes the client from waiters of all keys.
+func (w *waiters) removeClient(c *client) {
+	w.mu.Lock()
+	var keys [][]byte
+	for key, queue := range w.keys {
+		for i := range queue {
+			if queue[i] == c {
+				keys = append(keys, []byte(key))
+				break
+			}
+		}
+	}
+	w.mu.Unlock()
+
+	w.remove(c, keys)
+}
+
+// signal wakes up the first client waiting for the key.
+func (w *waiters) signal(key []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if queue := w.keys[string(key)]; len(queue) > 0 {
+		wakeUp(queue[0])
+	}
+}
+
+func wakeUp(c *client) {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// parseTimeout parses timeout in seconds of blocking commands, zero deadline means forever.
+func parseTimeout(arg []byte) (time.Time, error) {
+	secs, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(secs) || math.IsInf(secs, 0) {
+		return time.Time{}, errors.New("timeout is not a float or out of range")
+	}
+	if secs < 0 {
+		return time.Time{}, errors.New("timeout is negative")
+	}
+	if secs == 0 {
+		return time.Time{}, nil
+	}
+	return time.Now().Add(time.Duration(secs * float64(time.Second))), nil
+}
+
+// parseTimeoutMs is like parseTimeout but for timeout in milliseconds, like BLOCK option of XREAD.
+func parseTimeoutMs(arg []byte) (time.Time, error) {
+	ms, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return time.Time{}, errors.New("timeout is not an integer or out of range")
+	}
+	if ms < 0 {
+		return time.Time{}, errors.New("timeout is negative")
+	}
+	if ms == 0 {
+		return time.Time{}, nil
+	}
+	return time.Now().Add(time.Duration(ms) * time.Millisecond), nil
+}
+
+// cloneCommand returns a copy of the command that doesn't refer to redcon buffers,
+// they are reused when the next command is read.
+func cloneCommand(cmd redcon.Command) redcon.Command {
+	res := redcon.Command{
+		Raw:  bytes.Clone(cmd.Raw),
+		Args: make([][]byte, len(cmd.Args)),
+	}
+	for i := range cmd.Args {
+		res.Args[i] = bytes.Clone(cmd.Args[i])
+	}
+	return res
+}
@@ -0,0 +1,419 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cristalhq/testt"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestBLMOVE(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+	other := testClient(t, addr)
+
+	res := make(chan string, 1)
+	go func() {
+		val, err := client.BLMove(ctx, "mylist", "myotherlist", "RIGHT", "LEFT", 0).Result()
+		testt.NoError(t, err)
+		res <- val
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	n, err := other.RPush(ctx, "mylist", "one", "two").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+	testt.MustEqual(t, <-res, "two")
+
+	vals, err := other.LRange(ctx, "myotherlist", 0, -1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"two"})
+}
+
+func TestBLMPOP(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Do(ctx, "BLMPOP", "0.1", "2", "mylist", "myotherlist", "LEFT").Err()
+	testt.MustEqual(t, err, redis.Nil)
+
+	_, err = client.RPush(ctx, "myotherlist", "one", "two", "three").Result()
+	testt.NoError(t, err)
+
+	key, vals, err := client.BLMPop(ctx, 0, "RIGHT", 2, "mylist", "myotherlist").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, key, "myotherlist")
+	testt.MustEqual(t, vals, []string{"three", "two"})
+}
+
+func TestBLPOP(t *testing.T) {
+	/*
+		redis> RPUSH list1 a b c
+		(integer) 3
+		redis> BLPOP list1 list2 0
+		1) "list1"
+		2) "a"
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	n, err := client.RPush(ctx, "list1", "a", "b", "c").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(3))
+
+	vals, err := client.BLPop(ctx, 0, "list1", "list2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"list1", "a"})
+}
+
+func TestBRPOP(t *testing.T) {
+	/*
+		redis> RPUSH list1 a b c
+		(integer) 3
+		redis> BRPOP list1 list2 0
+		1) "list1"
+		2) "c"
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	n, err := client.RPush(ctx, "list1", "a", "b", "c").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(3))
+
+	vals, err := client.BRPop(ctx, 0, "list1", "list2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"list1", "c"})
+}
+
+func TestBZMPOP(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+	other := testClient(t, addr)
+
+	err := client.Do(ctx, "BZMPOP", "0.1", "2", "myzset", "myotherzset", "MIN").Err()
+	testt.MustEqual(t, err, redis.Nil)
+
+	type popped struct {
+		key     string
+		members []redis.Z
+	}
+	res := make(chan popped, 1)
+	go func() {
+		key, members, err := client.BZMPop(ctx, 0, "MAX", 2, "myzset", "myotherzset").Result()
+		testt.NoError(t, err)
+		res <- popped{key, members}
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	err = other.ZAdd(ctx, "myotherzset", redis.Z{Score: 1, Member: "one"}, redis.Z{Score: 2, Member: "two"}, redis.Z{Score: 3, Member: "three"}).Err()
+	testt.NoError(t, err)
+
+	p := <-res
+	testt.MustEqual(t, p.key, "myotherzset")
+	testt.MustEqual(t, p.members, []redis.Z{{Score: 3, Member: "three"}, {Score: 2, Member: "two"}})
+}
+
+func TestBZPOPMAX(t *testing.T) {
+	/*
+		redis> DEL zset1 zset2
+		(integer) 0
+		redis> ZADD zset1 0 a 1 b 2 c
+		(integer) 3
+		redis> BZPOPMAX zset1 zset2 0
+		1) "zset1"
+		2) "c"
+		3) "2"
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	n, err := client.ZAdd(ctx, "zset1", redis.Z{Score: 0, Member: "a"}, redis.Z{Score: 1, Member: "b"}, redis.Z{Score: 2, Member: "c"}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(3))
+
+	res, err := client.BZPopMax(ctx, 0, "zset1", "zset2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res.Key, "zset1")
+	testt.MustEqual(t, res.Z, redis.Z{Score: 2, Member: "c"})
+}
+
+func TestBZPOPMIN(t *testing.T) {
+	/*
+		redis> DEL zset1 zset2
+		(integer) 0
+		redis> ZADD zset1 0 a 1 b 2 c
+		(integer) 3
+		redis> BZPOPMIN zset1 zset2 0
+		1) "zset1"
+		2) "a"
+		3) "0"
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+	other := testClient(t, addr)
+
+	n, err := client.ZAdd(ctx, "zset1", redis.Z{Score: 0, Member: "a"}, redis.Z{Score: 1, Member: "b"}, redis.Z{Score: 2, Member: "c"}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(3))
+
+	res, err := client.BZPopMin(ctx, 0, "zset1", "zset2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res.Key, "zset1")
+	testt.MustEqual(t, res.Z, redis.Z{Score: 0, Member: "a"})
+
+	popped := make(chan *redis.ZWithKey, 1)
+	go func() {
+		res, err := client.BZPopMin(ctx, 0, "zset2").Result()
+		testt.NoError(t, err)
+		popped <- res
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	err = other.ZUnionStore(ctx, "zset2", &redis.ZStore{Keys: []string{"zset1"}}).Err()
+	testt.NoError(t, err)
+
+	res = <-popped
+	testt.MustEqual(t, res.Key, "zset2")
+	testt.MustEqual(t, res.Z, redis.Z{Score: 1, Member: "b"})
+
+	err = client.Set(ctx, "key", "value", 0).Err()
+	testt.NoError(t, err)
+	err = client.Do(ctx, "BZPOPMIN", "key", "0").Err()
+	testt.MustEqual(t, err.Error(), "WRONGTYPE Operation against a key holding the wrong kind of value")
+
+	err = client.Do(ctx, "BZPOPMIN", "zset3", "0.1").Err()
+	testt.MustEqual(t, err, redis.Nil)
+}
+
+func TestLMPOP(t *testing.T) {
+	/*
+		redis> LMPOP 2 non1 non2 LEFT COUNT 10
+		(nil)
+		redis> LPUSH mylist "one" "two" "three" "four" "five"
+		(integer) 5
+		redis> LMPOP 1 mylist LEFT
+		1) "mylist"
+		2) 1) "five"
+		redis> LRANGE mylist 0 -1
+		1) "four"
+		2) "three"
+		3) "two"
+		4) "one"
+		redis> LMPOP 1 mylist RIGHT COUNT 10
+		1) "mylist"
+		2) 1) "one"
+		   2) "two"
+		   3) "three"
+		   4) "four"
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	_, _, err := client.LMPop(ctx, "LEFT", 10, "non1", "non2").Result()
+	testt.MustEqual(t, err, redis.Nil)
+
+	n, err := client.LPush(ctx, "mylist", "one", "two", "three", "four", "five").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(5))
+
+	key, vals, err := client.LMPop(ctx, "LEFT", 1, "mylist").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, key, "mylist")
+	testt.MustEqual(t, vals, []string{"five"})
+
+	key, vals, err = client.LMPop(ctx, "RIGHT", 10, "mylist").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, key, "mylist")
+	testt.MustEqual(t, vals, []string{"one", "two", "three", "four"})
+
+	err = client.Do(ctx, "LMPOP", "0", "mylist", "LEFT").Err()
+	testt.MustEqual(t, err.Error(), "ERR numkeys should be greater than 0")
+
+	err = client.Do(ctx, "LMPOP", "1", "mylist", "LEFT", "COUNT", "0").Err()
+	testt.MustEqual(t, err.Error(), "ERR count should be greater than 0")
+}
+
+func TestZMPOP(t *testing.T) {
+	/*
+		redis> ZMPOP 1 notsuchkey MIN
+		(nil)
+		redis> ZADD myzset 1 "one" 2 "two" 3 "three"
+		(integer) 3
+		redis> ZMPOP 1 myzset MIN
+		1) "myzset"
+		2) 1) 1) "one"
+		      2) "1"
+		redis> ZRANGE myzset 0 -1 WITHSCORES
+		1) "two"
+		2) "2"
+		3) "three"
+		4) "3"
+		redis> ZMPOP 1 myzset MAX COUNT 10
+		1) "myzset"
+		2) 1) 1) "three"
+		      2) "3"
+		   2) 1) "two"
+		      2) "2"
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	_, _, err := client.ZMPop(ctx, "MIN", 1, "notsuchkey").Result()
+	testt.MustEqual(t, err, redis.Nil)
+
+	n, err := client.ZAdd(ctx, "myzset", redis.Z{Score: 1, Member: "one"}, redis.Z{Score: 2, Member: "two"}, redis.Z{Score: 3, Member: "three"}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(3))
+
+	key, members, err := client.ZMPop(ctx, "MIN", 1, "myzset").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, key, "myzset")
+	testt.MustEqual(t, members, []redis.Z{{Score: 1, Member: "one"}})
+
+	key, members, err = client.ZMPop(ctx, "MAX", 10, "myzset").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, key, "myzset")
+	testt.MustEqual(t, members, []redis.Z{{Score: 3, Member: "three"}, {Score: 2, Member: "two"}})
+
+	err = client.Do(ctx, "ZMPOP", "1", "myzset", "AVG").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+
+	err = client.Do(ctx, "ZMPOP", "1", "myzset", "MIN", "COUNT", "0").Err()
+	testt.MustEqual(t, err.Error(), "ERR count should be greater than 0")
+}
+
+func TestBlockingTimeout(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	start := time.Now()
+	err := client.Do(ctx, "BLPOP", "mylist", "0.1").Err()
+	testt.MustEqual(t, err, redis.Nil)
+	if time.Since(start) < 100*time.Millisecond {
+		t.Fatal("returned before timeout")
+	}
+
+	err = client.Do(ctx, "BLPOP", "mylist", "-1").Err()
+	testt.MustEqual(t, err.Error(), "ERR timeout is negative")
+
+	err = client.Do(ctx, "BLPOP", "mylist", "abc").Err()
+	testt.MustEqual(t, err.Error(), "ERR timeout is not a float or out of range")
+
+	// the connection is still served after the timeout.
+	err = client.Set(ctx, "key", "value", 0).Err()
+	testt.NoError(t, err)
+}
+
+func TestBlockingFIFO(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	other := testClient(t, addr)
+
+	res := make(chan string, 3)
+	for _, name := range []string{"first", "second", "third"} {
+		client := testClient(t, addr)
+		go func(name string) {
+			_, err := client.BLPop(ctx, 0, "mylist").Result()
+			testt.NoError(t, err)
+			res <- name
+		}(name)
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	for _, want := range []string{"first", "second", "third"} {
+		_, err := other.RPush(ctx, "mylist", "a").Result()
+		testt.NoError(t, err)
+		testt.MustEqual(t, <-res, want)
+	}
+}
+
+func TestBlockingSameConnection(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		PoolSize: 1,
+	})
+	other := testClient(t, addr)
+
+	for i := 0; i < 3; i++ {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			_, err := other.RPush(ctx, "mylist", "a").Result()
+			testt.NoError(t, err)
+		}()
+
+		vals, err := client.BLPop(ctx, 0, "mylist").Result()
+		testt.NoError(t, err)
+		testt.MustEqual(t, vals, []string{"mylist", "a"})
+
+		err = client.Set(ctx, "key", "value", 0).Err()
+		testt.NoError(t, err)
+	}
+}
+
+func TestBlockingClientClosed(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	conn, err := net.Dial("tcp", addr)
+	testt.NoError(t, err)
+	_, err = conn.Write([]byte("*3\r\n$5\r\nBLPOP\r\n$6\r\nmylist\r\n$1\r\n0\r\n"))
+	testt.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	testt.NoError(t, conn.Close())
+	time.Sleep(50 * time.Millisecond)
+
+	_, err = client.RPush(ctx, "mylist", "a").Result()
+	testt.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	n, err := client.LLen(ctx, "mylist").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+}
+
+func TestBlockingZSetClientClosed(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	conn, err := net.Dial("tcp", addr)
+	testt.NoError(t, err)
+	_, err = conn.Write([]byte("*3\r\n$8\r\nBZPOPMIN\r\n$6\r\nmyzset\r\n$1\r\n0\r\n"))
+	testt.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	testt.NoError(t, conn.Close())
+	time.Sleep(50 * time.Millisecond)
+
+	err = client.ZAdd(ctx, "myzset", redis.Z{Score: 1, Member: "one"}).Err()
+	testt.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	n, err := client.ZCard(ctx, "myzset").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+}
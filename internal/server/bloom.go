@@ -0,0 +1,269 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/tidwall/redcon"
+)
+
+// Bloom filter operations https://redis.io/commands/?group=bf
+// Cuckoo filter operations https://redis.io/commands/?group=cf
+
+func (s *Server) handleBFADD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'BF.ADD' command")
+		return
+	}
+
+	res, err := s.db.BFMADD(cmd.Args[1], cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBool(conn, res[0])
+}
+
+func (s *Server) handleBFEXISTS(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'BF.EXISTS' command")
+		return
+	}
+
+	res, err := s.db.BFMEXISTS(cmd.Args[1], cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBool(conn, res[0])
+}
+
+func (s *Server) handleBFINFO(conn redcon.Conn, cmd redcon.Command) {
+	switch {
+	case len(cmd.Args) < 2:
+		conn.WriteError("ERR wrong number of arguments for 'BF.INFO' command")
+		return
+	case len(cmd.Args) > 3:
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	info, err := s.db.BFINFO(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	fields := []struct {
+		opt, name string
+		value     int64
+	}{
+		{"CAPACITY", "Capacity", info.Capacity},
+		{"SIZE", "Size", info.Size},
+		{"FILTERS", "Number of filters", info.Filters},
+		{"ITEMS", "Number of items inserted", info.Items},
+		{"EXPANSION", "Expansion rate", info.Expansion},
+	}
+	// non scaling filters have no expansion rate.
+	writeValue := func(value int64, opt string) {
+		if opt == "EXPANSION" && value == 0 {
+			conn.WriteNull()
+			return
+		}
+		conn.WriteInt64(value)
+	}
+
+	if len(cmd.Args) == 3 {
+		opt := strings.ToUpper(string(cmd.Args[2]))
+		for _, f := range fields {
+			if f.opt == opt {
+				conn.WriteArray(1)
+				writeValue(f.value, f.opt)
+				return
+			}
+		}
+		conn.WriteError("ERR Invalid information value")
+		return
+	}
+	conn.WriteArray(2 * len(fields))
+	for _, f := range fields {
+		conn.WriteBulkString(f.name)
+		writeValue(f.value, f.opt)
+	}
+}
+
+func (s *Server) handleBFMADD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'BF.MADD' command")
+		return
+	}
+
+	items := cmd.Args[2:]
+	res, err := s.db.BFMADD(cmd.Args[1], items...)
+	if err != nil && !errors.Is(err, core.ErrBloomFull) {
+		writeError(conn, err)
+		return
+	}
+	// items after the filter got full are replied with the error.
+	conn.WriteArray(len(items))
+	for _, ok := range res {
+		writeBool(conn, ok)
+	}
+	for range items[len(res):] {
+		writeError(conn, err)
+	}
+}
+
+func (s *Server) handleBFMEXISTS(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'BF.MEXISTS' command")
+		return
+	}
+
+	res, err := s.db.BFMEXISTS(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteArray(len(res))
+	for _, ok := range res {
+		writeBool(conn, ok)
+	}
+}
+
+func (s *Server) handleBFRESERVE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for 'BF.RESERVE' command")
+		return
+	}
+
+	opts := core.BloomOptions{Expansion: core.BloomDefaultExpansion}
+	var err error
+	opts.ErrorRate, err = strconv.ParseFloat(string(cmd.Args[2]), 64)
+	switch {
+	case err != nil:
+		conn.WriteError("ERR bad error rate")
+		return
+	case opts.ErrorRate <= 0 || opts.ErrorRate >= 1:
+		writeError(conn, core.ErrBloomErrorRate)
+		return
+	}
+	opts.Capacity, err = strconv.ParseInt(string(cmd.Args[3]), 10, 64)
+	switch {
+	case err != nil:
+		conn.WriteError("ERR bad capacity")
+		return
+	case opts.Capacity <= 0:
+		writeError(conn, core.ErrBloomCapacity)
+		return
+	}
+
+	hasExpansion := false
+	for args := cmd.Args[4:]; len(args) > 0; args = args[1:] {
+		switch strings.ToUpper(string(args[0])) {
+		case "NONSCALING":
+			opts.NonScaling = true
+		case "EXPANSION":
+			if len(args) < 2 {
+				writeError(conn, core.ErrSyntax)
+				return
+			}
+			opts.Expansion, err = strconv.ParseInt(string(args[1]), 10, 64)
+			switch {
+			case err != nil:
+				conn.WriteError("ERR bad expansion")
+				return
+			case opts.Expansion < 1:
+				conn.WriteError("ERR expansion should be greater or equal to 1")
+				return
+			}
+			hasExpansion = true
+			args = args[1:]
+		default:
+			writeError(conn, core.ErrSyntax)
+			return
+		}
+	}
+	if opts.NonScaling && hasExpansion {
+		conn.WriteError("ERR nonscaling filters cannot expand")
+		return
+	}
+
+	if err := s.db.BFRESERVE(cmd.Args[1], opts); err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteString("OK")
+}
+
+func (s *Server) handleCFADD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'CF.ADD' command")
+		return
+	}
+
+	if err := s.db.CFADD(cmd.Args[1], cmd.Args[2]); err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(1)
+}
+
+func (s *Server) handleCFADDNX(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'CF.ADDNX' command")
+		return
+	}
+
+	ok, err := s.db.CFADDNX(cmd.Args[1], cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBool(conn, ok)
+}
+
+func (s *Server) handleCFCOUNT(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'CF.COUNT' command")
+		return
+	}
+
+	n, err := s.db.CFCOUNT(cmd.Args[1], cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt64(n)
+}
+
+func (s *Server) handleCFDEL(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'CF.DEL' command")
+		return
+	}
+
+	ok, err := s.db.CFDEL(cmd.Args[1], cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBool(conn, ok)
+}
+
+func (s *Server) handleCFEXISTS(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'CF.EXISTS' command")
+		return
+	}
+
+	ok, err := s.db.CFEXISTS(cmd.Args[1], cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBool(conn, ok)
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cristalhq/testt"
+)
+
+func TestBFADD(t *testing.T) {
+	/*
+		redis> BF.ADD bf item1
+		(integer) 1
+		redis> BF.ADD bf item1
+		(integer) 0
+		redis> BF.EXISTS bf item1
+		(integer) 1
+		redis> BF.EXISTS bf item2
+		(integer) 0
+		redis> BF.MADD bf item1 item2 item3
+		1) (integer) 0
+		2) (integer) 1
+		3) (integer) 1
+		redis> BF.MEXISTS bf item1 item2 item4
+		1) (integer) 1
+		2) (integer) 1
+		3) (integer) 0
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	n, err := client.Do(ctx, "BF.ADD", "bf", "item1").Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.Do(ctx, "BF.ADD", "bf", "item1").Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+
+	n, err = client.Do(ctx, "BF.EXISTS", "bf", "item1").Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.Do(ctx, "BF.EXISTS", "bf", "item2").Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+
+	res, err := client.Do(ctx, "BF.MADD", "bf", "item1", "item2", "item3").Int64Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{0, 1, 1})
+
+	res, err = client.Do(ctx, "BF.MEXISTS", "bf", "item1", "item2", "item4").Int64Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{1, 1, 0})
+
+	err = client.Set(ctx, "str", "value", 0).Err()
+	testt.NoError(t, err)
+
+	err = client.Do(ctx, "BF.ADD", "str", "item1").Err()
+	testt.MustEqual(t, err.Error(), "WRONGTYPE Operation against a key holding the wrong kind of value")
+
+	typ, err := client.Type(ctx, "bf").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "MBbloom--")
+}
+
+func TestBFRESERVE(t *testing.T) {
+	/*
+		redis> BF.RESERVE bf 0.01 1000
+		OK
+		redis> BF.RESERVE bf 0.01 1000
+		(error) ERR item exists
+		redis> BF.RESERVE bf_exp 0.01 1000 EXPANSION 2
+		OK
+		redis> BF.RESERVE bf_non 0.01 1000 NONSCALING
+		OK
+		redis> BF.INFO bf_non EXPANSION
+		1) (nil)
+		redis> BF.INFO bf CAPACITY
+		1) (integer) 1000
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Do(ctx, "BF.RESERVE", "bf", "0.01", "1000").Err()
+	testt.NoError(t, err)
+
+	err = client.Do(ctx, "BF.RESERVE", "bf", "0.01", "1000").Err()
+	testt.MustEqual(t, err.Error(), "ERR item exists")
+
+	err = client.Do(ctx, "BF.RESERVE", "bf_exp", "0.01", "1000", "EXPANSION", "2").Err()
+	testt.NoError(t, err)
+
+	err = client.Do(ctx, "BF.RESERVE", "bf_non", "0.01", "1000", "NONSCALING").Err()
+	testt.NoError(t, err)
+
+	res, err := client.Do(ctx, "BF.INFO", "bf_non", "EXPANSION").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{nil})
+
+	res, err = client.Do(ctx, "BF.INFO", "bf", "CAPACITY").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{int64(1000)})
+
+	res, err = client.Do(ctx, "BF.INFO", "bf").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{
+		"Capacity", int64(1000),
+		"Size", int64(1199),
+		"Number of filters", int64(1),
+		"Number of items inserted", int64(0),
+		"Expansion rate", int64(2),
+	})
+
+	err = client.Do(ctx, "BF.INFO", "nokey").Err()
+	testt.MustEqual(t, err.Error(), "ERR not found")
+
+	cases := []struct {
+		args []any
+		err  string
+	}{
+		{[]any{"BF.RESERVE", "x", "1", "10"}, "ERR (0 < error rate range < 1)"},
+		{[]any{"BF.RESERVE", "x", "abc", "10"}, "ERR bad error rate"},
+		{[]any{"BF.RESERVE", "x", "0.1", "0"}, "ERR (capacity should be larger than 0)"},
+		{[]any{"BF.RESERVE", "x", "0.01", "9223372036854775807"}, "ERR Insufficient memory to create filter"},
+		{[]any{"BF.RESERVE", "x", "0.0001", "100000000000"}, "ERR Insufficient memory to create filter"},
+		{[]any{"BF.RESERVE", "x", "0.1", "10", "EXPANSION", "0"}, "ERR expansion should be greater or equal to 1"},
+		{[]any{"BF.RESERVE", "x", "0.1", "10", "EXPANSION", "2", "NONSCALING"}, "ERR nonscaling filters cannot expand"},
+		{[]any{"BF.RESERVE", "x", "0.1"}, "ERR wrong number of arguments for 'BF.RESERVE' command"},
+	}
+	for _, tc := range cases {
+		err := client.Do(ctx, tc.args...).Err()
+		testt.MustEqual(t, err.Error(), tc.err)
+	}
+
+	// rejected filters are not created, BF.ADD creates a default one.
+	added, err := client.Do(ctx, "BF.ADD", "x", "item").Bool()
+	testt.NoError(t, err)
+	testt.MustEqual(t, added, true)
+}
+
+func TestBFMADDNonScaling(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Do(ctx, "BF.RESERVE", "bf", "0.01", "2", "NONSCALING").Err()
+	testt.NoError(t, err)
+
+	res, err := client.Do(ctx, "BF.MADD", "bf", "item1", "item2", "item3").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 3)
+	testt.MustEqual(t, res[0], any(int64(1)))
+	testt.MustEqual(t, res[1], any(int64(1)))
+	testt.MustEqual(t, res[2].(error).Error(), "ERR non scaling filter is full")
+}
+
+func TestCFADD(t *testing.T) {
+	/*
+		redis> CF.ADD cf item1
+		(integer) 1
+		redis> CF.ADD cf item1
+		(integer) 1
+		redis> CF.COUNT cf item1
+		(integer) 2
+		redis> CF.ADDNX cf item1
+		(integer) 0
+		redis> CF.ADDNX cf item2
+		(integer) 1
+		redis> CF.DEL cf item1
+		(integer) 1
+		redis> CF.EXISTS cf item1
+		(integer) 1
+		redis> CF.DEL cf item1
+		(integer) 1
+		redis> CF.EXISTS cf item1
+		(integer) 0
+		redis> CF.DEL cf item1
+		(integer) 0
+		redis> CF.DEL nokey item1
+		(error) ERR Not found
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	steps := []struct {
+		args []any
+		want int64
+	}{
+		{[]any{"CF.ADD", "cf", "item1"}, 1},
+		{[]any{"CF.ADD", "cf", "item1"}, 1},
+		{[]any{"CF.COUNT", "cf", "item1"}, 2},
+		{[]any{"CF.ADDNX", "cf", "item1"}, 0},
+		{[]any{"CF.ADDNX", "cf", "item2"}, 1},
+		{[]any{"CF.DEL", "cf", "item1"}, 1},
+		{[]any{"CF.EXISTS", "cf", "item1"}, 1},
+		{[]any{"CF.DEL", "cf", "item1"}, 1},
+		{[]any{"CF.EXISTS", "cf", "item1"}, 0},
+		{[]any{"CF.DEL", "cf", "item1"}, 0},
+		{[]any{"CF.COUNT", "nokey", "item1"}, 0},
+		{[]any{"CF.EXISTS", "nokey", "item1"}, 0},
+	}
+	for _, step := range steps {
+		n, err := client.Do(ctx, step.args...).Int64()
+		testt.NoError(t, err)
+		testt.MustEqual(t, n, step.want)
+	}
+
+	err := client.Do(ctx, "CF.DEL", "nokey", "item1").Err()
+	testt.MustEqual(t, err.Error(), "ERR Not found")
+
+	typ, err := client.Type(ctx, "cf").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "MBbloomCF")
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/tidwall/redcon"
+)
+
+// writeError writes err as RESP error, errors without a prefix are sent with ERR prefix.
+func writeError(conn redcon.Conn, err error) {
+	var e *core.Error
+	if errors.As(err, &e) {
+		conn.WriteError(e.Error())
+		return
+	}
+	conn.WriteError(core.PrefixErr + " " + err.Error())
+}
+
+// writeExpireError is like writeError but mentions the command for invalid expire time like Redis does.
+func writeExpireError(conn redcon.Conn, err error, name string) {
+	if errors.Is(err, core.ErrInvalidExpireTime) {
+		conn.WriteError(err.Error() + " in '" + strings.ToLower(name) + "' command")
+		return
+	}
+	writeError(conn, err)
+}
+
+// writeBulkOrNil writes val, or nil bulk if the key is missing.
+func writeBulkOrNil(conn redcon.Conn, val []byte, err error) {
+	switch {
+	case errors.Is(err, core.ErrKeyNotFound):
+		conn.WriteNull()
+	case err != nil:
+		writeError(conn, err)
+	default:
+		conn.WriteBulk(val)
+	}
+}
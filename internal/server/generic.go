@@ -0,0 +1,344 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/tidwall/redcon"
+)
+
+// Generic operations https://redis.io/commands/?group=generic
+
+func (s *Server) handleCOPY(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'COPY' command")
+		return
+	}
+
+	var replace bool
+	for i := 3; i < len(cmd.Args); i++ {
+		switch strings.ToUpper(string(cmd.Args[i])) {
+		case "REPLACE":
+			replace = true
+		case "DB":
+			// there is only one database.
+			if i+1 == len(cmd.Args) {
+				writeError(conn, core.ErrSyntax)
+				return
+			}
+			i++
+			if string(cmd.Args[i]) != "0" {
+				conn.WriteError("ERR DB index is out of range")
+				return
+			}
+		default:
+			writeError(conn, core.ErrSyntax)
+			return
+		}
+	}
+
+	ok, err := s.db.COPY(cmd.Args[1], cmd.Args[2], replace)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	if ok {
+		s.waiters.signal(cmd.Args[2])
+	}
+	writeBool(conn, ok)
+}
+
+func (s *Server) handleDEL(conn redcon.Conn, cmd redcon.Command) {
+	s.keysGeneric(conn, cmd, "DEL", s.db.DEL)
+}
+
+func (s *Server) handleEXISTS(conn redcon.Conn, cmd redcon.Command) {
+	s.keysGeneric(conn, cmd, "EXISTS", s.db.EXISTS)
+}
+
+func (s *Server) handleEXPIRE(conn redcon.Conn, cmd redcon.Command) {
+	s.expireGeneric(conn, cmd, "EXPIRE", s.db.EXPIRE)
+}
+
+func (s *Server) handleEXPIREAT(conn redcon.Conn, cmd redcon.Command) {
+	s.expireGeneric(conn, cmd, "EXPIREAT", s.db.EXPIREAT)
+}
+
+func (s *Server) handleEXPIRETIME(conn redcon.Conn, cmd redcon.Command) {
+	s.ttlGeneric(conn, cmd, "EXPIRETIME", s.db.EXPIRETIME)
+}
+
+func (s *Server) handleFLUSHDB(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) > 2 {
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+	if len(cmd.Args) == 2 {
+		// both modes are synchronous here.
+		switch strings.ToUpper(string(cmd.Args[1])) {
+		case "ASYNC", "SYNC":
+		default:
+			writeError(conn, core.ErrSyntax)
+			return
+		}
+	}
+
+	if err := s.db.FLUSHDB(); err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteString("OK")
+}
+
+func (s *Server) handleKEYS(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 2 {
+		conn.WriteError("ERR wrong number of arguments for 'KEYS' command")
+		return
+	}
+
+	keys, err := s.db.KEYS(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	conn.WriteArray(len(keys))
+	for _, key := range keys {
+		conn.WriteBulk(key)
+	}
+}
+
+func (s *Server) handlePERSIST(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 2 {
+		conn.WriteError("ERR wrong number of arguments for 'PERSIST' command")
+		return
+	}
+
+	ok, err := s.db.PERSIST(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBool(conn, ok)
+}
+
+func (s *Server) handlePEXPIRE(conn redcon.Conn, cmd redcon.Command) {
+	s.expireGeneric(conn, cmd, "PEXPIRE", s.db.PEXPIRE)
+}
+
+func (s *Server) handlePEXPIREAT(conn redcon.Conn, cmd redcon.Command) {
+	s.expireGeneric(conn, cmd, "PEXPIREAT", s.db.PEXPIREAT)
+}
+
+func (s *Server) handlePEXPIRETIME(conn redcon.Conn, cmd redcon.Command) {
+	s.ttlGeneric(conn, cmd, "PEXPIRETIME", s.db.PEXPIRETIME)
+}
+
+func (s *Server) handlePTTL(conn redcon.Conn, cmd redcon.Command) {
+	s.ttlGeneric(conn, cmd, "PTTL", s.db.PTTL)
+}
+
+func (s *Server) handleRENAME(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'RENAME' command")
+		return
+	}
+
+	err := s.db.RENAME(cmd.Args[1], cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	s.waiters.signal(cmd.Args[2])
+	conn.WriteString("OK")
+}
+
+func (s *Server) handleRENAMENX(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'RENAMENX' command")
+		return
+	}
+
+	ok, err := s.db.RENAMENX(cmd.Args[1], cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	if ok {
+		s.waiters.signal(cmd.Args[2])
+	}
+	writeBool(conn, ok)
+}
+
+func (s *Server) handleSCAN(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'SCAN' command")
+		return
+	}
+
+	cursor, err := strconv.ParseUint(string(cmd.Args[1]), 10, 64)
+	if err != nil {
+		conn.WriteError("ERR invalid cursor")
+		return
+	}
+
+	var opts core.ScanOptions
+	for i := 2; i < len(cmd.Args); i++ {
+		if i+1 == len(cmd.Args) {
+			writeError(conn, core.ErrSyntax)
+			return
+		}
+
+		switch strings.ToUpper(string(cmd.Args[i])) {
+		case "MATCH":
+			opts.Match = cmd.Args[i+1]
+		case "COUNT":
+			count, err := strconv.ParseInt(string(cmd.Args[i+1]), 10, 64)
+			if err != nil {
+				writeError(conn, core.ErrNotIntOrOutOfRange)
+				return
+			}
+			if count < 1 {
+				writeError(conn, core.ErrSyntax)
+				return
+			}
+			opts.Count = int(count)
+		case "TYPE":
+			opts.Type = strings.ToLower(string(cmd.Args[i+1]))
+		default:
+			writeError(conn, core.ErrSyntax)
+			return
+		}
+		i++
+	}
+
+	keys, next, err := s.db.SCAN(cursor, opts)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	conn.WriteArray(2)
+	conn.WriteBulkString(strconv.FormatUint(next, 10))
+	conn.WriteArray(len(keys))
+	for _, key := range keys {
+		conn.WriteBulk(key)
+	}
+}
+
+func (s *Server) handleTOUCH(conn redcon.Conn, cmd redcon.Command) {
+	s.keysGeneric(conn, cmd, "TOUCH", s.db.TOUCH)
+}
+
+func (s *Server) handleTTL(conn redcon.Conn, cmd redcon.Command) {
+	s.ttlGeneric(conn, cmd, "TTL", s.db.TTL)
+}
+
+func (s *Server) handleTYPE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 2 {
+		conn.WriteError("ERR wrong number of arguments for 'TYPE' command")
+		return
+	}
+
+	typ, err := s.db.TYPE(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteString(typ)
+}
+
+func (s *Server) handleUNLINK(conn redcon.Conn, cmd redcon.Command) {
+	s.keysGeneric(conn, cmd, "UNLINK", s.db.UNLINK)
+}
+
+func (s *Server) keysGeneric(conn redcon.Conn, cmd redcon.Command, name string, fn func(keys ...[]byte) (int, error)) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	n, err := fn(cmd.Args[1:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) ttlGeneric(conn redcon.Conn, cmd redcon.Command, name string, fn func(key []byte) (int64, error)) {
+	if len(cmd.Args) != 2 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	val, err := fn(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt64(val)
+}
+
+func (s *Server) expireGeneric(conn redcon.Conn, cmd redcon.Command, name string, fn func(key []byte, d int64, cond core.ExpireCond) (bool, error)) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	d, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+
+	cond, err := parseExpireCond(cmd.Args[3:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	ok, err := fn(cmd.Args[1], d, cond)
+	if err != nil {
+		writeExpireError(conn, err, name)
+		return
+	}
+	writeBool(conn, ok)
+}
+
+func parseExpireCond(args [][]byte) (core.ExpireCond, error) {
+	var cond core.ExpireCond
+	for _, arg := range args {
+		switch strings.ToUpper(string(arg)) {
+		case "NX":
+			cond |= core.ExpireNX
+		case "XX":
+			cond |= core.ExpireXX
+		case "GT":
+			cond |= core.ExpireGT
+		case "LT":
+			cond |= core.ExpireLT
+		default:
+			return 0, errors.New("Unsupported option " + string(arg))
+		}
+	}
+
+	switch {
+	case cond&core.ExpireNX != 0 && cond != core.ExpireNX:
+		return 0, errors.New("NX and XX, GT or LT options at the same time are not compatible")
+	case cond&core.ExpireGT != 0 && cond&core.ExpireLT != 0:
+		return 0, errors.New("GT and LT options at the same time are not compatible")
+	}
+	return cond, nil
+}
+
+func writeBool(conn redcon.Conn, ok bool) {
+	if ok {
+		conn.WriteInt(1)
+	} else {
+		conn.WriteInt(0)
+	}
+}
@@ -0,0 +1,553 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cristalhq/testt"
+)
+
+func TestCOPY(t *testing.T) {
+	/*
+		redis> SET dolly "sheep"
+		"OK"
+		redis> COPY dolly clone
+		(integer) 1
+		redis> GET clone
+		"sheep"
+		redis> COPY dolly clone
+		(integer) 0
+		redis> COPY dolly clone REPLACE
+		(integer) 1
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "dolly", "sheep", 0).Err()
+	testt.NoError(t, err)
+
+	n, err := client.Copy(ctx, "dolly", "clone", 0, false).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	val, err := client.Get(ctx, "clone").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "sheep")
+
+	n, err = client.Do(ctx, "COPY", "dolly", "clone").Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+
+	n, err = client.Do(ctx, "COPY", "dolly", "clone", "REPLACE").Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+}
+
+func TestDEL(t *testing.T) {
+	/*
+		redis> SET key1 "Hello"
+		"OK"
+		redis> SET key2 "World"
+		"OK"
+		redis> DEL key1 key2 key3
+		(integer) 2
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.MSet(ctx, "key1", "Hello", "key2", "World").Err()
+	testt.NoError(t, err)
+
+	n, err := client.Del(ctx, "key1", "key2", "key3").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+}
+
+func TestEXISTS(t *testing.T) {
+	/*
+		redis> SET key1 "Hello"
+		"OK"
+		redis> EXISTS key1
+		(integer) 1
+		redis> EXISTS nosuchkey
+		(integer) 0
+		redis> SET key2 "World"
+		"OK"
+		redis> EXISTS key1 key2 nosuchkey
+		(integer) 2
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "key1", "Hello", 0).Err()
+	testt.NoError(t, err)
+
+	n, err := client.Exists(ctx, "key1").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.Exists(ctx, "nosuchkey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+
+	err = client.Set(ctx, "key2", "World", 0).Err()
+	testt.NoError(t, err)
+
+	n, err = client.Exists(ctx, "key1", "key2", "nosuchkey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+}
+
+func TestEXPIRE(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> EXPIRE mykey 10
+		(integer) 1
+		redis> TTL mykey
+		(integer) 10
+		redis> SET mykey "Hello World"
+		"OK"
+		redis> TTL mykey
+		(integer) -1
+		redis> EXPIRE mykey 10 XX
+		(integer) 0
+		redis> TTL mykey
+		(integer) -1
+		redis> EXPIRE mykey 10 NX
+		(integer) 1
+		redis> TTL mykey
+		(integer) 10
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "mykey", "Hello", 0).Err()
+	testt.NoError(t, err)
+
+	ok, err := client.Expire(ctx, "mykey", 10*time.Second).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ttl, err := client.TTL(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, 10*time.Second)
+
+	err = client.Set(ctx, "mykey", "Hello World", 0).Err()
+	testt.NoError(t, err)
+
+	ttl, err = client.TTL(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, time.Duration(-1))
+
+	ok, err = client.ExpireXX(ctx, "mykey", 10*time.Second).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	ttl, err = client.TTL(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, time.Duration(-1))
+
+	ok, err = client.ExpireNX(ctx, "mykey", 10*time.Second).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ttl, err = client.TTL(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, 10*time.Second)
+
+	err = client.Do(ctx, "EXPIRE", "mykey", 10, "NX", "XX").Err()
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), "ERR NX and XX, GT or LT options at the same time are not compatible")
+}
+
+func TestEXPIREAT(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> EXPIREAT mykey 1293840000
+		(integer) 1
+		redis> GET mykey
+		(nil)
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "mykey", "Hello", 0).Err()
+	testt.NoError(t, err)
+
+	ok, err := client.ExpireAt(ctx, "mykey", time.Unix(1293840000, 0)).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	_, err = client.Get(ctx, "mykey").Result()
+	testt.WantError(t, err)
+}
+
+func TestEXPIRETIME(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> EXPIREAT mykey 33177117420
+		(integer) 1
+		redis> EXPIRETIME mykey
+		(integer) 33177117420
+		redis> PEXPIRETIME mykey
+		(integer) 33177117420000
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "mykey", "Hello", 0).Err()
+	testt.NoError(t, err)
+
+	ok, err := client.ExpireAt(ctx, "mykey", time.Unix(33177117420, 0)).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	// go-redis returns time.Duration which overflows for such values.
+	at, err := client.Do(ctx, "EXPIRETIME", "mykey").Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, at, int64(33177117420))
+
+	at, err = client.Do(ctx, "PEXPIRETIME", "mykey").Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, at, int64(33177117420000))
+}
+
+func TestFLUSHDB(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.MSet(ctx, "key1", "Hello", "key2", "World").Err()
+	testt.NoError(t, err)
+
+	err = client.FlushDB(ctx).Err()
+	testt.NoError(t, err)
+
+	n, err := client.Exists(ctx, "key1", "key2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+}
+
+func TestKEYS(t *testing.T) {
+	/*
+		redis> MSET firstname Jack lastname Stuntman age 35
+		"OK"
+		redis> KEYS *name*
+		1) "firstname"
+		2) "lastname"
+		redis> KEYS a??
+		1) "age"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.MSet(ctx, "firstname", "Jack", "lastname", "Stuntman", "age", 35).Err()
+	testt.NoError(t, err)
+
+	keys, err := client.Keys(ctx, "*name*").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, keys, []string{"firstname", "lastname"})
+
+	keys, err = client.Keys(ctx, "a??").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, keys, []string{"age"})
+}
+
+func TestPERSIST(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> EXPIRE mykey 10
+		(integer) 1
+		redis> TTL mykey
+		(integer) 10
+		redis> PERSIST mykey
+		(integer) 1
+		redis> TTL mykey
+		(integer) -1
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "mykey", "Hello", 0).Err()
+	testt.NoError(t, err)
+
+	ok, err := client.Expire(ctx, "mykey", 10*time.Second).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ttl, err := client.TTL(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, 10*time.Second)
+
+	ok, err = client.Persist(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ttl, err = client.TTL(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, time.Duration(-1))
+}
+
+func TestPEXPIRE(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> PEXPIRE mykey 1500
+		(integer) 1
+		redis> PTTL mykey
+		(integer) 1499
+		redis> PEXPIRE mykey 1000 XX
+		(integer) 1
+		redis> PEXPIRE mykey 1000 NX
+		(integer) 0
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "mykey", "Hello", 0).Err()
+	testt.NoError(t, err)
+
+	ok, err := client.PExpire(ctx, "mykey", 1500*time.Millisecond).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ttl, err := client.PTTL(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl > time.Second && ttl <= 1500*time.Millisecond, true)
+
+	ok, err = client.Do(ctx, "PEXPIRE", "mykey", 1000, "XX").Bool()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = client.Do(ctx, "PEXPIRE", "mykey", 1000, "NX").Bool()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+}
+
+func TestRENAME(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> RENAME mykey myotherkey
+		"OK"
+		redis> GET myotherkey
+		"Hello"
+		redis> RENAME mykey myotherkey
+		(error) ERR no such key
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "mykey", "Hello", 0).Err()
+	testt.NoError(t, err)
+
+	err = client.Rename(ctx, "mykey", "myotherkey").Err()
+	testt.NoError(t, err)
+
+	val, err := client.Get(ctx, "myotherkey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "Hello")
+
+	err = client.Rename(ctx, "mykey", "myotherkey").Err()
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), "ERR no such key")
+}
+
+func TestRENAMENX(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> SET myotherkey "World"
+		"OK"
+		redis> RENAMENX mykey myotherkey
+		(integer) 0
+		redis> GET myotherkey
+		"World"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.MSet(ctx, "mykey", "Hello", "myotherkey", "World").Err()
+	testt.NoError(t, err)
+
+	ok, err := client.RenameNX(ctx, "mykey", "myotherkey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	val, err := client.Get(ctx, "myotherkey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "World")
+}
+
+func TestSCAN(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	for i := 0; i < 50; i++ {
+		err := client.Set(ctx, fmt.Sprintf("key:%02d", i), "value", 0).Err()
+		testt.NoError(t, err)
+	}
+
+	seen := map[string]bool{}
+	iter := client.Scan(ctx, 0, "key:*", 10).Iterator()
+	for iter.Next(ctx) {
+		seen[iter.Val()] = true
+	}
+	testt.NoError(t, iter.Err())
+	testt.MustEqual(t, len(seen), 50)
+
+	keys, cursor, err := client.ScanType(ctx, 0, "*", 100, "string").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(keys), 50)
+	testt.MustEqual(t, cursor, uint64(0))
+
+	err = client.Do(ctx, "SCAN", "abc").Err()
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), "ERR invalid cursor")
+}
+
+func TestTOUCH(t *testing.T) {
+	/*
+		redis> SET key1 "Hello"
+		"OK"
+		redis> SET key2 "World"
+		"OK"
+		redis> TOUCH key1 key2
+		(integer) 2
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.MSet(ctx, "key1", "Hello", "key2", "World").Err()
+	testt.NoError(t, err)
+
+	n, err := client.Touch(ctx, "key1", "key2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+}
+
+func TestTTL(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> EXPIRE mykey 10
+		(integer) 1
+		redis> TTL mykey
+		(integer) 10
+		redis> TTL nonexisting
+		(integer) -2
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "mykey", "Hello", 0).Err()
+	testt.NoError(t, err)
+
+	ok, err := client.Expire(ctx, "mykey", 10*time.Second).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ttl, err := client.TTL(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, 10*time.Second)
+
+	ttl, err = client.TTL(ctx, "nonexisting").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, time.Duration(-2))
+}
+
+func TestTYPE(t *testing.T) {
+	/*
+		redis> SET key1 "value"
+		"OK"
+		redis> TYPE key1
+		string
+		redis> TYPE nonexisting
+		none
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "key1", "value", 0).Err()
+	testt.NoError(t, err)
+
+	typ, err := client.Type(ctx, "key1").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "string")
+
+	typ, err = client.Type(ctx, "nonexisting").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "none")
+}
+
+func TestUNLINK(t *testing.T) {
+	/*
+		redis> SET key1 "Hello"
+		"OK"
+		redis> SET key2 "World"
+		"OK"
+		redis> UNLINK key1 key2 key3
+		(integer) 2
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.MSet(ctx, "key1", "Hello", "key2", "World").Err()
+	testt.NoError(t, err)
+
+	n, err := client.Unlink(ctx, "key1", "key2", "key3").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+}
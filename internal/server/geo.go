@@ -0,0 +1,377 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/tidwall/redcon"
+)
+
+// Geospatial operations https://redis.io/commands/?group=geo
+
+var errGeoUnit = errors.New("unsupported unit provided. please use M, KM, FT, MI")
+
+// GEOADD, GEODIST, GEOHASH and GEOPOS are sorted set commands over geohash scores, like in Redis.
+
+func (s *Server) handleGEOADD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 5 {
+		conn.WriteError("ERR wrong number of arguments for 'GEOADD' command")
+		return
+	}
+
+	var opts core.ZAddOptions
+	i := 2
+loop:
+	for ; i < len(cmd.Args); i++ {
+		switch strings.ToUpper(string(cmd.Args[i])) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "CH":
+			opts.CH = true
+		default:
+			break loop
+		}
+	}
+	args := cmd.Args[i:]
+	if len(args) == 0 || len(args)%3 != 0 || (opts.NX && opts.XX) {
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	members := make([]core.ZMember, 0, len(args)/3)
+	for i := 0; i < len(args); i += 3 {
+		lon, lat, err := parseLonLat(args[i], args[i+1])
+		if err != nil {
+			writeError(conn, err)
+			return
+		}
+		members = append(members, core.ZMember{Member: args[i+2], Score: core.GeoScore(lon, lat)})
+	}
+
+	n, err := s.db.ZADD(cmd.Args[1], opts, members...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	s.waiters.signal(cmd.Args[1])
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleGEODIST(conn redcon.Conn, cmd redcon.Command) {
+	switch {
+	case len(cmd.Args) < 4:
+		conn.WriteError("ERR wrong number of arguments for 'GEODIST' command")
+		return
+	case len(cmd.Args) > 5:
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	unit := core.GeoMeters
+	if len(cmd.Args) == 5 {
+		var err error
+		unit, err = parseGeoUnit(cmd.Args[4])
+		if err != nil {
+			writeError(conn, err)
+			return
+		}
+	}
+
+	res, err := s.db.ZMSCORE(cmd.Args[1], cmd.Args[2], cmd.Args[3])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	if res[0] == nil || res[1] == nil {
+		conn.WriteNull()
+		return
+	}
+	lon1, lat1 := core.GeoPosition(*res[0])
+	lon2, lat2 := core.GeoPosition(*res[1])
+	writeGeoDist(conn, core.GeoDist(lon1, lat1, lon2, lat2)/float64(unit))
+}
+
+func (s *Server) handleGEOHASH(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'GEOHASH' command")
+		return
+	}
+
+	res, err := s.db.ZMSCORE(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	conn.WriteArray(len(res))
+	for _, score := range res {
+		if score == nil {
+			conn.WriteNull()
+			continue
+		}
+		conn.WriteBulkString(core.GeoHash(*score))
+	}
+}
+
+func (s *Server) handleGEOPOS(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'GEOPOS' command")
+		return
+	}
+
+	res, err := s.db.ZMSCORE(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	conn.WriteArray(len(res))
+	for _, score := range res {
+		if score == nil {
+			conn.WriteNull()
+			continue
+		}
+		lon, lat := core.GeoPosition(*score)
+		writeGeoPos(conn, lon, lat)
+	}
+}
+
+func (s *Server) handleGEOSEARCH(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 7 {
+		conn.WriteError("ERR wrong number of arguments for 'GEOSEARCH' command")
+		return
+	}
+
+	q, opts, err := parseGeoSearch(cmd.Args[2:], "GEOSEARCH")
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.GEOSEARCH(cmd.Args[1], q)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeGeoPoints(conn, res, opts)
+}
+
+func (s *Server) handleGEOSEARCHSTORE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 8 {
+		conn.WriteError("ERR wrong number of arguments for 'GEOSEARCHSTORE' command")
+		return
+	}
+
+	q, opts, err := parseGeoSearch(cmd.Args[3:], "GEOSEARCHSTORE")
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	n, err := s.db.GEOSEARCHSTORE(cmd.Args[1], cmd.Args[2], q, opts.storeDist)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	if n > 0 {
+		s.waiters.signal(cmd.Args[1])
+	}
+	conn.WriteInt(n)
+}
+
+// geoReplyOpts are options of GEOSEARCH and GEOSEARCHSTORE replies.
+type geoReplyOpts struct {
+	withCoord, withDist, withHash bool
+	// storeDist stores distances instead of geohashes with GEOSEARCHSTORE.
+	storeDist bool
+}
+
+// parseGeoSearch parses arguments of GEOSEARCH and GEOSEARCHSTORE after keys.
+func parseGeoSearch(args [][]byte, name string) (core.GeoSearch, geoReplyOpts, error) {
+	store := name == "GEOSEARCHSTORE"
+	q := core.GeoSearch{}
+	var opts geoReplyOpts
+	from, by := false, false
+
+	for i := 0; i < len(args); i++ {
+		left := len(args) - i - 1
+		switch arg := strings.ToUpper(string(args[i])); {
+		case arg == "WITHCOORD":
+			opts.withCoord = true
+		case arg == "WITHDIST":
+			opts.withDist = true
+		case arg == "WITHHASH":
+			opts.withHash = true
+		case arg == "STOREDIST" && store:
+			opts.storeDist = true
+		case arg == "ANY":
+			q.Any = true
+		case arg == "ASC":
+			q.Sort = core.GeoSortAsc
+		case arg == "DESC":
+			q.Sort = core.GeoSortDesc
+		case arg == "COUNT" && left >= 1:
+			count, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return core.GeoSearch{}, opts, core.ErrNotIntOrOutOfRange
+			}
+			if count <= 0 {
+				return core.GeoSearch{}, opts, errors.New("COUNT must be > 0")
+			}
+			q.Count = int(count)
+			i++
+		case arg == "FROMMEMBER" && left >= 1:
+			if from {
+				return core.GeoSearch{}, opts, core.ErrSyntax
+			}
+			from = true
+			q.FromMember = args[i+1]
+			i++
+		case arg == "FROMLONLAT" && left >= 2:
+			if from {
+				return core.GeoSearch{}, opts, core.ErrSyntax
+			}
+			from = true
+			lon, lat, err := parseLonLat(args[i+1], args[i+2])
+			if err != nil {
+				return core.GeoSearch{}, opts, err
+			}
+			q.Lon, q.Lat = lon, lat
+			i += 2
+		case arg == "BYRADIUS" && left >= 2:
+			if by {
+				return core.GeoSearch{}, opts, core.ErrSyntax
+			}
+			by = true
+			radius, err := parseScore(args[i+1])
+			if err != nil {
+				return core.GeoSearch{}, opts, errors.New("need numeric radius")
+			}
+			if radius < 0 {
+				return core.GeoSearch{}, opts, errors.New("radius cannot be negative")
+			}
+			unit, err := parseGeoUnit(args[i+2])
+			if err != nil {
+				return core.GeoSearch{}, opts, err
+			}
+			q.Radius, q.Unit = radius, unit
+			i += 2
+		case arg == "BYBOX" && left >= 3:
+			if by {
+				return core.GeoSearch{}, opts, core.ErrSyntax
+			}
+			by = true
+			width, err := parseScore(args[i+1])
+			if err != nil {
+				return core.GeoSearch{}, opts, err
+			}
+			height, err := parseScore(args[i+2])
+			if err != nil {
+				return core.GeoSearch{}, opts, err
+			}
+			if width < 0 || height < 0 {
+				return core.GeoSearch{}, opts, errors.New("height or width cannot be negative")
+			}
+			unit, err := parseGeoUnit(args[i+3])
+			if err != nil {
+				return core.GeoSearch{}, opts, err
+			}
+			q.ByBox, q.Width, q.Height, q.Unit = true, width, height, unit
+			i += 3
+		default:
+			return core.GeoSearch{}, opts, core.ErrSyntax
+		}
+	}
+
+	switch {
+	case store && (opts.withCoord || opts.withDist || opts.withHash):
+		return core.GeoSearch{}, opts, errors.New(name + " is not compatible with WITHDIST, WITHHASH and WITHCOORD options")
+	case !from:
+		return core.GeoSearch{}, opts, errors.New("exactly one of FROMMEMBER or FROMLONLAT can be specified for " + name)
+	case !by:
+		return core.GeoSearch{}, opts, errors.New("exactly one of BYRADIUS and BYBOX can be specified for " + name)
+	case q.Any && q.Count == 0:
+		return core.GeoSearch{}, opts, errors.New("the ANY argument requires COUNT argument")
+	}
+	return q, opts, nil
+}
+
+// parseLonLat parses a position, it must be in the range of geo indexes.
+func parseLonLat(lonArg, latArg []byte) (float64, float64, error) {
+	lon, err := parseScore(lonArg)
+	if err != nil {
+		return 0, 0, err
+	}
+	lat, err := parseScore(latArg)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !core.ValidLonLat(lon, lat) {
+		return 0, 0, fmt.Errorf("invalid longitude,latitude pair %f,%f", lon, lat)
+	}
+	return lon, lat, nil
+}
+
+func parseGeoUnit(arg []byte) (core.GeoUnit, error) {
+	switch strings.ToLower(string(arg)) {
+	case "m":
+		return core.GeoMeters, nil
+	case "km":
+		return core.GeoKilometers, nil
+	case "ft":
+		return core.GeoFeet, nil
+	case "mi":
+		return core.GeoMiles, nil
+	default:
+		return 0, errGeoUnit
+	}
+}
+
+// writeGeoPoints writes found members, each one is an array with the requested fields if any.
+func writeGeoPoints(conn redcon.Conn, points []core.GeoPoint, opts geoReplyOpts) {
+	fields := 1
+	for _, ok := range []bool{opts.withDist, opts.withHash, opts.withCoord} {
+		if ok {
+			fields++
+		}
+	}
+
+	conn.WriteArray(len(points))
+	for _, p := range points {
+		if fields == 1 {
+			conn.WriteBulk(p.Member)
+			continue
+		}
+		conn.WriteArray(fields)
+		conn.WriteBulk(p.Member)
+		if opts.withDist {
+			writeGeoDist(conn, p.Dist)
+		}
+		if opts.withHash {
+			conn.WriteInt64(int64(p.Score))
+		}
+		if opts.withCoord {
+			writeGeoPos(conn, p.Lon, p.Lat)
+		}
+	}
+}
+
+// writeGeoDist writes the distance with 4 decimals like Redis does.
+func writeGeoDist(conn redcon.Conn, dist float64) {
+	conn.WriteBulkString(strconv.FormatFloat(dist, 'f', 4, 64))
+}
+
+// writeGeoPos writes longitude and latitude with 17 decimals without trailing zeros like Redis does.
+func writeGeoPos(conn redcon.Conn, lon, lat float64) {
+	conn.WriteArray(2)
+	for _, v := range []float64{lon, lat} {
+		s := strings.TrimRight(strconv.FormatFloat(v, 'f', 17, 64), "0")
+		conn.WriteBulkString(strings.TrimSuffix(s, "."))
+	}
+}
@@ -0,0 +1,365 @@
+package server
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/cristalhq/testt"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestGEOADD(t *testing.T) {
+	/*
+		redis> GEOADD Sicily 13.361389 38.115556 "Palermo" 15.087269 37.502669 "Catania"
+		(integer) 2
+		redis> GEODIST Sicily Palermo Catania
+		"166274.1516"
+		redis> ZRANGE Sicily 0 -1 WITHSCORES
+		1) "Palermo"
+		2) "3479099956230698"
+		3) "Catania"
+		4) "3479447370796909"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	n, err := client.GeoAdd(ctx, "Sicily",
+		&redis.GeoLocation{Longitude: 13.361389, Latitude: 38.115556, Name: "Palermo"},
+		&redis.GeoLocation{Longitude: 15.087269, Latitude: 37.502669, Name: "Catania"},
+	).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	dist, err := client.Do(ctx, "GEODIST", "Sicily", "Palermo", "Catania").Text()
+	testt.NoError(t, err)
+	testt.MustEqual(t, dist, "166274.1516")
+
+	members, err := client.ZRangeWithScores(ctx, "Sicily", 0, -1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, members, []redis.Z{
+		{Member: "Palermo", Score: 3479099956230698},
+		{Member: "Catania", Score: 3479447370796909},
+	})
+
+	// geohash scores are integers and have no exponent.
+	score, err := client.Do(ctx, "ZSCORE", "Sicily", "Palermo").Text()
+	testt.NoError(t, err)
+	testt.MustEqual(t, score, "3479099956230698")
+
+	n, err = client.Do(ctx, "GEOADD", "Sicily", "XX", "CH", 13.361389, 38.115556, "Palermo", 15, 37, "Syracuse").Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+
+	n, err = client.Do(ctx, "GEOADD", "Sicily", "NX", "CH", 15, 37, "Catania", 15.28, 37.07, "Syracuse").Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	err = client.Do(ctx, "GEOADD", "Sicily", 200, 100, "Nowhere").Err()
+	testt.MustEqual(t, err.Error(), "ERR invalid longitude,latitude pair 200.000000,100.000000")
+
+	err = client.Do(ctx, "GEOADD", "Sicily", "NX", "XX", 15, 37, "Catania").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+
+	err = client.Do(ctx, "GEOADD", "Sicily", 15, 37).Err()
+	testt.MustEqual(t, err.Error(), "ERR wrong number of arguments for 'GEOADD' command")
+
+	err = client.Do(ctx, "GEOADD", "Sicily", "east", 37, "Catania").Err()
+	testt.MustEqual(t, err.Error(), "ERR value is not a valid float")
+}
+
+func TestGEODIST(t *testing.T) {
+	/*
+		redis> GEOADD Sicily 13.361389 38.115556 "Palermo" 15.087269 37.502669 "Catania"
+		(integer) 2
+		redis> GEODIST Sicily Palermo Catania
+		"166274.1516"
+		redis> GEODIST Sicily Palermo Catania km
+		"166.2742"
+		redis> GEODIST Sicily Palermo Catania mi
+		"103.3182"
+		redis> GEODIST Sicily Foo Bar
+		(nil)
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Do(ctx, "GEOADD", "Sicily", 13.361389, 38.115556, "Palermo", 15.087269, 37.502669, "Catania").Err()
+	testt.NoError(t, err)
+
+	dist, err := client.Do(ctx, "GEODIST", "Sicily", "Palermo", "Catania").Text()
+	testt.NoError(t, err)
+	testt.MustEqual(t, dist, "166274.1516")
+
+	dist, err = client.Do(ctx, "GEODIST", "Sicily", "Palermo", "Catania", "km").Text()
+	testt.NoError(t, err)
+	testt.MustEqual(t, dist, "166.2742")
+
+	dist, err = client.Do(ctx, "GEODIST", "Sicily", "Palermo", "Catania", "mi").Text()
+	testt.NoError(t, err)
+	testt.MustEqual(t, dist, "103.3182")
+
+	err = client.Do(ctx, "GEODIST", "Sicily", "Foo", "Bar").Err()
+	testt.MustEqual(t, err, redis.Nil)
+
+	err = client.Do(ctx, "GEODIST", "Sicily", "Palermo", "Catania", "yd").Err()
+	testt.MustEqual(t, err.Error(), "ERR unsupported unit provided. please use M, KM, FT, MI")
+}
+
+func TestGEOHASH(t *testing.T) {
+	/*
+		redis> GEOADD Sicily 13.361389 38.115556 "Palermo" 15.087269 37.502669 "Catania"
+		(integer) 2
+		redis> GEOHASH Sicily Palermo Catania
+		1) "sqc8b49rny0"
+		2) "sqdtr74hyu0"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Do(ctx, "GEOADD", "Sicily", 13.361389, 38.115556, "Palermo", 15.087269, 37.502669, "Catania").Err()
+	testt.NoError(t, err)
+
+	res, err := client.GeoHash(ctx, "Sicily", "Palermo", "Catania").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []string{"sqc8b49rny0", "sqdtr74hyu0"})
+
+	val, err := client.Do(ctx, "GEOHASH", "Sicily", "Palermo", "NonExisting").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, []any{"sqc8b49rny0", nil})
+}
+
+func TestGEOPOS(t *testing.T) {
+	/*
+		redis> GEOADD Sicily 13.361389 38.115556 "Palermo" 15.087269 37.502669 "Catania"
+		(integer) 2
+		redis> GEOPOS Sicily Palermo Catania NonExisting
+		1) 1) "13.36138933897018433"
+		   2) "38.11555639549629859"
+		2) 1) "15.08726745843887329"
+		   2) "37.50266842333162032"
+		3) (nil)
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Do(ctx, "GEOADD", "Sicily", 13.361389, 38.115556, "Palermo", 15.087269, 37.502669, "Catania").Err()
+	testt.NoError(t, err)
+
+	val, err := client.Do(ctx, "GEOPOS", "Sicily", "Palermo", "Catania", "NonExisting").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, []any{
+		[]any{"13.36138933897018433", "38.11555639549629859"},
+		[]any{"15.08726745843887329", "37.50266842333162032"},
+		nil,
+	})
+
+	err = client.LPush(ctx, "list", "a").Err()
+	testt.NoError(t, err)
+
+	err = client.GeoPos(ctx, "list", "a").Err()
+	testt.MustEqual(t, err.Error(), "WRONGTYPE Operation against a key holding the wrong kind of value")
+}
+
+func TestGEOSEARCH(t *testing.T) {
+	/*
+		redis> GEOADD Sicily 13.361389 38.115556 "Palermo" 15.087269 37.502669 "Catania"
+		(integer) 2
+		redis> GEOADD Sicily 12.758489 38.788135 "edge1"   17.241510 38.788135 "edge2"
+		(integer) 2
+		redis> GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 200 km ASC
+		1) "Catania"
+		2) "Palermo"
+		redis> GEOSEARCH Sicily FROMLONLAT 15 37 BYBOX 400 400 km ASC WITHCOORD WITHDIST
+		1) 1) "Catania"
+		   2) "56.4413"
+		   3) 1) "15.08726745843887329"
+		      2) "37.50266842333162032"
+		2) 1) "Palermo"
+		   2) "190.4424"
+		   3) 1) "13.36138933897018433"
+		      2) "38.11555639549629859"
+		3) 1) "edge2"
+		   2) "279.7403"
+		   3) 1) "17.24151045083999634"
+		      2) "38.78813451624225195"
+		4) 1) "edge1"
+		   2) "279.7405"
+		   3) 1) "12.7584877610206604"
+		      2) "38.78813451624225195"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Do(ctx, "GEOADD", "Sicily", 13.361389, 38.115556, "Palermo", 15.087269, 37.502669, "Catania").Err()
+	testt.NoError(t, err)
+	err = client.Do(ctx, "GEOADD", "Sicily", 12.758489, 38.788135, "edge1", 17.241510, 38.788135, "edge2").Err()
+	testt.NoError(t, err)
+
+	res, err := client.GeoSearch(ctx, "Sicily", &redis.GeoSearchQuery{
+		Longitude: 15, Latitude: 37, Radius: 200, RadiusUnit: "km", Sort: "ASC",
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []string{"Catania", "Palermo"})
+
+	val, err := client.Do(ctx, "GEOSEARCH", "Sicily", "FROMLONLAT", 15, 37, "BYBOX", 400, 400, "km", "ASC", "WITHCOORD", "WITHDIST").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, []any{
+		[]any{"Catania", "56.4413", []any{"15.08726745843887329", "37.50266842333162032"}},
+		[]any{"Palermo", "190.4424", []any{"13.36138933897018433", "38.11555639549629859"}},
+		[]any{"edge2", "279.7403", []any{"17.24151045083999634", "38.78813451624225195"}},
+		[]any{"edge1", "279.7405", []any{"12.7584877610206604", "38.78813451624225195"}},
+	})
+
+	res, err = client.GeoSearch(ctx, "Sicily", &redis.GeoSearchQuery{
+		Member: "Palermo", Radius: 200, RadiusUnit: "km", Sort: "DESC",
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []string{"Catania", "edge1", "Palermo"})
+
+	res, err = client.GeoSearch(ctx, "Sicily", &redis.GeoSearchQuery{
+		Member: "Palermo", Radius: 200, RadiusUnit: "km", Count: 2,
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []string{"Palermo", "edge1"})
+
+	res, err = client.GeoSearch(ctx, "Sicily", &redis.GeoSearchQuery{
+		Longitude: 15, Latitude: 37, BoxWidth: 400, BoxHeight: 400, BoxUnit: "km", Count: 1, CountAny: true,
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 1)
+
+	res, err = client.GeoSearch(ctx, "nokey", &redis.GeoSearchQuery{
+		Member: "Palermo", Radius: 200, RadiusUnit: "km",
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []string{})
+
+	val, err = client.Do(ctx, "GEOSEARCH", "Sicily", "FROMMEMBER", "Catania", "BYRADIUS", 1, "m", "WITHHASH").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, []any{[]any{"Catania", int64(3479447370796909)}})
+
+	err = client.Do(ctx, "GEOSEARCH", "Sicily", "FROMMEMBER", "Rome", "BYRADIUS", 200, "km").Err()
+	testt.MustEqual(t, err.Error(), "ERR could not decode requested zset member")
+
+	err = client.Do(ctx, "GEOSEARCH", "Sicily", "BYRADIUS", 200, "km", "ASC", "WITHDIST").Err()
+	testt.MustEqual(t, err.Error(), "ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH")
+
+	err = client.Do(ctx, "GEOSEARCH", "Sicily", "FROMLONLAT", 15, 37, "ASC", "COUNT", 1).Err()
+	testt.MustEqual(t, err.Error(), "ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH")
+
+	err = client.Do(ctx, "GEOSEARCH", "Sicily", "FROMLONLAT", 15, 37, "BYRADIUS", 200, "km", "ANY").Err()
+	testt.MustEqual(t, err.Error(), "ERR the ANY argument requires COUNT argument")
+
+	err = client.Do(ctx, "GEOSEARCH", "Sicily", "FROMLONLAT", 15, 37, "BYRADIUS", 200, "km", "COUNT", 0).Err()
+	testt.MustEqual(t, err.Error(), "ERR COUNT must be > 0")
+
+	err = client.Do(ctx, "GEOSEARCH", "Sicily", "FROMLONLAT", 15, 37, "BYRADIUS", -1, "km").Err()
+	testt.MustEqual(t, err.Error(), "ERR radius cannot be negative")
+
+	err = client.Do(ctx, "GEOSEARCH", "Sicily", "FROMLONLAT", 15, 37, "BYRADIUS", 200, "km", "STOREDIST").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+}
+
+func TestGEOSEARCHSTORE(t *testing.T) {
+	/*
+		redis> GEOADD Sicily 13.361389 38.115556 "Palermo" 15.087269 37.502669 "Catania"
+		(integer) 2
+		redis> GEOADD Sicily 12.758489 38.788135 "edge1"   17.241510 38.788135 "edge2"
+		(integer) 2
+		redis> GEOSEARCHSTORE key1 Sicily FROMLONLAT 15 37 BYBOX 400 400 km ASC COUNT 3
+		(integer) 3
+		redis> GEOSEARCH key1 FROMLONLAT 15 37 BYBOX 400 400 km ASC WITHCOORD WITHDIST WITHHASH
+		1) 1) "Catania"
+		   2) "56.4413"
+		   3) (integer) 3479447370796909
+		   4) 1) "15.08726745843887329"
+		      2) "37.50266842333162032"
+		2) 1) "Palermo"
+		   2) "190.4424"
+		   3) (integer) 3479099956230698
+		   4) 1) "13.36138933897018433"
+		      2) "38.11555639549629859"
+		3) 1) "edge2"
+		   2) "279.7403"
+		   3) (integer) 3481342659049484
+		   4) 1) "17.24151045083999634"
+		      2) "38.78813451624225195"
+		redis> GEOSEARCHSTORE key2 Sicily FROMLONLAT 15 37 BYBOX 400 400 km ASC COUNT 3 STOREDIST
+		(integer) 3
+		redis> ZRANGE key2 0 -1 WITHSCORES
+		1) "Catania"
+		2) "56.441257870158204"
+		3) "Palermo"
+		4) "190.44242984775784"
+		5) "edge2"
+		6) "279.7403417843143"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Do(ctx, "GEOADD", "Sicily", 13.361389, 38.115556, "Palermo", 15.087269, 37.502669, "Catania").Err()
+	testt.NoError(t, err)
+	err = client.Do(ctx, "GEOADD", "Sicily", 12.758489, 38.788135, "edge1", 17.241510, 38.788135, "edge2").Err()
+	testt.NoError(t, err)
+
+	n, err := client.Do(ctx, "GEOSEARCHSTORE", "key1", "Sicily", "FROMLONLAT", 15, 37, "BYBOX", 400, 400, "km", "ASC", "COUNT", 3).Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(3))
+
+	val, err := client.Do(ctx, "GEOSEARCH", "key1", "FROMLONLAT", 15, 37, "BYBOX", 400, 400, "km", "ASC", "WITHCOORD", "WITHDIST", "WITHHASH").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, []any{
+		[]any{"Catania", "56.4413", int64(3479447370796909), []any{"15.08726745843887329", "37.50266842333162032"}},
+		[]any{"Palermo", "190.4424", int64(3479099956230698), []any{"13.36138933897018433", "38.11555639549629859"}},
+		[]any{"edge2", "279.7403", int64(3481342659049484), []any{"17.24151045083999634", "38.78813451624225195"}},
+	})
+
+	n, err = client.Do(ctx, "GEOSEARCHSTORE", "key2", "Sicily", "FROMLONLAT", 15, 37, "BYBOX", 400, 400, "km", "ASC", "COUNT", 3, "STOREDIST").Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(3))
+
+	// distances might differ from Redis in the last bit because of math functions.
+	members, err := client.ZRangeWithScores(ctx, "key2", 0, -1).Result()
+	testt.NoError(t, err)
+	want := []redis.Z{
+		{Member: "Catania", Score: 56.441257870158204},
+		{Member: "Palermo", Score: 190.44242984775784},
+		{Member: "edge2", Score: 279.7403417843143},
+	}
+	testt.MustEqual(t, len(members), len(want))
+	for i := range want {
+		testt.MustEqual(t, members[i].Member, want[i].Member)
+		testt.MustEqual(t, math.Abs(members[i].Score-want[i].Score) < 1e-9, true)
+	}
+
+	// nothing found removes dst.
+	n, err = client.Do(ctx, "GEOSEARCHSTORE", "key2", "Sicily", "FROMLONLAT", 0, 0, "BYRADIUS", 1, "km").Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+
+	exists, err := client.Exists(ctx, "key2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, exists, int64(0))
+
+	err = client.Do(ctx, "GEOSEARCHSTORE", "key2", "Sicily", "FROMLONLAT", 15, 37, "BYRADIUS", 200, "km", "WITHDIST").Err()
+	testt.MustEqual(t, err.Error(), "ERR GEOSEARCHSTORE is not compatible with WITHDIST, WITHHASH and WITHCOORD options")
+}
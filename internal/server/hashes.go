@@ -0,0 +1,462 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/tidwall/redcon"
+)
+
+// Hashes operations https://redis.io/commands/?group=hash
+
+func (s *Server) handleHDEL(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'HDEL' command")
+		return
+	}
+
+	n, err := s.db.HDEL(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleHEXISTS(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'HEXISTS' command")
+		return
+	}
+
+	ok, err := s.db.HEXISTS(cmd.Args[1], cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBool(conn, ok)
+}
+
+func (s *Server) handleHEXPIRE(conn redcon.Conn, cmd redcon.Command) {
+	s.hexpireGeneric(conn, cmd, "HEXPIRE", s.db.HEXPIRE)
+}
+
+func (s *Server) handleHEXPIREAT(conn redcon.Conn, cmd redcon.Command) {
+	s.hexpireGeneric(conn, cmd, "HEXPIREAT", s.db.HEXPIREAT)
+}
+
+func (s *Server) handleHEXPIRETIME(conn redcon.Conn, cmd redcon.Command) {
+	s.httlGeneric(conn, cmd, "HEXPIRETIME", s.db.HEXPIRETIME)
+}
+
+func (s *Server) handleHGET(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'HGET' command")
+		return
+	}
+
+	val, err := s.db.HGET(cmd.Args[1], cmd.Args[2])
+	writeBulkOrNil(conn, val, err)
+}
+
+func (s *Server) handleHGETALL(conn redcon.Conn, cmd redcon.Command) {
+	s.hashGeneric(conn, cmd, "HGETALL", s.db.HGETALL)
+}
+
+func (s *Server) handleHINCRBY(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'HINCRBY' command")
+		return
+	}
+
+	by, err := strconv.ParseInt(string(cmd.Args[3]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+
+	n, err := s.db.HINCRBY(cmd.Args[1], cmd.Args[2], by)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt64(n)
+}
+
+func (s *Server) handleHINCRBYFLOAT(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'HINCRBYFLOAT' command")
+		return
+	}
+
+	by, err := strconv.ParseFloat(string(cmd.Args[3]), 64)
+	if err != nil {
+		writeError(conn, core.ErrNotFloat)
+		return
+	}
+	if math.IsNaN(by) || math.IsInf(by, 0) {
+		writeError(conn, core.ErrNaNOrInf)
+		return
+	}
+
+	val, err := s.db.HINCRBYFLOAT(cmd.Args[1], cmd.Args[2], by)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteBulkString(val)
+}
+
+func (s *Server) handleHKEYS(conn redcon.Conn, cmd redcon.Command) {
+	s.hashGeneric(conn, cmd, "HKEYS", s.db.HKEYS)
+}
+
+func (s *Server) handleHLEN(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 2 {
+		conn.WriteError("ERR wrong number of arguments for 'HLEN' command")
+		return
+	}
+
+	n, err := s.db.HLEN(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleHMGET(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'HMGET' command")
+		return
+	}
+
+	res, err := s.db.HMGET(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	conn.WriteArray(len(res))
+	for i := range res {
+		if res[i] == nil {
+			conn.WriteNull()
+			continue
+		}
+		conn.WriteBulk(res[i])
+	}
+}
+
+func (s *Server) handleHMSET(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 || len(cmd.Args)%2 == 1 {
+		conn.WriteError("ERR wrong number of arguments for 'HMSET' command")
+		return
+	}
+
+	if _, err := s.db.HSET(cmd.Args[1], cmd.Args[2:]...); err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteString("OK")
+}
+
+func (s *Server) handleHPERSIST(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 5 {
+		conn.WriteError("ERR wrong number of arguments for 'HPERSIST' command")
+		return
+	}
+
+	fields, err := parseFields(cmd.Args[2:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.HPERSIST(cmd.Args[1], fields...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteArray(len(res))
+	for _, status := range res {
+		conn.WriteInt(status)
+	}
+}
+
+func (s *Server) handleHPEXPIRE(conn redcon.Conn, cmd redcon.Command) {
+	s.hexpireGeneric(conn, cmd, "HPEXPIRE", s.db.HPEXPIRE)
+}
+
+func (s *Server) handleHPEXPIREAT(conn redcon.Conn, cmd redcon.Command) {
+	s.hexpireGeneric(conn, cmd, "HPEXPIREAT", s.db.HPEXPIREAT)
+}
+
+func (s *Server) handleHPEXPIRETIME(conn redcon.Conn, cmd redcon.Command) {
+	s.httlGeneric(conn, cmd, "HPEXPIRETIME", s.db.HPEXPIRETIME)
+}
+
+func (s *Server) handleHPTTL(conn redcon.Conn, cmd redcon.Command) {
+	s.httlGeneric(conn, cmd, "HPTTL", s.db.HPTTL)
+}
+
+func (s *Server) handleHRANDFIELD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 || len(cmd.Args) > 4 {
+		conn.WriteError("ERR wrong number of arguments for 'HRANDFIELD' command")
+		return
+	}
+
+	if len(cmd.Args) == 2 {
+		res, err := s.db.HRANDFIELD(cmd.Args[1], 1, false)
+		writeSingleOrNil(conn, res, err)
+		return
+	}
+
+	count, err := parseRandCount(cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	withValues := false
+	if len(cmd.Args) == 4 {
+		if !strings.EqualFold(string(cmd.Args[3]), "WITHVALUES") {
+			writeError(conn, core.ErrSyntax)
+			return
+		}
+		withValues = true
+	}
+
+	res, err := s.db.HRANDFIELD(cmd.Args[1], count, withValues)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBulks(conn, res)
+}
+
+// maxRandCount limits how many members HRANDFIELD and SRANDMEMBER pick with repetitions.
+// Redis rejects counts below -MaxInt64/2, the limit is lower here because the reply is built in memory.
+const maxRandCount = 1 << 24
+
+// parseRandCount parses count of HRANDFIELD and SRANDMEMBER commands.
+func parseRandCount(arg []byte) (int, error) {
+	count, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, core.ErrNotIntOrOutOfRange
+	}
+	if count < -maxRandCount {
+		return 0, core.ErrOutOfRange
+	}
+	return int(count), nil
+}
+
+func (s *Server) handleHSCAN(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'HSCAN' command")
+		return
+	}
+
+	cursor, err := strconv.ParseUint(string(cmd.Args[2]), 10, 64)
+	if err != nil {
+		conn.WriteError("ERR invalid cursor")
+		return
+	}
+
+	var opts core.ScanOptions
+	noValues := false
+	for i := 3; i < len(cmd.Args); i++ {
+		if strings.EqualFold(string(cmd.Args[i]), "NOVALUES") {
+			noValues = true
+			continue
+		}
+		if i+1 == len(cmd.Args) {
+			writeError(conn, core.ErrSyntax)
+			return
+		}
+
+		switch strings.ToUpper(string(cmd.Args[i])) {
+		case "MATCH":
+			opts.Match = cmd.Args[i+1]
+		case "COUNT":
+			count, err := strconv.ParseInt(string(cmd.Args[i+1]), 10, 64)
+			if err != nil {
+				writeError(conn, core.ErrNotIntOrOutOfRange)
+				return
+			}
+			if count < 1 {
+				writeError(conn, core.ErrSyntax)
+				return
+			}
+			opts.Count = int(count)
+		default:
+			writeError(conn, core.ErrSyntax)
+			return
+		}
+		i++
+	}
+
+	res, next, err := s.db.HSCAN(cmd.Args[1], cursor, opts)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	conn.WriteArray(2)
+	conn.WriteBulkString(strconv.FormatUint(next, 10))
+	if !noValues {
+		writeBulks(conn, res)
+		return
+	}
+	conn.WriteArray(len(res) / 2)
+	for i := 0; i < len(res); i += 2 {
+		conn.WriteBulk(res[i])
+	}
+}
+
+func (s *Server) handleHSET(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 || len(cmd.Args)%2 == 1 {
+		conn.WriteError("ERR wrong number of arguments for 'HSET' command")
+		return
+	}
+
+	n, err := s.db.HSET(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleHSETNX(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'HSETNX' command")
+		return
+	}
+
+	ok, err := s.db.HSETNX(cmd.Args[1], cmd.Args[2], cmd.Args[3])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBool(conn, ok)
+}
+
+func (s *Server) handleHSTRLEN(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'HSTRLEN' command")
+		return
+	}
+
+	n, err := s.db.HSTRLEN(cmd.Args[1], cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleHTTL(conn redcon.Conn, cmd redcon.Command) {
+	s.httlGeneric(conn, cmd, "HTTL", s.db.HTTL)
+}
+
+func (s *Server) handleHVALS(conn redcon.Conn, cmd redcon.Command) {
+	s.hashGeneric(conn, cmd, "HVALS", s.db.HVALS)
+}
+
+func (s *Server) hashGeneric(conn redcon.Conn, cmd redcon.Command, name string, fn func(key []byte) ([][]byte, error)) {
+	if len(cmd.Args) != 2 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	res, err := fn(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBulks(conn, res)
+}
+
+func (s *Server) hexpireGeneric(conn redcon.Conn, cmd redcon.Command, name string, fn func(key []byte, d int64, cond core.ExpireCond, fields ...[]byte) ([]int, error)) {
+	if len(cmd.Args) < 6 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	d, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+
+	// conditions are followed by FIELDS.
+	i := slices.IndexFunc(cmd.Args[3:], func(arg []byte) bool {
+		return strings.EqualFold(string(arg), "FIELDS")
+	})
+	if i == -1 {
+		conn.WriteError("ERR Mandatory argument FIELDS is missing or not at the right position")
+		return
+	}
+	cond, err := parseExpireCond(cmd.Args[3 : 3+i])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	fields, err := parseFields(cmd.Args[3+i:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := fn(cmd.Args[1], d, cond, fields...)
+	if err != nil {
+		writeExpireError(conn, err, name)
+		return
+	}
+	conn.WriteArray(len(res))
+	for _, status := range res {
+		conn.WriteInt(status)
+	}
+}
+
+func (s *Server) httlGeneric(conn redcon.Conn, cmd redcon.Command, name string, fn func(key []byte, fields ...[]byte) ([]int64, error)) {
+	if len(cmd.Args) < 5 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	fields, err := parseFields(cmd.Args[2:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := fn(cmd.Args[1], fields...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteArray(len(res))
+	for _, val := range res {
+		conn.WriteInt64(val)
+	}
+}
+
+// parseFields parses `FIELDS numfields field [field ...]`.
+func parseFields(args [][]byte) ([][]byte, error) {
+	if !strings.EqualFold(string(args[0]), "FIELDS") {
+		return nil, errors.New("Mandatory argument FIELDS is missing or not at the right position")
+	}
+	numfields, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil || numfields <= 0 {
+		return nil, errors.New("Parameter `numFields` should be greater than 0")
+	}
+	if numfields != int64(len(args)-2) {
+		return nil, errors.New("The `numfields` parameter must match the number of arguments")
+	}
+	return args[2:], nil
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cristalhq/testt"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestHSET(t *testing.T) {
+	/*
+		redis> HSET myhash field1 "Hello"
+		(integer) 1
+		redis> HGET myhash field1
+		"Hello"
+		redis> HSET myhash field2 "Hi" field3 "World"
+		(integer) 2
+		redis> HGETALL myhash
+		1) "field1"
+		2) "Hello"
+		3) "field2"
+		4) "Hi"
+		5) "field3"
+		6) "World"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	n, err := client.HSet(ctx, "myhash", "field1", "Hello").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	val, err := client.HGet(ctx, "myhash", "field1").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "Hello")
+
+	n, err = client.HSet(ctx, "myhash", "field2", "Hi", "field3", "World").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	all, err := client.HGetAll(ctx, "myhash").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, all, map[string]string{"field1": "Hello", "field2": "Hi", "field3": "World"})
+
+	_, err = client.HGet(ctx, "myhash", "nofield").Result()
+	testt.MustEqual(t, err, redis.Nil)
+
+	err = client.Do(ctx, "HSET", "myhash", "field1").Err()
+	testt.MustEqual(t, err.Error(), "ERR wrong number of arguments for 'HSET' command")
+}
+
+func TestHMGET(t *testing.T) {
+	/*
+		redis> HSET myhash field1 "Hello"
+		(integer) 1
+		redis> HSET myhash field2 "World"
+		(integer) 1
+		redis> HMGET myhash field1 field2 nofield
+		1) "Hello"
+		2) "World"
+		3) (nil)
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	_, err := client.HSet(ctx, "myhash", "field1", "Hello", "field2", "World").Result()
+	testt.NoError(t, err)
+
+	vals, err := client.HMGet(ctx, "myhash", "field1", "field2", "nofield").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []any{"Hello", "World", nil})
+}
+
+func TestHINCRBY(t *testing.T) {
+	/*
+		redis> HSET myhash field 5
+		(integer) 1
+		redis> HINCRBY myhash field 1
+		(integer) 6
+		redis> HINCRBY myhash field -10
+		(integer) -4
+		redis> HINCRBYFLOAT myhash field 0.5
+		"-3.5"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	_, err := client.HSet(ctx, "myhash", "field", "5").Result()
+	testt.NoError(t, err)
+
+	n, err := client.HIncrBy(ctx, "myhash", "field", 1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(6))
+
+	n, err = client.HIncrBy(ctx, "myhash", "field", -10).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(-4))
+
+	f, err := client.HIncrByFloat(ctx, "myhash", "field", 0.5).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, f, -3.5)
+
+	err = client.HIncrBy(ctx, "myhash", "field", 1).Err()
+	testt.MustEqual(t, err.Error(), "ERR hash value is not an integer")
+
+	err = client.Do(ctx, "HINCRBYFLOAT", "myhash", "field", "nan").Err()
+	testt.MustEqual(t, err.Error(), "ERR value is NaN or Infinity")
+
+	err = client.Do(ctx, "HINCRBYFLOAT", "myhash", "field", "+inf").Err()
+	testt.MustEqual(t, err.Error(), "ERR value is NaN or Infinity")
+
+	err = client.HSet(ctx, "myhash", "big", "1.7e308").Err()
+	testt.NoError(t, err)
+	err = client.HIncrByFloat(ctx, "myhash", "big", 1.7e308).Err()
+	testt.MustEqual(t, err.Error(), "ERR increment would produce NaN or Infinity")
+}
+
+func TestHSCAN(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	_, err := client.HSet(ctx, "myhash", "a1", "1", "a2", "2", "b1", "3").Result()
+	testt.NoError(t, err)
+
+	res, cursor, err := client.HScan(ctx, "myhash", 0, "a*", 10).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, cursor, uint64(0))
+	testt.MustEqual(t, res, []string{"a1", "1", "a2", "2"})
+
+	reply, err := client.Do(ctx, "HSCAN", "myhash", "0", "NOVALUES").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, reply, []any{"0", []any{"a1", "a2", "b1"}})
+}
+
+func TestHRANDFIELD(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	_, err := client.HSet(ctx, "coin", "heads", "obverse", "tails", "reverse", "edge", "null").Result()
+	testt.NoError(t, err)
+
+	val, err := client.Do(ctx, "HRANDFIELD", "coin").Text()
+	testt.NoError(t, err)
+	if val != "heads" && val != "tails" && val != "edge" {
+		t.Fatalf("unexpected field %q", val)
+	}
+
+	vals, err := client.HRandField(ctx, "coin", -5).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(vals), 5)
+
+	pairs, err := client.HRandFieldWithValues(ctx, "coin", 2).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(pairs), 2)
+
+	err = client.Do(ctx, "HRANDFIELD", "nosuchkey").Err()
+	testt.MustEqual(t, err, redis.Nil)
+
+	// a huge negative count is rejected, the connection stays alive.
+	err = client.Do(ctx, "HRANDFIELD", "coin", "-9223372036854775807").Err()
+	testt.MustEqual(t, err.Error(), "ERR value is out of range")
+
+	err = client.Do(ctx, "HRANDFIELD", "coin", "-9223372036854775807", "WITHVALUES").Err()
+	testt.MustEqual(t, err.Error(), "ERR value is out of range")
+
+	n, err := client.HLen(ctx, "coin").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(3))
+}
+
+func TestHEXPIRE(t *testing.T) {
+	/*
+		redis> HSET mykey field1 "hello" field2 "world"
+		(integer) 2
+		redis> HEXPIRE mykey 10 FIELDS 3 field1 field2 field3
+		1) (integer) 1
+		2) (integer) 1
+		3) (integer) -2
+		redis> HTTL mykey FIELDS 2 field1 field2
+		1) (integer) 10
+		2) (integer) 10
+		redis> HPERSIST mykey FIELDS 1 field2
+		1) (integer) 1
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	n, err := client.HSet(ctx, "mykey", "field1", "hello", "field2", "world").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	res, err := client.Do(ctx, "HEXPIRE", "mykey", "10", "FIELDS", "3", "field1", "field2", "field3").Int64Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{1, 1, -2})
+
+	res, err = client.Do(ctx, "HTTL", "mykey", "FIELDS", "2", "field1", "field2").Int64Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{10, 10})
+
+	res, err = client.Do(ctx, "HPERSIST", "mykey", "FIELDS", "1", "field2").Int64Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{1})
+
+	res, err = client.Do(ctx, "HPEXPIRE", "mykey", "100", "XX", "FIELDS", "2", "field1", "field2").Int64Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{1, 0})
+
+	err = client.Do(ctx, "HEXPIRE", "mykey", "10", "NX", "1", "field1").Err()
+	testt.MustEqual(t, err.Error(), "ERR Mandatory argument FIELDS is missing or not at the right position")
+
+	err = client.Do(ctx, "HEXPIRE", "mykey", "10", "FIELDS", "2", "field1").Err()
+	testt.MustEqual(t, err.Error(), "ERR The `numfields` parameter must match the number of arguments")
+
+	err = client.Do(ctx, "HTTL", "mykey", "FIELDS", "0", "field1").Err()
+	testt.MustEqual(t, err.Error(), "ERR Parameter `numFields` should be greater than 0")
+
+	err = client.Do(ctx, "HEXPIRE", "mykey", "10", "NX", "XX", "FIELDS", "1", "field1").Err()
+	testt.MustEqual(t, err.Error(), "ERR NX and XX, GT or LT options at the same time are not compatible")
+
+	err = client.Do(ctx, "HEXPIRE", "mykey", "9223372036854775807", "FIELDS", "1", "field1").Err()
+	testt.MustEqual(t, err.Error(), "ERR invalid expire time in 'hexpire' command")
+}
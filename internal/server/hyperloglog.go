@@ -0,0 +1,48 @@
+package server
+
+import (
+	"github.com/tidwall/redcon"
+)
+
+// HyperLogLog operations https://redis.io/commands/?group=hyperloglog
+
+func (s *Server) handlePFADD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'PFADD' command")
+		return
+	}
+
+	updated, err := s.db.PFADD(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBool(conn, updated)
+}
+
+func (s *Server) handlePFCOUNT(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'PFCOUNT' command")
+		return
+	}
+
+	card, err := s.db.PFCOUNT(cmd.Args[1:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt64(card)
+}
+
+func (s *Server) handlePFMERGE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'PFMERGE' command")
+		return
+	}
+
+	if err := s.db.PFMERGE(cmd.Args[1], cmd.Args[2:]...); err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteString("OK")
+}
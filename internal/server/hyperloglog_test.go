@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cristalhq/testt"
+)
+
+func TestPFADD(t *testing.T) {
+	/*
+		redis> PFADD hll a b c d e f g
+		(integer) 1
+		redis> PFCOUNT hll
+		(integer) 7
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	n, err := client.PFAdd(ctx, "hll", "a", "b", "c", "d", "e", "f", "g").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.PFCount(ctx, "hll").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(7))
+
+	n, err = client.Do(ctx, "PFADD", "empty").Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	err = client.Set(ctx, "str", "Hello World", 0).Err()
+	testt.NoError(t, err)
+
+	err = client.PFAdd(ctx, "str", "a").Err()
+	testt.MustEqual(t, err.Error(), "WRONGTYPE Key is not a valid HyperLogLog string value.")
+}
+
+func TestPFCOUNT(t *testing.T) {
+	/*
+		redis> PFADD hll foo bar zap
+		(integer) 1
+		redis> PFADD hll zap zap zap
+		(integer) 0
+		redis> PFADD hll foo bar
+		(integer) 0
+		redis> PFCOUNT hll
+		(integer) 3
+		redis> PFADD some-other-hll 1 2 3
+		(integer) 1
+		redis> PFCOUNT hll some-other-hll
+		(integer) 6
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	n, err := client.PFAdd(ctx, "hll", "foo", "bar", "zap").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.PFAdd(ctx, "hll", "zap", "zap", "zap").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+
+	n, err = client.PFAdd(ctx, "hll", "foo", "bar").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+
+	n, err = client.PFCount(ctx, "hll").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(3))
+
+	n, err = client.PFAdd(ctx, "some-other-hll", 1, 2, 3).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.PFCount(ctx, "hll", "some-other-hll").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(6))
+
+	err = client.PFAdd(ctx, "corrupted", "a", "b", "c").Err()
+	testt.NoError(t, err)
+
+	err = client.Append(ctx, "corrupted", "hello").Err()
+	testt.NoError(t, err)
+
+	err = client.PFCount(ctx, "corrupted").Err()
+	testt.MustEqual(t, err.Error(), "INVALIDOBJ Corrupted HLL object detected")
+}
+
+func TestPFMERGE(t *testing.T) {
+	/*
+		redis> PFADD hll1 foo bar zap a
+		(integer) 1
+		redis> PFADD hll2 a b c foo
+		(integer) 1
+		redis> PFMERGE hll3 hll1 hll2
+		"OK"
+		redis> PFCOUNT hll3
+		(integer) 6
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	n, err := client.PFAdd(ctx, "hll1", "foo", "bar", "zap", "a").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.PFAdd(ctx, "hll2", "a", "b", "c", "foo").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	res, err := client.PFMerge(ctx, "hll3", "hll1", "hll2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, "OK")
+
+	n, err = client.PFCount(ctx, "hll3").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(6))
+}
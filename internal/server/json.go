@@ -0,0 +1,441 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/tidwall/redcon"
+)
+
+// JSON operations https://redis.io/commands/?group=json
+
+// Replies of a legacy path are single values, JSONPaths reply with a value per match.
+
+func (s *Server) handleJSONARRAPPEND(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'JSON.ARRAPPEND' command")
+		return
+	}
+
+	// the path is optional with a single value.
+	path, args := core.JSONRoot, cmd.Args[2:]
+	if len(args) > 1 {
+		var err error
+		if path, err = core.ParseJSONPath(args[0]); err != nil {
+			writeError(conn, err)
+			return
+		}
+		args = args[1:]
+	}
+	values, err := parseJSONValues(args)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.JSONARRAPPEND(cmd.Args[1], path, values...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeJSONLens(conn, path, res)
+}
+
+func (s *Server) handleJSONARRINSERT(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 5 {
+		conn.WriteError("ERR wrong number of arguments for 'JSON.ARRINSERT' command")
+		return
+	}
+
+	path, err := core.ParseJSONPath(cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	index, err := strconv.ParseInt(string(cmd.Args[3]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+	values, err := parseJSONValues(cmd.Args[4:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.JSONARRINSERT(cmd.Args[1], path, index, values...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeJSONLens(conn, path, res)
+}
+
+func (s *Server) handleJSONARRPOP(conn redcon.Conn, cmd redcon.Command) {
+	switch {
+	case len(cmd.Args) < 2:
+		conn.WriteError("ERR wrong number of arguments for 'JSON.ARRPOP' command")
+		return
+	case len(cmd.Args) > 4:
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	path, err := parseJSONPathArg(cmd.Args, 2)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	index := int64(-1)
+	if len(cmd.Args) == 4 {
+		index, err = strconv.ParseInt(string(cmd.Args[3]), 10, 64)
+		if err != nil {
+			writeError(conn, core.ErrNotIntOrOutOfRange)
+			return
+		}
+	}
+
+	res, err := s.db.JSONARRPOP(cmd.Args[1], path, index)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	if path.Legacy {
+		writeJSONOrNull(conn, res[0])
+		return
+	}
+	conn.WriteArray(len(res))
+	for _, val := range res {
+		writeJSONOrNull(conn, val)
+	}
+}
+
+func (s *Server) handleJSONDEL(conn redcon.Conn, cmd redcon.Command) {
+	switch {
+	case len(cmd.Args) < 2:
+		conn.WriteError("ERR wrong number of arguments for '" + strings.ToUpper(string(cmd.Args[0])) + "' command")
+		return
+	case len(cmd.Args) > 3:
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	path, err := parseJSONPathArg(cmd.Args, 2)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	n, err := s.db.JSONDEL(cmd.Args[1], path)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleJSONGET(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'JSON.GET' command")
+		return
+	}
+
+	var f core.JSONFormat
+	args := cmd.Args[2:]
+loop:
+	for ; len(args) >= 2; args = args[2:] {
+		switch strings.ToUpper(string(args[0])) {
+		case "INDENT":
+			f.Indent = string(args[1])
+		case "NEWLINE":
+			f.Newline = string(args[1])
+		case "SPACE":
+			f.Space = string(args[1])
+		default:
+			break loop
+		}
+	}
+
+	paths := make([]core.JSONPath, len(args))
+	for i, arg := range args {
+		var err error
+		if paths[i], err = core.ParseJSONPath(arg); err != nil {
+			writeError(conn, err)
+			return
+		}
+	}
+
+	res, err := s.db.JSONGET(cmd.Args[1], f, paths...)
+	writeBulkOrNil(conn, res, err)
+}
+
+func (s *Server) handleJSONMERGE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'JSON.MERGE' command")
+		return
+	}
+
+	path, err := core.ParseJSONPath(cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	patch, err := core.ParseJSON(cmd.Args[3])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	if err := s.db.JSONMERGE(cmd.Args[1], path, patch); err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteString("OK")
+}
+
+func (s *Server) handleJSONMGET(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'JSON.MGET' command")
+		return
+	}
+
+	path, err := core.ParseJSONPath(cmd.Args[len(cmd.Args)-1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.JSONMGET(path, cmd.Args[1:len(cmd.Args)-1]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteArray(len(res))
+	for _, val := range res {
+		writeJSONOrNull(conn, val)
+	}
+}
+
+func (s *Server) handleJSONNUMINCRBY(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'JSON.NUMINCRBY' command")
+		return
+	}
+
+	path, err := core.ParseJSONPath(cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	by, err := core.ParseJSON(cmd.Args[3])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.JSONNUMINCRBY(cmd.Args[1], path, by)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteBulk(res)
+}
+
+func (s *Server) handleJSONOBJKEYS(conn redcon.Conn, cmd redcon.Command) {
+	switch {
+	case len(cmd.Args) < 2:
+		conn.WriteError("ERR wrong number of arguments for 'JSON.OBJKEYS' command")
+		return
+	case len(cmd.Args) > 3:
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	path, err := parseJSONPathArg(cmd.Args, 2)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.JSONOBJKEYS(cmd.Args[1], path)
+	switch {
+	case errors.Is(err, core.ErrKeyNotFound):
+		conn.WriteNull()
+		return
+	case err != nil:
+		writeError(conn, err)
+		return
+	}
+	if path.Legacy {
+		writeBulks(conn, res[0])
+		return
+	}
+	conn.WriteArray(len(res))
+	for _, keys := range res {
+		if keys == nil {
+			conn.WriteNull()
+			continue
+		}
+		writeBulks(conn, keys)
+	}
+}
+
+func (s *Server) handleJSONSET(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for 'JSON.SET' command")
+		return
+	}
+
+	var opts core.JSONSetOptions
+	for _, arg := range cmd.Args[4:] {
+		switch strings.ToUpper(string(arg)) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		default:
+			writeError(conn, core.ErrSyntax)
+			return
+		}
+	}
+	if opts.NX && opts.XX {
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	path, err := core.ParseJSONPath(cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	value, err := core.ParseJSON(cmd.Args[3])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	ok, err := s.db.JSONSET(cmd.Args[1], path, value, opts)
+	switch {
+	case err != nil:
+		writeError(conn, err)
+	case !ok:
+		conn.WriteNull()
+	default:
+		conn.WriteString("OK")
+	}
+}
+
+func (s *Server) handleJSONSTRAPPEND(conn redcon.Conn, cmd redcon.Command) {
+	switch {
+	case len(cmd.Args) < 3:
+		conn.WriteError("ERR wrong number of arguments for 'JSON.STRAPPEND' command")
+		return
+	case len(cmd.Args) > 4:
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	// the path is optional, the value is the last argument.
+	path, err := parseJSONPathArg(cmd.Args[:len(cmd.Args)-1], 2)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	value, err := core.ParseJSON(cmd.Args[len(cmd.Args)-1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.JSONSTRAPPEND(cmd.Args[1], path, value)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeJSONLens(conn, path, res)
+}
+
+func (s *Server) handleJSONTYPE(conn redcon.Conn, cmd redcon.Command) {
+	switch {
+	case len(cmd.Args) < 2:
+		conn.WriteError("ERR wrong number of arguments for 'JSON.TYPE' command")
+		return
+	case len(cmd.Args) > 3:
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	path, err := parseJSONPathArg(cmd.Args, 2)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.JSONTYPE(cmd.Args[1], path)
+	switch {
+	case errors.Is(err, core.ErrKeyNotFound):
+		conn.WriteNull()
+		return
+	case err != nil:
+		writeError(conn, err)
+		return
+	}
+	if path.Legacy {
+		if len(res) == 0 {
+			conn.WriteNull()
+			return
+		}
+		conn.WriteString(res[0])
+		return
+	}
+	conn.WriteArray(len(res))
+	for _, typ := range res {
+		conn.WriteBulkString(typ)
+	}
+}
+
+// parseJSONPathArg parses an optional path at i, the root is the default.
+func parseJSONPathArg(args [][]byte, i int) (core.JSONPath, error) {
+	if len(args) <= i {
+		return core.JSONRoot, nil
+	}
+	return core.ParseJSONPath(args[i])
+}
+
+func parseJSONValues(args [][]byte) ([][]byte, error) {
+	values := make([][]byte, len(args))
+	for i, arg := range args {
+		var err error
+		if values[i], err = core.ParseJSON(arg); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// writeJSONLens writes lengths of values, nil is written for values of other types.
+func writeJSONLens(conn redcon.Conn, path core.JSONPath, lens []*int64) {
+	if path.Legacy {
+		conn.WriteInt64(*lens[0])
+		return
+	}
+	conn.WriteArray(len(lens))
+	for _, n := range lens {
+		if n == nil {
+			conn.WriteNull()
+			continue
+		}
+		conn.WriteInt64(*n)
+	}
+}
+
+func writeJSONOrNull(conn redcon.Conn, val []byte) {
+	if val == nil {
+		conn.WriteNull()
+		return
+	}
+	conn.WriteBulk(val)
+}
@@ -0,0 +1,569 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cristalhq/testt"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestJSONSET(t *testing.T) {
+	/*
+		redis> JSON.SET doc $ '{"a":2}'
+		OK
+		redis> JSON.SET doc $.a '3'
+		OK
+		redis> JSON.GET doc $
+		"[{\"a\":3}]"
+		redis> JSON.SET doc $.b '8'
+		OK
+		redis> JSON.GET doc $
+		"[{\"a\":3,\"b\":8}]"
+		redis> JSON.SET doc2 $ '{"f1": {"a":1}, "f2":{"a":2}}'
+		OK
+		redis> JSON.SET doc2 $..a 3
+		OK
+		redis> JSON.GET doc2
+		"{\"f1\":{\"a\":3},\"f2\":{\"a\":3}}"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc", "$", `{"a":2}`))
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc", "$.a", "3"))
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc", "$"), `[{"a":3}]`)
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc", "$.b", "8"))
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc", "$"), `[{"a":3,"b":8}]`)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc2", "$", `{"f1": {"a":1}, "f2":{"a":2}}`))
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc2", "$..a", "3"))
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc2"), `{"f1":{"a":3},"f2":{"a":3}}`)
+
+	// NX and XX.
+	err := client.Do(ctx, "JSON.SET", "doc", "$.a", "4", "NX").Err()
+	testt.MustEqual(t, err, redis.Nil)
+	err = client.Do(ctx, "JSON.SET", "doc", "$.c", "4", "XX").Err()
+	testt.MustEqual(t, err, redis.Nil)
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc", "$.c", `{"d":[1,2.5,"x",true,null]}`, "NX"))
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc"), `{"a":3,"b":8,"c":{"d":[1,2.5,"x",true,null]}}`)
+
+	// missing parents are not created.
+	err = client.Do(ctx, "JSON.SET", "doc", "$.x.y", "1").Err()
+	testt.MustEqual(t, err, redis.Nil)
+
+	// legacy paths.
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc", ".c.d[1]", `"y"`))
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc", "e", `[]`))
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc", "c"), `{"d":[1,"y","x",true,null]}`)
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc", ".e"), `[]`)
+
+	err = client.Do(ctx, "JSON.SET", "nokey", "$.a", "1").Err()
+	testt.MustEqual(t, err.Error(), "ERR new objects must be created at the root")
+
+	err = client.Do(ctx, "JSON.SET", "doc", "$", `{"a":}`).Err()
+	testt.MustEqual(t, err.Error(), "ERR expected value at line 1 column 6")
+
+	err = client.Do(ctx, "JSON.SET", "doc", "$", "{\"a\":1,\n\"b\" 2}").Err()
+	testt.MustEqual(t, err.Error(), "ERR expected `:` at line 2 column 5")
+
+	err = client.Do(ctx, "JSON.SET", "doc", "$[", "1").Err()
+	testt.MustEqual(t, err.Error(), "ERR invalid JSONPath '$[' at position 1")
+
+	err = client.Do(ctx, "JSON.SET", "doc", "$", "1", "NX", "XX").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+
+	err = client.Set(ctx, "str", "value", 0).Err()
+	testt.NoError(t, err)
+	err = client.Do(ctx, "JSON.SET", "str", "$", "1").Err()
+	testt.MustEqual(t, err.Error(), "WRONGTYPE Operation against a key holding the wrong kind of value")
+
+	typ, err := client.Type(ctx, "doc").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "ReJSON-RL")
+}
+
+func TestJSONGET(t *testing.T) {
+	/*
+		redis> JSON.SET doc $ '{"a":2, "b": 3, "nested": {"a": 4, "b": null}}'
+		OK
+		redis> JSON.GET doc $..b
+		"[3,null]"
+		redis> JSON.GET doc ..a $..b
+		"{\"$..b\":[3,null],\"..a\":[2,4]}"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc", "$", `{"a":2, "b": 3, "nested": {"a": 4, "b": null}}`))
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc", "$..b"), `[3,null]`)
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc", "..a", "$..b"), `{"..a":[2,4],"$..b":[3,null]}`)
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc", ".a", ".nested.b"), `{".a":2,".nested.b":null}`)
+
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc", "INDENT", "\t", "NEWLINE", "\n", "SPACE", " ", "$.nested"),
+		"[\n\t{\n\t\t\"a\": 4,\n\t\t\"b\": null\n\t}\n]")
+
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc", "$.nope"), `[]`)
+
+	err := client.Do(ctx, "JSON.GET", "doc", ".nope").Err()
+	testt.MustEqual(t, err.Error(), "ERR Path '.nope' does not exist")
+
+	err = client.Do(ctx, "JSON.GET", "nokey", "$").Err()
+	testt.MustEqual(t, err, redis.Nil)
+
+	// values are formatted like RedisJSON does.
+	jsonOK(t, client.Do(ctx, "JSON.SET", "num", "$", `[1.0, 1.5, -0.0, 1e16, 1e-7, 123456789012345678901, "é\t\u0001\""]`))
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "num"), `[1.0,1.5,-0.0,1e16,1e-7,1.2345678901234568e20,"é\t\u0001\""]`)
+}
+
+func TestJSONPaths(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "store", "$", `{"books":[
+		{"title":"Sayings","price":8.95,"tags":["a"]},
+		{"title":"Sword","price":12.99},
+		{"title":"Moby Dick","price":8.99,"isbn":"0-553"},
+		{"title":"Rings","price":22.99,"isbn":"0-395"}
+	],"bike":{"color":"red","price":19.95}}`))
+
+	for path, want := range map[string]string{
+		"$.books[0].title":                                   `["Sayings"]`,
+		"$.books[-1].title":                                  `["Rings"]`,
+		"$.books[0,2].title":                                 `["Sayings","Moby Dick"]`,
+		"$.books[1:3].title":                                 `["Sword","Moby Dick"]`,
+		"$.books[::2].title":                                 `["Sayings","Moby Dick"]`,
+		"$.books[-2:].title":                                 `["Moby Dick","Rings"]`,
+		"$['bike']['color']":                                 `["red"]`,
+		"$.bike.*":                                           `["red",19.95]`,
+		"$..price":                                           `[8.95,12.99,8.99,22.99,19.95]`,
+		"$.books[*].isbn":                                    `["0-553","0-395"]`,
+		"$.books[?(@.isbn)].title":                           `["Moby Dick","Rings"]`,
+		"$.books[?(@.price < 10)].title":                     `["Sayings","Moby Dick"]`,
+		"$.books[?(@.price>10 && @.isbn)]":                   `[{"title":"Rings","price":22.99,"isbn":"0-395"}]`,
+		`$.books[?(@.title == "Sword")].price`:               `[12.99]`,
+		"$.books[?(@.title =~ '^S')].title":                  `["Sayings","Sword"]`,
+		"$.books[?(@.price > $.bike.price || @.tags)].title": `["Sayings","Rings"]`,
+		"$.books[?(!@.isbn)].title":                          `["Sayings","Sword"]`,
+		"$..tags[0]":                                         `["a"]`,
+		"$.nope[0]":                                          `[]`,
+	} {
+		jsonEqual(t, client.Do(ctx, "JSON.GET", "store", path), want)
+	}
+}
+
+func TestJSONDEL(t *testing.T) {
+	/*
+		redis> JSON.SET doc $ '{"a": 1, "nested": {"a": 2, "b": 3}}'
+		OK
+		redis> JSON.DEL doc $..a
+		(integer) 2
+		redis> JSON.GET doc $
+		"[{\"nested\":{\"b\":3}}]"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc", "$", `{"a": 1, "nested": {"a": 2, "b": 3}}`))
+	jsonInt(t, client.Do(ctx, "JSON.DEL", "doc", "$..a"), 2)
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc", "$"), `[{"nested":{"b":3}}]`)
+
+	jsonInt(t, client.Do(ctx, "JSON.DEL", "doc", "$..a"), 0)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "arr", "$", `[1,2,3,4,5]`))
+	jsonInt(t, client.Do(ctx, "JSON.FORGET", "arr", "$[0,2,4]"), 3)
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "arr"), `[2,4]`)
+
+	jsonInt(t, client.Do(ctx, "JSON.DEL", "doc"), 1)
+	jsonInt(t, client.Do(ctx, "JSON.DEL", "doc"), 0)
+
+	n, err := client.Exists(ctx, "doc").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+}
+
+func TestJSONMGET(t *testing.T) {
+	/*
+		redis> JSON.SET doc1 $ '{"a":1, "b": 2, "nested": {"a": 3}, "c": null}'
+		OK
+		redis> JSON.SET doc2 $ '{"a":4, "b": 5, "nested": {"a": 6}, "c": null}'
+		OK
+		redis> JSON.MGET doc1 doc2 $..a
+		1) "[1,3]"
+		2) "[4,6]"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc1", "$", `{"a":1, "b": 2, "nested": {"a": 3}, "c": null}`))
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc2", "$", `{"a":4, "b": 5, "nested": {"a": 6}, "c": null}`))
+
+	res, err := client.Do(ctx, "JSON.MGET", "doc1", "doc2", "$..a").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{"[1,3]", "[4,6]"})
+
+	err = client.Set(ctx, "str", "value", 0).Err()
+	testt.NoError(t, err)
+
+	res, err = client.Do(ctx, "JSON.MGET", "doc1", "nokey", "str", ".nested.a").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{"3", nil, nil})
+
+	res, err = client.Do(ctx, "JSON.MGET", "doc1", "doc2", ".b.c").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{nil, nil})
+}
+
+func TestJSONTYPE(t *testing.T) {
+	/*
+		redis> JSON.SET doc $ '{"a":2, "nested": {"a": true}, "foo": "bar"}'
+		OK
+		redis> JSON.TYPE doc $..foo
+		1) "string"
+		redis> JSON.TYPE doc $..a
+		1) "integer"
+		2) "boolean"
+		redis> JSON.TYPE doc $..dummy
+		(empty array)
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc", "$", `{"a":2, "nested": {"a": true}, "foo": "bar"}`))
+
+	res, err := client.Do(ctx, "JSON.TYPE", "doc", "$..foo").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{"string"})
+
+	res, err = client.Do(ctx, "JSON.TYPE", "doc", "$..a").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{"integer", "boolean"})
+
+	res, err = client.Do(ctx, "JSON.TYPE", "doc", "$..dummy").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{})
+
+	typ, err := client.Do(ctx, "JSON.TYPE", "doc").Text()
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "object")
+
+	err = client.Do(ctx, "JSON.TYPE", "doc", ".dummy").Err()
+	testt.MustEqual(t, err, redis.Nil)
+
+	err = client.Do(ctx, "JSON.TYPE", "nokey").Err()
+	testt.MustEqual(t, err, redis.Nil)
+}
+
+func TestJSONNUMINCRBY(t *testing.T) {
+	/*
+		redis> JSON.SET doc . '{"a":"b","b":[{"a":2}, {"a":5}, {"a":"c"}]}'
+		OK
+		redis> JSON.NUMINCRBY doc $.a 2
+		"[null]"
+		redis> JSON.NUMINCRBY doc $..a 2
+		"[null,4,7,null]"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc", ".", `{"a":"b","b":[{"a":2}, {"a":5}, {"a":"c"}]}`))
+	jsonEqual(t, client.Do(ctx, "JSON.NUMINCRBY", "doc", "$.a", 2), `[null]`)
+	jsonEqual(t, client.Do(ctx, "JSON.NUMINCRBY", "doc", "$..a", 2), `[null,4,7,null]`)
+
+	jsonEqual(t, client.Do(ctx, "JSON.NUMINCRBY", "doc", ".b[0].a", 1.5), `5.5`)
+	jsonEqual(t, client.Do(ctx, "JSON.NUMINCRBY", "doc", ".b[1].a", -7), `0`)
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc", "$.b"), `[[{"a":5.5},{"a":0},{"a":"c"}]]`)
+
+	// integers overflow into numbers.
+	jsonOK(t, client.Do(ctx, "JSON.SET", "big", "$", "9223372036854775807"))
+	jsonEqual(t, client.Do(ctx, "JSON.NUMINCRBY", "big", "$", 1), `[9.223372036854776e18]`)
+
+	err := client.Do(ctx, "JSON.NUMINCRBY", "doc", ".a", 1).Err()
+	testt.MustEqual(t, err.Error(), "ERR wrong type of path value - expected number but found string")
+
+	err = client.Do(ctx, "JSON.NUMINCRBY", "doc", ".nope", 1).Err()
+	testt.MustEqual(t, err.Error(), "ERR Path '.nope' does not exist")
+
+	err = client.Do(ctx, "JSON.NUMINCRBY", "doc", "$..a", `"x"`).Err()
+	testt.MustEqual(t, err.Error(), "ERR wrong type of value - expected number but found string")
+
+	err = client.Do(ctx, "JSON.NUMINCRBY", "nokey", "$", 1).Err()
+	testt.MustEqual(t, err.Error(), "ERR could not perform this operation on a key that doesn't exist")
+}
+
+func TestJSONSTRAPPEND(t *testing.T) {
+	/*
+		redis> JSON.SET doc $ '{"a":"foo", "nested": {"a": "hello"}, "nested2": {"a": 31}}'
+		OK
+		redis> JSON.STRAPPEND doc $..a '"baz"'
+		1) (integer) 6
+		2) (integer) 8
+		3) (nil)
+		redis> JSON.GET doc $
+		"[{\"a\":\"foobaz\",\"nested\":{\"a\":\"hellobaz\"},\"nested2\":{\"a\":31}}]"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc", "$", `{"a":"foo", "nested": {"a": "hello"}, "nested2": {"a": 31}}`))
+
+	res, err := client.Do(ctx, "JSON.STRAPPEND", "doc", "$..a", `"baz"`).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{int64(6), int64(8), nil})
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc", "$"), `[{"a":"foobaz","nested":{"a":"hellobaz"},"nested2":{"a":31}}]`)
+
+	jsonInt(t, client.Do(ctx, "JSON.STRAPPEND", "doc", ".a", `"!"`), 7)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "str", "$", `"a"`))
+	jsonInt(t, client.Do(ctx, "JSON.STRAPPEND", "str", `"bc"`), 3)
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "str"), `"abc"`)
+
+	err = client.Do(ctx, "JSON.STRAPPEND", "doc", ".nested2.a", `"x"`).Err()
+	testt.MustEqual(t, err.Error(), "ERR wrong type of path value - expected string but found integer")
+
+	err = client.Do(ctx, "JSON.STRAPPEND", "doc", "$..a", "baz").Err()
+	testt.MustEqual(t, err.Error(), "ERR expected value at line 1 column 1")
+}
+
+func TestJSONARRAPPEND(t *testing.T) {
+	/*
+		redis> JSON.SET item:1 $ '{"name":"Noise-cancelling Bluetooth headphones","description":"Wireless Bluetooth headphones with noise-cancelling technology","connection":{"wireless":true,"type":"Bluetooth"},"price":99.98,"stock":25,"colors":["black","silver"]}'
+		OK
+		redis> JSON.ARRAPPEND item:1 $.colors '"blue"'
+		1) (integer) 3
+		redis> JSON.GET item:1
+		"{\"name\":\"Noise-cancelling Bluetooth headphones\",\"description\":\"Wireless Bluetooth headphones with noise-cancelling technology\",\"connection\":{\"wireless\":true,\"type\":\"Bluetooth\"},\"price\":99.98,\"stock\":25,\"colors\":[\"black\",\"silver\",\"blue\"]}"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	item := `{"name":"Noise-cancelling Bluetooth headphones","description":"Wireless Bluetooth headphones with noise-cancelling technology","connection":{"wireless":true,"type":"Bluetooth"},"price":99.98,"stock":25,"colors":["black","silver"]}`
+	jsonOK(t, client.Do(ctx, "JSON.SET", "item:1", "$", item))
+
+	res, err := client.Do(ctx, "JSON.ARRAPPEND", "item:1", "$.colors", `"blue"`).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{int64(3)})
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "item:1"), `{"name":"Noise-cancelling Bluetooth headphones","description":"Wireless Bluetooth headphones with noise-cancelling technology","connection":{"wireless":true,"type":"Bluetooth"},"price":99.98,"stock":25,"colors":["black","silver","blue"]}`)
+
+	jsonInt(t, client.Do(ctx, "JSON.ARRAPPEND", "item:1", ".colors", `"red"`, `{"a":[]}`), 5)
+
+	// nested arrays are updated too.
+	jsonOK(t, client.Do(ctx, "JSON.SET", "nested", "$", `[[1],[[2]]]`))
+	res, err = client.Do(ctx, "JSON.ARRAPPEND", "nested", "$..*", "0").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{int64(2), int64(2), nil, int64(2), nil})
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "nested"), `[[1,0],[[2,0],0]]`)
+
+	err = client.Do(ctx, "JSON.ARRAPPEND", "item:1", ".name", "1").Err()
+	testt.MustEqual(t, err.Error(), "ERR wrong type of path value - expected array but found string")
+}
+
+func TestJSONARRINSERT(t *testing.T) {
+	/*
+		redis> JSON.SET item:1 $ '{"name":"Noise-cancelling Bluetooth headphones","description":"Wireless Bluetooth headphones with noise-cancelling technology","connection":{"wireless":true,"type":"Bluetooth"},"price":99.98,"stock":25,"colors":["black","silver"]}'
+		OK
+		redis> JSON.ARRAPPEND item:1 $.colors '"blue"'
+		1) (integer) 3
+		redis> JSON.ARRINSERT item:1 $.colors 2 '"yellow"' '"gold"'
+		1) (integer) 5
+		redis> JSON.GET item:1 $.colors
+		"[[\"black\",\"silver\",\"yellow\",\"gold\",\"blue\"]]"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "item:1", "$", `{"colors":["black","silver"]}`))
+	jsonInt(t, client.Do(ctx, "JSON.ARRAPPEND", "item:1", ".colors", `"blue"`), 3)
+
+	res, err := client.Do(ctx, "JSON.ARRINSERT", "item:1", "$.colors", 2, `"yellow"`, `"gold"`).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{int64(5)})
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "item:1", "$.colors"), `[["black","silver","yellow","gold","blue"]]`)
+
+	jsonInt(t, client.Do(ctx, "JSON.ARRINSERT", "item:1", ".colors", -1, `"white"`), 6)
+	jsonInt(t, client.Do(ctx, "JSON.ARRINSERT", "item:1", ".colors", 6, `"end"`), 7)
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "item:1", ".colors"), `["black","silver","yellow","gold","white","blue","end"]`)
+
+	err = client.Do(ctx, "JSON.ARRINSERT", "item:1", "$.colors", 8, "1").Err()
+	testt.MustEqual(t, err.Error(), "ERR index out of bounds")
+
+	err = client.Do(ctx, "JSON.ARRINSERT", "item:1", "$.colors", "x", "1").Err()
+	testt.MustEqual(t, err.Error(), "ERR value is not an integer or out of range")
+}
+
+func TestJSONARRPOP(t *testing.T) {
+	/*
+		redis> JSON.SET item:3 $ '{"name":"Wireless earbuds","description":"Wireless Bluetooth in-ear headphones","connection":{"wireless":true,"type":"Bluetooth"},"price":64.99,"stock":17,"colors":["black","white"], "max_level":[80, 90, 100, 120]}'
+		OK
+		redis> JSON.ARRPOP item:3 $.max_level 0
+		1) "80"
+		redis> JSON.GET item:3 $.max_level
+		"[[90,100,120]]"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "item:3", "$", `{"name":"Wireless earbuds","description":"Wireless Bluetooth in-ear headphones","connection":{"wireless":true,"type":"Bluetooth"},"price":64.99,"stock":17,"colors":["black","white"], "max_level":[80, 90, 100, 120]}`))
+
+	res, err := client.Do(ctx, "JSON.ARRPOP", "item:3", "$.max_level", 0).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{"80"})
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "item:3", "$.max_level"), `[[90,100,120]]`)
+
+	// the index is clamped.
+	jsonEqual(t, client.Do(ctx, "JSON.ARRPOP", "item:3", ".max_level", 10), `120`)
+	jsonEqual(t, client.Do(ctx, "JSON.ARRPOP", "item:3", ".colors"), `"white"`)
+
+	res, err = client.Do(ctx, "JSON.ARRPOP", "item:3", "$.*").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{nil, nil, nil, nil, nil, `"black"`, "100"})
+
+	err = client.Do(ctx, "JSON.ARRPOP", "item:3", ".colors").Err()
+	testt.MustEqual(t, err, redis.Nil)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "arr", "$", `[{"a":1}]`))
+	jsonEqual(t, client.Do(ctx, "JSON.ARRPOP", "arr"), `{"a":1}`)
+}
+
+func TestJSONOBJKEYS(t *testing.T) {
+	/*
+		redis> JSON.SET doc $ '{"a":[3], "nested": {"a": {"b":2, "c": 1}}}'
+		OK
+		redis> JSON.OBJKEYS doc $..a
+		1) (nil)
+		2) 1) "b"
+		   2) "c"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc", "$", `{"a":[3], "nested": {"a": {"b":2, "c": 1}}}`))
+
+	res, err := client.Do(ctx, "JSON.OBJKEYS", "doc", "$..a").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{nil, []any{"b", "c"}})
+
+	res, err = client.Do(ctx, "JSON.OBJKEYS", "doc").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{"a", "nested"})
+
+	err = client.Do(ctx, "JSON.OBJKEYS", "doc", ".a").Err()
+	testt.MustEqual(t, err.Error(), "ERR wrong type of path value - expected object but found array")
+
+	err = client.Do(ctx, "JSON.OBJKEYS", "nokey").Err()
+	testt.MustEqual(t, err, redis.Nil)
+}
+
+func TestJSONMERGE(t *testing.T) {
+	/*
+		redis> JSON.SET doc $ '{"a":2}'
+		OK
+		redis> JSON.MERGE doc $.a '3'
+		OK
+		redis> JSON.GET doc $
+		"[{\"a\":3}]"
+		redis> JSON.MERGE doc $.b '8'
+		OK
+		redis> JSON.GET doc $
+		"[{\"a\":3,\"b\":8}]"
+		redis> JSON.MERGE doc $.a 'null'
+		OK
+		redis> JSON.GET doc $
+		"[{\"b\":8}]"
+		redis> JSON.SET doc2 $ '{"a":[2,4,6,8]}'
+		OK
+		redis> JSON.MERGE doc2 $.a '[10,12]'
+		OK
+		redis> JSON.GET doc2 $
+		"[{\"a\":[10,12]}]"
+		redis> JSON.SET doc3 $ '{"f1": {"a":1}, "f2":{"a":2}}'
+		OK
+		redis> JSON.MERGE doc3 $ '{"f1": null, "f2":{"a":3, "b":4}, "f3":[2,4,6]}'
+		OK
+		redis> JSON.GET doc3
+		"{\"f2\":{\"a\":3,\"b\":4},\"f3\":[2,4,6]}"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc", "$", `{"a":2}`))
+	jsonOK(t, client.Do(ctx, "JSON.MERGE", "doc", "$.a", "3"))
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc", "$"), `[{"a":3}]`)
+	jsonOK(t, client.Do(ctx, "JSON.MERGE", "doc", "$.b", "8"))
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc", "$"), `[{"a":3,"b":8}]`)
+	jsonOK(t, client.Do(ctx, "JSON.MERGE", "doc", "$.a", "null"))
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc", "$"), `[{"b":8}]`)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc2", "$", `{"a":[2,4,6,8]}`))
+	jsonOK(t, client.Do(ctx, "JSON.MERGE", "doc2", "$.a", "[10,12]"))
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc2", "$"), `[{"a":[10,12]}]`)
+
+	jsonOK(t, client.Do(ctx, "JSON.SET", "doc3", "$", `{"f1": {"a":1}, "f2":{"a":2}}`))
+	jsonOK(t, client.Do(ctx, "JSON.MERGE", "doc3", "$", `{"f1": null, "f2":{"a":3, "b":4}, "f3":[2,4,6]}`))
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc3"), `{"f2":{"a":3,"b":4},"f3":[2,4,6]}`)
+
+	// a new document has nulls of the patch removed.
+	jsonOK(t, client.Do(ctx, "JSON.MERGE", "doc4", "$", `{"a":{"b":null,"c":1}}`))
+	jsonEqual(t, client.Do(ctx, "JSON.GET", "doc4"), `{"a":{"c":1}}`)
+
+	err := client.Do(ctx, "JSON.MERGE", "nokey", "$.a", "1").Err()
+	testt.MustEqual(t, err.Error(), "ERR new objects must be created at the root")
+}
+
+func jsonOK(tb testing.TB, cmd *redis.Cmd) {
+	tb.Helper()
+	res, err := cmd.Text()
+	testt.NoError(tb, err)
+	testt.MustEqual(tb, res, "OK")
+}
+
+func jsonEqual(tb testing.TB, cmd *redis.Cmd, want string) {
+	tb.Helper()
+	res, err := cmd.Text()
+	testt.NoError(tb, err)
+	testt.MustEqual(tb, res, want)
+}
+
+func jsonInt(tb testing.TB, cmd *redis.Cmd, want int64) {
+	tb.Helper()
+	res, err := cmd.Int64()
+	testt.NoError(tb, err)
+	testt.MustEqual(tb, res, want)
+}
@@ -0,0 +1,523 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/tidwall/redcon"
+)
+
+// Lists operations https://redis.io/commands/?group=list
+
+func (s *Server) handleBLMOVE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 6 {
+		conn.WriteError("ERR wrong number of arguments for 'BLMOVE' command")
+		return
+	}
+	cmd = cloneCommand(cmd)
+
+	from, err := parseListSide(cmd.Args[3])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	to, err := parseListSide(cmd.Args[4])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	deadline, err := parseTimeout(cmd.Args[5])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	s.block(conn, cmd.Args[1:2], deadline, func(conn redcon.Conn) bool {
+		val, err := s.db.LMOVE(cmd.Args[1], cmd.Args[2], from, to)
+		if errors.Is(err, core.ErrKeyNotFound) {
+			return false
+		}
+		if err != nil {
+			writeError(conn, err)
+			return true
+		}
+		s.waiters.signal(cmd.Args[2])
+		conn.WriteBulk(val)
+		return true
+	})
+}
+
+func (s *Server) handleBLMPOP(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 5 {
+		conn.WriteError("ERR wrong number of arguments for 'BLMPOP' command")
+		return
+	}
+	cmd = cloneCommand(cmd)
+
+	deadline, err := parseTimeout(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	keys, side, count, err := parseMPop(cmd.Args[2:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	s.block(conn, keys, deadline, func(conn redcon.Conn) bool {
+		return s.mpop(conn, keys, side, count)
+	})
+}
+
+func (s *Server) handleBLPOP(conn redcon.Conn, cmd redcon.Command) {
+	s.bpopGeneric(conn, cmd, "BLPOP", core.ListLeft)
+}
+
+func (s *Server) handleBRPOP(conn redcon.Conn, cmd redcon.Command) {
+	s.bpopGeneric(conn, cmd, "BRPOP", core.ListRight)
+}
+
+func (s *Server) handleLINDEX(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'LINDEX' command")
+		return
+	}
+
+	index, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+
+	val, err := s.db.LINDEX(cmd.Args[1], int(index))
+	writeBulkOrNil(conn, val, err)
+}
+
+func (s *Server) handleLINSERT(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 5 {
+		conn.WriteError("ERR wrong number of arguments for 'LINSERT' command")
+		return
+	}
+
+	var side core.ListSide
+	switch strings.ToUpper(string(cmd.Args[2])) {
+	case "BEFORE":
+		side = core.ListLeft
+	case "AFTER":
+		side = core.ListRight
+	default:
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	n, err := s.db.LINSERT(cmd.Args[1], side, cmd.Args[3], cmd.Args[4])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	if n > 0 {
+		s.waiters.signal(cmd.Args[1])
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleLLEN(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 2 {
+		conn.WriteError("ERR wrong number of arguments for 'LLEN' command")
+		return
+	}
+
+	n, err := s.db.LLEN(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleLMOVE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 5 {
+		conn.WriteError("ERR wrong number of arguments for 'LMOVE' command")
+		return
+	}
+
+	from, err := parseListSide(cmd.Args[3])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	to, err := parseListSide(cmd.Args[4])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	val, err := s.db.LMOVE(cmd.Args[1], cmd.Args[2], from, to)
+	if err == nil {
+		s.waiters.signal(cmd.Args[2])
+	}
+	writeBulkOrNil(conn, val, err)
+}
+
+func (s *Server) handleLMPOP(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for 'LMPOP' command")
+		return
+	}
+
+	keys, side, count, err := parseMPop(cmd.Args[1:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	if !s.mpop(conn, keys, side, count) {
+		conn.WriteNull()
+	}
+}
+
+func (s *Server) handleLPOP(conn redcon.Conn, cmd redcon.Command) {
+	s.popGeneric(conn, cmd, "LPOP", s.db.LPOP)
+}
+
+func (s *Server) handleLPOS(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 || len(cmd.Args)%2 == 0 {
+		conn.WriteError("ERR wrong number of arguments for 'LPOS' command")
+		return
+	}
+
+	opts := core.LPosOptions{Rank: 1, Count: 1}
+	withCount := false
+	for i := 3; i < len(cmd.Args); i += 2 {
+		n, err := strconv.ParseInt(string(cmd.Args[i+1]), 10, 64)
+		if err != nil {
+			writeError(conn, core.ErrNotIntOrOutOfRange)
+			return
+		}
+
+		switch strings.ToUpper(string(cmd.Args[i])) {
+		case "RANK":
+			if n == 0 {
+				conn.WriteError("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+				return
+			}
+			opts.Rank = int(n)
+		case "COUNT":
+			if n < 0 {
+				conn.WriteError("ERR COUNT can't be negative")
+				return
+			}
+			opts.Count = int(n)
+			withCount = true
+		case "MAXLEN":
+			if n < 0 {
+				conn.WriteError("ERR MAXLEN can't be negative")
+				return
+			}
+			opts.MaxLen = int(n)
+		default:
+			writeError(conn, core.ErrSyntax)
+			return
+		}
+	}
+
+	res, err := s.db.LPOS(cmd.Args[1], cmd.Args[2], opts)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	if !withCount {
+		if len(res) == 0 {
+			conn.WriteNull()
+		} else {
+			conn.WriteInt(res[0])
+		}
+		return
+	}
+	conn.WriteArray(len(res))
+	for _, pos := range res {
+		conn.WriteInt(pos)
+	}
+}
+
+func (s *Server) handleLPUSH(conn redcon.Conn, cmd redcon.Command) {
+	s.pushGeneric(conn, cmd, "LPUSH", s.db.LPUSH)
+}
+
+func (s *Server) handleLPUSHX(conn redcon.Conn, cmd redcon.Command) {
+	s.pushGeneric(conn, cmd, "LPUSHX", s.db.LPUSHX)
+}
+
+func (s *Server) handleLRANGE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'LRANGE' command")
+		return
+	}
+
+	start, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+	stop, err := strconv.ParseInt(string(cmd.Args[3]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+
+	res, err := s.db.LRANGE(cmd.Args[1], int(start), int(stop))
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBulks(conn, res)
+}
+
+func (s *Server) handleLREM(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'LREM' command")
+		return
+	}
+
+	count, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+
+	n, err := s.db.LREM(cmd.Args[1], int(count), cmd.Args[3])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleLSET(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'LSET' command")
+		return
+	}
+
+	index, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+
+	if err := s.db.LSET(cmd.Args[1], int(index), cmd.Args[3]); err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteString("OK")
+}
+
+func (s *Server) handleLTRIM(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'LTRIM' command")
+		return
+	}
+
+	start, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+	stop, err := strconv.ParseInt(string(cmd.Args[3]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+
+	if err := s.db.LTRIM(cmd.Args[1], int(start), int(stop)); err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteString("OK")
+}
+
+func (s *Server) handleRPOP(conn redcon.Conn, cmd redcon.Command) {
+	s.popGeneric(conn, cmd, "RPOP", s.db.RPOP)
+}
+
+func (s *Server) handleRPOPLPUSH(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'RPOPLPUSH' command")
+		return
+	}
+
+	val, err := s.db.LMOVE(cmd.Args[1], cmd.Args[2], core.ListRight, core.ListLeft)
+	if err == nil {
+		s.waiters.signal(cmd.Args[2])
+	}
+	writeBulkOrNil(conn, val, err)
+}
+
+func (s *Server) handleRPUSH(conn redcon.Conn, cmd redcon.Command) {
+	s.pushGeneric(conn, cmd, "RPUSH", s.db.RPUSH)
+}
+
+func (s *Server) handleRPUSHX(conn redcon.Conn, cmd redcon.Command) {
+	s.pushGeneric(conn, cmd, "RPUSHX", s.db.RPUSHX)
+}
+
+func (s *Server) pushGeneric(conn redcon.Conn, cmd redcon.Command, name string, fn func(key []byte, elements ...[]byte) (int, error)) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	n, err := fn(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	if n > 0 {
+		s.waiters.signal(cmd.Args[1])
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) popGeneric(conn redcon.Conn, cmd redcon.Command, name string, fn func(key []byte, count int) ([][]byte, error)) {
+	if len(cmd.Args) != 2 && len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	count := int64(1)
+	if len(cmd.Args) == 3 {
+		var err error
+		count, err = strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+		if err != nil || count < 0 {
+			conn.WriteError("ERR value is out of range, must be positive")
+			return
+		}
+	}
+
+	res, err := fn(cmd.Args[1], int(count))
+	switch {
+	case errors.Is(err, core.ErrKeyNotFound):
+		conn.WriteNull()
+	case err != nil:
+		writeError(conn, err)
+	case len(cmd.Args) == 2:
+		conn.WriteBulk(res[0])
+	default:
+		writeBulks(conn, res)
+	}
+}
+
+// bpopGeneric blocks until an element is popped from the first non-empty list.
+func (s *Server) bpopGeneric(conn redcon.Conn, cmd redcon.Command, name string, side core.ListSide) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+	cmd = cloneCommand(cmd)
+
+	deadline, err := parseTimeout(cmd.Args[len(cmd.Args)-1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	keys := cmd.Args[1 : len(cmd.Args)-1]
+
+	s.block(conn, keys, deadline, func(conn redcon.Conn) bool {
+		for _, key := range keys {
+			res, err := s.pop(key, side, 1)
+			if errors.Is(err, core.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				writeError(conn, err)
+				return true
+			}
+			conn.WriteArray(2)
+			conn.WriteBulk(key)
+			conn.WriteBulk(res[0])
+			return true
+		}
+		return false
+	})
+}
+
+// mpop pops up to count elements from the first non-empty list, reports false if all are empty.
+func (s *Server) mpop(conn redcon.Conn, keys [][]byte, side core.ListSide, count int) bool {
+	for _, key := range keys {
+		res, err := s.pop(key, side, count)
+		if errors.Is(err, core.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			writeError(conn, err)
+			return true
+		}
+		conn.WriteArray(2)
+		conn.WriteBulk(key)
+		writeBulks(conn, res)
+		return true
+	}
+	return false
+}
+
+func (s *Server) pop(key []byte, side core.ListSide, count int) ([][]byte, error) {
+	if side == core.ListLeft {
+		return s.db.LPOP(key, count)
+	}
+	return s.db.RPOP(key, count)
+}
+
+// parseMPop parses `numkeys key [key ...] <LEFT | RIGHT> [COUNT count]`.
+func parseMPop(args [][]byte) ([][]byte, core.ListSide, int, error) {
+	numkeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return nil, 0, 0, core.ErrNotIntOrOutOfRange
+	}
+	if numkeys <= 0 {
+		return nil, 0, 0, errors.New("numkeys should be greater than 0")
+	}
+	if numkeys > int64(len(args)-2) {
+		return nil, 0, 0, core.ErrSyntax
+	}
+	keys, args := args[1:numkeys+1], args[numkeys+1:]
+
+	side, err := parseListSide(args[0])
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	count := int64(1)
+	switch {
+	case len(args) == 1:
+	case len(args) == 3 && strings.EqualFold(string(args[1]), "COUNT"):
+		count, err = strconv.ParseInt(string(args[2]), 10, 64)
+		if err != nil || count <= 0 {
+			return nil, 0, 0, errors.New("count should be greater than 0")
+		}
+	default:
+		return nil, 0, 0, core.ErrSyntax
+	}
+	return keys, side, int(count), nil
+}
+
+func parseListSide(arg []byte) (core.ListSide, error) {
+	switch strings.ToUpper(string(arg)) {
+	case "LEFT":
+		return core.ListLeft, nil
+	case "RIGHT":
+		return core.ListRight, nil
+	default:
+		return 0, core.ErrSyntax
+	}
+}
+
+func writeBulks(conn redcon.Conn, vals [][]byte) {
+	conn.WriteArray(len(vals))
+	for _, val := range vals {
+		conn.WriteBulk(val)
+	}
+}
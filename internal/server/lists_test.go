@@ -0,0 +1,519 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cristalhq/testt"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestLINDEX(t *testing.T) {
+	/*
+		redis> LPUSH mylist "World"
+		(integer) 1
+		redis> LPUSH mylist "Hello"
+		(integer) 2
+		redis> LINDEX mylist 0
+		"Hello"
+		redis> LINDEX mylist -1
+		"World"
+		redis> LINDEX mylist 3
+		(nil)
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	n, err := client.LPush(ctx, "mylist", "World").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.LPush(ctx, "mylist", "Hello").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	val, err := client.LIndex(ctx, "mylist", 0).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "Hello")
+
+	val, err = client.LIndex(ctx, "mylist", -1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "World")
+
+	_, err = client.LIndex(ctx, "mylist", 3).Result()
+	testt.MustEqual(t, err, redis.Nil)
+}
+
+func TestLINSERT(t *testing.T) {
+	/*
+		redis> RPUSH mylist "Hello"
+		(integer) 1
+		redis> RPUSH mylist "World"
+		(integer) 2
+		redis> LINSERT mylist BEFORE "World" "There"
+		(integer) 3
+		redis> LRANGE mylist 0 -1
+		1) "Hello"
+		2) "There"
+		3) "World"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.RPush(ctx, "mylist", "Hello", "World").Err()
+	testt.NoError(t, err)
+
+	n, err := client.LInsertBefore(ctx, "mylist", "World", "There").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(3))
+
+	vals, err := client.LRange(ctx, "mylist", 0, -1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"Hello", "There", "World"})
+
+	err = client.Do(ctx, "LINSERT", "mylist", "NEAR", "World", "There").Err()
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+}
+
+func TestLLEN(t *testing.T) {
+	/*
+		redis> LPUSH mylist "World"
+		(integer) 1
+		redis> LPUSH mylist "Hello"
+		(integer) 2
+		redis> LLEN mylist
+		(integer) 2
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.LPush(ctx, "mylist", "World", "Hello").Err()
+	testt.NoError(t, err)
+
+	n, err := client.LLen(ctx, "mylist").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+}
+
+func TestLMOVE(t *testing.T) {
+	/*
+		redis> RPUSH mylist "one"
+		(integer) 1
+		redis> RPUSH mylist "two"
+		(integer) 2
+		redis> RPUSH mylist "three"
+		(integer) 3
+		redis> LMOVE mylist myotherlist RIGHT LEFT
+		"three"
+		redis> LMOVE mylist myotherlist LEFT RIGHT
+		"one"
+		redis> LRANGE mylist 0 -1
+		1) "two"
+		redis> LRANGE myotherlist 0 -1
+		1) "three"
+		2) "one"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.RPush(ctx, "mylist", "one", "two", "three").Err()
+	testt.NoError(t, err)
+
+	val, err := client.LMove(ctx, "mylist", "myotherlist", "RIGHT", "LEFT").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "three")
+
+	val, err = client.LMove(ctx, "mylist", "myotherlist", "LEFT", "RIGHT").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "one")
+
+	vals, err := client.LRange(ctx, "mylist", 0, -1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"two"})
+
+	vals, err = client.LRange(ctx, "myotherlist", 0, -1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"three", "one"})
+
+	_, err = client.LMove(ctx, "nonexisting", "myotherlist", "LEFT", "RIGHT").Result()
+	testt.MustEqual(t, err, redis.Nil)
+
+	val, err = client.RPopLPush(ctx, "myotherlist", "myotherlist").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "one")
+}
+
+func TestLPOP(t *testing.T) {
+	/*
+		redis> RPUSH mylist "one" "two" "three" "four" "five"
+		(integer) 5
+		redis> LPOP mylist
+		"one"
+		redis> LPOP mylist 2
+		1) "two"
+		2) "three"
+		redis> LRANGE mylist 0 -1
+		1) "four"
+		2) "five"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.RPush(ctx, "mylist", "one", "two", "three", "four", "five").Err()
+	testt.NoError(t, err)
+
+	val, err := client.LPop(ctx, "mylist").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "one")
+
+	vals, err := client.LPopCount(ctx, "mylist", 2).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"two", "three"})
+
+	vals, err = client.LRange(ctx, "mylist", 0, -1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"four", "five"})
+
+	_, err = client.LPop(ctx, "nonexisting").Result()
+	testt.MustEqual(t, err, redis.Nil)
+
+	_, err = client.LPopCount(ctx, "nonexisting", 2).Result()
+	testt.MustEqual(t, err, redis.Nil)
+
+	err = client.LPopCount(ctx, "mylist", -1).Err()
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), "ERR value is out of range, must be positive")
+}
+
+func TestLPOS(t *testing.T) {
+	/*
+		redis> RPUSH mylist a b c d 1 2 3 4 3 3 3
+		(integer) 11
+		redis> LPOS mylist 3
+		(integer) 6
+		redis> LPOS mylist 3 COUNT 0 RANK 2
+		1) (integer) 8
+		2) (integer) 9
+		3) (integer) 10
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.RPush(ctx, "mylist", "a", "b", "c", "d", 1, 2, 3, 4, 3, 3, 3).Err()
+	testt.NoError(t, err)
+
+	pos, err := client.LPos(ctx, "mylist", "3", redis.LPosArgs{}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, pos, int64(6))
+
+	all, err := client.LPosCount(ctx, "mylist", "3", 0, redis.LPosArgs{Rank: 2}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, all, []int64{8, 9, 10})
+
+	_, err = client.LPos(ctx, "mylist", "x", redis.LPosArgs{}).Result()
+	testt.MustEqual(t, err, redis.Nil)
+
+	err = client.Do(ctx, "LPOS", "mylist", "3", "RANK", 0).Err()
+	testt.WantError(t, err)
+}
+
+func TestLPUSH(t *testing.T) {
+	/*
+		redis> LPUSH mylist "world"
+		(integer) 1
+		redis> LPUSH mylist "hello"
+		(integer) 2
+		redis> LRANGE mylist 0 -1
+		1) "hello"
+		2) "world"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	n, err := client.LPush(ctx, "mylist", "world").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.LPush(ctx, "mylist", "hello").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	vals, err := client.LRange(ctx, "mylist", 0, -1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"hello", "world"})
+
+	err = client.Set(ctx, "mykey", "Hello", 0).Err()
+	testt.NoError(t, err)
+
+	err = client.LPush(ctx, "mykey", "world").Err()
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), "WRONGTYPE Operation against a key holding the wrong kind of value")
+}
+
+func TestLPUSHX(t *testing.T) {
+	/*
+		redis> LPUSH mylist "World"
+		(integer) 1
+		redis> LPUSHX mylist "Hello"
+		(integer) 2
+		redis> LPUSHX myotherlist "Hello"
+		(integer) 0
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.LPush(ctx, "mylist", "World").Err()
+	testt.NoError(t, err)
+
+	n, err := client.LPushX(ctx, "mylist", "Hello").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	n, err = client.LPushX(ctx, "myotherlist", "Hello").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+}
+
+func TestLRANGE(t *testing.T) {
+	/*
+		redis> RPUSH mylist "one"
+		(integer) 1
+		redis> RPUSH mylist "two"
+		(integer) 2
+		redis> RPUSH mylist "three"
+		(integer) 3
+		redis> LRANGE mylist 0 0
+		1) "one"
+		redis> LRANGE mylist -3 2
+		1) "one"
+		2) "two"
+		3) "three"
+		redis> LRANGE mylist 5 10
+		(empty array)
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.RPush(ctx, "mylist", "one", "two", "three").Err()
+	testt.NoError(t, err)
+
+	vals, err := client.LRange(ctx, "mylist", 0, 0).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"one"})
+
+	vals, err = client.LRange(ctx, "mylist", -3, 2).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"one", "two", "three"})
+
+	vals, err = client.LRange(ctx, "mylist", 5, 10).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{})
+}
+
+func TestLREM(t *testing.T) {
+	/*
+		redis> RPUSH mylist "hello" "hello" "foo" "hello"
+		(integer) 4
+		redis> LREM mylist -2 "hello"
+		(integer) 2
+		redis> LRANGE mylist 0 -1
+		1) "hello"
+		2) "foo"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.RPush(ctx, "mylist", "hello", "hello", "foo", "hello").Err()
+	testt.NoError(t, err)
+
+	n, err := client.LRem(ctx, "mylist", -2, "hello").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	vals, err := client.LRange(ctx, "mylist", 0, -1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"hello", "foo"})
+}
+
+func TestLSET(t *testing.T) {
+	/*
+		redis> RPUSH mylist "one" "two" "three"
+		(integer) 3
+		redis> LSET mylist 0 "four"
+		"OK"
+		redis> LSET mylist -2 "five"
+		"OK"
+		redis> LRANGE mylist 0 -1
+		1) "four"
+		2) "five"
+		3) "three"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.RPush(ctx, "mylist", "one", "two", "three").Err()
+	testt.NoError(t, err)
+
+	err = client.LSet(ctx, "mylist", 0, "four").Err()
+	testt.NoError(t, err)
+
+	err = client.LSet(ctx, "mylist", -2, "five").Err()
+	testt.NoError(t, err)
+
+	vals, err := client.LRange(ctx, "mylist", 0, -1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"four", "five", "three"})
+
+	err = client.LSet(ctx, "mylist", 10, "six").Err()
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), "ERR index out of range")
+
+	err = client.LSet(ctx, "nonexisting", 0, "six").Err()
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), "ERR no such key")
+}
+
+func TestLTRIM(t *testing.T) {
+	/*
+		redis> RPUSH mylist "one" "two" "three"
+		(integer) 3
+		redis> LTRIM mylist 1 -1
+		"OK"
+		redis> LRANGE mylist 0 -1
+		1) "two"
+		2) "three"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.RPush(ctx, "mylist", "one", "two", "three").Err()
+	testt.NoError(t, err)
+
+	err = client.LTrim(ctx, "mylist", 1, -1).Err()
+	testt.NoError(t, err)
+
+	vals, err := client.LRange(ctx, "mylist", 0, -1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"two", "three"})
+}
+
+func TestRPOP(t *testing.T) {
+	/*
+		redis> RPUSH mylist "one" "two" "three" "four" "five"
+		(integer) 5
+		redis> RPOP mylist
+		"five"
+		redis> RPOP mylist 2
+		1) "four"
+		2) "three"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.RPush(ctx, "mylist", "one", "two", "three", "four", "five").Err()
+	testt.NoError(t, err)
+
+	val, err := client.RPop(ctx, "mylist").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "five")
+
+	vals, err := client.RPopCount(ctx, "mylist", 2).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"four", "three"})
+}
+
+func TestRPUSH(t *testing.T) {
+	/*
+		redis> RPUSH mylist "hello"
+		(integer) 1
+		redis> RPUSH mylist "world"
+		(integer) 2
+		redis> LRANGE mylist 0 -1
+		1) "hello"
+		2) "world"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	n, err := client.RPush(ctx, "mylist", "hello").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.RPush(ctx, "mylist", "world").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	vals, err := client.LRange(ctx, "mylist", 0, -1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, vals, []string{"hello", "world"})
+}
+
+func TestRPUSHX(t *testing.T) {
+	/*
+		redis> RPUSH mylist "Hello"
+		(integer) 1
+		redis> RPUSHX mylist "World"
+		(integer) 2
+		redis> RPUSHX myotherlist "World"
+		(integer) 0
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.RPush(ctx, "mylist", "Hello").Err()
+	testt.NoError(t, err)
+
+	n, err := client.RPushX(ctx, "mylist", "World").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	n, err = client.RPushX(ctx, "myotherlist", "World").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+}
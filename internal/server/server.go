@@ -18,6 +18,9 @@ type Server struct {
 	ln   net.Listener
 	mux  *redcon.ServeMux
 	srv  *redcon.Server
+
+	// waiters are clients blocked on keys.
+	waiters waiters
 }
 
 type Config struct {
@@ -39,9 +42,10 @@ func New(cfg Config) (*Server, error) {
 	}
 	s.addr = s.ln.Addr().String()
 
+	s.mux = s.makeMux()
 	s.srv = redcon.NewServer(
 		s.cfg.Addr,
-		s.makeMux().ServeRESP,
+		s.mux.ServeRESP,
 		s.onAccept,
 		s.onClosed,
 	)
@@ -65,7 +69,12 @@ func (s *Server) onAccept(conn redcon.Conn) bool {
 	return true
 }
 
-func (s *Server) onClosed(conn redcon.Conn, err error) {}
+// onClosed is called for connections closed by redcon and by the server itself (detached ones).
+func (s *Server) onClosed(conn redcon.Conn, err error) {
+	if c, ok := conn.(*client); ok {
+		s.waiters.removeClient(c)
+	}
+}
 
 func (s *Server) makeMux() *redcon.ServeMux {
 	mux := redcon.NewServeMux()
@@ -75,15 +84,226 @@ func (s *Server) makeMux() *redcon.ServeMux {
 	mux.HandleFunc("decrby", s.handleDECRBY)
 	mux.HandleFunc("get", s.handleGET)
 	mux.HandleFunc("getdel", s.handleGETDEL)
+	mux.HandleFunc("getex", s.handleGETEX)
 	mux.HandleFunc("getrange", s.handleGETRANGE)
 	mux.HandleFunc("getset", s.handleGETSET)
 	mux.HandleFunc("incr", s.handleINCR)
 	mux.HandleFunc("incrby", s.handleINCRBY)
 	mux.HandleFunc("incrbyfloat", s.handleINCRBYFLOAT)
+	mux.HandleFunc("lcs", s.handleLCS)
 	mux.HandleFunc("mget", s.handleMGET)
 	mux.HandleFunc("mset", s.handleMSET)
+	mux.HandleFunc("msetnx", s.handleMSETNX)
+	mux.HandleFunc("psetex", s.handlePSETEX)
 	mux.HandleFunc("set", s.handleSET)
+	mux.HandleFunc("setex", s.handleSETEX)
+	mux.HandleFunc("setnx", s.handleSETNX)
+	mux.HandleFunc("setrange", s.handleSETRANGE)
 	mux.HandleFunc("strlen", s.handleSTRLEN)
+	mux.HandleFunc("substr", s.handleSUBSTR)
+
+	mux.HandleFunc("bitcount", s.handleBITCOUNT)
+	mux.HandleFunc("bitfield", s.handleBITFIELD)
+	mux.HandleFunc("bitfield_ro", s.handleBITFIELD_RO)
+	mux.HandleFunc("bitop", s.handleBITOP)
+	mux.HandleFunc("bitpos", s.handleBITPOS)
+	mux.HandleFunc("getbit", s.handleGETBIT)
+	mux.HandleFunc("setbit", s.handleSETBIT)
+
+	mux.HandleFunc("pfadd", s.handlePFADD)
+	mux.HandleFunc("pfcount", s.handlePFCOUNT)
+	mux.HandleFunc("pfmerge", s.handlePFMERGE)
+
+	mux.HandleFunc("copy", s.handleCOPY)
+	mux.HandleFunc("del", s.handleDEL)
+	mux.HandleFunc("exists", s.handleEXISTS)
+	mux.HandleFunc("expire", s.handleEXPIRE)
+	mux.HandleFunc("expireat", s.handleEXPIREAT)
+	mux.HandleFunc("expiretime", s.handleEXPIRETIME)
+	mux.HandleFunc("flushdb", s.handleFLUSHDB)
+	mux.HandleFunc("keys", s.handleKEYS)
+	mux.HandleFunc("persist", s.handlePERSIST)
+	mux.HandleFunc("pexpire", s.handlePEXPIRE)
+	mux.HandleFunc("pexpireat", s.handlePEXPIREAT)
+	mux.HandleFunc("pexpiretime", s.handlePEXPIRETIME)
+	mux.HandleFunc("pttl", s.handlePTTL)
+	mux.HandleFunc("rename", s.handleRENAME)
+	mux.HandleFunc("renamenx", s.handleRENAMENX)
+	mux.HandleFunc("scan", s.handleSCAN)
+	mux.HandleFunc("touch", s.handleTOUCH)
+	mux.HandleFunc("ttl", s.handleTTL)
+	mux.HandleFunc("type", s.handleTYPE)
+	mux.HandleFunc("unlink", s.handleUNLINK)
+
+	mux.HandleFunc("blmove", s.handleBLMOVE)
+	mux.HandleFunc("blmpop", s.handleBLMPOP)
+	mux.HandleFunc("blpop", s.handleBLPOP)
+	mux.HandleFunc("brpop", s.handleBRPOP)
+	mux.HandleFunc("lindex", s.handleLINDEX)
+	mux.HandleFunc("linsert", s.handleLINSERT)
+	mux.HandleFunc("llen", s.handleLLEN)
+	mux.HandleFunc("lmove", s.handleLMOVE)
+	mux.HandleFunc("lmpop", s.handleLMPOP)
+	mux.HandleFunc("lpop", s.handleLPOP)
+	mux.HandleFunc("lpos", s.handleLPOS)
+	mux.HandleFunc("lpush", s.handleLPUSH)
+	mux.HandleFunc("lpushx", s.handleLPUSHX)
+	mux.HandleFunc("lrange", s.handleLRANGE)
+	mux.HandleFunc("lrem", s.handleLREM)
+	mux.HandleFunc("lset", s.handleLSET)
+	mux.HandleFunc("ltrim", s.handleLTRIM)
+	mux.HandleFunc("rpop", s.handleRPOP)
+	mux.HandleFunc("rpoplpush", s.handleRPOPLPUSH)
+	mux.HandleFunc("rpush", s.handleRPUSH)
+	mux.HandleFunc("rpushx", s.handleRPUSHX)
+
+	mux.HandleFunc("hdel", s.handleHDEL)
+	mux.HandleFunc("hexists", s.handleHEXISTS)
+	mux.HandleFunc("hexpire", s.handleHEXPIRE)
+	mux.HandleFunc("hexpireat", s.handleHEXPIREAT)
+	mux.HandleFunc("hexpiretime", s.handleHEXPIRETIME)
+	mux.HandleFunc("hget", s.handleHGET)
+	mux.HandleFunc("hgetall", s.handleHGETALL)
+	mux.HandleFunc("hincrby", s.handleHINCRBY)
+	mux.HandleFunc("hincrbyfloat", s.handleHINCRBYFLOAT)
+	mux.HandleFunc("hkeys", s.handleHKEYS)
+	mux.HandleFunc("hlen", s.handleHLEN)
+	mux.HandleFunc("hmget", s.handleHMGET)
+	mux.HandleFunc("hmset", s.handleHMSET)
+	mux.HandleFunc("hpersist", s.handleHPERSIST)
+	mux.HandleFunc("hpexpire", s.handleHPEXPIRE)
+	mux.HandleFunc("hpexpireat", s.handleHPEXPIREAT)
+	mux.HandleFunc("hpexpiretime", s.handleHPEXPIRETIME)
+	mux.HandleFunc("hpttl", s.handleHPTTL)
+	mux.HandleFunc("hrandfield", s.handleHRANDFIELD)
+	mux.HandleFunc("hscan", s.handleHSCAN)
+	mux.HandleFunc("hset", s.handleHSET)
+	mux.HandleFunc("hsetnx", s.handleHSETNX)
+	mux.HandleFunc("hstrlen", s.handleHSTRLEN)
+	mux.HandleFunc("httl", s.handleHTTL)
+	mux.HandleFunc("hvals", s.handleHVALS)
+
+	mux.HandleFunc("sadd", s.handleSADD)
+	mux.HandleFunc("scard", s.handleSCARD)
+	mux.HandleFunc("sdiff", s.handleSDIFF)
+	mux.HandleFunc("sdiffstore", s.handleSDIFFSTORE)
+	mux.HandleFunc("sinter", s.handleSINTER)
+	mux.HandleFunc("sintercard", s.handleSINTERCARD)
+	mux.HandleFunc("sinterstore", s.handleSINTERSTORE)
+	mux.HandleFunc("sismember", s.handleSISMEMBER)
+	mux.HandleFunc("smembers", s.handleSMEMBERS)
+	mux.HandleFunc("smismember", s.handleSMISMEMBER)
+	mux.HandleFunc("smove", s.handleSMOVE)
+	mux.HandleFunc("spop", s.handleSPOP)
+	mux.HandleFunc("srandmember", s.handleSRANDMEMBER)
+	mux.HandleFunc("srem", s.handleSREM)
+	mux.HandleFunc("sscan", s.handleSSCAN)
+	mux.HandleFunc("sunion", s.handleSUNION)
+	mux.HandleFunc("sunionstore", s.handleSUNIONSTORE)
+
+	mux.HandleFunc("bzmpop", s.handleBZMPOP)
+	mux.HandleFunc("bzpopmax", s.handleBZPOPMAX)
+	mux.HandleFunc("bzpopmin", s.handleBZPOPMIN)
+	mux.HandleFunc("zadd", s.handleZADD)
+	mux.HandleFunc("zcard", s.handleZCARD)
+	mux.HandleFunc("zcount", s.handleZCOUNT)
+	mux.HandleFunc("zdiff", s.handleZDIFF)
+	mux.HandleFunc("zdiffstore", s.handleZDIFFSTORE)
+	mux.HandleFunc("zincrby", s.handleZINCRBY)
+	mux.HandleFunc("zinter", s.handleZINTER)
+	mux.HandleFunc("zintercard", s.handleZINTERCARD)
+	mux.HandleFunc("zinterstore", s.handleZINTERSTORE)
+	mux.HandleFunc("zlexcount", s.handleZLEXCOUNT)
+	mux.HandleFunc("zmpop", s.handleZMPOP)
+	mux.HandleFunc("zmscore", s.handleZMSCORE)
+	mux.HandleFunc("zpopmax", s.handleZPOPMAX)
+	mux.HandleFunc("zpopmin", s.handleZPOPMIN)
+	mux.HandleFunc("zrange", s.handleZRANGE)
+	mux.HandleFunc("zrangebylex", s.handleZRANGEBYLEX)
+	mux.HandleFunc("zrangebyscore", s.handleZRANGEBYSCORE)
+	mux.HandleFunc("zrangestore", s.handleZRANGESTORE)
+	mux.HandleFunc("zrank", s.handleZRANK)
+	mux.HandleFunc("zrem", s.handleZREM)
+	mux.HandleFunc("zremrangebylex", s.handleZREMRANGEBYLEX)
+	mux.HandleFunc("zremrangebyrank", s.handleZREMRANGEBYRANK)
+	mux.HandleFunc("zremrangebyscore", s.handleZREMRANGEBYSCORE)
+	mux.HandleFunc("zrevrange", s.handleZREVRANGE)
+	mux.HandleFunc("zrevrangebylex", s.handleZREVRANGEBYLEX)
+	mux.HandleFunc("zrevrangebyscore", s.handleZREVRANGEBYSCORE)
+	mux.HandleFunc("zrevrank", s.handleZREVRANK)
+	mux.HandleFunc("zscore", s.handleZSCORE)
+	mux.HandleFunc("zunion", s.handleZUNION)
+	mux.HandleFunc("zunionstore", s.handleZUNIONSTORE)
+
+	mux.HandleFunc("geoadd", s.handleGEOADD)
+	mux.HandleFunc("geodist", s.handleGEODIST)
+	mux.HandleFunc("geohash", s.handleGEOHASH)
+	mux.HandleFunc("geopos", s.handleGEOPOS)
+	mux.HandleFunc("geosearch", s.handleGEOSEARCH)
+	mux.HandleFunc("geosearchstore", s.handleGEOSEARCHSTORE)
+
+	mux.HandleFunc("xack", s.handleXACK)
+	mux.HandleFunc("xadd", s.handleXADD)
+	mux.HandleFunc("xautoclaim", s.handleXAUTOCLAIM)
+	mux.HandleFunc("xclaim", s.handleXCLAIM)
+	mux.HandleFunc("xdel", s.handleXDEL)
+	mux.HandleFunc("xgroup", s.handleXGROUP)
+	mux.HandleFunc("xinfo", s.handleXINFO)
+	mux.HandleFunc("xlen", s.handleXLEN)
+	mux.HandleFunc("xpending", s.handleXPENDING)
+	mux.HandleFunc("xrange", s.handleXRANGE)
+	mux.HandleFunc("xread", s.handleXREAD)
+	mux.HandleFunc("xreadgroup", s.handleXREADGROUP)
+	mux.HandleFunc("xrevrange", s.handleXREVRANGE)
+	mux.HandleFunc("xtrim", s.handleXTRIM)
+
+	mux.HandleFunc("json.arrappend", s.handleJSONARRAPPEND)
+	mux.HandleFunc("json.arrinsert", s.handleJSONARRINSERT)
+	mux.HandleFunc("json.arrpop", s.handleJSONARRPOP)
+	mux.HandleFunc("json.del", s.handleJSONDEL)
+	mux.HandleFunc("json.forget", s.handleJSONDEL)
+	mux.HandleFunc("json.get", s.handleJSONGET)
+	mux.HandleFunc("json.merge", s.handleJSONMERGE)
+	mux.HandleFunc("json.mget", s.handleJSONMGET)
+	mux.HandleFunc("json.numincrby", s.handleJSONNUMINCRBY)
+	mux.HandleFunc("json.objkeys", s.handleJSONOBJKEYS)
+	mux.HandleFunc("json.set", s.handleJSONSET)
+	mux.HandleFunc("json.strappend", s.handleJSONSTRAPPEND)
+	mux.HandleFunc("json.type", s.handleJSONTYPE)
+
+	mux.HandleFunc("bf.add", s.handleBFADD)
+	mux.HandleFunc("bf.exists", s.handleBFEXISTS)
+	mux.HandleFunc("bf.info", s.handleBFINFO)
+	mux.HandleFunc("bf.madd", s.handleBFMADD)
+	mux.HandleFunc("bf.mexists", s.handleBFMEXISTS)
+	mux.HandleFunc("bf.reserve", s.handleBFRESERVE)
+
+	mux.HandleFunc("cf.add", s.handleCFADD)
+	mux.HandleFunc("cf.addnx", s.handleCFADDNX)
+	mux.HandleFunc("cf.count", s.handleCFCOUNT)
+	mux.HandleFunc("cf.del", s.handleCFDEL)
+	mux.HandleFunc("cf.exists", s.handleCFEXISTS)
+
+	mux.HandleFunc("cms.incrby", s.handleCMSINCRBY)
+	mux.HandleFunc("cms.initbydim", s.handleCMSINITBYDIM)
+	mux.HandleFunc("cms.initbyprob", s.handleCMSINITBYPROB)
+	mux.HandleFunc("cms.merge", s.handleCMSMERGE)
+	mux.HandleFunc("cms.query", s.handleCMSQUERY)
+
+	mux.HandleFunc("topk.add", s.handleTOPKADD)
+	mux.HandleFunc("topk.incrby", s.handleTOPKINCRBY)
+	mux.HandleFunc("topk.info", s.handleTOPKINFO)
+	mux.HandleFunc("topk.list", s.handleTOPKLIST)
+	mux.HandleFunc("topk.query", s.handleTOPKQUERY)
+	mux.HandleFunc("topk.reserve", s.handleTOPKRESERVE)
+
+	mux.HandleFunc("ts.add", s.handleTSADD)
+	mux.HandleFunc("ts.create", s.handleTSCREATE)
+	mux.HandleFunc("ts.createrule", s.handleTSCREATERULE)
+	mux.HandleFunc("ts.deleterule", s.handleTSDELETERULE)
+	mux.HandleFunc("ts.mrange", s.handleTSMRANGE)
+	mux.HandleFunc("ts.queryindex", s.handleTSQUERYINDEX)
+	mux.HandleFunc("ts.range", s.handleTSRANGE)
 
 	return mux
 }
@@ -0,0 +1,335 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/tidwall/redcon"
+)
+
+// Sets operations https://redis.io/commands/?group=set
+
+func (s *Server) handleSADD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'SADD' command")
+		return
+	}
+
+	n, err := s.db.SADD(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleSCARD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 2 {
+		conn.WriteError("ERR wrong number of arguments for 'SCARD' command")
+		return
+	}
+
+	n, err := s.db.SCARD(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleSDIFF(conn redcon.Conn, cmd redcon.Command) {
+	s.setAlgebraGeneric(conn, cmd, "SDIFF", s.db.SDIFF)
+}
+
+func (s *Server) handleSDIFFSTORE(conn redcon.Conn, cmd redcon.Command) {
+	s.setStoreGeneric(conn, cmd, "SDIFFSTORE", s.db.SDIFFSTORE)
+}
+
+func (s *Server) handleSINTER(conn redcon.Conn, cmd redcon.Command) {
+	s.setAlgebraGeneric(conn, cmd, "SINTER", s.db.SINTER)
+}
+
+func (s *Server) handleSINTERCARD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'SINTERCARD' command")
+		return
+	}
+
+	keys, limit, err := parseInterCard(cmd.Args[1:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	n, err := s.db.SINTERCARD(limit, keys...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleSINTERSTORE(conn redcon.Conn, cmd redcon.Command) {
+	s.setStoreGeneric(conn, cmd, "SINTERSTORE", s.db.SINTERSTORE)
+}
+
+func (s *Server) handleSISMEMBER(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'SISMEMBER' command")
+		return
+	}
+
+	ok, err := s.db.SISMEMBER(cmd.Args[1], cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBool(conn, ok)
+}
+
+func (s *Server) handleSMEMBERS(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 2 {
+		conn.WriteError("ERR wrong number of arguments for 'SMEMBERS' command")
+		return
+	}
+
+	res, err := s.db.SMEMBERS(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBulks(conn, res)
+}
+
+func (s *Server) handleSMISMEMBER(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'SMISMEMBER' command")
+		return
+	}
+
+	res, err := s.db.SMISMEMBER(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteArray(len(res))
+	for _, ok := range res {
+		writeBool(conn, ok)
+	}
+}
+
+func (s *Server) handleSMOVE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'SMOVE' command")
+		return
+	}
+
+	ok, err := s.db.SMOVE(cmd.Args[1], cmd.Args[2], cmd.Args[3])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBool(conn, ok)
+}
+
+func (s *Server) handleSPOP(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 2 && len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'SPOP' command")
+		return
+	}
+
+	if len(cmd.Args) == 2 {
+		res, err := s.db.SPOP(cmd.Args[1], 1)
+		writeSingleOrNil(conn, res, err)
+		return
+	}
+
+	count, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+	if err != nil || count < 0 {
+		conn.WriteError("ERR value is out of range, must be positive")
+		return
+	}
+
+	res, err := s.db.SPOP(cmd.Args[1], int(count))
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBulks(conn, res)
+}
+
+func (s *Server) handleSRANDMEMBER(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 2 && len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'SRANDMEMBER' command")
+		return
+	}
+
+	if len(cmd.Args) == 2 {
+		res, err := s.db.SRANDMEMBER(cmd.Args[1], 1)
+		writeSingleOrNil(conn, res, err)
+		return
+	}
+
+	count, err := parseRandCount(cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.SRANDMEMBER(cmd.Args[1], count)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBulks(conn, res)
+}
+
+func (s *Server) handleSREM(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'SREM' command")
+		return
+	}
+
+	n, err := s.db.SREM(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleSSCAN(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'SSCAN' command")
+		return
+	}
+
+	cursor, err := strconv.ParseUint(string(cmd.Args[2]), 10, 64)
+	if err != nil {
+		conn.WriteError("ERR invalid cursor")
+		return
+	}
+	opts, err := parseScanOptions(cmd.Args[3:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, next, err := s.db.SSCAN(cmd.Args[1], cursor, opts)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteArray(2)
+	conn.WriteBulkString(strconv.FormatUint(next, 10))
+	writeBulks(conn, res)
+}
+
+func (s *Server) handleSUNION(conn redcon.Conn, cmd redcon.Command) {
+	s.setAlgebraGeneric(conn, cmd, "SUNION", s.db.SUNION)
+}
+
+func (s *Server) handleSUNIONSTORE(conn redcon.Conn, cmd redcon.Command) {
+	s.setStoreGeneric(conn, cmd, "SUNIONSTORE", s.db.SUNIONSTORE)
+}
+
+func (s *Server) setAlgebraGeneric(conn redcon.Conn, cmd redcon.Command, name string, fn func(keys ...[]byte) ([][]byte, error)) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	res, err := fn(cmd.Args[1:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBulks(conn, res)
+}
+
+func (s *Server) setStoreGeneric(conn redcon.Conn, cmd redcon.Command, name string, fn func(dst []byte, keys ...[]byte) (int, error)) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	n, err := fn(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+// parseScanOptions parses MATCH and COUNT arguments of *SCAN commands.
+func parseScanOptions(args [][]byte) (core.ScanOptions, error) {
+	var opts core.ScanOptions
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return core.ScanOptions{}, core.ErrSyntax
+		}
+
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			opts.Match = args[i+1]
+		case "COUNT":
+			count, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return core.ScanOptions{}, core.ErrNotIntOrOutOfRange
+			}
+			if count < 1 {
+				return core.ScanOptions{}, core.ErrSyntax
+			}
+			opts.Count = int(count)
+		default:
+			return core.ScanOptions{}, core.ErrSyntax
+		}
+	}
+	return opts, nil
+}
+
+// parseInterCard parses numkeys, keys and optional LIMIT of SINTERCARD and ZINTERCARD.
+func parseInterCard(args [][]byte) ([][]byte, int, error) {
+	numkeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return nil, 0, core.ErrNotIntOrOutOfRange
+	}
+	if numkeys <= 0 {
+		return nil, 0, errors.New("numkeys should be greater than 0")
+	}
+	if numkeys > int64(len(args)-1) {
+		return nil, 0, errors.New("Number of keys can't be greater than number of args")
+	}
+	keys, args := args[1:numkeys+1], args[numkeys+1:]
+
+	limit := int64(0)
+	switch {
+	case len(args) == 0:
+	case len(args) == 2 && strings.EqualFold(string(args[0]), "LIMIT"):
+		limit, err = strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return nil, 0, core.ErrNotIntOrOutOfRange
+		}
+		if limit < 0 {
+			return nil, 0, errors.New("LIMIT can't be negative")
+		}
+	default:
+		return nil, 0, core.ErrSyntax
+	}
+	return keys, int(limit), nil
+}
+
+// writeSingleOrNil writes the first value as a bulk string or null for no values.
+func writeSingleOrNil(conn redcon.Conn, vals [][]byte, err error) {
+	switch {
+	case err != nil:
+		writeError(conn, err)
+	case len(vals) == 0:
+		conn.WriteNull()
+	default:
+		conn.WriteBulk(vals[0])
+	}
+}
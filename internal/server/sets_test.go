@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cristalhq/testt"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSADD(t *testing.T) {
+	/*
+		redis> SADD myset "Hello"
+		(integer) 1
+		redis> SADD myset "World"
+		(integer) 1
+		redis> SADD myset "World"
+		(integer) 0
+		redis> SMEMBERS myset
+		1) "Hello"
+		2) "World"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	n, err := client.SAdd(ctx, "myset", "Hello").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.SAdd(ctx, "myset", "World").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.SAdd(ctx, "myset", "World").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+
+	res, err := client.SMembers(ctx, "myset").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []string{"Hello", "World"})
+
+	ok, err := client.SIsMember(ctx, "myset", "Hello").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	oks, err := client.SMIsMember(ctx, "myset", "Hello", "nope").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, oks, []bool{true, false})
+
+	typ, err := client.Type(ctx, "myset").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "set")
+
+	err = client.Do(ctx, "SADD", "myset").Err()
+	testt.MustEqual(t, err.Error(), "ERR wrong number of arguments for 'SADD' command")
+}
+
+func TestSINTERCARD(t *testing.T) {
+	/*
+		redis> SADD key1 "a" "b" "c" "d"
+		(integer) 4
+		redis> SADD key2 "c" "d" "e"
+		(integer) 3
+		redis> SINTER key1 key2
+		1) "c"
+		2) "d"
+		redis> SINTERCARD 2 key1 key2
+		(integer) 2
+		redis> SINTERCARD 2 key1 key2 LIMIT 1
+		(integer) 1
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.SAdd(ctx, "key1", "a", "b", "c", "d").Err()
+	testt.NoError(t, err)
+	err = client.SAdd(ctx, "key2", "c", "d", "e").Err()
+	testt.NoError(t, err)
+
+	res, err := client.SInter(ctx, "key1", "key2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []string{"c", "d"})
+
+	n, err := client.SInterCard(ctx, 0, "key1", "key2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	n, err = client.SInterCard(ctx, 1, "key1", "key2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	err = client.Do(ctx, "SINTERCARD", "0", "key1").Err()
+	testt.MustEqual(t, err.Error(), "ERR numkeys should be greater than 0")
+
+	err = client.Do(ctx, "SINTERCARD", "3", "key1", "key2").Err()
+	testt.MustEqual(t, err.Error(), "ERR Number of keys can't be greater than number of args")
+
+	err = client.Do(ctx, "SINTERCARD", "2", "key1", "key2", "LIMIT", "-1").Err()
+	testt.MustEqual(t, err.Error(), "ERR LIMIT can't be negative")
+}
+
+func TestSUNIONSTORE(t *testing.T) {
+	/*
+		redis> SADD key1 "a" "b" "c"
+		(integer) 3
+		redis> SADD key2 "c" "d" "e"
+		(integer) 3
+		redis> SUNIONSTORE key key1 key2
+		(integer) 5
+		redis> SDIFFSTORE key key1 key2
+		(integer) 2
+		redis> SMEMBERS key
+		1) "a"
+		2) "b"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.SAdd(ctx, "key1", "a", "b", "c").Err()
+	testt.NoError(t, err)
+	err = client.SAdd(ctx, "key2", "c", "d", "e").Err()
+	testt.NoError(t, err)
+
+	n, err := client.SUnionStore(ctx, "key", "key1", "key2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(5))
+
+	n, err = client.SDiffStore(ctx, "key", "key1", "key2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	res, err := client.SMembers(ctx, "key").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []string{"a", "b"})
+}
+
+func TestSPOP(t *testing.T) {
+	/*
+		redis> SADD myset "one" "two" "three"
+		(integer) 3
+		redis> SPOP myset
+		"one"
+		redis> SPOP myset 5
+		1) "three"
+		2) "two"
+		redis> SPOP myset
+		(nil)
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.SAdd(ctx, "myset", "one", "two", "three").Err()
+	testt.NoError(t, err)
+
+	err = client.SPop(ctx, "myset").Err()
+	testt.NoError(t, err)
+
+	res, err := client.SPopN(ctx, "myset", 5).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 2)
+
+	_, err = client.SPop(ctx, "myset").Result()
+	testt.MustEqual(t, err, redis.Nil)
+
+	err = client.Do(ctx, "SPOP", "myset", "-1").Err()
+	testt.MustEqual(t, err.Error(), "ERR value is out of range, must be positive")
+}
+
+func TestSRANDMEMBER(t *testing.T) {
+	/*
+		redis> SADD myset one two three
+		(integer) 3
+		redis> SRANDMEMBER myset -5
+		1) "one"
+		2) "one"
+		3) "one"
+		4) "two"
+		5) "one"
+		redis> SRANDMEMBER nokey
+		(nil)
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.SAdd(ctx, "myset", "one", "two", "three").Err()
+	testt.NoError(t, err)
+
+	res, err := client.SRandMemberN(ctx, "myset", -5).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 5)
+
+	res, err = client.SRandMemberN(ctx, "myset", 5).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 3)
+
+	_, err = client.SRandMember(ctx, "nokey").Result()
+	testt.MustEqual(t, err, redis.Nil)
+
+	// a huge negative count is rejected, the connection stays alive.
+	err = client.Do(ctx, "SRANDMEMBER", "myset", "-9223372036854775807").Err()
+	testt.MustEqual(t, err.Error(), "ERR value is out of range")
+
+	n, err := client.SCard(ctx, "myset").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(3))
+}
+
+func TestSSCAN(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.SAdd(ctx, "myset", "a1", "a2", "b1").Err()
+	testt.NoError(t, err)
+
+	res, cursor, err := client.SScan(ctx, "myset", 0, "a*", 10).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, cursor, uint64(0))
+	testt.MustEqual(t, res, []string{"a1", "a2"})
+
+	err = client.Do(ctx, "SSCAN", "myset", "0", "COUNT").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+}
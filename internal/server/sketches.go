@@ -0,0 +1,322 @@
+package server
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/tidwall/redcon"
+)
+
+// Count-Min sketch operations https://redis.io/commands/?group=cms
+// Top-K operations https://redis.io/commands/?group=topk
+
+// topkMaxIncrement is the maximum increment of TOPK.INCRBY, like in RedisBloom.
+const topkMaxIncrement = 100000
+
+func (s *Server) handleCMSINCRBY(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 || len(cmd.Args)%2 != 0 {
+		conn.WriteError("ERR wrong number of arguments for 'CMS.INCRBY' command")
+		return
+	}
+
+	n := (len(cmd.Args) - 2) / 2
+	items, incrs := make([][]byte, n), make([]int64, n)
+	for i := range items {
+		items[i] = cmd.Args[2+2*i]
+		incr, err := strconv.ParseInt(string(cmd.Args[3+2*i]), 10, 64)
+		if err != nil || incr < 0 || incr > math.MaxUint32 {
+			writeError(conn, core.ErrCMSNumber)
+			return
+		}
+		incrs[i] = incr
+	}
+
+	res, err := s.db.CMSINCRBY(cmd.Args[1], items, incrs)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeInts(conn, res)
+}
+
+func (s *Server) handleCMSINITBYDIM(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'CMS.INITBYDIM' command")
+		return
+	}
+
+	width, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+	if err != nil || width < 1 || width > math.MaxUint32 {
+		writeError(conn, core.ErrCMSWidth)
+		return
+	}
+	depth, err := strconv.ParseInt(string(cmd.Args[3]), 10, 64)
+	if err != nil || depth < 1 || depth > math.MaxUint32 {
+		writeError(conn, core.ErrCMSDepth)
+		return
+	}
+
+	if err := s.db.CMSINIT(cmd.Args[1], width, depth); err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteString("OK")
+}
+
+func (s *Server) handleCMSINITBYPROB(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'CMS.INITBYPROB' command")
+		return
+	}
+
+	errorRate, err := strconv.ParseFloat(string(cmd.Args[2]), 64)
+	if err != nil || errorRate <= 0 || errorRate >= 1 {
+		writeError(conn, core.ErrCMSErrorRate)
+		return
+	}
+	prob, err := strconv.ParseFloat(string(cmd.Args[3]), 64)
+	if err != nil || prob <= 0 || prob >= 1 {
+		writeError(conn, core.ErrCMSProb)
+		return
+	}
+	width, depth := core.CMSDimsByProb(errorRate, prob)
+	if width > math.MaxUint32 {
+		writeError(conn, core.ErrCMSErrorRate)
+		return
+	}
+
+	if err := s.db.CMSINIT(cmd.Args[1], width, depth); err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteString("OK")
+}
+
+func (s *Server) handleCMSMERGE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for 'CMS.MERGE' command")
+		return
+	}
+
+	numKeys, err := strconv.Atoi(string(cmd.Args[2]))
+	if err != nil || numKeys < 1 {
+		writeError(conn, core.ErrCMSNumKeys)
+		return
+	}
+	args := cmd.Args[3:]
+	if len(args) < numKeys {
+		conn.WriteError("ERR wrong number of arguments for 'CMS.MERGE' command")
+		return
+	}
+	keys, args := args[:numKeys], args[numKeys:]
+
+	weights := make([]int64, numKeys)
+	for i := range weights {
+		weights[i] = 1
+	}
+	if len(args) > 0 {
+		if !strings.EqualFold(string(args[0]), "WEIGHTS") || len(args)-1 != numKeys {
+			conn.WriteError("ERR wrong number of arguments for 'CMS.MERGE' command")
+			return
+		}
+		for i, arg := range args[1:] {
+			if weights[i], err = strconv.ParseInt(string(arg), 10, 64); err != nil {
+				writeError(conn, core.ErrCMSWeight)
+				return
+			}
+		}
+	}
+
+	if err := s.db.CMSMERGE(cmd.Args[1], keys, weights); err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteString("OK")
+}
+
+func (s *Server) handleCMSQUERY(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'CMS.QUERY' command")
+		return
+	}
+
+	res, err := s.db.CMSQUERY(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeInts(conn, res)
+}
+
+func (s *Server) handleTOPKADD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'TOPK.ADD' command")
+		return
+	}
+
+	items := cmd.Args[2:]
+	incrs := make([]int64, len(items))
+	for i := range incrs {
+		incrs[i] = 1
+	}
+	s.topkIncrBy(conn, cmd.Args[1], items, incrs)
+}
+
+func (s *Server) handleTOPKINCRBY(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 || len(cmd.Args)%2 != 0 {
+		conn.WriteError("ERR wrong number of arguments for 'TOPK.INCRBY' command")
+		return
+	}
+
+	n := (len(cmd.Args) - 2) / 2
+	items, incrs := make([][]byte, n), make([]int64, n)
+	for i := range items {
+		items[i] = cmd.Args[2+2*i]
+		incr, err := strconv.ParseInt(string(cmd.Args[3+2*i]), 10, 64)
+		if err != nil || incr < 1 || incr > topkMaxIncrement {
+			writeError(conn, core.ErrTopKIncrement)
+			return
+		}
+		incrs[i] = incr
+	}
+	s.topkIncrBy(conn, cmd.Args[1], items, incrs)
+}
+
+func (s *Server) topkIncrBy(conn redcon.Conn, key []byte, items [][]byte, incrs []int64) {
+	res, err := s.db.TOPKINCRBY(key, items, incrs)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteArray(len(res))
+	for _, expelled := range res {
+		if expelled == nil {
+			conn.WriteNull()
+			continue
+		}
+		conn.WriteBulk(expelled)
+	}
+}
+
+func (s *Server) handleTOPKINFO(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 2 {
+		conn.WriteError("ERR wrong number of arguments for 'TOPK.INFO' command")
+		return
+	}
+
+	info, err := s.db.TOPKINFO(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteArray(8)
+	conn.WriteBulkString("k")
+	conn.WriteInt64(info.K)
+	conn.WriteBulkString("width")
+	conn.WriteInt64(info.Width)
+	conn.WriteBulkString("depth")
+	conn.WriteInt64(info.Depth)
+	conn.WriteBulkString("decay")
+	conn.WriteBulkString(strconv.FormatFloat(info.Decay, 'g', 17, 64))
+}
+
+func (s *Server) handleTOPKLIST(conn redcon.Conn, cmd redcon.Command) {
+	withCount := false
+	switch {
+	case len(cmd.Args) < 2:
+		conn.WriteError("ERR wrong number of arguments for 'TOPK.LIST' command")
+		return
+	case len(cmd.Args) == 3 && strings.EqualFold(string(cmd.Args[2]), "WITHCOUNT"):
+		withCount = true
+	case len(cmd.Args) > 2:
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	res, err := s.db.TOPKLIST(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	if !withCount {
+		conn.WriteArray(len(res))
+		for _, e := range res {
+			conn.WriteBulk(e.Item)
+		}
+		return
+	}
+	conn.WriteArray(2 * len(res))
+	for _, e := range res {
+		conn.WriteBulk(e.Item)
+		conn.WriteInt64(e.Count)
+	}
+}
+
+func (s *Server) handleTOPKQUERY(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'TOPK.QUERY' command")
+		return
+	}
+
+	res, err := s.db.TOPKQUERY(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteArray(len(res))
+	for _, ok := range res {
+		writeBool(conn, ok)
+	}
+}
+
+func (s *Server) handleTOPKRESERVE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 && len(cmd.Args) != 6 {
+		conn.WriteError("ERR wrong number of arguments for 'TOPK.RESERVE' command")
+		return
+	}
+
+	opts := core.TopKOptions{
+		Width: core.TopKDefaultWidth,
+		Depth: core.TopKDefaultDepth,
+		Decay: core.TopKDefaultDecay,
+	}
+	var err error
+	opts.K, err = strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+	if err != nil || opts.K < 1 || opts.K > math.MaxUint32 {
+		writeError(conn, core.ErrTopKK)
+		return
+	}
+	if len(cmd.Args) == 6 {
+		opts.Width, err = strconv.ParseInt(string(cmd.Args[3]), 10, 64)
+		if err != nil || opts.Width < 1 || opts.Width > math.MaxUint32 {
+			writeError(conn, core.ErrTopKWidth)
+			return
+		}
+		opts.Depth, err = strconv.ParseInt(string(cmd.Args[4]), 10, 64)
+		if err != nil || opts.Depth < 1 || opts.Depth > math.MaxUint32 {
+			writeError(conn, core.ErrTopKDepth)
+			return
+		}
+		opts.Decay, err = strconv.ParseFloat(string(cmd.Args[5]), 64)
+		if err != nil || opts.Decay <= 0 || opts.Decay > 1 {
+			writeError(conn, core.ErrTopKDecay)
+			return
+		}
+	}
+
+	if err := s.db.TOPKRESERVE(cmd.Args[1], opts); err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteString("OK")
+}
+
+func writeInts(conn redcon.Conn, nums []int64) {
+	conn.WriteArray(len(nums))
+	for _, n := range nums {
+		conn.WriteInt64(n)
+	}
+}
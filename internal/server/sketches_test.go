@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cristalhq/testt"
+)
+
+func TestCMSINCRBY(t *testing.T) {
+	/*
+		redis> CMS.INITBYDIM test 2000 5
+		OK
+		redis> CMS.INCRBY test foo 10 bar 42
+		1) (integer) 10
+		2) (integer) 42
+		redis> CMS.QUERY test foo bar
+		1) (integer) 10
+		2) (integer) 42
+		redis> CMS.INITBYPROB test2 0.001 0.01
+		OK
+		redis> CMS.INITBYDIM test 2000 5
+		(error) CMS: key already exists
+		redis> CMS.QUERY nokey foo
+		(error) CMS: key does not exist
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Do(ctx, "CMS.INITBYDIM", "test", "2000", "5").Err()
+	testt.NoError(t, err)
+
+	res, err := client.Do(ctx, "CMS.INCRBY", "test", "foo", "10", "bar", "42").Int64Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{10, 42})
+
+	res, err = client.Do(ctx, "CMS.QUERY", "test", "foo", "bar").Int64Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{10, 42})
+
+	err = client.Do(ctx, "CMS.INITBYPROB", "test2", "0.001", "0.01").Err()
+	testt.NoError(t, err)
+
+	cases := []struct {
+		args []any
+		err  string
+	}{
+		{[]any{"CMS.INITBYDIM", "test", "2000", "5"}, "CMS: key already exists"},
+		{[]any{"CMS.QUERY", "nokey", "foo"}, "CMS: key does not exist"},
+		{[]any{"CMS.INITBYDIM", "x", "0", "5"}, "CMS: invalid width"},
+		{[]any{"CMS.INITBYDIM", "x", "10", "abc"}, "CMS: invalid depth"},
+		{[]any{"CMS.INITBYPROB", "x", "1", "0.01"}, "CMS: invalid overestimation value"},
+		{[]any{"CMS.INITBYPROB", "x", "0.01", "0"}, "CMS: invalid prob value"},
+		{[]any{"CMS.INCRBY", "test", "foo", "-1"}, "CMS: Cannot parse number"},
+		{[]any{"CMS.INCRBY", "test", "foo", "4294967295"}, "CMS: INCRBY overflow"},
+		{[]any{"CMS.INCRBY", "test", "foo"}, "ERR wrong number of arguments for 'CMS.INCRBY' command"},
+		{[]any{"CMS.MERGE", "test", "2", "test2", "test2"}, "CMS: width/depth is not equal"},
+		{[]any{"CMS.MERGE", "test", "0", "test2"}, "CMS: invalid numkeys"},
+	}
+	for _, tc := range cases {
+		err := client.Do(ctx, tc.args...).Err()
+		testt.MustEqual(t, err.Error(), tc.err)
+	}
+
+	typ, err := client.Type(ctx, "test").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "CMSk-type")
+}
+
+func TestCMSMERGE(t *testing.T) {
+	/*
+		redis> CMS.INITBYDIM test1 100 5
+		OK
+		redis> CMS.INITBYDIM test2 100 5
+		OK
+		redis> CMS.INITBYDIM dest 100 5
+		OK
+		redis> CMS.INCRBY test1 foo 1
+		1) (integer) 1
+		redis> CMS.INCRBY test2 foo 2
+		1) (integer) 2
+		redis> CMS.MERGE dest 2 test1 test2 WEIGHTS 1 3
+		OK
+		redis> CMS.QUERY dest foo
+		1) (integer) 7
+		redis> CMS.MERGE dest 2 test1 test2
+		OK
+		redis> CMS.QUERY dest foo
+		1) (integer) 3
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	for _, key := range []string{"test1", "test2", "dest"} {
+		err := client.Do(ctx, "CMS.INITBYDIM", key, "100", "5").Err()
+		testt.NoError(t, err)
+	}
+	err := client.Do(ctx, "CMS.INCRBY", "test1", "foo", "1").Err()
+	testt.NoError(t, err)
+	err = client.Do(ctx, "CMS.INCRBY", "test2", "foo", "2").Err()
+	testt.NoError(t, err)
+
+	err = client.Do(ctx, "CMS.MERGE", "dest", "2", "test1", "test2", "WEIGHTS", "1", "3").Err()
+	testt.NoError(t, err)
+
+	res, err := client.Do(ctx, "CMS.QUERY", "dest", "foo").Int64Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{7})
+
+	err = client.Do(ctx, "CMS.MERGE", "dest", "2", "test1", "test2").Err()
+	testt.NoError(t, err)
+
+	res, err = client.Do(ctx, "CMS.QUERY", "dest", "foo").Int64Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []int64{3})
+
+	err = client.Do(ctx, "CMS.MERGE", "dest", "2", "test1", "test2", "WEIGHTS", "1").Err()
+	testt.MustEqual(t, err.Error(), "ERR wrong number of arguments for 'CMS.MERGE' command")
+}
+
+func TestTOPKADD(t *testing.T) {
+	/*
+		redis> TOPK.RESERVE topk 50 2000 7 0.925
+		OK
+		redis> TOPK.ADD topk foo bar 42
+		1) (nil)
+		2) (nil)
+		3) (nil)
+		redis> TOPK.INCRBY topk foo 3 42 2
+		1) (nil)
+		2) (nil)
+		redis> TOPK.QUERY topk 42 nonexist
+		1) (integer) 1
+		2) (integer) 0
+		redis> TOPK.LIST topk
+		1) "foo"
+		2) "42"
+		3) "bar"
+		redis> TOPK.LIST topk WITHCOUNT
+		1) "foo"
+		2) (integer) 4
+		3) "42"
+		4) (integer) 3
+		5) "bar"
+		6) (integer) 1
+		redis> TOPK.INFO topk
+		1) k
+		2) (integer) 50
+		3) width
+		4) (integer) 2000
+		5) depth
+		6) (integer) 7
+		7) decay
+		8) "0.92500000000000004"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Do(ctx, "TOPK.RESERVE", "topk", "50", "2000", "7", "0.925").Err()
+	testt.NoError(t, err)
+
+	res, err := client.Do(ctx, "TOPK.ADD", "topk", "foo", "bar", "42").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{nil, nil, nil})
+
+	res, err = client.Do(ctx, "TOPK.INCRBY", "topk", "foo", "3", "42", "2").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{nil, nil})
+
+	ok, err := client.Do(ctx, "TOPK.QUERY", "topk", "42", "nonexist").Int64Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, []int64{1, 0})
+
+	list, err := client.Do(ctx, "TOPK.LIST", "topk").StringSlice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, list, []string{"foo", "42", "bar"})
+
+	res, err = client.Do(ctx, "TOPK.LIST", "topk", "WITHCOUNT").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{"foo", int64(4), "42", int64(3), "bar", int64(1)})
+
+	res, err = client.Do(ctx, "TOPK.INFO", "topk").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{"k", int64(50), "width", int64(2000), "depth", int64(7), "decay", "0.92500000000000004"})
+
+	typ, err := client.Type(ctx, "topk").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "TopK-TYPE")
+
+	cases := []struct {
+		args []any
+		err  string
+	}{
+		{[]any{"TOPK.RESERVE", "topk", "50"}, "TopK: key already exists"},
+		{[]any{"TOPK.ADD", "nokey", "foo"}, "TopK: key does not exist"},
+		{[]any{"TOPK.RESERVE", "x", "0"}, "TopK: invalid k"},
+		{[]any{"TOPK.RESERVE", "x", "10", "0", "7", "0.9"}, "TopK: invalid width"},
+		{[]any{"TOPK.RESERVE", "x", "10", "8", "0", "0.9"}, "TopK: invalid depth"},
+		{[]any{"TOPK.RESERVE", "x", "10", "8", "7", "1.5"}, "TopK: invalid decay value. must be '<= 1' & '> 0'"},
+		{[]any{"TOPK.RESERVE", "x", "10", "8"}, "ERR wrong number of arguments for 'TOPK.RESERVE' command"},
+		{[]any{"TOPK.INCRBY", "topk", "foo", "0"}, "TopK: increment must be an integer greater or equal to 1 and less than or equal to 100000"},
+	}
+	for _, tc := range cases {
+		err := client.Do(ctx, tc.args...).Err()
+		testt.MustEqual(t, err.Error(), tc.err)
+	}
+}
+
+func TestTOPKExpelled(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Do(ctx, "TOPK.RESERVE", "topk", "2", "2000", "7", "0.9").Err()
+	testt.NoError(t, err)
+
+	res, err := client.Do(ctx, "TOPK.INCRBY", "topk", "a", "3", "b", "2", "c", "1").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{nil, nil, nil})
+
+	// counts equal to the least one in the top take its place.
+	res, err = client.Do(ctx, "TOPK.ADD", "topk", "c", "c", "c").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{"b", nil, nil})
+
+	list, err := client.Do(ctx, "TOPK.LIST", "topk").StringSlice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, list, []string{"c", "a"})
+}
@@ -0,0 +1,1042 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/tidwall/redcon"
+)
+
+// Streams operations https://redis.io/commands/?group=stream
+
+func (s *Server) handleXACK(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for 'XACK' command")
+		return
+	}
+
+	ids, err := parseStreamIDs(cmd.Args[3:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	n, err := s.db.XACK(cmd.Args[1], cmd.Args[2], ids...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleXADD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 5 {
+		conn.WriteError("ERR wrong number of arguments for 'XADD' command")
+		return
+	}
+
+	var opts core.XAddOptions
+	i := 2
+loop:
+	for ; i < len(cmd.Args); i++ {
+		switch strings.ToUpper(string(cmd.Args[i])) {
+		case "NOMKSTREAM":
+			opts.NoMkStream = true
+		case "MAXLEN", "MINID":
+			trim, n, err := parseXTrim(cmd.Args[i:])
+			if err != nil {
+				writeError(conn, err)
+				return
+			}
+			opts.Trim = trim
+			i += n - 1
+		default:
+			break loop
+		}
+	}
+
+	fieldvals := cmd.Args[min(i+1, len(cmd.Args)):]
+	if len(fieldvals) == 0 || len(fieldvals)%2 != 0 {
+		conn.WriteError("ERR wrong number of arguments for 'XADD' command")
+		return
+	}
+
+	id := string(cmd.Args[i])
+	switch ms, hasAutoSeq := strings.CutSuffix(id, "-*"); {
+	case id == "*":
+		opts.AutoID = true
+	case hasAutoSeq:
+		var err error
+		opts.ID, err = core.ParseStreamID(ms, 0)
+		if err != nil || strings.Contains(ms, "-") {
+			writeError(conn, core.ErrStreamIDInvalid)
+			return
+		}
+		opts.AutoSeq = true
+	default:
+		var err error
+		opts.ID, err = core.ParseStreamID(id, 0)
+		if err != nil {
+			writeError(conn, err)
+			return
+		}
+	}
+
+	res, ok, err := s.db.XADD(cmd.Args[1], opts, fieldvals...)
+	switch {
+	case err != nil:
+		writeError(conn, err)
+	case !ok:
+		conn.WriteNull()
+	default:
+		s.waiters.signal(cmd.Args[1])
+		conn.WriteBulkString(res.String())
+	}
+}
+
+func (s *Server) handleXAUTOCLAIM(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 6 {
+		conn.WriteError("ERR wrong number of arguments for 'XAUTOCLAIM' command")
+		return
+	}
+
+	minIdle, err := strconv.ParseInt(string(cmd.Args[4]), 10, 64)
+	if err != nil {
+		conn.WriteError("ERR Invalid min-idle-time argument for XAUTOCLAIM")
+		return
+	}
+	start, err := parseStreamBound(cmd.Args[5], false)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	count := int64(100)
+	justID := false
+	for i := 6; i < len(cmd.Args); i++ {
+		switch strings.ToUpper(string(cmd.Args[i])) {
+		case "COUNT":
+			if i+1 == len(cmd.Args) {
+				writeError(conn, core.ErrSyntax)
+				return
+			}
+			count, err = strconv.ParseInt(string(cmd.Args[i+1]), 10, 64)
+			if err != nil {
+				writeError(conn, core.ErrNotIntOrOutOfRange)
+				return
+			}
+			if count < 1 || count > math.MaxInt32 {
+				conn.WriteError("ERR COUNT must be > 0")
+				return
+			}
+			i++
+		case "JUSTID":
+			justID = true
+		default:
+			writeError(conn, core.ErrSyntax)
+			return
+		}
+	}
+
+	key, group := cmd.Args[1], cmd.Args[2]
+	next, claimed, deleted, err := s.db.XAUTOCLAIM(key, group, cmd.Args[3], max(minIdle, 0), start, int(count), justID)
+	if err != nil {
+		writeNoGroupError(conn, err, key, group)
+		return
+	}
+
+	conn.WriteArray(3)
+	conn.WriteBulkString(next.String())
+	if justID {
+		writeStreamIDs(conn, claimed)
+	} else {
+		writeStreamEntries(conn, claimed)
+	}
+	conn.WriteArray(len(deleted))
+	for _, id := range deleted {
+		conn.WriteBulkString(id.String())
+	}
+}
+
+func (s *Server) handleXCLAIM(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 6 {
+		conn.WriteError("ERR wrong number of arguments for 'XCLAIM' command")
+		return
+	}
+
+	minIdle, err := strconv.ParseInt(string(cmd.Args[4]), 10, 64)
+	if err != nil {
+		conn.WriteError("ERR Invalid min-idle-time argument for XCLAIM")
+		return
+	}
+
+	// IDs are followed by options, the first argument that is not an ID starts them.
+	i := 5
+	ids := []core.StreamID{}
+	for ; i < len(cmd.Args); i++ {
+		id, err := core.ParseStreamID(string(cmd.Args[i]), 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+
+	opts := core.XClaimOptions{RetryCount: -1}
+	for ; i < len(cmd.Args); i++ {
+		opt := strings.ToUpper(string(cmd.Args[i]))
+		switch opt {
+		case "FORCE":
+			opts.Force = true
+			continue
+		case "JUSTID":
+			opts.JustID = true
+			continue
+		case "IDLE", "TIME", "RETRYCOUNT", "LASTID":
+			if i+1 == len(cmd.Args) {
+				writeError(conn, core.ErrSyntax)
+				return
+			}
+		default:
+			conn.WriteError("ERR Unrecognized XCLAIM option '" + string(cmd.Args[i]) + "'")
+			return
+		}
+
+		i++
+		if opt == "LASTID" {
+			opts.LastID, err = core.ParseStreamID(string(cmd.Args[i]), 0)
+			if err != nil {
+				writeError(conn, err)
+				return
+			}
+			continue
+		}
+		n, err := strconv.ParseInt(string(cmd.Args[i]), 10, 64)
+		if err != nil {
+			writeError(conn, core.ErrNotIntOrOutOfRange)
+			return
+		}
+		switch opt {
+		case "IDLE":
+			opts.DeliveryTime = core.NowMs() - max(n, 0)
+		case "TIME":
+			opts.DeliveryTime = n
+		case "RETRYCOUNT":
+			opts.RetryCount = max(n, 0)
+		}
+	}
+
+	key, group := cmd.Args[1], cmd.Args[2]
+	res, err := s.db.XCLAIM(key, group, cmd.Args[3], max(minIdle, 0), ids, opts)
+	if err != nil {
+		writeNoGroupError(conn, err, key, group)
+		return
+	}
+	if opts.JustID {
+		writeStreamIDs(conn, res)
+		return
+	}
+	writeStreamEntries(conn, res)
+}
+
+func (s *Server) handleXDEL(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'XDEL' command")
+		return
+	}
+
+	ids, err := parseStreamIDs(cmd.Args[2:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	n, err := s.db.XDEL(cmd.Args[1], ids...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleXGROUP(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'XGROUP' command")
+		return
+	}
+
+	sub := strings.ToUpper(string(cmd.Args[1]))
+	arity := map[string]int{"CREATE": -5, "SETID": -5, "CREATECONSUMER": 5, "DELCONSUMER": 5, "DESTROY": 4}[sub]
+	switch {
+	case arity == 0:
+		conn.WriteError("ERR unknown subcommand '" + string(cmd.Args[1]) + "'. Try XGROUP HELP.")
+		return
+	case arity > 0 && len(cmd.Args) != arity, arity < 0 && len(cmd.Args) < -arity:
+		conn.WriteError("ERR wrong number of arguments for 'xgroup|" + strings.ToLower(sub) + "' command")
+		return
+	}
+
+	key, group := cmd.Args[2], cmd.Args[3]
+	var err error
+	switch sub {
+	case "CREATE", "SETID":
+		var opts core.XGroupIDOptions
+		var mkStream bool
+		opts, mkStream, err = parseXGroupID(cmd.Args[4:], sub == "CREATE")
+		if err != nil {
+			writeError(conn, err)
+			return
+		}
+		if sub == "CREATE" {
+			err = s.db.XGROUPCREATE(key, group, opts, mkStream)
+		} else {
+			err = s.db.XGROUPSETID(key, group, opts)
+		}
+		if err == nil {
+			conn.WriteString("OK")
+		}
+	case "CREATECONSUMER":
+		var ok bool
+		ok, err = s.db.XGROUPCREATECONSUMER(key, group, cmd.Args[4])
+		if err == nil {
+			writeBool(conn, ok)
+		}
+	case "DELCONSUMER":
+		var n int
+		n, err = s.db.XGROUPDELCONSUMER(key, group, cmd.Args[4])
+		if err == nil {
+			conn.WriteInt(n)
+		}
+	case "DESTROY":
+		var ok bool
+		ok, err = s.db.XGROUPDESTROY(key, group)
+		if err == nil {
+			writeBool(conn, ok)
+		}
+	}
+
+	switch {
+	case errors.Is(err, core.ErrKeyNotFound):
+		conn.WriteError("ERR The XGROUP subcommand requires the key to exist. " +
+			"Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+	case errors.Is(err, core.ErrNoGroup):
+		writeNoConsumerGroupError(conn, key, group)
+	case err != nil:
+		writeError(conn, err)
+	}
+}
+
+func (s *Server) handleXINFO(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'XINFO' command")
+		return
+	}
+
+	sub := strings.ToUpper(string(cmd.Args[1]))
+	arity := map[string]int{"STREAM": 3, "GROUPS": 3, "CONSUMERS": 4}[sub]
+	switch {
+	case arity == 0:
+		conn.WriteError("ERR unknown subcommand '" + string(cmd.Args[1]) + "'. Try XINFO HELP.")
+		return
+	case len(cmd.Args) != arity:
+		// FULL form of XINFO STREAM is not supported.
+		conn.WriteError("ERR wrong number of arguments for 'xinfo|" + strings.ToLower(sub) + "' command")
+		return
+	}
+
+	key := cmd.Args[2]
+	var err error
+	switch sub {
+	case "STREAM":
+		var info core.StreamInfo
+		info, err = s.db.XINFOSTREAM(key)
+		if err == nil {
+			writeStreamInfo(conn, info)
+		}
+	case "GROUPS":
+		var groups []core.StreamGroupInfo
+		groups, err = s.db.XINFOGROUPS(key)
+		if err == nil {
+			writeStreamGroupsInfo(conn, groups)
+		}
+	case "CONSUMERS":
+		var consumers []core.StreamConsumerInfo
+		consumers, err = s.db.XINFOCONSUMERS(key, cmd.Args[3])
+		if err == nil {
+			writeStreamConsumersInfo(conn, consumers)
+		}
+	}
+
+	switch {
+	case errors.Is(err, core.ErrKeyNotFound):
+		conn.WriteError("ERR no such key")
+	case errors.Is(err, core.ErrNoGroup):
+		writeNoConsumerGroupError(conn, key, cmd.Args[3])
+	case err != nil:
+		writeError(conn, err)
+	}
+}
+
+func (s *Server) handleXLEN(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 2 {
+		conn.WriteError("ERR wrong number of arguments for 'XLEN' command")
+		return
+	}
+
+	n, err := s.db.XLEN(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleXPENDING(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 && (len(cmd.Args) < 6 || len(cmd.Args) > 9) {
+		conn.WriteError("ERR wrong number of arguments for 'XPENDING' command")
+		return
+	}
+
+	key, group := cmd.Args[1], cmd.Args[2]
+	if len(cmd.Args) == 3 {
+		res, err := s.db.XPENDING(key, group)
+		if err != nil {
+			writeNoGroupError(conn, err, key, group)
+			return
+		}
+
+		conn.WriteArray(4)
+		conn.WriteInt(res.Count)
+		if res.Count == 0 {
+			conn.WriteNull()
+			conn.WriteNull()
+			conn.WriteNull()
+			return
+		}
+		conn.WriteBulkString(res.First.String())
+		conn.WriteBulkString(res.Last.String())
+		conn.WriteArray(len(res.Consumers))
+		for _, c := range res.Consumers {
+			conn.WriteArray(2)
+			conn.WriteBulk(c.Name)
+			conn.WriteBulkString(strconv.Itoa(c.Count))
+		}
+		return
+	}
+
+	opts, err := parseXPending(cmd.Args[3:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	res, err := s.db.XPENDINGRANGE(key, group, opts)
+	if err != nil {
+		writeNoGroupError(conn, err, key, group)
+		return
+	}
+
+	conn.WriteArray(len(res))
+	for _, p := range res {
+		conn.WriteArray(4)
+		conn.WriteBulkString(p.ID.String())
+		conn.WriteBulk(p.Consumer)
+		conn.WriteInt64(p.Idle)
+		conn.WriteInt64(p.Deliveries)
+	}
+}
+
+func (s *Server) handleXRANGE(conn redcon.Conn, cmd redcon.Command) {
+	s.xrangeGeneric(conn, cmd, "XRANGE", false)
+}
+
+func (s *Server) handleXREAD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for 'XREAD' command")
+		return
+	}
+	cmd = cloneCommand(cmd)
+
+	args, err := parseXRead(cmd.Args[1:], false)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	ids := make([]core.StreamID, len(args.keys))
+	for j, arg := range args.ids {
+		if string(arg) != "$" {
+			id, err := core.ParseStreamID(string(arg), 0)
+			if err != nil {
+				writeError(conn, err)
+				return
+			}
+			ids[j] = id
+			continue
+		}
+
+		// new entries always get IDs greater than the last existing one.
+		last, err := s.db.XREVRANGE(args.keys[j], core.MaxStreamID, core.StreamID{}, 1)
+		if err != nil {
+			writeError(conn, err)
+			return
+		}
+		if len(last) > 0 {
+			ids[j] = last[0].ID
+		}
+	}
+
+	try := func(conn redcon.Conn) bool {
+		res, err := s.db.XREAD(args.keys, ids, args.count)
+		switch {
+		case err != nil:
+			writeError(conn, err)
+		case len(res) == 0:
+			return false
+		default:
+			writeStreams(conn, res)
+		}
+		return true
+	}
+
+	if !args.block {
+		if !try(conn) {
+			conn.WriteNull()
+		}
+		return
+	}
+	s.block(conn, args.keys, args.deadline, try)
+}
+
+func (s *Server) handleXREADGROUP(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 7 {
+		conn.WriteError("ERR wrong number of arguments for 'XREADGROUP' command")
+		return
+	}
+	cmd = cloneCommand(cmd)
+
+	args, err := parseXRead(cmd.Args[1:], true)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	// only new entries are waited for, history of the consumer is always served right away.
+	ids := make([]*core.StreamID, len(args.keys))
+	onlyNew := true
+	for j, arg := range args.ids {
+		switch string(arg) {
+		case ">":
+			continue
+		case "$":
+			conn.WriteError("ERR The $ ID is meaningless in the context of XREADGROUP: you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. The $ ID would just return an empty result set.")
+			return
+		}
+		id, err := core.ParseStreamID(string(arg), 0)
+		if err != nil {
+			writeError(conn, err)
+			return
+		}
+		ids[j] = &id
+		onlyNew = false
+	}
+
+	try := func(conn redcon.Conn) bool {
+		res, err := s.db.XREADGROUP(args.group, args.consumer, args.keys, ids, args.count, args.noAck)
+		switch {
+		case errors.Is(err, core.ErrKeyNotFound) || errors.Is(err, core.ErrNoGroup):
+			conn.WriteError("NOGROUP No such key '" + string(s.missingGroupKey(args.keys, args.group)) +
+				"' or consumer group '" + string(args.group) + "' in XREADGROUP with GROUP option")
+		case err != nil:
+			writeError(conn, err)
+		case len(res) == 0:
+			return false
+		default:
+			writeStreams(conn, res)
+		}
+		return true
+	}
+
+	if !args.block || !onlyNew {
+		if !try(conn) {
+			conn.WriteNull()
+		}
+		return
+	}
+	s.block(conn, args.keys, args.deadline, try)
+}
+
+func (s *Server) handleXREVRANGE(conn redcon.Conn, cmd redcon.Command) {
+	s.xrangeGeneric(conn, cmd, "XREVRANGE", true)
+}
+
+func (s *Server) handleXTRIM(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for 'XTRIM' command")
+		return
+	}
+
+	opts, n, err := parseXTrim(cmd.Args[2:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	if n != len(cmd.Args)-2 {
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	res, err := s.db.XTRIM(cmd.Args[1], opts)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(res)
+}
+
+// xrangeGeneric handles XRANGE and XREVRANGE, the latter takes end before start.
+func (s *Server) xrangeGeneric(conn redcon.Conn, cmd redcon.Command, name string, rev bool) {
+	if len(cmd.Args) != 4 && len(cmd.Args) != 6 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	startArg, endArg := cmd.Args[2], cmd.Args[3]
+	if rev {
+		startArg, endArg = endArg, startArg
+	}
+	start, err := parseStreamBound(startArg, false)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	end, err := parseStreamBound(endArg, true)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	count := int64(-1)
+	if len(cmd.Args) == 6 {
+		if !strings.EqualFold(string(cmd.Args[4]), "COUNT") {
+			writeError(conn, core.ErrSyntax)
+			return
+		}
+		count, err = strconv.ParseInt(string(cmd.Args[5]), 10, 64)
+		if err != nil {
+			writeError(conn, core.ErrNotIntOrOutOfRange)
+			return
+		}
+		if count <= 0 {
+			conn.WriteArray(0)
+			return
+		}
+	}
+
+	var res []core.StreamEntry
+	if rev {
+		res, err = s.db.XREVRANGE(cmd.Args[1], end, start, int(count))
+	} else {
+		res, err = s.db.XRANGE(cmd.Args[1], start, end, int(count))
+	}
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeStreamEntries(conn, res)
+}
+
+// missingGroupKey returns the first key without the group, it's used to report errors of multi-key commands.
+func (s *Server) missingGroupKey(keys [][]byte, group []byte) []byte {
+	for _, key := range keys {
+		if _, err := s.db.XPENDING(key, group); err != nil {
+			return key
+		}
+	}
+	return keys[0]
+}
+
+// xreadArgs are arguments of XREAD and XREADGROUP.
+type xreadArgs struct {
+	group    []byte
+	consumer []byte
+	count    int
+	block    bool
+	deadline time.Time
+	noAck    bool
+	keys     [][]byte
+	ids      [][]byte
+}
+
+// parseXRead parses arguments of XREAD and, if isGroup is set, XREADGROUP.
+func parseXRead(args [][]byte, isGroup bool) (xreadArgs, error) {
+	var res xreadArgs
+	i := 0
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+		if opt == "STREAMS" {
+			break
+		}
+		if opt == "NOACK" && isGroup {
+			res.noAck = true
+			continue
+		}
+		if i+1 == len(args) {
+			return res, core.ErrSyntax
+		}
+
+		var err error
+		switch opt {
+		case "COUNT":
+			count, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return res, core.ErrNotIntOrOutOfRange
+			}
+			res.count = int(max(count, 0))
+		case "BLOCK":
+			res.deadline, err = parseTimeoutMs(args[i+1])
+			if err != nil {
+				return res, err
+			}
+			res.block = true
+		case "GROUP":
+			if !isGroup {
+				return res, errors.New("The GROUP option is only supported by XREADGROUP. You called XREAD instead.")
+			}
+			if i+2 == len(args) {
+				return res, core.ErrSyntax
+			}
+			res.group, res.consumer = args[i+1], args[i+2]
+			i++
+		default:
+			return res, core.ErrSyntax
+		}
+		i++
+	}
+
+	streams := args[min(i+1, len(args)):]
+	if i == len(args) || len(streams) == 0 {
+		return res, core.ErrSyntax
+	}
+	if isGroup && res.group == nil {
+		return res, errors.New("Missing GROUP option for XREADGROUP")
+	}
+	if len(streams)%2 != 0 {
+		if isGroup {
+			return res, errors.New("Unbalanced 'xreadgroup' list of streams: for each stream key an ID or '>' must be specified.")
+		}
+		return res, errors.New("Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.")
+	}
+
+	res.keys, res.ids = streams[:len(streams)/2], streams[len(streams)/2:]
+	if !isGroup && slices.ContainsFunc(res.ids, func(id []byte) bool { return string(id) == ">" }) {
+		return res, errors.New("The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option.")
+	}
+	return res, nil
+}
+
+// parseXGroupID parses `<id | $> [MKSTREAM] [ENTRIESREAD entries-read]` of XGROUP CREATE and SETID,
+// MKSTREAM is allowed only if mkStream is set.
+func parseXGroupID(args [][]byte, allowMkStream bool) (core.XGroupIDOptions, bool, error) {
+	opts := core.XGroupIDOptions{EntriesRead: -1}
+	if string(args[0]) == "$" {
+		opts.LastID = true
+	} else {
+		id, err := core.ParseStreamID(string(args[0]), 0)
+		if err != nil {
+			return opts, false, err
+		}
+		opts.ID = id
+	}
+
+	mkStream := false
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "MKSTREAM":
+			if !allowMkStream {
+				return opts, false, core.ErrSyntax
+			}
+			mkStream = true
+		case "ENTRIESREAD":
+			if i+1 == len(args) {
+				return opts, false, core.ErrSyntax
+			}
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return opts, false, core.ErrNotIntOrOutOfRange
+			}
+			if n < -1 {
+				return opts, false, errors.New("value for ENTRIESREAD must be positive or -1")
+			}
+			opts.EntriesRead = n
+			i++
+		default:
+			return opts, false, core.ErrSyntax
+		}
+	}
+	return opts, mkStream, nil
+}
+
+// parseXPending parses `[IDLE min-idle-time] start end count [consumer]` of XPENDING.
+func parseXPending(args [][]byte) (core.XPendingOptions, error) {
+	var opts core.XPendingOptions
+	if strings.EqualFold(string(args[0]), "IDLE") {
+		n, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return opts, core.ErrNotIntOrOutOfRange
+		}
+		opts.MinIdle = n
+		args = args[2:]
+	}
+	if len(args) != 3 && len(args) != 4 {
+		return opts, core.ErrSyntax
+	}
+
+	var err error
+	opts.Start, err = parseStreamBound(args[0], false)
+	if err != nil {
+		return opts, err
+	}
+	opts.End, err = parseStreamBound(args[1], true)
+	if err != nil {
+		return opts, err
+	}
+	count, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return opts, core.ErrNotIntOrOutOfRange
+	}
+	opts.Count = int(max(count, 0))
+	if len(args) == 4 {
+		opts.Consumer = args[3]
+	}
+	return opts, nil
+}
+
+func parseStreamIDs(args [][]byte) ([]core.StreamID, error) {
+	res := make([]core.StreamID, 0, len(args))
+	for _, arg := range args {
+		id, err := core.ParseStreamID(string(arg), 0)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, id)
+	}
+	return res, nil
+}
+
+// parseStreamBound parses start or end of XRANGE: `-`, `+`, an ID or an ID prefixed with `(` for exclusive bound.
+// Missing sequence number selects the whole millisecond.
+func parseStreamBound(arg []byte, isEnd bool) (core.StreamID, error) {
+	switch string(arg) {
+	case "-":
+		return core.StreamID{}, nil
+	case "+":
+		return core.MaxStreamID, nil
+	}
+
+	missingSeq := uint64(0)
+	if isEnd {
+		missingSeq = core.MaxStreamID.Seq
+	}
+	str, exclusive := strings.CutPrefix(string(arg), "(")
+	id, err := core.ParseStreamID(str, missingSeq)
+	if err != nil || !exclusive {
+		return id, err
+	}
+
+	if isEnd {
+		prev, ok := id.Prev()
+		if !ok {
+			return core.StreamID{}, errors.New("invalid end ID for the interval")
+		}
+		return prev, nil
+	}
+	next, ok := id.Next()
+	if !ok {
+		return core.StreamID{}, errors.New("invalid start ID for the interval")
+	}
+	return next, nil
+}
+
+// parseXTrim parses `<MAXLEN | MINID> [= | ~] threshold [LIMIT count]`,
+// returns the options and the number of parsed arguments.
+func parseXTrim(args [][]byte) (core.XTrimOptions, int, error) {
+	var opts core.XTrimOptions
+	switch strings.ToUpper(string(args[0])) {
+	case "MAXLEN":
+		opts.Strategy = core.XTrimMaxLen
+	case "MINID":
+		opts.Strategy = core.XTrimMinID
+	default:
+		return opts, 0, core.ErrSyntax
+	}
+
+	i := 1
+	if i < len(args) && (string(args[i]) == "=" || string(args[i]) == "~") {
+		opts.Approx = string(args[i]) == "~"
+		i++
+	}
+	if i == len(args) {
+		return opts, 0, core.ErrSyntax
+	}
+
+	if opts.Strategy == core.XTrimMaxLen {
+		n, err := strconv.ParseInt(string(args[i]), 10, 64)
+		if err != nil {
+			return opts, 0, core.ErrNotIntOrOutOfRange
+		}
+		if n < 0 {
+			return opts, 0, errors.New("The MAXLEN argument must be >= 0.")
+		}
+		opts.MaxLen = int(n)
+	} else {
+		id, err := core.ParseStreamID(string(args[i]), 0)
+		if err != nil {
+			return opts, 0, err
+		}
+		opts.MinID = id
+	}
+	i++
+
+	if opts.Approx {
+		// like Redis, approximate trimming evicts at most 100 nodes by default.
+		opts.Limit = 100 * core.StreamNodeEntries
+	}
+	if i+1 < len(args) && strings.EqualFold(string(args[i]), "LIMIT") {
+		if !opts.Approx {
+			return opts, 0, errors.New("syntax error, LIMIT cannot be used without the special ~ option")
+		}
+		n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		if err != nil {
+			return opts, 0, core.ErrNotIntOrOutOfRange
+		}
+		if n < 0 {
+			return opts, 0, errors.New("The LIMIT argument must be >= 0.")
+		}
+		opts.Limit = int(n)
+		i += 2
+	}
+	return opts, i, nil
+}
+
+// writeNoGroupError is like writeError but reports a missing stream or group like Redis does.
+func writeNoGroupError(conn redcon.Conn, err error, key, group []byte) {
+	if errors.Is(err, core.ErrKeyNotFound) || errors.Is(err, core.ErrNoGroup) {
+		conn.WriteError("NOGROUP No such key '" + string(key) + "' or consumer group '" + string(group) + "'")
+		return
+	}
+	writeError(conn, err)
+}
+
+func writeNoConsumerGroupError(conn redcon.Conn, key, group []byte) {
+	conn.WriteError("NOGROUP No such consumer group '" + string(group) + "' for key name '" + string(key) + "'")
+}
+
+// writeStreamEntries writes entries, fields of deleted pending entries are nil.
+func writeStreamEntries(conn redcon.Conn, entries []core.StreamEntry) {
+	conn.WriteArray(len(entries))
+	for _, e := range entries {
+		writeStreamEntry(conn, e)
+	}
+}
+
+func writeStreamEntry(conn redcon.Conn, e core.StreamEntry) {
+	conn.WriteArray(2)
+	conn.WriteBulkString(e.ID.String())
+	if e.Fields == nil {
+		conn.WriteNull()
+		return
+	}
+	writeBulks(conn, e.Fields)
+}
+
+func writeStreamIDs(conn redcon.Conn, entries []core.StreamEntry) {
+	conn.WriteArray(len(entries))
+	for _, e := range entries {
+		conn.WriteBulkString(e.ID.String())
+	}
+}
+
+func writeStreams(conn redcon.Conn, streams []core.StreamEntries) {
+	conn.WriteArray(len(streams))
+	for _, st := range streams {
+		conn.WriteArray(2)
+		conn.WriteBulk(st.Key)
+		writeStreamEntries(conn, st.Entries)
+	}
+}
+
+func writeStreamInfo(conn redcon.Conn, info core.StreamInfo) {
+	conn.WriteArray(16)
+	conn.WriteBulkString("length")
+	conn.WriteInt(info.Len)
+	conn.WriteBulkString("last-generated-id")
+	conn.WriteBulkString(info.LastID.String())
+	conn.WriteBulkString("max-deleted-entry-id")
+	conn.WriteBulkString(info.MaxDeletedID.String())
+	conn.WriteBulkString("entries-added")
+	conn.WriteInt64(info.EntriesAdded)
+	conn.WriteBulkString("recorded-first-entry-id")
+	conn.WriteBulkString(info.FirstID.String())
+	conn.WriteBulkString("groups")
+	conn.WriteInt(info.Groups)
+	conn.WriteBulkString("first-entry")
+	writeStreamEntryOrNull(conn, info.FirstEntry)
+	conn.WriteBulkString("last-entry")
+	writeStreamEntryOrNull(conn, info.LastEntry)
+}
+
+func writeStreamEntryOrNull(conn redcon.Conn, e *core.StreamEntry) {
+	if e == nil {
+		conn.WriteNull()
+		return
+	}
+	writeStreamEntry(conn, *e)
+}
+
+func writeStreamGroupsInfo(conn redcon.Conn, groups []core.StreamGroupInfo) {
+	conn.WriteArray(len(groups))
+	for _, g := range groups {
+		conn.WriteArray(12)
+		conn.WriteBulkString("name")
+		conn.WriteBulk(g.Name)
+		conn.WriteBulkString("consumers")
+		conn.WriteInt(g.Consumers)
+		conn.WriteBulkString("pending")
+		conn.WriteInt(g.Pending)
+		conn.WriteBulkString("last-delivered-id")
+		conn.WriteBulkString(g.LastDeliveredID.String())
+		conn.WriteBulkString("entries-read")
+		writeIntOrNull(conn, g.EntriesRead)
+		conn.WriteBulkString("lag")
+		writeIntOrNull(conn, g.Lag)
+	}
+}
+
+func writeStreamConsumersInfo(conn redcon.Conn, consumers []core.StreamConsumerInfo) {
+	conn.WriteArray(len(consumers))
+	for _, c := range consumers {
+		conn.WriteArray(8)
+		conn.WriteBulkString("name")
+		conn.WriteBulk(c.Name)
+		conn.WriteBulkString("pending")
+		conn.WriteInt(c.Pending)
+		conn.WriteBulkString("idle")
+		conn.WriteInt64(c.Idle)
+		conn.WriteBulkString("inactive")
+		conn.WriteInt64(c.Inactive)
+	}
+}
+
+// writeIntOrNull writes n, or null if it's negative (unknown).
+func writeIntOrNull(conn redcon.Conn, n int64) {
+	if n < 0 {
+		conn.WriteNull()
+		return
+	}
+	conn.WriteInt64(n)
+}
@@ -0,0 +1,422 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cristalhq/testt"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestXADD(t *testing.T) {
+	/*
+		redis> XADD mystream 1526919030474-55 name Sara surname OConnor
+		"1526919030474-55"
+		redis> XADD mystream 1526919030474-* field1 value1 field2 value2
+		"1526919030474-56"
+		redis> XLEN mystream
+		(integer) 2
+		redis> XRANGE mystream - +
+		1) 1) "1526919030474-55"
+		   2) 1) "name"
+		      2) "Sara"
+		      3) "surname"
+		      4) "OConnor"
+		2) 1) "1526919030474-56"
+		   2) 1) "field1"
+		      2) "value1"
+		      3) "field2"
+		      4) "value2"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	id, err := client.XAdd(ctx, &redis.XAddArgs{
+		Stream: "mystream",
+		ID:     "1526919030474-55",
+		Values: []string{"name", "Sara", "surname", "OConnor"},
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, id, "1526919030474-55")
+
+	id, err = client.XAdd(ctx, &redis.XAddArgs{
+		Stream: "mystream",
+		ID:     "1526919030474-*",
+		Values: []string{"field1", "value1", "field2", "value2"},
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, id, "1526919030474-56")
+
+	n, err := client.XLen(ctx, "mystream").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	res, err := client.XRange(ctx, "mystream", "-", "+").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []redis.XMessage{
+		{ID: "1526919030474-55", Values: map[string]any{"name": "Sara", "surname": "OConnor"}},
+		{ID: "1526919030474-56", Values: map[string]any{"field1": "value1", "field2": "value2"}},
+	})
+
+	err = client.XAdd(ctx, &redis.XAddArgs{Stream: "other", NoMkStream: true, Values: []string{"a", "1"}}).Err()
+	testt.MustEqual(t, err, redis.Nil)
+
+	err = client.Do(ctx, "XADD", "mystream", "1-0", "a", "1").Err()
+	testt.MustEqual(t, err.Error(), "ERR The ID specified in XADD is equal or smaller than the target stream top item")
+
+	err = client.Do(ctx, "XADD", "other", "0-0", "a", "1").Err()
+	testt.MustEqual(t, err.Error(), "ERR The ID specified in XADD must be greater than 0-0")
+
+	err = client.Do(ctx, "XADD", "other", "abc", "a", "1").Err()
+	testt.MustEqual(t, err.Error(), "ERR Invalid stream ID specified as stream command argument")
+
+	err = client.Do(ctx, "XADD", "other", "*", "a").Err()
+	testt.MustEqual(t, err.Error(), "ERR wrong number of arguments for 'XADD' command")
+
+	err = client.Do(ctx, "XADD", "other", "MAXLEN", "-1", "*", "a", "1").Err()
+	testt.MustEqual(t, err.Error(), "ERR The MAXLEN argument must be >= 0.")
+
+	err = client.Do(ctx, "XADD", "other", "MAXLEN", "1", "LIMIT", "10", "*", "a", "1").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error, LIMIT cannot be used without the special ~ option")
+}
+
+func TestXRANGE(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	for _, id := range []string{"1-0", "1-1", "2-0", "3-0"} {
+		err := client.XAdd(ctx, &redis.XAddArgs{Stream: "mystream", ID: id, Values: []string{"id", id}}).Err()
+		testt.NoError(t, err)
+	}
+
+	ids := func(msgs []redis.XMessage) []string {
+		res := []string{}
+		for _, msg := range msgs {
+			res = append(res, msg.ID)
+		}
+		return res
+	}
+
+	res, err := client.XRange(ctx, "mystream", "1", "2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ids(res), []string{"1-0", "1-1", "2-0"})
+
+	res, err = client.XRange(ctx, "mystream", "(1-0", "(3-0").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ids(res), []string{"1-1", "2-0"})
+
+	res, err = client.XRangeN(ctx, "mystream", "-", "+", 2).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ids(res), []string{"1-0", "1-1"})
+
+	res, err = client.XRevRangeN(ctx, "mystream", "+", "-", 3).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ids(res), []string{"3-0", "2-0", "1-1"})
+
+	res, err = client.XRevRange(ctx, "mystream", "(2-0", "1").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ids(res), []string{"1-1", "1-0"})
+
+	res, err = client.XRangeN(ctx, "mystream", "-", "+", 0).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ids(res), []string{})
+
+	n, err := client.XDel(ctx, "mystream", "1-1", "5-0").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.XTrimMaxLen(ctx, "mystream", 1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	n, err = client.XTrimMinIDApprox(ctx, "mystream", "4-0", 0).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(0))
+
+	err = client.Do(ctx, "XRANGE", "mystream", "(18446744073709551615-18446744073709551615", "+").Err()
+	testt.MustEqual(t, err.Error(), "ERR invalid start ID for the interval")
+}
+
+func TestXREAD(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+	other := testClient(t, addr)
+
+	err := client.XAdd(ctx, &redis.XAddArgs{Stream: "mystream", ID: "1-0", Values: []string{"a", "1"}}).Err()
+	testt.NoError(t, err)
+
+	res, err := client.XRead(ctx, &redis.XReadArgs{Streams: []string{"mystream", "0"}, Block: -1}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []redis.XStream{
+		{Stream: "mystream", Messages: []redis.XMessage{{ID: "1-0", Values: map[string]any{"a": "1"}}}},
+	})
+
+	err = client.XRead(ctx, &redis.XReadArgs{Streams: []string{"mystream", "$"}, Block: -1}).Err()
+	testt.MustEqual(t, err, redis.Nil)
+
+	start := time.Now()
+	err = client.XRead(ctx, &redis.XReadArgs{Streams: []string{"mystream", "$"}, Block: 100 * time.Millisecond}).Err()
+	testt.MustEqual(t, err, redis.Nil)
+	if time.Since(start) < 100*time.Millisecond {
+		t.Fatal("returned before timeout")
+	}
+
+	// every reader blocked on a stream gets a new entry.
+	read := make(chan []redis.XStream, 2)
+	for _, c := range []*redis.Client{client, testClient(t, addr)} {
+		go func(c *redis.Client) {
+			res, err := c.XRead(ctx, &redis.XReadArgs{Streams: []string{"otherstream", "mystream", "$", "$"}, Block: 0}).Result()
+			testt.NoError(t, err)
+			read <- res
+		}(c)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	err = other.XAdd(ctx, &redis.XAddArgs{Stream: "mystream", ID: "2-0", Values: []string{"b", "2"}}).Err()
+	testt.NoError(t, err)
+
+	want := []redis.XStream{
+		{Stream: "mystream", Messages: []redis.XMessage{{ID: "2-0", Values: map[string]any{"b": "2"}}}},
+	}
+	testt.MustEqual(t, <-read, want)
+	testt.MustEqual(t, <-read, want)
+
+	err = client.Do(ctx, "XREAD", "STREAMS", "mystream", "otherstream", "0").Err()
+	testt.MustEqual(t, err.Error(), "ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.")
+
+	err = client.Do(ctx, "XREAD", "BLOCK", "-1", "STREAMS", "mystream", "0").Err()
+	testt.MustEqual(t, err.Error(), "ERR timeout is negative")
+}
+
+func TestXREADGROUP(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+	other := testClient(t, addr)
+
+	err := client.XGroupCreate(ctx, "mystream", "mygroup", "$").Err()
+	testt.MustEqual(t, err.Error(), "ERR The XGROUP subcommand requires the key to exist. "+
+		"Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+
+	err = client.XGroupCreateMkStream(ctx, "mystream", "mygroup", "$").Err()
+	testt.NoError(t, err)
+
+	err = client.XGroupCreateMkStream(ctx, "mystream", "mygroup", "$").Err()
+	testt.MustEqual(t, err.Error(), "BUSYGROUP Consumer Group name already exists")
+
+	err = client.XAdd(ctx, &redis.XAddArgs{Stream: "mystream", ID: "1-0", Values: []string{"a", "1"}}).Err()
+	testt.NoError(t, err)
+
+	res, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "mygroup",
+		Consumer: "Alice",
+		Streams:  []string{"mystream", ">"},
+		Block:    -1,
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []redis.XStream{
+		{Stream: "mystream", Messages: []redis.XMessage{{ID: "1-0", Values: map[string]any{"a": "1"}}}},
+	})
+
+	err = client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "mygroup",
+		Consumer: "Alice",
+		Streams:  []string{"mystream", ">"},
+		Block:    -1,
+	}).Err()
+	testt.MustEqual(t, err, redis.Nil)
+
+	// a blocked consumer gets a new entry.
+	read := make(chan []redis.XStream, 1)
+	go func() {
+		res, err := other.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    "mygroup",
+			Consumer: "Bob",
+			Streams:  []string{"mystream", ">"},
+			Block:    0,
+		}).Result()
+		testt.NoError(t, err)
+		read <- res
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	err = client.XAdd(ctx, &redis.XAddArgs{Stream: "mystream", ID: "2-0", Values: []string{"b", "2"}}).Err()
+	testt.NoError(t, err)
+	testt.MustEqual(t, <-read, []redis.XStream{
+		{Stream: "mystream", Messages: []redis.XMessage{{ID: "2-0", Values: map[string]any{"b": "2"}}}},
+	})
+
+	pending, err := client.XPending(ctx, "mystream", "mygroup").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, pending, &redis.XPending{
+		Count:     2,
+		Lower:     "1-0",
+		Higher:    "2-0",
+		Consumers: map[string]int64{"Alice": 1, "Bob": 1},
+	})
+
+	n, err := client.XAck(ctx, "mystream", "mygroup", "1-0", "3-0").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	// deleted pending entries are returned with nil fields.
+	err = client.XDel(ctx, "mystream", "2-0").Err()
+	testt.NoError(t, err)
+	history, err := client.Do(ctx, "XREADGROUP", "GROUP", "mygroup", "Bob", "STREAMS", "mystream", "0").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, history, []any{[]any{"mystream", []any{[]any{"2-0", nil}}}})
+
+	err = client.Do(ctx, "XREADGROUP", "GROUP", "other", "Bob", "STREAMS", "mystream", ">").Err()
+	testt.MustEqual(t, err.Error(), "NOGROUP No such key 'mystream' or consumer group 'other' in XREADGROUP with GROUP option")
+
+	err = client.Do(ctx, "XREADGROUP", "GROUP", "mygroup", "Bob", "STREAMS", "mystream", "$").Err()
+	testt.MustEqual(t, err.Error(), "ERR The $ ID is meaningless in the context of XREADGROUP: you want to read the history "+
+		"of this consumer by specifying a proper ID, or use the > ID to get new messages. The $ ID would just return an empty result set.")
+
+	err = client.Do(ctx, "XREAD", "STREAMS", "mystream", ">").Err()
+	testt.MustEqual(t, err.Error(), "ERR The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option.")
+
+	err = client.Do(ctx, "XGROUP", "SETID", "mystream", "mygroup", "0", "ENTRIESREAD", "-2").Err()
+	testt.MustEqual(t, err.Error(), "ERR value for ENTRIESREAD must be positive or -1")
+
+	err = client.Do(ctx, "XGROUP", "CREATECONSUMER", "mystream", "other", "Bob").Err()
+	testt.MustEqual(t, err.Error(), "NOGROUP No such consumer group 'other' for key name 'mystream'")
+
+	err = client.Do(ctx, "XGROUP", "FOO").Err()
+	testt.MustEqual(t, err.Error(), "ERR unknown subcommand 'FOO'. Try XGROUP HELP.")
+}
+
+func TestXCLAIM(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	for _, id := range []string{"1-0", "2-0", "3-0"} {
+		err := client.XAdd(ctx, &redis.XAddArgs{Stream: "mystream", ID: id, Values: []string{"id", id}}).Err()
+		testt.NoError(t, err)
+	}
+	err := client.XGroupCreate(ctx, "mystream", "mygroup", "0").Err()
+	testt.NoError(t, err)
+	err = client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "mygroup",
+		Consumer: "Alice",
+		Streams:  []string{"mystream", ">"},
+		Block:    -1,
+	}).Err()
+	testt.NoError(t, err)
+
+	msgs, err := client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   "mystream",
+		Group:    "mygroup",
+		Consumer: "Bob",
+		Messages: []string{"1-0"},
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, msgs, []redis.XMessage{{ID: "1-0", Values: map[string]any{"id": "1-0"}}})
+
+	ids, err := client.XClaimJustID(ctx, &redis.XClaimArgs{
+		Stream:   "mystream",
+		Group:    "mygroup",
+		Consumer: "Bob",
+		MinIdle:  time.Hour,
+		Messages: []string{"2-0"},
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ids, []string{})
+
+	ext, err := client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   "mystream",
+		Group:    "mygroup",
+		Start:    "-",
+		End:      "+",
+		Count:    10,
+		Consumer: "Bob",
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(ext), 1)
+	testt.MustEqual(t, ext[0].ID, "1-0")
+	testt.MustEqual(t, ext[0].RetryCount, int64(2))
+
+	err = client.XDel(ctx, "mystream", "3-0").Err()
+	testt.NoError(t, err)
+	ids, start, err := client.XAutoClaimJustID(ctx, &redis.XAutoClaimArgs{
+		Stream:   "mystream",
+		Group:    "mygroup",
+		Start:    "0",
+		Consumer: "Bob",
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ids, []string{"1-0", "2-0"})
+	testt.MustEqual(t, start, "0-0")
+
+	res, err := client.Do(ctx, "XAUTOCLAIM", "mystream", "mygroup", "Bob", "0", "0").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res[2], []any{})
+
+	err = client.Do(ctx, "XAUTOCLAIM", "mystream", "mygroup", "Bob", "0", "0", "COUNT", "0").Err()
+	testt.MustEqual(t, err.Error(), "ERR COUNT must be > 0")
+
+	err = client.Do(ctx, "XCLAIM", "mystream", "mygroup", "Bob", "0", "1-0", "FOO").Err()
+	testt.MustEqual(t, err.Error(), "ERR Unrecognized XCLAIM option 'FOO'")
+
+	err = client.XPending(ctx, "mystream", "other").Err()
+	testt.MustEqual(t, err.Error(), "NOGROUP No such key 'mystream' or consumer group 'other'")
+}
+
+func TestXINFO(t *testing.T) {
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.XInfoStream(ctx, "mystream").Err()
+	testt.MustEqual(t, err.Error(), "ERR no such key")
+
+	for _, id := range []string{"1-0", "2-0"} {
+		err := client.XAdd(ctx, &redis.XAddArgs{Stream: "mystream", ID: id, Values: []string{"id", id}}).Err()
+		testt.NoError(t, err)
+	}
+	err = client.XGroupCreate(ctx, "mystream", "mygroup", "0").Err()
+	testt.NoError(t, err)
+	err = client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "mygroup",
+		Consumer: "Alice",
+		Streams:  []string{"mystream", ">"},
+		Count:    1,
+		Block:    -1,
+	}).Err()
+	testt.NoError(t, err)
+
+	info, err := client.XInfoStream(ctx, "mystream").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, info, &redis.XInfoStream{
+		Length:               2,
+		Groups:               1,
+		LastGeneratedID:      "2-0",
+		MaxDeletedEntryID:    "0-0",
+		EntriesAdded:         2,
+		FirstEntry:           redis.XMessage{ID: "1-0", Values: map[string]any{"id": "1-0"}},
+		LastEntry:            redis.XMessage{ID: "2-0", Values: map[string]any{"id": "2-0"}},
+		RecordedFirstEntryID: "1-0",
+	})
+
+	groups, err := client.XInfoGroups(ctx, "mystream").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, groups, []redis.XInfoGroup{
+		{Name: "mygroup", Consumers: 1, Pending: 1, LastDeliveredID: "1-0", EntriesRead: 1, Lag: 1},
+	})
+
+	consumers, err := client.XInfoConsumers(ctx, "mystream", "mygroup").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(consumers), 1)
+	testt.MustEqual(t, consumers[0].Name, "Alice")
+	testt.MustEqual(t, consumers[0].Pending, int64(1))
+
+	err = client.XInfoConsumers(ctx, "mystream", "other").Err()
+	testt.MustEqual(t, err.Error(), "NOGROUP No such consumer group 'other' for key name 'mystream'")
+}
@@ -1,7 +1,12 @@
 package server
 
 import (
+	"errors"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cristaloleg/didis/internal/core"
 
 	"github.com/tidwall/redcon"
 )
@@ -16,7 +21,7 @@ func (s *Server) handleAPPEND(conn redcon.Conn, cmd redcon.Command) {
 
 	size, err := s.db.APPEND(cmd.Args[1], cmd.Args[2])
 	if err != nil {
-		conn.WriteError(err.Error())
+		writeError(conn, err)
 		return
 	}
 
@@ -31,7 +36,7 @@ func (s *Server) handleDECR(conn redcon.Conn, cmd redcon.Command) {
 
 	val, err := s.db.DECR(cmd.Args[1])
 	if err != nil {
-		conn.WriteError(err.Error())
+		writeError(conn, err)
 		return
 	}
 	conn.WriteInt64(val)
@@ -45,13 +50,13 @@ func (s *Server) handleDECRBY(conn redcon.Conn, cmd redcon.Command) {
 
 	by, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
 	if err != nil {
-		conn.WriteError(err.Error())
+		writeError(conn, core.ErrNotIntOrOutOfRange)
 		return
 	}
 
 	val, err := s.db.DECRBY(cmd.Args[1], int(by))
 	if err != nil {
-		conn.WriteError(err.Error())
+		writeError(conn, err)
 		return
 	}
 	conn.WriteInt64(val)
@@ -64,11 +69,7 @@ func (s *Server) handleGET(conn redcon.Conn, cmd redcon.Command) {
 	}
 
 	val, err := s.db.GET(cmd.Args[1])
-	if err != nil {
-		conn.WriteError(err.Error())
-		return
-	}
-	conn.WriteString(string(val))
+	writeBulkOrNil(conn, val, err)
 }
 
 func (s *Server) handleGETDEL(conn redcon.Conn, cmd redcon.Command) {
@@ -78,11 +79,23 @@ func (s *Server) handleGETDEL(conn redcon.Conn, cmd redcon.Command) {
 	}
 
 	val, err := s.db.GETDEL(cmd.Args[1])
+	writeBulkOrNil(conn, val, err)
+}
+
+func (s *Server) handleGETEX(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'GETEX' command")
+		return
+	}
+
+	opts, err := parseGetExOptions(cmd.Args[2:])
 	if err != nil {
-		conn.WriteError(err.Error())
+		writeError(conn, err)
 		return
 	}
-	conn.WriteString(string(val))
+
+	val, err := s.db.GETEX(cmd.Args[1], opts)
+	writeBulkOrNil(conn, val, err)
 }
 
 func (s *Server) handleGETRANGE(conn redcon.Conn, cmd redcon.Command) {
@@ -93,21 +106,21 @@ func (s *Server) handleGETRANGE(conn redcon.Conn, cmd redcon.Command) {
 
 	start, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
 	if err != nil {
-		conn.WriteError(err.Error())
+		writeError(conn, core.ErrNotIntOrOutOfRange)
 		return
 	}
 	end, err := strconv.ParseInt(string(cmd.Args[3]), 10, 64)
 	if err != nil {
-		conn.WriteError(err.Error())
+		writeError(conn, core.ErrNotIntOrOutOfRange)
 		return
 	}
 
 	val, err := s.db.GETRANGE(cmd.Args[1], int(start), int(end))
-	if err != nil {
-		conn.WriteError(err.Error())
+	if err != nil && !errors.Is(err, core.ErrKeyNotFound) {
+		writeError(conn, err)
 		return
 	}
-	conn.WriteString(string(val))
+	conn.WriteBulk(val)
 }
 
 func (s *Server) handleGETSET(conn redcon.Conn, cmd redcon.Command) {
@@ -118,10 +131,14 @@ func (s *Server) handleGETSET(conn redcon.Conn, cmd redcon.Command) {
 
 	val, err := s.db.GETSET(cmd.Args[1], cmd.Args[2])
 	if err != nil {
-		conn.WriteError(err.Error())
+		writeError(conn, err)
 		return
 	}
-	conn.WriteString(string(val))
+	if val == nil {
+		conn.WriteNull()
+		return
+	}
+	conn.WriteBulk(val)
 }
 
 func (s *Server) handleINCR(conn redcon.Conn, cmd redcon.Command) {
@@ -132,7 +149,7 @@ func (s *Server) handleINCR(conn redcon.Conn, cmd redcon.Command) {
 
 	val, err := s.db.INCR(cmd.Args[1])
 	if err != nil {
-		conn.WriteError(err.Error())
+		writeError(conn, err)
 		return
 	}
 	conn.WriteInt64(val)
@@ -146,13 +163,13 @@ func (s *Server) handleINCRBY(conn redcon.Conn, cmd redcon.Command) {
 
 	by, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
 	if err != nil {
-		conn.WriteError(err.Error())
+		writeError(conn, core.ErrNotIntOrOutOfRange)
 		return
 	}
 
 	val, err := s.db.INCRBY(cmd.Args[1], int(by))
 	if err != nil {
-		conn.WriteError(err.Error())
+		writeError(conn, err)
 		return
 	}
 	conn.WriteInt64(val)
@@ -166,28 +183,111 @@ func (s *Server) handleINCRBYFLOAT(conn redcon.Conn, cmd redcon.Command) {
 
 	by, err := strconv.ParseFloat(string(cmd.Args[2]), 64)
 	if err != nil {
-		conn.WriteError(err.Error())
+		writeError(conn, core.ErrNotFloat)
 		return
 	}
 
 	val, err := s.db.INCRBYFLOAT(cmd.Args[1], by)
 	if err != nil {
-		conn.WriteError(err.Error())
+		writeError(conn, err)
 		return
 	}
 	conn.WriteString(val)
 }
 
+func (s *Server) handleLCS(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'LCS' command")
+		return
+	}
+
+	var opts core.LCSOptions
+	var withMatchLen bool
+	for i := 3; i < len(cmd.Args); i++ {
+		switch strings.ToUpper(string(cmd.Args[i])) {
+		case "LEN":
+			opts.Len = true
+		case "IDX":
+			opts.Idx = true
+		case "WITHMATCHLEN":
+			withMatchLen = true
+		case "MINMATCHLEN":
+			if i+1 == len(cmd.Args) {
+				writeError(conn, core.ErrSyntax)
+				return
+			}
+			i++
+			n, err := strconv.ParseInt(string(cmd.Args[i]), 10, 64)
+			if err != nil {
+				writeError(conn, core.ErrNotIntOrOutOfRange)
+				return
+			}
+			opts.MinMatchLen = int(max(n, 0))
+		default:
+			writeError(conn, core.ErrSyntax)
+			return
+		}
+	}
+	if opts.Len && opts.Idx {
+		conn.WriteError("ERR If you want both the length and indexes, please just use IDX.")
+		return
+	}
+
+	res, err := s.db.LCS(cmd.Args[1], cmd.Args[2], opts)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	switch {
+	case opts.Len:
+		conn.WriteInt(res.Len)
+	case opts.Idx:
+		conn.WriteArray(4)
+		conn.WriteBulkString("matches")
+		conn.WriteArray(len(res.Matches))
+		for _, m := range res.Matches {
+			if withMatchLen {
+				conn.WriteArray(3)
+			} else {
+				conn.WriteArray(2)
+			}
+			conn.WriteArray(2)
+			conn.WriteInt(m.Start1)
+			conn.WriteInt(m.End1)
+			conn.WriteArray(2)
+			conn.WriteInt(m.Start2)
+			conn.WriteInt(m.End2)
+			if withMatchLen {
+				conn.WriteInt(m.Len)
+			}
+		}
+		conn.WriteBulkString("len")
+		conn.WriteInt(res.Len)
+	default:
+		conn.WriteBulk(res.Seq)
+	}
+}
+
 func (s *Server) handleMGET(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'MGET' command")
+		return
+	}
+
 	res, err := s.db.MGET(cmd.Args[1:]...)
 	if err != nil {
-		conn.WriteError("ERR in 'MGET' command: " + err.Error())
+		writeError(conn, err)
 		return
 	}
 
 	conn.WriteArray(len(res))
 	for i := range res {
-		conn.WriteBulkString(string(res[i]))
+		if res[i] == nil {
+			conn.WriteNull()
+			continue
+		}
+		conn.WriteBulk(res[i])
 	}
 }
 
@@ -199,24 +299,100 @@ func (s *Server) handleMSET(conn redcon.Conn, cmd redcon.Command) {
 
 	err := s.db.MSET(cmd.Args[1:]...)
 	if err != nil {
-		conn.WriteError("ERR in 'MSET' command: " + err.Error())
+		writeError(conn, err)
 		return
 	}
 	conn.WriteString("OK")
 }
 
+func (s *Server) handleMSETNX(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 || (len(cmd.Args)-1)%2 != 0 {
+		conn.WriteError("ERR wrong number of arguments for 'MSETNX' command")
+		return
+	}
+
+	ok, err := s.db.MSETNX(cmd.Args[1:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBool(conn, ok)
+}
+
+func (s *Server) handlePSETEX(conn redcon.Conn, cmd redcon.Command) {
+	s.setexGeneric(conn, cmd, "PSETEX", s.db.PSETEX)
+}
+
 func (s *Server) handleSET(conn redcon.Conn, cmd redcon.Command) {
-	if len(cmd.Args) != 3 {
+	if len(cmd.Args) < 3 {
 		conn.WriteError("ERR wrong number of arguments for 'SET' command")
 		return
 	}
 
-	err := s.db.SET(cmd.Args[1], cmd.Args[2])
+	opts, err := parseSetOptions(cmd.Args[3:])
 	if err != nil {
-		conn.WriteError("ERR in 'SET' command: " + err.Error())
+		writeError(conn, err)
 		return
 	}
-	conn.WriteString("OK")
+
+	old, ok, err := s.db.SET(cmd.Args[1], cmd.Args[2], opts)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	switch {
+	case opts.Get && old == nil:
+		conn.WriteNull()
+	case opts.Get:
+		conn.WriteBulk(old)
+	case !ok:
+		conn.WriteNull()
+	default:
+		conn.WriteString("OK")
+	}
+}
+
+func (s *Server) handleSETEX(conn redcon.Conn, cmd redcon.Command) {
+	s.setexGeneric(conn, cmd, "SETEX", s.db.SETEX)
+}
+
+func (s *Server) handleSETNX(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'SETNX' command")
+		return
+	}
+
+	ok, err := s.db.SETNX(cmd.Args[1], cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBool(conn, ok)
+}
+
+func (s *Server) handleSETRANGE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'SETRANGE' command")
+		return
+	}
+
+	offset, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+	if offset < 0 {
+		conn.WriteError("ERR offset is out of range")
+		return
+	}
+
+	size, err := s.db.SETRANGE(cmd.Args[1], int(offset), cmd.Args[3])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(size)
 }
 
 func (s *Server) handleSTRLEN(conn redcon.Conn, cmd redcon.Command) {
@@ -227,8 +403,153 @@ func (s *Server) handleSTRLEN(conn redcon.Conn, cmd redcon.Command) {
 
 	val, err := s.db.STRLEN(cmd.Args[1])
 	if err != nil {
-		conn.WriteError("ERR in 'STRLEN' command: " + err.Error())
+		writeError(conn, err)
 		return
 	}
 	conn.WriteInt64(val)
 }
+
+func (s *Server) handleSUBSTR(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'SUBSTR' command")
+		return
+	}
+
+	start, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+	end, err := strconv.ParseInt(string(cmd.Args[3]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+
+	val, err := s.db.SUBSTR(cmd.Args[1], int(start), int(end))
+	if err != nil && !errors.Is(err, core.ErrKeyNotFound) {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteBulk(val)
+}
+
+func (s *Server) setexGeneric(conn redcon.Conn, cmd redcon.Command, name string, fn func(key []byte, d int64, value []byte) error) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	d, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+
+	if err := fn(cmd.Args[1], d, cmd.Args[3]); err != nil {
+		writeExpireError(conn, err, name)
+		return
+	}
+	conn.WriteString("OK")
+}
+
+// parseSetOptions parses SET options:
+// [NX | XX] [GET] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+func parseSetOptions(args [][]byte) (core.SetOptions, error) {
+	var opts core.SetOptions
+
+	for i := 0; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); opt {
+		case "NX":
+			if opts.XX {
+				return opts, core.ErrSyntax
+			}
+			opts.NX = true
+		case "XX":
+			if opts.NX {
+				return opts, core.ErrSyntax
+			}
+			opts.XX = true
+		case "GET":
+			opts.Get = true
+		case "KEEPTTL":
+			if opts.ExpireAt != 0 {
+				return opts, core.ErrSyntax
+			}
+			opts.KeepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if opts.ExpireAt != 0 || opts.KeepTTL || i+1 == len(args) {
+				return opts, core.ErrSyntax
+			}
+			i++
+
+			at, err := parseExpireAt(opt, args[i], "set")
+			if err != nil {
+				return opts, err
+			}
+			opts.ExpireAt = at
+		default:
+			return opts, core.ErrSyntax
+		}
+	}
+	return opts, nil
+}
+
+// parseGetExOptions parses GETEX options:
+// [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | PERSIST]
+func parseGetExOptions(args [][]byte) (core.GetExOptions, error) {
+	var opts core.GetExOptions
+
+	for i := 0; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); opt {
+		case "PERSIST":
+			if opts.ExpireAt != 0 {
+				return opts, core.ErrSyntax
+			}
+			opts.Persist = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if opts.ExpireAt != 0 || opts.Persist || i+1 == len(args) {
+				return opts, core.ErrSyntax
+			}
+			i++
+
+			at, err := parseExpireAt(opt, args[i], "getex")
+			if err != nil {
+				return opts, err
+			}
+			opts.ExpireAt = at
+		default:
+			return opts, core.ErrSyntax
+		}
+	}
+	return opts, nil
+}
+
+// parseExpireAt returns unix time in milliseconds for EX, PX, EXAT or PXAT option.
+func parseExpireAt(opt string, arg []byte, cmdName string) (int64, error) {
+	errInvalid := errors.New("invalid expire time in '" + cmdName + "' command")
+
+	d, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, core.ErrNotIntOrOutOfRange
+	}
+	if d <= 0 {
+		return 0, errInvalid
+	}
+
+	var at int64
+	switch opt {
+	case "EX":
+		at, err = core.ExpireAtMs(core.NowMs(), d, time.Second)
+	case "PX":
+		at, err = core.ExpireAtMs(core.NowMs(), d, time.Millisecond)
+	case "EXAT":
+		at, err = core.UnixMs(d, time.Second)
+	case "PXAT":
+		at = d
+	}
+	if err != nil {
+		return 0, errInvalid
+	}
+	return at, nil
+}
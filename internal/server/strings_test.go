@@ -93,6 +93,10 @@ func TestDECRBY(t *testing.T) {
 	val, err := client.DecrBy(ctx, "mykey", 3).Result()
 	testt.NoError(t, err)
 	testt.MustEqual(t, val, int64(7))
+
+	err = client.Do(ctx, "DECRBY", "mykey", "three").Err()
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), "ERR value is not an integer or out of range")
 }
 
 func TestGET(t *testing.T) {
@@ -111,8 +115,7 @@ func TestGET(t *testing.T) {
 	client := testClient(t, addr)
 
 	val, err := client.Get(ctx, "nonexisting").Result()
-	testt.WantError(t, err)
-	testt.MustEqual(t, err.Error(), "key not found")
+	testt.MustEqual(t, err, redis.Nil)
 
 	err = client.Set(ctx, "mykey", "Hello", 0).Err()
 	testt.NoError(t, err)
@@ -145,8 +148,46 @@ func TestGETDEL(t *testing.T) {
 	testt.MustEqual(t, string(val), "Hello")
 
 	val, err = client.Get(ctx, "mykey").Result()
-	testt.WantError(t, err)
-	testt.MustEqual(t, err.Error(), "key not found")
+	testt.MustEqual(t, err, redis.Nil)
+}
+
+func TestGETEX(t *testing.T) {
+	/*
+		redis> SET mykey "Hello"
+		"OK"
+		redis> GETEX mykey EX 60
+		"Hello"
+		redis> TTL mykey
+		(integer) 60
+		redis> GETEX mykey PERSIST
+		"Hello"
+		redis> TTL mykey
+		(integer) -1
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "mykey", "Hello", 0).Err()
+	testt.NoError(t, err)
+
+	val, err := client.GetEx(ctx, "mykey", time.Minute).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "Hello")
+
+	ttl, err := client.TTL(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, time.Minute)
+
+	val, err = client.GetEx(ctx, "mykey", 0).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "Hello")
+
+	ttl, err = client.TTL(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, time.Duration(-1))
 }
 
 func TestGETRANGE(t *testing.T) {
@@ -299,6 +340,82 @@ func TestINCRBYFLOAT(t *testing.T) {
 	val, err = client.IncrByFloat(ctx, "mykey", 2.0e2).Result()
 	testt.NoError(t, err)
 	testt.MustEqual(t, val, 5200.0)
+
+	err = client.Set(ctx, "mykey", []byte("Hello"), 0).Err()
+	testt.NoError(t, err)
+
+	err = client.IncrByFloat(ctx, "mykey", 1).Err()
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), "ERR value is not a valid float")
+}
+
+func TestLCS(t *testing.T) {
+	/*
+		redis> MSET key1 ohmytext key2 mynewtext
+		"OK"
+		redis> LCS key1 key2
+		"mytext"
+		redis> LCS key1 key2 LEN
+		(integer) 6
+		redis> LCS key1 key2 IDX
+		1) "matches"
+		2) 1) 1) 1) (integer) 4
+		         2) (integer) 7
+		      2) 1) (integer) 5
+		         2) (integer) 8
+		   2) 1) 1) (integer) 2
+		         2) (integer) 3
+		      2) 1) (integer) 0
+		         2) (integer) 1
+		3) "len"
+		4) (integer) 6
+		redis> LCS key1 key2 IDX MINMATCHLEN 4 WITHMATCHLEN
+		1) "matches"
+		2) 1) 1) 1) (integer) 4
+		         2) (integer) 7
+		      2) 1) (integer) 5
+		         2) (integer) 8
+		      3) (integer) 4
+		3) "len"
+		4) (integer) 6
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.MSet(ctx, "key1", "ohmytext", "key2", "mynewtext").Err()
+	testt.NoError(t, err)
+
+	res, err := client.LCS(ctx, &redis.LCSQuery{Key1: "key1", Key2: "key2"}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res.MatchString, "mytext")
+
+	res, err = client.LCS(ctx, &redis.LCSQuery{Key1: "key1", Key2: "key2", Len: true}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res.Len, int64(6))
+
+	res, err = client.LCS(ctx, &redis.LCSQuery{Key1: "key1", Key2: "key2", Idx: true}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res.Len, int64(6))
+	testt.MustEqual(t, res.Matches, []redis.LCSMatchedPosition{
+		{Key1: redis.LCSPosition{Start: 4, End: 7}, Key2: redis.LCSPosition{Start: 5, End: 8}},
+		{Key1: redis.LCSPosition{Start: 2, End: 3}, Key2: redis.LCSPosition{Start: 0, End: 1}},
+	})
+
+	res, err = client.LCS(ctx, &redis.LCSQuery{
+		Key1:         "key1",
+		Key2:         "key2",
+		Idx:          true,
+		MinMatchLen:  4,
+		WithMatchLen: true,
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res.Len, int64(6))
+	testt.MustEqual(t, res.Matches, []redis.LCSMatchedPosition{
+		{Key1: redis.LCSPosition{Start: 4, End: 7}, Key2: redis.LCSPosition{Start: 5, End: 8}, MatchLen: 4},
+	})
 }
 
 func TestMGET(t *testing.T) {
@@ -330,7 +447,7 @@ func TestMGET(t *testing.T) {
 	testt.MustEqual(t, len(vals), 3)
 	testt.MustEqual(t, vals[0].(string), "Hello")
 	testt.MustEqual(t, vals[1].(string), "World")
-	testt.MustEqual(t, vals[2].(string), "")
+	testt.MustEqual(t, vals[2], nil)
 }
 
 func TestMSET(t *testing.T) {
@@ -360,6 +477,65 @@ func TestMSET(t *testing.T) {
 	testt.MustEqual(t, string(val), "World")
 }
 
+func TestMSETNX(t *testing.T) {
+	/*
+		redis> MSETNX key1 "Hello" key2 "there"
+		(integer) 1
+		redis> MSETNX key2 "new" key3 "world"
+		(integer) 0
+		redis> MGET key1 key2 key3
+		1) "Hello"
+		2) "there"
+		3) (nil)
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	ok, err := client.MSetNX(ctx, "key1", "Hello", "key2", "there").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = client.MSetNX(ctx, "key2", "new", "key3", "world").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	vals, err := client.MGet(ctx, "key1", "key2", "key3").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(vals), 3)
+	testt.MustEqual(t, vals[0].(string), "Hello")
+	testt.MustEqual(t, vals[1].(string), "there")
+}
+
+func TestPSETEX(t *testing.T) {
+	/*
+		redis> PSETEX mykey 1000 "Hello"
+		"OK"
+		redis> PTTL mykey
+		(integer) 1000
+		redis> GET mykey
+		"Hello"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Do(ctx, "PSETEX", "mykey", 1000, "Hello").Err()
+	testt.NoError(t, err)
+
+	ttl, err := client.PTTL(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl > 900*time.Millisecond && ttl <= time.Second, true)
+
+	val, err := client.Get(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "Hello")
+}
+
 func TestSET(t *testing.T) {
 	/*
 		redis> SET "mykey" "Hello"
@@ -380,6 +556,160 @@ func TestSET(t *testing.T) {
 	testt.MustEqual(t, string(val), "Hello")
 }
 
+func TestSETOptions(t *testing.T) {
+	/*
+		redis> SET mykey "Hello" NX EX 60
+		"OK"
+		redis> SET mykey "World" NX
+		(nil)
+		redis> SET mykey "World" XX GET KEEPTTL
+		"Hello"
+		redis> TTL mykey
+		(integer) 60
+		redis> SET mykey "Again" EX 0
+		(error) ERR invalid expire time in 'set' command
+		redis> SET mykey "Again" EX 10 PX 100
+		(error) ERR syntax error
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	ok, err := client.SetNX(ctx, "mykey", "Hello", time.Minute).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = client.SetNX(ctx, "mykey", "World", time.Minute).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	val, err := client.SetArgs(ctx, "mykey", "World", redis.SetArgs{
+		Mode:    "XX",
+		Get:     true,
+		KeepTTL: true,
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "Hello")
+
+	ttl, err := client.TTL(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, time.Minute)
+
+	err = client.Do(ctx, "SET", "mykey", "Again", "EX", 0).Err()
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), "ERR invalid expire time in 'set' command")
+
+	err = client.Do(ctx, "SET", "mykey", "Again", "EX", 10, "PX", 100).Err()
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+}
+
+func TestSETEX(t *testing.T) {
+	/*
+		redis> SETEX mykey 10 "Hello"
+		"OK"
+		redis> TTL mykey
+		(integer) 10
+		redis> GET mykey
+		"Hello"
+		redis> SETEX mykey 0 "Hello"
+		(error) ERR invalid expire time in 'setex' command
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.SetEx(ctx, "mykey", "Hello", 10*time.Second).Err()
+	testt.NoError(t, err)
+
+	ttl, err := client.TTL(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ttl, 10*time.Second)
+
+	val, err := client.Get(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "Hello")
+
+	err = client.Do(ctx, "SETEX", "mykey", 0, "Hello").Err()
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), "ERR invalid expire time in 'setex' command")
+}
+
+func TestSETNX(t *testing.T) {
+	/*
+		redis> SETNX mykey "Hello"
+		(integer) 1
+		redis> SETNX mykey "World"
+		(integer) 0
+		redis> GET mykey
+		"Hello"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	ok, err := client.SetNX(ctx, "mykey", "Hello", 0).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, true)
+
+	ok, err = client.SetNX(ctx, "mykey", "World", 0).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, ok, false)
+
+	val, err := client.Get(ctx, "mykey").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "Hello")
+}
+
+func TestSETRANGE(t *testing.T) {
+	/*
+		redis> SET key1 "Hello World"
+		"OK"
+		redis> SETRANGE key1 6 "Redis"
+		(integer) 11
+		redis> GET key1
+		"Hello Redis"
+		redis> SETRANGE key2 6 "Redis"
+		(integer) 11
+		redis> GET key2
+		"\x00\x00\x00\x00\x00\x00Redis"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "key1", "Hello World", 0).Err()
+	testt.NoError(t, err)
+
+	size, err := client.SetRange(ctx, "key1", 6, "Redis").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, size, int64(11))
+
+	val, err := client.Get(ctx, "key1").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "Hello Redis")
+
+	size, err = client.SetRange(ctx, "key2", 6, "Redis").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, size, int64(11))
+
+	val, err = client.Get(ctx, "key2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "\x00\x00\x00\x00\x00\x00Redis")
+
+	err = client.SetRange(ctx, "key2", -1, "Redis").Err()
+	testt.WantError(t, err)
+	testt.MustEqual(t, err.Error(), "ERR offset is out of range")
+}
+
 func TestSTRLEN(t *testing.T) {
 	/*
 		redis> SET "mykey" "Hello world"
@@ -407,6 +737,33 @@ func TestSTRLEN(t *testing.T) {
 	testt.MustEqual(t, size, int64(0))
 }
 
+func TestSUBSTR(t *testing.T) {
+	/*
+		redis> SET mykey "This is a string"
+		"OK"
+		redis> SUBSTR mykey 0 3
+		"This"
+		redis> SUBSTR mykey -3 -1
+		"ing"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Set(ctx, "mykey", "This is a string", 0).Err()
+	testt.NoError(t, err)
+
+	val, err := client.Do(ctx, "SUBSTR", "mykey", 0, 3).Text()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "This")
+
+	val, err = client.Do(ctx, "SUBSTR", "mykey", -3, -1).Text()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "ing")
+}
+
 func testServer(tb testing.TB) string {
 	tb.Helper()
 
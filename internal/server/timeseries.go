@@ -0,0 +1,458 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/tidwall/redcon"
+)
+
+// Time series operations https://redis.io/commands/?group=timeseries
+
+func (s *Server) handleTSADD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for 'TS.ADD' command")
+		return
+	}
+
+	var sample core.TSSample
+	if string(cmd.Args[2]) == "*" {
+		sample.Timestamp = core.NowMs()
+	} else {
+		ts, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+		if err != nil || ts < 0 {
+			conn.WriteError("ERR TSDB: invalid timestamp")
+			return
+		}
+		sample.Timestamp = ts
+	}
+	value, err := strconv.ParseFloat(string(cmd.Args[3]), 64)
+	if err != nil || math.IsNaN(value) {
+		conn.WriteError("ERR TSDB: invalid value")
+		return
+	}
+	sample.Value = value
+
+	var opts core.TSAddOptions
+	if err := parseTSOptions(cmd.Args[4:], &opts, true); err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.TSADD(cmd.Args[1], sample, opts)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt64(res)
+}
+
+func (s *Server) handleTSCREATE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'TS.CREATE' command")
+		return
+	}
+
+	var opts core.TSAddOptions
+	if err := parseTSOptions(cmd.Args[2:], &opts, false); err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	if err := s.db.TSCREATE(cmd.Args[1], opts.TSOptions); err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteString("OK")
+}
+
+func (s *Server) handleTSCREATERULE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 6 && len(cmd.Args) != 7 {
+		conn.WriteError("ERR wrong number of arguments for 'TS.CREATERULE' command")
+		return
+	}
+	if !strings.EqualFold(string(cmd.Args[3]), "AGGREGATION") {
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	agg, err := parseTSAggregation(cmd.Args[4], cmd.Args[5])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	if len(cmd.Args) == 7 {
+		agg.Align, err = strconv.ParseInt(string(cmd.Args[6]), 10, 64)
+		if err != nil {
+			conn.WriteError("ERR TSDB: Couldn't parse alignTimestamp")
+			return
+		}
+	}
+
+	if err := s.db.TSCREATERULE(cmd.Args[1], cmd.Args[2], agg); err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteString("OK")
+}
+
+func (s *Server) handleTSDELETERULE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'TS.DELETERULE' command")
+		return
+	}
+
+	if err := s.db.TSDELETERULE(cmd.Args[1], cmd.Args[2]); err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteString("OK")
+}
+
+func (s *Server) handleTSMRANGE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 5 {
+		conn.WriteError("ERR wrong number of arguments for 'TS.MRANGE' command")
+		return
+	}
+
+	args, err := parseTSRangeArgs(cmd.Args[1:], true)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.TSMRANGE(args.filters, args.opts)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteArray(len(res))
+	for _, r := range res {
+		conn.WriteArray(3)
+		conn.WriteBulk(r.Key)
+		writeTSLabels(conn, r.Labels, args)
+		writeTSSamples(conn, r.Samples)
+	}
+}
+
+func (s *Server) handleTSQUERYINDEX(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'TS.QUERYINDEX' command")
+		return
+	}
+
+	filters, err := parseTSFilters(cmd.Args[1:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.TSQUERYINDEX(filters)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeBulks(conn, res)
+}
+
+func (s *Server) handleTSRANGE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for 'TS.RANGE' command")
+		return
+	}
+
+	args, err := parseTSRangeArgs(cmd.Args[2:], false)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.TSRANGE(cmd.Args[1], args.opts)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeTSSamples(conn, res)
+}
+
+// parseTSOptions parses options of TS.CREATE and TS.ADD (with onDuplicate) commands.
+// ENCODING and CHUNK_SIZE are validated and ignored, samples are stored one by one.
+func parseTSOptions(args [][]byte, opts *core.TSAddOptions, onDuplicate bool) error {
+	for ; len(args) > 0; args = args[1:] {
+		opt := strings.ToUpper(string(args[0]))
+		if opt == "LABELS" {
+			args = args[1:]
+			if len(args)%2 != 0 {
+				return core.ErrSyntax
+			}
+			for i := 0; i < len(args); i += 2 {
+				opts.Labels = append(opts.Labels, core.TSLabel{Name: args[i], Value: args[i+1]})
+			}
+			return nil
+		}
+		if len(args) < 2 {
+			return core.ErrSyntax
+		}
+
+		var err error
+		switch opt {
+		case "RETENTION":
+			opts.Retention, err = strconv.ParseInt(string(args[1]), 10, 64)
+			if err != nil || opts.Retention < 0 {
+				return errors.New("TSDB: Couldn't parse RETENTION")
+			}
+		case "ENCODING":
+			if enc := strings.ToUpper(string(args[1])); enc != "COMPRESSED" && enc != "UNCOMPRESSED" {
+				return errors.New("TSDB: unknown ENCODING parameter")
+			}
+		case "CHUNK_SIZE":
+			size, err := strconv.ParseInt(string(args[1]), 10, 64)
+			if err != nil || size < 48 || size > 1048576 || size%8 != 0 {
+				return errors.New("TSDB: CHUNK_SIZE value must be a multiple of 8 in the range [48 .. 1048576]")
+			}
+		case "DUPLICATE_POLICY":
+			if opts.DuplicatePolicy, err = core.ParseTSDuplicatePolicy(args[1]); err != nil {
+				return err
+			}
+		case "ON_DUPLICATE":
+			if !onDuplicate {
+				return core.ErrSyntax
+			}
+			if opts.OnDuplicate, err = core.ParseTSDuplicatePolicy(args[1]); err != nil {
+				return err
+			}
+		default:
+			return core.ErrSyntax
+		}
+		args = args[1:]
+	}
+	return nil
+}
+
+// parseTSAggregation parses an aggregator and a bucket duration.
+func parseTSAggregation(name, duration []byte) (core.TSAggregation, error) {
+	typ, err := core.ParseTSAggregationType(name)
+	if err != nil {
+		return core.TSAggregation{}, err
+	}
+	d, err := strconv.ParseInt(string(duration), 10, 64)
+	if err != nil || d <= 0 {
+		return core.TSAggregation{}, core.ErrTSBucketDuration
+	}
+	return core.TSAggregation{Type: typ, BucketDuration: d}, nil
+}
+
+func parseTSFilters(args [][]byte) ([]core.TSFilter, error) {
+	filters := make([]core.TSFilter, len(args))
+	for i, arg := range args {
+		f, err := core.ParseTSFilter(arg)
+		if err != nil {
+			return nil, err
+		}
+		filters[i] = f
+	}
+	return filters, nil
+}
+
+// tsRangeArgs are arguments of TS.RANGE and TS.MRANGE commands.
+type tsRangeArgs struct {
+	opts core.TSRangeOptions
+
+	// TS.MRANGE only.
+	withLabels     bool
+	selectedLabels [][]byte
+	filters        []core.TSFilter
+}
+
+// parseTSRangeArgs parses arguments of TS.RANGE and TS.MRANGE (multi) commands starting from fromTimestamp.
+// LATEST is accepted and ignored, GROUPBY is not supported.
+func parseTSRangeArgs(args [][]byte, multi bool) (tsRangeArgs, error) {
+	var res tsRangeArgs
+	var err error
+	if res.opts.From, err = parseTSRangeTimestamp(args[0]); err != nil {
+		return res, errors.New("TSDB: wrong fromTimestamp")
+	}
+	if res.opts.To, err = parseTSRangeTimestamp(args[1]); err != nil {
+		return res, errors.New("TSDB: wrong toTimestamp")
+	}
+
+	var align []byte
+	for args = args[2:]; len(args) > 0; args = args[1:] {
+		switch opt := strings.ToUpper(string(args[0])); {
+		case opt == "LATEST":
+		case opt == "EMPTY":
+			res.opts.Aggregation.Empty = true
+		case opt == "WITHLABELS" && multi:
+			res.withLabels = true
+		case opt == "SELECTED_LABELS" && multi:
+			for len(args) > 1 && !isTSRangeOption(args[1]) {
+				res.selectedLabels = append(res.selectedLabels, args[1])
+				args = args[1:]
+			}
+			if len(res.selectedLabels) == 0 {
+				return res, core.ErrSyntax
+			}
+		case opt == "FILTER" && multi:
+			n := 1
+			for n < len(args) && !strings.EqualFold(string(args[n]), "GROUPBY") {
+				n++
+			}
+			if res.filters, err = parseTSFilters(args[1:n]); err != nil {
+				return res, err
+			}
+			args = args[n-1:]
+		case opt == "GROUPBY" && multi:
+			return res, errors.New("TSDB: GROUPBY is not supported")
+		case opt == "FILTER_BY_TS":
+			res.opts.FilterByTS = []int64{}
+			for len(args) > 1 {
+				ts, err := strconv.ParseInt(string(args[1]), 10, 64)
+				if err != nil {
+					break
+				}
+				res.opts.FilterByTS = append(res.opts.FilterByTS, ts)
+				args = args[1:]
+			}
+			if len(res.opts.FilterByTS) == 0 {
+				return res, errors.New("TSDB: FILTER_BY_TS one or more arguments are missing")
+			}
+		case len(args) < 2:
+			return res, core.ErrSyntax
+		case opt == "FILTER_BY_VALUE":
+			if len(args) < 3 {
+				return res, core.ErrSyntax
+			}
+			res.opts.FilterByValue = true
+			if res.opts.MinValue, err = strconv.ParseFloat(string(args[1]), 64); err != nil {
+				return res, errors.New("TSDB: Couldn't parse MIN")
+			}
+			if res.opts.MaxValue, err = strconv.ParseFloat(string(args[2]), 64); err != nil {
+				return res, errors.New("TSDB: Couldn't parse MAX")
+			}
+			args = args[2:]
+		case opt == "COUNT":
+			res.opts.Count, err = strconv.ParseInt(string(args[1]), 10, 64)
+			if err != nil || res.opts.Count <= 0 {
+				return res, errors.New("TSDB: Couldn't parse COUNT")
+			}
+			args = args[1:]
+		case opt == "ALIGN":
+			align, args = args[1], args[1:]
+		case opt == "AGGREGATION":
+			if len(args) < 3 {
+				return res, core.ErrSyntax
+			}
+			agg, err := parseTSAggregation(args[1], args[2])
+			if err != nil {
+				return res, err
+			}
+			agg.Empty = res.opts.Aggregation.Empty
+			res.opts.Aggregation, args = agg, args[2:]
+		case opt == "BUCKETTIMESTAMP":
+			switch strings.ToLower(string(args[1])) {
+			case "-", "low", "start":
+				res.opts.Aggregation.BucketTimestamp = core.TSBucketLow
+			case "+", "high", "end":
+				res.opts.Aggregation.BucketTimestamp = core.TSBucketHigh
+			case "~", "mid":
+				res.opts.Aggregation.BucketTimestamp = core.TSBucketMid
+			default:
+				return res, errors.New("TSDB: unknown BUCKETTIMESTAMP parameter")
+			}
+			args = args[1:]
+		default:
+			return res, core.ErrSyntax
+		}
+	}
+
+	if align != nil {
+		switch strings.ToLower(string(align)) {
+		case "-", "start":
+			res.opts.Aggregation.Align = res.opts.From
+		case "+", "end":
+			res.opts.Aggregation.Align = res.opts.To
+		default:
+			if res.opts.Aggregation.Align, err = strconv.ParseInt(string(align), 10, 64); err != nil {
+				return res, errors.New("TSDB: unknown ALIGN parameter")
+			}
+		}
+	}
+	if multi && res.filters == nil {
+		return res, errors.New("TSDB: missing FILTER argument")
+	}
+	if res.withLabels && res.selectedLabels != nil {
+		return res, errors.New("TSDB: cannot accept WITHLABELS and SELECT_LABELS together")
+	}
+	return res, nil
+}
+
+// isTSRangeOption reports whether arg is an option of TS.MRANGE command.
+func isTSRangeOption(arg []byte) bool {
+	switch strings.ToUpper(string(arg)) {
+	case "LATEST", "FILTER_BY_TS", "FILTER_BY_VALUE", "WITHLABELS", "SELECTED_LABELS", "COUNT",
+		"ALIGN", "AGGREGATION", "BUCKETTIMESTAMP", "EMPTY", "FILTER", "GROUPBY":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseTSRangeTimestamp parses a timestamp, - and + are the earliest and the latest ones.
+func parseTSRangeTimestamp(arg []byte) (int64, error) {
+	switch string(arg) {
+	case "-":
+		return 0, nil
+	case "+":
+		return math.MaxInt64, nil
+	}
+	ts, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil || ts < 0 {
+		return 0, errors.New("invalid timestamp")
+	}
+	return ts, nil
+}
+
+// writeTSLabels writes all labels with WITHLABELS, selected ones (nil for missing) with
+// SELECTED_LABELS and no labels otherwise.
+func writeTSLabels(conn redcon.Conn, labels []core.TSLabel, args tsRangeArgs) {
+	switch {
+	case args.withLabels:
+		conn.WriteArray(len(labels))
+		for _, l := range labels {
+			conn.WriteArray(2)
+			conn.WriteBulk(l.Name)
+			conn.WriteBulk(l.Value)
+		}
+	case args.selectedLabels != nil:
+		conn.WriteArray(len(args.selectedLabels))
+		for _, name := range args.selectedLabels {
+			conn.WriteArray(2)
+			conn.WriteBulk(name)
+			i := slices.IndexFunc(labels, func(l core.TSLabel) bool {
+				return bytes.Equal(l.Name, name)
+			})
+			if i < 0 {
+				conn.WriteNull()
+				continue
+			}
+			conn.WriteBulk(labels[i].Value)
+		}
+	default:
+		conn.WriteArray(0)
+	}
+}
+
+func writeTSSamples(conn redcon.Conn, samples []core.TSSample) {
+	conn.WriteArray(len(samples))
+	for _, s := range samples {
+		conn.WriteArray(2)
+		conn.WriteInt64(s.Timestamp)
+		conn.WriteBulkString(core.FormatScore(s.Value))
+	}
+}
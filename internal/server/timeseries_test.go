@@ -0,0 +1,295 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cristalhq/testt"
+)
+
+func TestTSADD(t *testing.T) {
+	/*
+		redis> TS.CREATE temp RETENTION 1000 DUPLICATE_POLICY MAX LABELS sensor 1
+		OK
+		redis> TS.ADD temp 1000 26.5
+		(integer) 1000
+		redis> TS.ADD temp 1000 20
+		(integer) 1000
+		redis> TS.ADD temp 1010 27 ON_DUPLICATE LAST
+		(integer) 1010
+		redis> TS.RANGE temp - +
+		1) 1) (integer) 1000
+		   2) 26.5
+		2) 1) (integer) 1010
+		   2) 27
+		redis> TS.ADD temp 1 1
+		(error) ERR TSDB: Timestamp is older than retention
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Do(ctx, "TS.CREATE", "temp", "RETENTION", "1000", "DUPLICATE_POLICY", "MAX", "LABELS", "sensor", "1").Err()
+	testt.NoError(t, err)
+
+	n, err := client.Do(ctx, "TS.ADD", "temp", "1000", "26.5").Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1000))
+
+	err = client.Do(ctx, "TS.ADD", "temp", "1000", "20").Err()
+	testt.NoError(t, err)
+
+	err = client.Do(ctx, "TS.ADD", "temp", "1010", "27", "ON_DUPLICATE", "LAST").Err()
+	testt.NoError(t, err)
+
+	res, err := client.Do(ctx, "TS.RANGE", "temp", "-", "+").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{
+		[]any{int64(1000), "26.5"},
+		[]any{int64(1010), "27"},
+	})
+
+	n, err = client.Do(ctx, "TS.ADD", "auto", "*", "1").Int64()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n > 0, true)
+
+	typ, err := client.Type(ctx, "temp").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "TSDB-TYPE")
+
+	cases := []struct {
+		args []any
+		err  string
+	}{
+		{[]any{"TS.ADD", "temp", "1", "1"}, "ERR TSDB: Timestamp is older than retention"},
+		{[]any{"TS.CREATE", "temp"}, "ERR TSDB: key already exists"},
+		{[]any{"TS.ADD", "temp", "-1", "1"}, "ERR TSDB: invalid timestamp"},
+		{[]any{"TS.ADD", "temp", "1", "abc"}, "ERR TSDB: invalid value"},
+		{[]any{"TS.ADD", "temp", "1"}, "ERR wrong number of arguments for 'TS.ADD' command"},
+		{[]any{"TS.CREATE", "x", "RETENTION", "-1"}, "ERR TSDB: Couldn't parse RETENTION"},
+		{[]any{"TS.CREATE", "x", "DUPLICATE_POLICY", "NEWEST"}, "ERR TSDB: Unknown DUPLICATE_POLICY"},
+		{[]any{"TS.CREATE", "x", "CHUNK_SIZE", "10"}, "ERR TSDB: CHUNK_SIZE value must be a multiple of 8 in the range [48 .. 1048576]"},
+		{[]any{"TS.CREATE", "x", "ON_DUPLICATE", "LAST"}, "ERR syntax error"},
+		{[]any{"TS.CREATE", "x", "LABELS", "a"}, "ERR syntax error"},
+		{[]any{"TS.RANGE", "nokey", "-", "+"}, "ERR TSDB: the key does not exist"},
+	}
+	for _, tc := range cases {
+		err := client.Do(ctx, tc.args...).Err()
+		testt.MustEqual(t, err.Error(), tc.err)
+	}
+}
+
+func TestTSRANGE(t *testing.T) {
+	/*
+		redis> TS.ADD temp 0 1
+		redis> TS.ADD temp 5 3
+		redis> TS.ADD temp 10 10
+		redis> TS.ADD temp 30 4
+		redis> TS.RANGE temp - + AGGREGATION avg 10
+		1) 1) (integer) 0
+		   2) 2
+		2) 1) (integer) 10
+		   2) 10
+		3) 1) (integer) 30
+		   2) 4
+		redis> TS.RANGE temp 0 100 FILTER_BY_VALUE 2 5 COUNT 1
+		1) 1) (integer) 5
+		   2) 3
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	for _, sample := range [][]string{{"0", "1"}, {"5", "3"}, {"10", "10"}, {"30", "4"}} {
+		err := client.Do(ctx, "TS.ADD", "temp", sample[0], sample[1]).Err()
+		testt.NoError(t, err)
+	}
+
+	res, err := client.Do(ctx, "TS.RANGE", "temp", "-", "+", "AGGREGATION", "avg", "10").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{
+		[]any{int64(0), "2"},
+		[]any{int64(10), "10"},
+		[]any{int64(30), "4"},
+	})
+
+	res, err = client.Do(ctx, "TS.RANGE", "temp", "0", "100", "FILTER_BY_VALUE", "2", "5", "COUNT", "1").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{[]any{int64(5), "3"}})
+
+	res, err = client.Do(ctx, "TS.RANGE", "temp", "-", "+", "FILTER_BY_TS", "10", "30", "AGGREGATION", "sum", "20", "BUCKETTIMESTAMP", "+").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{
+		[]any{int64(20), "10"},
+		[]any{int64(40), "4"},
+	})
+
+	res, err = client.Do(ctx, "TS.RANGE", "temp", "5", "+", "ALIGN", "start", "AGGREGATION", "count", "10", "EMPTY").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{
+		[]any{int64(5), "2"},
+		[]any{int64(15), "0"},
+		[]any{int64(25), "1"},
+	})
+
+	cases := []struct {
+		args []any
+		err  string
+	}{
+		{[]any{"TS.RANGE", "temp", "abc", "+"}, "ERR TSDB: wrong fromTimestamp"},
+		{[]any{"TS.RANGE", "temp", "-", "abc"}, "ERR TSDB: wrong toTimestamp"},
+		{[]any{"TS.RANGE", "temp", "-", "+", "AGGREGATION", "median", "10"}, "ERR TSDB: Unknown aggregation type"},
+		{[]any{"TS.RANGE", "temp", "-", "+", "AGGREGATION", "avg", "0"}, "ERR TSDB: bucketDuration must be greater than zero"},
+		{[]any{"TS.RANGE", "temp", "-", "+", "COUNT", "0"}, "ERR TSDB: Couldn't parse COUNT"},
+		{[]any{"TS.RANGE", "temp", "-", "+", "ALIGN", "middle"}, "ERR TSDB: unknown ALIGN parameter"},
+		{[]any{"TS.RANGE", "temp", "-", "+", "FILTER_BY_TS"}, "ERR TSDB: FILTER_BY_TS one or more arguments are missing"},
+		{[]any{"TS.RANGE", "temp", "-", "+", "WITHLABELS"}, "ERR syntax error"},
+		{[]any{"TS.RANGE", "temp", "-"}, "ERR wrong number of arguments for 'TS.RANGE' command"},
+	}
+	for _, tc := range cases {
+		err := client.Do(ctx, tc.args...).Err()
+		testt.MustEqual(t, err.Error(), tc.err)
+	}
+}
+
+func TestTSMRANGE(t *testing.T) {
+	/*
+		redis> TS.ADD a 1 10 LABELS type temp room kitchen
+		(integer) 1
+		redis> TS.ADD b 1 20 LABELS type temp room hall
+		(integer) 1
+		redis> TS.ADD c 1 30 LABELS type humidity room kitchen
+		(integer) 1
+		redis> TS.MRANGE - + WITHLABELS FILTER type=temp
+		1) 1) "a"
+		   2) 1) 1) "type"
+		         2) "temp"
+		      2) 1) "room"
+		         2) "kitchen"
+		   3) 1) 1) (integer) 1
+		         2) 10
+		2) 1) "b"
+		   2) 1) 1) "type"
+		         2) "temp"
+		      2) 1) "room"
+		         2) "hall"
+		   3) 1) 1) (integer) 1
+		         2) 20
+		redis> TS.QUERYINDEX room=kitchen
+		1) "a"
+		2) "c"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.Do(ctx, "TS.ADD", "a", "1", "10", "LABELS", "type", "temp", "room", "kitchen").Err()
+	testt.NoError(t, err)
+	err = client.Do(ctx, "TS.ADD", "b", "1", "20", "LABELS", "type", "temp", "room", "hall").Err()
+	testt.NoError(t, err)
+	err = client.Do(ctx, "TS.ADD", "c", "1", "30", "LABELS", "type", "humidity", "room", "kitchen").Err()
+	testt.NoError(t, err)
+
+	res, err := client.Do(ctx, "TS.MRANGE", "-", "+", "WITHLABELS", "FILTER", "type=temp").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{
+		[]any{"a", []any{[]any{"type", "temp"}, []any{"room", "kitchen"}}, []any{[]any{int64(1), "10"}}},
+		[]any{"b", []any{[]any{"type", "temp"}, []any{"room", "hall"}}, []any{[]any{int64(1), "20"}}},
+	})
+
+	res, err = client.Do(ctx, "TS.MRANGE", "-", "+", "SELECTED_LABELS", "room", "floor", "FILTER", "room=kitchen", "type!=temp").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{
+		[]any{"c", []any{[]any{"room", "kitchen"}, []any{"floor", nil}}, []any{[]any{int64(1), "30"}}},
+	})
+
+	res, err = client.Do(ctx, "TS.MRANGE", "-", "+", "AGGREGATION", "max", "10", "FILTER", "room=(kitchen,hall)").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, len(res), 3)
+
+	keys, err := client.Do(ctx, "TS.QUERYINDEX", "room=kitchen").StringSlice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, keys, []string{"a", "c"})
+
+	cases := []struct {
+		args []any
+		err  string
+	}{
+		{[]any{"TS.QUERYINDEX", "type!=temp"}, "ERR TSDB: please provide at least one matcher"},
+		{[]any{"TS.QUERYINDEX", "type"}, "ERR TSDB: failed parsing labels"},
+		{[]any{"TS.MRANGE", "-", "+", "WITHLABELS", "COUNT", "1"}, "ERR TSDB: missing FILTER argument"},
+		{[]any{"TS.MRANGE", "-", "+", "FILTER", "type=temp", "GROUPBY", "room", "REDUCE", "max"}, "ERR TSDB: GROUPBY is not supported"},
+		{[]any{"TS.MRANGE", "-", "+", "FILTER"}, "ERR wrong number of arguments for 'TS.MRANGE' command"},
+	}
+	for _, tc := range cases {
+		err := client.Do(ctx, tc.args...).Err()
+		testt.MustEqual(t, err.Error(), tc.err)
+	}
+}
+
+func TestTSCREATERULE(t *testing.T) {
+	/*
+		redis> TS.CREATE temp
+		OK
+		redis> TS.CREATE temp_avg
+		OK
+		redis> TS.CREATERULE temp temp_avg AGGREGATION avg 10
+		OK
+		redis> TS.ADD temp 1 1
+		redis> TS.ADD temp 5 3
+		redis> TS.ADD temp 12 7
+		redis> TS.RANGE temp_avg - +
+		1) 1) (integer) 0
+		   2) 2
+		redis> TS.CREATERULE temp temp_avg AGGREGATION avg 10
+		(error) ERR TSDB: the destination key already has a src rule
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	for _, key := range []string{"temp", "temp_avg", "other"} {
+		err := client.Do(ctx, "TS.CREATE", key).Err()
+		testt.NoError(t, err)
+	}
+	err := client.Do(ctx, "TS.CREATERULE", "temp", "temp_avg", "AGGREGATION", "avg", "10").Err()
+	testt.NoError(t, err)
+
+	for _, sample := range [][]string{{"1", "1"}, {"5", "3"}, {"12", "7"}} {
+		err := client.Do(ctx, "TS.ADD", "temp", sample[0], sample[1]).Err()
+		testt.NoError(t, err)
+	}
+
+	res, err := client.Do(ctx, "TS.RANGE", "temp_avg", "-", "+").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []any{[]any{int64(0), "2"}})
+
+	cases := []struct {
+		args []any
+		err  string
+	}{
+		{[]any{"TS.CREATERULE", "temp", "temp_avg", "AGGREGATION", "avg", "10"}, "ERR TSDB: the destination key already has a src rule"},
+		{[]any{"TS.CREATERULE", "temp", "temp", "AGGREGATION", "avg", "10"}, "ERR TSDB: the source key and destination key should be different"},
+		{[]any{"TS.CREATERULE", "temp", "nokey", "AGGREGATION", "avg", "10"}, "ERR TSDB: the key does not exist"},
+		{[]any{"TS.CREATERULE", "temp", "other", "AGGREGATION", "avg"}, "ERR wrong number of arguments for 'TS.CREATERULE' command"},
+		{[]any{"TS.CREATERULE", "temp", "other", "AGGREGATE", "avg", "10"}, "ERR syntax error"},
+		{[]any{"TS.DELETERULE", "temp", "other"}, "ERR TSDB: compaction rule does not exist"},
+	}
+	for _, tc := range cases {
+		err := client.Do(ctx, tc.args...).Err()
+		testt.MustEqual(t, err.Error(), tc.err)
+	}
+
+	err = client.Do(ctx, "TS.DELETERULE", "temp", "temp_avg").Err()
+	testt.NoError(t, err)
+	err = client.Do(ctx, "TS.CREATERULE", "other", "temp_avg", "AGGREGATION", "sum", "10", "5").Err()
+	testt.NoError(t, err)
+}
@@ -0,0 +1,868 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cristaloleg/didis/internal/core"
+
+	"github.com/tidwall/redcon"
+)
+
+// Sorted sets operations https://redis.io/commands/?group=sorted-set
+
+var (
+	errNotFloatBound = errors.New("min or max is not a float")
+	errNotLexBound   = errors.New("min or max not valid string range item")
+)
+
+func (s *Server) handleBZMPOP(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 5 {
+		conn.WriteError("ERR wrong number of arguments for 'BZMPOP' command")
+		return
+	}
+	cmd = cloneCommand(cmd)
+
+	deadline, err := parseTimeout(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	keys, max, count, err := parseZMPop(cmd.Args[2:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	s.block(conn, keys, deadline, func(conn redcon.Conn) bool {
+		return s.zmpop(conn, keys, max, count)
+	})
+}
+
+func (s *Server) handleBZPOPMAX(conn redcon.Conn, cmd redcon.Command) {
+	s.bzpopGeneric(conn, cmd, "BZPOPMAX", true)
+}
+
+func (s *Server) handleBZPOPMIN(conn redcon.Conn, cmd redcon.Command) {
+	s.bzpopGeneric(conn, cmd, "BZPOPMIN", false)
+}
+
+func (s *Server) handleZADD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for 'ZADD' command")
+		return
+	}
+
+	var opts core.ZAddOptions
+	incr := false
+	i := 2
+loop:
+	for ; i < len(cmd.Args); i++ {
+		switch strings.ToUpper(string(cmd.Args[i])) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "GT":
+			opts.GT = true
+		case "LT":
+			opts.LT = true
+		case "CH":
+			opts.CH = true
+		case "INCR":
+			incr = true
+		default:
+			break loop
+		}
+	}
+	args := cmd.Args[i:]
+
+	switch {
+	case opts.NX && opts.XX:
+		conn.WriteError("ERR XX and NX options at the same time are not compatible")
+		return
+	case (opts.GT && opts.LT) || (opts.NX && (opts.GT || opts.LT)):
+		conn.WriteError("ERR GT, LT, and/or NX options at the same time are not compatible")
+		return
+	case len(args) == 0 || len(args)%2 == 1:
+		writeError(conn, core.ErrSyntax)
+		return
+	case incr && len(args) != 2:
+		conn.WriteError("ERR INCR option supports a single increment-element pair")
+		return
+	}
+
+	members := make([]core.ZMember, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		score, err := parseScore(args[i])
+		if err != nil {
+			writeError(conn, err)
+			return
+		}
+		members = append(members, core.ZMember{Member: args[i+1], Score: score})
+	}
+
+	if incr {
+		score, ok, err := s.db.ZINCRBY(cmd.Args[1], members[0].Score, members[0].Member, opts)
+		switch {
+		case err != nil:
+			writeError(conn, err)
+		case !ok:
+			conn.WriteNull()
+		default:
+			s.waiters.signal(cmd.Args[1])
+			conn.WriteBulkString(core.FormatScore(score))
+		}
+		return
+	}
+
+	n, err := s.db.ZADD(cmd.Args[1], opts, members...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	s.waiters.signal(cmd.Args[1])
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleZCARD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 2 {
+		conn.WriteError("ERR wrong number of arguments for 'ZCARD' command")
+		return
+	}
+
+	n, err := s.db.ZCARD(cmd.Args[1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleZCOUNT(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'ZCOUNT' command")
+		return
+	}
+
+	min, max, err := parseScoreBounds(cmd.Args[2], cmd.Args[3])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	n, err := s.db.ZCOUNT(cmd.Args[1], min, max)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleZDIFF(conn redcon.Conn, cmd redcon.Command) {
+	s.zalgebraGeneric(conn, cmd, "ZDIFF", false, func(_ core.ZAggOptions, keys ...[]byte) ([]core.ZMember, error) {
+		return s.db.ZDIFF(keys...)
+	})
+}
+
+func (s *Server) handleZDIFFSTORE(conn redcon.Conn, cmd redcon.Command) {
+	s.zstoreGeneric(conn, cmd, "ZDIFFSTORE", false, func(dst []byte, _ core.ZAggOptions, keys ...[]byte) (int, error) {
+		return s.db.ZDIFFSTORE(dst, keys...)
+	})
+}
+
+func (s *Server) handleZINCRBY(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'ZINCRBY' command")
+		return
+	}
+
+	by, err := parseScore(cmd.Args[2])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	score, _, err := s.db.ZINCRBY(cmd.Args[1], by, cmd.Args[3], core.ZAddOptions{})
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	s.waiters.signal(cmd.Args[1])
+	conn.WriteBulkString(core.FormatScore(score))
+}
+
+func (s *Server) handleZINTER(conn redcon.Conn, cmd redcon.Command) {
+	s.zalgebraGeneric(conn, cmd, "ZINTER", true, s.db.ZINTER)
+}
+
+func (s *Server) handleZINTERCARD(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'ZINTERCARD' command")
+		return
+	}
+
+	keys, limit, err := parseInterCard(cmd.Args[1:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	n, err := s.db.ZINTERCARD(limit, keys...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleZINTERSTORE(conn redcon.Conn, cmd redcon.Command) {
+	s.zstoreGeneric(conn, cmd, "ZINTERSTORE", true, s.db.ZINTERSTORE)
+}
+
+func (s *Server) handleZLEXCOUNT(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'ZLEXCOUNT' command")
+		return
+	}
+
+	min, max, err := parseLexBounds(cmd.Args[2], cmd.Args[3])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	n, err := s.db.ZLEXCOUNT(cmd.Args[1], min, max)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleZMPOP(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for 'ZMPOP' command")
+		return
+	}
+
+	keys, max, count, err := parseZMPop(cmd.Args[1:])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	if !s.zmpop(conn, keys, max, count) {
+		conn.WriteNull()
+	}
+}
+
+func (s *Server) handleZMSCORE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'ZMSCORE' command")
+		return
+	}
+
+	res, err := s.db.ZMSCORE(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	conn.WriteArray(len(res))
+	for _, score := range res {
+		if score == nil {
+			conn.WriteNull()
+			continue
+		}
+		conn.WriteBulkString(core.FormatScore(*score))
+	}
+}
+
+func (s *Server) handleZPOPMAX(conn redcon.Conn, cmd redcon.Command) {
+	s.zpopGeneric(conn, cmd, "ZPOPMAX", s.db.ZPOPMAX)
+}
+
+func (s *Server) handleZPOPMIN(conn redcon.Conn, cmd redcon.Command) {
+	s.zpopGeneric(conn, cmd, "ZPOPMIN", s.db.ZPOPMIN)
+}
+
+func (s *Server) handleZRANGE(conn redcon.Conn, cmd redcon.Command) {
+	s.zrangeGeneric(conn, cmd, "ZRANGE", core.ZRange{}, false)
+}
+
+func (s *Server) handleZRANGEBYLEX(conn redcon.Conn, cmd redcon.Command) {
+	s.zrangeGeneric(conn, cmd, "ZRANGEBYLEX", core.ZRange{By: core.ZRangeByLex}, true)
+}
+
+func (s *Server) handleZRANGEBYSCORE(conn redcon.Conn, cmd redcon.Command) {
+	s.zrangeGeneric(conn, cmd, "ZRANGEBYSCORE", core.ZRange{By: core.ZRangeByScore}, true)
+}
+
+func (s *Server) handleZRANGESTORE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 5 {
+		conn.WriteError("ERR wrong number of arguments for 'ZRANGESTORE' command")
+		return
+	}
+
+	r, withScores, err := parseZRange(cmd.Args[3:], core.ZRange{}, false)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	if withScores {
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	n, err := s.db.ZRANGESTORE(cmd.Args[1], cmd.Args[2], r)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	if n > 0 {
+		s.waiters.signal(cmd.Args[1])
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleZRANK(conn redcon.Conn, cmd redcon.Command) {
+	s.zrankGeneric(conn, cmd, "ZRANK", s.db.ZRANK)
+}
+
+func (s *Server) handleZREM(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for 'ZREM' command")
+		return
+	}
+
+	n, err := s.db.ZREM(cmd.Args[1], cmd.Args[2:]...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleZREMRANGEBYLEX(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'ZREMRANGEBYLEX' command")
+		return
+	}
+
+	min, max, err := parseLexBounds(cmd.Args[2], cmd.Args[3])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	n, err := s.db.ZREMRANGEBYLEX(cmd.Args[1], min, max)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleZREMRANGEBYRANK(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'ZREMRANGEBYRANK' command")
+		return
+	}
+
+	start, err := strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+	stop, err := strconv.ParseInt(string(cmd.Args[3]), 10, 64)
+	if err != nil {
+		writeError(conn, core.ErrNotIntOrOutOfRange)
+		return
+	}
+
+	n, err := s.db.ZREMRANGEBYRANK(cmd.Args[1], int(start), int(stop))
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleZREMRANGEBYSCORE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for 'ZREMRANGEBYSCORE' command")
+		return
+	}
+
+	min, max, err := parseScoreBounds(cmd.Args[2], cmd.Args[3])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	n, err := s.db.ZREMRANGEBYSCORE(cmd.Args[1], min, max)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) handleZREVRANGE(conn redcon.Conn, cmd redcon.Command) {
+	s.zrangeGeneric(conn, cmd, "ZREVRANGE", core.ZRange{Rev: true}, true)
+}
+
+func (s *Server) handleZREVRANGEBYLEX(conn redcon.Conn, cmd redcon.Command) {
+	s.zrangeGeneric(conn, cmd, "ZREVRANGEBYLEX", core.ZRange{By: core.ZRangeByLex, Rev: true}, true)
+}
+
+func (s *Server) handleZREVRANGEBYSCORE(conn redcon.Conn, cmd redcon.Command) {
+	s.zrangeGeneric(conn, cmd, "ZREVRANGEBYSCORE", core.ZRange{By: core.ZRangeByScore, Rev: true}, true)
+}
+
+func (s *Server) handleZREVRANK(conn redcon.Conn, cmd redcon.Command) {
+	s.zrankGeneric(conn, cmd, "ZREVRANK", s.db.ZREVRANK)
+}
+
+func (s *Server) handleZSCORE(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'ZSCORE' command")
+		return
+	}
+
+	score, ok, err := s.db.ZSCORE(cmd.Args[1], cmd.Args[2])
+	switch {
+	case err != nil:
+		writeError(conn, err)
+	case !ok:
+		conn.WriteNull()
+	default:
+		conn.WriteBulkString(core.FormatScore(score))
+	}
+}
+
+func (s *Server) handleZUNION(conn redcon.Conn, cmd redcon.Command) {
+	s.zalgebraGeneric(conn, cmd, "ZUNION", true, s.db.ZUNION)
+}
+
+func (s *Server) handleZUNIONSTORE(conn redcon.Conn, cmd redcon.Command) {
+	s.zstoreGeneric(conn, cmd, "ZUNIONSTORE", true, s.db.ZUNIONSTORE)
+}
+
+// zalgebraGeneric handles ZUNION, ZINTER and ZDIFF, the latter doesn't accept WEIGHTS and AGGREGATE.
+func (s *Server) zalgebraGeneric(conn redcon.Conn, cmd redcon.Command, name string, weighted bool, fn func(opts core.ZAggOptions, keys ...[]byte) ([]core.ZMember, error)) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	keys, opts, withScores, err := parseZAgg(cmd.Args[1:], name, weighted, true)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := fn(opts, keys...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeZMembers(conn, res, withScores)
+}
+
+// zstoreGeneric handles ZUNIONSTORE, ZINTERSTORE and ZDIFFSTORE.
+func (s *Server) zstoreGeneric(conn redcon.Conn, cmd redcon.Command, name string, weighted bool, fn func(dst []byte, opts core.ZAggOptions, keys ...[]byte) (int, error)) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	keys, opts, _, err := parseZAgg(cmd.Args[2:], name, weighted, false)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	n, err := fn(cmd.Args[1], opts, keys...)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	if n > 0 {
+		s.waiters.signal(cmd.Args[1])
+	}
+	conn.WriteInt(n)
+}
+
+func (s *Server) zpopGeneric(conn redcon.Conn, cmd redcon.Command, name string, fn func(key []byte, count int) ([]core.ZMember, error)) {
+	if len(cmd.Args) != 2 && len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	count := int64(1)
+	if len(cmd.Args) == 3 {
+		var err error
+		count, err = strconv.ParseInt(string(cmd.Args[2]), 10, 64)
+		if err != nil || count < 0 {
+			conn.WriteError("ERR value is out of range, must be positive")
+			return
+		}
+	}
+
+	res, err := fn(cmd.Args[1], int(count))
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeZMembers(conn, res, true)
+}
+
+// bzpopGeneric blocks until a member is popped from the first non-empty sorted set.
+func (s *Server) bzpopGeneric(conn redcon.Conn, cmd redcon.Command, name string, max bool) {
+	if len(cmd.Args) < 3 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+	cmd = cloneCommand(cmd)
+
+	deadline, err := parseTimeout(cmd.Args[len(cmd.Args)-1])
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	keys := cmd.Args[1 : len(cmd.Args)-1]
+
+	s.block(conn, keys, deadline, func(conn redcon.Conn) bool {
+		for _, key := range keys {
+			res, err := s.zpop(key, max, 1)
+			if err != nil {
+				writeError(conn, err)
+				return true
+			}
+			if len(res) == 0 {
+				continue
+			}
+			conn.WriteArray(3)
+			conn.WriteBulk(key)
+			conn.WriteBulk(res[0].Member)
+			conn.WriteBulkString(core.FormatScore(res[0].Score))
+			return true
+		}
+		return false
+	})
+}
+
+// zmpop pops up to count members from the first non-empty sorted set, reports false if all are empty.
+func (s *Server) zmpop(conn redcon.Conn, keys [][]byte, max bool, count int) bool {
+	for _, key := range keys {
+		res, err := s.zpop(key, max, count)
+		if err != nil {
+			writeError(conn, err)
+			return true
+		}
+		if len(res) == 0 {
+			continue
+		}
+		conn.WriteArray(2)
+		conn.WriteBulk(key)
+		conn.WriteArray(len(res))
+		for _, m := range res {
+			conn.WriteArray(2)
+			conn.WriteBulk(m.Member)
+			conn.WriteBulkString(core.FormatScore(m.Score))
+		}
+		return true
+	}
+	return false
+}
+
+func (s *Server) zpop(key []byte, max bool, count int) ([]core.ZMember, error) {
+	if max {
+		return s.db.ZPOPMAX(key, count)
+	}
+	return s.db.ZPOPMIN(key, count)
+}
+
+// zrangeGeneric handles ZRANGE and its legacy variants, which set r.By and r.Rev
+// by the command name instead of options.
+func (s *Server) zrangeGeneric(conn redcon.Conn, cmd redcon.Command, name string, r core.ZRange, legacy bool) {
+	if len(cmd.Args) < 4 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+
+	r, withScores, err := parseZRange(cmd.Args[2:], r, legacy)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+
+	res, err := s.db.ZRANGE(cmd.Args[1], r)
+	if err != nil {
+		writeError(conn, err)
+		return
+	}
+	writeZMembers(conn, res, withScores)
+}
+
+func (s *Server) zrankGeneric(conn redcon.Conn, cmd redcon.Command, name string, fn func(key, member []byte) (int, float64, bool, error)) {
+	if len(cmd.Args) != 3 && len(cmd.Args) != 4 {
+		conn.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+	withScore := len(cmd.Args) == 4
+	if withScore && !strings.EqualFold(string(cmd.Args[3]), "WITHSCORE") {
+		writeError(conn, core.ErrSyntax)
+		return
+	}
+
+	rank, score, ok, err := fn(cmd.Args[1], cmd.Args[2])
+	switch {
+	case err != nil:
+		writeError(conn, err)
+	case !ok:
+		conn.WriteNull()
+	case withScore:
+		conn.WriteArray(2)
+		conn.WriteInt(rank)
+		conn.WriteBulkString(core.FormatScore(score))
+	default:
+		conn.WriteInt(rank)
+	}
+}
+
+// parseZAgg parses numkeys, keys and options of ZUNION family commands.
+// WEIGHTS and AGGREGATE are accepted when weighted is set, WITHSCORES when withScores is.
+func parseZAgg(args [][]byte, name string, weighted, withScores bool) ([][]byte, core.ZAggOptions, bool, error) {
+	var opts core.ZAggOptions
+	numkeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return nil, opts, false, core.ErrNotIntOrOutOfRange
+	}
+	if numkeys < 1 {
+		return nil, opts, false, errors.New("at least 1 input key is needed for '" + strings.ToLower(name) + "' command")
+	}
+	if numkeys > int64(len(args)-1) {
+		return nil, opts, false, core.ErrSyntax
+	}
+	keys, args := args[1:numkeys+1], args[numkeys+1:]
+
+	scores := false
+	for i := 0; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "WEIGHTS" && weighted && i+len(keys) < len(args):
+			opts.Weights = make([]float64, len(keys))
+			for j := range keys {
+				i++
+				w, err := strconv.ParseFloat(string(args[i]), 64)
+				if err != nil || math.IsNaN(w) {
+					return nil, opts, false, errors.New("weight value is not a float")
+				}
+				opts.Weights[j] = w
+			}
+		case opt == "AGGREGATE" && weighted && i+1 < len(args):
+			i++
+			switch strings.ToUpper(string(args[i])) {
+			case "SUM":
+				opts.Aggregate = core.ZAggregateSum
+			case "MIN":
+				opts.Aggregate = core.ZAggregateMin
+			case "MAX":
+				opts.Aggregate = core.ZAggregateMax
+			default:
+				return nil, opts, false, core.ErrSyntax
+			}
+		case opt == "WITHSCORES" && withScores:
+			scores = true
+		default:
+			return nil, opts, false, core.ErrSyntax
+		}
+	}
+	return keys, opts, scores, nil
+}
+
+// parseZMPop parses `numkeys key [key ...] <MIN | MAX> [COUNT count]`.
+func parseZMPop(args [][]byte) ([][]byte, bool, int, error) {
+	numkeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return nil, false, 0, core.ErrNotIntOrOutOfRange
+	}
+	if numkeys <= 0 {
+		return nil, false, 0, errors.New("numkeys should be greater than 0")
+	}
+	if numkeys > int64(len(args)-2) {
+		return nil, false, 0, core.ErrSyntax
+	}
+	keys, args := args[1:numkeys+1], args[numkeys+1:]
+
+	var max bool
+	switch strings.ToUpper(string(args[0])) {
+	case "MIN":
+	case "MAX":
+		max = true
+	default:
+		return nil, false, 0, core.ErrSyntax
+	}
+
+	count := int64(1)
+	switch {
+	case len(args) == 1:
+	case len(args) == 3 && strings.EqualFold(string(args[1]), "COUNT"):
+		count, err = strconv.ParseInt(string(args[2]), 10, 64)
+		if err != nil || count <= 0 {
+			return nil, false, 0, errors.New("count should be greater than 0")
+		}
+	default:
+		return nil, false, 0, core.ErrSyntax
+	}
+	return keys, max, int(count), nil
+}
+
+// parseZRange parses bounds and options of ZRANGE family commands.
+// Legacy commands don't accept BYSCORE, BYLEX and REV options,
+// their bounds are in the same order as in ZRANGE: max goes first with REV.
+func parseZRange(args [][]byte, r core.ZRange, legacy bool) (core.ZRange, bool, error) {
+	withScores := false
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "WITHSCORES":
+			withScores = true
+		case opt == "BYSCORE" && !legacy:
+			r.By = core.ZRangeByScore
+		case opt == "BYLEX" && !legacy:
+			r.By = core.ZRangeByLex
+		case opt == "REV" && !legacy:
+			r.Rev = true
+		case opt == "LIMIT" && i+2 < len(args):
+			offset, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return core.ZRange{}, false, core.ErrNotIntOrOutOfRange
+			}
+			count, err := strconv.ParseInt(string(args[i+2]), 10, 64)
+			if err != nil {
+				return core.ZRange{}, false, core.ErrNotIntOrOutOfRange
+			}
+			r.Limit, r.Offset, r.Count = true, int(offset), int(count)
+			i += 2
+		default:
+			return core.ZRange{}, false, core.ErrSyntax
+		}
+	}
+
+	switch {
+	case r.Limit && r.By == core.ZRangeByIndex:
+		return core.ZRange{}, false, errors.New("syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	case withScores && r.By == core.ZRangeByLex:
+		return core.ZRange{}, false, errors.New("syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+
+	min, max := args[0], args[1]
+	if r.Rev && r.By != core.ZRangeByIndex {
+		min, max = max, min
+	}
+
+	var err error
+	switch r.By {
+	case core.ZRangeByScore:
+		r.Min, r.Max, err = parseScoreBounds(min, max)
+	case core.ZRangeByLex:
+		r.LexMin, r.LexMax, err = parseLexBounds(min, max)
+	default:
+		var start, stop int64
+		start, err = strconv.ParseInt(string(min), 10, 64)
+		if err == nil {
+			stop, err = strconv.ParseInt(string(max), 10, 64)
+		}
+		if err != nil {
+			err = core.ErrNotIntOrOutOfRange
+		}
+		r.Start, r.Stop = int(start), int(stop)
+	}
+	if err != nil {
+		return core.ZRange{}, false, err
+	}
+	return r, withScores, nil
+}
+
+// parseScore parses a score or an increment, NaN is not a valid score.
+func parseScore(arg []byte) (float64, error) {
+	score, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, core.ErrNotFloat
+	}
+	return score, nil
+}
+
+// parseScoreBounds parses min and max like ZRANGEBYSCORE does, ( prefix makes a bound exclusive.
+func parseScoreBounds(min, max []byte) (core.ScoreBound, core.ScoreBound, error) {
+	lo, err := parseScoreBound(min)
+	if err != nil {
+		return core.ScoreBound{}, core.ScoreBound{}, err
+	}
+	hi, err := parseScoreBound(max)
+	if err != nil {
+		return core.ScoreBound{}, core.ScoreBound{}, err
+	}
+	return lo, hi, nil
+}
+
+func parseScoreBound(arg []byte) (core.ScoreBound, error) {
+	var b core.ScoreBound
+	if len(arg) > 0 && arg[0] == '(' {
+		b.Exclusive = true
+		arg = arg[1:]
+	}
+	score, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(score) {
+		return core.ScoreBound{}, errNotFloatBound
+	}
+	b.Value = score
+	return b, nil
+}
+
+// parseLexBounds parses min and max like ZRANGEBYLEX does: - and + or [ and ( prefixed values.
+func parseLexBounds(min, max []byte) (core.LexBound, core.LexBound, error) {
+	lo, err := parseLexBound(min)
+	if err != nil {
+		return core.LexBound{}, core.LexBound{}, err
+	}
+	hi, err := parseLexBound(max)
+	if err != nil {
+		return core.LexBound{}, core.LexBound{}, err
+	}
+	return lo, hi, nil
+}
+
+func parseLexBound(arg []byte) (core.LexBound, error) {
+	switch {
+	case string(arg) == "-":
+		return core.LexBound{Inf: -1}, nil
+	case string(arg) == "+":
+		return core.LexBound{Inf: 1}, nil
+	case len(arg) > 0 && arg[0] == '[':
+		return core.LexBound{Value: arg[1:]}, nil
+	case len(arg) > 0 && arg[0] == '(':
+		return core.LexBound{Value: arg[1:], Exclusive: true}, nil
+	default:
+		return core.LexBound{}, errNotLexBound
+	}
+}
+
+// writeZMembers writes members as a flat array, each one followed by its score if withScores is set.
+func writeZMembers(conn redcon.Conn, members []core.ZMember, withScores bool) {
+	if !withScores {
+		conn.WriteArray(len(members))
+		for _, m := range members {
+			conn.WriteBulk(m.Member)
+		}
+		return
+	}
+	conn.WriteArray(2 * len(members))
+	for _, m := range members {
+		conn.WriteBulk(m.Member)
+		conn.WriteBulkString(core.FormatScore(m.Score))
+	}
+}
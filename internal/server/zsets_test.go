@@ -0,0 +1,450 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cristalhq/testt"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestZADD(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one"
+		(integer) 1
+		redis> ZADD myzset 1 "uno"
+		(integer) 1
+		redis> ZADD myzset 2 "two" 3 "three"
+		(integer) 2
+		redis> ZRANGE myzset 0 -1 WITHSCORES
+		1) "one"
+		2) "1"
+		3) "uno"
+		4) "1"
+		5) "two"
+		6) "2"
+		7) "three"
+		8) "3"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	n, err := client.ZAdd(ctx, "myzset", redis.Z{Score: 1, Member: "one"}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.ZAdd(ctx, "myzset", redis.Z{Score: 1, Member: "uno"}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.ZAdd(ctx, "myzset", redis.Z{Score: 2, Member: "two"}, redis.Z{Score: 3, Member: "three"}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	res, err := client.ZRangeWithScores(ctx, "myzset", 0, -1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []redis.Z{
+		{Score: 1, Member: "one"},
+		{Score: 1, Member: "uno"},
+		{Score: 2, Member: "two"},
+		{Score: 3, Member: "three"},
+	})
+
+	score, err := client.ZAddArgsIncr(ctx, "myzset", redis.ZAddArgs{
+		GT:      true,
+		Members: []redis.Z{{Score: 1.5, Member: "one"}},
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, score, 2.5)
+
+	_, err = client.ZAddArgsIncr(ctx, "myzset", redis.ZAddArgs{
+		GT:      true,
+		Members: []redis.Z{{Score: -1, Member: "one"}},
+	}).Result()
+	testt.MustEqual(t, err, redis.Nil)
+
+	err = client.Do(ctx, "ZADD", "myzset", "NX", "XX", "1", "one").Err()
+	testt.MustEqual(t, err.Error(), "ERR XX and NX options at the same time are not compatible")
+
+	err = client.Do(ctx, "ZADD", "myzset", "NX", "GT", "1", "one").Err()
+	testt.MustEqual(t, err.Error(), "ERR GT, LT, and/or NX options at the same time are not compatible")
+
+	err = client.Do(ctx, "ZADD", "myzset", "INCR", "1", "one", "2", "two").Err()
+	testt.MustEqual(t, err.Error(), "ERR INCR option supports a single increment-element pair")
+
+	err = client.Do(ctx, "ZADD", "myzset", "nan", "one").Err()
+	testt.MustEqual(t, err.Error(), "ERR value is not a valid float")
+
+	err = client.Do(ctx, "ZADD", "myzset", "1", "one", "2").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+}
+
+func TestZRANGE(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one" 2 "two" 3 "three"
+		(integer) 3
+		redis> ZRANGE myzset 0 1 REV
+		1) "three"
+		2) "two"
+		redis> ZRANGE myzset (1 +inf BYSCORE LIMIT 1 1
+		1) "three"
+		redis> ZREVRANGEBYSCORE myzset +inf -inf WITHSCORES
+		1) "three"
+		2) "3"
+		3) "two"
+		4) "2"
+		5) "one"
+		6) "1"
+		redis> ZRANGE myzset 0 -1 LIMIT 0 1
+		(error) ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.ZAdd(ctx, "myzset",
+		redis.Z{Score: 1, Member: "one"},
+		redis.Z{Score: 2, Member: "two"},
+		redis.Z{Score: 3, Member: "three"},
+	).Err()
+	testt.NoError(t, err)
+
+	res, err := client.ZRangeArgs(ctx, redis.ZRangeArgs{Key: "myzset", Start: 0, Stop: 1, Rev: true}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []string{"three", "two"})
+
+	res, err = client.ZRangeArgs(ctx, redis.ZRangeArgs{
+		Key: "myzset", Start: "(1", Stop: "+inf", ByScore: true, Offset: 1, Count: 1,
+	}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []string{"three"})
+
+	withScores, err := client.ZRevRangeByScoreWithScores(ctx, "myzset", &redis.ZRangeBy{Min: "-inf", Max: "+inf"}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, withScores, []redis.Z{
+		{Score: 3, Member: "three"},
+		{Score: 2, Member: "two"},
+		{Score: 1, Member: "one"},
+	})
+
+	res, err = client.ZRevRange(ctx, "myzset", 0, 0).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []string{"three"})
+
+	n, err := client.ZRangeStore(ctx, "dst", redis.ZRangeArgs{Key: "myzset", Start: "2", Stop: "3", ByScore: true}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	err = client.Do(ctx, "ZRANGE", "myzset", "0", "-1", "LIMIT", "0", "1").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+
+	err = client.Do(ctx, "ZRANGE", "myzset", "-", "+", "BYLEX", "WITHSCORES").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+
+	err = client.Do(ctx, "ZRANGEBYSCORE", "myzset", "a", "1").Err()
+	testt.MustEqual(t, err.Error(), "ERR min or max is not a float")
+
+	err = client.Do(ctx, "ZRANGEBYLEX", "myzset", "a", "+").Err()
+	testt.MustEqual(t, err.Error(), "ERR min or max not valid string range item")
+
+	err = client.Do(ctx, "ZRANGEBYSCORE", "myzset", "1", "2", "REV").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+}
+
+func TestZRANGEBYLEX(t *testing.T) {
+	/*
+		redis> ZADD myzset 0 a 0 b 0 c 0 d 0 e 0 f 0 g
+		(integer) 7
+		redis> ZRANGEBYLEX myzset [aaa (g
+		1) "b"
+		2) "c"
+		3) "d"
+		4) "e"
+		5) "f"
+		redis> ZREVRANGEBYLEX myzset [c -
+		1) "c"
+		2) "b"
+		3) "a"
+		redis> ZLEXCOUNT myzset [b [f
+		(integer) 5
+		redis> ZREMRANGEBYLEX myzset - (c
+		(integer) 2
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	for _, member := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		err := client.ZAdd(ctx, "myzset", redis.Z{Member: member}).Err()
+		testt.NoError(t, err)
+	}
+
+	res, err := client.ZRangeByLex(ctx, "myzset", &redis.ZRangeBy{Min: "[aaa", Max: "(g"}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []string{"b", "c", "d", "e", "f"})
+
+	res, err = client.ZRevRangeByLex(ctx, "myzset", &redis.ZRangeBy{Min: "-", Max: "[c"}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []string{"c", "b", "a"})
+
+	n, err := client.ZLexCount(ctx, "myzset", "[b", "[f").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(5))
+
+	n, err = client.ZRemRangeByLex(ctx, "myzset", "-", "(c").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+}
+
+func TestZPOPMIN(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one" 2 "two" 3 "three"
+		(integer) 3
+		redis> ZPOPMIN myzset
+		1) "one"
+		2) "1"
+		redis> ZPOPMAX myzset 5
+		1) "three"
+		2) "3"
+		3) "two"
+		4) "2"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.ZAdd(ctx, "myzset",
+		redis.Z{Score: 1, Member: "one"},
+		redis.Z{Score: 2, Member: "two"},
+		redis.Z{Score: 3, Member: "three"},
+	).Err()
+	testt.NoError(t, err)
+
+	res, err := client.ZPopMin(ctx, "myzset").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []redis.Z{{Score: 1, Member: "one"}})
+
+	res, err = client.ZPopMax(ctx, "myzset", 5).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []redis.Z{{Score: 3, Member: "three"}, {Score: 2, Member: "two"}})
+
+	res, err = client.ZPopMin(ctx, "myzset").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []redis.Z{})
+
+	err = client.Do(ctx, "ZPOPMIN", "myzset", "-1").Err()
+	testt.MustEqual(t, err.Error(), "ERR value is out of range, must be positive")
+}
+
+func TestZRANK(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one" 2 "two" 3 "three"
+		(integer) 3
+		redis> ZRANK myzset "three"
+		(integer) 2
+		redis> ZRANK myzset "four"
+		(nil)
+		redis> ZREVRANK myzset "one" WITHSCORE
+		1) (integer) 2
+		2) "1"
+		redis> ZSCORE myzset "two"
+		"2"
+		redis> ZMSCORE myzset "one" "four"
+		1) "1"
+		2) (nil)
+		redis> ZINCRBY myzset 0.5 "one"
+		"1.5"
+		redis> ZCOUNT myzset (1.5 +inf
+		(integer) 2
+		redis> ZADD big 1000000 a
+		(integer) 1
+		redis> ZSCORE big a
+		"1000000"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.ZAdd(ctx, "myzset",
+		redis.Z{Score: 1, Member: "one"},
+		redis.Z{Score: 2, Member: "two"},
+		redis.Z{Score: 3, Member: "three"},
+	).Err()
+	testt.NoError(t, err)
+
+	rank, err := client.ZRank(ctx, "myzset", "three").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, rank, int64(2))
+
+	_, err = client.ZRank(ctx, "myzset", "four").Result()
+	testt.MustEqual(t, err, redis.Nil)
+
+	rs, err := client.ZRevRankWithScore(ctx, "myzset", "one").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, rs, redis.RankScore{Rank: 2, Score: 1})
+
+	score, err := client.ZScore(ctx, "myzset", "two").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, score, 2.0)
+
+	err = client.ZAdd(ctx, "big", redis.Z{Score: 1e6, Member: "a"}, redis.Z{Score: 0.00001, Member: "b"}).Err()
+	testt.NoError(t, err)
+	scores, err := client.Do(ctx, "ZMSCORE", "big", "a", "b").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, scores, []any{"1000000", "1e-05"})
+
+	scores, err = client.Do(ctx, "ZMSCORE", "myzset", "one", "four").Slice()
+	testt.NoError(t, err)
+	testt.MustEqual(t, scores, []any{"1", nil})
+
+	val, err := client.Do(ctx, "ZINCRBY", "myzset", "0.5", "one").Text()
+	testt.NoError(t, err)
+	testt.MustEqual(t, val, "1.5")
+
+	n, err := client.ZCount(ctx, "myzset", "(1.5", "+inf").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(2))
+
+	n, err = client.ZCard(ctx, "myzset").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(3))
+
+	typ, err := client.Type(ctx, "myzset").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, typ, "zset")
+}
+
+func TestZREMRANGE(t *testing.T) {
+	/*
+		redis> ZADD myzset 1 "one" 2 "two" 3 "three" 4 "four"
+		(integer) 4
+		redis> ZREM myzset "four" "five"
+		(integer) 1
+		redis> ZREMRANGEBYRANK myzset 0 0
+		(integer) 1
+		redis> ZREMRANGEBYSCORE myzset -inf (3
+		(integer) 1
+		redis> ZRANGE myzset 0 -1 WITHSCORES
+		1) "three"
+		2) "3"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.ZAdd(ctx, "myzset",
+		redis.Z{Score: 1, Member: "one"},
+		redis.Z{Score: 2, Member: "two"},
+		redis.Z{Score: 3, Member: "three"},
+		redis.Z{Score: 4, Member: "four"},
+	).Err()
+	testt.NoError(t, err)
+
+	n, err := client.ZRem(ctx, "myzset", "four", "five").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.ZRemRangeByRank(ctx, "myzset", 0, 0).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	n, err = client.ZRemRangeByScore(ctx, "myzset", "-inf", "(3").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	res, err := client.ZRangeWithScores(ctx, "myzset", 0, -1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []redis.Z{{Score: 3, Member: "three"}})
+}
+
+func TestZUNIONSTORE(t *testing.T) {
+	/*
+		redis> ZADD zset1 1 "one"
+		(integer) 1
+		redis> ZADD zset1 2 "two"
+		(integer) 1
+		redis> ZADD zset2 1 "one"
+		(integer) 1
+		redis> ZADD zset2 2 "two"
+		(integer) 1
+		redis> ZADD zset2 3 "three"
+		(integer) 1
+		redis> ZUNIONSTORE out 2 zset1 zset2 WEIGHTS 2 3
+		(integer) 3
+		redis> ZRANGE out 0 -1 WITHSCORES
+		1) "one"
+		2) "5"
+		3) "three"
+		4) "9"
+		5) "two"
+		6) "10"
+		redis>
+	*/
+
+	ctx := context.Background()
+	addr := testServer(t)
+	client := testClient(t, addr)
+
+	err := client.ZAdd(ctx, "zset1", redis.Z{Score: 1, Member: "one"}, redis.Z{Score: 2, Member: "two"}).Err()
+	testt.NoError(t, err)
+	err = client.ZAdd(ctx, "zset2", redis.Z{Score: 1, Member: "one"}, redis.Z{Score: 2, Member: "two"}, redis.Z{Score: 3, Member: "three"}).Err()
+	testt.NoError(t, err)
+
+	n, err := client.ZUnionStore(ctx, "out", &redis.ZStore{Keys: []string{"zset1", "zset2"}, Weights: []float64{2, 3}}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(3))
+
+	res, err := client.ZRangeWithScores(ctx, "out", 0, -1).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []redis.Z{
+		{Score: 5, Member: "one"},
+		{Score: 9, Member: "three"},
+		{Score: 10, Member: "two"},
+	})
+
+	members, err := client.ZInter(ctx, &redis.ZStore{Keys: []string{"zset1", "zset2"}, Aggregate: "MAX"}).Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, members, []string{"one", "two"})
+
+	res, err = client.ZDiffWithScores(ctx, "zset2", "zset1").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, res, []redis.Z{{Score: 3, Member: "three"}})
+
+	n, err = client.ZInterCard(ctx, 1, "zset1", "zset2").Result()
+	testt.NoError(t, err)
+	testt.MustEqual(t, n, int64(1))
+
+	err = client.Do(ctx, "ZUNION", "0", "zset1").Err()
+	testt.MustEqual(t, err.Error(), "ERR at least 1 input key is needed for 'zunion' command")
+
+	err = client.Do(ctx, "ZUNION", "3", "zset1", "zset2").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+
+	err = client.Do(ctx, "ZINTERSTORE", "out", "2", "zset1", "zset2", "WEIGHTS", "1", "x").Err()
+	testt.MustEqual(t, err.Error(), "ERR weight value is not a float")
+
+	err = client.Do(ctx, "ZUNION", "2", "zset1", "zset2", "AGGREGATE", "AVG").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+
+	err = client.Do(ctx, "ZDIFF", "2", "zset1", "zset2", "WEIGHTS", "1", "2").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+
+	err = client.Do(ctx, "ZUNIONSTORE", "out", "2", "zset1", "zset2", "WITHSCORES").Err()
+	testt.MustEqual(t, err.Error(), "ERR syntax error")
+
+	err = client.Do(ctx, "ZINTERCARD", "2", "zset1", "zset2", "LIMIT", "-1").Err()
+	testt.MustEqual(t, err.Error(), "ERR LIMIT can't be negative")
+}
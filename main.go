@@ -76,6 +76,7 @@ func run(ctx context.Context, args []string) error {
 	}
 
 	cg := synx.NewContextGroup(ctx)
+	cg.Go(store.Run)
 	cg.Go(srv.Run)
 
 	if err := cg.WaitErr(); err != nil && !errors.Is(err, context.Canceled) {